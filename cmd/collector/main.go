@@ -10,20 +10,108 @@ import (
 	_ "net/http/pprof" // Enable pprof endpoints
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/saintparish4/asmbly/internal/alerting"
+	"github.com/saintparish4/asmbly/internal/archive"
 	"github.com/saintparish4/asmbly/internal/collector"
+	"github.com/saintparish4/asmbly/internal/cost"
+	"github.com/saintparish4/asmbly/internal/gateway"
+	"github.com/saintparish4/asmbly/internal/plugin"
+	_ "github.com/saintparish4/asmbly/internal/plugin/dropfilter" // Register built-in processors
+	_ "github.com/saintparish4/asmbly/internal/plugin/otlpexport" // Register built-in exporters
+	"github.com/saintparish4/asmbly/internal/plugin/replicate"
+	_ "github.com/saintparish4/asmbly/internal/plugin/transform"
+	"github.com/saintparish4/asmbly/internal/prober"
+	"github.com/saintparish4/asmbly/internal/receiver"
 	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/internal/ui"
+	"github.com/saintparish4/asmbly/internal/wal"
 )
 
 // Config holds application configuration.
 type Config struct {
-	Port       int
-	Workers    int
-	LogLevel   string
-	MaxTraces  int
-	BufferSize int
+	Port            int
+	Workers         int
+	LogLevel        string
+	MaxTraces       int
+	Retention       time.Duration      // Max trace age (0 = keep until capacity eviction)
+	JanitorInterval time.Duration      // How often traces past retention are removed
+	Shards          int                // Storage shards; more let more writers proceed at once
+	DurationBuckets string             // Duration index buckets: bounds list or exponential spec
+	CostBuckets     string             // Cost index buckets: bounds list or exponential spec
+	Currency        string             // Unit of span costs
+	SpanLimits      storage.SpanLimits // Per-trace span and per-span tag limits (0 = unlimited)
+	BufferSize      int
+	MaxInFlight     int                       // Concurrent ingestion requests (0 = unlimited)
+	LoadShedding    bool                      // Discard whole traces while the queue is nearly full
+	QueueFull       string                    // What to do with spans while the queue is full: drop, block or spill
+	QueueTimeout    time.Duration             // How long the block policy waits for room
+	SpillDir        string                    // Directory spilled spans are written to (spill policy)
+	ApdexThreshold  time.Duration             // Satisfied response time for Apdex scores
+	TrackTopology   bool                      // Record service dependency graph changes
+	TopologyEdgeTTL time.Duration             // Unseen time before an edge counts as removed
+	QueryCache      int                       // FindTraces result cache entries (0 = disabled)
+	ConfigFile      string                    // Optional JSON file configuring pipeline components
+	GRPCAddr        string                    // Listen address for SDK gRPC export (empty = disabled)
+	OTLPAddr        string                    // Listen address for OTLP/gRPC (empty = disabled)
+	WALDir          string                    // Write-ahead log directory (empty = disabled)
+	WALSyncInterval time.Duration             // WAL fsync period (0 = every span)
+	Origin          collector.OriginConfig    // Tagging spans with the submitting client
+	RateLimit       collector.RateLimitConfig // Per-client span rate limit (replaced by the config file's rate_limit)
+	IdempotencyTTL  time.Duration             // How long batch responses are remembered by Idempotency-Key (0 = disabled)
+	Compress        bool                      // Compress large trace query responses for clients that accept it
+	StandbyURL      string                    // Standby collector to replicate stored spans to (empty = disabled)
+	TLS             collector.TLSConfig       // Serve the HTTP and gRPC listeners over TLS (mTLS with a client CA)
+	EnableChaos     bool                      // Allow failure injection through /api/v1/admin/chaos
+	AlertRules      string                    // JSON file of alert rules, reloaded when it changes (empty = disabled)
+	AlertInterval   time.Duration             // How often alert rules are evaluated
+	ReadyQueue      float64                   // Span queue fill fraction at which /readyz fails
+	UI              bool                      // Serve the trace explorer at /ui/
+}
+
+// FileConfig is the layout of the optional -config JSON file.
+type FileConfig struct {
+	plugin.Config
+
+	// Receivers are additional ingestion listeners, started alongside the
+	// built-in endpoints on the main HTTP server
+	Receivers []receiver.Spec `json:"receivers,omitempty"`
+
+	// Storage routes traces to several backends; when unset a single
+	// in-memory store is configured from the command line
+	Storage *storage.RoutingConfig `json:"storage,omitempty"`
+
+	// Materialized are queries refreshed in the background for dashboards
+	Materialized []collector.MaterializedSpec `json:"materialized,omitempty"`
+
+	// Notifiers are alert destinations that alert rules refer to by name
+	Notifiers []alerting.Spec `json:"notifiers,omitempty"`
+
+	// RateLimit replaces the -rate-limit flags, adding per-tenant overrides
+	RateLimit *collector.RateLimitConfig `json:"rate_limit,omitempty"`
+
+	// Sampling is served to SDKs, which adapt their sample rates to it
+	Sampling *collector.SamplingConfig `json:"sampling,omitempty"`
+
+	// Archive writes completed traces to object storage
+	Archive *archive.Config `json:"archive,omitempty"`
+
+	// Pricing assigns span costs from rules instead of trusting clients
+	Pricing *cost.Config `json:"pricing,omitempty"`
+
+	// Probes are synthetic HTTP checks recorded as traces
+	Probes *prober.Config `json:"probes,omitempty"`
+
+	// Gateway serves queries from regional collectors instead of a local
+	// store; it cannot be combined with Storage
+	Gateway *gateway.Config `json:"gateway,omitempty"`
 }
 
 func main() {
@@ -38,24 +126,285 @@ func main() {
 		"max_traces", config.MaxTraces,
 	)
 
+	// Load the TLS certificate before anything starts listening
+	tlsConfig, err := config.TLS.ServerConfig()
+	if err != nil {
+		logger.Error("invalid TLS configuration", "error", err)
+		os.Exit(1)
+	}
+	if tlsConfig != nil {
+		logger.Info("tls enabled", "cert", config.TLS.CertFile, "client_auth", config.TLS.ClientCAFile != "")
+	}
+
+	// Load pipeline components from the config file
+	fileConfig, err := loadFileConfig(config.ConfigFile)
+	if err != nil {
+		logger.Error("failed to load config file", "path", config.ConfigFile, "error", err)
+		os.Exit(1)
+	}
+
 	// Initialize storage
-	store := storage.NewMemoryStore(config.MaxTraces)
-	logger.Info("storage initialized", "type", "in-memory", "max_traces", config.MaxTraces)
+	var store storage.Store
+	var regions *gateway.Store
+	if fileConfig.Gateway != nil {
+		if fileConfig.Storage != nil {
+			logger.Error("the config file's gateway and storage sections cannot be combined")
+			os.Exit(1)
+		}
+		regions, err = gateway.New(*fileConfig.Gateway, logger)
+		if err != nil {
+			logger.Error("invalid gateway", "error", err)
+			os.Exit(1)
+		}
+		store = regions
+		logger.Info("storage initialized", "type", "gateway", "regions", regions.Regions())
+	} else if fileConfig.Storage != nil {
+		routing, err := storage.NewRoutingStore(fileConfig.Storage)
+		if err != nil {
+			logger.Error("failed to build storage", "error", err, "available", storage.Backends())
+			os.Exit(1)
+		}
+		store = routing
+		logger.Info("storage initialized", "type", "routing", "backends", len(fileConfig.Storage.Backends), "routes", len(fileConfig.Storage.Routes))
+	} else {
+		bounds, err := storage.ParseDurationBuckets(config.DurationBuckets)
+		if err != nil {
+			logger.Error("invalid duration buckets", "error", err)
+			os.Exit(1)
+		}
+		buckets, err := storage.NewDurationBuckets(bounds)
+		if err != nil {
+			logger.Error("invalid duration buckets", "error", err)
+			os.Exit(1)
+		}
+		costBounds, err := storage.ParseCostBuckets(config.CostBuckets)
+		if err != nil {
+			logger.Error("invalid cost buckets", "error", err)
+			os.Exit(1)
+		}
+		costBuckets, err := storage.NewCostBuckets(costBounds)
+		if err != nil {
+			logger.Error("invalid cost buckets", "error", err)
+			os.Exit(1)
+		}
+		store = storage.NewMemoryStore(config.MaxTraces).
+			WithShards(config.Shards).
+			WithRetention(config.Retention).
+			WithDurationBuckets(buckets).
+			WithCostBuckets(costBuckets).
+			WithCurrency(config.Currency).
+			WithLimits(config.SpanLimits).
+			StartJanitor(config.JanitorInterval)
+		logger.Info("storage initialized", "type", "in-memory", "max_traces", config.MaxTraces, "retention", config.Retention,
+			"janitor_interval", config.JanitorInterval, "shards", config.Shards,
+			"duration_buckets", config.DurationBuckets, "cost_buckets", config.CostBuckets, "currency", config.Currency, "span_limits", config.SpanLimits)
+	}
+	processors, err := fileConfig.BuildProcessors()
+	if err != nil {
+		logger.Error("failed to build processors", "error", err)
+		os.Exit(1)
+	}
+	exporters, err := fileConfig.BuildExporters()
+	if err != nil {
+		logger.Error("failed to build exporters", "error", err)
+		os.Exit(1)
+	}
+	if config.StandbyURL != "" {
+		replica, err := replicate.New(replicate.Config{Endpoint: config.StandbyURL})
+		if err != nil {
+			logger.Error("invalid standby URL", "error", err)
+			os.Exit(1)
+		}
+		exporters = append(exporters, replica)
+		logger.Info("replicating to standby", "url", config.StandbyURL)
+	}
+	for _, spec := range fileConfig.Materialized {
+		if err := spec.Validate(); err != nil {
+			logger.Error("invalid materialized view", "error", err)
+			os.Exit(1)
+		}
+	}
+	notifiers, err := alerting.NewSet(fileConfig.Notifiers)
+	if err != nil {
+		logger.Error("failed to build notifiers", "error", err, "available", alerting.Registered())
+		os.Exit(1)
+	}
+	logger.Info("plugins loaded",
+		"processors", len(processors),
+		"exporters", len(exporters),
+		"available_processors", plugin.Processors(),
+		"available_exporters", plugin.Exporters(),
+		"notifiers", notifiers.Names(),
+	)
+
+	// Optional alert rules on trace metrics
+	var alertRules *alerting.Engine
+	if config.AlertRules != "" {
+		alertRules, err = alerting.NewEngine(config.AlertRules, config.AlertInterval, store, notifiers, logger)
+		if err != nil {
+			logger.Error("invalid alert rules", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("alert rules loaded", "path", config.AlertRules, "rules", alertRules.Rules(), "interval", config.AlertInterval)
+	}
+
+	// What SubmitSpan does while the queue is full
+	queueFull, err := collector.ParseQueueFullPolicy(config.QueueFull)
+	if err != nil {
+		logger.Error("invalid queue full policy", "error", err)
+		os.Exit(1)
+	}
+	var spill *wal.Log
+	if queueFull == collector.QueueFullSpill {
+		if config.SpillDir == "" {
+			logger.Error("queue full policy spill requires -spill-dir")
+			os.Exit(1)
+		}
+		spill, err = wal.Open(config.SpillDir, wal.Options{SyncInterval: config.WALSyncInterval})
+		if err != nil {
+			logger.Error("failed to open spill log", "error", err)
+			os.Exit(1)
+		}
+	}
+	if queueFull != collector.QueueFullDrop {
+		logger.Info("queue full policy", "policy", queueFull, "timeout", config.QueueTimeout, "spill_dir", config.SpillDir)
+	}
+
+	// Optional write-ahead log, replayed when the collector starts
+	var spanLog *wal.Log
+	if config.WALDir != "" {
+		spanLog, err = wal.Open(config.WALDir, wal.Options{SyncInterval: config.WALSyncInterval})
+		if err != nil {
+			logger.Error("failed to open write-ahead log", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("write-ahead log enabled", "dir", spanLog.Dir(), "sync_interval", config.WALSyncInterval)
+	}
+
+	if fileConfig.Sampling != nil {
+		if err := fileConfig.Sampling.Validate(); err != nil {
+			logger.Error("invalid sampling config", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("serving sampling config", "services", len(fileConfig.Sampling.Services))
+	}
+
+	// Optional archive of completed traces
+	var traceArchive *archive.Archiver
+	if fileConfig.Archive != nil {
+		traceArchive, err = archive.New(*fileConfig.Archive, logger)
+		if err != nil {
+			logger.Error("invalid archive", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("archive enabled", "sink", traceArchive.String(), "format", traceArchive.Format())
+	}
+
+	// Optional span pricing
+	var pricer *cost.Pricer
+	if fileConfig.Pricing != nil {
+		pricer, err = cost.New(*fileConfig.Pricing)
+		if err != nil {
+			logger.Error("invalid pricing", "error", err)
+			os.Exit(1)
+		}
+		logger.Info("pricing spans", "rules", len(fileConfig.Pricing.Rules), "override", fileConfig.Pricing.Override, "currency", config.Currency)
+	}
+
+	// Optional synthetic checks, started once the collector is
+	var probes *prober.Prober
+	if fileConfig.Probes != nil {
+		probes, err = prober.New(*fileConfig.Probes, notifiers, logger)
+		if err != nil {
+			logger.Error("invalid probes", "error", err)
+			os.Exit(1)
+		}
+	}
 
 	// Initialize collector
+	rateLimit := config.RateLimit
+	if fileConfig.RateLimit != nil {
+		rateLimit = *fileConfig.RateLimit
+	}
+	if err := rateLimit.Validate(); err != nil {
+		logger.Error("invalid rate limit", "error", err)
+		os.Exit(1)
+	}
+	if rateLimit.Enabled() {
+		logger.Info("ingestion rate limit enabled", "spans_per_second", rateLimit.SpansPerSecond, "key_header", rateLimit.KeyHeader, "tenants", len(rateLimit.Tenants))
+	}
+
 	collectorConfig := &collector.Config{
-		Workers:       config.Workers,
-		ChannelBuffer: config.BufferSize,
+		Workers:        config.Workers,
+		ChannelBuffer:  config.BufferSize,
+		Processors:     processors,
+		Exporters:      exporters,
+		QueryCacheSize: config.QueryCache,
+		Materialized:   fileConfig.Materialized,
+
+		MaxInFlightRequests: config.MaxInFlight,
+		LoadShedding:        config.LoadShedding,
+		ApdexThreshold:      config.ApdexThreshold,
+		TrackTopology:       config.TrackTopology,
+		TopologyEdgeTTL:     config.TopologyEdgeTTL,
+		WAL:                 spanLog,
+		QueueFull:           queueFull,
+		QueueFullTimeout:    config.QueueTimeout,
+		Spill:               spill,
+		Origin:              config.Origin,
+		RateLimit:           rateLimit,
+		IdempotencyTTL:      config.IdempotencyTTL,
+		Sampling:            fileConfig.Sampling,
+		Archive:             traceArchive,
+		Pricing:             pricer,
+		EnableChaos:         config.EnableChaos,
+		ReadyQueueThreshold: config.ReadyQueue,
 	}
 	col := collector.NewCollector(store, collectorConfig, logger)
+	if config.EnableChaos {
+		logger.Warn("failure injection enabled, inactive until set at /api/v1/admin/chaos")
+	}
 
 	// Start collector workers
 	ctx := context.Background()
 	col.Start(ctx)
 	logger.Info("collector workers started", "count", config.Workers)
+	if alertRules != nil {
+		alertRules.Start()
+	}
+	if probes != nil {
+		probes.Start(col)
+		logger.Info("probes started", "checks", probes.Checks())
+	}
+
+	// Start additional receivers
+	receiverSpecs := fileConfig.Receivers
+	if config.GRPCAddr != "" {
+		grpcConfig, _ := json.Marshal(collector.GRPCReceiverConfig{Addr: config.GRPCAddr, Origin: config.Origin, TLS: config.TLS})
+		receiverSpecs = append(receiverSpecs, receiver.Spec{Name: "grpc", Config: grpcConfig})
+	}
+	if config.OTLPAddr != "" {
+		otlpConfig, _ := json.Marshal(collector.OTLPGRPCReceiverConfig{Addr: config.OTLPAddr, Origin: config.Origin, TLS: config.TLS})
+		receiverSpecs = append(receiverSpecs, receiver.Spec{Name: "otlp_grpc", Config: otlpConfig})
+	}
+	receivers, err := receiver.NewManager(receiverSpecs, logger)
+	if err != nil {
+		logger.Error("failed to build receivers", "error", err, "available", receiver.Registered())
+		os.Exit(1)
+	}
+	if err := receivers.Start(ctx, col); err != nil {
+		logger.Error("failed to start receivers", "error", err)
+		os.Exit(1)
+	}
 
 	// Setup HTTP routes
 	mux := http.NewServeMux()
+	compress := func(next http.HandlerFunc) http.HandlerFunc {
+		if !config.Compress {
+			return next
+		}
+		return collector.CompressionMiddleware(next)
+	}
 
 	// Span ingestion endpoints
 	mux.HandleFunc("/api/v1/spans",
@@ -69,15 +418,54 @@ func main() {
 		),
 	)
 
+	// OpenTelemetry OTLP/HTTP ingestion
+	mux.HandleFunc("/v1/traces",
+		collector.LoggingMiddleware(logger, col.HandleOTLPTraces),
+	)
+
+	// Zipkin v2 JSON ingestion
+	mux.HandleFunc("/api/v2/spans",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleZipkinSpans),
+		),
+	)
+
 	// Trace query endpoints
+	mux.HandleFunc("/api/v1/traces/stream",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleTraceStream),
+		),
+	)
+	mux.HandleFunc("/api/v1/traces/compare",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, compress(col.HandleCompareTraces)),
+		),
+	)
+	mux.HandleFunc("/api/v1/assertions",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleAssertions),
+		),
+	)
 	mux.HandleFunc("/api/v1/traces/",
 		collector.CORSMiddleware(
-			collector.LoggingMiddleware(logger, col.HandleGetTrace),
+			collector.LoggingMiddleware(logger, compress(col.HandleGetTrace)),
 		),
 	)
 	mux.HandleFunc("/api/v1/traces",
 		collector.CORSMiddleware(
-			collector.LoggingMiddleware(logger, col.HandleFindTraces),
+			collector.LoggingMiddleware(logger, compress(col.HandleFindTraces)),
+		),
+	)
+
+	// API v2 trace queries (summaries, typed attributes, cursor pagination)
+	mux.HandleFunc("/api/v2/traces/",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, compress(col.HandleGetTraceV2)),
+		),
+	)
+	mux.HandleFunc("/api/v2/traces",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, compress(col.HandleFindTracesV2)),
 		),
 	)
 
@@ -88,11 +476,152 @@ func main() {
 		),
 	)
 
+	// Trace-derived service metrics
+	mux.HandleFunc("/api/v1/metrics/services/",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleServiceMetrics),
+		),
+	)
+	mux.HandleFunc("/api/v1/ingest/lag",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleIngestLag),
+		),
+	)
+
+	// Stored span aggregates
+	mux.HandleFunc("/api/v1/deployments",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleDeployments),
+		),
+	)
+	mux.HandleFunc("/api/v1/deployments/",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleServiceDeployments),
+		),
+	)
+	mux.HandleFunc("/api/v1/stats/services",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleServiceStats),
+		),
+	)
+	mux.HandleFunc("/api/v1/stats/operations",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleOperationStats),
+		),
+	)
+	mux.HandleFunc("/api/v1/costs",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleCosts),
+		),
+	)
+
+	// Instrumentation diagnostics
+	mux.HandleFunc("/api/v1/diagnostics/fragmentation",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleFragmentation),
+		),
+	)
+
+	// Trend reports
+	mux.HandleFunc("/api/v1/reports",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleReports),
+		),
+	)
+
+	// Service dependency graph
+	mux.HandleFunc("/api/v1/topology",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleTopology),
+		),
+	)
+	mux.HandleFunc("/api/v1/topology/changes",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleTopologyChanges),
+		),
+	)
+
+	// Materialized query endpoints
+	mux.HandleFunc("/api/v1/materialized",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleMaterialized),
+		),
+	)
+	mux.HandleFunc("/api/v1/materialized/",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleMaterialized),
+		),
+	)
+
+	// Sampling config polled by SDKs
+	mux.HandleFunc("/api/v1/sampling",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleSampling),
+		),
+	)
+
+	// On-demand archive export
+	mux.HandleFunc("/api/v1/export",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleExport),
+		),
+	)
+
+	// Failure injection for soak tests; no CORS so browser pages cannot
+	// switch it on
+	mux.HandleFunc("/api/v1/admin/chaos", collector.LoggingMiddleware(logger, col.HandleChaos))
+
+	// Alert notifier endpoints
+	mux.HandleFunc("/api/v1/alerting/notifiers",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, notifiers.HandleNotifiers),
+		),
+	)
+	mux.HandleFunc("/api/v1/alerting/notifiers/",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, notifiers.HandleNotifiers),
+		),
+	)
+	mux.HandleFunc("/api/v1/alerting/rules",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, alertRules.HandleRules),
+		),
+	)
+
+	// Regions queried in gateway mode
+	mux.HandleFunc("/api/v1/gateway/regions",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, regions.HandleRegions),
+		),
+	)
+
+	// Synthetic check status
+	mux.HandleFunc("/api/v1/probes",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, probes.HandleProbes),
+		),
+	)
+
 	// Health check endpoint
 	mux.HandleFunc("/health", handleHealth(col))
 
-	// Metrics endpoint (Prometheus-compatible)
-	mux.HandleFunc("/metrics", handleMetrics(col))
+	// Liveness and readiness (lifecycle, storage, queue and workers) for
+	// load balancers and orchestrators
+	mux.HandleFunc("/healthz", col.HandleHealthz)
+	mux.HandleFunc("/readyz", col.HandleReadyz)
+	mux.HandleFunc("/api/v1/info",
+		collector.CORSMiddleware(
+			collector.LoggingMiddleware(logger, col.HandleInfo),
+		),
+	)
+
+	// Trace explorer, a single-page app on top of the query API
+	if config.UI {
+		mux.Handle("/ui/", http.StripPrefix("/ui", compress(collector.LoggingMiddleware(logger, ui.Handler().ServeHTTP))))
+	}
+
+	// Metrics endpoint (Prometheus)
+	mux.Handle("/metrics", promhttp.HandlerFor(newMetricsRegistry(col, receivers, probes, regions), promhttp.HandlerOpts{}))
 
 	// Create HTTP server
 	addr := fmt.Sprintf(":%d", config.Port)
@@ -102,6 +631,7 @@ func main() {
 		ReadTimeout:  10 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  120 * time.Second,
+		TLSConfig:    tlsConfig,
 	}
 
 	// Start pprof server on port 6060 (for profiling)
@@ -118,7 +648,11 @@ func main() {
 	// Start server in goroutine
 	serverErrors := make(chan error, 1)
 	go func() {
-		logger.Info("http server listening", "addr", addr)
+		logger.Info("http server listening", "addr", addr, "tls", tlsConfig != nil)
+		if tlsConfig != nil {
+			serverErrors <- server.ListenAndServeTLS("", "") // Certificates come from TLSConfig
+			return
+		}
 		serverErrors <- server.ListenAndServe()
 	}()
 
@@ -137,6 +671,13 @@ func main() {
 	case sig := <-shutdown:
 		logger.Info("shutdown signal received", "signal", sig)
 
+		// Finish probe runs while their spans are still accepted
+		probes.Stop()
+		alertRules.Stop()
+
+		// Refuse new spans and fail /readyz so clients move elsewhere
+		col.Drain()
+
 		// Graceful shutdown with timeout
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -152,12 +693,29 @@ func main() {
 			logger.Error("http server shutdown error", "error", err)
 			server.Close()
 		}
+		if err := receivers.Stop(ctx); err != nil {
+			logger.Error("receiver shutdown error", "error", err)
+		}
 
 		// Stop collector workers (drain in-flight spans)
 		if err := col.Stop(ctx); err != nil {
 			logger.Error("collector shutdown error", "error", err)
 		}
 
+		// Close the write-ahead log; it is empty if every span was processed
+		if spanLog != nil {
+			if err := spanLog.Close(); err != nil {
+				logger.Error("write-ahead log close error", "error", err)
+			}
+		}
+
+		// Close the spill log; spans still in it are queued after a restart
+		if spill != nil {
+			if err := spill.Close(); err != nil {
+				logger.Error("spill log close error", "error", err)
+			}
+		}
+
 		// Close storage
 		if err := store.Close(); err != nil {
 			logger.Error("storage close error", "error", err)
@@ -170,19 +728,79 @@ func main() {
 // parseConfig parses configuration from command-line flags and environment variables.
 func parseConfig() *Config {
 	config := &Config{}
+	var rateLimit int
 
 	// Define flags
 	flag.IntVar(&config.Port, "port", getEnvInt("PORT", 9090), "HTTP server port")
 	flag.IntVar(&config.Workers, "workers", getEnvInt("WORKERS", 10), "Number of worker goroutines")
 	flag.StringVar(&config.LogLevel, "log-level", getEnvString("LOG_LEVEL", "info"), "Log level (debug, info, warn, error)")
 	flag.IntVar(&config.MaxTraces, "max-traces", getEnvInt("MAX_TRACES", 10000), "Maximum traces to keep in memory")
+	flag.DurationVar(&config.Retention, "retention", getEnvDuration("RETENTION", 0), "Max trace age, e.g. 24h (0 = keep until max-traces eviction)")
+	flag.DurationVar(&config.JanitorInterval, "janitor-interval", getEnvDuration("JANITOR_INTERVAL", storage.DefaultJanitorInterval), "How often traces past -retention are removed in the background")
+	flag.IntVar(&config.Shards, "storage-shards", getEnvInt("STORAGE_SHARDS", storage.DefaultShards), "Shards the in-memory store splits traces across; raise on many-core hosts with heavy write load")
+	flag.StringVar(&config.DurationBuckets, "duration-buckets", getEnvString("DURATION_BUCKETS", storage.DefaultDurationBucketSpec), "Duration histogram buckets for the trace index and percentiles: ascending bounds (e.g. 1s,1m,10m) or exponential:START,FACTOR,COUNT")
+	flag.StringVar(&config.CostBuckets, "cost-buckets", getEnvString("COST_BUCKETS", storage.DefaultCostBucketSpec), "Cost histogram buckets in -currency units: ascending bounds or exponential:START,FACTOR,COUNT")
+	flag.StringVar(&config.Currency, "currency", getEnvString("CURRENCY", storage.DefaultCurrency), "Unit of span costs, reported on traces (e.g. USD, EUR, USD_MICROS)")
+	flag.IntVar(&config.SpanLimits.MaxSpansPerTrace, "max-spans-per-trace", getEnvInt("MAX_SPANS_PER_TRACE", 0), "Spans kept per trace; later spans are counted on the trace as dropped (0 = unlimited)")
+	flag.IntVar(&config.SpanLimits.MaxTagsPerSpan, "max-tags-per-span", getEnvInt("MAX_TAGS_PER_SPAN", 0), "Tags kept per span, first in key order (0 = unlimited)")
+	flag.IntVar(&config.SpanLimits.MaxTagValueLength, "max-tag-value-length", getEnvInt("MAX_TAG_VALUE_LENGTH", 0), "Bytes kept per tag value (0 = unlimited)")
 	flag.IntVar(&config.BufferSize, "buffer-size", getEnvInt("BUFFER_SIZE", 1000), "Span channel buffer size")
+	flag.IntVar(&config.MaxInFlight, "max-in-flight", getEnvInt("MAX_IN_FLIGHT", 256), "Concurrent ingestion requests before 503 (0 = unlimited)")
+	flag.BoolVar(&config.LoadShedding, "load-shedding", getEnvBool("LOAD_SHEDDING", false), "Past 80% queue fill, discard whole traces by trace ID instead of refusing spans once the queue is full")
+	flag.IntVar(&config.QueryCache, "query-cache-size", getEnvInt("QUERY_CACHE_SIZE", 0), "Cached FindTraces results, served for 5s (0 = disabled)")
+	flag.DurationVar(&config.ApdexThreshold, "apdex-threshold", getEnvDuration("APDEX_THRESHOLD", collector.DefaultApdexThreshold), "Span duration counted as satisfied in Apdex scores (tolerating up to 4x)")
+	flag.BoolVar(&config.TrackTopology, "track-topology", getEnvBool("TRACK_TOPOLOGY", false), "Track the service dependency graph and report added/removed edges at /api/v1/topology/changes")
+	flag.DurationVar(&config.TopologyEdgeTTL, "topology-edge-ttl", getEnvDuration("TOPOLOGY_EDGE_TTL", collector.DefaultTopologyEdgeTTL), "How long a dependency edge may go unseen before it is reported as removed")
+	flag.StringVar(&config.ConfigFile, "config", getEnvString("CONFIG_FILE", ""), "Path to JSON config file for processors and exporters")
+	flag.StringVar(&config.OTLPAddr, "otlp-grpc-addr", getEnvString("OTLP_GRPC_ADDR", ":4317"), "Listen address for the OTLP/gRPC trace receiver (empty = disabled)")
+	flag.StringVar(&config.GRPCAddr, "grpc-addr", getEnvString("GRPC_ADDR", ""), "Listen address for SDK gRPC span export (empty = disabled)")
+	flag.StringVar(&config.QueueFull, "queue-full-policy", getEnvString("QUEUE_FULL_POLICY", "drop"), "What to do with spans while the queue is full: drop (refuse), block (wait up to -queue-full-timeout) or spill (write to -spill-dir)")
+	flag.DurationVar(&config.QueueTimeout, "queue-full-timeout", getEnvDuration("QUEUE_FULL_TIMEOUT", collector.DefaultQueueFullTimeout), "How long the block queue full policy waits for room before refusing a span")
+	flag.StringVar(&config.SpillDir, "spill-dir", getEnvString("SPILL_DIR", ""), "Directory spans are spilled to while the queue is full (spill queue full policy)")
+	flag.StringVar(&config.WALDir, "wal-dir", getEnvString("WAL_DIR", ""), "Directory for a write-ahead log of accepted spans, replayed on startup (empty = disabled)")
+	flag.DurationVar(&config.WALSyncInterval, "wal-sync-interval", getEnvDuration("WAL_SYNC_INTERVAL", 0), "Fsync the write-ahead log on this period instead of per span (0 = every span)")
+	flag.BoolVar(&config.Origin.Enabled, "tag-origin", getEnvBool("TAG_ORIGIN", false), "Tag ingested spans with the client address, user agent and identity")
+	flag.BoolVar(&config.Origin.TrustForwardedFor, "trust-forwarded-for", getEnvBool("TRUST_FORWARDED_FOR", false), "Take the client address for -tag-origin and -rate-limit from X-Forwarded-For (only behind a proxy)")
+	flag.StringVar(&config.Origin.IdentityHeader, "origin-identity-header", getEnvString("ORIGIN_IDENTITY_HEADER", ""), "Request header (gRPC metadata key) naming the client for -tag-origin, e.g. X-Client-ID")
+	flag.IntVar(&rateLimit, "rate-limit", getEnvInt("RATE_LIMIT", 0), "Spans per second each client (or -rate-limit-key-header tenant) may submit over HTTP before 429 (0 = unlimited)")
+	flag.IntVar(&config.RateLimit.Burst, "rate-limit-burst", getEnvInt("RATE_LIMIT_BURST", 0), "Spans a client may submit at once under -rate-limit (0 = one second's worth)")
+	flag.StringVar(&config.RateLimit.KeyHeader, "rate-limit-key-header", getEnvString("RATE_LIMIT_KEY_HEADER", ""), "Request header naming the tenant for -rate-limit, e.g. X-Tenant-ID (default: client address)")
+	flag.DurationVar(&config.IdempotencyTTL, "idempotency-ttl", getEnvDuration("IDEMPOTENCY_TTL", collector.DefaultIdempotencyTTL), "How long batch responses are remembered by Idempotency-Key so retried batches are not ingested twice (0 = disabled)")
+	flag.BoolVar(&config.Compress, "compress-responses", getEnvBool("COMPRESS_RESPONSES", true), "Compress trace query responses over 1 KiB with zstd or gzip when the client sends Accept-Encoding")
+	flag.StringVar(&config.StandbyURL, "standby-url", getEnvString("STANDBY_URL", ""), "Base URL of a standby collector to replicate stored spans to, e.g. http://standby:9090 (empty = disabled)")
+	flag.StringVar(&config.TLS.CertFile, "tls-cert", getEnvString("TLS_CERT", ""), "PEM certificate chain; serves the HTTP and gRPC listeners over TLS (requires -tls-key)")
+	flag.StringVar(&config.TLS.KeyFile, "tls-key", getEnvString("TLS_KEY", ""), "PEM private key for -tls-cert")
+	flag.StringVar(&config.TLS.ClientCAFile, "tls-client-ca", getEnvString("TLS_CLIENT_CA", ""), "PEM CA bundle; clients must present a certificate it signed (mutual TLS)")
+	flag.Float64Var(&config.ReadyQueue, "ready-queue-threshold", getEnvFloat("READY_QUEUE_THRESHOLD", collector.DefaultReadyQueueThreshold), "Fraction of the span queue in use at which /readyz reports not ready, so load balancers route spans elsewhere")
+	flag.StringVar(&config.AlertRules, "alert-rules", getEnvString("ALERT_RULES", ""), "JSON file of alert rules on trace metrics, reloaded when it changes; rules send to the config file's notifiers (empty = disabled)")
+	flag.DurationVar(&config.AlertInterval, "alert-interval", getEnvDuration("ALERT_INTERVAL", alerting.DefaultEvalInterval), "How often alert rules are evaluated and their file checked for changes")
+	flag.BoolVar(&config.UI, "ui", getEnvBool("UI", true), "Serve the web UI for browsing services and traces at /ui/")
+	flag.BoolVar(&config.EnableChaos, "enable-chaos", getEnvBool("ENABLE_CHAOS", false), "Allow storage errors, latency, dropped spans and refused requests to be injected through /api/v1/admin/chaos, for soak tests (never in production)")
 
 	flag.Parse()
+	config.RateLimit.SpansPerSecond = float64(rateLimit)
+	config.RateLimit.TrustForwardedFor = config.Origin.TrustForwardedFor
 
 	return config
 }
 
+// loadFileConfig reads the optional JSON config file; an empty path yields an empty config.
+func loadFileConfig(path string) (*FileConfig, error) {
+	config := &FileConfig{}
+	if path == "" {
+		return config, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return config, nil
+}
+
 // setupLogger creates a structured logger with the specified level.
 func setupLogger(level string) *slog.Logger {
 	var logLevel slog.Level
@@ -214,9 +832,13 @@ func handleHealth(col *collector.Collector) http.HandlerFunc {
 
 		status := map[string]interface{}{
 			"status":         "healthy",
+			"state":          col.State(),
 			"spans_received": metrics.SpansReceived,
 			"spans_stored":   metrics.SpansStored,
 			"span_errors":    metrics.SpanErrors,
+			"spans_dropped":  metrics.SpansDropped,
+			"spans_shed":     metrics.SpansShed,
+			"spans_spilled":  metrics.SpansSpilled,
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -225,26 +847,120 @@ func handleHealth(col *collector.Collector) http.HandlerFunc {
 	}
 }
 
-// handleMetrics returns a Prometheus-compatible metrics handler.
-func handleMetrics(col *collector.Collector) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		metrics := col.GetMetrics()
+// newMetricsRegistry registers the collector's metrics, per-receiver
+// counters and Go runtime and process metrics.
+func newMetricsRegistry(col *collector.Collector, receivers *receiver.Manager, probes *prober.Prober, regions *gateway.Store) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		col,
+		receiverMetrics{receivers},
+		probeMetrics{probes},
+		gatewayMetrics{regions},
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return registry
+}
 
-		w.Header().Set("Content-Type", "text/plain")
-		w.WriteHeader(http.StatusOK)
+var (
+	receiverAcceptedDesc = prometheus.NewDesc("traceflow_receiver_spans_accepted_total",
+		"Spans accepted per receiver", []string{"receiver"}, nil)
+	receiverRejectedDesc = prometheus.NewDesc("traceflow_receiver_spans_rejected_total",
+		"Spans rejected per receiver", []string{"receiver"}, nil)
+)
+
+// receiverMetrics exports the counters of configured receivers.
+type receiverMetrics struct {
+	manager *receiver.Manager
+}
+
+func (m receiverMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- receiverAcceptedDesc
+	ch <- receiverRejectedDesc
+}
+
+func (m receiverMetrics) Collect(ch chan<- prometheus.Metric) {
+	for id, metrics := range m.manager.Metrics() {
+		ch <- prometheus.MustNewConstMetric(receiverAcceptedDesc, prometheus.CounterValue, float64(metrics.Accepted), id)
+		ch <- prometheus.MustNewConstMetric(receiverRejectedDesc, prometheus.CounterValue, float64(metrics.Rejected), id)
+	}
+}
+
+var (
+	probeUpDesc = prometheus.NewDesc("traceflow_probe_up",
+		"Whether the last run of a synthetic check passed", []string{"probe"}, nil)
+	probeRunsDesc = prometheus.NewDesc("traceflow_probe_runs_total",
+		"Runs per synthetic check", []string{"probe"}, nil)
+	probeFailuresDesc = prometheus.NewDesc("traceflow_probe_failures_total",
+		"Failed runs per synthetic check", []string{"probe"}, nil)
+	probeDurationDesc = prometheus.NewDesc("traceflow_probe_duration_seconds",
+		"Duration of the last run of a synthetic check", []string{"probe"}, nil)
+)
+
+// probeMetrics exports the state of synthetic checks.
+type probeMetrics struct {
+	prober *prober.Prober
+}
+
+func (m probeMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- probeUpDesc
+	ch <- probeRunsDesc
+	ch <- probeFailuresDesc
+	ch <- probeDurationDesc
+}
+
+func (m probeMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range m.prober.Statuses() {
+		if status.Runs == 0 {
+			continue
+		}
+		up := 0.0
+		if status.Up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(probeUpDesc, prometheus.GaugeValue, up, status.Name)
+		ch <- prometheus.MustNewConstMetric(probeRunsDesc, prometheus.CounterValue, float64(status.Runs), status.Name)
+		ch <- prometheus.MustNewConstMetric(probeFailuresDesc, prometheus.CounterValue, float64(status.Failures), status.Name)
+		ch <- prometheus.MustNewConstMetric(probeDurationDesc, prometheus.GaugeValue, status.LastDuration.Seconds(), status.Name)
+	}
+}
 
-		// Prometheus format
-		fmt.Fprintf(w, "# HELP traceflow_spans_received_total Total number of spans received\n")
-		fmt.Fprintf(w, "# TYPE traceflow_spans_received_total counter\n")
-		fmt.Fprintf(w, "traceflow_spans_received_total %d\n", metrics.SpansReceived)
+var (
+	gatewayUpDesc = prometheus.NewDesc("traceflow_gateway_region_up",
+		"Whether the gateway's last request to a region succeeded", []string{"region"}, nil)
+	gatewayRequestsDesc = prometheus.NewDesc("traceflow_gateway_region_requests_total",
+		"Requests from the gateway per region", []string{"region"}, nil)
+	gatewayFailuresDesc = prometheus.NewDesc("traceflow_gateway_region_failures_total",
+		"Failed requests from the gateway per region", []string{"region"}, nil)
+	gatewayDurationDesc = prometheus.NewDesc("traceflow_gateway_region_request_duration_seconds",
+		"Duration of the gateway's last request to a region", []string{"region"}, nil)
+)
 
-		fmt.Fprintf(w, "# HELP traceflow_spans_stored_total Total number of spans stored\n")
-		fmt.Fprintf(w, "# TYPE traceflow_spans_stored_total counter\n")
-		fmt.Fprintf(w, "traceflow_spans_stored_total %d\n", metrics.SpansStored)
+// gatewayMetrics exports the state of the regions a gateway queries.
+type gatewayMetrics struct {
+	regions *gateway.Store
+}
 
-		fmt.Fprintf(w, "# HELP traceflow_span_errors_total Total number of span errors\n")
-		fmt.Fprintf(w, "# TYPE traceflow_span_errors_total counter\n")
-		fmt.Fprintf(w, "traceflow_span_errors_total %d\n", metrics.SpanErrors)
+func (m gatewayMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- gatewayUpDesc
+	ch <- gatewayRequestsDesc
+	ch <- gatewayFailuresDesc
+	ch <- gatewayDurationDesc
+}
+
+func (m gatewayMetrics) Collect(ch chan<- prometheus.Metric) {
+	for _, status := range m.regions.Statuses() {
+		if status.Requests == 0 {
+			continue
+		}
+		up := 0.0
+		if status.Up {
+			up = 1
+		}
+		ch <- prometheus.MustNewConstMetric(gatewayUpDesc, prometheus.GaugeValue, up, status.Name)
+		ch <- prometheus.MustNewConstMetric(gatewayRequestsDesc, prometheus.CounterValue, float64(status.Requests), status.Name)
+		ch <- prometheus.MustNewConstMetric(gatewayFailuresDesc, prometheus.CounterValue, float64(status.Failures), status.Name)
+		ch <- prometheus.MustNewConstMetric(gatewayDurationDesc, prometheus.GaugeValue, status.LastDuration.Seconds(), status.Name)
 	}
 }
 
@@ -266,3 +982,30 @@ func getEnvInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
+
+func getEnvBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if d, err := time.ParseDuration(value); err == nil {
+			return d
+		}
+	}
+	return defaultValue
+}
@@ -0,0 +1,154 @@
+// Command traceload replays exported traces into a collector, for load
+// testing and for moving historical data between environments. It reads
+// JSON-lines files (as written by the archive or returned by the query API)
+// and OTLP protobuf files, optionally gzip-compressed, and posts their spans
+// to the collector's batch endpoint at a configurable rate.
+//
+//	traceload -collector http://localhost:9090 -rate 5000 -ids regenerate -retime archive/*.jsonl.gz
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config holds traceload configuration
+type Config struct {
+	Collector   string        // Collector base URL
+	Rate        float64       // Spans per second (0 = as fast as the collector accepts them)
+	BatchSize   int           // Spans per request
+	Concurrency int           // Requests in flight
+	IDs         string        // "preserve" or "regenerate"
+	Retime      bool          // Move each trace to start when it is replayed
+	Format      string        // "auto", "jsonl" or "otlp"
+	Repeat      int           // Passes over the input
+	Timeout     time.Duration // Per-request timeout
+	LogLevel    string        // Log level (debug, info, warn, error)
+}
+
+// How trace and span IDs are replayed
+const (
+	idsPreserve   = "preserve"
+	idsRegenerate = "regenerate"
+)
+
+func main() {
+	config := parseConfig()
+	logger := setupLogger(config.LogLevel)
+
+	files := flag.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	if err := validate(config, files); err != nil {
+		fmt.Fprintln(os.Stderr, "traceload:", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	r := newReplayer(config, logger)
+	r.run()
+	var readErr error
+	for pass := 0; pass < config.Repeat && readErr == nil; pass++ {
+		r.newPass()
+		for _, file := range files {
+			if readErr = replayFile(r, file, config.Format); readErr != nil {
+				logger.Error("failed to read traces", "file", file, "error", readErr)
+				break
+			}
+		}
+	}
+	r.close()
+
+	elapsed := time.Since(r.start)
+	sent, failed := r.sent.Load(), r.failed.Load()
+	logger.Info("replay finished",
+		"traces", r.traces,
+		"spans_sent", sent,
+		"spans_failed", failed,
+		"elapsed", elapsed.Round(time.Millisecond),
+		"spans_per_second", int(float64(sent)/elapsed.Seconds()),
+	)
+	if readErr != nil || failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// replayFile feeds one input file, or stdin for "-", to the replayer.
+func replayFile(r *replayer, path, format string) error {
+	var in io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	if format == formatAuto && strings.Contains(path, ".otlp") {
+		format = formatOTLP
+	}
+	return readTraces(in, format, r.add)
+}
+
+func validate(config Config, files []string) error {
+	switch {
+	case config.IDs != idsPreserve && config.IDs != idsRegenerate:
+		return fmt.Errorf("-ids must be %s or %s", idsPreserve, idsRegenerate)
+	case config.Format != formatAuto && config.Format != formatJSONL && config.Format != formatOTLP:
+		return fmt.Errorf("-format must be %s, %s or %s", formatAuto, formatJSONL, formatOTLP)
+	case config.Rate < 0 || config.BatchSize <= 0 || config.Concurrency <= 0 || config.Repeat <= 0:
+		return fmt.Errorf("-rate must not be negative; -batch-size, -concurrency and -repeat must be positive")
+	}
+	for _, file := range files {
+		if file == "-" && config.Repeat > 1 {
+			return fmt.Errorf("-repeat needs files, stdin can only be read once")
+		}
+	}
+	return nil
+}
+
+// parseConfig parses command line flags
+func parseConfig() Config {
+	config := Config{}
+
+	flag.StringVar(&config.Collector, "collector", "http://localhost:9090", "Collector base URL")
+	flag.Float64Var(&config.Rate, "rate", 0, "Spans per second (0 = as fast as the collector accepts them)")
+	flag.IntVar(&config.BatchSize, "batch-size", 100, "Spans per request")
+	flag.IntVar(&config.Concurrency, "concurrency", 4, "Requests in flight")
+	flag.StringVar(&config.IDs, "ids", idsPreserve, "Trace and span IDs: preserve, or regenerate to replay the same traces more than once")
+	flag.BoolVar(&config.Retime, "retime", false, "Move each trace to start when it is replayed, keeping its spans' relative timing")
+	flag.StringVar(&config.Format, "format", formatAuto, "Input format: auto, jsonl or otlp")
+	flag.IntVar(&config.Repeat, "repeat", 1, "Passes over the input files")
+	flag.DurationVar(&config.Timeout, "timeout", 30*time.Second, "Per-request timeout")
+	flag.StringVar(&config.LogLevel, "log-level", "info", "Log level (debug, info, warn, error)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: traceload [flags] [file ...]\n\nReads stdin when no file is given.\n\n")
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	config.Collector = strings.TrimSuffix(config.Collector, "/")
+	return config
+}
+
+// setupLogger creates a structured logger
+func setupLogger(level string) *slog.Logger {
+	var logLevel slog.Level
+	switch level {
+	case "debug":
+		logLevel = slog.LevelDebug
+	case "warn":
+		logLevel = slog.LevelWarn
+	case "error":
+		logLevel = slog.LevelError
+	default:
+		logLevel = slog.LevelInfo
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevel}))
+}
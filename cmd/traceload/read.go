@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/saintparish4/asmbly/internal/collector"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Input formats
+const (
+	formatAuto  = "auto"
+	formatJSONL = "jsonl"
+	formatOTLP  = "otlp"
+)
+
+// readTraces reads an exported file and passes its spans to emit a trace at
+// a time. JSON input holds traces (as written by the archive or returned by
+// the query API), spans or arrays of spans, one after another; OTLP input is
+// one protobuf ExportTraceServiceRequest. Gzip-compressed input is detected.
+func readTraces(r io.Reader, format string, emit func([]*models.Span) error) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		br = bufio.NewReader(zr)
+	}
+
+	if format == formatAuto {
+		format = detectFormat(br)
+	}
+	switch format {
+	case formatJSONL:
+		return readJSON(br, emit)
+	case formatOTLP:
+		return readOTLP(br, emit)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// detectFormat tells JSON from protobuf by the first non-space byte.
+func detectFormat(br *bufio.Reader) string {
+	for n := 1; ; n++ {
+		peek, err := br.Peek(n)
+		if err != nil {
+			return formatJSONL // Empty input
+		}
+		switch c := peek[n-1]; c {
+		case ' ', '\t', '\r', '\n':
+			continue
+		case '{', '[':
+			return formatJSONL
+		default:
+			return formatOTLP
+		}
+	}
+}
+
+// jsonRecord is a trace or a span; traces have spans, spans a span ID.
+type jsonRecord struct {
+	Spans  []models.Span `json:"spans"`
+	SpanID string        `json:"span_id"`
+}
+
+func readJSON(r io.Reader, emit func([]*models.Span) error) error {
+	dec := json.NewDecoder(r)
+	var group traceGroup
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+
+		var spans []models.Span
+		if raw[0] == '[' {
+			if err := json.Unmarshal(raw, &spans); err != nil {
+				return err
+			}
+		} else {
+			var record jsonRecord
+			if err := json.Unmarshal(raw, &record); err != nil {
+				return err
+			}
+			spans = record.Spans
+			if len(spans) == 0 && record.SpanID != "" {
+				spans = make([]models.Span, 1)
+				if err := json.Unmarshal(raw, &spans[0]); err != nil {
+					return err
+				}
+			}
+		}
+		for i := range spans {
+			if err := group.add(&spans[i], emit); err != nil {
+				return err
+			}
+		}
+	}
+	return group.flush(emit)
+}
+
+func readOTLP(r io.Reader, emit func([]*models.Span) error) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	var req coltracepb.ExportTraceServiceRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return fmt.Errorf("parse OTLP: %w", err)
+	}
+	spans, rejected := collector.SpansFromOTLP(&req)
+	if rejected > 0 {
+		return fmt.Errorf("%d OTLP spans have malformed IDs", rejected)
+	}
+
+	// Spans of a trace may be spread over resources; emit them together
+	var order []string
+	byTrace := make(map[string][]*models.Span)
+	for _, span := range spans {
+		if _, ok := byTrace[span.TraceID]; !ok {
+			order = append(order, span.TraceID)
+		}
+		byTrace[span.TraceID] = append(byTrace[span.TraceID], span)
+	}
+	for _, traceID := range order {
+		if err := emit(byTrace[traceID]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// traceGroup collects consecutive spans of one trace.
+type traceGroup struct {
+	spans []*models.Span
+}
+
+func (g *traceGroup) add(span *models.Span, emit func([]*models.Span) error) error {
+	if len(g.spans) > 0 && g.spans[0].TraceID != span.TraceID {
+		if err := g.flush(emit); err != nil {
+			return err
+		}
+	}
+	g.spans = append(g.spans, span)
+	return nil
+}
+
+func (g *traceGroup) flush(emit func([]*models.Span) error) error {
+	if len(g.spans) == 0 {
+		return nil
+	}
+	spans := g.spans
+	g.spans = nil
+	return emit(spans)
+}
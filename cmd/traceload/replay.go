@@ -0,0 +1,231 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// maxRetries bounds the retries of a batch the collector could not take
+const maxRetries = 5
+
+// replayer sends spans to the collector's batch endpoint at a steady rate
+// from several workers.
+type replayer struct {
+	client      *http.Client
+	url         string
+	rate        float64 // Spans per second (0 = unlimited)
+	batchSize   int
+	concurrency int
+	regenerate  bool // New trace and span IDs instead of the exported ones
+	retime      bool // Move each trace to start when it is replayed
+	logger      *slog.Logger
+
+	// Reading side, used by one goroutine
+	salt      string // Varies regenerated IDs between passes
+	pending   []*models.Span
+	start     time.Time
+	scheduled int64 // Spans released so far, for pacing
+	traces    int64
+
+	batches chan []*models.Span
+	wg      sync.WaitGroup
+	sent    atomic.Int64
+	failed  atomic.Int64
+}
+
+func newReplayer(config Config, logger *slog.Logger) *replayer {
+	return &replayer{
+		client:      &http.Client{Timeout: config.Timeout},
+		url:         config.Collector + "/api/v1/spans/batch",
+		rate:        config.Rate,
+		batchSize:   config.BatchSize,
+		concurrency: config.Concurrency,
+		regenerate:  config.IDs == idsRegenerate,
+		retime:      config.Retime,
+		logger:      logger,
+		batches:     make(chan []*models.Span, config.Concurrency),
+	}
+}
+
+// run starts the workers; close ends the replay once everything is sent.
+func (r *replayer) run() {
+	r.start = time.Now()
+	for i := 0; i < r.concurrency; i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			for batch := range r.batches {
+				r.send(batch)
+			}
+		}()
+	}
+}
+
+// newPass starts another pass over the input. Regenerated IDs differ from
+// one pass to the next, so repeating a file yields new traces.
+func (r *replayer) newPass() {
+	r.salt = models.GenerateTraceID()
+}
+
+// add queues one trace's spans, rewritten as configured.
+func (r *replayer) add(spans []*models.Span) error {
+	r.traces++
+	shift := time.Duration(0)
+	if r.retime {
+		earliest := spans[0].StartTime
+		for _, span := range spans[1:] {
+			if span.StartTime.Before(earliest) {
+				earliest = span.StartTime
+			}
+		}
+		shift = time.Since(earliest)
+	}
+
+	for _, span := range spans {
+		if r.regenerate {
+			span.TraceID = r.newID(span.TraceID, 32)
+			span.SpanID = r.newID(span.SpanID, 16)
+			if span.ParentSpanID != "" {
+				span.ParentSpanID = r.newID(span.ParentSpanID, 16)
+			}
+		}
+		if shift != 0 {
+			span.StartTime = span.StartTime.Add(shift)
+			for i := range span.Events {
+				span.Events[i].Timestamp = span.Events[i].Timestamp.Add(shift)
+			}
+		}
+		r.pending = append(r.pending, span)
+		if len(r.pending) >= r.batchSize {
+			r.flush()
+		}
+	}
+	return nil
+}
+
+// newID derives a replacement ID of n hex digits from an exported one. The
+// same ID always maps to the same replacement within a pass, so parent
+// links and spans of a trace spread over several files stay connected.
+func (r *replayer) newID(id string, n int) string {
+	sum := sha256.Sum256([]byte(r.salt + id))
+	return hex.EncodeToString(sum[:])[:n]
+}
+
+// flush hands the pending spans to a worker once the rate allows.
+func (r *replayer) flush() {
+	if len(r.pending) == 0 {
+		return
+	}
+	if r.rate > 0 {
+		due := r.start.Add(time.Duration(float64(r.scheduled) / r.rate * float64(time.Second)))
+		time.Sleep(time.Until(due))
+	}
+	r.scheduled += int64(len(r.pending))
+	r.batches <- r.pending
+	r.pending = nil
+}
+
+// close sends what is pending and waits for the workers.
+func (r *replayer) close() {
+	r.flush()
+	close(r.batches)
+	r.wg.Wait()
+}
+
+// send posts a batch, retrying while the collector is busy or unreachable.
+// Retries carry the same Idempotency-Key, so a batch is not ingested twice.
+func (r *replayer) send(batch []*models.Span) {
+	body, err := encodeBatch(batch)
+	if err != nil {
+		r.logger.Error("failed to encode batch", "error", err)
+		r.failed.Add(int64(len(batch)))
+		return
+	}
+	key := models.GenerateTraceID()
+
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		failed, retryAfter, err := r.post(body, key)
+		if err == nil {
+			r.sent.Add(int64(len(batch) - failed))
+			r.failed.Add(int64(failed))
+			return
+		}
+		if retryAfter < 0 || attempt >= maxRetries {
+			r.logger.Error("failed to send batch", "spans", len(batch), "attempts", attempt+1, "error", err)
+			r.failed.Add(int64(len(batch)))
+			return
+		}
+		if retryAfter == 0 {
+			retryAfter = backoff
+			backoff *= 2
+		}
+		r.logger.Warn("collector busy, retrying batch", "spans", len(batch), "retry_in", retryAfter, "error", err)
+		time.Sleep(retryAfter)
+	}
+}
+
+// post makes one request. It returns the spans the collector refused, or
+// an error with the delay before a retry: 0 for the default backoff, -1
+// when retrying is pointless.
+func (r *replayer) post(body []byte, key string) (failed int, retryAfter time.Duration, err error) {
+	req, err := http.NewRequest(http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, -1, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Idempotency-Key", key)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		var result struct {
+			Failed int `json:"failed"`
+		}
+		json.NewDecoder(resp.Body).Decode(&result)
+		return result.Failed, 0, nil
+	case resp.StatusCode/100 == 2:
+		return 0, 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusConflict ||
+		resp.StatusCode >= 500:
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		return 0, retryAfter, fmt.Errorf("collector returned status %d", resp.StatusCode)
+	default:
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, -1, fmt.Errorf("collector returned status %d: %s", resp.StatusCode, bytes.TrimSpace(detail))
+	}
+}
+
+// encodeBatch renders spans as a gzip-compressed JSON batch.
+func encodeBatch(batch []*models.Span) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(models.WithSchemaVersion(batch)); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
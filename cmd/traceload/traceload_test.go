@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/saintparish4/asmbly/internal/plugin/otlpexport"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func exportedTrace(traceID string, start time.Time) models.Trace {
+	return models.Trace{
+		TraceID: traceID,
+		Spans: []models.Span{
+			{TraceID: traceID, SpanID: "00000000000000a1", ServiceName: "api", OperationName: "GET /users", StartTime: start, Duration: time.Second, Status: "ok"},
+			{TraceID: traceID, SpanID: "00000000000000a2", ParentSpanID: "00000000000000a1", ServiceName: "db", OperationName: "SELECT", StartTime: start.Add(10 * time.Millisecond), Duration: time.Millisecond, Status: "ok"},
+		},
+	}
+}
+
+func collect(t *testing.T, input []byte, format string) [][]*models.Span {
+	t.Helper()
+	var traces [][]*models.Span
+	err := readTraces(bytes.NewReader(input), format, func(spans []*models.Span) error {
+		traces = append(traces, spans)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return traces
+}
+
+func TestReadTraces_JSONLines(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	enc.Encode(exportedTrace("11111111111111111111111111111111", start))
+	// Spans of one trace on consecutive lines form one trace
+	for _, span := range exportedTrace("22222222222222222222222222222222", start).Spans {
+		enc.Encode(span)
+	}
+	zw.Close()
+
+	traces := collect(t, buf.Bytes(), formatAuto)
+	if len(traces) != 2 || len(traces[0]) != 2 || len(traces[1]) != 2 {
+		t.Fatalf("read %d traces, want 2 of 2 spans", len(traces))
+	}
+	if traces[1][1].ParentSpanID != "00000000000000a1" {
+		t.Errorf("span = %+v", traces[1][1])
+	}
+}
+
+func TestReadTraces_OTLP(t *testing.T) {
+	trace := exportedTrace("33333333333333333333333333333333", time.Now())
+	data, err := proto.Marshal(otlpexport.ToOTLP([]*models.Span{&trace.Spans[0], &trace.Spans[1]}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	traces := collect(t, data, formatAuto)
+	if len(traces) != 1 || len(traces[0]) != 2 || traces[0][0].TraceID != trace.TraceID {
+		t.Fatalf("read %+v, want the trace's 2 spans together", traces)
+	}
+}
+
+func TestReplayer_RegeneratesIDsAndRetimes(t *testing.T) {
+	var mu sync.Mutex
+	var received []models.Span
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Errorf("body is not gzip: %v", err)
+			return
+		}
+		var spans []models.Span
+		json.NewDecoder(zr).Decode(&spans)
+		mu.Lock()
+		received = append(received, spans...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	r := newReplayer(Config{
+		Collector:   server.URL,
+		BatchSize:   3,
+		Concurrency: 2,
+		IDs:         idsRegenerate,
+		Retime:      true,
+		Timeout:     time.Second,
+	}, slog.Default())
+	r.run()
+	old := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for pass := 0; pass < 2; pass++ {
+		r.newPass()
+		trace := exportedTrace("44444444444444444444444444444444", old)
+		r.add([]*models.Span{&trace.Spans[0], &trace.Spans[1]})
+	}
+	r.close()
+
+	if len(received) != 4 || r.sent.Load() != 4 || r.failed.Load() != 0 {
+		t.Fatalf("received %d spans, sent %d, failed %d; want 4", len(received), r.sent.Load(), r.failed.Load())
+	}
+	traceIDs := make(map[string]bool)
+	for _, span := range received {
+		traceIDs[span.TraceID] = true
+		if span.TraceID == "44444444444444444444444444444444" || len(span.TraceID) != 32 || len(span.SpanID) != 16 {
+			t.Errorf("IDs were not regenerated: %+v", span)
+		}
+		if time.Since(span.StartTime) > time.Minute {
+			t.Errorf("start time %v was not moved to the replay", span.StartTime)
+		}
+	}
+	if len(traceIDs) != 2 {
+		t.Errorf("got %d trace IDs, want a new one per pass", len(traceIDs))
+	}
+
+	// Parent links survive regeneration
+	spanIDs := make(map[string]bool)
+	for _, span := range received {
+		spanIDs[span.SpanID] = true
+	}
+	for _, span := range received {
+		if span.ParentSpanID != "" && !spanIDs[span.ParentSpanID] {
+			t.Errorf("parent %s of %s was not regenerated consistently", span.ParentSpanID, span.SpanID)
+		}
+	}
+}
+
+func TestReplayer_RetriesBusyCollector(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		attempt := len(keys)
+		mu.Unlock()
+		if attempt == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	r := newReplayer(Config{Collector: server.URL, BatchSize: 10, Concurrency: 1, IDs: idsPreserve, Timeout: time.Second}, slog.Default())
+	r.run()
+	trace := exportedTrace("55555555555555555555555555555555", time.Now())
+	r.add([]*models.Span{&trace.Spans[0], &trace.Spans[1]})
+	r.close()
+
+	if r.sent.Load() != 2 || len(keys) != 2 || keys[0] == "" || keys[0] != keys[1] {
+		t.Errorf("sent %d spans with keys %q, want 2 after one retry under the same key", r.sent.Load(), keys)
+	}
+}
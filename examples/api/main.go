@@ -0,0 +1,124 @@
+// Command api is the example backend: it serves products and takes orders,
+// querying the db service through a traced HTTP client so each query shows
+// up as a client span with the db's spans beneath it.
+//
+// Environment: ADDR (default :8081), DB_URL (default http://localhost:8082),
+// COLLECTOR_URL, DEPLOYMENT_ID, GIT_SHA, ENVIRONMENT, EXTRA_LATENCY (added
+// to every request, e.g. 50ms, to stage a regressed deployment) and
+// ERROR_RATE (default 0.02, orders rejected as out of stock).
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/saintparish4/asmbly/examples/internal/demo"
+	"github.com/saintparish4/asmbly/sdk"
+)
+
+// api serves the HTTP endpoints.
+type api struct {
+	tracer       *sdk.Tracer
+	db           *http.Client
+	dbURL        string
+	extraLatency time.Duration
+	errorRate    float64
+}
+
+// query runs a pretend query on the db service.
+func (a *api) query(ctx context.Context, op, table string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/query?op=%s&table=%s", a.dbURL, op, table), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := a.db.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s: db returned %s", op, table, resp.Status)
+	}
+	return nil
+}
+
+// handleProducts handles GET /api/products, checking a pretend cache before
+// the database.
+func (a *api) handleProducts(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	demo.Work(ctx, a.extraLatency)
+
+	span, cacheCtx := a.tracer.StartSpan(ctx, "cache lookup", sdk.WithTags(map[string]string{"cache.key": "products"}))
+	demo.Work(cacheCtx, time.Millisecond)
+	hit := demo.Chance(0.6)
+	span.SetTag("cache.hit", fmt.Sprint(hit))
+	span.Finish()
+
+	if !hit {
+		if err := a.query(ctx, "select", "products"); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode([]map[string]any{{"id": 1, "name": "coffee"}, {"id": 2, "name": "tea"}})
+}
+
+// handleOrders handles POST /api/orders: check inventory, write the order
+// and update stock.
+func (a *api) handleOrders(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	demo.Work(ctx, a.extraLatency)
+
+	if err := a.query(ctx, "select", "inventory"); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if demo.Chance(a.errorRate) {
+		// The 503 fails the request span; the error says why
+		if span := sdk.SpanFromContext(ctx); span != nil {
+			span.SetError(errors.New("out of stock"))
+		}
+		http.Error(w, "out of stock", http.StatusServiceUnavailable)
+		return
+	}
+
+	// Both writes run in a child span, like a transaction would
+	span, ctx := a.tracer.StartSpan(ctx, "place order")
+	defer span.Finish()
+	for _, table := range []string{"orders", "inventory"} {
+		op := "insert"
+		if table == "inventory" {
+			op = "update"
+		}
+		if err := a.query(ctx, op, table); err != nil {
+			span.SetError(err)
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"order_id": span.SpanID()})
+}
+
+func main() {
+	tracer := demo.NewTracer("api")
+	a := &api{
+		tracer:       tracer,
+		db:           sdk.ClientMiddleware(tracer)(&http.Client{Timeout: 5 * time.Second}),
+		dbURL:        demo.Env("DB_URL", "http://localhost:8082"),
+		extraLatency: demo.EnvDuration("EXTRA_LATENCY", 0),
+		errorRate:    demo.EnvFloat("ERROR_RATE", 0.02),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/products", a.handleProducts)
+	mux.HandleFunc("POST /api/orders", a.handleOrders)
+
+	demo.Serve("api", demo.Env("ADDR", ":8081"), sdk.Middleware(tracer)(mux), tracer)
+}
@@ -0,0 +1,70 @@
+// Command db simulates a database behind an HTTP interface: GET
+// /query?op=select&table=products runs a pretend query whose latency depends
+// on the table. The middleware continues the caller's trace with a server
+// span, and the query is a child span tagged the way database drivers tag
+// theirs.
+//
+// Environment: ADDR (default :8082), COLLECTOR_URL, DEPLOYMENT_ID, GIT_SHA,
+// ENVIRONMENT, SLOW_QUERY_RATE (default 0.05) and ERROR_RATE (default 0.01).
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/saintparish4/asmbly/examples/internal/demo"
+	"github.com/saintparish4/asmbly/sdk"
+)
+
+// tableLatency is the typical latency of a query on each table.
+var tableLatency = map[string]time.Duration{
+	"products":  4 * time.Millisecond,
+	"inventory": 8 * time.Millisecond,
+	"orders":    12 * time.Millisecond,
+	"users":     3 * time.Millisecond,
+}
+
+func main() {
+	tracer := demo.NewTracer("db")
+	slowRate := demo.EnvFloat("SLOW_QUERY_RATE", 0.05)
+	errorRate := demo.EnvFloat("ERROR_RATE", 0.01)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /query", func(w http.ResponseWriter, r *http.Request) {
+		op, table := r.URL.Query().Get("op"), r.URL.Query().Get("table")
+		latency, ok := tableLatency[table]
+		if !ok || (op != "select" && op != "insert" && op != "update") {
+			http.Error(w, "unknown query", http.StatusBadRequest)
+			return
+		}
+
+		statement := fmt.Sprintf("%s %s", strings.ToUpper(op), table)
+		span, ctx := tracer.StartSpan(r.Context(), statement, sdk.WithSpanKind("internal"), sdk.WithTags(map[string]string{
+			"db.system":    "postgresql",
+			"db.name":      "shop",
+			"db.operation": strings.ToUpper(op),
+			"db.sql.table": table,
+		}))
+		defer span.Finish()
+
+		if demo.Chance(slowRate) {
+			span.AddEvent("lock wait", map[string]string{"db.sql.table": table})
+			latency *= 20
+		}
+		demo.Work(ctx, latency)
+
+		if demo.Chance(errorRate) {
+			span.SetError(errors.New("deadlock detected"))
+			http.Error(w, "deadlock detected", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"statement": statement, "rows": 1 + len(table)})
+	})
+
+	demo.Serve("db", demo.Env("ADDR", ":8082"), sdk.Middleware(tracer)(mux), tracer)
+}
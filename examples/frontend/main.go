@@ -0,0 +1,120 @@
+// Command frontend is the example's entry point: its pages call the api
+// service through a traced HTTP client, so a page view becomes one trace
+// across frontend, api and db. With TRAFFIC_INTERVAL set it also requests
+// its own pages on that interval, so traces appear without a browser.
+//
+// Environment: ADDR (default :8080), API_URL (default
+// http://localhost:8081), COLLECTOR_URL, DEPLOYMENT_ID, GIT_SHA, ENVIRONMENT
+// and TRAFFIC_INTERVAL (e.g. 1s; default none).
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/saintparish4/asmbly/examples/internal/demo"
+	"github.com/saintparish4/asmbly/sdk"
+)
+
+// frontend serves the pages.
+type frontend struct {
+	tracer *sdk.Tracer
+	api    *http.Client
+	apiURL string
+}
+
+// call makes a request to the api service and returns its body.
+func (f *frontend) call(ctx context.Context, method, path string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, f.apiURL+path, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.api.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return string(body), nil
+}
+
+// render writes a page, timing it in its own span.
+func (f *frontend) render(ctx context.Context, w http.ResponseWriter, title, body string) {
+	span, ctx := f.tracer.StartSpan(ctx, "render "+title, sdk.WithTags(map[string]string{"template": title}))
+	defer span.Finish()
+	demo.Work(ctx, 2*time.Millisecond)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<h1>%s</h1><pre>%s</pre>\n", title, body)
+}
+
+// handleHome handles GET /, listing products.
+func (f *frontend) handleHome(w http.ResponseWriter, r *http.Request) {
+	products, err := f.call(r.Context(), http.MethodGet, "/api/products")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	f.render(r.Context(), w, "products", products)
+}
+
+// handleCheckout handles POST /checkout, placing an order.
+func (f *frontend) handleCheckout(w http.ResponseWriter, r *http.Request) {
+	order, err := f.call(r.Context(), http.MethodPost, "/api/orders")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	f.render(r.Context(), w, "order placed", order)
+}
+
+// generateTraffic requests the frontend's own pages every interval, a
+// checkout for every few page views. The requests are not traced here, so
+// each one starts a new trace in the frontend's middleware.
+func generateTraffic(baseURL string, interval time.Duration) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	for i := 0; ; i++ {
+		time.Sleep(interval)
+		method, path := http.MethodGet, "/"
+		if i%4 == 3 {
+			method, path = http.MethodPost, "/checkout"
+		}
+		req, _ := http.NewRequest(method, baseURL+path, nil)
+		resp, err := client.Do(req)
+		if err != nil {
+			slog.Warn("traffic request failed", "path", path, "error", err)
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func main() {
+	tracer := demo.NewTracer("frontend")
+	f := &frontend{
+		tracer: tracer,
+		api:    sdk.ClientMiddleware(tracer)(&http.Client{Timeout: 5 * time.Second}),
+		apiURL: demo.Env("API_URL", "http://localhost:8081"),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", f.handleHome)
+	mux.HandleFunc("POST /checkout", f.handleCheckout)
+
+	addr := demo.Env("ADDR", ":8080")
+	if interval := demo.EnvDuration("TRAFFIC_INTERVAL", 0); interval > 0 {
+		go generateTraffic("http://localhost"+addr, interval)
+	}
+	demo.Serve("frontend", addr, sdk.Middleware(tracer)(mux), tracer)
+}
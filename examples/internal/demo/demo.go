@@ -0,0 +1,98 @@
+// Package demo holds what the example services share: a tracer configured
+// from the environment, simulated work and an HTTP server that flushes its
+// spans on shutdown.
+package demo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/saintparish4/asmbly/sdk"
+)
+
+// Env returns the environment variable key, or fallback when it is unset.
+func Env(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// EnvDuration returns the duration in the environment variable key, or
+// fallback when it is unset or invalid.
+func EnvDuration(key string, fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(os.Getenv(key)); err == nil {
+		return d
+	}
+	return fallback
+}
+
+// EnvFloat returns the number in the environment variable key, or fallback
+// when it is unset or invalid.
+func EnvFloat(key string, fallback float64) float64 {
+	if f, err := strconv.ParseFloat(os.Getenv(key), 64); err == nil {
+		return f
+	}
+	return fallback
+}
+
+// NewTracer returns a tracer for service exporting to COLLECTOR_URL, with
+// every span tagged with DEPLOYMENT_ID, GIT_SHA and ENVIRONMENT.
+func NewTracer(service string) *sdk.Tracer {
+	return sdk.NewTracer(service, Env("COLLECTOR_URL", "http://localhost:9090"),
+		sdk.WithServiceDeployment(Env("DEPLOYMENT_ID", "dev"), Env("GIT_SHA", ""), Env("ENVIRONMENT", "local")),
+		sdk.WithBatching(sdk.BatchConfig{Compression: sdk.CompressionGzip}),
+	)
+}
+
+// Work simulates work taking about d, give or take half of it, returning
+// early if ctx ends.
+func Work(ctx context.Context, d time.Duration) {
+	if d <= 0 {
+		return
+	}
+	d = d/2 + time.Duration(rand.Int63n(int64(d)))
+	select {
+	case <-time.After(d):
+	case <-ctx.Done():
+	}
+}
+
+// Chance reports true with probability p.
+func Chance(p float64) bool { return rand.Float64() < p }
+
+// Serve runs an HTTP server on addr until SIGINT or SIGTERM, then stops it
+// and sends the tracer's remaining spans.
+func Serve(service, addr string, handler http.Handler, tracer *sdk.Tracer) {
+	server := &http.Server{Addr: addr, Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	errs := make(chan error, 1)
+	go func() {
+		slog.Info("listening", "service", service, "addr", addr)
+		errs <- server.ListenAndServe()
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		if !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("server failed", "service", service, "error", err)
+		}
+	case <-stop:
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	server.Shutdown(ctx)
+	if err := tracer.Shutdown(ctx); err != nil {
+		slog.Error("failed to flush spans", "service", service, "error", err)
+	}
+}
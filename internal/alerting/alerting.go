@@ -0,0 +1,195 @@
+// Package alerting delivers alert notifications to chat tools and webhooks.
+// Notifier types register themselves by name, like plugins and receivers;
+// the "notifiers" section of the collector config file creates named
+// instances, and each alert rule lists the instances it notifies.
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Status is the state an alert notification reports.
+type Status string
+
+const (
+	StatusFiring   Status = "firing"
+	StatusResolved Status = "resolved"
+)
+
+// Alert is one notification about an alert rule's condition for a service.
+type Alert struct {
+	Rule      string            `json:"rule"`
+	Service   string            `json:"service,omitempty"`
+	Status    Status            `json:"status"`
+	Severity  string            `json:"severity,omitempty"` // e.g. "critical", "warning"
+	Summary   string            `json:"summary,omitempty"`
+	Value     float64           `json:"value"`     // Observed value of the rule's metric
+	Threshold float64           `json:"threshold"` // Value the rule fires at
+	StartsAt  time.Time         `json:"starts_at"`
+	EndsAt    *time.Time        `json:"ends_at,omitempty"` // Set once resolved
+	URL       string            `json:"url,omitempty"`     // Link to the matching traces
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Firing reports whether the alert's condition currently holds.
+func (a Alert) Firing() bool {
+	return a.Status != StatusResolved
+}
+
+// DedupKey identifies the alert across notifications. Every event for the
+// same rule and service shares it, so incident tools group repeats and a
+// resolve closes the incident the firing event opened.
+func (a Alert) DedupKey() string {
+	if a.Service == "" {
+		return a.Rule
+	}
+	return a.Rule + "/" + a.Service
+}
+
+// Title is a one-line headline, e.g. "[FIRING] high-error-rate (checkout)".
+func (a Alert) Title() string {
+	status := StatusFiring
+	if !a.Firing() {
+		status = StatusResolved
+	}
+	title := fmt.Sprintf("[%s] %s", strings.ToUpper(string(status)), a.Rule)
+	if a.Service != "" {
+		title += " (" + a.Service + ")"
+	}
+	return title
+}
+
+// Notifier sends alerts to one destination.
+// Implementations must be safe for concurrent use.
+type Notifier interface {
+	Notify(ctx context.Context, alert Alert) error
+}
+
+// Factory builds a notifier from its raw JSON config (may be nil).
+type Factory func(config json.RawMessage) (Notifier, error)
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+)
+
+// Register makes a notifier type available under name.
+// It panics if name is already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := factories[name]; dup {
+		panic("alerting: notifier registered twice: " + name)
+	}
+	factories[name] = factory
+}
+
+// Registered returns the sorted names of all registered notifier types.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Spec configures one notifier instance in the "notifiers" section of the
+// collector config file. Alert rules refer to it by Name.
+type Spec struct {
+	Name   string          `json:"name"`
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// Set holds the configured notifiers by name.
+type Set struct {
+	notifiers map[string]Notifier
+	types     map[string]string
+}
+
+// NewSet builds a notifier for every spec.
+func NewSet(specs []Spec) (*Set, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	s := &Set{notifiers: make(map[string]Notifier), types: make(map[string]string)}
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, fmt.Errorf("notifier of type %q has no name", spec.Type)
+		}
+		if _, dup := s.notifiers[spec.Name]; dup {
+			return nil, fmt.Errorf("duplicate notifier name %q", spec.Name)
+		}
+		factory, ok := factories[spec.Type]
+		if !ok {
+			return nil, fmt.Errorf("notifier %q: unknown type %q", spec.Name, spec.Type)
+		}
+		n, err := factory(spec.Config)
+		if err != nil {
+			return nil, fmt.Errorf("notifier %q: %w", spec.Name, err)
+		}
+		s.Add(spec.Name, spec.Type, n)
+	}
+	return s, nil
+}
+
+// Add registers an already-built notifier under name, replacing any other.
+func (s *Set) Add(name, typ string, n Notifier) {
+	s.notifiers[name] = n
+	s.types[name] = typ
+}
+
+// Names returns the sorted notifier names.
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.notifiers))
+	for name := range s.notifiers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate reports names that do not refer to a configured notifier, so
+// alert rules can be checked when they are loaded.
+func (s *Set) Validate(names []string) error {
+	for _, name := range names {
+		if _, ok := s.notifiers[name]; !ok {
+			return fmt.Errorf("unknown notifier %q (configured: %v)", name, s.Names())
+		}
+	}
+	return nil
+}
+
+// Notify sends alert to the named notifiers concurrently, so a slow
+// destination does not hold up the others, and returns the combined errors.
+func (s *Set) Notify(ctx context.Context, alert Alert, names []string) error {
+	if err := s.Validate(names); err != nil {
+		return err
+	}
+
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			if err := s.notifiers[name].Notify(ctx, alert); err != nil {
+				errs[i] = fmt.Errorf("notifier %q: %w", name, err)
+			}
+		}(i, name)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,133 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// recordingNotifier remembers the alerts it was sent.
+type recordingNotifier struct {
+	mu     sync.Mutex
+	alerts []Alert
+	err    error
+}
+
+func (n *recordingNotifier) Notify(ctx context.Context, alert Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alert)
+	return n.err
+}
+
+func TestAlert_DedupKeyAndTitle(t *testing.T) {
+	alert := Alert{Rule: "high-error-rate", Service: "checkout", Status: StatusFiring}
+	if key := alert.DedupKey(); key != "high-error-rate/checkout" {
+		t.Errorf("dedup key = %q", key)
+	}
+	if title := alert.Title(); title != "[FIRING] high-error-rate (checkout)" {
+		t.Errorf("title = %q", title)
+	}
+
+	alert.Service = ""
+	alert.Status = StatusResolved
+	if alert.DedupKey() != "high-error-rate" || alert.Title() != "[RESOLVED] high-error-rate" {
+		t.Errorf("without service: key %q, title %q", alert.DedupKey(), alert.Title())
+	}
+}
+
+func TestNewSet(t *testing.T) {
+	set, err := NewSet([]Spec{
+		{Name: "ops-slack", Type: SlackType, Config: json.RawMessage(`{"webhook_url": "https://hooks.slack.com/services/x"}`)},
+		{Name: "audit", Type: WebhookType, Config: json.RawMessage(`{"url": "https://example.com/alerts"}`)},
+	})
+	if err != nil {
+		t.Fatalf("NewSet failed: %v", err)
+	}
+	if names := set.Names(); len(names) != 2 || names[0] != "audit" || names[1] != "ops-slack" {
+		t.Errorf("names = %v", names)
+	}
+	if err := set.Validate([]string{"ops-slack", "pager"}); err == nil || !strings.Contains(err.Error(), "pager") {
+		t.Errorf("validate = %v, want unknown notifier pager", err)
+	}
+
+	for _, specs := range [][]Spec{
+		{{Name: "a", Type: "carrier-pigeon"}},
+		{{Type: WebhookType}},
+		{{Name: "a", Type: SlackType}}, // Missing webhook_url
+		{{Name: "a", Type: SlackType, Config: json.RawMessage(`{"webhook_url": "ftp://x"}`)}},  // Not http
+		{{Name: "a", Type: SlackType, Config: json.RawMessage(`{"webhok_url": "https://x"}`)}}, // Typo
+		{{Name: "a", Type: WebhookType, Config: json.RawMessage(`{"url": "https://x", "template": "{{"}`)}},
+		{
+			{Name: "a", Type: WebhookType, Config: json.RawMessage(`{"url": "https://x"}`)},
+			{Name: "a", Type: WebhookType, Config: json.RawMessage(`{"url": "https://y"}`)},
+		},
+	} {
+		if _, err := NewSet(specs); err == nil {
+			t.Errorf("NewSet(%+v) succeeded, want error", specs)
+		}
+	}
+}
+
+func TestSet_NotifyJoinsErrors(t *testing.T) {
+	set, _ := NewSet(nil)
+	ok, failing := &recordingNotifier{}, &recordingNotifier{err: errors.New("boom")}
+	set.Add("ok", "test", ok)
+	set.Add("failing", "test", failing)
+
+	err := set.Notify(context.Background(), Alert{Rule: "r"}, []string{"ok", "failing"})
+	if err == nil || !strings.Contains(err.Error(), `notifier "failing": boom`) {
+		t.Errorf("err = %v", err)
+	}
+	if len(ok.alerts) != 1 || len(failing.alerts) != 1 {
+		t.Errorf("deliveries = %d, %d; want both notified", len(ok.alerts), len(failing.alerts))
+	}
+
+	if err := set.Notify(context.Background(), Alert{Rule: "r"}, []string{"missing"}); err == nil {
+		t.Error("expected error for an unknown notifier")
+	}
+}
+
+func TestHandleNotifiers(t *testing.T) {
+	set, _ := NewSet(nil)
+	ok := &recordingNotifier{}
+	set.Add("ops", "test", ok)
+	set.Add("broken", "test", &recordingNotifier{err: errors.New("boom")})
+
+	rec := httptest.NewRecorder()
+	set.HandleNotifiers(rec, httptest.NewRequest(http.MethodGet, "/api/v1/alerting/notifiers", nil))
+	var list struct {
+		Notifiers []map[string]string `json:"notifiers"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	if len(list.Notifiers) != 2 || list.Notifiers[1]["name"] != "ops" || list.Notifiers[1]["type"] != "test" {
+		t.Errorf("notifiers = %v", list.Notifiers)
+	}
+
+	rec = httptest.NewRecorder()
+	set.HandleNotifiers(rec, httptest.NewRequest(http.MethodPost, "/api/v1/alerting/notifiers/ops/test", nil))
+	if rec.Code != http.StatusOK || len(ok.alerts) != 1 || ok.alerts[0].Rule != "test" {
+		t.Errorf("test send: status %d, alerts %+v", rec.Code, ok.alerts)
+	}
+
+	for _, tc := range []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodPost, "/api/v1/alerting/notifiers/broken/test", http.StatusBadGateway},
+		{http.MethodPost, "/api/v1/alerting/notifiers/missing/test", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/alerting/notifiers/ops/test", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/alerting/notifiers/ops", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		set.HandleNotifiers(rec, httptest.NewRequest(tc.method, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Errorf("%s %s: status = %d, want %d", tc.method, tc.path, rec.Code, tc.want)
+		}
+	}
+}
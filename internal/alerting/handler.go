@@ -0,0 +1,92 @@
+package alerting
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HandleNotifiers handles the notifier endpoints:
+//
+//	GET  /api/v1/alerting/notifiers             - list configured notifiers
+//	POST /api/v1/alerting/notifiers/{name}/test - send a test alert
+func (s *Set) HandleNotifiers(w http.ResponseWriter, r *http.Request) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/alerting/notifiers"), "/")
+	if rest == "" {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		notifiers := make([]map[string]string, 0, len(s.notifiers))
+		for _, name := range s.Names() {
+			notifiers = append(notifiers, map[string]string{"name": name, "type": s.types[name]})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"notifiers": notifiers,
+			"total":     len(notifiers),
+		})
+		return
+	}
+
+	name, action, _ := strings.Cut(rest, "/")
+	if action != "test" {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := s.notifiers[name]; !ok {
+		http.Error(w, "notifier not found", http.StatusNotFound)
+		return
+	}
+
+	alert := Alert{
+		Rule:     "test",
+		Status:   StatusFiring,
+		Severity: "info",
+		Summary:  "Test notification from traceflow",
+		StartsAt: time.Now(),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := s.Notify(r.Context(), alert, []string{name}); err != nil {
+		w.WriteHeader(http.StatusBadGateway)
+		json.NewEncoder(w).Encode(map[string]string{"status": "failed", "error": err.Error()})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"status": "sent"})
+}
+
+// HandleRules handles GET /api/v1/alerting/rules - the loaded alert rules
+// with their pending and firing alerts. A nil Engine serves an empty list.
+func (e *Engine) HandleRules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rules := e.Status()
+	response := map[string]interface{}{
+		"rules": rules,
+		"total": len(rules),
+	}
+	if e != nil {
+		e.mu.Lock()
+		response["file"] = e.path
+		response["loaded_at"] = e.loadedAt
+		if e.loadErr != nil {
+			response["load_error"] = e.loadErr.Error()
+		}
+		if !e.lastEval.IsZero() {
+			response["last_evaluation"] = e.lastEval
+		}
+		if e.evalErr != nil {
+			response["evaluation_error"] = e.evalErr.Error()
+		}
+		e.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
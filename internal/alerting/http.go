@@ -0,0 +1,134 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// DefaultTimeout bounds each notification request. It stays under the
+// collector's HTTP write timeout so test sends can report the outcome.
+const DefaultTimeout = 5 * time.Second
+
+// maxErrorBody is how much of a failed response is quoted in the error.
+const maxErrorBody = 512
+
+var httpClient = &http.Client{Timeout: DefaultTimeout}
+
+// validateURL checks that raw is an absolute http(s) URL.
+func validateURL(field, raw string) error {
+	if raw == "" {
+		return fmt.Errorf("%s is required", field)
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s must be an http or https URL", field)
+	}
+	return nil
+}
+
+// postJSON sends payload as a JSON POST to url.
+func postJSON(ctx context.Context, url string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return send(ctx, http.MethodPost, url, "application/json", body, nil)
+}
+
+// send makes one request and fails unless the response status is 2xx.
+func send(ctx context.Context, method, url, contentType string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "traceflow-alerting")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return fmt.Errorf("%s %s: status %d: %s", method, redactURL(url), resp.StatusCode, strings.TrimSpace(string(detail)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// redactURL drops the path and query of webhook URLs, which usually embed
+// the secret token, before they are logged.
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+// templateFuncs are available in notifier templates.
+var templateFuncs = template.FuncMap{
+	// json encodes a value, e.g. {{json .Summary}} for a quoted string
+	"json": func(v any) (string, error) {
+		b, err := json.Marshal(v)
+		return string(b), err
+	},
+	"upper": strings.ToUpper,
+	"lower": strings.ToLower,
+	// number formats a float without trailing zeros
+	"number": func(f float64) string {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	},
+}
+
+// parseTemplate compiles a notifier message template. An empty text uses
+// fallback.
+func parseTemplate(name, text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	tmpl, err := template.New(name).Funcs(templateFuncs).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// render executes tmpl with the alert as its data.
+func render(tmpl *template.Template, alert Alert) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, alert); err != nil {
+		return "", fmt.Errorf("render template: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// defaultMessage is the message body of chat notifiers without a template.
+const defaultMessage = `{{if .Summary}}{{.Summary}}{{else}}Value {{number .Value}} crossed threshold {{number .Threshold}}{{end}}`
+
+// decodeConfig unmarshals a notifier's JSON config, rejecting unknown fields
+// so typos in the config file are caught at startup.
+func decodeConfig(config json.RawMessage, v any) error {
+	if len(config) == 0 {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(config))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	return nil
+}
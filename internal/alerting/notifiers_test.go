@@ -0,0 +1,269 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// captured is one request received by a test endpoint.
+type captured struct {
+	method      string
+	contentType string
+	header      http.Header
+	body        []byte
+}
+
+// endpoint records requests and answers with status.
+func endpoint(t *testing.T, status int) (*httptest.Server, *[]captured) {
+	t.Helper()
+	var requests []captured
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, captured{r.Method, r.Header.Get("Content-Type"), r.Header, body})
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			io.WriteString(w, "invalid_payload")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+var testAlert = Alert{
+	Rule:      "high-error-rate",
+	Service:   "checkout",
+	Status:    StatusFiring,
+	Severity:  "critical",
+	Summary:   "Error rate above 5%",
+	Value:     0.12,
+	Threshold: 0.05,
+	StartsAt:  time.Unix(1700000000, 0),
+	URL:       "https://traceflow.example.com/traces?service=checkout&errors_only=true",
+}
+
+func TestSlack_Notify(t *testing.T) {
+	server, requests := endpoint(t, http.StatusOK)
+	slack, err := NewSlack(SlackConfig{WebhookURL: server.URL, Channel: "#oncall"})
+	if err != nil {
+		t.Fatalf("NewSlack failed: %v", err)
+	}
+
+	resolved := testAlert
+	resolved.Status = StatusResolved
+	for _, alert := range []Alert{testAlert, resolved} {
+		if err := slack.Notify(context.Background(), alert); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+
+	var msg slackMessage
+	if err := json.Unmarshal((*requests)[0].body, &msg); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if msg.Channel != "#oncall" || msg.Text != "[FIRING] high-error-rate (checkout)" || len(msg.Attachments) != 1 {
+		t.Fatalf("message = %+v", msg)
+	}
+	attachment := msg.Attachments[0]
+	if attachment.Color != "danger" || attachment.Text != "Error rate above 5%" || attachment.TitleLink != testAlert.URL {
+		t.Errorf("attachment = %+v", attachment)
+	}
+	if len(attachment.Fields) != 4 || attachment.Fields[0].Value != "checkout" || attachment.Fields[2].Value != "0.12" {
+		t.Errorf("fields = %+v", attachment.Fields)
+	}
+
+	json.Unmarshal((*requests)[1].body, &msg)
+	if msg.Attachments[0].Color != "good" {
+		t.Errorf("resolved color = %q, want good", msg.Attachments[0].Color)
+	}
+}
+
+func TestSlack_TemplateAndErrors(t *testing.T) {
+	server, requests := endpoint(t, http.StatusBadRequest)
+	slack, err := NewSlack(SlackConfig{
+		WebhookURL: server.URL + "/services/T000/B000/secret",
+		Template:   "{{.Service | upper}} at {{number .Value}}",
+	})
+	if err != nil {
+		t.Fatalf("NewSlack failed: %v", err)
+	}
+
+	err = slack.Notify(context.Background(), testAlert)
+	if err == nil || !strings.Contains(err.Error(), "status 400: invalid_payload") {
+		t.Fatalf("err = %v, want the response status and body", err)
+	}
+	if strings.Contains(err.Error(), "secret") {
+		t.Errorf("error leaks the webhook token: %v", err)
+	}
+
+	var msg slackMessage
+	json.Unmarshal((*requests)[0].body, &msg)
+	if text := msg.Attachments[0].Text; text != "CHECKOUT at 0.12" {
+		t.Errorf("templated text = %q", text)
+	}
+}
+
+func TestTeams_Notify(t *testing.T) {
+	server, requests := endpoint(t, http.StatusOK)
+	teams, err := NewTeams(TeamsConfig{WebhookURL: server.URL})
+	if err != nil {
+		t.Fatalf("NewTeams failed: %v", err)
+	}
+	if err := teams.Notify(context.Background(), testAlert); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+
+	var card teamsCard
+	if err := json.Unmarshal((*requests)[0].body, &card); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if card.Type != "MessageCard" || card.Title != testAlert.Title() || card.ThemeColor != "D93F0B" {
+		t.Errorf("card = %+v", card)
+	}
+	if len(card.Sections) != 1 || len(card.Sections[0].Facts) != 4 {
+		t.Errorf("sections = %+v", card.Sections)
+	}
+	if len(card.Actions) != 1 || card.Actions[0].Targets[0].URI != testAlert.URL {
+		t.Errorf("actions = %+v", card.Actions)
+	}
+}
+
+func TestWebhook_Notify(t *testing.T) {
+	server, requests := endpoint(t, http.StatusOK)
+
+	// Without a template the alert is sent as JSON
+	plain, err := NewWebhook(WebhookConfig{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	if err != nil {
+		t.Fatalf("NewWebhook failed: %v", err)
+	}
+	if err := plain.Notify(context.Background(), testAlert); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	req := (*requests)[0]
+	var alert Alert
+	if err := json.Unmarshal(req.body, &alert); err != nil || alert.DedupKey() != testAlert.DedupKey() || alert.Value != 0.12 {
+		t.Errorf("body = %s (%v)", req.body, err)
+	}
+	if req.method != http.MethodPost || req.header.Get("Authorization") != "Bearer token" {
+		t.Errorf("method %s, headers %v", req.method, req.header)
+	}
+
+	templated, err := NewWebhook(WebhookConfig{
+		URL:         server.URL,
+		Method:      http.MethodPut,
+		ContentType: "text/plain",
+		Template:    `{{.Status}} {{.DedupKey}} {{json .Summary}}`,
+	})
+	if err != nil {
+		t.Fatalf("NewWebhook failed: %v", err)
+	}
+	if err := templated.Notify(context.Background(), testAlert); err != nil {
+		t.Fatalf("Notify failed: %v", err)
+	}
+	req = (*requests)[1]
+	if string(req.body) != `firing high-error-rate/checkout "Error rate above 5%"` || req.method != http.MethodPut || req.contentType != "text/plain" {
+		t.Errorf("request = %s %s %q", req.method, req.contentType, req.body)
+	}
+
+	if _, err := NewWebhook(WebhookConfig{URL: server.URL, Method: http.MethodGet}); err == nil {
+		t.Error("expected error for GET webhooks")
+	}
+}
+
+func TestPagerDuty_TriggerAndResolve(t *testing.T) {
+	server, requests := endpoint(t, http.StatusAccepted)
+	pd, err := NewPagerDuty(PagerDutyConfig{RoutingKey: "R0UT1NG", URL: server.URL})
+	if err != nil {
+		t.Fatalf("NewPagerDuty failed: %v", err)
+	}
+
+	resolved := testAlert
+	resolved.Status = StatusResolved
+	for _, alert := range []Alert{testAlert, resolved} {
+		if err := pd.Notify(context.Background(), alert); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+
+	var trigger, resolve pagerDutyEvent
+	json.Unmarshal((*requests)[0].body, &trigger)
+	json.Unmarshal((*requests)[1].body, &resolve)
+	if trigger.EventAction != "trigger" || trigger.RoutingKey != "R0UT1NG" || trigger.DedupKey != "high-error-rate/checkout" {
+		t.Errorf("trigger = %+v", trigger)
+	}
+	if p := trigger.Payload; p == nil || p.Severity != "critical" || p.Component != "checkout" || p.Source != "traceflow" ||
+		p.Summary != "[FIRING] high-error-rate (checkout): Error rate above 5%" {
+		t.Errorf("payload = %+v", trigger.Payload)
+	}
+	if resolve.EventAction != "resolve" || resolve.DedupKey != trigger.DedupKey || resolve.Payload != nil {
+		t.Errorf("resolve = %+v, want the trigger's dedup key", resolve)
+	}
+
+	// Unknown severities fall back to the configured one
+	unknown := testAlert
+	unknown.Severity = "page-everyone"
+	pd.Notify(context.Background(), unknown)
+	json.Unmarshal((*requests)[2].body, &trigger)
+	if trigger.Payload.Severity != "error" {
+		t.Errorf("severity = %q, want the default error", trigger.Payload.Severity)
+	}
+
+	if _, err := NewPagerDuty(PagerDutyConfig{}); err == nil {
+		t.Error("expected error without a routing key")
+	}
+}
+
+func TestOpsgenie_CreateAndClose(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "GenieKey secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		paths = append(paths, r.URL.RequestURI())
+		if r.URL.Path == "/v2/alerts" {
+			var alert opsgenieAlert
+			json.NewDecoder(r.Body).Decode(&alert)
+			if alert.Alias != "high-error-rate/checkout" || alert.Priority != "P1" || alert.Details["traces"] != testAlert.URL {
+				t.Errorf("alert = %+v", alert)
+			}
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	og, err := NewOpsgenie(OpsgenieConfig{
+		APIKey:     "secret",
+		URL:        server.URL + "/",
+		Responders: []OpsgenieResponder{{Type: "team", Name: "payments"}},
+	})
+	if err != nil {
+		t.Fatalf("NewOpsgenie failed: %v", err)
+	}
+
+	resolved := testAlert
+	resolved.Status = StatusResolved
+	for _, alert := range []Alert{testAlert, resolved} {
+		if err := og.Notify(context.Background(), alert); err != nil {
+			t.Fatalf("Notify failed: %v", err)
+		}
+	}
+	if len(paths) != 2 || paths[0] != "/v2/alerts" || paths[1] != "/v2/alerts/high-error-rate%2Fcheckout/close?identifierType=alias" {
+		t.Errorf("paths = %v", paths)
+	}
+
+	for _, cfg := range []OpsgenieConfig{
+		{},
+		{APIKey: "k", Priority: "P9"},
+		{APIKey: "k", Responders: []OpsgenieResponder{{Type: "team"}}},
+	} {
+		if _, err := NewOpsgenie(cfg); err == nil {
+			t.Errorf("NewOpsgenie(%+v) succeeded, want error", cfg)
+		}
+	}
+}
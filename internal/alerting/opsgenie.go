@@ -0,0 +1,148 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OpsgenieType is the registered type of the Opsgenie alert API notifier.
+const OpsgenieType = "opsgenie"
+
+// DefaultOpsgenieURL is the Opsgenie API base URL; EU accounts use
+// https://api.eu.opsgenie.com.
+const DefaultOpsgenieURL = "https://api.opsgenie.com"
+
+func init() {
+	Register(OpsgenieType, func(config json.RawMessage) (Notifier, error) {
+		var cfg OpsgenieConfig
+		if err := decodeConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		return NewOpsgenie(cfg)
+	})
+}
+
+// OpsgenieConfig configures an Opsgenie API integration.
+type OpsgenieConfig struct {
+	APIKey     string              `json:"api_key"`
+	URL        string              `json:"url,omitempty"` // Defaults to DefaultOpsgenieURL
+	Responders []OpsgenieResponder `json:"responders,omitempty"`
+	Tags       []string            `json:"tags,omitempty"`
+
+	// Priority (P1-P5) for alerts whose severity does not map to one;
+	// defaults to P3
+	Priority string `json:"priority,omitempty"`
+}
+
+// OpsgenieResponder is a team, user, escalation or schedule to notify,
+// identified by name, or username for users.
+type OpsgenieResponder struct {
+	Type     string `json:"type"`
+	Name     string `json:"name,omitempty"`
+	Username string `json:"username,omitempty"`
+	ID       string `json:"id,omitempty"`
+}
+
+// opsgeniePriorities maps alert severities to Opsgenie priorities.
+var opsgeniePriorities = map[string]string{
+	"critical": "P1",
+	"error":    "P2",
+	"warning":  "P3",
+	"info":     "P5",
+}
+
+// Opsgenie creates an alert for each firing alert and closes it when the
+// alert clears. The alert's DedupKey is the Opsgenie alias, so repeated
+// notifications for the same rule and service are deduplicated.
+type Opsgenie struct {
+	cfg OpsgenieConfig
+}
+
+// NewOpsgenie validates cfg and builds the notifier.
+func NewOpsgenie(cfg OpsgenieConfig) (*Opsgenie, error) {
+	if cfg.APIKey == "" {
+		return nil, fmt.Errorf("api_key is required")
+	}
+	if cfg.URL == "" {
+		cfg.URL = DefaultOpsgenieURL
+	}
+	if err := validateURL("url", cfg.URL); err != nil {
+		return nil, err
+	}
+	cfg.URL = strings.TrimSuffix(cfg.URL, "/")
+	switch cfg.Priority {
+	case "":
+		cfg.Priority = "P3"
+	case "P1", "P2", "P3", "P4", "P5":
+	default:
+		return nil, fmt.Errorf("priority must be P1 to P5")
+	}
+	for _, r := range cfg.Responders {
+		if r.Type == "" || (r.Name == "" && r.Username == "" && r.ID == "") {
+			return nil, fmt.Errorf("responders need a type and a name, username or id")
+		}
+	}
+	return &Opsgenie{cfg: cfg}, nil
+}
+
+type opsgenieAlert struct {
+	Message     string              `json:"message"`
+	Alias       string              `json:"alias"`
+	Description string              `json:"description,omitempty"`
+	Responders  []OpsgenieResponder `json:"responders,omitempty"`
+	Tags        []string            `json:"tags,omitempty"`
+	Details     map[string]string   `json:"details,omitempty"`
+	Entity      string              `json:"entity,omitempty"`
+	Source      string              `json:"source"`
+	Priority    string              `json:"priority"`
+}
+
+type opsgenieClose struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+// Notify implements Notifier.
+func (o *Opsgenie) Notify(ctx context.Context, alert Alert) error {
+	alias := truncate(alert.DedupKey(), 512)
+	target := o.cfg.URL + "/v2/alerts"
+	var payload any
+	if alert.Firing() {
+		priority, ok := opsgeniePriorities[alert.Severity]
+		if !ok {
+			priority = o.cfg.Priority
+		}
+		details := make(map[string]string)
+		for key, value := range alertDetails(alert) {
+			details[key] = fmt.Sprint(value)
+		}
+		if alert.URL != "" {
+			details["traces"] = alert.URL
+		}
+		payload = opsgenieAlert{
+			Message:     truncate(alert.Title(), 130),
+			Alias:       alias,
+			Description: summaryOrValue(alert),
+			Responders:  o.cfg.Responders,
+			Tags:        o.cfg.Tags,
+			Details:     details,
+			Entity:      alert.Service,
+			Source:      "traceflow",
+			Priority:    priority,
+		}
+	} else {
+		target += "/" + url.PathEscape(alias) + "/close?identifierType=alias"
+		payload = opsgenieClose{Source: "traceflow", Note: "Alert condition cleared"}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return send(ctx, http.MethodPost, target, "application/json", body,
+		map[string]string{"Authorization": "GenieKey " + o.cfg.APIKey})
+}
@@ -0,0 +1,154 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PagerDutyType is the registered type of the PagerDuty Events API v2 notifier.
+const PagerDutyType = "pagerduty"
+
+// DefaultPagerDutyURL is the Events API v2 enqueue endpoint.
+const DefaultPagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+
+func init() {
+	Register(PagerDutyType, func(config json.RawMessage) (Notifier, error) {
+		var cfg PagerDutyConfig
+		if err := decodeConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		return NewPagerDuty(cfg)
+	})
+}
+
+// PagerDutyConfig configures a PagerDuty Events API v2 integration.
+type PagerDutyConfig struct {
+	RoutingKey string `json:"routing_key"`        // Integration key of the service
+	URL        string `json:"url,omitempty"`      // Defaults to DefaultPagerDutyURL
+	Source     string `json:"source,omitempty"`   // Defaults to "traceflow"
+	Severity   string `json:"severity,omitempty"` // For alerts without a valid one; defaults to "error"
+}
+
+// pagerDutySeverities are the severities the Events API accepts.
+var pagerDutySeverities = map[string]bool{"critical": true, "error": true, "warning": true, "info": true}
+
+// PagerDuty triggers an incident for each firing alert and resolves it when
+// the alert clears. Both events carry the alert's DedupKey, so repeated
+// notifications for the same rule and service update a single incident.
+type PagerDuty struct {
+	cfg PagerDutyConfig
+}
+
+// NewPagerDuty validates cfg and builds the notifier.
+func NewPagerDuty(cfg PagerDutyConfig) (*PagerDuty, error) {
+	if cfg.RoutingKey == "" {
+		return nil, fmt.Errorf("routing_key is required")
+	}
+	if cfg.URL == "" {
+		cfg.URL = DefaultPagerDutyURL
+	}
+	if err := validateURL("url", cfg.URL); err != nil {
+		return nil, err
+	}
+	if cfg.Source == "" {
+		cfg.Source = "traceflow"
+	}
+	if cfg.Severity == "" {
+		cfg.Severity = "error"
+	}
+	if !pagerDutySeverities[cfg.Severity] {
+		return nil, fmt.Errorf("severity must be critical, error, warning or info")
+	}
+	return &PagerDuty{cfg: cfg}, nil
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"` // "trigger" or "resolve"
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"` // Trigger only
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp,omitempty"`
+	Component     string         `json:"component,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// Notify implements Notifier.
+func (p *PagerDuty) Notify(ctx context.Context, alert Alert) error {
+	event := pagerDutyEvent{
+		RoutingKey:  p.cfg.RoutingKey,
+		EventAction: "resolve",
+		DedupKey:    alert.DedupKey(),
+	}
+	if alert.Firing() {
+		severity := alert.Severity
+		if !pagerDutySeverities[severity] {
+			severity = p.cfg.Severity
+		}
+		event.EventAction = "trigger"
+		event.Payload = &pagerDutyPayload{
+			Summary:       truncate(alert.Title()+": "+summaryOrValue(alert), 1024),
+			Source:        p.cfg.Source,
+			Severity:      severity,
+			Timestamp:     alert.StartsAt.UTC().Format(time.RFC3339),
+			Component:     alert.Service,
+			Class:         alert.Rule,
+			CustomDetails: alertDetails(alert),
+		}
+		if alert.URL != "" {
+			event.Links = []pagerDutyLink{{Href: alert.URL, Text: "View traces"}}
+		}
+	}
+	return postJSON(ctx, p.cfg.URL, event)
+}
+
+// summaryOrValue returns the alert summary, or describes the value that
+// crossed the threshold when there is none.
+func summaryOrValue(alert Alert) string {
+	if alert.Summary != "" {
+		return alert.Summary
+	}
+	return fmt.Sprintf("value %g crossed threshold %g", alert.Value, alert.Threshold)
+}
+
+// alertDetails lists the alert fields incident tools show as details.
+func alertDetails(alert Alert) map[string]any {
+	details := map[string]any{
+		"rule":      alert.Rule,
+		"value":     alert.Value,
+		"threshold": alert.Threshold,
+	}
+	if alert.Service != "" {
+		details["service"] = alert.Service
+	}
+	for key, value := range alert.Labels {
+		details[key] = value
+	}
+	return details
+}
+
+// truncate shortens s to at most n bytes for APIs with field limits,
+// without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
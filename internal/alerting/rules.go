@@ -0,0 +1,582 @@
+package alerting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+)
+
+// Defaults for rules and the engine.
+const (
+	DefaultRuleWindow   = 5 * time.Minute
+	DefaultEvalInterval = 30 * time.Second
+)
+
+// Rule metrics. Latencies are in milliseconds, error_rate is a fraction
+// (0.05 is 5%) and request_rate is spans per second over the window.
+const (
+	MetricErrorRate   = "error_rate"
+	MetricErrors      = "errors"
+	MetricSpans       = "spans"
+	MetricRequestRate = "request_rate"
+	MetricAvg         = "avg_ms"
+	MetricP50         = "p50_ms"
+	MetricP95         = "p95_ms"
+	MetricP99         = "p99_ms"
+)
+
+// counts are the metrics evaluated for services with no spans in the window,
+// so a rule can fire when traffic stops.
+var counts = map[string]bool{MetricErrors: true, MetricSpans: true, MetricRequestRate: true}
+
+// Rule fires when a metric of a service's spans over a window crosses a
+// threshold, e.g. p99_ms > 500 for checkout over 5m.
+type Rule struct {
+	Name      string  `json:"name"`
+	Service   string  `json:"service,omitempty"`   // Empty: every service, each alerting separately
+	Operation string  `json:"operation,omitempty"` // Empty: all of the service's spans
+	Metric    string  `json:"metric"`
+	Op        string  `json:"op,omitempty"` // >, >=, < or <=; default >
+	Threshold float64 `json:"threshold"`
+	Window    string  `json:"window,omitempty"` // Default 5m
+
+	// For is how long the condition must hold before the rule fires
+	For string `json:"for,omitempty"`
+
+	// MinSpans is the fewest spans in the window for rate and latency
+	// metrics to be evaluated (default 1), so a handful of slow requests
+	// cannot fire a rule
+	MinSpans int64 `json:"min_spans,omitempty"`
+
+	Severity string   `json:"severity,omitempty"` // Default "warning"
+	Notify   []string `json:"notify"`
+}
+
+// RulesFile is the layout of the -alert-rules file.
+type RulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// compiledRule is a validated Rule.
+type compiledRule struct {
+	Rule
+	window time.Duration
+	hold   time.Duration
+}
+
+func compileRule(rule Rule, notifiers *Set) (*compiledRule, error) {
+	if rule.Name == "" {
+		return nil, errors.New("rule name is required")
+	}
+	c := &compiledRule{Rule: rule, window: DefaultRuleWindow}
+	switch rule.Metric {
+	case MetricErrorRate, MetricErrors, MetricSpans, MetricRequestRate, MetricAvg, MetricP50, MetricP95, MetricP99:
+	default:
+		return nil, fmt.Errorf("rule %q: unknown metric %q", rule.Name, rule.Metric)
+	}
+	if c.Op == "" {
+		c.Op = ">"
+	}
+	if _, err := compare(c.Op, 0, 0); err != nil {
+		return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+	if rule.Window != "" {
+		d, err := time.ParseDuration(rule.Window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("rule %q: invalid window %q", rule.Name, rule.Window)
+		}
+		c.window = d
+	}
+	if rule.For != "" {
+		d, err := time.ParseDuration(rule.For)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("rule %q: invalid for %q", rule.Name, rule.For)
+		}
+		c.hold = d
+	}
+	if c.MinSpans <= 0 {
+		c.MinSpans = 1
+	}
+	if c.Severity == "" {
+		c.Severity = "warning"
+	}
+	if len(rule.Notify) == 0 {
+		return nil, fmt.Errorf("rule %q: notify at least one notifier", rule.Name)
+	}
+	if err := notifiers.Validate(rule.Notify); err != nil {
+		return nil, fmt.Errorf("rule %q: %w", rule.Name, err)
+	}
+	return c, nil
+}
+
+// compare reports whether value op threshold holds.
+func compare(op string, value, threshold float64) (bool, error) {
+	switch op {
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	}
+	return false, fmt.Errorf("unknown op %q", op)
+}
+
+// value returns the rule's metric from stats.
+func (r *compiledRule) value(stats storage.SpanStats) float64 {
+	switch r.Metric {
+	case MetricErrorRate:
+		return stats.ErrorRate
+	case MetricErrors:
+		return float64(stats.Errors)
+	case MetricSpans:
+		return float64(stats.Spans)
+	case MetricRequestRate:
+		return float64(stats.Spans) / r.window.Seconds()
+	case MetricAvg:
+		return milliseconds(stats.AvgDuration)
+	case MetricP50:
+		return milliseconds(stats.P50Duration)
+	case MetricP95:
+		return milliseconds(stats.P95Duration)
+	case MetricP99:
+		return milliseconds(stats.P99Duration)
+	}
+	return 0
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// loadRules reads and validates a rules file.
+func loadRules(path string, notifiers *Set) ([]*compiledRule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file RulesFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	rules := make([]*compiledRule, 0, len(file.Rules))
+	names := make(map[string]bool)
+	for _, rule := range file.Rules {
+		compiled, err := compileRule(rule, notifiers)
+		if err != nil {
+			return nil, err
+		}
+		if names[rule.Name] {
+			return nil, fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+// StatsSource is what rules are evaluated against; every storage.Store is
+// one.
+type StatsSource interface {
+	GetServiceStats(ctx context.Context, query *storage.StatsQuery) ([]storage.ServiceStats, error)
+	GetOperationStats(ctx context.Context, query *storage.StatsQuery) ([]storage.OperationStats, error)
+}
+
+// Alert states of a rule for one service.
+const (
+	StateInactive = "inactive"
+	StatePending  = "pending" // Condition holds, waiting out the rule's for
+	StateFiring   = "firing"
+)
+
+// instance is the alert state of a rule for one service.
+type instance struct {
+	state   string
+	value   float64
+	since   time.Time // When the condition started holding
+	firedAt time.Time
+}
+
+// Engine evaluates alert rules from a file against stored spans, sending
+// notifications when a rule starts and stops firing. The file is reloaded
+// whenever it changes; a file that fails to load keeps the previous rules.
+type Engine struct {
+	path      string
+	interval  time.Duration
+	source    StatsSource
+	notifiers *Set
+	logger    *slog.Logger
+	now       func() time.Time
+
+	mu        sync.Mutex
+	rules     []*compiledRule
+	instances map[string]map[string]*instance // rule -> service -> state
+	fileInfo  os.FileInfo                     // Of the loaded file, to detect changes
+	loadedAt  time.Time
+	loadErr   error
+	lastEval  time.Time
+	evalErr   error
+
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewEngine loads the rules file at path, failing if it is invalid.
+// Rules are evaluated every interval (DefaultEvalInterval if zero) once
+// Start is called.
+func NewEngine(path string, interval time.Duration, source StatsSource, notifiers *Set, logger *slog.Logger) (*Engine, error) {
+	if interval <= 0 {
+		interval = DefaultEvalInterval
+	}
+	e := &Engine{
+		path:      path,
+		interval:  interval,
+		source:    source,
+		notifiers: notifiers,
+		logger:    logger.With("component", "alert-rules"),
+		now:       time.Now,
+		instances: make(map[string]map[string]*instance),
+		done:      make(chan struct{}),
+	}
+	if _, err := e.Reload(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Rules returns the number of loaded rules.
+func (e *Engine) Rules() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return len(e.rules)
+}
+
+// Reload loads the rules file if it changed since the last load, reporting
+// whether it did. Firing alerts of rules that are gone are resolved.
+func (e *Engine) Reload() (bool, error) {
+	info, err := os.Stat(e.path)
+	if err == nil {
+		e.mu.Lock()
+		unchanged := e.fileInfo != nil && info.ModTime().Equal(e.fileInfo.ModTime()) && info.Size() == e.fileInfo.Size()
+		e.mu.Unlock()
+		if unchanged {
+			return false, nil
+		}
+	}
+
+	rules, err := loadRules(e.path, e.notifiers)
+	e.mu.Lock()
+	if err != nil {
+		e.loadErr = err
+		e.mu.Unlock()
+		return false, err
+	}
+	previous := e.rules
+	e.rules = rules
+	e.fileInfo = info
+	e.loadedAt = e.now()
+	e.loadErr = nil
+
+	type notification struct {
+		alert Alert
+		names []string
+	}
+	var resolved []notification
+	for _, old := range previous {
+		if e.rule(old.Name) != nil {
+			continue
+		}
+		for service, inst := range e.instances[old.Name] {
+			if inst.state == StateFiring {
+				alert := old.alert(service, StatusResolved, inst)
+				ends := e.loadedAt
+				alert.EndsAt = &ends
+				alert.Summary = "rule removed"
+				resolved = append(resolved, notification{*alert, old.Notify})
+			}
+		}
+		delete(e.instances, old.Name)
+	}
+	e.mu.Unlock()
+
+	for _, n := range resolved {
+		e.notify(n.alert, n.names)
+	}
+	return true, nil
+}
+
+// rule returns the loaded rule called name. Caller holds mu.
+func (e *Engine) rule(name string) *compiledRule {
+	for _, rule := range e.rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	return nil
+}
+
+// Start evaluates the rules every interval, reloading the file first if it
+// changed, until Stop.
+func (e *Engine) Start() {
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-e.done:
+				return
+			case <-ticker.C:
+			}
+			if reloaded, err := e.Reload(); err != nil {
+				e.logger.Error("failed to reload alert rules, keeping the previous ones", "path", e.path, "error", err)
+			} else if reloaded {
+				e.logger.Info("alert rules reloaded", "path", e.path, "rules", e.Rules())
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+			e.Evaluate(ctx)
+			cancel()
+		}
+	}()
+}
+
+// Stop ends evaluation. It is a no-op on a nil Engine.
+func (e *Engine) Stop() {
+	if e == nil {
+		return
+	}
+	e.stopOnce.Do(func() { close(e.done) })
+	e.wg.Wait()
+}
+
+// Evaluate checks every rule once and sends the resulting notifications.
+func (e *Engine) Evaluate(ctx context.Context) {
+	e.mu.Lock()
+	rules := e.rules
+	e.mu.Unlock()
+
+	now := e.now()
+	type notification struct {
+		alert Alert
+		names []string
+	}
+	var notifications []notification
+	var errs []error
+	for _, rule := range rules {
+		values, err := e.values(ctx, rule, now)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("rule %q: %w", rule.Name, err))
+			continue
+		}
+
+		e.mu.Lock()
+		services := e.instances[rule.Name]
+		if services == nil {
+			services = make(map[string]*instance)
+			e.instances[rule.Name] = services
+		}
+		for service := range services {
+			if _, ok := values[service]; !ok {
+				values[service] = nil // No longer reported: the condition cleared
+			}
+		}
+		for service, value := range values {
+			inst := services[service]
+			if inst == nil {
+				inst = &instance{state: StateInactive}
+				services[service] = inst
+			}
+			if alert := rule.step(inst, service, value, now); alert != nil {
+				notifications = append(notifications, notification{*alert, rule.Notify})
+			}
+			if inst.state == StateInactive {
+				delete(services, service)
+			}
+		}
+		e.mu.Unlock()
+	}
+
+	e.mu.Lock()
+	e.lastEval = now
+	e.evalErr = errors.Join(errs...)
+	e.mu.Unlock()
+	if len(errs) > 0 {
+		e.logger.Error("failed to evaluate alert rules", "error", errors.Join(errs...))
+	}
+
+	for _, n := range notifications {
+		e.notify(n.alert, n.names)
+	}
+}
+
+// values returns the rule's metric for each service it covers over the
+// window ending at now. Services whose condition cannot be evaluated map to
+// nil.
+func (e *Engine) values(ctx context.Context, rule *compiledRule, now time.Time) (map[string]*float64, error) {
+	query := &storage.StatsQuery{Service: rule.Service, StartTime: now.Add(-rule.window), EndTime: now}
+	stats := make(map[string]storage.SpanStats)
+	if rule.Operation != "" {
+		operations, err := e.source.GetOperationStats(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, op := range operations {
+			if op.Operation == rule.Operation {
+				stats[op.Service] = op.SpanStats
+			}
+		}
+	} else {
+		services, err := e.source.GetServiceStats(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range services {
+			stats[service.Service] = service.SpanStats
+		}
+	}
+	if _, ok := stats[rule.Service]; !ok && rule.Service != "" {
+		stats[rule.Service] = storage.SpanStats{} // So traffic rules see it stop
+	}
+
+	values := make(map[string]*float64, len(stats))
+	for service, s := range stats {
+		if s.Spans < rule.MinSpans && !counts[rule.Metric] {
+			values[service] = nil
+			continue
+		}
+		value := rule.value(s)
+		values[service] = &value
+	}
+	return values, nil
+}
+
+// step advances a service's alert state given the rule's current value
+// (nil when not evaluated), returning the notification to send, if any.
+func (r *compiledRule) step(inst *instance, service string, value *float64, now time.Time) *Alert {
+	holds := false
+	if value != nil {
+		inst.value = *value
+		holds, _ = compare(r.Op, *value, r.Threshold)
+	}
+
+	if !holds {
+		wasFiring := inst.state == StateFiring
+		inst.state = StateInactive
+		if wasFiring {
+			alert := r.alert(service, StatusResolved, inst)
+			ends := now
+			alert.EndsAt = &ends
+			return alert
+		}
+		return nil
+	}
+
+	switch inst.state {
+	case StateInactive:
+		inst.state = StatePending
+		inst.since = now
+		fallthrough
+	case StatePending:
+		if now.Sub(inst.since) >= r.hold {
+			inst.state = StateFiring
+			inst.firedAt = now
+			return r.alert(service, StatusFiring, inst)
+		}
+	}
+	return nil
+}
+
+// alert builds the rule's notification for a service.
+func (r *compiledRule) alert(service string, status Status, inst *instance) *Alert {
+	subject := service
+	if r.Operation != "" {
+		subject += " " + r.Operation
+	}
+	summary := fmt.Sprintf("%s of %s is %.4g (%s %g) over %s", r.Metric, subject, inst.value, r.Op, r.Threshold, r.window)
+	if status == StatusResolved {
+		summary = fmt.Sprintf("%s of %s is back at %.4g over %s", r.Metric, subject, inst.value, r.window)
+	}
+	labels := map[string]string{"metric": r.Metric}
+	if r.Operation != "" {
+		labels["operation"] = r.Operation
+	}
+	return &Alert{
+		Rule:      r.Name,
+		Service:   service,
+		Status:    status,
+		Severity:  r.Severity,
+		Summary:   summary,
+		Value:     inst.value,
+		Threshold: r.Threshold,
+		StartsAt:  inst.firedAt,
+		Labels:    labels,
+	}
+}
+
+// notify sends an alert, logging failures.
+func (e *Engine) notify(alert Alert, names []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTimeout)
+	defer cancel()
+	if err := e.notifiers.Notify(ctx, alert, names); err != nil {
+		e.logger.Error("failed to send alert", "rule", alert.Rule, "service", alert.Service, "status", alert.Status, "error", err)
+		return
+	}
+	e.logger.Info("alert sent", "rule", alert.Rule, "service", alert.Service, "status", alert.Status, "value", alert.Value)
+}
+
+// RuleStatus is a loaded rule and its alert state per service.
+type RuleStatus struct {
+	Rule
+	Alerts []AlertState `json:"alerts"`
+}
+
+// AlertState is the state of a rule for one service.
+type AlertState struct {
+	Service string     `json:"service"`
+	State   string     `json:"state"`
+	Value   float64    `json:"value"`
+	Since   time.Time  `json:"since"`
+	FiredAt *time.Time `json:"fired_at,omitempty"`
+}
+
+// Status returns the loaded rules with their pending and firing alerts.
+func (e *Engine) Status() []RuleStatus {
+	if e == nil {
+		return []RuleStatus{}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	statuses := make([]RuleStatus, 0, len(e.rules))
+	for _, rule := range e.rules {
+		status := RuleStatus{Rule: rule.Rule, Alerts: []AlertState{}}
+		status.Op, status.Severity, status.MinSpans = rule.Op, rule.Severity, rule.MinSpans
+		for service, inst := range e.instances[rule.Name] {
+			state := AlertState{Service: service, State: inst.state, Value: inst.value, Since: inst.since}
+			if inst.state == StateFiring {
+				firedAt := inst.firedAt
+				state.FiredAt = &firedAt
+			}
+			status.Alerts = append(status.Alerts, state)
+		}
+		sort.Slice(status.Alerts, func(i, j int) bool { return status.Alerts[i].Service < status.Alerts[j].Service })
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,166 @@
+package alerting
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// writeRules writes a rules file, moving its modification time forward so
+// the engine sees each write as a change.
+func writeRules(t *testing.T, path, rules string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Now().Add(time.Duration(len(rules)) * time.Second)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testEngine(t *testing.T, rules string) (*Engine, *storage.MemoryStore, *recordingNotifier) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "rules.json")
+	writeRules(t, path, rules)
+
+	notifier := &recordingNotifier{}
+	set, _ := NewSet(nil)
+	set.Add("oncall", "test", notifier)
+	store := storage.NewMemoryStore(100)
+	engine, err := NewEngine(path, time.Second, store, set, slog.Default())
+	if err != nil {
+		t.Fatalf("NewEngine failed: %v", err)
+	}
+	return engine, store, notifier
+}
+
+func writeSpans(t *testing.T, store *storage.MemoryStore, service string, n int, duration time.Duration, status string) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		span := &models.Span{
+			TraceID: models.GenerateTraceID(), SpanID: models.GenerateSpanID(),
+			ServiceName: service, OperationName: "GET /", StartTime: time.Now().Add(-time.Second),
+			Duration: duration, Status: status,
+		}
+		if err := store.WriteSpan(context.Background(), span); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestEngine_FiresAndResolves(t *testing.T) {
+	engine, store, notifier := testEngine(t, `{"rules": [
+		{"name": "errors", "service": "checkout", "metric": "error_rate", "threshold": 0.2, "notify": ["oncall"]}
+	]}`)
+
+	writeSpans(t, store, "checkout", 6, 10*time.Millisecond, "ok")
+	engine.Evaluate(context.Background())
+	if len(notifier.alerts) != 0 {
+		t.Fatalf("fired below threshold: %+v", notifier.alerts)
+	}
+
+	writeSpans(t, store, "checkout", 4, 10*time.Millisecond, "error")
+	engine.Evaluate(context.Background())
+	engine.Evaluate(context.Background()) // Still firing: no repeat
+	if len(notifier.alerts) != 1 {
+		t.Fatalf("expected one alert, got %+v", notifier.alerts)
+	}
+	alert := notifier.alerts[0]
+	if alert.Status != StatusFiring || alert.Service != "checkout" || alert.Value != 0.4 || alert.Threshold != 0.2 {
+		t.Errorf("unexpected alert: %+v", alert)
+	}
+	if status := engine.Status(); len(status[0].Alerts) != 1 || status[0].Alerts[0].State != StateFiring {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	writeSpans(t, store, "checkout", 30, 10*time.Millisecond, "ok")
+	engine.Evaluate(context.Background())
+	if len(notifier.alerts) != 2 || notifier.alerts[1].Status != StatusResolved || notifier.alerts[1].EndsAt == nil {
+		t.Fatalf("expected a resolved alert, got %+v", notifier.alerts)
+	}
+}
+
+func TestEngine_ForAndEveryService(t *testing.T) {
+	engine, store, notifier := testEngine(t, `{"rules": [
+		{"name": "slow", "metric": "p99_ms", "threshold": 100, "for": "1m", "min_spans": 3, "notify": ["oncall"]}
+	]}`)
+	now := time.Now()
+	engine.now = func() time.Time { return now }
+
+	writeSpans(t, store, "api", 5, 2*time.Second, "ok")
+	writeSpans(t, store, "db", 2, 2*time.Second, "ok") // Under min_spans
+	engine.Evaluate(context.Background())
+	status := engine.Status()[0]
+	if len(status.Alerts) != 1 || status.Alerts[0].Service != "api" || status.Alerts[0].State != StatePending {
+		t.Fatalf("expected api pending, got %+v", status.Alerts)
+	}
+
+	now = now.Add(time.Minute)
+	engine.Evaluate(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Service != "api" || notifier.alerts[0].Value < 100 {
+		t.Fatalf("expected api to fire, got %+v", notifier.alerts)
+	}
+}
+
+func TestEngine_Reload(t *testing.T) {
+	engine, _, notifier := testEngine(t, `{"rules": [
+		{"name": "traffic", "service": "checkout", "metric": "spans", "op": "<", "threshold": 1, "notify": ["oncall"]}
+	]}`)
+
+	// No spans at all: the traffic rule fires for its service
+	engine.Evaluate(context.Background())
+	if len(notifier.alerts) != 1 || notifier.alerts[0].Status != StatusFiring {
+		t.Fatalf("expected traffic to fire, got %+v", notifier.alerts)
+	}
+
+	if reloaded, err := engine.Reload(); reloaded || err != nil {
+		t.Errorf("unchanged file reloaded = %v, %v", reloaded, err)
+	}
+
+	// An invalid file keeps the previous rules
+	writeRules(t, engine.path, `{"rules": [{"name": "bad", "metric": "vibes", "notify": ["oncall"]}]}`)
+	if _, err := engine.Reload(); err == nil || engine.Rules() != 1 {
+		t.Fatalf("invalid file: err %v, rules %d", err, engine.Rules())
+	}
+
+	// Removing the rule resolves its alert
+	writeRules(t, engine.path, `{"rules": [{"name": "latency", "metric": "p95_ms", "threshold": 500, "notify": ["oncall"]}]}`)
+	if reloaded, err := engine.Reload(); !reloaded || err != nil {
+		t.Fatalf("reload = %v, %v", reloaded, err)
+	}
+	if len(notifier.alerts) != 2 || notifier.alerts[1].Status != StatusResolved || notifier.alerts[1].Rule != "traffic" {
+		t.Fatalf("expected traffic resolved, got %+v", notifier.alerts)
+	}
+	if status := engine.Status(); len(status) != 1 || status[0].Name != "latency" {
+		t.Errorf("unexpected rules after reload: %+v", status)
+	}
+}
+
+func TestLoadRules_Invalid(t *testing.T) {
+	set, _ := NewSet(nil)
+	set.Add("oncall", "test", &recordingNotifier{})
+	for name, rules := range map[string]string{
+		"unknown metric":   `{"rules": [{"name": "a", "metric": "vibes", "notify": ["oncall"]}]}`,
+		"unknown op":       `{"rules": [{"name": "a", "metric": "spans", "op": "!=", "notify": ["oncall"]}]}`,
+		"bad window":       `{"rules": [{"name": "a", "metric": "spans", "window": "soon", "notify": ["oncall"]}]}`,
+		"no notifier":      `{"rules": [{"name": "a", "metric": "spans"}]}`,
+		"unknown notifier": `{"rules": [{"name": "a", "metric": "spans", "notify": ["pager"]}]}`,
+		"duplicate":        `{"rules": [{"name": "a", "metric": "spans", "notify": ["oncall"]}, {"name": "a", "metric": "errors", "notify": ["oncall"]}]}`,
+		"unknown field":    `{"rules": [{"name": "a", "metric": "spans", "treshold": 1, "notify": ["oncall"]}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			writeRules(t, path, rules)
+			if _, err := loadRules(path, set); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"text/template"
+)
+
+// SlackType is the registered type of the Slack incoming webhook notifier.
+const SlackType = "slack"
+
+func init() {
+	Register(SlackType, func(config json.RawMessage) (Notifier, error) {
+		var cfg SlackConfig
+		if err := decodeConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		return NewSlack(cfg)
+	})
+}
+
+// SlackConfig configures a Slack incoming webhook. Channel, Username and
+// IconEmoji override the webhook's defaults where Slack allows it.
+type SlackConfig struct {
+	WebhookURL string `json:"webhook_url"`
+	Channel    string `json:"channel,omitempty"`
+	Username   string `json:"username,omitempty"`
+	IconEmoji  string `json:"icon_emoji,omitempty"`
+
+	// Template renders the message text from the Alert (text/template);
+	// it defaults to the alert summary
+	Template string `json:"template,omitempty"`
+}
+
+// Slack posts alerts as colored message attachments.
+type Slack struct {
+	cfg  SlackConfig
+	text *template.Template
+}
+
+// NewSlack validates cfg and builds the notifier.
+func NewSlack(cfg SlackConfig) (*Slack, error) {
+	if err := validateURL("webhook_url", cfg.WebhookURL); err != nil {
+		return nil, err
+	}
+	text, err := parseTemplate(SlackType, cfg.Template, defaultMessage)
+	if err != nil {
+		return nil, err
+	}
+	return &Slack{cfg: cfg, text: text}, nil
+}
+
+type slackMessage struct {
+	Text        string            `json:"text"` // Fallback for notifications
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	IconEmoji   string            `json:"icon_emoji,omitempty"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color     string       `json:"color"`
+	Title     string       `json:"title"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Text      string       `json:"text"`
+	Fields    []slackField `json:"fields,omitempty"`
+	Timestamp int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// Notify implements Notifier.
+func (s *Slack) Notify(ctx context.Context, alert Alert) error {
+	text, err := render(s.text, alert)
+	if err != nil {
+		return err
+	}
+
+	attachment := slackAttachment{
+		Color:     "danger",
+		Title:     alert.Title(),
+		TitleLink: alert.URL,
+		Text:      text,
+		Timestamp: alert.StartsAt.Unix(),
+	}
+	if !alert.Firing() {
+		attachment.Color = "good"
+	}
+	for _, f := range alertFacts(alert) {
+		attachment.Fields = append(attachment.Fields, slackField{Title: f.name, Value: f.value, Short: true})
+	}
+
+	return postJSON(ctx, s.cfg.WebhookURL, slackMessage{
+		Text:        alert.Title(),
+		Channel:     s.cfg.Channel,
+		Username:    s.cfg.Username,
+		IconEmoji:   s.cfg.IconEmoji,
+		Attachments: []slackAttachment{attachment},
+	})
+}
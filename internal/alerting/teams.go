@@ -0,0 +1,133 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"text/template"
+)
+
+// TeamsType is the registered type of the Microsoft Teams notifier.
+const TeamsType = "teams"
+
+func init() {
+	Register(TeamsType, func(config json.RawMessage) (Notifier, error) {
+		var cfg TeamsConfig
+		if err := decodeConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		return NewTeams(cfg)
+	})
+}
+
+// TeamsConfig configures a Microsoft Teams incoming webhook.
+type TeamsConfig struct {
+	WebhookURL string `json:"webhook_url"`
+
+	// Template renders the card text from the Alert (text/template);
+	// it defaults to the alert summary
+	Template string `json:"template,omitempty"`
+}
+
+// Teams posts alerts as MessageCards, which Teams incoming webhooks and
+// Power Automate webhook flows both accept.
+type Teams struct {
+	cfg  TeamsConfig
+	text *template.Template
+}
+
+// NewTeams validates cfg and builds the notifier.
+func NewTeams(cfg TeamsConfig) (*Teams, error) {
+	if err := validateURL("webhook_url", cfg.WebhookURL); err != nil {
+		return nil, err
+	}
+	text, err := parseTemplate(TeamsType, cfg.Template, defaultMessage)
+	if err != nil {
+		return nil, err
+	}
+	return &Teams{cfg: cfg, text: text}, nil
+}
+
+type teamsCard struct {
+	Type       string         `json:"@type"`
+	Context    string         `json:"@context"`
+	Summary    string         `json:"summary"`
+	ThemeColor string         `json:"themeColor"`
+	Title      string         `json:"title"`
+	Text       string         `json:"text"`
+	Sections   []teamsSection `json:"sections,omitempty"`
+	Actions    []teamsAction  `json:"potentialAction,omitempty"`
+}
+
+type teamsSection struct {
+	Facts []teamsFact `json:"facts"`
+}
+
+type teamsFact struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type teamsAction struct {
+	Type    string        `json:"@type"`
+	Name    string        `json:"name"`
+	Targets []teamsTarget `json:"targets"`
+}
+
+type teamsTarget struct {
+	OS  string `json:"os"`
+	URI string `json:"uri"`
+}
+
+// Notify implements Notifier.
+func (t *Teams) Notify(ctx context.Context, alert Alert) error {
+	text, err := render(t.text, alert)
+	if err != nil {
+		return err
+	}
+
+	card := teamsCard{
+		Type:       "MessageCard",
+		Context:    "https://schema.org/extensions",
+		Summary:    alert.Title(),
+		ThemeColor: "D93F0B",
+		Title:      alert.Title(),
+		Text:       text,
+	}
+	if !alert.Firing() {
+		card.ThemeColor = "2EB67D"
+	}
+	var section teamsSection
+	for _, f := range alertFacts(alert) {
+		section.Facts = append(section.Facts, teamsFact{Name: f.name, Value: f.value})
+	}
+	card.Sections = []teamsSection{section}
+	if alert.URL != "" {
+		card.Actions = []teamsAction{{
+			Type:    "OpenUri",
+			Name:    "View traces",
+			Targets: []teamsTarget{{OS: "default", URI: alert.URL}},
+		}}
+	}
+	return postJSON(ctx, t.cfg.WebhookURL, card)
+}
+
+type fact struct {
+	name, value string
+}
+
+// alertFacts lists the alert details shown as fields in chat messages.
+func alertFacts(alert Alert) []fact {
+	var facts []fact
+	if alert.Service != "" {
+		facts = append(facts, fact{"Service", alert.Service})
+	}
+	if alert.Severity != "" {
+		facts = append(facts, fact{"Severity", alert.Severity})
+	}
+	facts = append(facts,
+		fact{"Value", strconv.FormatFloat(alert.Value, 'g', 6, 64)},
+		fact{"Threshold", strconv.FormatFloat(alert.Threshold, 'g', 6, 64)},
+	)
+	return facts
+}
@@ -0,0 +1,86 @@
+package alerting
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"text/template"
+)
+
+// WebhookType is the registered type of the generic webhook notifier.
+const WebhookType = "webhook"
+
+func init() {
+	Register(WebhookType, func(config json.RawMessage) (Notifier, error) {
+		var cfg WebhookConfig
+		if err := decodeConfig(config, &cfg); err != nil {
+			return nil, err
+		}
+		return NewWebhook(cfg)
+	})
+}
+
+// WebhookConfig configures a generic HTTP webhook.
+type WebhookConfig struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"` // Defaults to POST
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Template renders the request body from the Alert (text/template);
+	// without one the alert is sent as JSON
+	Template    string `json:"template,omitempty"`
+	ContentType string `json:"content_type,omitempty"` // Defaults to application/json
+}
+
+// Webhook sends alerts to an arbitrary HTTP endpoint.
+type Webhook struct {
+	cfg  WebhookConfig
+	body *template.Template // Nil sends the alert as JSON
+}
+
+// NewWebhook validates cfg and builds the notifier.
+func NewWebhook(cfg WebhookConfig) (*Webhook, error) {
+	if err := validateURL("url", cfg.URL); err != nil {
+		return nil, err
+	}
+	switch cfg.Method {
+	case "":
+		cfg.Method = http.MethodPost
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		return nil, fmt.Errorf("method must be POST, PUT or PATCH")
+	}
+	if cfg.ContentType == "" {
+		cfg.ContentType = "application/json"
+	}
+
+	w := &Webhook{cfg: cfg}
+	if cfg.Template != "" {
+		body, err := parseTemplate(WebhookType, cfg.Template, "")
+		if err != nil {
+			return nil, err
+		}
+		w.body = body
+	}
+	return w, nil
+}
+
+// Notify implements Notifier.
+func (w *Webhook) Notify(ctx context.Context, alert Alert) error {
+	var body []byte
+	if w.body == nil {
+		encoded, err := json.Marshal(alert)
+		if err != nil {
+			return err
+		}
+		body = encoded
+	} else {
+		rendered, err := render(w.body, alert)
+		if err != nil {
+			return err
+		}
+		body = []byte(rendered)
+	}
+	return send(ctx, w.cfg.Method, w.cfg.URL, w.cfg.ContentType, body, w.cfg.Headers)
+}
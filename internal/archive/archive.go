@@ -0,0 +1,340 @@
+// Package archive writes completed traces to object storage for retention
+// beyond what the collector's store keeps. Traces are buffered and written
+// periodically as gzip-compressed files, JSON lines (one trace per line) or
+// an OTLP ExportTraceServiceRequest, each followed by a JSON manifest
+// describing it. A manifest is only written once its data file is complete,
+// so readers list manifests to find archived data.
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/saintparish4/asmbly/internal/plugin/otlpexport"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Archive file formats
+const (
+	FormatJSONL = "jsonl" // One JSON trace per line
+	FormatOTLP  = "otlp"  // One protobuf OTLP ExportTraceServiceRequest
+)
+
+// Defaults for unset Config fields.
+const (
+	DefaultInterval  = 5 * time.Minute
+	DefaultMaxTraces = 10000
+)
+
+// Config is the "archive" section of the collector's config file.
+type Config struct {
+	// Sink is where files go: "s3://bucket/prefix", "gs://bucket/prefix"
+	// or a local directory
+	Sink string `json:"sink"`
+
+	Format    string `json:"format,omitempty"`     // "jsonl" (default) or "otlp"
+	Interval  string `json:"interval,omitempty"`   // How often buffered traces are written (default 5m)
+	MaxTraces int    `json:"max_traces,omitempty"` // Traces per file; a full buffer is written early (default 10000)
+
+	// Object storage settings. Credentials default to the
+	// AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY environment variables;
+	// Cloud Storage needs HMAC keys.
+	Endpoint        string `json:"endpoint,omitempty"` // e.g. "http://minio:9000" (default: the provider's)
+	Region          string `json:"region,omitempty"`   // default us-east-1 (auto for gs://)
+	AccessKeyID     string `json:"access_key_id,omitempty"`
+	SecretAccessKey string `json:"secret_access_key,omitempty"`
+}
+
+// Manifest describes one archive file. It is written next to the file, with
+// the same name and a .manifest.json suffix in place of the file's.
+type Manifest struct {
+	File        string    `json:"file"` // Key of the data file, relative to the sink
+	Format      string    `json:"format"`
+	Compression string    `json:"compression"`
+	Traces      int       `json:"traces"`
+	Spans       int       `json:"spans"`
+	Bytes       int       `json:"bytes"`  // Compressed size
+	SHA256      string    `json:"sha256"` // Of the compressed file
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Stats counts archive activity since start.
+type Stats struct {
+	Files   int64 `json:"files"`
+	Traces  int64 `json:"traces"`
+	Dropped int64 `json:"dropped"` // Traces discarded after failed writes overflowed the buffer
+	Failed  int64 `json:"failed"`  // Failed file writes
+}
+
+// Archiver buffers completed traces and writes them to a sink. It is safe
+// for concurrent use.
+type Archiver struct {
+	sink      Sink
+	format    string
+	interval  time.Duration
+	maxTraces int
+	logger    *slog.Logger
+
+	mu      sync.Mutex
+	pending []*models.Trace
+	writeMu sync.Mutex // Serializes writes so retried traces keep their order
+
+	full     chan struct{} // Signals the flusher that the buffer is full
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	files   atomic.Int64
+	traces  atomic.Int64
+	dropped atomic.Int64
+	failed  atomic.Int64
+}
+
+// New validates config and opens its sink. Call Start to write periodically.
+func New(config Config, logger *slog.Logger) (*Archiver, error) {
+	a := &Archiver{
+		format:    config.Format,
+		interval:  DefaultInterval,
+		maxTraces: config.MaxTraces,
+		logger:    logger.With("component", "archive"),
+		full:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	switch a.format {
+	case "":
+		a.format = FormatJSONL
+	case FormatJSONL, FormatOTLP:
+	default:
+		return nil, fmt.Errorf("format must be %s or %s", FormatJSONL, FormatOTLP)
+	}
+	if config.Interval != "" {
+		var err error
+		if a.interval, err = time.ParseDuration(config.Interval); err != nil || a.interval <= 0 {
+			return nil, fmt.Errorf("invalid interval %q", config.Interval)
+		}
+	}
+	if a.maxTraces <= 0 {
+		a.maxTraces = DefaultMaxTraces
+	}
+
+	sink, err := NewSink(config)
+	if err != nil {
+		return nil, err
+	}
+	a.sink = sink
+	return a, nil
+}
+
+// String describes the archive destination.
+func (a *Archiver) String() string { return a.sink.String() }
+
+// Format returns the archive file format.
+func (a *Archiver) Format() string { return a.format }
+
+// MaxTraces returns the number of traces per file.
+func (a *Archiver) MaxTraces() int { return a.maxTraces }
+
+// Stats returns the archive counters.
+func (a *Archiver) Stats() Stats {
+	return Stats{
+		Files:   a.files.Load(),
+		Traces:  a.traces.Load(),
+		Dropped: a.dropped.Load(),
+		Failed:  a.failed.Load(),
+	}
+}
+
+// Add buffers a completed trace for the next file.
+func (a *Archiver) Add(trace *models.Trace) {
+	a.mu.Lock()
+	a.pending = append(a.pending, trace)
+	full := len(a.pending) >= a.maxTraces
+	a.mu.Unlock()
+
+	if full {
+		select {
+		case a.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Start writes buffered traces every interval, and as soon as a file's
+// worth is buffered, until Stop.
+func (a *Archiver) Start() {
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+
+		ticker := time.NewTicker(a.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.done:
+				return
+			case <-ticker.C:
+			case <-a.full:
+			}
+			a.flushLogged(context.Background())
+		}
+	}()
+}
+
+// Stop ends periodic writes and writes what is still buffered.
+func (a *Archiver) Stop(ctx context.Context) error {
+	a.stopOnce.Do(func() { close(a.done) })
+	a.wg.Wait()
+	_, err := a.Flush(ctx)
+	return err
+}
+
+// Flush writes buffered traces now, a file per MaxTraces, and returns the
+// manifests written. Traces of a failed write stay buffered for the next
+// attempt, up to twice MaxTraces; older ones are dropped beyond that.
+func (a *Archiver) Flush(ctx context.Context) ([]*Manifest, error) {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+
+	var manifests []*Manifest
+	for {
+		a.mu.Lock()
+		n := min(len(a.pending), a.maxTraces)
+		batch := a.pending[:n:n]
+		a.pending = a.pending[n:]
+		a.mu.Unlock()
+		if len(batch) == 0 {
+			return manifests, nil
+		}
+
+		manifest, err := a.write(ctx, batch)
+		if err != nil {
+			a.requeue(batch)
+			return manifests, err
+		}
+		manifests = append(manifests, manifest)
+	}
+}
+
+// flushLogged flushes in the background, logging the outcome.
+func (a *Archiver) flushLogged(ctx context.Context) {
+	manifests, err := a.Flush(ctx)
+	for _, m := range manifests {
+		a.logger.Info("archived traces", "file", m.File, "traces", m.Traces, "bytes", m.Bytes)
+	}
+	if err != nil {
+		a.logger.Error("failed to archive traces", "sink", a.sink.String(), "error", err)
+	}
+}
+
+// requeue puts the traces of a failed write back in front of the buffer.
+func (a *Archiver) requeue(batch []*models.Trace) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending = append(batch, a.pending...)
+	if excess := len(a.pending) - 2*a.maxTraces; excess > 0 {
+		a.pending = a.pending[excess:]
+		a.dropped.Add(int64(excess))
+	}
+}
+
+// Write archives traces as one file, bypassing the buffer.
+func (a *Archiver) Write(ctx context.Context, traces []*models.Trace) (*Manifest, error) {
+	if len(traces) == 0 {
+		return nil, errors.New("no traces to archive")
+	}
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+	return a.write(ctx, traces)
+}
+
+// write encodes traces, puts the file and then its manifest.
+func (a *Archiver) write(ctx context.Context, traces []*models.Trace) (*Manifest, error) {
+	body, err := encode(a.format, traces)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(body)
+
+	now := time.Now().UTC()
+	name := fmt.Sprintf("%s/%s-%s", now.Format("2006/01/02"), now.Format("20060102T150405.000Z"), models.GenerateSpanID())
+	manifest := &Manifest{
+		File:        name + "." + a.format + ".gz",
+		Format:      a.format,
+		Compression: "gzip",
+		Traces:      len(traces),
+		Bytes:       len(body),
+		SHA256:      hex.EncodeToString(sum[:]),
+		CreatedAt:   now,
+	}
+	for i, trace := range traces {
+		manifest.Spans += len(trace.Spans)
+		end := trace.StartTime.Add(trace.Duration)
+		if i == 0 || trace.StartTime.Before(manifest.StartTime) {
+			manifest.StartTime = trace.StartTime
+		}
+		if end.After(manifest.EndTime) {
+			manifest.EndTime = end
+		}
+	}
+
+	if err := a.sink.Put(ctx, manifest.File, body, "application/gzip"); err != nil {
+		a.failed.Add(1)
+		return nil, err
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	if err := a.sink.Put(ctx, name+".manifest.json", data, "application/json"); err != nil {
+		a.failed.Add(1)
+		return nil, err
+	}
+	a.files.Add(1)
+	a.traces.Add(int64(len(traces)))
+	return manifest, nil
+}
+
+// encode renders traces in format, gzip-compressed.
+func encode(format string, traces []*models.Trace) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+
+	switch format {
+	case FormatOTLP:
+		var spans []*models.Span
+		for _, trace := range traces {
+			for i := range trace.Spans {
+				spans = append(spans, &trace.Spans[i])
+			}
+		}
+		data, err := proto.Marshal(otlpexport.ToOTLP(spans))
+		if err != nil {
+			return nil, err
+		}
+		zw.Write(data)
+	default:
+		enc := json.NewEncoder(zw)
+		for _, trace := range traces {
+			if err := enc.Encode(trace); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
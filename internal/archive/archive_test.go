@@ -0,0 +1,201 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func testTrace(start time.Time) *models.Trace {
+	traceID := models.GenerateTraceID()
+	return &models.Trace{
+		TraceID:   traceID,
+		StartTime: start,
+		Duration:  time.Second,
+		Spans: []models.Span{{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /users",
+			StartTime:     start,
+			Duration:      time.Second,
+			Status:        "ok",
+		}},
+	}
+}
+
+// readFile decompresses an archived file after checking it against its
+// manifest.
+func readFile(t *testing.T, dir string, manifest *Manifest) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(manifest.File)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(data)
+	if manifest.Bytes != len(data) || manifest.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("manifest size/sha256 do not match %s", manifest.File)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return body
+}
+
+func TestArchiver_FlushWritesJSONLinesAndManifest(t *testing.T) {
+	dir := t.TempDir()
+	a, err := New(Config{Sink: dir, MaxTraces: 2}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 3; i++ {
+		a.Add(testTrace(start.Add(time.Duration(i) * time.Minute)))
+	}
+
+	manifests, err := a.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 2 || manifests[0].Traces != 2 || manifests[1].Traces != 1 {
+		t.Fatalf("manifests = %+v, want files of 2 and 1 traces", manifests)
+	}
+	first := manifests[0]
+	if !first.StartTime.Equal(start) || !first.EndTime.Equal(start.Add(time.Minute+time.Second)) || first.Spans != 2 {
+		t.Errorf("manifest = %+v", first)
+	}
+	if !strings.HasSuffix(first.File, ".jsonl.gz") {
+		t.Errorf("file = %q", first.File)
+	}
+
+	var lines int
+	scanner := bufio.NewScanner(bytes.NewReader(readFile(t, dir, first)))
+	for scanner.Scan() {
+		var trace models.Trace
+		if err := json.Unmarshal(scanner.Bytes(), &trace); err != nil || len(trace.Spans) != 1 {
+			t.Fatalf("line %d: %v", lines, err)
+		}
+		lines++
+	}
+	if lines != 2 {
+		t.Errorf("lines = %d, want 2", lines)
+	}
+
+	// The manifest sits next to the file
+	data, err := os.ReadFile(filepath.Join(dir, strings.TrimSuffix(first.File, ".jsonl.gz")+".manifest.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored Manifest
+	if err := json.Unmarshal(data, &stored); err != nil || stored.SHA256 != first.SHA256 {
+		t.Errorf("stored manifest = %+v (%v)", stored, err)
+	}
+
+	if stats := a.Stats(); stats.Files != 2 || stats.Traces != 3 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestArchiver_OTLPFormat(t *testing.T) {
+	dir := t.TempDir()
+	a, err := New(Config{Sink: "file://" + dir, Format: FormatOTLP}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := a.Write(context.Background(), []*models.Trace{testTrace(time.Now()), testTrace(time.Now())})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req collectortrace.ExportTraceServiceRequest
+	if err := proto.Unmarshal(readFile(t, dir, manifest), &req); err != nil {
+		t.Fatal(err)
+	}
+	spans := 0
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			spans += len(ss.Spans)
+		}
+	}
+	if spans != 2 || manifest.Spans != 2 {
+		t.Errorf("spans = %d, manifest spans = %d, want 2", spans, manifest.Spans)
+	}
+}
+
+type failingSink struct{ fail bool }
+
+func (s *failingSink) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	if s.fail {
+		return errors.New("unavailable")
+	}
+	return nil
+}
+
+func (s *failingSink) String() string { return "failing" }
+
+func TestArchiver_FailedWritesAreRetried(t *testing.T) {
+	a, err := New(Config{Sink: t.TempDir(), MaxTraces: 2}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &failingSink{fail: true}
+	a.sink = sink
+
+	for i := 0; i < 5; i++ {
+		a.Add(testTrace(time.Now()))
+	}
+	if _, err := a.Flush(context.Background()); err == nil {
+		t.Fatal("expected an error from the failing sink")
+	}
+	// Only twice MaxTraces stay buffered
+	if stats := a.Stats(); stats.Failed != 1 || stats.Dropped != 1 {
+		t.Errorf("stats = %+v, want 1 failed write and 1 dropped trace", stats)
+	}
+
+	sink.fail = false
+	manifests, err := a.Flush(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifests) != 2 || a.Stats().Traces != 4 {
+		t.Errorf("retried %d files, stats = %+v", len(manifests), a.Stats())
+	}
+}
+
+func TestNew_RejectsInvalidConfig(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	for _, config := range []Config{
+		{},
+		{Sink: t.TempDir(), Format: "csv"},
+		{Sink: t.TempDir(), Interval: "soon"},
+		{Sink: "s3://"},
+		{Sink: "s3://bucket/prefix"}, // No credentials
+	} {
+		if _, err := New(config, slog.Default()); err == nil {
+			t.Errorf("New(%+v) succeeded", config)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Endpoints used unless Config.Endpoint is set
+const (
+	gcsEndpoint = "https://storage.googleapis.com"
+	s3Endpoint  = "https://s3.%s.amazonaws.com"
+)
+
+// s3Sink puts objects with the S3 REST API, signed with AWS Signature
+// Version 4. Cloud Storage's XML API and S3-compatible stores such as MinIO
+// accept the same requests, so one client covers them all.
+type s3Sink struct {
+	endpoint  string // Scheme and host, without a trailing slash
+	bucket    string
+	prefix    string // Key prefix, empty or ending in "/"
+	region    string
+	accessKey string
+	secretKey string
+	client    *http.Client
+	scheme    string // "s3" or "gs", for String
+}
+
+func newS3Sink(config Config) (*s3Sink, error) {
+	scheme, rest, _ := strings.Cut(config.Sink, "://")
+	bucket, prefix, _ := strings.Cut(rest, "/")
+	if bucket == "" {
+		return nil, fmt.Errorf("sink %q has no bucket", config.Sink)
+	}
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+
+	s := &s3Sink{
+		endpoint:  strings.TrimSuffix(config.Endpoint, "/"),
+		bucket:    bucket,
+		prefix:    prefix,
+		region:    config.Region,
+		accessKey: config.AccessKeyID,
+		secretKey: config.SecretAccessKey,
+		client:    &http.Client{Timeout: time.Minute},
+		scheme:    scheme,
+	}
+	if s.accessKey == "" && s.secretKey == "" {
+		s.accessKey, s.secretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if s.accessKey == "" || s.secretKey == "" {
+		return nil, fmt.Errorf("%s sink needs access_key_id and secret_access_key (or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY)", scheme)
+	}
+	if s.region == "" {
+		s.region = "us-east-1"
+		if scheme == "gs" {
+			s.region = "auto"
+		}
+	}
+	if s.endpoint == "" {
+		s.endpoint = fmt.Sprintf(s3Endpoint, s.region)
+		if scheme == "gs" {
+			s.endpoint = gcsEndpoint
+		}
+	}
+	return s, nil
+}
+
+// Put uploads one object with a path-style PUT.
+func (s *s3Sink) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut,
+		s.endpoint+"/"+s.bucket+"/"+uriEncode(s.prefix+key, false), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	signV4(req, body, s.region, s.accessKey, s.secretKey, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("put %s: status %d: %s", key, resp.StatusCode, bytes.TrimSpace(detail))
+	}
+	return nil
+}
+
+func (s *s3Sink) String() string {
+	return s.scheme + "://" + s.bucket + "/" + s.prefix
+}
+
+// signV4 adds the x-amz-date, x-amz-content-sha256 and Authorization
+// headers of AWS Signature Version 4 for the S3 service, signing the host
+// and every header already set on req.
+func signV4(req *http.Request, body []byte, region, accessKey, secretKey string, now time.Time) {
+	payloadHash := sha256.Sum256(body)
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	// Canonical headers: lowercase names, sorted, values trimmed
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery sorts and encodes query parameters as SigV4 requires.
+func canonicalQuery(values url.Values) string {
+	var pairs []string
+	for key, vals := range values {
+		for _, v := range vals {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode percent-encodes everything but unreserved characters (and "/"
+// unless encodeSlash), the encoding SigV4 signs.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,75 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sink stores archive objects. Put must be safe to retry: writing the same
+// key twice leaves one complete object.
+type Sink interface {
+	// Put stores body under key, a slash-separated path
+	Put(ctx context.Context, key string, body []byte, contentType string) error
+
+	// String describes the destination for logs, without credentials
+	String() string
+}
+
+// NewSink returns the sink for a destination: "s3://bucket/prefix",
+// "gs://bucket/prefix" (Cloud Storage through its S3-compatible API, with
+// HMAC keys) or a local directory, optionally as "file:///path".
+func NewSink(config Config) (Sink, error) {
+	switch {
+	case strings.HasPrefix(config.Sink, "s3://"), strings.HasPrefix(config.Sink, "gs://"):
+		return newS3Sink(config)
+	case config.Sink == "":
+		return nil, fmt.Errorf("sink is required")
+	default:
+		return newDirSink(strings.TrimPrefix(config.Sink, "file://"))
+	}
+}
+
+// dirSink writes objects as files under a local directory, for testing or
+// for a directory synced to object storage by other means.
+type dirSink struct {
+	dir string
+}
+
+func newDirSink(dir string) (*dirSink, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create archive directory: %w", err)
+	}
+	return &dirSink{dir: dir}, nil
+}
+
+// Put writes the file through a temporary file and a rename, so readers
+// never see a partial object.
+func (s *dirSink) Put(ctx context.Context, key string, body []byte, contentType string) error {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s *dirSink) String() string { return s.dir }
@@ -0,0 +1,321 @@
+package collector
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// API v2: /api/v2/traces returns enveloped responses with cursor pagination,
+// typed attributes and trace summaries. Its handlers share parsing, storage
+// access and metrics with v1 and differ only in the shape of requests and
+// responses, so v1 stays stable while v2 can change independently. Ingestion
+// (POST /api/v2/spans, Zipkin) is unrelated to this versioning.
+
+// apiVersion selects the request and response shapes of a shared handler.
+type apiVersion int
+
+const (
+	apiV1 apiVersion = 1
+	apiV2 apiVersion = 2
+)
+
+// prefix returns the version's URL prefix, e.g. "/api/v2".
+func (v apiVersion) prefix() string {
+	return "/api/v" + strconv.Itoa(int(v))
+}
+
+// HandleGetTraceV2 handles GET /api/v2/traces/:id - a trace with typed
+// attributes and its summary.
+func (c *Collector) HandleGetTraceV2(w http.ResponseWriter, r *http.Request) {
+	c.handleGetTrace(w, r, apiV2)
+}
+
+// HandleFindTracesV2 handles GET /api/v2/traces - trace summaries matching
+// the v1 filters, paged by cursor.
+func (c *Collector) HandleFindTracesV2(w http.ResponseWriter, r *http.Request) {
+	c.handleFindTraces(w, r, apiV2)
+}
+
+// responseMetaV2 is included in every v2 response.
+type responseMetaV2 struct {
+	APIVersion int `json:"api_version"`
+}
+
+// pageV2 describes the position of a v2 list response.
+type pageV2 struct {
+	Limit      int    `json:"limit"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"` // Pass as cursor for the next page
+}
+
+type findTracesResponseV2 struct {
+	Data []traceSummaryV2 `json:"data"`
+	Page pageV2           `json:"page"`
+	Meta responseMetaV2   `json:"meta"`
+}
+
+// traceSummaryV2 describes a trace without its spans.
+type traceSummaryV2 struct {
+	TraceID       string    `json:"trace_id"`
+	RootService   string    `json:"root_service,omitempty"`
+	RootOperation string    `json:"root_operation,omitempty"`
+	StartTime     time.Time `json:"start_time"`
+	DurationNs    int64     `json:"duration_ns"`
+	SpanCount     int       `json:"span_count"`
+	ErrorCount    int       `json:"error_count"`
+	Services      []string  `json:"services"`
+	InProgress    bool      `json:"in_progress,omitempty"`
+	TotalCost     float64   `json:"total_cost,omitempty"`
+	Currency      string    `json:"currency,omitempty"`
+	Truncated     bool      `json:"truncated,omitempty"`
+	Regions       []string  `json:"regions,omitempty"`
+}
+
+// traceV2 is a trace's summary and trace-level details. Its spans, as
+// spanV2, are streamed after them (see writeTrace).
+type traceV2 struct {
+	traceSummaryV2
+	Deployments   map[string]string  `json:"deployments,omitempty"`
+	CostBreakdown map[string]float64 `json:"cost_breakdown,omitempty"`
+	ExpiresAt     *time.Time         `json:"expires_at,omitempty"`
+	Truncation    *models.Truncation `json:"truncation,omitempty"`
+}
+
+// spanV2 is a span with typed attributes in place of string tags.
+type spanV2 struct {
+	TraceID       string                 `json:"trace_id"`
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	ServiceName   string                 `json:"service_name"`
+	OperationName string                 `json:"operation_name"`
+	StartTime     time.Time              `json:"start_time"`
+	DurationNs    int64                  `json:"duration_ns"`
+	SpanKind      string                 `json:"span_kind,omitempty"`
+	Status        string                 `json:"status"`
+	StatusMessage string                 `json:"status_message,omitempty"`
+	InProgress    bool                   `json:"in_progress,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"`
+	Events        []eventV2              `json:"events,omitempty"`
+	DeploymentID  string                 `json:"deployment_id,omitempty"`
+	GitSHA        string                 `json:"git_sha,omitempty"`
+	Environment   string                 `json:"environment,omitempty"`
+	Cost          float64                `json:"cost,omitempty"`
+	HasProfile    bool                   `json:"has_profile,omitempty"`
+	ProfileID     string                 `json:"profile_id,omitempty"`
+}
+
+type eventV2 struct {
+	Name       string                 `json:"name"`
+	Timestamp  time.Time              `json:"timestamp"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// newTraceSummaryV2 summarizes a trace. The root is the earliest span
+// without a parent in the trace.
+func newTraceSummaryV2(trace *models.Trace) traceSummaryV2 {
+	summary := traceSummaryV2{
+		TraceID:    trace.TraceID,
+		StartTime:  trace.StartTime,
+		DurationNs: int64(trace.Duration),
+		SpanCount:  len(trace.Spans),
+		Services:   trace.Services,
+		InProgress: trace.InProgress,
+		TotalCost:  trace.TotalCost,
+		Currency:   trace.Currency,
+		Truncated:  trace.Truncation != nil,
+		Regions:    trace.Regions,
+	}
+
+	ids := make(map[string]bool, len(trace.Spans))
+	for i := range trace.Spans {
+		ids[trace.Spans[i].SpanID] = true
+	}
+	var root *models.Span
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		if span.IsError() {
+			summary.ErrorCount++
+		}
+		if span.ParentSpanID != "" && ids[span.ParentSpanID] {
+			continue
+		}
+		if root == nil || span.StartTime.Before(root.StartTime) {
+			root = span
+		}
+	}
+	if root != nil {
+		summary.RootService = root.ServiceName
+		summary.RootOperation = root.OperationName
+	}
+	return summary
+}
+
+func newTraceV2(trace *models.Trace) traceV2 {
+	return traceV2{
+		traceSummaryV2: newTraceSummaryV2(trace),
+		Deployments:    trace.Deployments,
+		CostBreakdown:  trace.CostBreakdown,
+		ExpiresAt:      trace.ExpiresAt,
+		Truncation:     trace.Truncation,
+	}
+}
+
+func newSpanV2(span *models.Span) spanV2 {
+	result := spanV2{
+		TraceID:       span.TraceID,
+		SpanID:        span.SpanID,
+		ParentSpanID:  span.ParentSpanID,
+		ServiceName:   span.ServiceName,
+		OperationName: span.OperationName,
+		StartTime:     span.StartTime,
+		DurationNs:    int64(span.Duration),
+		SpanKind:      span.SpanKind,
+		Status:        span.Status,
+		StatusMessage: span.StatusMessage,
+		InProgress:    span.InProgress,
+		Attributes:    typedAttributes(span.Tags),
+		DeploymentID:  span.DeploymentID,
+		GitSHA:        span.GitSHA,
+		Environment:   span.Environment,
+		Cost:          span.Cost,
+		HasProfile:    span.HasProfile,
+		ProfileID:     span.ProfileID,
+	}
+	for _, event := range span.Events {
+		result.Events = append(result.Events, eventV2{
+			Name:       event.Name,
+			Timestamp:  event.Timestamp,
+			Attributes: typedAttributes(event.Attributes),
+		})
+	}
+	return result
+}
+
+// typedAttributes converts string tags to typed values: "true"/"false"
+// become booleans and numbers in canonical form become numbers, so "200"
+// is 200 but "007" and "1e3" stay strings.
+func typedAttributes(tags map[string]string) map[string]interface{} {
+	if len(tags) == 0 {
+		return nil
+	}
+	attrs := make(map[string]interface{}, len(tags))
+	for key, value := range tags {
+		attrs[key] = typedValue(value)
+	}
+	return attrs
+}
+
+func typedValue(s string) interface{} {
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && strconv.FormatInt(n, 10) == s {
+		return n
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsInf(f, 0) && !math.IsNaN(f) &&
+		strconv.FormatFloat(f, 'f', -1, 64) == s {
+		return f
+	}
+	return s
+}
+
+// traceCursor is the decoded form of a v2 cursor: the position of the next
+// page and a fingerprint of the filters it was issued for.
+type traceCursor struct {
+	Offset int    `json:"o"`
+	Filter string `json:"f"`
+}
+
+// cursorIgnoredParams do not affect which traces a query matches, so a
+// cursor stays valid when they change.
+var cursorIgnoredParams = []string{"cursor", "limit"}
+
+// filterFingerprint hashes the parameters that select and order traces.
+func filterFingerprint(params url.Values) string {
+	normalized := make(url.Values, len(params))
+	for k, v := range params {
+		normalized[k] = v
+	}
+	for _, k := range cursorIgnoredParams {
+		normalized.Del(k)
+	}
+	h := fnv.New64a()
+	h.Write([]byte(normalized.Encode()))
+	return strconv.FormatUint(h.Sum64(), 36)
+}
+
+func encodeCursor(cursor traceCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// applyCursor positions query at the v2 cursor parameter. v2 pages only by
+// cursor, so offset is rejected, as is a cursor issued for other filters.
+func applyCursor(params url.Values, query *storage.Query) []QueryParamError {
+	var errs []QueryParamError
+	for _, param := range []string{"offset", "fields", "strict"} {
+		if params.Has(param) {
+			errs = append(errs, QueryParamError{Param: param, Value: params.Get(param), Reason: "not supported in API v2"})
+		}
+	}
+	query.Offset = 0
+
+	value := params.Get("cursor")
+	if value == "" {
+		return errs
+	}
+	var cursor traceCursor
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err == nil {
+		err = json.Unmarshal(data, &cursor)
+	}
+	switch {
+	case err != nil || cursor.Offset < 0:
+		errs = append(errs, QueryParamError{Param: "cursor", Value: value, Reason: "malformed cursor"})
+	case cursor.Filter != filterFingerprint(params):
+		errs = append(errs, QueryParamError{Param: "cursor", Value: value, Reason: "cursor was issued for different filters"})
+	default:
+		query.Offset = cursor.Offset
+	}
+	return errs
+}
+
+// writeFindTracesV2 answers a v2 search with summaries and, when more
+// traces match, a cursor (also sent as a Link header) for the next page.
+func writeFindTracesV2(w http.ResponseWriter, r *http.Request, traces []*models.Trace, query *storage.Query, limit int, hasNext bool) {
+	response := findTracesResponseV2{
+		Data: make([]traceSummaryV2, len(traces)),
+		Page: pageV2{Limit: limit, HasMore: hasNext},
+		Meta: responseMetaV2{APIVersion: int(apiV2)},
+	}
+	for i, trace := range traces {
+		response.Data[i] = newTraceSummaryV2(trace)
+	}
+
+	if hasNext {
+		params := r.URL.Query()
+		response.Page.NextCursor = encodeCursor(traceCursor{
+			Offset: query.Offset + limit,
+			Filter: filterFingerprint(params),
+		})
+		params.Set("cursor", response.Page.NextCursor)
+		next := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
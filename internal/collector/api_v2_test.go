@@ -0,0 +1,149 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHandleFindTracesV2_CursorPagination(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /users",
+			StartTime:     base.Add(time.Duration(i) * time.Minute),
+			Duration:      50 * time.Millisecond,
+			Status:        "ok",
+		})
+	}
+
+	get := func(target string) (*httptest.ResponseRecorder, findTracesResponseV2) {
+		rec := httptest.NewRecorder()
+		col.HandleFindTracesV2(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var resp findTracesResponseV2
+		json.NewDecoder(rec.Body).Decode(&resp)
+		return rec, resp
+	}
+
+	seen := make(map[string]bool)
+	target := "/api/v2/traces?service=api&limit=2"
+	for pages := 0; ; pages++ {
+		rec, resp := get(target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("page %d: status %d", pages, rec.Code)
+		}
+		if resp.Meta.APIVersion != 2 || resp.Page.Limit != 2 {
+			t.Errorf("meta %+v, page %+v", resp.Meta, resp.Page)
+		}
+		for _, summary := range resp.Data {
+			if seen[summary.TraceID] {
+				t.Errorf("trace %s returned twice", summary.TraceID)
+			}
+			seen[summary.TraceID] = true
+		}
+		if !resp.Page.HasMore {
+			if pages != 2 || rec.Header().Get("Link") != "" {
+				t.Errorf("last page after %d pages, Link %q", pages+1, rec.Header().Get("Link"))
+			}
+			break
+		}
+		target = "/api/v2/traces?service=api&limit=2&cursor=" + resp.Page.NextCursor
+	}
+	if len(seen) != 5 {
+		t.Errorf("paged through %d traces, want 5", len(seen))
+	}
+
+	// A cursor is tied to its filters, and v1 offsets are not accepted
+	_, first := get("/api/v2/traces?service=api&limit=2")
+	if rec, _ := get("/api/v2/traces?service=web&limit=2&cursor=" + first.Page.NextCursor); rec.Code != http.StatusBadRequest {
+		t.Errorf("cursor with other filters: status %d, want 400", rec.Code)
+	}
+	for _, target := range []string{"/api/v2/traces?cursor=bm90LWpzb24", "/api/v2/traces?offset=2", "/api/v2/traces?limit=x&strict=false"} {
+		if rec, _ := get(target); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", target, rec.Code)
+		}
+	}
+}
+
+func TestHandleGetTraceV2_SummaryAndTypedAttributes(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+
+	traceID := models.GenerateTraceID()
+	rootID := models.GenerateSpanID()
+	start := time.Now().Add(-time.Minute)
+	store.WriteSpan(ctx, &models.Span{
+		TraceID: traceID, SpanID: rootID, ServiceName: "frontend", OperationName: "GET /checkout",
+		StartTime: start, Duration: 100 * time.Millisecond, Status: "ok",
+		Tags: map[string]string{"http.status_code": "200", "cache.hit": "true", "ratio": "0.25", "zip": "007", "peer": "db"},
+	})
+	store.WriteSpan(ctx, &models.Span{
+		TraceID: traceID, SpanID: models.GenerateSpanID(), ParentSpanID: rootID, ServiceName: "payments",
+		OperationName: "charge", StartTime: start.Add(time.Millisecond), Duration: 50 * time.Millisecond, Status: "error",
+	})
+
+	rec := httptest.NewRecorder()
+	col.HandleGetTraceV2(rec, httptest.NewRequest(http.MethodGet, "/api/v2/traces/"+traceID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var resp struct {
+		Data struct {
+			traceSummaryV2
+			Spans []struct {
+				SpanID     string                 `json:"span_id"`
+				DurationNs int64                  `json:"duration_ns"`
+				Attributes map[string]interface{} `json:"attributes"`
+			} `json:"spans"`
+		} `json:"data"`
+	}
+	json.NewDecoder(rec.Body).Decode(&resp)
+
+	summary := resp.Data.traceSummaryV2
+	if summary.RootService != "frontend" || summary.RootOperation != "GET /checkout" || summary.SpanCount != 2 || summary.ErrorCount != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+	for _, span := range resp.Data.Spans {
+		if span.SpanID != rootID {
+			continue
+		}
+		attrs := span.Attributes
+		if attrs["http.status_code"] != float64(200) || attrs["cache.hit"] != true || attrs["ratio"] != 0.25 ||
+			attrs["zip"] != "007" || attrs["peer"] != "db" {
+			t.Errorf("attributes = %v", attrs)
+		}
+		if span.DurationNs != int64(100*time.Millisecond) {
+			t.Errorf("duration_ns = %d", span.DurationNs)
+		}
+	}
+
+	// The v1 endpoint keeps the original trace shape
+	rec = httptest.NewRecorder()
+	col.HandleGetTrace(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces/"+traceID, nil))
+	var v1 models.Trace
+	if err := json.NewDecoder(rec.Body).Decode(&v1); err != nil || v1.TraceID != traceID || len(v1.Spans) != 2 {
+		t.Errorf("v1 trace = %+v, err %v", v1, err)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleGetTraceV2(rec, httptest.NewRequest(http.MethodGet, "/api/v2/traces/"+models.GenerateTraceID(), nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown trace: status %d, want 404", rec.Code)
+	}
+}
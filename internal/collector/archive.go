@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/archive"
+	"github.com/saintparish4/asmbly/internal/events"
+	"github.com/saintparish4/asmbly/internal/storage"
+)
+
+// archiveQueueSize bounds completed traces waiting to be buffered by the
+// archiver; the archiver itself buffers a file's worth or more.
+const archiveQueueSize = 10000
+
+// startArchive feeds completed traces to the archiver.
+func (c *Collector) startArchive() {
+	if c.archive == nil {
+		return
+	}
+	sub := c.events.Subscribe(archiveQueueSize, events.TraceCompleted)
+	c.archiveWg.Add(1)
+	go func() {
+		defer c.archiveWg.Done()
+		for evt := range sub.C() {
+			c.archive.Add(evt.Trace)
+		}
+	}()
+	c.archive.Start()
+	c.logger.Info("archiving completed traces", "sink", c.archive.String(), "format", c.archive.Format())
+}
+
+// stopArchive writes the traces still buffered. It must be called after the
+// event bus is closed.
+func (c *Collector) stopArchive(ctx context.Context) error {
+	if c.archive == nil {
+		return nil
+	}
+	c.archiveWg.Wait()
+	return c.archive.Stop(ctx)
+}
+
+// HandleExport handles POST /api/v1/export. Without parameters it writes the
+// completed traces buffered for the archive now. With start and/or end it
+// archives the stored traces in that range instead (optionally only those
+// of service), a file per max_traces, e.g. to backfill before enabling the
+// archive.
+func (c *Collector) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.archive == nil {
+		http.Error(w, "archive export not configured", http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	var query *storage.Query
+	if params.Has("start") || params.Has("end") {
+		notInProgress := false
+		query = &storage.Query{
+			Service:    params.Get("service"),
+			InProgress: &notInProgress,
+			SortBy:     storage.SortByStartTime,
+			SortOrder:  storage.SortAsc,
+		}
+		var errs []QueryParamError
+		now := time.Now()
+		for _, p := range []struct {
+			name string
+			dst  *time.Time
+		}{{"start", &query.StartTime}, {"end", &query.EndTime}} {
+			value := params.Get(p.name)
+			if value == "" {
+				continue
+			}
+			t, err := parseTimeParam(value, now, time.UTC)
+			if err != nil {
+				errs = append(errs, QueryParamError{
+					Param:  p.name,
+					Value:  value,
+					Reason: "must be RFC3339, epoch millis or relative (e.g. -24h)",
+				})
+				continue
+			}
+			*p.dst = t
+		}
+		if len(errs) > 0 {
+			writeQueryErrors(w, errs)
+			return
+		}
+	}
+
+	var manifests []*archive.Manifest
+	var err error
+	if query == nil {
+		manifests, err = c.archive.Flush(r.Context())
+	} else {
+		manifests, err = c.exportRange(r.Context(), query)
+	}
+
+	traces := 0
+	for _, m := range manifests {
+		traces += m.Traces
+	}
+	response := map[string]interface{}{
+		"files":  manifests,
+		"traces": traces,
+	}
+	code := http.StatusOK
+	if err != nil {
+		c.logger.Error("archive export failed", "error", err)
+		response["error"] = err.Error()
+		code = http.StatusBadGateway
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
+
+// exportRange archives the traces matching query, a page per file.
+func (c *Collector) exportRange(ctx context.Context, query *storage.Query) ([]*archive.Manifest, error) {
+	query.Limit = c.archive.MaxTraces()
+	var manifests []*archive.Manifest
+	for {
+		traces, err := c.store.FindTraces(ctx, query)
+		if err != nil || len(traces) == 0 {
+			return manifests, err
+		}
+		manifest, err := c.archive.Write(ctx, traces)
+		if err != nil {
+			return manifests, err
+		}
+		manifests = append(manifests, manifest)
+		if len(traces) < query.Limit {
+			return manifests, nil
+		}
+		query.Offset += len(traces)
+	}
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/archive"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHandleExport_NotConfigured(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	rec := httptest.NewRecorder()
+	col.HandleExport(rec, httptest.NewRequest(http.MethodPost, "/api/v1/export", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestHandleExport_ArchivesStoredRange(t *testing.T) {
+	arch, err := archive.New(archive.Config{Sink: t.TempDir(), MaxTraces: 2}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, Archive: arch}, slog.Default())
+
+	ctx := context.Background()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i < 5; i++ {
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /users",
+			StartTime:     start.Add(time.Duration(i) * time.Minute),
+			Duration:      time.Millisecond,
+			Status:        "ok",
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleExport(rec, httptest.NewRequest(http.MethodPost, "/api/v1/export?start=-2h&end=now", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var response struct {
+		Files  []archive.Manifest `json:"files"`
+		Traces int                `json:"traces"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Traces != 5 || len(response.Files) != 3 {
+		t.Errorf("exported %d traces in %d files, want 5 in 3", response.Traces, len(response.Files))
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleExport(rec, httptest.NewRequest(http.MethodPost, "/api/v1/export?start=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid start: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestCollector_ArchivesCompletedTraces(t *testing.T) {
+	arch, err := archive.New(archive.Config{Sink: t.TempDir()}, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10, Archive: arch}, slog.Default())
+	col.Start(context.Background())
+
+	span := queueFullTestSpan()
+	if err := col.SubmitSpan(span); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stop publishes the pending trace's completion and writes the archive
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := col.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stats := arch.Stats(); stats.Files != 1 || stats.Traces != 1 {
+		t.Errorf("archive stats = %+v, want 1 trace in 1 file", stats)
+	}
+}
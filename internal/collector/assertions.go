@@ -0,0 +1,394 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/pattern"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// maxAssertionWait caps how long an assertion request waits for its trace.
+// It stays under the HTTP write timeout so the report can still be sent.
+const maxAssertionWait = 8 * time.Second
+
+// assertionPollInterval is how often a waiting request checks again.
+const assertionPollInterval = 250 * time.Millisecond
+
+// defaultAssertionLookback is how far back Match looks for traces.
+const defaultAssertionLookback = 5 * time.Minute
+
+// AssertionRequest asks whether traces have the expected spans, for
+// integration tests that check what a request did and not only what it
+// returned. Assertions apply to the trace TraceID, or to each trace Match
+// selects.
+type AssertionRequest struct {
+	TraceID string      `json:"trace_id,omitempty"`
+	Match   *TraceMatch `json:"match,omitempty"`
+
+	// Wait retries until every assertion passes or Wait runs out, for spans
+	// still on their way (at most 8s)
+	Wait string `json:"wait,omitempty"`
+
+	Assertions []Assertion `json:"assertions"`
+}
+
+// TraceMatch selects the newest traces of a service, typically by a tag the
+// test set, e.g. {"test.run_id": "42"}.
+type TraceMatch struct {
+	Service  string            `json:"service,omitempty"`
+	Tags     map[string]string `json:"tags,omitempty"`
+	Lookback string            `json:"lookback,omitempty"` // Default 5m
+	Limit    int               `json:"limit,omitempty"`    // Traces checked, default 1
+}
+
+// Assertion checks the spans Select matches against Expect.
+type Assertion struct {
+	Name   string       `json:"name"`
+	Select SpanSelector `json:"select"`
+	Expect Expectation  `json:"expect"`
+}
+
+// SpanSelector matches spans; empty fields match anything. Operation may
+// contain '*' wildcards.
+type SpanSelector struct {
+	Service   string            `json:"service,omitempty"`
+	Operation string            `json:"operation,omitempty"`
+	Kind      string            `json:"kind,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Tags      map[string]string `json:"tags,omitempty"` // An empty value matches any value
+
+	// Within matches only spans beneath a span it matches
+	Within *SpanSelector `json:"within,omitempty"`
+}
+
+// Expectation is what the selected spans must satisfy. Without MinCount or
+// MaxCount, at least one span must match.
+type Expectation struct {
+	MinCount    *int              `json:"min_count,omitempty"`
+	MaxCount    *int              `json:"max_count,omitempty"`
+	MinDuration string            `json:"min_duration,omitempty"` // Each span
+	MaxDuration string            `json:"max_duration,omitempty"` // Each span
+	Status      string            `json:"status,omitempty"`       // Each span, "ok" or "error"
+	Tags        map[string]string `json:"tags,omitempty"`         // Each span; an empty value only requires the key
+}
+
+// AssertionReport is the outcome of an assertion request.
+type AssertionReport struct {
+	Passed   bool              `json:"passed"`
+	TraceIDs []string          `json:"trace_ids"`
+	Error    string            `json:"error,omitempty"` // Why no trace could be checked
+	Results  []AssertionResult `json:"results"`
+	Attempts int               `json:"attempts"`
+}
+
+// AssertionResult is the outcome of one assertion on one trace.
+type AssertionResult struct {
+	Name     string   `json:"name"`
+	TraceID  string   `json:"trace_id"`
+	Passed   bool     `json:"passed"`
+	Matched  int      `json:"matched"`
+	Failures []string `json:"failures,omitempty"`
+}
+
+// compiledAssertion is a validated Assertion.
+type compiledAssertion struct {
+	name        string
+	selector    *compiledSelector
+	minCount    int
+	maxCount    int // -1 for no limit
+	minDuration time.Duration
+	maxDuration time.Duration
+	status      string
+	tags        map[string]string
+}
+
+type compiledSelector struct {
+	SpanSelector
+	operation *regexp.Regexp
+	within    *compiledSelector
+}
+
+func compileSelector(s SpanSelector) (*compiledSelector, error) {
+	if s.Status != "" && s.Status != "ok" && s.Status != "error" {
+		return nil, fmt.Errorf("status must be ok or error, got %q", s.Status)
+	}
+	c := &compiledSelector{SpanSelector: s, operation: pattern.Compile(s.Operation)}
+	if s.Within != nil {
+		within, err := compileSelector(*s.Within)
+		if err != nil {
+			return nil, fmt.Errorf("within: %w", err)
+		}
+		c.within = within
+	}
+	return c, nil
+}
+
+func compileAssertion(i int, a Assertion) (*compiledAssertion, error) {
+	c := &compiledAssertion{name: a.Name, minCount: 1, maxCount: -1, status: a.Expect.Status, tags: a.Expect.Tags}
+	if c.name == "" {
+		c.name = fmt.Sprintf("assertion %d", i+1)
+	}
+	selector, err := compileSelector(a.Select)
+	if err != nil {
+		return nil, fmt.Errorf("%s: select: %w", c.name, err)
+	}
+	c.selector = selector
+
+	if a.Expect.MaxCount != nil {
+		c.minCount, c.maxCount = 0, *a.Expect.MaxCount
+	}
+	if a.Expect.MinCount != nil {
+		c.minCount = *a.Expect.MinCount
+	}
+	if c.minCount < 0 || (c.maxCount >= 0 && c.maxCount < c.minCount) {
+		return nil, fmt.Errorf("%s: invalid count range", c.name)
+	}
+	for _, d := range []struct {
+		field, value string
+		into         *time.Duration
+	}{
+		{"min_duration", a.Expect.MinDuration, &c.minDuration},
+		{"max_duration", a.Expect.MaxDuration, &c.maxDuration},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("%s: invalid %s %q", c.name, d.field, d.value)
+		}
+		*d.into = parsed
+	}
+	if c.status != "" && c.status != "ok" && c.status != "error" {
+		return nil, fmt.Errorf("%s: expected status must be ok or error, got %q", c.name, c.status)
+	}
+	return c, nil
+}
+
+// matches reports whether span, in a trace indexed by span ID, matches.
+func (s *compiledSelector) matches(span *models.Span, spans map[string]*models.Span) bool {
+	if (s.Service != "" && span.ServiceName != s.Service) ||
+		(s.operation != nil && !s.operation.MatchString(span.OperationName)) ||
+		(s.Kind != "" && span.SpanKind != s.Kind) ||
+		(s.Status == "error" && !span.IsError()) || (s.Status == "ok" && span.IsError()) ||
+		!hasTags(span, s.Tags) {
+		return false
+	}
+	if s.within == nil {
+		return true
+	}
+	seen := make(map[string]bool)
+	for parent := spans[span.ParentSpanID]; parent != nil && !seen[parent.SpanID]; parent = spans[parent.ParentSpanID] {
+		seen[parent.SpanID] = true // Guards against parent cycles
+		if s.within.matches(parent, spans) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTags reports whether span has every tag, any value for empty ones.
+func hasTags(span *models.Span, tags map[string]string) bool {
+	for key, want := range tags {
+		got, ok := span.Tags[key]
+		if !ok || (want != "" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// check runs the assertion on a trace.
+func (a *compiledAssertion) check(trace *models.Trace) AssertionResult {
+	result := AssertionResult{Name: a.name, TraceID: trace.TraceID}
+	spans := make(map[string]*models.Span, len(trace.Spans))
+	for i := range trace.Spans {
+		spans[trace.Spans[i].SpanID] = &trace.Spans[i]
+	}
+
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		if !a.selector.matches(span, spans) {
+			continue
+		}
+		result.Matched++
+		name := fmt.Sprintf("%s %q (span %s)", span.ServiceName, span.OperationName, span.SpanID)
+		if a.maxDuration > 0 && span.Duration > a.maxDuration {
+			result.Failures = append(result.Failures, fmt.Sprintf("%s took %s, want at most %s", name, span.Duration, a.maxDuration))
+		}
+		if span.Duration < a.minDuration {
+			result.Failures = append(result.Failures, fmt.Sprintf("%s took %s, want at least %s", name, span.Duration, a.minDuration))
+		}
+		if a.status != "" && (a.status == "error") != span.IsError() {
+			got := "ok"
+			if span.IsError() {
+				got = "error"
+			}
+			result.Failures = append(result.Failures, fmt.Sprintf("%s has status %s, want %s", name, got, a.status))
+		}
+		for key, want := range a.tags {
+			got, ok := span.Tags[key]
+			switch {
+			case !ok:
+				result.Failures = append(result.Failures, fmt.Sprintf("%s has no tag %s", name, key))
+			case want != "" && got != want:
+				result.Failures = append(result.Failures, fmt.Sprintf("%s has tag %s=%q, want %q", name, key, got, want))
+			}
+		}
+	}
+
+	if result.Matched < a.minCount {
+		result.Failures = append(result.Failures, fmt.Sprintf("matched %d spans, want at least %d", result.Matched, a.minCount))
+	}
+	if a.maxCount >= 0 && result.Matched > a.maxCount {
+		result.Failures = append(result.Failures, fmt.Sprintf("matched %d spans, want at most %d", result.Matched, a.maxCount))
+	}
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// assertionRun is a validated AssertionRequest.
+type assertionRun struct {
+	traceID    string
+	query      *storage.Query
+	wait       time.Duration
+	assertions []*compiledAssertion
+}
+
+func (req AssertionRequest) compile(now time.Time) (*assertionRun, error) {
+	if (req.TraceID == "") == (req.Match == nil) {
+		return nil, errors.New("set exactly one of trace_id and match")
+	}
+	if len(req.Assertions) == 0 {
+		return nil, errors.New("at least one assertion is required")
+	}
+	run := &assertionRun{traceID: req.TraceID}
+	if req.Wait != "" {
+		wait, err := time.ParseDuration(req.Wait)
+		if err != nil || wait < 0 {
+			return nil, fmt.Errorf("invalid wait %q", req.Wait)
+		}
+		run.wait = min(wait, maxAssertionWait)
+	}
+	if match := req.Match; match != nil {
+		lookback := defaultAssertionLookback
+		if match.Lookback != "" {
+			parsed, err := time.ParseDuration(match.Lookback)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("invalid match lookback %q", match.Lookback)
+			}
+			lookback = parsed
+		}
+		if match.Service == "" && len(match.Tags) == 0 {
+			return nil, errors.New("match needs a service or tags")
+		}
+		limit := match.Limit
+		if limit <= 0 {
+			limit = 1
+		}
+		run.query = &storage.Query{
+			Service:   match.Service,
+			Tags:      match.Tags,
+			StartTime: now.Add(-lookback),
+			Limit:     limit,
+			SortBy:    storage.SortByStartTime,
+			SortOrder: storage.SortDesc,
+		}
+	}
+	for i, a := range req.Assertions {
+		compiled, err := compileAssertion(i, a)
+		if err != nil {
+			return nil, err
+		}
+		run.assertions = append(run.assertions, compiled)
+	}
+	return run, nil
+}
+
+// traces returns the traces the run checks.
+func (run *assertionRun) traces(ctx context.Context, store storage.Store) ([]*models.Trace, error) {
+	if run.query == nil {
+		trace, err := store.GetTrace(ctx, run.traceID)
+		if err != nil || trace == nil {
+			return nil, err
+		}
+		return []*models.Trace{trace}, nil
+	}
+	return store.FindTraces(ctx, run.query)
+}
+
+// evaluate checks every assertion once.
+func (run *assertionRun) evaluate(ctx context.Context, store storage.Store) (AssertionReport, error) {
+	report := AssertionReport{TraceIDs: []string{}, Results: []AssertionResult{}}
+	traces, err := run.traces(ctx, store)
+	if err != nil {
+		return report, err
+	}
+	if len(traces) == 0 {
+		report.Error = "no trace found"
+		return report, nil
+	}
+	report.Passed = true
+	for _, trace := range traces {
+		report.TraceIDs = append(report.TraceIDs, trace.TraceID)
+		for _, a := range run.assertions {
+			result := a.check(trace)
+			report.Passed = report.Passed && result.Passed
+			report.Results = append(report.Results, result)
+		}
+	}
+	return report, nil
+}
+
+// HandleAssertions handles POST /api/v1/assertions - check that a trace has
+// the spans an integration test expects, e.g. a db span under 50ms beneath
+// the checkout request. The report says whether every assertion passed.
+func (c *Collector) HandleAssertions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req AssertionRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&req); err != nil {
+		http.Error(w, "invalid assertion request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	run, err := req.compile(time.Now())
+	if err != nil {
+		http.Error(w, "invalid assertion request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	deadline := time.Now().Add(run.wait)
+	var report AssertionReport
+	for attempts := 1; ; attempts++ {
+		report, err = run.evaluate(r.Context(), c.store)
+		if err != nil {
+			c.logger.Error("failed to evaluate assertions", "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		report.Attempts = attempts
+		if report.Passed || time.Now().Add(assertionPollInterval).After(deadline) {
+			break
+		}
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(assertionPollInterval):
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
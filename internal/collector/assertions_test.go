@@ -0,0 +1,120 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// writeAssertionTrace stores a checkout trace: a server span with an
+// inventory query and a payment call beneath it.
+func writeAssertionTrace(t *testing.T, store storage.Store, runID string, queryDuration time.Duration) string {
+	t.Helper()
+	traceID := models.GenerateTraceID()
+	rootID, paymentID := models.GenerateSpanID(), models.GenerateSpanID()
+	start := time.Now().Add(-time.Second)
+	for _, span := range []*models.Span{
+		{SpanID: rootID, ServiceName: "api", OperationName: "POST /checkout", SpanKind: "server", Duration: 200 * time.Millisecond, Status: "ok", Tags: map[string]string{"test.run_id": runID}},
+		{SpanID: models.GenerateSpanID(), ParentSpanID: rootID, ServiceName: "db", OperationName: "SELECT inventory", Duration: queryDuration, Status: "ok", Tags: map[string]string{"db.system": "postgresql"}},
+		{SpanID: paymentID, ParentSpanID: rootID, ServiceName: "payments", OperationName: "charge", Duration: 80 * time.Millisecond, Status: "ok"},
+	} {
+		span.TraceID, span.StartTime = traceID, start
+		if err := store.WriteSpan(context.Background(), span); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return traceID
+}
+
+func postAssertions(t *testing.T, col *Collector, body string) (int, AssertionReport) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/assertions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	col.HandleAssertions(w, req)
+	var report AssertionReport
+	if w.Code == http.StatusOK {
+		if err := json.NewDecoder(w.Body).Decode(&report); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return w.Code, report
+}
+
+func TestHandleAssertions(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	fast := writeAssertionTrace(t, store, "1", 20*time.Millisecond)
+	writeAssertionTrace(t, store, "2", 70*time.Millisecond)
+
+	code, report := postAssertions(t, col, `{"trace_id": "`+fast+`", "assertions": [
+		{"name": "fast query", "select": {"service": "db", "operation": "SELECT *", "within": {"kind": "server"}}, "expect": {"max_duration": "50ms", "tags": {"db.system": "postgresql"}}},
+		{"name": "no errors", "select": {"status": "error"}, "expect": {"max_count": 0}},
+		{"select": {"service": "payments"}, "expect": {"min_count": 1, "max_count": 1, "status": "ok"}}
+	]}`)
+	if code != http.StatusOK || !report.Passed || len(report.Results) != 3 {
+		t.Fatalf("expected all to pass, got %d %+v", code, report)
+	}
+	if report.Results[0].Matched != 1 || report.Results[2].Name != "assertion 3" {
+		t.Errorf("unexpected results: %+v", report.Results)
+	}
+
+	// Matched by the test's tag, the slow run fails with the reason
+	code, report = postAssertions(t, col, `{"match": {"service": "api", "tags": {"test.run_id": "2"}}, "assertions": [
+		{"name": "fast query", "select": {"service": "db"}, "expect": {"max_duration": "50ms"}},
+		{"name": "cache used", "select": {"service": "cache"}}
+	]}`)
+	if code != http.StatusOK || report.Passed || len(report.TraceIDs) != 1 || report.TraceIDs[0] == fast {
+		t.Fatalf("expected the slow trace to fail, got %d %+v", code, report)
+	}
+	if failures := report.Results[0].Failures; len(failures) != 1 || !strings.Contains(failures[0], "took 70ms, want at most 50ms") {
+		t.Errorf("unexpected failures: %v", failures)
+	}
+	if failures := report.Results[1].Failures; len(failures) != 1 || !strings.Contains(failures[0], "matched 0 spans") {
+		t.Errorf("unexpected failures: %v", failures)
+	}
+
+	code, report = postAssertions(t, col, `{"trace_id": "`+models.GenerateTraceID()+`", "assertions": [{"select": {}}]}`)
+	if code != http.StatusOK || report.Passed || report.Error != "no trace found" {
+		t.Errorf("missing trace: %d %+v", code, report)
+	}
+}
+
+func TestHandleAssertions_Wait(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		writeAssertionTrace(t, store, "late", 10*time.Millisecond)
+	}()
+
+	code, report := postAssertions(t, col, `{"match": {"tags": {"test.run_id": "late"}}, "wait": "5s", "assertions": [{"select": {"service": "payments"}}]}`)
+	if code != http.StatusOK || !report.Passed || report.Attempts < 2 {
+		t.Errorf("expected a pass after waiting, got %d %+v", code, report)
+	}
+}
+
+func TestHandleAssertions_Invalid(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(10), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	for _, body := range []string{
+		`{"assertions": [{"select": {}}]}`,
+		`{"trace_id": "a", "match": {"service": "api"}, "assertions": [{"select": {}}]}`,
+		`{"trace_id": "a", "assertions": []}`,
+		`{"trace_id": "a", "assertions": [{"select": {"status": "broken"}}]}`,
+		`{"trace_id": "a", "assertions": [{"select": {}, "expect": {"max_duration": "fast"}}]}`,
+		`{"trace_id": "a", "assertions": [{"select": {}, "expect": {"min_count": 3, "max_count": 1}}]}`,
+		`{"match": {}, "assertions": [{"select": {}}]}`,
+		`{"trace_id": "a", "asserts": []}`,
+	} {
+		if code, _ := postAssertions(t, col, body); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", body, code)
+		}
+	}
+}
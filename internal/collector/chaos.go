@@ -0,0 +1,288 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxChaosLatency caps injected latency, which holds a worker for its
+// whole length and delays shutdown by as much.
+const maxChaosLatency = 10 * time.Second
+
+// Injected failures; processSpan returns the first two like their real
+// counterparts
+var (
+	errChaosStorage = errors.New("injected storage error")
+	errChaosDrop    = fmt.Errorf("injected drop: %w", errSpanDropped)
+	errChaosReject  = errors.New("injected ingestion failure")
+)
+
+// ChaosSpec is the failure injection set with PUT /api/v1/admin/chaos, for
+// soak tests that check SDK retries and backoff, and alerting, before a real
+// incident does. Rates are fractions between 0 and 1.
+type ChaosSpec struct {
+	StorageErrorRate float64 `json:"storage_error_rate,omitempty"` // Span writes failing as if the store had
+	DropRate         float64 `json:"drop_rate,omitempty"`          // Accepted spans discarded before storage
+	RejectRate       float64 `json:"reject_rate,omitempty"`        // HTTP ingestion requests refused with 503
+
+	Latency string `json:"latency,omitempty"` // Added before each span write, e.g. "200ms"
+	Jitter  string `json:"jitter,omitempty"`  // Up to this much more latency at random
+
+	// Duration ends the injection after this long, so a forgotten soak test
+	// does not outlive it (default: until DELETE)
+	Duration string `json:"duration,omitempty"`
+}
+
+// parse validates the spec and resolves its durations.
+func (s ChaosSpec) parse() (*chaosState, error) {
+	state := &chaosState{spec: s}
+	for name, rate := range map[string]float64{
+		"storage_error_rate": s.StorageErrorRate,
+		"drop_rate":          s.DropRate,
+		"reject_rate":        s.RejectRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("%s %v must be between 0 and 1", name, rate)
+		}
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"latency", s.Latency, &state.latency},
+		{"jitter", s.Jitter, &state.jitter},
+		{"duration", s.Duration, &state.duration},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid %s %q", d.name, d.value)
+		}
+		*d.into = parsed
+	}
+	if state.latency+state.jitter > maxChaosLatency {
+		return nil, fmt.Errorf("latency plus jitter must not exceed %s", maxChaosLatency)
+	}
+	return state, nil
+}
+
+// ChaosStatus is the response of /api/v1/admin/chaos.
+type ChaosStatus struct {
+	Active    bool       `json:"active"`
+	Spec      *ChaosSpec `json:"spec,omitempty"`
+	Since     *time.Time `json:"since,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Injected counts the failures injected since the collector started
+	Injected ChaosCounts `json:"injected"`
+}
+
+// ChaosCounts are the failures injected, by kind.
+type ChaosCounts struct {
+	StorageErrors    int64 `json:"storage_errors"`
+	SpansDropped     int64 `json:"spans_dropped"`
+	RequestsRejected int64 `json:"requests_rejected"`
+	SpansDelayed     int64 `json:"spans_delayed"`
+}
+
+// chaosState is an active ChaosSpec.
+type chaosState struct {
+	spec                      ChaosSpec
+	latency, jitter, duration time.Duration
+	since, expires            time.Time // Zero expires = until cleared
+}
+
+// chaos injects failures into the span path while a spec is set. A nil
+// *chaos, when failure injection is not enabled, injects nothing.
+type chaos struct {
+	mu     sync.Mutex
+	state  *chaosState // nil when inactive
+	random *rand.Rand  // Guarded by mu
+
+	storageErrors    atomic.Int64
+	spansDropped     atomic.Int64
+	requestsRejected atomic.Int64
+	spansDelayed     atomic.Int64
+}
+
+func newChaos() *chaos {
+	return &chaos{random: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+// set starts injecting the failures of state, replacing any before it.
+func (ch *chaos) set(state *chaosState) {
+	state.since = time.Now()
+	if state.duration > 0 {
+		state.expires = state.since.Add(state.duration)
+	}
+	ch.mu.Lock()
+	ch.state = state
+	ch.mu.Unlock()
+}
+
+// clear stops injecting failures.
+func (ch *chaos) clear() {
+	ch.mu.Lock()
+	ch.state = nil
+	ch.mu.Unlock()
+}
+
+// activeLocked returns the current state, clearing it once expired.
+func (ch *chaos) activeLocked() *chaosState {
+	if ch.state != nil && !ch.state.expires.IsZero() && time.Now().After(ch.state.expires) {
+		ch.state = nil
+	}
+	return ch.state
+}
+
+// roll reports whether a failure with the rate pick selects from the active
+// spec happens this time.
+func (ch *chaos) roll(pick func(ChaosSpec) float64) bool {
+	if ch == nil {
+		return false
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	state := ch.activeLocked()
+	if state == nil {
+		return false
+	}
+	rate := pick(state.spec)
+	return rate > 0 && ch.random.Float64() < rate
+}
+
+// delay returns the latency to inject before a span write.
+func (ch *chaos) delay() time.Duration {
+	if ch == nil {
+		return 0
+	}
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	state := ch.activeLocked()
+	if state == nil {
+		return 0
+	}
+	d := state.latency
+	if state.jitter > 0 {
+		d += time.Duration(ch.random.Int63n(int64(state.jitter) + 1))
+	}
+	return d
+}
+
+// beforeWrite injects latency, a drop or a storage error ahead of a span
+// write, returning errChaosDrop or errChaosStorage for the latter two.
+func (ch *chaos) beforeWrite(ctx context.Context) error {
+	if ch == nil {
+		return nil
+	}
+	if d := ch.delay(); d > 0 {
+		ch.spansDelayed.Add(1)
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+	if ch.roll(func(s ChaosSpec) float64 { return s.DropRate }) {
+		ch.spansDropped.Add(1)
+		return errChaosDrop
+	}
+	if ch.roll(func(s ChaosSpec) float64 { return s.StorageErrorRate }) {
+		ch.storageErrors.Add(1)
+		return errChaosStorage
+	}
+	return nil
+}
+
+// rejectRequest reports whether to refuse an ingestion request.
+func (ch *chaos) rejectRequest() bool {
+	if !ch.roll(func(s ChaosSpec) float64 { return s.RejectRate }) {
+		return false
+	}
+	ch.requestsRejected.Add(1)
+	return true
+}
+
+func (ch *chaos) counts() ChaosCounts {
+	return ChaosCounts{
+		StorageErrors:    ch.storageErrors.Load(),
+		SpansDropped:     ch.spansDropped.Load(),
+		RequestsRejected: ch.requestsRejected.Load(),
+		SpansDelayed:     ch.spansDelayed.Load(),
+	}
+}
+
+func (ch *chaos) status() ChaosStatus {
+	ch.mu.Lock()
+	state := ch.activeLocked()
+	ch.mu.Unlock()
+
+	status := ChaosStatus{Injected: ch.counts()}
+	if state != nil {
+		spec := state.spec
+		since := state.since
+		status.Active = true
+		status.Spec = &spec
+		status.Since = &since
+		if !state.expires.IsZero() {
+			expires := state.expires
+			status.ExpiresAt = &expires
+		}
+	}
+	return status
+}
+
+// HandleChaos handles /api/v1/admin/chaos: GET reports the failure
+// injection in effect and the failures injected so far, PUT replaces it
+// with the ChaosSpec in the body and DELETE stops it. It answers 404 unless
+// the collector was configured with EnableChaos.
+func (c *Collector) HandleChaos(w http.ResponseWriter, r *http.Request) {
+	if c.chaos == nil {
+		http.Error(w, "failure injection disabled", http.StatusNotFound)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var spec ChaosSpec
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&spec); err != nil {
+			http.Error(w, "invalid chaos spec: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		state, err := spec.parse()
+		if err != nil {
+			http.Error(w, "invalid chaos spec: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.chaos.set(state)
+		c.logger.Warn("failure injection started",
+			"storage_error_rate", spec.StorageErrorRate,
+			"drop_rate", spec.DropRate,
+			"reject_rate", spec.RejectRate,
+			"latency", state.latency,
+			"jitter", state.jitter,
+			"duration", state.duration,
+		)
+	case http.MethodDelete:
+		c.chaos.clear()
+		c.logger.Warn("failure injection stopped")
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.chaos.status())
+}
@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHandleChaos(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10, EnableChaos: true}, slog.Default())
+	chaosRequest := func(method, body string) (*httptest.ResponseRecorder, ChaosStatus) {
+		rec := httptest.NewRecorder()
+		col.HandleChaos(rec, httptest.NewRequest(method, "/api/v1/admin/chaos", strings.NewReader(body)))
+		var status ChaosStatus
+		json.NewDecoder(rec.Body).Decode(&status)
+		return rec, status
+	}
+	span := func() *models.Span {
+		return &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /users",
+			StartTime:     time.Now(),
+			Duration:      time.Millisecond,
+			Status:        "ok",
+		}
+	}
+
+	if _, status := chaosRequest(http.MethodGet, ""); status.Active {
+		t.Fatalf("chaos active before being set: %+v", status)
+	}
+	if err := col.processSpan(context.Background(), span()); err != nil {
+		t.Fatalf("processSpan without chaos: %v", err)
+	}
+
+	rec, status := chaosRequest(http.MethodPut, `{"storage_error_rate": 1, "latency": "1ms", "duration": "1h"}`)
+	if rec.Code != http.StatusOK || !status.Active || status.ExpiresAt == nil {
+		t.Fatalf("PUT = %d %+v", rec.Code, status)
+	}
+	if err := col.processSpan(context.Background(), span()); !errors.Is(err, errChaosStorage) {
+		t.Errorf("processSpan with storage errors = %v", err)
+	}
+
+	chaosRequest(http.MethodPut, `{"drop_rate": 1}`)
+	if err := col.processSpan(context.Background(), span()); !errors.Is(err, errSpanDropped) {
+		t.Errorf("processSpan with drops = %v", err)
+	}
+
+	chaosRequest(http.MethodPut, `{"reject_rate": 1}`)
+	body, _ := json.Marshal(span())
+	post := httptest.NewRecorder()
+	col.HandlePostSpan(post, httptest.NewRequest(http.MethodPost, "/api/v1/spans", strings.NewReader(string(body))))
+	if post.Code != http.StatusServiceUnavailable || post.Header().Get("Retry-After") == "" {
+		t.Errorf("POST with rejections = %d, Retry-After %q", post.Code, post.Header().Get("Retry-After"))
+	}
+
+	_, status = chaosRequest(http.MethodDelete, "")
+	want := ChaosCounts{StorageErrors: 1, SpansDropped: 1, RequestsRejected: 1, SpansDelayed: 1}
+	if status.Active || status.Injected != want {
+		t.Errorf("after DELETE = %+v, want inactive with %+v", status, want)
+	}
+	if err := col.processSpan(context.Background(), span()); err != nil {
+		t.Errorf("processSpan after DELETE: %v", err)
+	}
+
+	for _, body := range []string{`{"drop_rate": 1.5}`, `{"latency": "soon"}`, `{"latency": "30s"}`, `{"jitter": "-1s"}`} {
+		if rec, _ := chaosRequest(http.MethodPut, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s = %d, want 400", body, rec.Code)
+		}
+	}
+}
+
+func TestHandleChaos_Disabled(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	rec := httptest.NewRecorder()
+	col.HandleChaos(rec, httptest.NewRequest(http.MethodPut, "/api/v1/admin/chaos", strings.NewReader(`{"drop_rate": 1}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
+
+func TestChaos_Expires(t *testing.T) {
+	ch := newChaos()
+	state, err := ChaosSpec{DropRate: 1, Duration: "1ms"}.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch.set(state)
+	time.Sleep(5 * time.Millisecond)
+	if err := ch.beforeWrite(context.Background()); err != nil || ch.status().Active {
+		t.Errorf("expired chaos injected %v, status %+v", err, ch.status())
+	}
+}
@@ -0,0 +1,225 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Span changes in a trace comparison.
+const (
+	spanMatched = "matched" // In both traces
+	spanAdded   = "added"   // Only in trace B
+	spanRemoved = "removed" // Only in trace A
+)
+
+// TraceComparison lines up the spans of two traces, typically a slow trace
+// (B) against a fast baseline (A), to show where the difference comes from.
+type TraceComparison struct {
+	A ComparedTrace `json:"a"`
+	B ComparedTrace `json:"b"`
+
+	DurationDelta time.Duration `json:"duration_delta"` // B - A
+	Matched       int           `json:"matched"`
+	Added         int           `json:"added"`
+	Removed       int           `json:"removed"`
+	ErrorChanges  int           `json:"error_changes"` // Matched spans that failed in one trace only
+
+	// Spans are the aligned spans, largest duration change first
+	Spans []SpanComparison `json:"spans"`
+}
+
+// ComparedTrace summarizes one side of a comparison.
+type ComparedTrace struct {
+	TraceID     string            `json:"trace_id"`
+	StartTime   time.Time         `json:"start_time"`
+	Duration    time.Duration     `json:"duration"`
+	Spans       int               `json:"spans"`
+	Errors      int               `json:"errors"`
+	Deployments map[string]string `json:"deployments,omitempty"` // Service → deployment ID
+}
+
+// SpanComparison is a span of the same operation in each trace. Spans are
+// aligned by service and operation name; the nth span of an operation in A,
+// in start order, is matched with the nth in B.
+type SpanComparison struct {
+	Service    string `json:"service"`
+	Operation  string `json:"operation"`
+	Occurrence int    `json:"occurrence"` // 0 for the operation's first span in a trace
+	Change     string `json:"change"`     // matched, added or removed
+
+	A *ComparedSpan `json:"a,omitempty"`
+	B *ComparedSpan `json:"b,omitempty"`
+
+	// DurationDelta is B's duration minus A's; an added span counts its
+	// whole duration, a removed one minus its duration
+	DurationDelta time.Duration `json:"duration_delta"`
+	ErrorChanged  bool          `json:"error_changed,omitempty"`
+}
+
+// ComparedSpan is a span's side of a SpanComparison.
+type ComparedSpan struct {
+	SpanID       string        `json:"span_id"`
+	Offset       time.Duration `json:"offset"` // Start relative to the trace's start
+	Duration     time.Duration `json:"duration"`
+	Status       string        `json:"status"`
+	DeploymentID string        `json:"deployment_id,omitempty"`
+}
+
+// operationOccurrence identifies the nth span of an operation in a trace.
+type operationOccurrence struct {
+	service    string
+	operation  string
+	occurrence int
+}
+
+// occurrences indexes the spans of trace by operation and occurrence.
+func occurrences(trace *models.Trace) (map[operationOccurrence]*models.Span, []operationOccurrence) {
+	spans := make([]*models.Span, len(trace.Spans))
+	for i := range trace.Spans {
+		spans[i] = &trace.Spans[i]
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].StartTime.Before(spans[j].StartTime) })
+
+	byKey := make(map[operationOccurrence]*models.Span, len(spans))
+	keys := make([]operationOccurrence, 0, len(spans))
+	seen := make(map[operationOccurrence]int) // First occurrence → spans so far
+	for _, span := range spans {
+		first := operationOccurrence{service: span.ServiceName, operation: span.OperationName}
+		key := first
+		key.occurrence = seen[first]
+		seen[first]++
+		byKey[key] = span
+		keys = append(keys, key)
+	}
+	return byKey, keys
+}
+
+func newComparedTrace(trace *models.Trace) ComparedTrace {
+	compared := ComparedTrace{
+		TraceID:     trace.TraceID,
+		StartTime:   trace.StartTime,
+		Duration:    trace.Duration,
+		Spans:       len(trace.Spans),
+		Deployments: trace.Deployments,
+	}
+	for i := range trace.Spans {
+		if trace.Spans[i].IsError() {
+			compared.Errors++
+		}
+	}
+	return compared
+}
+
+func newComparedSpan(trace *models.Trace, span *models.Span) *ComparedSpan {
+	return &ComparedSpan{
+		SpanID:       span.SpanID,
+		Offset:       span.StartTime.Sub(trace.StartTime),
+		Duration:     span.Duration,
+		Status:       span.Status,
+		DeploymentID: span.DeploymentID,
+	}
+}
+
+// compareTraces aligns the spans of a and b.
+func compareTraces(a, b *models.Trace) TraceComparison {
+	comparison := TraceComparison{
+		A:             newComparedTrace(a),
+		B:             newComparedTrace(b),
+		DurationDelta: b.Duration - a.Duration,
+		Spans:         []SpanComparison{},
+	}
+	aSpans, aKeys := occurrences(a)
+	bSpans, bKeys := occurrences(b)
+
+	for _, key := range aKeys {
+		aSpan := aSpans[key]
+		diff := SpanComparison{Service: key.service, Operation: key.operation, Occurrence: key.occurrence, A: newComparedSpan(a, aSpan)}
+		if bSpan, ok := bSpans[key]; ok {
+			diff.Change = spanMatched
+			diff.B = newComparedSpan(b, bSpan)
+			diff.DurationDelta = bSpan.Duration - aSpan.Duration
+			diff.ErrorChanged = aSpan.IsError() != bSpan.IsError()
+			comparison.Matched++
+			if diff.ErrorChanged {
+				comparison.ErrorChanges++
+			}
+		} else {
+			diff.Change = spanRemoved
+			diff.DurationDelta = -aSpan.Duration
+			comparison.Removed++
+		}
+		comparison.Spans = append(comparison.Spans, diff)
+	}
+	for _, key := range bKeys {
+		if _, ok := aSpans[key]; ok {
+			continue
+		}
+		bSpan := bSpans[key]
+		comparison.Spans = append(comparison.Spans, SpanComparison{
+			Service:       key.service,
+			Operation:     key.operation,
+			Occurrence:    key.occurrence,
+			Change:        spanAdded,
+			B:             newComparedSpan(b, bSpan),
+			DurationDelta: bSpan.Duration,
+		})
+		comparison.Added++
+	}
+
+	sort.SliceStable(comparison.Spans, func(i, j int) bool {
+		return absDuration(comparison.Spans[i].DurationDelta) > absDuration(comparison.Spans[j].DurationDelta)
+	})
+	return comparison
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// HandleCompareTraces handles GET /api/v1/traces/compare?a=&b= - align two
+// traces by operation and report latency deltas, added and removed spans
+// and changed error states.
+func (c *Collector) HandleCompareTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	var errs []QueryParamError
+	for _, param := range []string{"a", "b"} {
+		if params.Get(param) == "" {
+			errs = append(errs, QueryParamError{Param: param, Reason: "trace ID required"})
+		}
+	}
+	if len(errs) > 0 {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	var traces [2]*models.Trace
+	for i, param := range []string{"a", "b"} {
+		traceID := params.Get(param)
+		trace, err := c.store.GetTrace(r.Context(), traceID)
+		if err != nil {
+			c.logger.Error("failed to get trace", "trace_id", traceID, "error", err)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if trace == nil {
+			http.Error(w, "trace "+param+" not found", http.StatusNotFound)
+			return
+		}
+		traces[i] = trace
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(compareTraces(traces[0], traces[1]))
+}
@@ -0,0 +1,118 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// compareSpan describes a span of a comparison test trace.
+type compareSpan struct {
+	service, operation string
+	offset, duration   time.Duration
+	status             string
+}
+
+// writeCompareTrace stores a trace of the given spans and returns its ID.
+func writeCompareTrace(t *testing.T, store storage.Store, spans ...compareSpan) string {
+	t.Helper()
+	traceID := models.GenerateTraceID()
+	start := time.Now().Add(-time.Minute)
+	for _, s := range spans {
+		err := store.WriteSpan(context.Background(), &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   s.service,
+			OperationName: s.operation,
+			StartTime:     start.Add(s.offset),
+			Duration:      s.duration,
+			Status:        s.status,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return traceID
+}
+
+func TestHandleCompareTraces(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ms := time.Millisecond
+	fast := writeCompareTrace(t, store,
+		compareSpan{"api", "GET /orders", 0, 100 * ms, "ok"},
+		compareSpan{"api", "auth", 1 * ms, 5 * ms, "ok"},
+		compareSpan{"db", "query", 10 * ms, 20 * ms, "ok"},
+		compareSpan{"db", "query", 40 * ms, 20 * ms, "ok"},
+	)
+	slow := writeCompareTrace(t, store,
+		compareSpan{"api", "GET /orders", 0, 300 * ms, "error"},
+		compareSpan{"db", "query", 10 * ms, 20 * ms, "ok"},
+		compareSpan{"db", "query", 40 * ms, 200 * ms, "error"},
+		compareSpan{"cache", "get", 250 * ms, 2 * ms, "ok"},
+	)
+
+	rec := httptest.NewRecorder()
+	col.HandleCompareTraces(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces/compare?a="+fast+"&b="+slow, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var comparison TraceComparison
+	if err := json.NewDecoder(rec.Body).Decode(&comparison); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	if comparison.A.TraceID != fast || comparison.B.TraceID != slow || comparison.DurationDelta != 200*ms {
+		t.Errorf("a %s, b %s, delta %v", comparison.A.TraceID, comparison.B.TraceID, comparison.DurationDelta)
+	}
+	if comparison.Matched != 3 || comparison.Added != 1 || comparison.Removed != 1 || comparison.ErrorChanges != 2 {
+		t.Errorf("matched %d, added %d, removed %d, error changes %d",
+			comparison.Matched, comparison.Added, comparison.Removed, comparison.ErrorChanges)
+	}
+	if len(comparison.Spans) != 5 {
+		t.Fatalf("%d spans, want 5", len(comparison.Spans))
+	}
+
+	// Largest change first: the root, then the second query
+	root, query := comparison.Spans[0], comparison.Spans[1]
+	if root.Operation != "GET /orders" || root.DurationDelta != 200*ms || !root.ErrorChanged {
+		t.Errorf("spans[0] = %+v", root)
+	}
+	if query.Operation != "query" || query.Occurrence != 1 || query.DurationDelta != 180*ms || query.B.Offset != 40*ms {
+		t.Errorf("spans[1] = %+v", query)
+	}
+	for _, span := range comparison.Spans[2:] {
+		switch span.Operation {
+		case "auth":
+			if span.Change != spanRemoved || span.B != nil || span.DurationDelta != -5*ms {
+				t.Errorf("auth = %+v, want removed", span)
+			}
+		case "get":
+			if span.Change != spanAdded || span.A != nil || span.DurationDelta != 2*ms {
+				t.Errorf("cache get = %+v, want added", span)
+			}
+		case "query":
+			if span.Change != spanMatched || span.Occurrence != 0 || span.DurationDelta != 0 || span.ErrorChanged {
+				t.Errorf("first query = %+v, want unchanged", span)
+			}
+		}
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/traces/compare?a=" + fast:                                    http.StatusBadRequest,
+		"/api/v1/traces/compare?a=" + fast + "&b=" + models.GenerateTraceID(): http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		col.HandleCompareTraces(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != want {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+}
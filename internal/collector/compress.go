@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressMinSize is the response size below which compression is skipped;
+// small bodies gain little and cost a header's worth of overhead.
+const compressMinSize = 1024
+
+var (
+	gzipWriters = sync.Pool{New: func() interface{} { return gzip.NewWriter(io.Discard) }}
+	zstdWriters = sync.Pool{New: func() interface{} {
+		w, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return w
+	}}
+)
+
+// CompressionMiddleware compresses responses of at least 1 KiB with zstd or
+// gzip, whichever the client's Accept-Encoding prefers (zstd on a tie).
+// Large trace query results shrink several-fold, which matters for clients
+// in other regions. Output is buffered until the size threshold is reached
+// or the handler flushes.
+func CompressionMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding, status: http.StatusOK}
+		defer cw.Close()
+		next(cw, r)
+	}
+}
+
+// negotiateEncoding picks zstd or gzip from an Accept-Encoding header by
+// q-value, or returns "" if the client accepts neither.
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "zstd" {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == "zstd") {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressWriter buffers the start of a response and compresses it once it
+// reaches compressMinSize; shorter responses are sent as they are.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	buf      []byte
+
+	enc         io.WriteCloser // Set once compressing
+	passthrough bool           // Set once sending uncompressed
+	headerSent  bool
+}
+
+func (w *compressWriter) WriteHeader(status int) {
+	if !w.headerSent {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	switch {
+	case w.enc != nil:
+		return w.enc.Write(p)
+	case w.passthrough:
+		return w.ResponseWriter.Write(p)
+	}
+
+	w.buf = append(w.buf, p...)
+	if len(w.buf) < compressMinSize {
+		return len(p), nil
+	}
+	if err := w.start(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// start sends the headers and the buffered data, compressing unless the
+// handler already encoded the body or the response is an error.
+func (w *compressWriter) start() error {
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" || w.status < 200 || w.status >= 300 {
+		w.passthrough = true
+		w.sendHeader()
+		_, err := w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+
+	header.Set("Content-Encoding", w.encoding)
+	header.Del("Content-Length")
+	w.sendHeader()
+	if w.encoding == "zstd" {
+		enc := zstdWriters.Get().(*zstd.Encoder)
+		enc.Reset(w.ResponseWriter)
+		w.enc = enc
+	} else {
+		enc := gzipWriters.Get().(*gzip.Writer)
+		enc.Reset(w.ResponseWriter)
+		w.enc = enc
+	}
+	_, err := w.enc.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+func (w *compressWriter) sendHeader() {
+	w.headerSent = true
+	w.ResponseWriter.WriteHeader(w.status)
+}
+
+// Close finishes the compressed stream, or sends a response that stayed
+// under the threshold uncompressed.
+func (w *compressWriter) Close() error {
+	switch {
+	case w.enc != nil:
+		err := w.enc.Close()
+		if enc, ok := w.enc.(*zstd.Encoder); ok {
+			zstdWriters.Put(enc)
+		} else {
+			gzipWriters.Put(w.enc)
+		}
+		w.enc = nil
+		return err
+	case w.passthrough:
+		return nil
+	}
+	w.passthrough = true
+	w.sendHeader()
+	_, err := w.ResponseWriter.Write(w.buf)
+	return err
+}
+
+// Flush sends what has been written so far. A handler that flushes is
+// streaming a large response, so compression starts even below the
+// threshold.
+func (w *compressWriter) Flush() {
+	if w.enc == nil && !w.passthrough {
+		if err := w.start(); err != nil {
+			return
+		}
+	}
+	if flusher, ok := w.enc.(interface{ Flush() error }); ok {
+		flusher.Flush()
+	}
+	http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
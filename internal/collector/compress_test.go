@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHandlePostSpansBatch_CompressedBodies(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	batch := func() []byte {
+		spans := []*models.Span{{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /",
+			StartTime:     time.Now(),
+			Duration:      time.Millisecond,
+			Status:        "ok",
+		}}
+		data, _ := json.Marshal(spans)
+		return data
+	}
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		gz.Close()
+		return buf.Bytes()
+	}
+	zstded := func(data []byte) []byte {
+		enc, _ := zstd.NewWriter(nil)
+		defer enc.Close()
+		return enc.EncodeAll(data, nil)
+	}
+
+	tests := []struct {
+		encoding string
+		body     []byte
+		want     int
+	}{
+		{"gzip", gzipped(batch()), http.StatusAccepted},
+		{"zstd", zstded(batch()), http.StatusAccepted},
+		{"br", batch(), http.StatusUnsupportedMediaType},
+		{"zstd", []byte("not zstd"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/spans/batch", bytes.NewReader(tt.body))
+		req.Header.Set("Content-Encoding", tt.encoding)
+		rec := httptest.NewRecorder()
+		col.HandlePostSpansBatch(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status %d, want %d (%s)", tt.encoding, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	large := strings.Repeat(`{"trace_id":"abc"},`, 200)
+	handler := CompressionMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/small":
+			io.WriteString(w, `{"ok":true}`)
+		case "/error":
+			http.Error(w, large, http.StatusBadRequest)
+		default:
+			// Written in pieces, as json.Encoder and streaming handlers do
+			for i := 0; i < len(large); i += 100 {
+				io.WriteString(w, large[i:min(i+100, len(large))])
+			}
+		}
+	})
+
+	decode := func(rec *httptest.ResponseRecorder) string {
+		var r io.Reader = rec.Body
+		switch rec.Header().Get("Content-Encoding") {
+		case "gzip":
+			gz, err := gzip.NewReader(r)
+			if err != nil {
+				t.Fatalf("gzip: %v", err)
+			}
+			r = gz
+		case "zstd":
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				t.Fatalf("zstd: %v", err)
+			}
+			defer zr.Close()
+			r = zr
+		}
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("decode %s: %v", rec.Header().Get("Content-Encoding"), err)
+		}
+		return string(data)
+	}
+
+	tests := []struct {
+		path           string
+		acceptEncoding string
+		wantEncoding   string
+	}{
+		{"/large", "gzip, deflate", "gzip"},
+		{"/large", "gzip, zstd", "zstd"},
+		{"/large", "zstd;q=0.5, gzip", "gzip"},
+		{"/large", "zstd;q=0, gzip;q=0", ""},
+		{"/large", "", ""},
+		{"/small", "gzip", ""},
+		{"/error", "gzip", ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.acceptEncoding != "" {
+			req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+
+		if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("%s with %q: Content-Encoding %q, want %q", tt.path, tt.acceptEncoding, got, tt.wantEncoding)
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%s: Vary = %q", tt.path, rec.Header().Get("Vary"))
+		}
+		body := decode(rec)
+		switch tt.path {
+		case "/small":
+			if body != `{"ok":true}` {
+				t.Errorf("small body = %q", body)
+			}
+		case "/error":
+			if rec.Code != http.StatusBadRequest || !strings.HasPrefix(body, large) {
+				t.Errorf("error response: status %d", rec.Code)
+			}
+		default:
+			if body != large {
+				t.Errorf("%s with %q: body mismatch (%d bytes)", tt.path, tt.acceptEncoding, len(body))
+			}
+		}
+	}
+}
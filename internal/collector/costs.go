@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+)
+
+// CostReport is the response of GET /api/v1/costs: what stored spans cost,
+// rolled up per service and per operation, most expensive first.
+type CostReport struct {
+	TotalCost  float64         `json:"total_cost"`
+	Services   []ServiceCost   `json:"services"`
+	Operations []OperationCost `json:"operations"`
+}
+
+// ServiceCost is the cost of a service's spans.
+type ServiceCost struct {
+	Service     string  `json:"service"`
+	Cost        float64 `json:"cost"`
+	Spans       int64   `json:"spans"`
+	CostPerSpan float64 `json:"cost_per_span"`
+	Share       float64 `json:"share"` // Of the report's total cost
+}
+
+// OperationCost is the cost of an operation's spans.
+type OperationCost struct {
+	Service     string  `json:"service"`
+	Operation   string  `json:"operation"`
+	Cost        float64 `json:"cost"`
+	Spans       int64   `json:"spans"`
+	CostPerSpan float64 `json:"cost_per_span"`
+	Share       float64 `json:"share"`
+}
+
+// costReport rolls operation stats up into a cost report.
+func costReport(stats []storage.OperationStats) CostReport {
+	report := CostReport{Services: []ServiceCost{}, Operations: make([]OperationCost, 0, len(stats))}
+	services := make(map[string]*ServiceCost)
+	for _, op := range stats {
+		report.TotalCost += op.TotalCost
+		report.Operations = append(report.Operations, OperationCost{
+			Service:   op.Service,
+			Operation: op.Operation,
+			Cost:      op.TotalCost,
+			Spans:     op.Spans,
+		})
+		service, ok := services[op.Service]
+		if !ok {
+			service = &ServiceCost{Service: op.Service}
+			services[op.Service] = service
+		}
+		service.Cost += op.TotalCost
+		service.Spans += op.Spans
+	}
+	for _, service := range services {
+		report.Services = append(report.Services, *service)
+	}
+
+	for i := range report.Services {
+		s := &report.Services[i]
+		s.CostPerSpan, s.Share = perSpan(s.Cost, s.Spans), share(s.Cost, report.TotalCost)
+	}
+	for i := range report.Operations {
+		o := &report.Operations[i]
+		o.CostPerSpan, o.Share = perSpan(o.Cost, o.Spans), share(o.Cost, report.TotalCost)
+	}
+	slices.SortFunc(report.Services, func(a, b ServiceCost) int {
+		return cmp.Or(cmp.Compare(b.Cost, a.Cost), cmp.Compare(a.Service, b.Service))
+	})
+	slices.SortFunc(report.Operations, func(a, b OperationCost) int {
+		return cmp.Or(cmp.Compare(b.Cost, a.Cost), cmp.Compare(a.Service, b.Service), cmp.Compare(a.Operation, b.Operation))
+	})
+	return report
+}
+
+func perSpan(cost float64, spans int64) float64 {
+	if spans == 0 {
+		return 0
+	}
+	return cost / float64(spans)
+}
+
+func share(cost, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return cost / total
+}
+
+// HandleCosts handles GET /api/v1/costs - span costs per service and
+// operation, with the same service and time range parameters as the stats
+// endpoints. Costs come from clients or from the collector's pricing rules.
+func (c *Collector) HandleCosts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query, errs := parseStatsQuery(r)
+	if len(errs) > 0 && isStrict(r) {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	stats, err := c.store.GetOperationStats(r.Context(), query)
+	if err != nil {
+		c.logger.Error("failed to get operation stats", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(costReport(stats))
+}
@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/cost"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHandleCosts(t *testing.T) {
+	pricer, err := cost.New(cost.Config{Rules: []cost.Rule{
+		{Service: "llm", Tokens: map[string]cost.TokenPrice{cost.AnyModel: {Input: 1, Output: 2}}},
+		{CPUSecond: 0.01},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10, Pricing: pricer}, slog.Default())
+
+	store := func(service, operation string, duration time.Duration, tags map[string]string) {
+		span := &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   service,
+			OperationName: operation,
+			StartTime:     time.Now().Add(-time.Minute),
+			Duration:      duration,
+			Status:        "ok",
+			Tags:          tags,
+		}
+		if err := col.processSpan(context.Background(), span); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 1M input and 1M output tokens cost 3; 100s of CPU 1
+	store("llm", "complete", time.Second, map[string]string{cost.DefaultInputTokensTag: "1000000", cost.DefaultOutputTokensTag: "1000000"})
+	store("api", "GET /users", 60*time.Second, nil)
+	store("api", "GET /orders", 40*time.Second, nil)
+
+	rec := httptest.NewRecorder()
+	col.HandleCosts(rec, httptest.NewRequest(http.MethodGet, "/api/v1/costs?lookback=1h", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var report CostReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.TotalCost != 4 {
+		t.Errorf("total cost = %v, want 4", report.TotalCost)
+	}
+	if len(report.Services) != 2 || report.Services[0].Service != "llm" || report.Services[0].Share != 0.75 {
+		t.Errorf("services = %+v, want llm first with 75%%", report.Services)
+	}
+	if api := report.Services[1]; api.Cost != 1 || api.Spans != 2 || api.CostPerSpan != 0.5 {
+		t.Errorf("api = %+v", api)
+	}
+	if len(report.Operations) != 3 || report.Operations[1].Operation != "GET /users" || report.Operations[1].Cost != 0.6 {
+		t.Errorf("operations = %+v", report.Operations)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleCosts(rec, httptest.NewRequest(http.MethodGet, "/api/v1/costs?service=api", nil))
+	json.NewDecoder(rec.Body).Decode(&report)
+	if len(report.Services) != 1 || report.TotalCost != 1 {
+		t.Errorf("api costs = %+v", report)
+	}
+}
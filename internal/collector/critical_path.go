@@ -0,0 +1,47 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// criticalPathSuffix follows the trace ID in critical path requests.
+const criticalPathSuffix = "/critical-path"
+
+// criticalPathResponse answers GET /api/v1/traces/:id/critical-path.
+type criticalPathResponse struct {
+	TraceID string `json:"trace_id"`
+	*models.CriticalPath
+}
+
+// handleCriticalPath handles GET /api/v1/traces/:id/critical-path - the spans
+// the trace's duration waited on, each with its contribution to it.
+func (c *Collector) handleCriticalPath(w http.ResponseWriter, r *http.Request, traceID string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if traceID == "" {
+		http.Error(w, "trace ID required", http.StatusBadRequest)
+		return
+	}
+
+	trace, err := c.store.GetTrace(r.Context(), traceID)
+	if err != nil {
+		c.logger.Error("failed to get trace", "trace_id", traceID, "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if trace == nil {
+		http.Error(w, "trace not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(criticalPathResponse{
+		TraceID:      traceID,
+		CriticalPath: models.BuildTraceTree(trace).CriticalPath(),
+	})
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHandleCriticalPath(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	traceID := models.GenerateTraceID()
+	start := time.Now().Add(-time.Minute)
+	root, slow := models.GenerateSpanID(), models.GenerateSpanID()
+	// The root waits 80ms on the slow query; the fast one runs alongside it
+	for _, span := range []*models.Span{
+		{SpanID: root, OperationName: "GET /", Duration: 100 * time.Millisecond},
+		{SpanID: slow, ParentSpanID: root, OperationName: "slow query", StartTime: start.Add(10 * time.Millisecond), Duration: 80 * time.Millisecond},
+		{SpanID: models.GenerateSpanID(), ParentSpanID: root, OperationName: "fast query", StartTime: start.Add(10 * time.Millisecond), Duration: 5 * time.Millisecond},
+	} {
+		if span.StartTime.IsZero() {
+			span.StartTime = start
+		}
+		span.TraceID, span.ServiceName, span.Status = traceID, "api", "ok"
+		if err := store.WriteSpan(context.Background(), span); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleGetTrace(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces/"+traceID+"/critical-path", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var response struct {
+		TraceID  string                    `json:"trace_id"`
+		Duration time.Duration             `json:"duration"`
+		Spans    []models.CriticalPathSpan `json:"spans"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if response.TraceID != traceID || response.Duration != 100*time.Millisecond || len(response.Spans) != 2 {
+		t.Fatalf("response = %+v", response)
+	}
+	if s := response.Spans[0]; s.Span.SpanID != root || s.Contribution != 20*time.Millisecond {
+		t.Errorf("spans[0] = %s %v, want the root with 20ms", s.Span.OperationName, s.Contribution)
+	}
+	if s := response.Spans[1]; s.Span.SpanID != slow || s.Contribution != 80*time.Millisecond || s.Share != 0.8 {
+		t.Errorf("spans[1] = %s %v %v, want the slow query with 80ms", s.Span.OperationName, s.Contribution, s.Share)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleGetTrace(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces/"+models.GenerateTraceID()+"/critical-path", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown trace: status %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,344 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// maxDeploymentsPerService bounds the deployments tracked per service. Once
+// reached, the deployment seen least recently is forgotten.
+const maxDeploymentsPerService = 20
+
+// Default regression thresholds: the current deployment regressed when its
+// p95 or p99 latency grew by more than 20%, or its error rate by more than
+// one percentage point, with at least 50 spans in both deployments.
+const (
+	DefaultRegressionLatencyIncrease   = 0.2
+	DefaultRegressionErrorRateIncrease = 0.01
+	DefaultRegressionMinSpans          = 50
+)
+
+// Deployments aggregates span latency and errors per service and deployment
+// ID, from the spans stored since the collector started, and compares each
+// service's current deployment with the previous one. Deployments are
+// ordered by their earliest span, so the current one is the latest to start
+// serving. Spans without a deployment ID are not counted.
+type Deployments struct {
+	mu       sync.Mutex
+	services map[string]map[string]*deploymentSeries // service -> deployment ID -> series
+}
+
+type deploymentSeries struct {
+	gitSHA      string
+	environment string
+	firstSeen   time.Time // Earliest span start
+	lastSeen    time.Time // Latest span end
+	spans       uint64
+	errors      uint64
+	durations   *histogram.Histogram // Seconds, over storage.StatsDurationBounds
+}
+
+func newDeployments() *Deployments {
+	return &Deployments{services: make(map[string]map[string]*deploymentSeries)}
+}
+
+// Observe records a stored span.
+func (d *Deployments) Observe(span *models.Span) {
+	if span.InProgress || span.DeploymentID == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deployments, ok := d.services[span.ServiceName]
+	if !ok {
+		deployments = make(map[string]*deploymentSeries)
+		d.services[span.ServiceName] = deployments
+	}
+	s, ok := deployments[span.DeploymentID]
+	if !ok {
+		if len(deployments) >= maxDeploymentsPerService {
+			evictStalestDeployment(deployments)
+		}
+		s = &deploymentSeries{firstSeen: span.StartTime, lastSeen: span.EndTime(), durations: histogram.New(storage.StatsDurationBounds)}
+		deployments[span.DeploymentID] = s
+	}
+
+	if span.GitSHA != "" {
+		s.gitSHA = span.GitSHA
+	}
+	if span.Environment != "" {
+		s.environment = span.Environment
+	}
+	if span.StartTime.Before(s.firstSeen) {
+		s.firstSeen = span.StartTime
+	}
+	if span.EndTime().After(s.lastSeen) {
+		s.lastSeen = span.EndTime()
+	}
+	s.spans++
+	if span.IsError() {
+		s.errors++
+	}
+	s.durations.Observe(span.Duration.Seconds())
+}
+
+// evictStalestDeployment forgets the deployment seen least recently.
+func evictStalestDeployment(deployments map[string]*deploymentSeries) {
+	var stalest string
+	for id, s := range deployments {
+		if stalest == "" || s.lastSeen.Before(deployments[stalest].lastSeen) {
+			stalest = id
+		}
+	}
+	delete(deployments, stalest)
+}
+
+// DeploymentStats aggregates the spans of one deployment of a service.
+type DeploymentStats struct {
+	DeploymentID string        `json:"deployment_id"`
+	GitSHA       string        `json:"git_sha,omitempty"`
+	Environment  string        `json:"environment,omitempty"`
+	FirstSeen    time.Time     `json:"first_seen"`
+	LastSeen     time.Time     `json:"last_seen"`
+	Spans        uint64        `json:"spans"`
+	Errors       uint64        `json:"errors"`
+	ErrorRate    float64       `json:"error_rate"`
+	AvgDuration  time.Duration `json:"avg_duration"`
+	P50Duration  time.Duration `json:"p50_duration"`
+	P95Duration  time.Duration `json:"p95_duration"`
+	P99Duration  time.Duration `json:"p99_duration"`
+}
+
+func (s *deploymentSeries) stats(id string) DeploymentStats {
+	stats := DeploymentStats{
+		DeploymentID: id,
+		GitSHA:       s.gitSHA,
+		Environment:  s.environment,
+		FirstSeen:    s.firstSeen,
+		LastSeen:     s.lastSeen,
+		Spans:        s.spans,
+		Errors:       s.errors,
+	}
+	if s.spans > 0 {
+		stats.ErrorRate = float64(s.errors) / float64(s.spans)
+		stats.AvgDuration = secondsDuration(s.durations.Sum() / float64(s.durations.Count()))
+		stats.P50Duration = secondsDuration(s.durations.Quantile(0.5))
+		stats.P95Duration = secondsDuration(s.durations.Quantile(0.95))
+		stats.P99Duration = secondsDuration(s.durations.Quantile(0.99))
+	}
+	return stats
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// Service returns the deployments of service, most recent first, or false
+// if it has none.
+func (d *Deployments) Service(service string) ([]DeploymentStats, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deployments, ok := d.services[service]
+	if !ok {
+		return nil, false
+	}
+	result := make([]DeploymentStats, 0, len(deployments))
+	for id, s := range deployments {
+		result = append(result, s.stats(id))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].FirstSeen.Equal(result[j].FirstSeen) {
+			return result[i].FirstSeen.After(result[j].FirstSeen)
+		}
+		return result[i].DeploymentID < result[j].DeploymentID
+	})
+	return result, true
+}
+
+// Services lists the services with deployments, sorted.
+func (d *Deployments) Services() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	services := make([]string, 0, len(d.services))
+	for service := range d.services {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services
+}
+
+// RegressionThresholds decide when a deployment counts as regressed.
+type RegressionThresholds struct {
+	LatencyIncrease   float64 // Relative p95 or p99 growth, e.g. 0.2 = 20%
+	ErrorRateIncrease float64 // Absolute error rate growth, e.g. 0.01 = 1 point
+	MinSpans          uint64  // Spans both deployments need to be compared
+}
+
+// DeploymentRegression compares a service's current deployment with the
+// previous one.
+type DeploymentRegression struct {
+	Service  string `json:"service"`
+	Current  string `json:"current"`
+	Previous string `json:"previous,omitempty"`
+
+	// Changes from the previous deployment: relative for latencies (0.5 =
+	// 50% slower), absolute for the error rate
+	P95Change       *float64 `json:"p95_change,omitempty"`
+	P99Change       *float64 `json:"p99_change,omitempty"`
+	ErrorRateChange float64  `json:"error_rate_change"`
+
+	Regressed bool     `json:"regressed"`
+	Reasons   []string `json:"reasons"` // Why it regressed, or why it could not be compared
+}
+
+// compareDeployments builds the regression report of deployments, sorted
+// most recent first.
+func compareDeployments(service string, deployments []DeploymentStats, thresholds RegressionThresholds) DeploymentRegression {
+	report := DeploymentRegression{Service: service, Current: deployments[0].DeploymentID, Reasons: []string{}}
+	if len(deployments) < 2 {
+		report.Reasons = append(report.Reasons, "no previous deployment")
+		return report
+	}
+	current, previous := deployments[0], deployments[1]
+	report.Previous = previous.DeploymentID
+	report.P95Change = relativeChange(float64(previous.P95Duration), float64(current.P95Duration))
+	report.P99Change = relativeChange(float64(previous.P99Duration), float64(current.P99Duration))
+	report.ErrorRateChange = current.ErrorRate - previous.ErrorRate
+
+	if current.Spans < thresholds.MinSpans || previous.Spans < thresholds.MinSpans {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("too few spans to compare: %d and %d, need %d",
+			current.Spans, previous.Spans, thresholds.MinSpans))
+		return report
+	}
+	if change := report.P95Change; change != nil && *change > thresholds.LatencyIncrease {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("p95 latency up %.0f%%: %v -> %v",
+			*change*100, previous.P95Duration, current.P95Duration))
+	}
+	if change := report.P99Change; change != nil && *change > thresholds.LatencyIncrease {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("p99 latency up %.0f%%: %v -> %v",
+			*change*100, previous.P99Duration, current.P99Duration))
+	}
+	if report.ErrorRateChange > thresholds.ErrorRateIncrease {
+		report.Reasons = append(report.Reasons, fmt.Sprintf("error rate up %.1f points: %.1f%% -> %.1f%%",
+			report.ErrorRateChange*100, previous.ErrorRate*100, current.ErrorRate*100))
+	}
+	report.Regressed = len(report.Reasons) > 0
+	return report
+}
+
+// parseRegressionThresholds reads latency_increase, error_rate_increase and
+// min_spans, defaulting to the Default thresholds.
+func parseRegressionThresholds(r *http.Request) (RegressionThresholds, []QueryParamError) {
+	thresholds := RegressionThresholds{
+		LatencyIncrease:   DefaultRegressionLatencyIncrease,
+		ErrorRateIncrease: DefaultRegressionErrorRateIncrease,
+		MinSpans:          DefaultRegressionMinSpans,
+	}
+	params := r.URL.Query()
+	var errs []QueryParamError
+	for param, target := range map[string]*float64{
+		"latency_increase":    &thresholds.LatencyIncrease,
+		"error_rate_increase": &thresholds.ErrorRateIncrease,
+	} {
+		if value := params.Get(param); value != "" {
+			if f, err := strconv.ParseFloat(value, 64); err == nil && f >= 0 {
+				*target = f
+			} else {
+				errs = append(errs, QueryParamError{Param: param, Value: value, Reason: "must be a non-negative number"})
+			}
+		}
+	}
+	if value := params.Get("min_spans"); value != "" {
+		if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+			thresholds.MinSpans = n
+		} else {
+			errs = append(errs, QueryParamError{Param: "min_spans", Value: value, Reason: "must be a non-negative integer"})
+		}
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Param < errs[j].Param })
+	return thresholds, errs
+}
+
+// HandleDeployments handles GET /api/v1/deployments - the regression report
+// of every service's current deployment, regressed services first.
+func (c *Collector) HandleDeployments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	thresholds, errs := parseRegressionThresholds(r)
+	if len(errs) > 0 && isStrict(r) {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	reports := []DeploymentRegression{}
+	regressed := 0
+	for _, service := range c.deployments.Services() {
+		deployments, ok := c.deployments.Service(service)
+		if !ok {
+			continue
+		}
+		report := compareDeployments(service, deployments, thresholds)
+		if report.Regressed {
+			regressed++
+		}
+		reports = append(reports, report)
+	}
+	sort.SliceStable(reports, func(i, j int) bool { return reports[i].Regressed && !reports[j].Regressed })
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"services":  reports,
+		"regressed": regressed,
+		"total":     len(reports),
+	})
+}
+
+// HandleServiceDeployments handles GET /api/v1/deployments/:service - the
+// service's deployments, most recent first, and whether the current one
+// regressed from the previous one.
+func (c *Collector) HandleServiceDeployments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	service := strings.TrimPrefix(r.URL.Path, "/api/v1/deployments/")
+	if service == "" || strings.Contains(service, "/") {
+		http.Error(w, "service name is required", http.StatusBadRequest)
+		return
+	}
+	thresholds, errs := parseRegressionThresholds(r)
+	if len(errs) > 0 && isStrict(r) {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	deployments, ok := c.deployments.Service(service)
+	if !ok {
+		http.Error(w, "no deployments for service", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"service":     service,
+		"deployments": deployments,
+		"regression":  compareDeployments(service, deployments, thresholds),
+	})
+}
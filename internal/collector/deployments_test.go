@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// observeDeployment records n spans of service from deployment, the given
+// number of which failed, lasting duration.
+func observeDeployment(d *Deployments, service, deployment string, start time.Time, n, failed int, duration time.Duration) {
+	for i := 0; i < n; i++ {
+		span := &models.Span{
+			TraceID:      models.GenerateTraceID(),
+			SpanID:       models.GenerateSpanID(),
+			ServiceName:  service,
+			StartTime:    start.Add(time.Duration(i) * time.Second),
+			Duration:     duration,
+			Status:       "ok",
+			DeploymentID: deployment,
+			GitSHA:       "sha-" + deployment,
+		}
+		if i < failed {
+			span.Status = "error"
+		}
+		d.Observe(span)
+	}
+}
+
+func TestHandleServiceDeployments_DetectsRegression(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	start := time.Now().Add(-2 * time.Hour)
+	observeDeployment(col.deployments, "api", "v1", start, 100, 0, 10*time.Millisecond)
+	observeDeployment(col.deployments, "api", "v2", start.Add(time.Hour), 100, 5, 30*time.Millisecond)
+	observeDeployment(col.deployments, "web", "v7", start, 100, 0, 10*time.Millisecond)
+	// Spans without a deployment ID are not counted
+	observeDeployment(col.deployments, "db", "", start, 10, 0, time.Millisecond)
+
+	get := func(target string) (*httptest.ResponseRecorder, map[string]json.RawMessage) {
+		rec := httptest.NewRecorder()
+		handler := col.HandleServiceDeployments
+		if !strings.HasPrefix(target, "/api/v1/deployments/") {
+			handler = col.HandleDeployments
+		}
+		handler(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var body map[string]json.RawMessage
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec, body
+	}
+
+	rec, body := get("/api/v1/deployments/api")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var deployments []DeploymentStats
+	var regression DeploymentRegression
+	json.Unmarshal(body["deployments"], &deployments)
+	json.Unmarshal(body["regression"], &regression)
+	if len(deployments) != 2 || deployments[0].DeploymentID != "v2" || deployments[0].GitSHA != "sha-v2" || deployments[0].ErrorRate != 0.05 {
+		t.Fatalf("deployments = %+v", deployments)
+	}
+	if !regression.Regressed || regression.Current != "v2" || regression.Previous != "v1" || len(regression.Reasons) != 3 {
+		t.Errorf("regression = %+v, want p95, p99 and error rate regressions", regression)
+	}
+	if regression.P95Change == nil || *regression.P95Change < 1 {
+		t.Errorf("p95 change = %v, want about +200%%", regression.P95Change)
+	}
+
+	// Looser thresholds accept the new deployment
+	_, body = get("/api/v1/deployments/api?latency_increase=3&error_rate_increase=0.1")
+	json.Unmarshal(body["regression"], &regression)
+	if regression.Regressed {
+		t.Errorf("regression with loose thresholds = %+v", regression)
+	}
+	_, body = get("/api/v1/deployments/api?min_spans=1000")
+	json.Unmarshal(body["regression"], &regression)
+	if regression.Regressed || len(regression.Reasons) != 1 {
+		t.Errorf("regression with too few spans = %+v", regression)
+	}
+
+	// The report covers every service, regressed ones first
+	_, body = get("/api/v1/deployments")
+	var reports []DeploymentRegression
+	json.Unmarshal(body["services"], &reports)
+	if len(reports) != 2 || reports[0].Service != "api" || !reports[0].Regressed || reports[1].Previous != "" {
+		t.Errorf("report = %+v", reports)
+	}
+
+	for target, want := range map[string]int{
+		"/api/v1/deployments/db":                    http.StatusNotFound,
+		"/api/v1/deployments/api?min_spans=-1":      http.StatusBadRequest,
+		"/api/v1/deployments?latency_increase=lots": http.StatusBadRequest,
+	} {
+		if rec, _ := get(target); rec.Code != want {
+			t.Errorf("%s: status %d, want %d", target, rec.Code, want)
+		}
+	}
+}
+
+func TestDeployments_EvictsStalest(t *testing.T) {
+	d := newDeployments()
+	start := time.Now().Add(-time.Hour)
+	for i := 0; i <= maxDeploymentsPerService; i++ {
+		observeDeployment(d, "api", "v"+string(rune('a'+i)), start.Add(time.Duration(i)*time.Minute), 1, 0, time.Millisecond)
+	}
+	deployments, _ := d.Service("api")
+	if len(deployments) != maxDeploymentsPerService || deployments[len(deployments)-1].DeploymentID != "vb" {
+		t.Errorf("%d deployments, oldest %s; want %d, the first evicted", len(deployments), deployments[len(deployments)-1].DeploymentID, maxDeploymentsPerService)
+	}
+}
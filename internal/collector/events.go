@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"context"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/events"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Events returns the collector's internal event bus. Extensions subscribe
+// to it instead of hooking into the worker loop.
+func (c *Collector) Events() *events.Bus {
+	return c.events
+}
+
+// publishSpanStored publishes a SpanStored event and records trace activity
+// for completion detection.
+func (c *Collector) publishSpanStored(span *models.Span) {
+	c.events.Publish(events.Event{
+		Type: events.SpanStored,
+		Span: span,
+	})
+
+	// Only track activity when someone is waiting for completed traces
+	if !c.events.HasSubscribers(events.TraceCompleted) {
+		return
+	}
+
+	c.pendingMu.Lock()
+	c.pending[span.TraceID] = time.Now()
+	c.pendingMu.Unlock()
+}
+
+// completionSweeper periodically publishes TraceCompleted for traces that
+// have received no spans for the idle timeout.
+func (c *Collector) completionSweeper(ctx context.Context) {
+	defer c.sweepWg.Done()
+
+	interval := c.traceIdleTimeout / 2
+	if interval <= 0 {
+		interval = c.traceIdleTimeout
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.stopCh:
+			return
+		case now := <-ticker.C:
+			c.publishIdleTraces(ctx, now.Add(-c.traceIdleTimeout))
+		}
+	}
+}
+
+// publishIdleTraces publishes TraceCompleted for traces idle since before cutoff.
+func (c *Collector) publishIdleTraces(ctx context.Context, cutoff time.Time) {
+	var idle []string
+
+	c.pendingMu.Lock()
+	for traceID, lastSeen := range c.pending {
+		if lastSeen.Before(cutoff) {
+			idle = append(idle, traceID)
+			delete(c.pending, traceID)
+		}
+	}
+	c.pendingMu.Unlock()
+
+	for _, traceID := range idle {
+		c.publishTraceCompleted(ctx, traceID)
+	}
+}
+
+// flushPendingTraces publishes TraceCompleted for every pending trace (used on shutdown).
+func (c *Collector) flushPendingTraces(ctx context.Context) {
+	c.publishIdleTraces(ctx, time.Now().Add(time.Hour))
+}
+
+// publishTraceCompleted assembles a trace and publishes it.
+func (c *Collector) publishTraceCompleted(ctx context.Context, traceID string) {
+	trace, err := c.store.GetTrace(ctx, traceID)
+	if err != nil {
+		c.logger.Error("failed to load completed trace", "trace_id", traceID, "error", err)
+		return
+	}
+	if trace == nil {
+		return // Evicted before completion
+	}
+
+	c.events.Publish(events.Event{
+		Type:  events.TraceCompleted,
+		Trace: trace,
+	})
+}
@@ -0,0 +1,97 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/events"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestEvents_SpanStoredAndTraceCompleted(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	config := &Config{Workers: 2, ChannelBuffer: 10, TraceIdleTimeout: 50 * time.Millisecond}
+	col := NewCollector(store, config, slog.Default())
+
+	stored := col.Events().Subscribe(10, events.SpanStored)
+	completed := col.Events().Subscribe(10, events.TraceCompleted)
+
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	traceID := models.GenerateTraceID()
+	for i := 0; i < 2; i++ {
+		span := &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "test-service",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Duration:      10 * time.Millisecond,
+			Status:        "ok",
+		}
+		if err := col.SubmitSpan(span); err != nil {
+			t.Fatalf("failed to submit span: %v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case evt := <-stored.C():
+			if evt.Span.TraceID != traceID {
+				t.Errorf("span event trace_id = %s, want %s", evt.Span.TraceID, traceID)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for SpanStored event")
+		}
+	}
+
+	select {
+	case evt := <-completed.C():
+		if evt.Trace.TraceID != traceID {
+			t.Errorf("completed trace_id = %s, want %s", evt.Trace.TraceID, traceID)
+		}
+		if len(evt.Trace.Spans) != 2 {
+			t.Errorf("completed trace has %d spans, want 2", len(evt.Trace.Spans))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for TraceCompleted event")
+	}
+}
+
+func TestEvents_FlushPendingOnStop(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	config := &Config{Workers: 1, ChannelBuffer: 10, TraceIdleTimeout: time.Hour}
+	col := NewCollector(store, config, slog.Default())
+
+	completed := col.Events().Subscribe(10, events.TraceCompleted)
+
+	ctx := context.Background()
+	col.Start(ctx)
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+	}
+	col.SubmitSpan(span)
+
+	if err := col.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	evt, ok := <-completed.C()
+	if !ok || evt.Trace.TraceID != span.TraceID {
+		t.Fatal("pending trace was not published as completed on Stop")
+	}
+	if _, ok := <-completed.C(); ok {
+		t.Error("subscription should be closed after Stop")
+	}
+}
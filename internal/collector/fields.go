@@ -0,0 +1,75 @@
+package collector
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// traceFields maps each selectable field name to its value on a trace.
+// Names match the Trace JSON keys; span_count is derived.
+var traceFields = map[string]func(*models.Trace) interface{}{
+	"trace_id":       func(t *models.Trace) interface{} { return t.TraceID },
+	"spans":          func(t *models.Trace) interface{} { return t.Spans },
+	"span_count":     func(t *models.Trace) interface{} { return len(t.Spans) },
+	"start_time":     func(t *models.Trace) interface{} { return t.StartTime },
+	"duration":       func(t *models.Trace) interface{} { return t.Duration },
+	"services":       func(t *models.Trace) interface{} { return t.Services },
+	"in_progress":    func(t *models.Trace) interface{} { return t.InProgress },
+	"expires_at":     func(t *models.Trace) interface{} { return t.ExpiresAt },
+	"deployments":    func(t *models.Trace) interface{} { return t.Deployments },
+	"total_cost":     func(t *models.Trace) interface{} { return t.TotalCost },
+	"cost_breakdown": func(t *models.Trace) interface{} { return t.CostBreakdown },
+	"currency":       func(t *models.Trace) interface{} { return t.Currency },
+	"truncation":     func(t *models.Trace) interface{} { return t.Truncation },
+	"regions":        func(t *models.Trace) interface{} { return t.Regions },
+}
+
+// parseFields parses a comma-separated fields parameter. It returns nil when
+// the parameter is empty (all fields) and the unknown names, if any.
+func parseFields(value string) (fields []string, unknown []string) {
+	if value == "" {
+		return nil, nil
+	}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := traceFields[name]; !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields, unknown
+}
+
+// selectableTraceFields returns the sorted field names for error messages.
+func selectableTraceFields() []string {
+	names := make([]string, 0, len(traceFields))
+	for name := range traceFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// projectTraces reduces traces to the selected fields. Without a selection
+// the traces are returned unchanged.
+func projectTraces(traces []*models.Trace, fields []string) interface{} {
+	if len(fields) == 0 {
+		return traces
+	}
+
+	result := make([]map[string]interface{}, len(traces))
+	for i, trace := range traces {
+		projected := make(map[string]interface{}, len(fields))
+		for _, name := range fields {
+			projected[name] = traceFields[name](trace)
+		}
+		result[i] = projected
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Fragmentation report defaults: recent traces scanned when the request does
+// not say otherwise, and example trace IDs listed per service.
+const (
+	defaultFragmentationLookback = time.Hour
+	defaultFragmentationScan     = 1000
+	fragmentationExamples        = 5
+)
+
+// FragmentationReport lists traces broken by lost context propagation: spans
+// whose parent never arrived, and traces with no root span.
+type FragmentationReport struct {
+	TracesScanned    int                      `json:"traces_scanned"`
+	FragmentedTraces int                      `json:"fragmented_traces"` // Traces with at least one orphan span
+	RootlessTraces   int                      `json:"rootless_traces"`   // Traces where every span has a parent ID
+	Services         []ServiceFragmentation   `json:"services"`          // Most orphan spans first
+	Window           FragmentationReportRange `json:"window"`
+}
+
+// FragmentationReportRange is the time range scanned.
+type FragmentationReportRange struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ServiceFragmentation counts one service's spans that reference a parent
+// missing from their trace. An orphan usually means the caller did not send
+// trace context, or the service did not read it.
+type ServiceFragmentation struct {
+	Service          string   `json:"service"`
+	OrphanSpans      int      `json:"orphan_spans"`
+	FragmentedTraces int      `json:"fragmented_traces"`
+	ExampleTraceIDs  []string `json:"example_trace_ids"`
+}
+
+// analyzeFragmentation finds orphan spans in traces and groups them by service.
+func analyzeFragmentation(traces []*models.Trace) FragmentationReport {
+	report := FragmentationReport{TracesScanned: len(traces), Services: []ServiceFragmentation{}}
+	byService := make(map[string]*ServiceFragmentation)
+
+	for _, trace := range traces {
+		spanIDs := make(map[string]bool, len(trace.Spans))
+		for _, span := range trace.Spans {
+			spanIDs[span.SpanID] = true
+		}
+
+		hasRoot := false
+		orphansByService := make(map[string]int)
+		for _, span := range trace.Spans {
+			switch {
+			case span.ParentSpanID == "":
+				hasRoot = true
+			case !spanIDs[span.ParentSpanID]:
+				orphansByService[span.ServiceName]++
+			}
+		}
+		if !hasRoot && len(trace.Spans) > 0 {
+			report.RootlessTraces++
+		}
+		if len(orphansByService) == 0 {
+			continue
+		}
+
+		report.FragmentedTraces++
+		for service, orphans := range orphansByService {
+			stats, ok := byService[service]
+			if !ok {
+				stats = &ServiceFragmentation{Service: service, ExampleTraceIDs: []string{}}
+				byService[service] = stats
+			}
+			stats.OrphanSpans += orphans
+			stats.FragmentedTraces++
+			if len(stats.ExampleTraceIDs) < fragmentationExamples {
+				stats.ExampleTraceIDs = append(stats.ExampleTraceIDs, trace.TraceID)
+			}
+		}
+	}
+
+	for _, stats := range byService {
+		report.Services = append(report.Services, *stats)
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		a, b := report.Services[i], report.Services[j]
+		if a.OrphanSpans != b.OrphanSpans {
+			return a.OrphanSpans > b.OrphanSpans
+		}
+		return a.Service < b.Service
+	})
+	return report
+}
+
+// HandleFragmentation handles GET /api/v1/diagnostics/fragmentation - report
+// spans referencing unknown parents, per service. It accepts the filters of
+// GET /api/v1/traces; by default the last hour's traces are scanned, up to
+// 1000. Traces that may still be receiving spans are skipped, since parents
+// are usually reported after their children.
+func (c *Collector) HandleFragmentation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	query, errs := c.parseQuery(r)
+	if len(errs) > 0 && isStrict(r) {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	params := r.URL.Query()
+	now := time.Now()
+	if params.Get("limit") == "" {
+		query.Limit = defaultFragmentationScan
+	}
+	if query.StartTime.IsZero() {
+		query.StartTime = now.Add(-defaultFragmentationLookback)
+	}
+	if settled := now.Add(-c.traceIdleTimeout); query.EndTime.IsZero() || query.EndTime.After(settled) {
+		query.EndTime = settled
+	}
+
+	traces, err := c.store.FindTraces(r.Context(), query)
+	if err != nil {
+		c.logger.Error("failed to find traces for fragmentation report", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	settled := make([]*models.Trace, 0, len(traces))
+	for _, trace := range traces {
+		if !trace.InProgress && !trace.StartTime.Add(trace.Duration).After(query.EndTime) {
+			settled = append(settled, trace)
+		}
+	}
+
+	report := analyzeFragmentation(settled)
+	report.Window = FragmentationReportRange{Start: query.StartTime, End: query.EndTime}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
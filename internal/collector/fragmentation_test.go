@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func fragmentSpan(traceID, spanID, parentID, service string, start time.Time) models.Span {
+	return models.Span{
+		TraceID:       traceID,
+		SpanID:        spanID,
+		ParentSpanID:  parentID,
+		ServiceName:   service,
+		OperationName: "op",
+		StartTime:     start,
+		Duration:      10 * time.Millisecond,
+		Status:        "ok",
+	}
+}
+
+func TestAnalyzeFragmentation(t *testing.T) {
+	start := time.Now().Add(-time.Minute)
+	complete := &models.Trace{TraceID: "complete", Spans: []models.Span{
+		fragmentSpan("complete", "a", "", "api", start),
+		fragmentSpan("complete", "b", "a", "db", start),
+	}}
+	// "payments" lost the context api sent, so its spans point at nothing
+	broken := &models.Trace{TraceID: "broken", Spans: []models.Span{
+		fragmentSpan("broken", "a", "", "api", start),
+		fragmentSpan("broken", "b", "missing", "payments", start),
+		fragmentSpan("broken", "c", "missing", "payments", start),
+		fragmentSpan("broken", "d", "gone", "queue", start),
+	}}
+	rootless := &models.Trace{TraceID: "rootless", Spans: []models.Span{
+		fragmentSpan("rootless", "a", "missing", "payments", start),
+	}}
+
+	report := analyzeFragmentation([]*models.Trace{complete, broken, rootless})
+	if report.TracesScanned != 3 || report.FragmentedTraces != 2 || report.RootlessTraces != 1 {
+		t.Errorf("report totals = %+v", report)
+	}
+	if len(report.Services) != 2 {
+		t.Fatalf("services = %+v, want payments and queue", report.Services)
+	}
+	payments := report.Services[0]
+	if payments.Service != "payments" || payments.OrphanSpans != 3 || payments.FragmentedTraces != 2 {
+		t.Errorf("payments = %+v", payments)
+	}
+	if len(payments.ExampleTraceIDs) != 2 || payments.ExampleTraceIDs[0] != "broken" {
+		t.Errorf("payments examples = %v", payments.ExampleTraceIDs)
+	}
+	if queue := report.Services[1]; queue.Service != "queue" || queue.OrphanSpans != 1 {
+		t.Errorf("queue = %+v", queue)
+	}
+}
+
+func TestHandleFragmentation_SkipsRecentTraces(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, TraceIdleTimeout: time.Minute}, slog.Default())
+	ctx := context.Background()
+
+	// An old orphan is reported; a recent one may still get its parent
+	old := time.Now().Add(-10 * time.Minute)
+	orphan := fragmentSpan(models.GenerateTraceID(), models.GenerateSpanID(), models.GenerateSpanID(), "payments", old)
+	recent := fragmentSpan(models.GenerateTraceID(), models.GenerateSpanID(), models.GenerateSpanID(), "payments", time.Now())
+	for _, span := range []*models.Span{&orphan, &recent} {
+		if err := store.WriteSpan(ctx, span); err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleFragmentation(rec, httptest.NewRequest(http.MethodGet, "/api/v1/diagnostics/fragmentation", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+
+	var report FragmentationReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if report.TracesScanned != 1 || report.FragmentedTraces != 1 {
+		t.Errorf("report = %+v, want only the old trace", report)
+	}
+	if len(report.Services) != 1 || report.Services[0].ExampleTraceIDs[0] != orphan.TraceID {
+		t.Errorf("services = %+v", report.Services)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleFragmentation(rec, httptest.NewRequest(http.MethodGet, "/api/v1/diagnostics/fragmentation?lookback=soon", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid lookback: status = %d, want 400", rec.Code)
+	}
+}
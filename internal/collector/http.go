@@ -3,32 +3,99 @@ package collector
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/saintparish4/asmbly/internal/models"
+	"github.com/saintparish4/asmbly/internal/archive"
+	"github.com/saintparish4/asmbly/internal/cost"
+	"github.com/saintparish4/asmbly/internal/events"
+	"github.com/saintparish4/asmbly/internal/plugin"
 	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/internal/wal"
+	"github.com/saintparish4/asmbly/models"
 )
 
 // Collector receives and processes spans using a worker pool pattern
 // It provides HTTP endpoints for span ingestion and trace querying
 type Collector struct {
 	store   storage.Store
-	spanCh  chan *models.Span // Buffered channel for async processing
-	workers int               // Number of worker goroutines
-	wg      sync.WaitGroup    // Wait for workers to finish
+	spanCh  chan queuedSpan // Buffered channel for async processing
+	workers int             // Number of worker goroutines
+	wg      sync.WaitGroup  // Wait for workers to finish
 
 	// Metrics
-	metrics *Metrics
+	metrics      *Metrics
+	queryMetrics *QueryMetrics
+	redMetrics   *REDMetrics      // Derived from stored spans (see red_metrics.go)
+	deployments  *Deployments     // Per-deployment stats of stored spans (see deployments.go)
+	ingestLag    *IngestLag       // Delay from span end to storage per service (see ingest_lag.go)
+	pipeline     *pipelineMetrics // Latency histograms (see prometheus.go)
 
-	// Lifecycle
-	stopCh chan struct{}
-	logger *slog.Logger
+	// Optional FindTraces result cache (nil = disabled)
+	queryCache *queryCache
+
+	// Scheduled queries served from memory (see materialized.go)
+	materialized []*materializedView
+
+	// Optional write-ahead log of accepted spans (see wal.go)
+	wal *wal.Log
+
+	// What SubmitSpan does when the queue is full (see queue_full.go)
+	queueFullPolicy  QueueFullPolicy
+	queueFullTimeout time.Duration
+	spill            *wal.Log
+	spillWg          sync.WaitGroup
+
+	// Discard whole traces under overload (see shed.go)
+	loadShedding bool
+
+	// Service dependency graph tracker, nil when disabled (see topology.go)
+	topology *Topology
+
+	// Open GET /api/v1/traces/stream clients (see stream.go)
+	traceStreams int32
+
+	// Internal pub/sub for extensions (see events.go)
+	events           *events.Bus
+	traceIdleTimeout time.Duration
+	pendingMu        sync.Mutex
+	pending          map[string]time.Time // traceID -> last span stored
+	sweepWg          sync.WaitGroup
+
+	// JSON ingestion endpoints, shared with HTTP receivers
+	ingest *ingestHandler
+
+	// Plugins
+	processors []plugin.Processor
+	exporters  []plugin.Exporter
+	exportWg   sync.WaitGroup
+
+	// Sample rates served to SDKs, nil to keep every trace (see sampling.go)
+	sampling *SamplingConfig
+
+	// Failure injection for soak tests, nil when disabled (see chaos.go)
+	chaos *chaos
+
+	// Prices spans from configured rules, nil to keep client costs (see costs.go)
+	pricing *cost.Pricer
+
+	// Optional archive of completed traces (see archive.go)
+	archive   *archive.Archiver
+	archiveWg sync.WaitGroup
+
+	// Lifecycle (see lifecycle.go)
+	lifecycle lifecycle
+	stopCh    chan struct{}
+	logger    *slog.Logger
 }
 
 // Metrics tracks collector statistics
@@ -36,20 +103,117 @@ type Metrics struct {
 	SpansReceived int64
 	SpansStored   int64
 	SpanErrors    int64
-	mu            sync.Mutex
+	SpansDropped  int64 // Dropped by processors
+	SpansShed     int64 // Discarded by load shedding
+	SpansSpilled  int64 // Written to disk while the queue was full
+
+	// Ingestion requests being handled, those refused by the
+	// concurrency limit and those refused by the per-client rate limit
+	IngestInFlight    int64
+	IngestRejected    int64
+	IngestRateLimited int64
+
+	// Batch submissions answered from the idempotency cache
+	IngestReplayed int64
+
+	mu sync.Mutex
 }
 
 // Config holds collector configuration.
 type Config struct {
 	Workers       int
 	ChannelBuffer int
+
+	// TraceIdleTimeout is how long a trace must go without new spans before
+	// a TraceCompleted event is published (0 = DefaultTraceIdleTimeout)
+	TraceIdleTimeout time.Duration
+
+	// Processors run in order on every span before storage
+	Processors []plugin.Processor
+
+	// Exporters receive every stored span
+	Exporters []plugin.Exporter
+
+	// QueryCacheSize enables an LRU cache of FindTraces results with this
+	// many entries (0 = disabled); entries live for QueryCacheTTL
+	// (0 = DefaultQueryCacheTTL)
+	QueryCacheSize int
+	QueryCacheTTL  time.Duration
+
+	// Materialized queries are refreshed in the background and served from
+	// memory at /api/v1/materialized/:name
+	Materialized []MaterializedSpec
+
+	// ApdexThreshold is the span duration up to which requests count as
+	// satisfied in per-operation Apdex scores (0 = DefaultApdexThreshold)
+	ApdexThreshold time.Duration
+
+	// TrackTopology derives the service dependency graph from completed
+	// traces and records edges appearing or disappearing; an edge unseen
+	// for TopologyEdgeTTL (0 = DefaultTopologyEdgeTTL) counts as removed
+	TrackTopology   bool
+	TopologyEdgeTTL time.Duration
+
+	// MaxInFlightRequests caps concurrent ingestion requests; more are
+	// refused with 503 before their bodies are read (0 = unlimited)
+	MaxInFlightRequests int
+
+	// Origin tags spans submitted over HTTP with the client that sent them
+	Origin OriginConfig
+
+	// RateLimit caps the spans each client or tenant may submit over HTTP;
+	// excess requests are refused with 429
+	RateLimit RateLimitConfig
+
+	// IdempotencyTTL is how long batch responses are remembered by
+	// Idempotency-Key, so retried batches are not ingested twice
+	// (0 = disabled)
+	IdempotencyTTL time.Duration
+
+	// LoadShedding discards whole traces, by trace ID, at the throttle
+	// hint's sample rate while the span queue is nearly full
+	LoadShedding bool
+
+	// WAL, if set, records accepted spans until they are processed and is
+	// replayed by Start. The caller opens and closes it.
+	WAL *wal.Log
+
+	// QueueFull is what SubmitSpan does with a span that finds the queue
+	// full (default QueueFullDrop). QueueFullBlock waits up to
+	// QueueFullTimeout (0 = DefaultQueueFullTimeout); QueueFullSpill
+	// appends the span to Spill, which the caller opens and closes.
+	QueueFull        QueueFullPolicy
+	QueueFullTimeout time.Duration
+	Spill            *wal.Log
+
+	// Sampling is served to SDKs at /api/v1/sampling (nil = keep every trace)
+	Sampling *SamplingConfig
+
+	// Archive, if set, receives every completed trace and is stopped, after
+	// writing what it buffered, by Stop
+	Archive *archive.Archiver
+
+	// Pricing, if set, assigns span costs before storage
+	Pricing *cost.Pricer
+
+	// EnableChaos allows failure injection to be switched on at
+	// /api/v1/admin/chaos; it is off until a spec is set there
+	EnableChaos bool
+
+	// ReadyQueueThreshold is the fraction of the span queue in use at which
+	// /readyz reports not ready (0 = DefaultReadyQueueThreshold)
+	ReadyQueueThreshold float64
 }
 
+// DefaultTraceIdleTimeout is the default quiet period before a trace is considered complete.
+const DefaultTraceIdleTimeout = 5 * time.Second
+
 // DefaultConfig returns sensible defaults.
 func DefaultConfig() *Config {
 	return &Config{
-		Workers:       10,
-		ChannelBuffer: 1000,
+		Workers:          10,
+		ChannelBuffer:    1000,
+		TraceIdleTimeout: DefaultTraceIdleTimeout,
 	}
 }
 
@@ -62,14 +226,70 @@ func NewCollector(store storage.Store, config *Config, logger *slog.Logger) *Col
 		logger = slog.Default()
 	}
 
-	return &Collector{
-		store:   store,
-		spanCh:  make(chan *models.Span, config.ChannelBuffer),
-		workers: config.Workers,
-		metrics: &Metrics{},
-		stopCh:  make(chan struct{}),
-		logger:  logger,
-	}
+	idleTimeout := config.TraceIdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = DefaultTraceIdleTimeout
+	}
+
+	c := &Collector{
+		store:            store,
+		spanCh:           make(chan queuedSpan, config.ChannelBuffer),
+		workers:          config.Workers,
+		metrics:          &Metrics{},
+		queryMetrics:     newQueryMetrics(),
+		redMetrics:       newREDMetrics(),
+		deployments:      newDeployments(),
+		ingestLag:        newIngestLag(),
+		pipeline:         newPipelineMetrics(),
+		events:           events.NewBus(),
+		traceIdleTimeout: idleTimeout,
+		pending:          make(map[string]time.Time),
+		processors:       config.Processors,
+		exporters:        config.Exporters,
+		wal:              config.WAL,
+		queueFullPolicy:  config.QueueFull,
+		queueFullTimeout: config.QueueFullTimeout,
+		spill:            config.Spill,
+		loadShedding:     config.LoadShedding,
+		sampling:         config.Sampling,
+		archive:          config.Archive,
+		pricing:          config.Pricing,
+		stopCh:           make(chan struct{}),
+		logger:           logger,
+	}
+	c.lifecycle.draining = make(chan struct{})
+	c.lifecycle.queueThreshold = config.ReadyQueueThreshold
+	if c.lifecycle.queueThreshold <= 0 {
+		c.lifecycle.queueThreshold = DefaultReadyQueueThreshold
+	}
+	c.ingest = newIngestHandler(c, config.MaxInFlightRequests, config.Origin, logger)
+	c.ingest.rateLimit = newRateLimiter(config.RateLimit)
+	if config.IdempotencyTTL > 0 {
+		c.ingest.idempotency = newIdempotencyCache(config.IdempotencyTTL)
+	}
+	if config.QueryCacheSize > 0 {
+		c.queryCache = newQueryCache(config.QueryCacheSize, config.QueryCacheTTL)
+	}
+	c.materialized = newMaterializedViews(config.Materialized, logger)
+	if config.ApdexThreshold > 0 {
+		c.redMetrics.apdexThreshold = config.ApdexThreshold
+	}
+	if config.TrackTopology {
+		c.topology = newTopology(config.TopologyEdgeTTL)
+	}
+	if config.EnableChaos {
+		c.chaos = newChaos()
+		c.ingest.chaos = c.chaos
+	}
+	if c.queueFullTimeout <= 0 {
+		c.queueFullTimeout = DefaultQueueFullTimeout
+	}
+	if c.queueFullPolicy == QueueFullSpill && c.spill == nil {
+		logger.Warn("queue full policy spill needs a spill log, refusing spans instead")
+		c.queueFullPolicy = QueueFullDrop
+	}
+
+	return c
 }
 
 // Start begins processing spans with worker goroutines.
@@ -79,18 +299,40 @@ func (c *Collector) Start(ctx context.Context) {
 
 	for i := 0; i < c.workers; i++ {
 		c.wg.Add(1)
+		c.lifecycle.workersStarted.Add(1)
+		c.lifecycle.workersRunning.Add(1)
 		go c.spanWorker(ctx, i)
 	}
+
+	c.sweepWg.Add(1)
+	go c.completionSweeper(ctx)
+	c.startMaterialized(ctx)
+
+	c.startExporters(ctx)
+	c.startTopology()
+	c.startArchive()
+
+	// Spans accepted before a crash are processed before new ones are accepted
+	c.replayWAL()
+	if c.queueFullPolicy == QueueFullSpill {
+		c.spillWg.Add(1)
+		go c.spillDrainer()
+	}
+
+	c.setState(StateReady)
 }
 
 // Stop gracefully shuts down the collector, waiting for in-flight spans to complete.
 func (c *Collector) Stop(ctx context.Context) error {
 	c.logger.Info("stopping collector")
+	c.setState(StateDraining)
 
 	// Signal workers to stop
 	close(c.stopCh)
 
-	// Close span channel (no more incoming spans)
+	// Close span channel (no more incoming spans) once the spill drainer
+	// stopped sending; spans still spilled wait for the next start
+	c.spillWg.Wait()
 	close(c.spanCh)
 
 	// Wait for workers to finish processing remaining spans
@@ -108,12 +350,22 @@ func (c *Collector) Stop(ctx context.Context) error {
 		return ctx.Err()
 	}
 
-	return nil
+	// Publish completion for traces still pending, then release subscribers
+	c.sweepWg.Wait()
+	c.flushPendingTraces(ctx)
+	c.events.Close()
+
+	// Exporters and the archive drain their subscriptions once the bus is
+	// closed
+	err := errors.Join(c.stopExporters(ctx), c.stopArchive(ctx))
+	c.setState(StateStopped)
+	return err
 }
 
 // spanWorker processes spans from the channel.
 func (c *Collector) spanWorker(ctx context.Context, id int) {
 	defer c.wg.Done()
+	defer c.lifecycle.workersRunning.Add(-1)
 
 	c.logger.Debug("worker started", "worker_id", id)
 
@@ -122,26 +374,12 @@ func (c *Collector) spanWorker(ctx context.Context, id int) {
 		case <-c.stopCh:
 			// Shutdown requested - drain remaining spans from channel
 			c.logger.Debug("worker draining remaining spans", "worker_id", id)
-			for span := range c.spanCh {
-				if err := c.processSpan(ctx, span); err != nil {
-					c.logger.Error("failed to process span",
-						"worker_id", id,
-						"trace_id", span.TraceID,
-						"span_id", span.SpanID,
-						"error", err,
-					)
-					c.metrics.mu.Lock()
-					c.metrics.SpanErrors++
-					c.metrics.mu.Unlock()
-				} else {
-					c.metrics.mu.Lock()
-					c.metrics.SpansStored++
-					c.metrics.mu.Unlock()
-				}
+			for item := range c.spanCh {
+				c.handleSpan(ctx, id, item)
 			}
 			c.logger.Debug("worker stopped", "worker_id", id)
 			return
-		case span, ok := <-c.spanCh:
+		case item, ok := <-c.spanCh:
 			if !ok {
 				// Channel closed
 				c.logger.Debug("worker exiting (channel closed)", "worker_id", id)
@@ -149,65 +387,175 @@ func (c *Collector) spanWorker(ctx context.Context, id int) {
 			}
 
 			// Process span
-			if err := c.processSpan(ctx, span); err != nil {
-				c.logger.Error("failed to process span",
-					"worker_id", id,
-					"trace_id", span.TraceID,
-					"span_id", span.SpanID,
-					"error", err,
-				)
-				c.metrics.mu.Lock()
-				c.metrics.SpanErrors++
-				c.metrics.mu.Unlock()
-			} else {
-				c.metrics.mu.Lock()
-				c.metrics.SpansStored++
-				c.metrics.mu.Unlock()
-			}
+			c.handleSpan(ctx, id, item)
 		}
 	}
 }
 
+// handleSpan processes a span, records the outcome in metrics and releases
+// its write-ahead log record.
+func (c *Collector) handleSpan(ctx context.Context, workerID int, item queuedSpan) {
+	span := item.span
+	start := time.Now()
+	err := c.processSpan(ctx, span)
+	c.pipeline.workerDuration.Observe(time.Since(start).Seconds())
+	c.lifecycle.lastProcessed.Store(time.Now().UnixNano())
+	c.ackWAL(item.wal)
+
+	c.metrics.mu.Lock()
+	defer c.metrics.mu.Unlock()
+
+	switch {
+	case errors.Is(err, errSpanDropped):
+		c.metrics.SpansDropped++
+	case err != nil:
+		c.logger.Error("failed to process span",
+			"worker_id", workerID,
+			"trace_id", span.TraceID,
+			"span_id", span.SpanID,
+			"error", err,
+		)
+		c.metrics.SpanErrors++
+	default:
+		c.metrics.SpansStored++
+	}
+}
+
 // processSpan validates and stores a single span.
 func (c *Collector) processSpan(ctx context.Context, span *models.Span) error {
+	if normalizeSpan(span) {
+		c.logger.Debug("clamped negative span duration",
+			"trace_id", span.TraceID, "span_id", span.SpanID, "service", span.ServiceName,
+			"duration", span.GetTag(NegativeDurationTag))
+	}
+
 	// Validate span (storage will also validate, but fail fast here)
 	if err := span.Validate(); err != nil {
 		return fmt.Errorf("invalid span: %w", err)
 	}
 
+	// Run processor plugins
+	span, err := c.runProcessors(ctx, span)
+	if err != nil {
+		return err
+	}
+	if span == nil {
+		return errSpanDropped
+	}
+
+	// Attribute cost after processors have fixed up the tags it reads
+	if c.pricing != nil {
+		c.pricing.Apply(span)
+	}
+
+	// Injected failures stand in for the store's own
+	if err := c.chaos.beforeWrite(ctx); errors.Is(err, errSpanDropped) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("failed to store span: %w", err)
+	}
+
 	// Store span
-	if err := c.store.WriteSpan(ctx, span); err != nil {
+	start := time.Now()
+	err = c.store.WriteSpan(ctx, span)
+	c.pipeline.storageDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
 		return fmt.Errorf("failed to store span: %w", err)
 	}
 
+	c.redMetrics.Observe(span)
+	c.deployments.Observe(span)
+	c.ingestLag.Observe(span)
+
+	// Drop cached query results this span could change
+	if c.queryCache != nil {
+		c.queryCache.invalidate(span, func(service string) bool {
+			trace, err := c.store.GetTrace(ctx, span.TraceID)
+			if err != nil || trace == nil {
+				return true // Unknown: assume it could match
+			}
+			return slices.Contains(trace.Services, service)
+		})
+	}
+
+	// Notify extensions
+	c.publishSpanStored(span)
+
 	return nil
 }
 
-// SubmitSpan adds a span to the processing queue.
-// This is non-blocking - the span is processed asynchronously by workers.
+// SubmitSpan adds a span to the processing queue; the span is processed
+// asynchronously by workers. When the queue is full, the queue full policy
+// decides whether it is refused, waited for or spilled to disk.
 func (c *Collector) SubmitSpan(span *models.Span) error {
-	select {
-	case c.spanCh <- span:
+	if err := stateError(c.State()); err != nil {
+		return err
+	}
+
+	if c.shouldShed(span.TraceID) {
 		c.metrics.mu.Lock()
-		c.metrics.SpansReceived++
+		c.metrics.SpansShed++
 		c.metrics.mu.Unlock()
+		return nil // Accepted; the throttle hint asks the client to sample
+	}
+
+	item := queuedSpan{span: span}
+	if c.wal != nil {
+		if len(c.spanCh) >= cap(c.spanCh) {
+			return c.queueFull(span) // Don't log a span about to be refused
+		}
+		pos, err := c.appendWAL(span)
+		if err != nil {
+			return err
+		}
+		item.wal = pos
+	}
+
+	select {
+	case c.spanCh <- item:
+		c.spanQueued()
 		return nil
 	case <-c.stopCh:
-		return fmt.Errorf("collector is stopping")
+		c.ackWAL(item.wal)
+		return ErrDraining
 	default:
 		// Channel full - this is a backpressure signal
-		return fmt.Errorf("span queue full, try again later")
+		c.ackWAL(item.wal)
+		return c.queueFull(span)
+	}
+}
+
+// spanQueued records a span entering the queue.
+func (c *Collector) spanQueued() {
+	c.metrics.mu.Lock()
+	c.metrics.SpansReceived++
+	c.metrics.mu.Unlock()
+	if capacity := cap(c.spanCh); capacity > 0 {
+		c.pipeline.queueFill.Observe(float64(len(c.spanCh)) / float64(capacity))
 	}
 }
 
+// QueryMetrics returns the query API performance metrics.
+func (c *Collector) QueryMetrics() *QueryMetrics {
+	return c.queryMetrics
+}
+
 // GetMetrics returns a snapshot of current metrics.
 func (c *Collector) GetMetrics() Metrics {
 	c.metrics.mu.Lock()
 	defer c.metrics.mu.Unlock()
 	return Metrics{
-		SpansReceived: c.metrics.SpansReceived,
-		SpansStored:   c.metrics.SpansStored,
-		SpanErrors:    c.metrics.SpanErrors,
+		SpansReceived:  c.metrics.SpansReceived,
+		SpansStored:    c.metrics.SpansStored,
+		SpanErrors:     c.metrics.SpanErrors,
+		SpansDropped:   c.metrics.SpansDropped,
+		SpansShed:      c.metrics.SpansShed,
+		SpansSpilled:   c.metrics.SpansSpilled,
+		IngestInFlight: c.ingest.inFlight.Load(),
+		IngestRejected: c.ingest.rejected.Load(),
+
+		IngestRateLimited: c.ingest.rateLimited.Load(),
+		IngestReplayed:    c.ingest.replayed.Load(),
 	}
 }
 
@@ -215,158 +563,207 @@ func (c *Collector) GetMetrics() Metrics {
 
 // HandlePostSpan handles POST /api/v1/spans - submit a single span.
 func (c *Collector) HandlePostSpan(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Read and parse span
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		c.logger.Error("failed to read request body", "error", err)
-		http.Error(w, "failed to read body", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
-
-	var span models.Span
-	if err := json.Unmarshal(body, &span); err != nil {
-		c.logger.Error("failed to parse span JSON", "error", err)
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
-
-	// Submit span
-	if err := c.SubmitSpan(&span); err != nil {
-		c.logger.Error("failed to submit span", "error", err)
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
-		return
-	}
-
-	// Success
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusAccepted)
-	json.NewEncoder(w).Encode(map[string]string{
-		"status": "accepted",
-	})
+	c.timeIngest(ingestSpan, c.ingest.limit(c.ingest.handlePostSpan))(w, r)
 }
 
 // HandlePostSpansBatch handles POST /api/v1/spans/batch - submit multiple spans.
 func (c *Collector) HandlePostSpansBatch(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	// Read and parse spans
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		c.logger.Error("failed to read request body", "error", err)
-		http.Error(w, "failed to read body", http.StatusBadRequest)
-		return
-	}
-	defer r.Body.Close()
+	c.timeIngest(ingestBatch, c.ingest.limit(c.ingest.idempotent(c.ingest.handlePostSpansBatch)))(w, r)
+}
 
-	var spans []models.Span
-	if err := json.Unmarshal(body, &spans); err != nil {
-		c.logger.Error("failed to parse spans JSON", "error", err)
-		http.Error(w, "invalid JSON", http.StatusBadRequest)
-		return
-	}
+// HandleOTLPTraces handles POST /v1/traces - OTLP/HTTP trace export.
+func (c *Collector) HandleOTLPTraces(w http.ResponseWriter, r *http.Request) {
+	c.timeIngest(ingestOTLP, c.ingest.limit(c.ingest.handleOTLPTraces))(w, r)
+}
 
-	// Submit all spans
-	accepted := 0
-	failed := 0
-	for i := range spans {
-		if err := c.SubmitSpan(&spans[i]); err != nil {
-			c.logger.Warn("failed to submit span in batch",
-				"span_index", i,
-				"error", err,
-			)
-			failed++
-		} else {
-			accepted++
-		}
-	}
+// HandleZipkinSpans handles POST /api/v2/spans - Zipkin v2 JSON ingestion.
+func (c *Collector) HandleZipkinSpans(w http.ResponseWriter, r *http.Request) {
+	c.timeIngest(ingestZipkin, c.ingest.limit(c.ingest.handleZipkinSpans))(w, r)
+}
 
-	// Response
-	w.Header().Set("Content-Type", "application/json")
-	if failed > 0 {
-		w.WriteHeader(http.StatusPartialContent)
-	} else {
-		w.WriteHeader(http.StatusAccepted)
+// HandleGetTrace handles GET /api/v1/traces/:id - retrieve a trace by ID -
+// and GET /api/v1/traces/:id/critical-path.
+func (c *Collector) HandleGetTrace(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, apiV1.prefix()+"/traces/")
+	if traceID, ok := strings.CutSuffix(path, criticalPathSuffix); ok {
+		c.handleCriticalPath(w, r, traceID)
+		return
 	}
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"accepted": accepted,
-		"failed":   failed,
-		"total":    len(spans),
-	})
+	c.handleGetTrace(w, r, apiV1)
 }
 
-// HandleGetTrace handles GET /api/v1/traces/:id - retrieve a trace by ID.
-func (c *Collector) HandleGetTrace(w http.ResponseWriter, r *http.Request) {
+// handleGetTrace implements GET /api/{version}/traces/:id.
+func (c *Collector) handleGetTrace(w http.ResponseWriter, r *http.Request, version apiVersion) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Extract trace ID from path (simple parsing - no router needed)
-	traceID := r.URL.Path[len("/api/v1/traces/"):]
+	traceID := strings.TrimPrefix(r.URL.Path, version.prefix()+"/traces/")
 	if traceID == "" {
 		http.Error(w, "trace ID required", http.StatusBadRequest)
 		return
 	}
+	page, errs := parseSpanPage(r.URL.Query())
+	tree, formatErrs := parseTraceFormat(r.URL.Query(), version)
+	errs = append(errs, formatErrs...)
+	if len(errs) > 0 {
+		writeQueryErrors(w, errs)
+		return
+	}
 
 	// Get trace
+	start := time.Now()
 	trace, err := c.store.GetTrace(r.Context(), traceID)
 	if err != nil {
 		c.logger.Error("failed to get trace", "trace_id", traceID, "error", err)
+		c.queryMetrics.ObserveError(endpointGetTrace)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 
 	if trace == nil {
+		c.queryMetrics.Observe(endpointGetTrace, time.Since(start), 0)
 		http.Error(w, "trace not found", http.StatusNotFound)
 		return
 	}
 
 	// Success
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(trace)
+	if tree {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(traceTreeV1{Trace: trace, Tree: models.BuildTraceTree(trace)})
+		c.queryMetrics.Observe(endpointGetTrace, time.Since(start), 1)
+		return
+	}
+	spans := trace.Spans
+	if page != nil {
+		spans = page.apply(spans)
+		setLinkHeader(w, buildParamPageLinks(r, "span_offset", "span_limit", page.Offset, page.Limit, page.HasMore))
+	}
+	if err := writeTrace(w, version, trace, spans, page); err != nil {
+		c.logger.Warn("failed to write trace", "trace_id", traceID, "error", err)
+	}
+	c.queryMetrics.Observe(endpointGetTrace, time.Since(start), 1)
 }
 
 // HandleFindTraces handles GET /api/v1/traces - search traces with filters.
 func (c *Collector) HandleFindTraces(w http.ResponseWriter, r *http.Request) {
+	c.handleFindTraces(w, r, apiV1)
+}
+
+// handleFindTraces implements GET /api/{version}/traces. Both versions
+// share filters, execution and metrics; v1 pages by offset and returns
+// whole traces, v2 pages by cursor and returns summaries.
+func (c *Collector) handleFindTraces(w http.ResponseWriter, r *http.Request, version apiVersion) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
 
 	// Parse query parameters
-	query := c.parseQuery(r)
+	query, errs := c.parseQuery(r)
+	var fields []string
+	if version == apiV2 {
+		errs = append(errs, applyCursor(r.URL.Query(), query)...)
+		query.Projection = storage.ProjectMetadata // Summaries need no tags or events
+	} else {
+		var unknown []string
+		fields, unknown = parseFields(r.URL.Query().Get("fields"))
+		if len(unknown) > 0 {
+			errs = append(errs, QueryParamError{
+				Param:  "fields",
+				Value:  strings.Join(unknown, ","),
+				Reason: "unknown field; valid fields are " + strings.Join(selectableTraceFields(), ", "),
+			})
+		}
+		if len(fields) > 0 && !slices.Contains(fields, "spans") {
+			query.Projection = storage.ProjectMetadata
+		}
+	}
+	// v2 always rejects malformed parameters
+	if len(errs) > 0 && (version == apiV2 || isStrict(r)) {
+		writeQueryErrors(w, errs)
+		return
+	}
 
-	// Execute query
+	// Execute query, fetching one extra result to learn whether a next page exists
+	limit := query.Limit
+	if limit > 0 {
+		query.Limit++
+	}
 	start := time.Now()
-	traces, err := c.store.FindTraces(r.Context(), query)
+	traces, err := c.findTraces(r, query)
+	query.Limit = limit
 	if err != nil {
 		c.logger.Error("failed to find traces", "error", err)
+		c.queryMetrics.ObserveError(endpointFindTraces)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
 	duration := time.Since(start)
 
+	hasNext := limit > 0 && len(traces) > limit
+	if hasNext {
+		traces = traces[:limit]
+	}
+
 	c.logger.Debug("query executed",
 		"duration_ms", duration.Milliseconds(),
 		"results", len(traces),
 	)
 
+	if version == apiV2 {
+		writeFindTracesV2(w, r, traces, query, limit, hasNext)
+		c.queryMetrics.Observe(endpointFindTraces, time.Since(start), len(traces))
+		return
+	}
+
+	// Total matching traces; on the last page it is known without counting
+	total := query.Offset + len(traces)
+	if hasNext || (len(traces) == 0 && query.Offset > 0) {
+		if total, err = c.store.CountTraces(r.Context(), query); err != nil {
+			c.logger.Error("failed to count traces", "error", err)
+			c.queryMetrics.ObserveError(endpointFindTraces)
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// Pagination links
+	links := buildPageLinks(r, query.Offset, limit, hasNext)
+	setLinkHeader(w, links)
+
 	// Success
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"traces": traces,
-		"total":  len(traces),
+		"traces": projectTraces(traces, fields),
+		"total":  total,
 		"query":  query,
+		"links":  links,
 	})
+	c.queryMetrics.Observe(endpointFindTraces, time.Since(start), len(traces))
+}
+
+// findTraces runs query against the store, going through the result cache when enabled.
+func (c *Collector) findTraces(r *http.Request, query *storage.Query) ([]*models.Trace, error) {
+	if c.queryCache == nil {
+		return c.store.FindTraces(r.Context(), query)
+	}
+
+	key := queryCacheKey(r.URL.Query(), query.Projection)
+	if traces, ok := c.queryCache.get(key); ok {
+		c.queryMetrics.ObserveCache(endpointFindTraces, true)
+		return traces, nil
+	}
+	c.queryMetrics.ObserveCache(endpointFindTraces, false)
+
+	traces, err := c.store.FindTraces(r.Context(), query)
+	if err != nil {
+		return nil, err
+	}
+	c.queryCache.put(key, query, traces)
+	return traces, nil
 }
 
 // HandleGetServices handles GET /api/v1/services - list all services.
@@ -377,9 +774,11 @@ func (c *Collector) HandleGetServices(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get services
+	start := time.Now()
 	services, err := c.store.GetServices(r.Context())
 	if err != nil {
 		c.logger.Error("failed to get services", "error", err)
+		c.queryMetrics.ObserveError(endpointServices)
 		http.Error(w, "internal error", http.StatusInternalServerError)
 		return
 	}
@@ -390,66 +789,198 @@ func (c *Collector) HandleGetServices(w http.ResponseWriter, r *http.Request) {
 		"services": services,
 		"total":    len(services),
 	})
+	c.queryMetrics.Observe(endpointServices, time.Since(start), len(services))
+}
+
+// QueryParamError describes a query parameter that failed to parse.
+type QueryParamError struct {
+	Param  string `json:"param"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
 }
 
 // parseQuery parses URL query parameters into a storage.Query.
-func (c *Collector) parseQuery(r *http.Request) *storage.Query {
+// Malformed parameters are left unset and reported in the returned errors;
+// callers decide whether to reject the request.
+func (c *Collector) parseQuery(r *http.Request) (*storage.Query, []QueryParamError) {
+	return parseQueryParams(r.URL.Query())
+}
+
+// maxTagFilters caps the tag parameters of a search, each of which every
+// candidate trace is checked against.
+const maxTagFilters = 32
+
+// parseQueryParams implements parseQuery for already-decoded parameters.
+func parseQueryParams(params url.Values) (*storage.Query, []QueryParamError) {
 	query := storage.NewQuery()
+	var errs []QueryParamError
 
-	// Service filter
-	if service := r.URL.Query().Get("service"); service != "" {
-		query.Service = service
+	invalid := func(param, reason string) {
+		errs = append(errs, QueryParamError{Param: param, Value: params.Get(param), Reason: reason})
 	}
-
-	// Duration filters
-	if minDur := r.URL.Query().Get("min_duration"); minDur != "" {
-		if d, err := time.ParseDuration(minDur); err == nil {
-			query.MinDuration = d
+	parseDuration := func(param string) time.Duration {
+		value := params.Get(param)
+		if value == "" {
+			return 0
 		}
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			invalid(param, "must be a duration such as 100ms, 1.5s or 2m")
+			return 0
+		}
+		if d < 0 {
+			invalid(param, "must not be negative")
+			return 0
+		}
+		return d
+	}
+	parseFloat := func(param string) float64 {
+		value := params.Get(param)
+		if value == "" {
+			return 0
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			invalid(param, "must be a number")
+			return 0
+		}
+		return f
 	}
-	if maxDur := r.URL.Query().Get("max_duration"); maxDur != "" {
-		if d, err := time.ParseDuration(maxDur); err == nil {
-			query.MaxDuration = d
+	parseBool := func(param string) *bool {
+		value := params.Get(param)
+		if value == "" {
+			return nil
+		}
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			invalid(param, "must be true or false")
+			return nil
 		}
+		return &b
+	}
+
+	// Service filter
+	query.Service = params.Get("service")
+
+	// Duration filters
+	query.MinDuration = parseDuration("min_duration")
+	query.MaxDuration = parseDuration("max_duration")
+	if query.MinDuration > 0 && query.MaxDuration > 0 && query.MinDuration > query.MaxDuration {
+		invalid("min_duration", "must not be greater than max_duration")
 	}
 
 	// Cost filters (Week 3)
-	if minCost := r.URL.Query().Get("min_cost"); minCost != "" {
-		if f, err := strconv.ParseFloat(minCost, 64); err == nil {
-			query.MinCost = f
+	query.MinCost = parseFloat("min_cost")
+	query.MaxCost = parseFloat("max_cost")
+
+	// Time range filters
+	now := time.Now()
+	loc := time.UTC
+	if tz := params.Get("tz"); tz != "" {
+		if l, err := time.LoadLocation(tz); err == nil {
+			loc = l
+		} else {
+			invalid("tz", "must be an IANA time zone such as America/New_York")
 		}
 	}
-	if maxCost := r.URL.Query().Get("max_cost"); maxCost != "" {
-		if f, err := strconv.ParseFloat(maxCost, 64); err == nil {
-			query.MaxCost = f
+	for _, param := range []string{"start_time", "end_time"} {
+		value := params.Get(param)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value, now, loc)
+		if err != nil {
+			invalid(param, "must be RFC3339, epoch millis or relative (e.g. -1h)")
+			continue
+		}
+		if param == "start_time" {
+			query.StartTime = t
+		} else {
+			query.EndTime = t
 		}
 	}
 
-	// Time range filters
-	if startTime := r.URL.Query().Get("start_time"); startTime != "" {
-		if t, err := time.Parse(time.RFC3339, startTime); err == nil {
-			query.StartTime = t
+	// lookback=30m is shorthand for start_time=-30m
+	if lookback := parseDuration("lookback"); lookback > 0 && query.StartTime.IsZero() {
+		query.StartTime = now.Add(-lookback)
+	}
+	if !query.StartTime.IsZero() && !query.EndTime.IsZero() && query.StartTime.After(query.EndTime) {
+		invalid("start_time", "must not be after end_time")
+	}
+
+	// Tag filters: tag=key:value matches a value, tag=key any value
+	if len(params["tag"]) > maxTagFilters {
+		errs = append(errs, QueryParamError{Param: "tag", Reason: fmt.Sprintf("at most %d tag filters are allowed", maxTagFilters)})
+	}
+	for _, tag := range params["tag"] {
+		key, value, _ := strings.Cut(tag, ":")
+		if key == "" {
+			errs = append(errs, QueryParamError{Param: "tag", Value: tag, Reason: "must be key:value or key"})
+			continue
 		}
+		query.WithTag(key, value)
 	}
-	if endTime := r.URL.Query().Get("end_time"); endTime != "" {
-		if t, err := time.Parse(time.RFC3339, endTime); err == nil {
-			query.EndTime = t
+
+	// Error filter
+	if errorsOnly := parseBool("errors_only"); errorsOnly != nil {
+		query.ErrorsOnly = *errorsOnly
+	}
+
+	// Partial trace filter
+	query.InProgress = parseBool("in_progress")
+
+	// Retention filter
+	query.MinTTL = parseDuration("min_ttl")
+
+	// Sorting
+	if sortBy := params.Get("sort"); sortBy != "" {
+		if slices.Contains(storage.SortKeys(), sortBy) {
+			query.SortBy = sortBy
+		} else {
+			invalid("sort", "must be one of "+strings.Join(storage.SortKeys(), ", "))
 		}
 	}
+	switch order := params.Get("order"); order {
+	case "", storage.SortAsc, storage.SortDesc:
+		query.SortOrder = order
+	default:
+		invalid("order", "must be asc or desc")
+	}
 
 	// Pagination
-	if limit := r.URL.Query().Get("limit"); limit != "" {
+	if limit := params.Get("limit"); limit != "" {
 		if l, err := strconv.Atoi(limit); err == nil && l > 0 {
 			query.Limit = l
+		} else {
+			invalid("limit", "must be a positive integer")
 		}
 	}
-	if offset := r.URL.Query().Get("offset"); offset != "" {
+	if offset := params.Get("offset"); offset != "" {
 		if o, err := strconv.Atoi(offset); err == nil && o >= 0 {
 			query.Offset = o
+		} else {
+			invalid("offset", "must be a non-negative integer")
 		}
 	}
 
-	return query
+	return query, errs
+}
+
+// isStrict reports whether malformed query parameters should be rejected
+// (strict=true, the default) rather than ignored.
+func isStrict(r *http.Request) bool {
+	strict, err := strconv.ParseBool(r.URL.Query().Get("strict"))
+	return err != nil || strict
+}
+
+// writeQueryErrors responds 400 with the field-level errors.
+func writeQueryErrors(w http.ResponseWriter, errs []QueryParamError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":  "invalid query parameters",
+		"fields": errs,
+	})
 }
 
 // Middleware
@@ -459,7 +990,7 @@ func CORSMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Encoding, "+SDKVersionHeader+", "+IdempotencyKeyHeader)
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
@@ -2,16 +2,20 @@ package collector
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"log/slog"
+	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
-	"github.com/saintparish4/asmbly/internal/models"
 	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
 )
 
 func TestHandlePostSpan_Success(t *testing.T) {
@@ -85,6 +89,82 @@ func TestHandlePostSpan_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestHandlePostSpan_DecompressedBodyTooLarge(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	// A few hundred KB of gzip that expands past the limit
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write(bytes.Repeat([]byte(" "), maxBodyBytes+1))
+	gz.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/spans/batch", &body)
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	col.HandlePostSpansBatch(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want 413", rec.Code)
+	}
+}
+
+func TestHandlePostSpan_UnsupportedSchemaVersion(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	body := []byte(`{"schema_version": 99, "trace_id": "` + models.GenerateTraceID() + `"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/spans", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+	col.HandlePostSpan(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "unsupported schema_version 99") {
+		t.Errorf("status = %d, body %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandlePostSpans_Protobuf(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+	col.Start(ctx)
+
+	traceID := models.GenerateTraceID()
+	newSpan := func() *models.Span {
+		return &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "checkout",
+			OperationName: "POST /orders",
+			StartTime:     time.Now(),
+			Duration:      5 * time.Millisecond,
+			Status:        "ok",
+			Tags:          map[string]string{"http.method": "POST"},
+		}
+	}
+	post := func(handler http.HandlerFunc, path string, body []byte) int {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		req.Header.Set("Content-Type", models.ProtobufContentType)
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec.Code
+	}
+
+	if code := post(col.HandlePostSpan, "/api/v1/spans", newSpan().MarshalProto()); code != http.StatusAccepted {
+		t.Errorf("single span status = %d, want %d", code, http.StatusAccepted)
+	}
+	batch := models.MarshalSpansProto([]*models.Span{newSpan(), newSpan()})
+	if code := post(col.HandlePostSpansBatch, "/api/v1/spans/batch", batch); code != http.StatusAccepted {
+		t.Errorf("batch status = %d, want %d", code, http.StatusAccepted)
+	}
+	if code := post(col.HandlePostSpansBatch, "/api/v1/spans/batch", batch[:len(batch)-3]); code != http.StatusBadRequest {
+		t.Errorf("truncated batch status = %d, want %d", code, http.StatusBadRequest)
+	}
+	col.Stop(ctx)
+
+	trace, _ := store.GetTrace(ctx, traceID)
+	if trace == nil || len(trace.Spans) != 3 || trace.Spans[0].GetTag("http.method") != "POST" {
+		t.Errorf("stored trace = %+v", trace)
+	}
+}
+
 func TestHandlePostSpan_InvalidSpan(t *testing.T) {
 	store := storage.NewMemoryStore(1000)
 	config := &Config{Workers: 2, ChannelBuffer: 10}
@@ -299,6 +379,234 @@ func TestHandleFindTraces_WithFilters(t *testing.T) {
 	}
 }
 
+func TestHandleFindTraces_Sort(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+
+	for _, d := range []time.Duration{20, 50, 10} {
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Duration:      d * time.Millisecond,
+			Status:        "ok",
+		})
+	}
+
+	durations := func(target string) []time.Duration {
+		rec := httptest.NewRecorder()
+		col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var result struct {
+			Traces []models.Trace `json:"traces"`
+		}
+		json.NewDecoder(rec.Body).Decode(&result)
+		var got []time.Duration
+		for _, trace := range result.Traces {
+			got = append(got, trace.Duration/time.Millisecond)
+		}
+		return got
+	}
+
+	if got := durations("/api/v1/traces?sort=duration&order=desc&limit=2"); len(got) != 2 || got[0] != 50 || got[1] != 20 {
+		t.Errorf("slowest first = %v, want [50 20]", got)
+	}
+	if got := durations("/api/v1/traces?sort=duration&order=asc"); len(got) != 3 || got[0] != 10 || got[2] != 50 {
+		t.Errorf("fastest first = %v, want [10 20 50]", got)
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces?sort=name&order=up", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid sort: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleFindTraces_Tags(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+
+	for _, status := range []string{"500", "200"} {
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Status:        "ok",
+			Tags:          map[string]string{"http.status_code": status, "url": "http://shop/cart"},
+		})
+	}
+
+	count := func(target string) int {
+		rec := httptest.NewRecorder()
+		col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d: %s", target, rec.Code, rec.Body)
+		}
+		var result struct {
+			Total int `json:"total"`
+		}
+		json.NewDecoder(rec.Body).Decode(&result)
+		return result.Total
+	}
+
+	if got := count("/api/v1/traces?tag=http.status_code:500"); got != 1 {
+		t.Errorf("tag=http.status_code:500 matched %d traces, want 1", got)
+	}
+	if got := count("/api/v1/traces?tag=http.status_code"); got != 2 {
+		t.Errorf("tag=http.status_code matched %d traces, want 2", got)
+	}
+	// Values may contain colons
+	if got := count("/api/v1/traces?tag=url:http://shop/cart&tag=http.status_code:200"); got != 1 {
+		t.Errorf("two tags matched %d traces, want 1", got)
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces?tag=:500", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("empty tag key: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleFindTraces_ErrorsOnly(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+
+	for _, status := range []string{"error", "ok", "ok"} {
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Status:        status,
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces?errors_only=true", nil))
+	var result struct {
+		Traces []models.Trace `json:"traces"`
+	}
+	json.NewDecoder(rec.Body).Decode(&result)
+	if len(result.Traces) != 1 || result.Traces[0].Spans[0].Status != "error" {
+		t.Errorf("errors_only returned %d traces, want the failed one", len(result.Traces))
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces?errors_only=maybe", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid errors_only: status = %d, want 400", rec.Code)
+	}
+}
+
+func TestHandleFindTraces_StrictValidation(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	config := &Config{Workers: 2, ChannelBuffer: 10}
+	col := NewCollector(store, config, slog.Default())
+
+	// Malformed parameters are rejected by default with one error per field
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/traces?min_duration=fast&start_time=yesterday&limit=-1", nil)
+	rec := httptest.NewRecorder()
+	col.HandleFindTraces(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	var response struct {
+		Error  string            `json:"error"`
+		Fields []QueryParamError `json:"fields"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	params := make(map[string]bool)
+	for _, f := range response.Fields {
+		params[f.Param] = true
+		if f.Reason == "" {
+			t.Errorf("field %s has no reason", f.Param)
+		}
+	}
+	for _, want := range []string{"min_duration", "start_time", "limit"} {
+		if !params[want] {
+			t.Errorf("missing error for %s in %+v", want, response.Fields)
+		}
+	}
+
+	// Inconsistent ranges are reported too
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/traces?min_duration=2s&max_duration=1s", nil)
+	rec = httptest.NewRecorder()
+	col.HandleFindTraces(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("inverted duration range: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	// strict=false ignores malformed parameters
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/traces?min_duration=fast&strict=false", nil)
+	rec = httptest.NewRecorder()
+	col.HandleFindTraces(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("strict=false: status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestHandleFindTraces_FieldSelection(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	config := &Config{Workers: 2, ChannelBuffer: 10}
+	col := NewCollector(store, config, slog.Default())
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Duration:      50 * time.Millisecond,
+		Status:        "ok",
+	}
+	store.WriteSpan(context.Background(), span)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/traces?fields=trace_id,duration,span_count", nil)
+	rec := httptest.NewRecorder()
+	col.HandleFindTraces(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	var response struct {
+		Traces []map[string]interface{} `json:"traces"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Traces) != 1 {
+		t.Fatalf("got %d traces, want 1", len(response.Traces))
+	}
+	trace := response.Traces[0]
+	if len(trace) != 3 || trace["trace_id"] != span.TraceID || trace["span_count"] != float64(1) {
+		t.Errorf("unexpected projection: %v", trace)
+	}
+	if _, ok := trace["spans"]; ok {
+		t.Error("spans should not be serialized when not selected")
+	}
+
+	// Unknown fields are a validation error
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/traces?fields=trace_id,colour", nil)
+	rec = httptest.NewRecorder()
+	col.HandleFindTraces(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown field: status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
 func TestHandleFindTraces_Pagination(t *testing.T) {
 	store := storage.NewMemoryStore(1000)
 	config := &Config{Workers: 2, ChannelBuffer: 10}
@@ -341,6 +649,64 @@ func TestHandleFindTraces_Pagination(t *testing.T) {
 	}
 }
 
+func TestHandleFindTraces_PaginationLinks(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	config := &Config{Workers: 2, ChannelBuffer: 10}
+	col := NewCollector(store, config, slog.Default())
+
+	ctx := context.Background()
+	for i := 0; i < 10; i++ {
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "test-service",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Duration:      50 * time.Millisecond,
+			Status:        "ok",
+		})
+	}
+
+	get := func(target string) (map[string]string, string) {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		rec := httptest.NewRecorder()
+		col.HandleFindTraces(rec, req)
+
+		var result struct {
+			Links map[string]string `json:"links"`
+		}
+		json.NewDecoder(rec.Body).Decode(&result)
+		return result.Links, rec.Header().Get("Link")
+	}
+
+	// First page: next only, filters preserved
+	links, header := get("/api/v1/traces?service=test-service&limit=4")
+	if links["next"] != "/api/v1/traces?limit=4&offset=4&service=test-service" {
+		t.Errorf("next = %q", links["next"])
+	}
+	if links["prev"] != "" {
+		t.Errorf("first page should have no prev, got %q", links["prev"])
+	}
+	if header != `</api/v1/traces?limit=4&offset=4&service=test-service>; rel="next"` {
+		t.Errorf("Link header = %q", header)
+	}
+
+	// Middle page: both
+	links, _ = get("/api/v1/traces?limit=4&offset=4")
+	if links["next"] == "" || links["prev"] != "/api/v1/traces?limit=4&offset=0" {
+		t.Errorf("middle page links = %v", links)
+	}
+
+	// Last page: prev only
+	links, header = get("/api/v1/traces?limit=4&offset=8")
+	if links["next"] != "" || links["prev"] == "" {
+		t.Errorf("last page links = %v", links)
+	}
+	if header != `</api/v1/traces?limit=4&offset=4>; rel="prev"` {
+		t.Errorf("Link header = %q", header)
+	}
+}
+
 func TestHandleGetServices(t *testing.T) {
 	store := storage.NewMemoryStore(1000)
 	config := &Config{Workers: 2, ChannelBuffer: 10}
@@ -540,6 +906,12 @@ func TestCORSMiddleware(t *testing.T) {
 	if rec.Header().Get("Access-Control-Allow-Methods") == "" {
 		t.Error("CORS methods header not set")
 	}
+	allowed := rec.Header().Get("Access-Control-Allow-Headers")
+	for _, header := range []string{"Content-Type", "Content-Encoding", SDKVersionHeader, IdempotencyKeyHeader} {
+		if !strings.Contains(allowed, header) {
+			t.Errorf("Access-Control-Allow-Headers = %q, missing %s", allowed, header)
+		}
+	}
 }
 
 // Benchmark span submission throughput
@@ -605,3 +977,66 @@ func BenchmarkHandlePostSpan(b *testing.B) {
 		col.HandlePostSpan(rec, req)
 	}
 }
+
+func TestHandleFindTraces_TotalCountsEveryMatch(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Status:        "ok",
+		})
+	}
+
+	for _, target := range []string{
+		"/api/v1/traces?limit=2",
+		"/api/v1/traces?limit=2&offset=4",
+		"/api/v1/traces?limit=2&offset=10",
+		"/api/v1/traces",
+	} {
+		rec := httptest.NewRecorder()
+		col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var result struct {
+			Traces []json.RawMessage `json:"traces"`
+			Total  int               `json:"total"`
+		}
+		json.NewDecoder(rec.Body).Decode(&result)
+		if result.Total != 5 {
+			t.Errorf("%s: total = %d with %d traces on the page, want 5", target, result.Total, len(result.Traces))
+		}
+	}
+}
+
+// FuzzParseQueryParams runs arbitrary query strings through the trace search
+// parser. Every parameter must parse or be reported, never panic, and an
+// accepted query must be consistent.
+func FuzzParseQueryParams(f *testing.F) {
+	f.Add("service=api&min_duration=100ms&max_duration=2s&tag=http.status:500&sort=duration&order=desc&limit=10&offset=20")
+	f.Add("start_time=now-1h&end_time=2024-01-15T10:00:00&tz=America/New_York&lookback=30m")
+	f.Add("min_cost=NaN&errors_only=maybe&in_progress=1&min_ttl=-1h&tag=:x")
+	f.Fuzz(func(t *testing.T, raw string) {
+		params, err := url.ParseQuery(raw)
+		if err != nil {
+			return
+		}
+		query, errs := parseQueryParams(params)
+		if len(errs) > 0 {
+			return
+		}
+		if query.MinDuration < 0 || query.MaxDuration < 0 || query.Limit < 0 || query.Offset < 0 {
+			t.Fatalf("%q: negative bound in %+v", raw, query)
+		}
+		if math.IsNaN(query.MinCost) || math.IsNaN(query.MaxCost) || math.IsInf(query.MinCost, 0) || math.IsInf(query.MaxCost, 0) {
+			t.Fatalf("%q: cost bounds %v..%v", raw, query.MinCost, query.MaxCost)
+		}
+		if !query.StartTime.IsZero() && !query.EndTime.IsZero() && query.StartTime.After(query.EndTime) {
+			t.Fatalf("%q: start %v after end %v", raw, query.StartTime, query.EndTime)
+		}
+	})
+}
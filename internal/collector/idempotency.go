@@ -0,0 +1,170 @@
+package collector
+
+import (
+	"bytes"
+	"container/list"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Headers for idempotent batch submission
+const (
+	// IdempotencyKeyHeader names a batch submission. A retry with the same
+	// key, e.g. after a client timeout, gets the first request's response
+	// instead of ingesting the batch twice.
+	IdempotencyKeyHeader = "Idempotency-Key"
+
+	// IdempotentReplayedHeader is set on responses served from the cache.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+// DefaultIdempotencyTTL is how long batch responses are remembered unless
+// configured; it covers an SDK's retries with room to spare.
+const DefaultIdempotencyTTL = 5 * time.Minute
+
+const (
+	// maxIdempotencyKeyLength bounds keys, which are stored verbatim
+	maxIdempotencyKeyLength = 255
+
+	// idempotencyCacheSize bounds the remembered responses; the oldest go
+	// first, shortening the window under extreme request rates
+	idempotencyCacheSize = 100000
+)
+
+// idempotencyCache remembers the responses to batch submissions by
+// Idempotency-Key for a short TTL. A key is pending while its first request
+// is handled and only successful responses are kept, so a request that
+// failed can be retried under the same key.
+type idempotencyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	size    int
+	entries map[string]*list.Element
+	lru     *list.List // Front = most recently added
+}
+
+type idempotencyEntry struct {
+	key         string
+	pending     bool
+	status      int
+	contentType string
+	body        []byte
+	expires     time.Time
+}
+
+func newIdempotencyCache(ttl time.Duration) *idempotencyCache {
+	return &idempotencyCache{
+		ttl:     ttl,
+		size:    idempotencyCacheSize,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// begin claims key for a new request. Otherwise it returns the entry
+// already held for key, which may still be pending.
+func (c *idempotencyCache) begin(key string) (existing *idempotencyEntry, claimed bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*idempotencyEntry)
+		if entry.pending || time.Now().Before(entry.expires) {
+			copied := *entry
+			return &copied, false
+		}
+		c.remove(elem)
+	}
+
+	c.entries[key] = c.lru.PushFront(&idempotencyEntry{key: key, pending: true})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	return nil, true
+}
+
+// finish stores the response to a claimed key, or releases the key if the
+// response is not worth replaying.
+func (c *idempotencyCache) finish(key string, status int, contentType string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return // Evicted while pending
+	}
+	if status < 200 || status >= 300 {
+		c.remove(elem)
+		return
+	}
+	entry := elem.Value.(*idempotencyEntry)
+	entry.pending = false
+	entry.status = status
+	entry.contentType = contentType
+	entry.body = body
+	entry.expires = time.Now().Add(c.ttl)
+}
+
+func (c *idempotencyCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*idempotencyEntry).key)
+}
+
+// idempotent wraps a batch handler so requests carrying an Idempotency-Key
+// are handled at most once per TTL. Retries of a handled request get its
+// response again, marked with Idempotent-Replayed; retries racing the first
+// request are refused with 409 until it finishes. Throttle and capability
+// headers on a replayed response are current, not the first response's.
+func (h *ingestHandler) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if h.idempotency == nil || key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+			return
+		}
+
+		key = r.URL.Path + " " + key
+		entry, claimed := h.idempotency.begin(key)
+		switch {
+		case !claimed && entry.pending:
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "a request with this Idempotency-Key is in progress", http.StatusConflict)
+			return
+		case !claimed:
+			h.replayed.Add(1)
+			h.setThrottleHeaders(w)
+			w.Header().Set("Content-Type", entry.contentType)
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		h.idempotency.finish(key, rec.status, w.Header().Get("Content-Type"), rec.body.Bytes())
+	}
+}
+
+// recordingWriter passes a response through while keeping a copy of its
+// status and body.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *recordingWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+)
+
+func TestHandlePostSpansBatch_IdempotencyKey(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10, IdempotencyTTL: time.Minute}, slog.Default())
+	col.setState(StateReady)
+
+	post := func(key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/spans/batch", strings.NewReader(body))
+		req.Header.Set(IdempotencyKeyHeader, key)
+		rec := httptest.NewRecorder()
+		col.HandlePostSpansBatch(rec, req)
+		return rec
+	}
+	batch := `[{"trace_id":"t1","span_id":"s1"},{"trace_id":"t1","span_id":"s2"}]`
+
+	// A failed request leaves the key free for its retry
+	if rec := post("batch-1", "not json"); rec.Code != http.StatusBadRequest {
+		t.Fatalf("invalid batch: status %d, want 400", rec.Code)
+	}
+	first := post("batch-1", batch)
+	if first.Code != http.StatusAccepted || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("first request: status %d, replayed %q", first.Code, first.Header().Get(IdempotentReplayedHeader))
+	}
+
+	// The retry gets the same response without ingesting the spans again
+	retry := post("batch-1", batch)
+	if retry.Code != first.Code || retry.Body.String() != first.Body.String() || retry.Header().Get(IdempotentReplayedHeader) != "true" {
+		t.Errorf("retry: status %d, body %q, replayed %q; want the first response replayed",
+			retry.Code, retry.Body.String(), retry.Header().Get(IdempotentReplayedHeader))
+	}
+	if metrics := col.GetMetrics(); metrics.SpansReceived != 2 || metrics.IngestReplayed != 1 {
+		t.Errorf("received %d spans and replayed %d requests, want 2 and 1", metrics.SpansReceived, metrics.IngestReplayed)
+	}
+
+	// Other keys are ingested
+	if rec := post("batch-2", batch); rec.Header().Get(IdempotentReplayedHeader) != "" || col.GetMetrics().SpansReceived != 4 {
+		t.Error("a batch with a new key was not ingested")
+	}
+	if rec := post(strings.Repeat("k", maxIdempotencyKeyLength+1), batch); rec.Code != http.StatusBadRequest {
+		t.Errorf("oversized key: status %d, want 400", rec.Code)
+	}
+}
+
+func TestIdempotencyCache_PendingAndExpiry(t *testing.T) {
+	cache := newIdempotencyCache(20 * time.Millisecond)
+
+	if _, claimed := cache.begin("k"); !claimed {
+		t.Fatal("a new key was not claimed")
+	}
+	if entry, claimed := cache.begin("k"); claimed || !entry.pending {
+		t.Fatalf("key in progress: claimed %v, entry %+v; want the pending entry", claimed, entry)
+	}
+
+	cache.finish("k", http.StatusAccepted, "application/json", []byte("{}"))
+	if entry, claimed := cache.begin("k"); claimed || entry.status != http.StatusAccepted {
+		t.Fatalf("finished key: claimed %v, entry %+v; want the stored response", claimed, entry)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, claimed := cache.begin("k"); !claimed {
+		t.Error("an expired key was not claimed again")
+	}
+}
@@ -0,0 +1,322 @@
+package collector
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// ThrottleSampleRateHeader carries the suggested sample rate while the
+// collector is saturated.
+const ThrottleSampleRateHeader = "X-Traceflow-Sample-Rate"
+
+// ingestHandler implements the JSON and protobuf span ingestion endpoints on
+// top of a receiver.Consumer, so the collector's own routes and any
+// configured HTTP receivers share one implementation.
+type ingestHandler struct {
+	consumer receiver.Consumer
+	origin   OriginConfig
+	logger   *slog.Logger
+
+	// Concurrency limit (see limit); nil = unlimited
+	slots    chan struct{}
+	inFlight atomic.Int64
+	rejected atomic.Int64
+
+	// Per-client span rate limit (see ratelimit.go); nil = unlimited
+	rateLimit   *rateLimiter
+	rateLimited atomic.Int64
+
+	// Batch responses by Idempotency-Key (see idempotency.go); nil = disabled
+	idempotency *idempotencyCache
+	replayed    atomic.Int64
+
+	// Failure injection (see chaos.go); nil = disabled
+	chaos *chaos
+
+	// SDK versions already logged (see negotiate.go)
+	sdkVersions     sync.Map
+	sdkVersionCount atomic.Int64
+}
+
+// newIngestHandler returns a handler admitting at most maxInFlight requests
+// at once (0 = unlimited) and tagging spans with their origin if enabled.
+func newIngestHandler(consumer receiver.Consumer, maxInFlight int, origin OriginConfig, logger *slog.Logger) *ingestHandler {
+	h := &ingestHandler{consumer: consumer, origin: origin, logger: logger}
+	if maxInFlight > 0 {
+		h.slots = make(chan struct{}, maxInFlight)
+	}
+	return h
+}
+
+// limit rejects requests beyond the concurrency limit with 503 before their
+// bodies are read, so a burst of large POSTs cannot exhaust memory ahead of
+// the span queue's own backpressure. Every response, refused or not,
+// advertises the collector's capabilities.
+func (h *ingestHandler) limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		setCapabilityHeaders(w.Header())
+		h.noteSDKVersion(r)
+		if h.slots != nil {
+			select {
+			case h.slots <- struct{}{}:
+				defer func() { <-h.slots }()
+			default:
+				h.rejected.Add(1)
+				h.setThrottleHeaders(w)
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "too many concurrent requests", http.StatusServiceUnavailable)
+				return
+			}
+		}
+		if h.chaos.rejectRequest() {
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, errChaosReject.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		if !h.admit(w, r, 0) {
+			return
+		}
+		h.inFlight.Add(1)
+		defer h.inFlight.Add(-1)
+		next(w, r)
+	}
+}
+
+// handlePostSpan handles POST /api/v1/spans - submit a single span.
+func (h *ingestHandler) handlePostSpan(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Read and parse span
+	body, err := readBody(r)
+	if err != nil {
+		h.writeBodyError(w, err)
+		return
+	}
+
+	var span models.Span
+	if isProtobuf(r) {
+		err = span.UnmarshalProto(body)
+	} else {
+		err = json.Unmarshal(body, &span)
+	}
+	if err != nil {
+		h.logger.Error("failed to parse span", "content_type", r.Header.Get("Content-Type"), "error", err)
+		writeDecodeError(w, r, err)
+		return
+	}
+	if !h.admit(w, r, 1) {
+		return
+	}
+
+	// Submit span
+	consumer := withOrigin(h.consumer, h.origin.httpOrigin(r))
+	if err := consumer.SubmitSpan(&span); err != nil {
+		h.logger.Error("failed to submit span", "error", err)
+		h.writeSubmitError(w, err)
+		return
+	}
+
+	// Success
+	response := map[string]interface{}{
+		"status": "accepted",
+	}
+	if hint := h.setThrottleHeaders(w); hint != nil {
+		response["throttle"] = hint
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(response)
+}
+
+// handlePostSpansBatch handles POST /api/v1/spans/batch - submit multiple spans.
+func (h *ingestHandler) handlePostSpansBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Read and parse spans
+	body, err := readBody(r)
+	if err != nil {
+		h.writeBodyError(w, err)
+		return
+	}
+
+	var spans []models.Span
+	if isProtobuf(r) {
+		spans, err = models.UnmarshalSpansProto(body)
+	} else {
+		err = json.Unmarshal(body, &spans)
+	}
+	if err != nil {
+		h.logger.Error("failed to parse spans", "content_type", r.Header.Get("Content-Type"), "error", err)
+		writeDecodeError(w, r, err)
+		return
+	}
+	if !h.admit(w, r, len(spans)) {
+		return
+	}
+
+	// Submit all spans
+	consumer := withOrigin(h.consumer, h.origin.httpOrigin(r))
+	accepted := 0
+	failed := 0
+	for i := range spans {
+		if err := consumer.SubmitSpan(&spans[i]); err != nil {
+			h.logger.Warn("failed to submit span in batch",
+				"span_index", i,
+				"error", err,
+			)
+			failed++
+		} else {
+			accepted++
+		}
+	}
+
+	// Response
+	response := map[string]interface{}{
+		"accepted": accepted,
+		"failed":   failed,
+		"total":    len(spans),
+	}
+	if hint := h.setThrottleHeaders(w); hint != nil {
+		response["throttle"] = hint
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if failed > 0 {
+		w.WriteHeader(http.StatusPartialContent)
+	} else {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// isProtobuf reports whether a span payload is in the protobuf wire format
+// (see models/span.proto) rather than JSON.
+func isProtobuf(r *http.Request) bool {
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return contentType == models.ProtobufContentType
+}
+
+// writeDecodeError answers an unparseable span payload with 400, naming
+// the problem when the client declared an unsupported schema_version.
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, models.ErrUnsupportedSchemaVersion):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case isProtobuf(r):
+		http.Error(w, "invalid protobuf", http.StatusBadRequest)
+	default:
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+	}
+}
+
+// errUnsupportedEncoding is returned by readBody for Content-Encodings other
+// than gzip, zstd and identity.
+var errUnsupportedEncoding = errors.New("unsupported content encoding, want gzip or zstd")
+
+// maxDecodedZstdWindow caps the memory a zstd payload may make the decoder
+// reserve, so a crafted frame header cannot allocate gigabytes.
+const maxDecodedZstdWindow = 64 << 20
+
+// maxBodyBytes caps an ingestion request body after decompression, so a
+// small compressed payload cannot expand into gigabytes of memory.
+const maxBodyBytes = 64 << 20
+
+// errBodyTooLarge is returned by readBody for bodies over maxBodyBytes.
+var errBodyTooLarge = fmt.Errorf("request body exceeds %d bytes", maxBodyBytes)
+
+// readBody reads and closes the request body, decompressing gzip and zstd
+// payloads.
+func readBody(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+
+	var body io.Reader
+	switch r.Header.Get("Content-Encoding") {
+	case "", "identity":
+		body = r.Body
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	case "zstd":
+		zr, err := zstd.NewReader(r.Body, zstd.WithDecoderConcurrency(1), zstd.WithDecoderMaxWindow(maxDecodedZstdWindow))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		body = zr
+	default:
+		return nil, errUnsupportedEncoding
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBodyBytes+1))
+	if err == nil && len(data) > maxBodyBytes {
+		return nil, errBodyTooLarge
+	}
+	return data, err
+}
+
+// writeBodyError answers a body readBody could not read.
+func (h *ingestHandler) writeBodyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errUnsupportedEncoding):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, errBodyTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	default:
+		h.logger.Error("failed to read request body", "error", err)
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+	}
+}
+
+// writeSubmitError answers a refused submission with 503. A starting
+// collector asks clients to retry shortly; a draining or stopped one closes
+// the connection so clients reconnect to another instance.
+func (h *ingestHandler) writeSubmitError(w http.ResponseWriter, err error) {
+	h.setThrottleHeaders(w)
+	switch {
+	case errors.Is(err, ErrStarting):
+		w.Header().Set("Retry-After", "1")
+	case errors.Is(err, ErrDraining), errors.Is(err, ErrStopped):
+		w.Header().Set("Connection", "close")
+	}
+	http.Error(w, err.Error(), http.StatusServiceUnavailable)
+}
+
+// setThrottleHeaders advertises the consumer's throttle hint, if any, via
+// X-Traceflow-Sample-Rate and Retry-After so clients can read it without
+// parsing the body (error responses are plain text).
+func (h *ingestHandler) setThrottleHeaders(w http.ResponseWriter) *models.ThrottleHint {
+	hint := receiver.ThrottleHintFor(h.consumer)
+	if hint == nil {
+		return nil
+	}
+
+	w.Header().Set(ThrottleSampleRateHeader, strconv.FormatFloat(hint.SampleRate, 'f', 3, 64))
+	if hint.BackoffMs > 0 {
+		seconds := (hint.BackoffMs + 999) / 1000 // Retry-After is whole seconds, round up
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	}
+	return hint
+}
@@ -0,0 +1,217 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// maxIngestLagServices bounds the services tracked. Once reached, further
+// services are counted under redOtherOperation.
+const maxIngestLagServices = 1000
+
+// Ingest lag histogram bucket upper bounds, in seconds. Spans are usually
+// stored within seconds of ending; minutes point at buffering or replay.
+var ingestLagBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900}
+
+// IngestLag tracks, per service, how long spans take from ending in the
+// client to being stored, and the watermark: the latest span end time
+// stored. Lag that grows while traffic is steady points at SDK or relay
+// buffering; a watermark that stops moving at a service that should be busy
+// means its spans stopped arriving. Spans that end after they are stored
+// come from clients whose clocks run ahead; they count as zero lag and are
+// reported separately.
+type IngestLag struct {
+	mu       sync.Mutex
+	services map[string]*ingestLagSeries
+	now      func() time.Time
+}
+
+type ingestLagSeries struct {
+	spans      uint64
+	future     uint64    // Spans ending after they were stored
+	watermark  time.Time // Latest span end stored
+	lastStored time.Time
+	lastLag    time.Duration
+	maxLag     time.Duration
+	lag        *histogram.Histogram // Seconds
+}
+
+func newIngestLag() *IngestLag {
+	return &IngestLag{services: make(map[string]*ingestLagSeries), now: time.Now}
+}
+
+// Observe records a span just stored.
+func (l *IngestLag) Observe(span *models.Span) {
+	if span.InProgress {
+		return
+	}
+	now := l.now()
+	end := span.EndTime()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	service := span.ServiceName
+	s, ok := l.services[service]
+	if !ok {
+		if len(l.services) >= maxIngestLagServices {
+			service = redOtherOperation
+			s, ok = l.services[service]
+		}
+		if !ok {
+			s = &ingestLagSeries{lag: histogram.New(ingestLagBuckets)}
+			l.services[service] = s
+		}
+	}
+
+	lag := now.Sub(end)
+	if lag < 0 {
+		s.future++
+		lag = 0
+	}
+	s.spans++
+	s.lastStored = now
+	s.lastLag = lag
+	s.maxLag = max(s.maxLag, lag)
+	s.lag.Observe(lag.Seconds())
+	if end.After(s.watermark) {
+		s.watermark = end
+	}
+}
+
+// ServiceIngestLag is the ingest lag and watermark of one service, or of
+// every service together.
+type ServiceIngestLag struct {
+	Service      string        `json:"service,omitempty"`
+	Spans        uint64        `json:"spans"`
+	FutureSpans  uint64        `json:"future_spans"`        // Ended after they were stored (client clock ahead)
+	Watermark    *time.Time    `json:"watermark,omitempty"` // Latest span end stored
+	Staleness    time.Duration `json:"staleness"`           // Now minus the watermark
+	LastStoredAt *time.Time    `json:"last_stored_at,omitempty"`
+	LastLag      time.Duration `json:"last_lag"`
+	AvgLag       time.Duration `json:"avg_lag"`
+	P50Lag       time.Duration `json:"p50_lag"`
+	P95Lag       time.Duration `json:"p95_lag"`
+	P99Lag       time.Duration `json:"p99_lag"`
+	MaxLag       time.Duration `json:"max_lag"`
+}
+
+// summary summarizes the series at now.
+func (s *ingestLagSeries) summary(service string, now time.Time) ServiceIngestLag {
+	watermark, lastStored := s.watermark, s.lastStored
+	summary := ServiceIngestLag{
+		Service:      service,
+		Spans:        s.spans,
+		FutureSpans:  s.future,
+		Watermark:    &watermark,
+		Staleness:    max(now.Sub(watermark), 0),
+		LastStoredAt: &lastStored,
+		LastLag:      s.lastLag,
+		P50Lag:       secondsDuration(s.lag.Quantile(0.50)),
+		P95Lag:       secondsDuration(s.lag.Quantile(0.95)),
+		P99Lag:       secondsDuration(s.lag.Quantile(0.99)),
+		MaxLag:       s.maxLag,
+	}
+	if s.lag.Count() > 0 {
+		summary.AvgLag = secondsDuration(s.lag.Sum() / float64(s.lag.Count()))
+	}
+	return summary
+}
+
+// Services returns the ingest lag of each service sorted by name, and of all
+// services together. The overall watermark is the earliest service
+// watermark: the point up to which every service's spans have arrived.
+func (l *IngestLag) Services() ([]ServiceIngestLag, ServiceIngestLag) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	services := make([]ServiceIngestLag, 0, len(l.services))
+	total := &ingestLagSeries{lag: histogram.New(ingestLagBuckets)}
+	for service, s := range l.services {
+		services = append(services, s.summary(service, now))
+
+		total.spans += s.spans
+		total.future += s.future
+		total.lag.Merge(s.lag)
+		total.maxLag = max(total.maxLag, s.maxLag)
+		if total.watermark.IsZero() || s.watermark.Before(total.watermark) {
+			total.watermark = s.watermark
+		}
+		if s.lastStored.After(total.lastStored) {
+			total.lastStored = s.lastStored
+			total.lastLag = s.lastLag
+		}
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Service < services[j].Service })
+
+	if len(services) == 0 {
+		return services, ServiceIngestLag{}
+	}
+	return services, total.summary("", now)
+}
+
+var (
+	ingestLagDesc = prometheus.NewDesc("traceflow_ingest_lag_seconds",
+		"Time from a span ending to it being stored, per service", []string{"service"}, nil)
+	ingestWatermarkDesc = prometheus.NewDesc("traceflow_ingest_watermark_seconds",
+		"Latest span end time stored per service, as a Unix timestamp", []string{"service"}, nil)
+	ingestFutureSpansDesc = prometheus.NewDesc("traceflow_ingest_future_spans_total",
+		"Spans that ended after they were stored (client clock ahead) per service", []string{"service"}, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (l *IngestLag) Describe(ch chan<- *prometheus.Desc) {
+	ch <- ingestLagDesc
+	ch <- ingestWatermarkDesc
+	ch <- ingestFutureSpansDesc
+}
+
+// Collect implements prometheus.Collector.
+func (l *IngestLag) Collect(ch chan<- prometheus.Metric) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for service, s := range l.services {
+		ch <- prometheus.MustNewConstHistogram(ingestLagDesc, s.lag.Count(), s.lag.Sum(), s.lag.Buckets(), service)
+		ch <- prometheus.MustNewConstMetric(ingestWatermarkDesc, prometheus.GaugeValue, float64(s.watermark.UnixNano())/1e9, service)
+		ch <- prometheus.MustNewConstMetric(ingestFutureSpansDesc, prometheus.CounterValue, float64(s.future), service)
+	}
+}
+
+// HandleIngestLag handles GET /api/v1/ingest/lag - how far behind stored
+// spans are, per service and overall. ?service= returns one service.
+func (c *Collector) HandleIngestLag(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	services, overall := c.ingestLag.Services()
+	if service := r.URL.Query().Get("service"); service != "" {
+		for _, s := range services {
+			if s.Service == service {
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(s)
+				return
+			}
+		}
+		http.Error(w, "no spans stored for service", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"overall":  overall,
+		"services": services,
+		"total":    len(services),
+	})
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestIngestLag(t *testing.T) {
+	now := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	lag := newIngestLag()
+	lag.now = func() time.Time { return now }
+
+	observe := func(service string, end time.Time) {
+		lag.Observe(&models.Span{ServiceName: service, StartTime: end.Add(-time.Second), Duration: time.Second})
+	}
+	observe("api", now.Add(-200*time.Millisecond))
+	observe("api", now.Add(-2*time.Second))
+	observe("api", now.Add(time.Second)) // Client clock ahead
+	observe("relay", now.Add(-2*time.Minute))
+	lag.Observe(&models.Span{ServiceName: "api", StartTime: now, InProgress: true})
+
+	services, overall := lag.Services()
+	if len(services) != 2 || services[0].Service != "api" || services[1].Service != "relay" {
+		t.Fatalf("unexpected services: %+v", services)
+	}
+	api := services[0]
+	if api.Spans != 3 || api.FutureSpans != 1 || api.LastLag != 0 || api.MaxLag != 2*time.Second {
+		t.Errorf("unexpected api lag: %+v", api)
+	}
+	if !api.Watermark.Equal(now.Add(time.Second)) || api.Staleness != 0 {
+		t.Errorf("api watermark %v, staleness %v", api.Watermark, api.Staleness)
+	}
+	if relay := services[1]; relay.Staleness != 2*time.Minute || relay.P50Lag < time.Minute {
+		t.Errorf("unexpected relay lag: %+v", relay)
+	}
+
+	// Overall, the watermark is the service furthest behind
+	if overall.Spans != 4 || !overall.Watermark.Equal(now.Add(-2*time.Minute)) || overall.MaxLag != 2*time.Minute {
+		t.Errorf("unexpected overall lag: %+v", overall)
+	}
+}
+
+func TestHandleIngestLag(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(100), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	col.ingestLag.Observe(&models.Span{ServiceName: "api", StartTime: time.Now().Add(-time.Second), Duration: 500 * time.Millisecond})
+
+	w := httptest.NewRecorder()
+	col.HandleIngestLag(w, httptest.NewRequest(http.MethodGet, "/api/v1/ingest/lag", nil))
+	var response struct {
+		Overall  ServiceIngestLag   `json:"overall"`
+		Services []ServiceIngestLag `json:"services"`
+		Total    int                `json:"total"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Total != 1 || response.Overall.Spans != 1 || response.Services[0].LastLag < 500*time.Millisecond {
+		t.Errorf("unexpected response: %+v", response)
+	}
+
+	w = httptest.NewRecorder()
+	col.HandleIngestLag(w, httptest.NewRequest(http.MethodGet, "/api/v1/ingest/lag?service=db", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown service: status %d, want 404", w.Code)
+	}
+}
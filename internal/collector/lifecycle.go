@@ -0,0 +1,255 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// State is the collector's lifecycle state.
+type State int32
+
+// Lifecycle states, in order. A collector only moves forward.
+const (
+	StateStarting State = iota // Built but workers not running; spans are refused
+	StateReady                 // Accepting spans
+	StateDraining              // Shutting down; in-flight spans finish, new ones are refused
+	StateStopped               // Workers stopped
+)
+
+func (s State) String() string {
+	switch s {
+	case StateStarting:
+		return "starting"
+	case StateReady:
+		return "ready"
+	case StateDraining:
+		return "draining"
+	case StateStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalJSON encodes the state by name.
+func (s State) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// SubmitSpan errors. Clients can retry ErrStarting and ErrQueueFull against
+// the same collector; ErrDraining and ErrStopped mean they should go elsewhere.
+var (
+	ErrStarting  = errors.New("collector is starting, try again shortly")
+	ErrDraining  = errors.New("collector is draining, send spans to another instance")
+	ErrStopped   = errors.New("collector is stopped")
+	ErrQueueFull = errors.New("span queue full, try again later")
+)
+
+// DefaultReadyQueueThreshold is the fraction of the span queue in use at
+// which /readyz fails, so load balancers send spans elsewhere before the
+// queue refuses them.
+const DefaultReadyQueueThreshold = 0.9
+
+// workerStallTimeout is how long spans may wait in the queue without any
+// being processed before the workers count as stalled.
+const workerStallTimeout = 30 * time.Second
+
+// readyPingTimeout bounds the storage ping of a readiness check.
+const readyPingTimeout = 2 * time.Second
+
+// lifecycle tracks the collector state.
+type lifecycle struct {
+	state     atomic.Int32
+	startedAt atomic.Int64  // Unix nanoseconds of the move to ready
+	draining  chan struct{} // Closed on the move to draining, ending long-lived streams
+
+	// Readiness inputs besides the state
+	queueThreshold float64
+	workersStarted atomic.Int32
+	workersRunning atomic.Int32
+	lastProcessed  atomic.Int64 // Unix nanoseconds a worker last finished a span
+}
+
+// State returns the current lifecycle state.
+func (c *Collector) State() State {
+	return State(c.lifecycle.state.Load())
+}
+
+// setState moves to state unless the collector is already past it.
+func (c *Collector) setState(state State) {
+	for {
+		current := c.lifecycle.state.Load()
+		if State(current) >= state {
+			return
+		}
+		if c.lifecycle.state.CompareAndSwap(current, int32(state)) {
+			if state == StateReady {
+				c.lifecycle.startedAt.Store(time.Now().UnixNano())
+			}
+			if State(current) < StateDraining && state >= StateDraining {
+				close(c.lifecycle.draining)
+			}
+			c.logger.Info("collector state changed", "from", State(current), "to", state)
+			return
+		}
+	}
+}
+
+// Drain stops accepting spans ahead of Stop, so /readyz fails and load
+// balancers take the instance out of rotation while servers still answer.
+func (c *Collector) Drain() {
+	c.setState(StateDraining)
+}
+
+// stateError returns the SubmitSpan error for a collector not accepting spans.
+func stateError(state State) error {
+	switch state {
+	case StateStarting:
+		return ErrStarting
+	case StateDraining:
+		return ErrDraining
+	case StateStopped:
+		return ErrStopped
+	default:
+		return nil
+	}
+}
+
+// ReadinessCheck is one condition of readiness.
+type ReadinessCheck struct {
+	Name   string `json:"name"` // lifecycle, storage, queue or workers
+	OK     bool   `json:"ok"`
+	Reason string `json:"reason,omitempty"` // Why not, when not OK
+}
+
+// Readiness reports whether the collector should receive spans: it is
+// ready, its store answers, its queue has room and its workers are running
+// and making progress.
+func (c *Collector) Readiness(ctx context.Context) (bool, []ReadinessCheck) {
+	state := c.State()
+	checks := []ReadinessCheck{{Name: "lifecycle", OK: state == StateReady}}
+	if state != StateReady {
+		checks[0].Reason = "collector is " + state.String()
+	}
+
+	storageCheck := ReadinessCheck{Name: "storage", OK: true}
+	if pinger, ok := c.store.(storage.Pinger); ok {
+		ctx, cancel := context.WithTimeout(ctx, readyPingTimeout)
+		if err := pinger.Ping(ctx); err != nil {
+			storageCheck = ReadinessCheck{Name: "storage", Reason: "storage unreachable: " + err.Error()}
+		}
+		cancel()
+	}
+	checks = append(checks, storageCheck)
+
+	queued, capacity := len(c.spanCh), cap(c.spanCh)
+	queueCheck := ReadinessCheck{Name: "queue", OK: true}
+	if capacity > 0 && float64(queued) >= c.lifecycle.queueThreshold*float64(capacity) {
+		queueCheck = ReadinessCheck{Name: "queue", Reason: fmt.Sprintf("span queue %.0f%% full (%d of %d)", 100*float64(queued)/float64(capacity), queued, capacity)}
+	}
+	checks = append(checks, queueCheck)
+
+	workersCheck := ReadinessCheck{Name: "workers", OK: true}
+	if state == StateReady {
+		started, running := c.lifecycle.workersStarted.Load(), c.lifecycle.workersRunning.Load()
+		since := max(c.lifecycle.lastProcessed.Load(), c.lifecycle.startedAt.Load())
+		switch {
+		case running < started:
+			workersCheck = ReadinessCheck{Name: "workers", Reason: fmt.Sprintf("%d of %d workers running", running, started)}
+		case queued > 0 && time.Since(time.Unix(0, since)) > workerStallTimeout:
+			workersCheck = ReadinessCheck{Name: "workers", Reason: fmt.Sprintf("no span processed in %s with %d queued", time.Since(time.Unix(0, since)).Round(time.Second), queued)}
+		}
+	}
+	checks = append(checks, workersCheck)
+
+	ready := true
+	for _, check := range checks {
+		ready = ready && check.OK
+	}
+	return ready, checks
+}
+
+// HandleReadyz handles GET /readyz, the readiness probe: 200 while the
+// collector should receive spans, 503 with the failing checks' reasons
+// otherwise.
+func (c *Collector) HandleReadyz(w http.ResponseWriter, r *http.Request) {
+	ready, checks := c.Readiness(r.Context())
+	code := http.StatusOK
+	reasons := []string{}
+	for _, check := range checks {
+		if !check.OK {
+			reasons = append(reasons, check.Reason)
+		}
+	}
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ready":   ready,
+		"state":   c.State(),
+		"checks":  checks,
+		"reasons": reasons,
+	})
+}
+
+// HandleHealthz handles GET /healthz, the liveness probe. It answers 200
+// whenever the server can respond, including while draining or while
+// storage is down: restarting the collector fixes neither, and readiness
+// already stops traffic.
+func (c *Collector) HandleHealthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "alive",
+		"state":  c.State(),
+	})
+}
+
+// Info describes a running collector.
+type Info struct {
+	State         State   `json:"state"`
+	UptimeSeconds float64 `json:"uptime_seconds"` // Time since ready (0 before)
+	Workers       int     `json:"workers"`
+	QueueLength   int     `json:"queue_length"`
+	QueueCapacity int     `json:"queue_capacity"`
+
+	// Capabilities is what span ingestion accepts (see negotiate.go)
+	Capabilities models.Capabilities `json:"capabilities"`
+}
+
+// Info returns the collector's state and queue usage.
+func (c *Collector) Info() Info {
+	info := Info{
+		State:         c.State(),
+		Workers:       c.workers,
+		QueueLength:   len(c.spanCh),
+		QueueCapacity: cap(c.spanCh),
+		Capabilities:  Capabilities(),
+	}
+	if started := c.lifecycle.startedAt.Load(); started > 0 {
+		info.UptimeSeconds = time.Since(time.Unix(0, started)).Seconds()
+	}
+	return info
+}
+
+// HandleInfo handles GET /api/v1/info.
+func (c *Collector) HandleInfo(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Info())
+}
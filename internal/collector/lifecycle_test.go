@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestLifecycle_SubmitSpanErrorPerState(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	if col.State() != StateStarting {
+		t.Fatalf("new collector state = %v, want starting", col.State())
+	}
+	if err := col.SubmitSpan(&models.Span{}); !errors.Is(err, ErrStarting) {
+		t.Errorf("SubmitSpan before Start = %v, want ErrStarting", err)
+	}
+
+	col.Start(context.Background())
+	if col.State() != StateReady {
+		t.Fatalf("state after Start = %v, want ready", col.State())
+	}
+
+	col.Drain()
+	if err := col.SubmitSpan(&models.Span{}); !errors.Is(err, ErrDraining) {
+		t.Errorf("SubmitSpan while draining = %v, want ErrDraining", err)
+	}
+
+	if err := col.Stop(context.Background()); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if col.State() != StateStopped {
+		t.Errorf("state after Stop = %v, want stopped", col.State())
+	}
+	if err := col.SubmitSpan(&models.Span{}); !errors.Is(err, ErrStopped) {
+		t.Errorf("SubmitSpan after Stop = %v, want ErrStopped", err)
+	}
+
+	// States only move forward
+	col.setState(StateReady)
+	if col.State() != StateStopped {
+		t.Errorf("state moved back to %v", col.State())
+	}
+}
+
+func TestHandleReadyz(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	readyz := func() (int, string) {
+		rec := httptest.NewRecorder()
+		col.HandleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body struct {
+			Ready bool   `json:"ready"`
+			State string `json:"state"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.State
+	}
+
+	if code, state := readyz(); code != http.StatusServiceUnavailable || state != "starting" {
+		t.Errorf("starting: got %d %q", code, state)
+	}
+	col.setState(StateReady)
+	if code, state := readyz(); code != http.StatusOK || state != "ready" {
+		t.Errorf("ready: got %d %q", code, state)
+	}
+	col.Drain()
+	if code, state := readyz(); code != http.StatusServiceUnavailable || state != "draining" {
+		t.Errorf("draining: got %d %q", code, state)
+	}
+}
+
+// pingStore is a store whose Ping returns err.
+type pingStore struct {
+	storage.Store
+	err error
+}
+
+func (s *pingStore) Ping(context.Context) error { return s.err }
+
+func TestHandleReadyz_Checks(t *testing.T) {
+	store := &pingStore{Store: storage.NewMemoryStore(1000)}
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, ReadyQueueThreshold: 0.5}, slog.Default())
+	col.setState(StateReady)
+
+	readyz := func() (int, []string) {
+		rec := httptest.NewRecorder()
+		col.HandleReadyz(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var body struct {
+			Reasons []string `json:"reasons"`
+		}
+		json.NewDecoder(rec.Body).Decode(&body)
+		return rec.Code, body.Reasons
+	}
+
+	store.err = errors.New("connection refused")
+	if code, reasons := readyz(); code != http.StatusServiceUnavailable || len(reasons) != 1 || reasons[0] != "storage unreachable: connection refused" {
+		t.Errorf("storage down: got %d %v", code, reasons)
+	}
+	store.err = nil
+
+	// Workers are not running, so queued spans stay queued
+	for i := 0; i < 5; i++ {
+		col.spanCh <- queuedSpan{span: &models.Span{}}
+	}
+	if code, reasons := readyz(); code != http.StatusServiceUnavailable || len(reasons) != 1 || reasons[0] != "span queue 50% full (5 of 10)" {
+		t.Errorf("queue saturated: got %d %v", code, reasons)
+	}
+
+	<-col.spanCh
+	col.lifecycle.startedAt.Store(time.Now().Add(-time.Minute).UnixNano())
+	if code, reasons := readyz(); code != http.StatusServiceUnavailable || len(reasons) != 1 || reasons[0] != "no span processed in 1m0s with 4 queued" {
+		t.Errorf("workers stalled: got %d %v", code, reasons)
+	}
+
+	col.lifecycle.lastProcessed.Store(time.Now().UnixNano())
+	if code, reasons := readyz(); code != http.StatusOK || len(reasons) != 0 {
+		t.Errorf("ready: got %d %v", code, reasons)
+	}
+}
+
+func TestHandleHealthz(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(10), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	col.Drain()
+
+	rec := httptest.NewRecorder()
+	col.HandleHealthz(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("healthz while draining: status %d, want 200", rec.Code)
+	}
+}
+
+func TestHandlePostSpan_NotReadyHeaders(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+	}
+	spanJSON, _ := json.Marshal(span)
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/spans", bytes.NewReader(spanJSON))
+		rec := httptest.NewRecorder()
+		col.HandlePostSpan(rec, req)
+		return rec
+	}
+
+	rec := post()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("starting: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	col.Drain()
+	rec = post()
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Connection") != "close" {
+		t.Errorf("draining: status %d, Connection %q", rec.Code, rec.Header().Get("Connection"))
+	}
+}
+
+func TestHandleInfo(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 3, ChannelBuffer: 10}, slog.Default())
+	col.setState(StateReady)
+	col.SubmitSpan(&models.Span{})
+
+	rec := httptest.NewRecorder()
+	col.HandleInfo(rec, httptest.NewRequest(http.MethodGet, "/api/v1/info", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var info struct {
+		State         string  `json:"state"`
+		UptimeSeconds float64 `json:"uptime_seconds"`
+		Workers       int     `json:"workers"`
+		QueueLength   int     `json:"queue_length"`
+		QueueCapacity int     `json:"queue_capacity"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if info.State != "ready" || info.Workers != 3 || info.QueueLength != 1 || info.QueueCapacity != 10 {
+		t.Errorf("info = %+v", info)
+	}
+	if info.UptimeSeconds < 0 {
+		t.Errorf("uptime = %g", info.UptimeSeconds)
+	}
+}
@@ -0,0 +1,287 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Materialized view aggregations
+const (
+	AggregateTraces    = "traces"     // The matching traces
+	AggregateByService = "by_service" // Per-service trace, error and latency summary
+)
+
+// MaterializedSpec configures a query that the collector refreshes on a
+// schedule and serves from memory, so dashboards polling it never hit the store.
+type MaterializedSpec struct {
+	Name      string `json:"name"`
+	Query     string `json:"query"`               // FindTraces parameters, e.g. "lookback=1h&service=api"
+	Aggregate string `json:"aggregate,omitempty"` // "traces" (default) or "by_service"
+	Interval  string `json:"interval,omitempty"`  // Refresh interval (default 30s)
+}
+
+// DefaultMaterializedInterval is the default refresh interval.
+const DefaultMaterializedInterval = 30 * time.Second
+
+// Validate checks the spec and returns a descriptive error.
+func (s MaterializedSpec) Validate() error {
+	if s.Name == "" {
+		return errors.New("name is required")
+	}
+	if strings.ContainsAny(s.Name, "/?#") {
+		return fmt.Errorf("view %q: name must not contain '/', '?' or '#'", s.Name)
+	}
+	if _, err := s.parse(); err != nil {
+		return fmt.Errorf("view %q: %w", s.Name, err)
+	}
+	return nil
+}
+
+// parse resolves the spec into its refresh interval and query parameters.
+func (s MaterializedSpec) parse() (*materializedView, error) {
+	view := &materializedView{spec: s, interval: DefaultMaterializedInterval}
+	if view.spec.Aggregate == "" {
+		view.spec.Aggregate = AggregateTraces
+	}
+	if view.spec.Aggregate != AggregateTraces && view.spec.Aggregate != AggregateByService {
+		return nil, fmt.Errorf("unknown aggregate %q", s.Aggregate)
+	}
+
+	if s.Interval != "" {
+		d, err := time.ParseDuration(s.Interval)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid interval %q", s.Interval)
+		}
+		view.interval = d
+	}
+
+	params, err := url.ParseQuery(s.Query)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query: %w", err)
+	}
+	if _, errs := parseQueryParams(params); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid query parameter %s: %s", errs[0].Param, errs[0].Reason)
+	}
+	view.params = params
+	return view, nil
+}
+
+// materializedView holds the latest result of one spec.
+type materializedView struct {
+	spec     MaterializedSpec
+	interval time.Duration
+	params   url.Values
+
+	mu          sync.RWMutex
+	result      interface{}
+	refreshedAt time.Time
+	lastErr     error
+}
+
+// ServiceSummary is one row of a by_service aggregate.
+type ServiceSummary struct {
+	Service       string  `json:"service"`
+	Traces        int     `json:"traces"`
+	ErrorTraces   int     `json:"error_traces"`
+	ErrorRate     float64 `json:"error_rate"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+}
+
+// startMaterialized starts one refresh loop per configured view.
+func (c *Collector) startMaterialized(ctx context.Context) {
+	for _, view := range c.materialized {
+		c.sweepWg.Add(1)
+		go c.refreshLoop(ctx, view)
+	}
+}
+
+// refreshLoop refreshes view immediately and then every interval.
+func (c *Collector) refreshLoop(ctx context.Context, view *materializedView) {
+	defer c.sweepWg.Done()
+
+	ticker := time.NewTicker(view.interval)
+	defer ticker.Stop()
+
+	for {
+		c.refreshView(ctx, view)
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshView recomputes a view from the store.
+func (c *Collector) refreshView(ctx context.Context, view *materializedView) {
+	// Relative times (lookback, -1h) are resolved at each refresh
+	query, _ := parseQueryParams(view.params)
+	if view.spec.Aggregate == AggregateByService && view.params.Get("limit") == "" {
+		query.Limit = 0 // Summarize every match unless limited explicitly
+	}
+
+	traces, err := c.store.FindTraces(ctx, query)
+	if err != nil {
+		c.logger.Error("failed to refresh materialized view", "view", view.spec.Name, "error", err)
+		view.mu.Lock()
+		view.lastErr = err
+		view.mu.Unlock()
+		return
+	}
+
+	var result interface{} = traces
+	if view.spec.Aggregate == AggregateByService {
+		result = summarizeByService(traces)
+	}
+
+	view.mu.Lock()
+	view.result = result
+	view.refreshedAt = time.Now()
+	view.lastErr = nil
+	view.mu.Unlock()
+}
+
+// summarizeByService counts traces, error traces and average duration per
+// service. A trace counts for every service it touches.
+func summarizeByService(traces []*models.Trace) []ServiceSummary {
+	type totals struct {
+		traces, errors int
+		duration       time.Duration
+	}
+	byService := make(map[string]*totals)
+
+	for _, trace := range traces {
+		failed := traceHasError(trace)
+		for _, service := range trace.Services {
+			t, ok := byService[service]
+			if !ok {
+				t = &totals{}
+				byService[service] = t
+			}
+			t.traces++
+			t.duration += trace.Duration
+			if failed {
+				t.errors++
+			}
+		}
+	}
+
+	result := make([]ServiceSummary, 0, len(byService))
+	for service, t := range byService {
+		result = append(result, ServiceSummary{
+			Service:       service,
+			Traces:        t.traces,
+			ErrorTraces:   t.errors,
+			ErrorRate:     float64(t.errors) / float64(t.traces),
+			AvgDurationMs: float64(t.duration.Milliseconds()) / float64(t.traces),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Service < result[j].Service
+	})
+	return result
+}
+
+// traceHasError reports whether any span of trace failed.
+func traceHasError(trace *models.Trace) bool {
+	for i := range trace.Spans {
+		if trace.Spans[i].IsError() {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleMaterialized handles GET /api/v1/materialized (list views) and
+// GET /api/v1/materialized/:name (latest result of one view).
+func (c *Collector) HandleMaterialized(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/api/v1/materialized"), "/")
+	if name == "" {
+		views := make([]map[string]interface{}, 0, len(c.materialized))
+		for _, view := range c.materialized {
+			view.mu.RLock()
+			views = append(views, map[string]interface{}{
+				"name":         view.spec.Name,
+				"query":        view.spec.Query,
+				"aggregate":    view.spec.Aggregate,
+				"interval":     view.interval.String(),
+				"refreshed_at": view.refreshedAt,
+			})
+			view.mu.RUnlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"views": views,
+			"total": len(views),
+		})
+		return
+	}
+
+	var view *materializedView
+	for _, v := range c.materialized {
+		if v.spec.Name == name {
+			view = v
+			break
+		}
+	}
+	if view == nil {
+		http.Error(w, "materialized view not found", http.StatusNotFound)
+		return
+	}
+
+	view.mu.RLock()
+	defer view.mu.RUnlock()
+	if view.refreshedAt.IsZero() {
+		http.Error(w, "materialized view not ready", http.StatusServiceUnavailable)
+		return
+	}
+
+	response := map[string]interface{}{
+		"name":         view.spec.Name,
+		"aggregate":    view.spec.Aggregate,
+		"refreshed_at": view.refreshedAt,
+		"result":       view.result,
+	}
+	if view.lastErr != nil {
+		response["stale"] = true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// newMaterializedViews builds views from specs. Invalid or duplicate specs
+// are logged and skipped; callers wanting a hard failure validate first.
+func newMaterializedViews(specs []MaterializedSpec, logger *slog.Logger) []*materializedView {
+	var views []*materializedView
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if err := spec.Validate(); err != nil {
+			logger.Error("skipping materialized view", "error", err)
+			continue
+		}
+		if seen[spec.Name] {
+			logger.Error("skipping duplicate materialized view", "view", spec.Name)
+			continue
+		}
+		seen[spec.Name] = true
+		view, _ := spec.parse()
+		views = append(views, view)
+	}
+	return views
+}
@@ -0,0 +1,112 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestMaterializedSpec_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    MaterializedSpec
+		wantErr bool
+	}{
+		{"valid", MaterializedSpec{Name: "api", Query: "service=api&lookback=1h", Interval: "10s"}, false},
+		{"by service", MaterializedSpec{Name: "summary", Aggregate: AggregateByService}, false},
+		{"missing name", MaterializedSpec{Query: "service=api"}, true},
+		{"slash in name", MaterializedSpec{Name: "a/b"}, true},
+		{"bad aggregate", MaterializedSpec{Name: "x", Aggregate: "p99"}, true},
+		{"bad interval", MaterializedSpec{Name: "x", Interval: "soon"}, true},
+		{"bad query param", MaterializedSpec{Name: "x", Query: "min_duration=fast"}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.spec.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestHandleMaterialized_ServesRefreshedResult(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	ctx := context.Background()
+	for _, span := range []*models.Span{
+		{ServiceName: "api", Status: "ok"},
+		{ServiceName: "api", Status: "error"},
+		{ServiceName: "db", Status: "ok"},
+	} {
+		span.TraceID = models.GenerateTraceID()
+		span.SpanID = models.GenerateSpanID()
+		span.OperationName = "test-op"
+		span.StartTime = time.Now()
+		store.WriteSpan(ctx, span)
+	}
+
+	col := NewCollector(store, &Config{
+		Workers:       1,
+		ChannelBuffer: 10,
+		Materialized: []MaterializedSpec{
+			{Name: "summary", Query: "lookback=1h", Aggregate: AggregateByService, Interval: "1h"},
+			{Name: "invalid", Aggregate: "p99"},
+		},
+	}, slog.Default())
+
+	rec := httptest.NewRecorder()
+	col.HandleMaterialized(rec, httptest.NewRequest(http.MethodGet, "/api/v1/materialized/summary", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status before refresh = %d, want 503", rec.Code)
+	}
+
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		rec = httptest.NewRecorder()
+		col.HandleMaterialized(rec, httptest.NewRequest(http.MethodGet, "/api/v1/materialized/summary", nil))
+		if rec.Code == http.StatusOK || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+
+	var response struct {
+		Result []ServiceSummary `json:"result"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(response.Result) != 2 {
+		t.Fatalf("got %d services, want 2: %+v", len(response.Result), response.Result)
+	}
+	api := response.Result[0]
+	if api.Service != "api" || api.Traces != 2 || api.ErrorTraces != 1 || api.ErrorRate != 0.5 {
+		t.Errorf("unexpected api summary: %+v", api)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleMaterialized(rec, httptest.NewRequest(http.MethodGet, "/api/v1/materialized/invalid", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("invalid view status = %d, want 404", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleMaterialized(rec, httptest.NewRequest(http.MethodGet, "/api/v1/materialized", nil))
+	var list struct {
+		Total int `json:"total"`
+	}
+	json.NewDecoder(rec.Body).Decode(&list)
+	if list.Total != 1 {
+		t.Errorf("listed %d views, want 1", list.Total)
+	}
+}
@@ -0,0 +1,61 @@
+package collector
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Headers SDKs and the collector exchange on HTTP span ingestion. SDKs
+// declare their version; every response advertises the collector's
+// Capabilities, so SDKs can stop using features the collector lacks.
+const (
+	SDKVersionHeader    = "X-Traceflow-SDK-Version"     // e.g. "go/1.2.0"
+	FeaturesHeader      = "X-Traceflow-Features"        // models.FormatFeatures
+	SchemaVersionHeader = "X-Traceflow-Schema-Version"  // Newest span schema_version read
+	MaxSpanEventsHeader = "X-Traceflow-Max-Span-Events" // Events a span may carry
+)
+
+// maxLoggedSDKVersions bounds the SDK versions remembered for logging, so
+// clients sending arbitrary versions cannot grow memory without limit.
+const maxLoggedSDKVersions = 256
+
+// Capabilities returns what this collector accepts on span ingestion.
+func Capabilities() models.Capabilities {
+	return models.Capabilities{
+		SchemaVersion: models.CurrentSchemaVersion,
+		Features: []string{
+			models.FeatureEvents,
+			models.FeatureGzip,
+			models.FeatureProtobuf,
+			models.FeatureZstd,
+		},
+		MaxSpanEvents: models.MaxSpanEvents,
+	}
+}
+
+// setCapabilityHeaders advertises Capabilities on an ingestion response.
+func setCapabilityHeaders(header http.Header) {
+	caps := Capabilities()
+	header.Set(FeaturesHeader, models.FormatFeatures(caps.Features))
+	header.Set(SchemaVersionHeader, strconv.Itoa(caps.SchemaVersion))
+	header.Set(MaxSpanEventsHeader, strconv.Itoa(caps.MaxSpanEvents))
+}
+
+// noteSDKVersion logs the first request from each SDK version, so operators
+// can see which SDKs are in use after a rollout.
+func (h *ingestHandler) noteSDKVersion(r *http.Request) {
+	version := r.Header.Get(SDKVersionHeader)
+	if version == "" {
+		return
+	}
+	if _, seen := h.sdkVersions.Load(version); seen || h.sdkVersionCount.Load() >= maxLoggedSDKVersions {
+		return
+	}
+	if _, seen := h.sdkVersions.LoadOrStore(version, struct{}{}); seen {
+		return
+	}
+	h.sdkVersionCount.Add(1)
+	h.logger.Info("first spans from SDK version", "sdk_version", version, "remote_addr", r.RemoteAddr)
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestIngest_AdvertisesCapabilities(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	col.setState(StateReady)
+
+	// Even refused requests advertise them
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/spans/batch", strings.NewReader("not json"))
+	req.Header.Set(SDKVersionHeader, "go/1.0.0")
+	rec := httptest.NewRecorder()
+	col.HandlePostSpansBatch(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", rec.Code)
+	}
+
+	features := models.ParseFeatures(rec.Header().Get(FeaturesHeader))
+	caps := models.Capabilities{Features: features}
+	for _, feature := range []string{models.FeatureEvents, models.FeatureProtobuf, models.FeatureGzip, models.FeatureZstd} {
+		if !caps.Supports(feature) {
+			t.Errorf("%s = %q, missing %s", FeaturesHeader, rec.Header().Get(FeaturesHeader), feature)
+		}
+	}
+	if got := rec.Header().Get(SchemaVersionHeader); got != strconv.Itoa(models.CurrentSchemaVersion) {
+		t.Errorf("%s = %q", SchemaVersionHeader, got)
+	}
+	if got := rec.Header().Get(MaxSpanEventsHeader); got != strconv.Itoa(models.MaxSpanEvents) {
+		t.Errorf("%s = %q", MaxSpanEventsHeader, got)
+	}
+
+	if info := col.Info(); !info.Capabilities.Supports(models.FeatureProtobuf) || info.Capabilities.SchemaVersion != models.CurrentSchemaVersion {
+		t.Errorf("info capabilities = %+v", info.Capabilities)
+	}
+}
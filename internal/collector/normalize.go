@@ -0,0 +1,29 @@
+package collector
+
+import (
+	"github.com/saintparish4/asmbly/models"
+)
+
+// NegativeDurationTag marks a span whose negative duration was clamped to
+// zero. Its value is the duration the client sent.
+const NegativeDurationTag = "traceflow.warning.negative_duration"
+
+// normalizeSpan puts span timestamps in UTC, so time indexes and query
+// results do not depend on the client's time zone, and clamps a negative
+// duration to zero. Durations only go negative when a client computes them
+// from wall-clock readings that jumped backwards, e.g. after an NTP step;
+// such a span is kept and tagged rather than rejected. It reports whether
+// the duration was clamped.
+func normalizeSpan(span *models.Span) bool {
+	span.StartTime = span.StartTime.UTC()
+	for i := range span.Events {
+		span.Events[i].Timestamp = span.Events[i].Timestamp.UTC()
+	}
+
+	if span.Duration >= 0 {
+		return false
+	}
+	span.SetTag(NegativeDurationTag, span.Duration.String())
+	span.Duration = 0
+	return true
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestNormalizeSpan(t *testing.T) {
+	zone := time.FixedZone("UTC+5", 5*60*60)
+	start := time.Date(2024, 3, 10, 14, 0, 0, 0, zone)
+	span := &models.Span{
+		StartTime: start,
+		Duration:  -250 * time.Millisecond,
+		Events:    []models.SpanEvent{{Name: "retry", Timestamp: start.Add(time.Millisecond)}},
+	}
+
+	if !normalizeSpan(span) {
+		t.Fatal("normalizeSpan() = false, want true for a negative duration")
+	}
+	if span.StartTime.Location() != time.UTC || !span.StartTime.Equal(start) {
+		t.Errorf("StartTime = %v, want %v in UTC", span.StartTime, start)
+	}
+	if span.Events[0].Timestamp.Location() != time.UTC {
+		t.Errorf("event timestamp = %v, want UTC", span.Events[0].Timestamp)
+	}
+	if span.Duration != 0 {
+		t.Errorf("Duration = %v, want 0", span.Duration)
+	}
+	if got := span.GetTag(NegativeDurationTag); got != "-250ms" {
+		t.Errorf("tag %s = %q, want -250ms", NegativeDurationTag, got)
+	}
+
+	span = &models.Span{StartTime: start, Duration: time.Second}
+	if normalizeSpan(span) || span.Duration != time.Second || span.GetTag(NegativeDurationTag) != "" {
+		t.Errorf("positive duration changed: %v, tags %v", span.Duration, span.Tags)
+	}
+}
+
+func TestProcessSpan_ClampsNegativeDuration(t *testing.T) {
+	store := storage.NewMemoryStore(100)
+	c := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now().In(time.FixedZone("UTC-8", -8*60*60)),
+		Duration:      -time.Second,
+		Status:        "ok",
+	}
+	if err := c.processSpan(context.Background(), span); err != nil {
+		t.Fatalf("processSpan() error = %v", err)
+	}
+
+	trace, err := store.GetTrace(context.Background(), span.TraceID)
+	if err != nil || trace == nil {
+		t.Fatalf("GetTrace() = %v, %v", trace, err)
+	}
+	got := trace.Spans[0]
+	if got.Duration != 0 || got.GetTag(NegativeDurationTag) != "-1s" {
+		t.Errorf("stored duration %v, tag %q", got.Duration, got.GetTag(NegativeDurationTag))
+	}
+	if got.StartTime.Location() != time.UTC {
+		t.Errorf("stored StartTime %v, want UTC", got.StartTime)
+	}
+}
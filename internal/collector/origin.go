@@ -0,0 +1,144 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Tags recording which client submitted a span. They overwrite any values
+// the client sent itself, so they can be trusted.
+const (
+	OriginAddressTag   = "traceflow.client.address"    // Client IP
+	OriginUserAgentTag = "traceflow.client.user_agent" // Exporter/SDK user agent
+	OriginIdentityTag  = "traceflow.client.identity"   // Value of OriginConfig.IdentityHeader
+)
+
+// OriginConfig enables tagging ingested spans with the submitting client,
+// so operators can tell which hosts send malformed or excessive data.
+type OriginConfig struct {
+	Enabled bool `json:"enabled"`
+
+	// TrustForwardedFor takes the client address from the first
+	// X-Forwarded-For entry. Only enable it behind a proxy that sets the
+	// header, since clients can forge it.
+	TrustForwardedFor bool `json:"trust_forwarded_for,omitempty"`
+
+	// IdentityHeader names a request header (gRPC metadata key) carrying a
+	// client identity such as a fleet or API key name, e.g. "X-Client-ID".
+	IdentityHeader string `json:"identity_header,omitempty"`
+}
+
+// httpOrigin returns the origin tags of an HTTP request, or nil if disabled.
+func (o OriginConfig) httpOrigin(r *http.Request) map[string]string {
+	if !o.Enabled {
+		return nil
+	}
+
+	var identity string
+	if o.IdentityHeader != "" {
+		identity = r.Header.Get(o.IdentityHeader)
+	}
+	return originTags(clientAddress(r, o.TrustForwardedFor), r.UserAgent(), identity)
+}
+
+// clientAddress returns the IP of the client that sent r, taken from the
+// first X-Forwarded-For entry if trustForwardedFor is set.
+func clientAddress(r *http.Request, trustForwardedFor bool) string {
+	if trustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// grpcOrigin returns the origin tags of a gRPC call, or nil if disabled.
+func (o OriginConfig) grpcOrigin(ctx context.Context) map[string]string {
+	if !o.Enabled {
+		return nil
+	}
+
+	var address string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		address = p.Addr.String()
+		if host, _, err := net.SplitHostPort(address); err == nil {
+			address = host
+		}
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+	if o.TrustForwardedFor {
+		if forwarded := first("x-forwarded-for"); forwarded != "" {
+			client, _, _ := strings.Cut(forwarded, ",")
+			address = strings.TrimSpace(client)
+		}
+	}
+
+	var identity string
+	if o.IdentityHeader != "" {
+		identity = first(o.IdentityHeader) // Keys are matched case-insensitively
+	}
+	return originTags(address, first("user-agent"), identity)
+}
+
+func originTags(address, userAgent, identity string) map[string]string {
+	tags := make(map[string]string, 3)
+	for key, value := range map[string]string{
+		OriginAddressTag:   address,
+		OriginUserAgentTag: userAgent,
+		OriginIdentityTag:  identity,
+	} {
+		if value != "" {
+			tags[key] = value
+		}
+	}
+	return tags
+}
+
+// withOrigin returns consumer tagging every span with tags, or consumer
+// itself when there are none.
+func withOrigin(consumer receiver.Consumer, tags map[string]string) receiver.Consumer {
+	if len(tags) == 0 {
+		return consumer
+	}
+	return &originConsumer{next: consumer, tags: tags}
+}
+
+// originConsumer adds origin tags to spans on their way to the consumer.
+type originConsumer struct {
+	next receiver.Consumer
+	tags map[string]string
+}
+
+func (c *originConsumer) SubmitSpan(span *models.Span) error {
+	if span.Tags == nil {
+		span.Tags = make(map[string]string, len(c.tags))
+	}
+	for key, value := range c.tags {
+		span.Tags[key] = value
+	}
+	return c.next.SubmitSpan(span)
+}
+
+// ThrottleHint forwards the wrapped consumer's hint.
+func (c *originConsumer) ThrottleHint() *models.ThrottleHint {
+	return receiver.ThrottleHintFor(c.next)
+}
@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// consumerFunc adapts a function to receiver.Consumer.
+type consumerFunc func(*models.Span) error
+
+func (f consumerFunc) SubmitSpan(span *models.Span) error { return f(span) }
+
+func TestIngestHandler_TagsOrigin(t *testing.T) {
+	var got *models.Span
+	consumer := consumerFunc(func(span *models.Span) error {
+		got = span
+		return nil
+	})
+	origin := OriginConfig{Enabled: true, TrustForwardedFor: true, IdentityHeader: "X-Client-ID"}
+	h := newIngestHandler(consumer, 0, origin, slog.Default())
+
+	span := models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+		Tags:          map[string]string{OriginAddressTag: "spoofed"},
+	}
+	body, _ := json.Marshal(span)
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/spans", bytes.NewReader(body))
+	req.RemoteAddr = "10.0.0.5:51234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.5")
+	req.Header.Set("User-Agent", "traceflow-go/1.2")
+	req.Header.Set("X-Client-ID", "checkout-fleet")
+
+	rec := httptest.NewRecorder()
+	h.handlePostSpan(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	want := map[string]string{
+		OriginAddressTag:   "203.0.113.7",
+		OriginUserAgentTag: "traceflow-go/1.2",
+		OriginIdentityTag:  "checkout-fleet",
+	}
+	for key, value := range want {
+		if got.Tags[key] != value {
+			t.Errorf("tag %s = %q, want %q", key, got.Tags[key], value)
+		}
+	}
+}
+
+func TestOriginConfig_Disabled(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/spans", nil)
+	if tags := (OriginConfig{}).httpOrigin(req); tags != nil {
+		t.Errorf("disabled origin produced tags %v", tags)
+	}
+
+	// Without trust, X-Forwarded-For is ignored
+	req.RemoteAddr = "10.0.0.5:51234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	if tags := (OriginConfig{Enabled: true}).httpOrigin(req); tags[OriginAddressTag] != "10.0.0.5" {
+		t.Errorf("address = %q, want the peer address", tags[OriginAddressTag])
+	}
+}
+
+func TestOriginConfig_GRPC(t *testing.T) {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{
+		Addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.10"), Port: 40000},
+	})
+	ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("user-agent", "grpc-go/1.60", "x-client-id", "batch-fleet"))
+
+	tags := OriginConfig{Enabled: true, IdentityHeader: "X-Client-ID"}.grpcOrigin(ctx)
+	if tags[OriginAddressTag] != "192.0.2.10" || tags[OriginUserAgentTag] != "grpc-go/1.60" || tags[OriginIdentityTag] != "batch-fleet" {
+		t.Errorf("tags = %v", tags)
+	}
+}
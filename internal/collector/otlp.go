@@ -0,0 +1,215 @@
+package collector
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// OTLP resource attributes mapped onto dedicated span fields
+const (
+	otlpServiceName = "service.name"
+	otlpVersion     = "service.version"
+	otlpEnvironment = "deployment.environment"
+)
+
+// submitOTLP converts an export request and submits its spans. Rejected
+// spans are reported as a partial success; if the consumer refused every
+// span, its error is returned so callers can signal a retryable failure.
+func submitOTLP(consumer receiver.Consumer, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	spans, rejected := SpansFromOTLP(req)
+
+	accepted := 0
+	var submitErr error
+	for _, span := range spans {
+		if err := consumer.SubmitSpan(span); err != nil {
+			submitErr = err
+			rejected++
+			continue
+		}
+		accepted++
+	}
+	if accepted == 0 && submitErr != nil {
+		return nil, submitErr
+	}
+
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	if rejected > 0 {
+		resp.PartialSuccess = &coltracepb.ExportTracePartialSuccess{
+			RejectedSpans: int64(rejected),
+			ErrorMessage:  fmt.Sprintf("%d spans rejected", rejected),
+		}
+	}
+	return resp, nil
+}
+
+// countOTLPSpans returns the number of spans in req.
+func countOTLPSpans(req *coltracepb.ExportTraceServiceRequest) int {
+	n := 0
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			n += len(ss.Spans)
+		}
+	}
+	return n
+}
+
+// SpansFromOTLP converts an OTLP export request into spans. Resource
+// attributes are copied into each span's tags; span attributes win on
+// conflicts. Spans with malformed IDs are counted in rejected.
+func SpansFromOTLP(req *coltracepb.ExportTraceServiceRequest) (spans []*models.Span, rejected int) {
+	for _, rs := range req.GetResourceSpans() {
+		resourceTags := make(map[string]string)
+		for _, kv := range rs.GetResource().GetAttributes() {
+			resourceTags[kv.GetKey()] = anyValueString(kv.GetValue())
+		}
+		serviceName := resourceTags[otlpServiceName]
+		if serviceName == "" {
+			serviceName = "unknown_service"
+		}
+
+		for _, ss := range rs.GetScopeSpans() {
+			scope := ss.GetScope().GetName()
+			for _, s := range ss.GetSpans() {
+				span, err := spanFromOTLP(s, serviceName, resourceTags, scope)
+				if err != nil {
+					rejected++
+					continue
+				}
+				spans = append(spans, span)
+			}
+		}
+	}
+	return spans, rejected
+}
+
+func spanFromOTLP(s *tracepb.Span, serviceName string, resourceTags map[string]string, scope string) (*models.Span, error) {
+	if len(s.GetTraceId()) != 16 || len(s.GetSpanId()) != 8 {
+		return nil, fmt.Errorf("invalid trace or span id length")
+	}
+
+	span := &models.Span{
+		TraceID:       hex.EncodeToString(s.GetTraceId()),
+		SpanID:        hex.EncodeToString(s.GetSpanId()),
+		ServiceName:   serviceName,
+		OperationName: s.GetName(),
+		StartTime:     time.Unix(0, int64(s.GetStartTimeUnixNano())).UTC(),
+		SpanKind:      otlpSpanKind(s.GetKind()),
+		Status:        "ok",
+		DeploymentID:  resourceTags[otlpVersion],
+		Environment:   resourceTags[otlpEnvironment],
+		Tags:          make(map[string]string, len(resourceTags)+len(s.GetAttributes())),
+	}
+	if len(s.GetParentSpanId()) == 8 {
+		span.ParentSpanID = hex.EncodeToString(s.GetParentSpanId())
+	}
+	// Signed, so an end before the start is clamped and tagged downstream
+	if end := s.GetEndTimeUnixNano(); end != 0 {
+		span.Duration = time.Duration(int64(end) - int64(s.GetStartTimeUnixNano()))
+	}
+	if s.GetStatus().GetCode() == tracepb.Status_STATUS_CODE_ERROR {
+		span.Status = "error"
+		span.StatusMessage = s.GetStatus().GetMessage()
+	}
+
+	for k, v := range resourceTags {
+		if k != otlpServiceName {
+			span.Tags[k] = v
+		}
+	}
+	if scope != "" {
+		span.Tags["otel.scope.name"] = scope
+	}
+	for _, kv := range s.GetAttributes() {
+		span.Tags[kv.GetKey()] = anyValueString(kv.GetValue())
+	}
+	for _, e := range s.GetEvents() {
+		event := models.SpanEvent{
+			Name:      e.GetName(),
+			Timestamp: time.Unix(0, int64(e.GetTimeUnixNano())).UTC(),
+		}
+		if len(e.GetAttributes()) > 0 {
+			event.Attributes = make(map[string]string, len(e.GetAttributes()))
+			for _, kv := range e.GetAttributes() {
+				event.Attributes[kv.GetKey()] = anyValueString(kv.GetValue())
+			}
+		}
+		if len(span.Events) < models.MaxSpanEvents {
+			span.Events = append(span.Events, event)
+		}
+	}
+
+	return span, nil
+}
+
+// otlpSpanKind maps OTLP span kinds to the model's kind names.
+func otlpSpanKind(kind tracepb.Span_SpanKind) string {
+	switch kind {
+	case tracepb.Span_SPAN_KIND_SERVER:
+		return "server"
+	case tracepb.Span_SPAN_KIND_CLIENT:
+		return "client"
+	case tracepb.Span_SPAN_KIND_PRODUCER:
+		return "producer"
+	case tracepb.Span_SPAN_KIND_CONSUMER:
+		return "consumer"
+	default:
+		return "internal"
+	}
+}
+
+// anyValueString flattens an attribute value into a tag string.
+// Arrays and maps are rendered as JSON.
+func anyValueString(v *commonpb.AnyValue) string {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return value.StringValue
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(value.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(value.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(value.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_BytesValue:
+		return hex.EncodeToString(value.BytesValue)
+	case *commonpb.AnyValue_ArrayValue, *commonpb.AnyValue_KvlistValue:
+		data, _ := json.Marshal(anyValueInterface(v))
+		return string(data)
+	default:
+		return ""
+	}
+}
+
+func anyValueInterface(v *commonpb.AnyValue) interface{} {
+	switch value := v.GetValue().(type) {
+	case *commonpb.AnyValue_ArrayValue:
+		items := make([]interface{}, 0, len(value.ArrayValue.GetValues()))
+		for _, item := range value.ArrayValue.GetValues() {
+			items = append(items, anyValueInterface(item))
+		}
+		return items
+	case *commonpb.AnyValue_KvlistValue:
+		m := make(map[string]interface{}, len(value.KvlistValue.GetValues()))
+		for _, kv := range value.KvlistValue.GetValues() {
+			m[kv.GetKey()] = anyValueInterface(kv.GetValue())
+		}
+		return m
+	case *commonpb.AnyValue_BoolValue:
+		return value.BoolValue
+	case *commonpb.AnyValue_IntValue:
+		return value.IntValue
+	case *commonpb.AnyValue_DoubleValue:
+		return value.DoubleValue
+	default:
+		return anyValueString(v)
+	}
+}
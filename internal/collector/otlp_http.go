@@ -0,0 +1,141 @@
+package collector
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP/HTTP content types
+const (
+	otlpProtobufContentType = "application/x-protobuf"
+	otlpJSONContentType     = "application/json"
+)
+
+// handleOTLPTraces handles POST /v1/traces - OTLP/HTTP trace export in
+// protobuf or JSON encoding, so stock OpenTelemetry SDKs can send to the
+// collector directly.
+func (h *ingestHandler) handleOTLPTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if contentType != otlpProtobufContentType && contentType != otlpJSONContentType {
+		http.Error(w, "unsupported content type, want application/x-protobuf or application/json", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		h.writeBodyError(w, err)
+		return
+	}
+
+	// Decode request
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if contentType == otlpJSONContentType {
+		err = unmarshalOTLPJSON(body, req)
+	} else {
+		err = proto.Unmarshal(body, req)
+	}
+	if err != nil {
+		h.logger.Error("failed to parse OTLP request", "content_type", contentType, "error", err)
+		http.Error(w, "invalid OTLP payload", http.StatusBadRequest)
+		return
+	}
+	if !h.admit(w, r, countOTLPSpans(req)) {
+		return
+	}
+
+	// Convert and submit; if nothing could be queued, ask for a retry
+	resp, err := submitOTLP(withOrigin(h.consumer, h.origin.httpOrigin(r)), req)
+	if err != nil {
+		h.logger.Error("failed to submit OTLP spans", "error", err)
+		h.writeSubmitError(w, err)
+		return
+	}
+	h.setThrottleHeaders(w)
+
+	var data []byte
+	if contentType == otlpJSONContentType {
+		data, err = protojson.Marshal(resp)
+	} else {
+		data, err = proto.Marshal(resp)
+	}
+	if err != nil {
+		http.Error(w, "failed to encode response", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// unmarshalOTLPJSON decodes OTLP/JSON. It differs from protojson in one way:
+// trace and span IDs are hex strings rather than base64, so they are
+// rewritten before decoding.
+func unmarshalOTLPJSON(data []byte, req *coltracepb.ExportTraceServiceRequest) error {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	for _, rs := range jsonArray(doc["resourceSpans"]) {
+		for _, ss := range jsonArray(jsonObject(rs)["scopeSpans"]) {
+			for _, s := range jsonArray(jsonObject(ss)["spans"]) {
+				span := jsonObject(s)
+				if err := hexIDsToBase64(span, "traceId", "spanId", "parentSpanId"); err != nil {
+					return err
+				}
+				for _, link := range jsonArray(span["links"]) {
+					if err := hexIDsToBase64(jsonObject(link), "traceId", "spanId"); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	converted, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(converted, req)
+}
+
+func hexIDsToBase64(obj map[string]interface{}, keys ...string) error {
+	for _, key := range keys {
+		id, ok := obj[key].(string)
+		if !ok || id == "" {
+			continue
+		}
+		raw, err := hex.DecodeString(id)
+		if err != nil {
+			return fmt.Errorf("%s: invalid hex id %q", key, id)
+		}
+		obj[key] = base64.StdEncoding.EncodeToString(raw)
+	}
+	return nil
+}
+
+func jsonArray(v interface{}) []interface{} {
+	a, _ := v.([]interface{})
+	return a
+}
+
+func jsonObject(v interface{}) map[string]interface{} {
+	m, _ := v.(map[string]interface{})
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
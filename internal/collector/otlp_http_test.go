@@ -0,0 +1,198 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func stringAttr(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func startOTLPCollector(t *testing.T) (*Collector, *storage.MemoryStore) {
+	t.Helper()
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 2, ChannelBuffer: 10}, slog.Default())
+	col.Start(context.Background())
+	t.Cleanup(func() { col.Stop(context.Background()) })
+	return col, store
+}
+
+func TestHandleOTLPTraces_Protobuf(t *testing.T) {
+	col, store := startOTLPCollector(t)
+
+	traceID, _ := hex.DecodeString(models.GenerateTraceID())
+	rootID, _ := hex.DecodeString(models.GenerateSpanID())
+	childID, _ := hex.DecodeString(models.GenerateSpanID())
+	start := time.Now().Add(-time.Second)
+
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{
+				stringAttr("service.name", "checkout"),
+				stringAttr("service.version", "1.4.2"),
+				stringAttr("host.name", "node-1"),
+			}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Scope: &commonpb.InstrumentationScope{Name: "net/http"},
+				Spans: []*tracepb.Span{
+					{
+						TraceId:           traceID,
+						SpanId:            rootID,
+						Name:              "POST /checkout",
+						Kind:              tracepb.Span_SPAN_KIND_SERVER,
+						StartTimeUnixNano: uint64(start.UnixNano()),
+						EndTimeUnixNano:   uint64(start.Add(250 * time.Millisecond).UnixNano()),
+						Attributes:        []*commonpb.KeyValue{stringAttr("http.method", "POST")},
+					},
+					{
+						TraceId:           traceID,
+						SpanId:            childID,
+						ParentSpanId:      rootID,
+						Name:              "charge card",
+						Kind:              tracepb.Span_SPAN_KIND_CLIENT,
+						StartTimeUnixNano: uint64(start.Add(10 * time.Millisecond).UnixNano()),
+						EndTimeUnixNano:   uint64(start.Add(200 * time.Millisecond).UnixNano()),
+						Status:            &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: "declined"},
+						Events: []*tracepb.Span_Event{{
+							Name:         "retry",
+							TimeUnixNano: uint64(start.Add(50 * time.Millisecond).UnixNano()),
+							Attributes:   []*commonpb.KeyValue{stringAttr("attempt", "2")},
+						}},
+					},
+					{TraceId: []byte{1, 2}, SpanId: childID, Name: "bad ids"},
+				},
+			}},
+		}},
+	}
+	body, _ := proto.Marshal(req)
+
+	httpReq := httptest.NewRequest(http.MethodPost, "/v1/traces", bytes.NewReader(body))
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	rec := httptest.NewRecorder()
+	col.HandleOTLPTraces(rec, httpReq)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	resp := &coltracepb.ExportTraceServiceResponse{}
+	if err := proto.Unmarshal(rec.Body.Bytes(), resp); err != nil {
+		t.Fatalf("invalid protobuf response: %v", err)
+	}
+	if got := resp.GetPartialSuccess().GetRejectedSpans(); got != 1 {
+		t.Errorf("rejected spans = %d, want 1", got)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	trace, _ := store.GetTrace(context.Background(), hex.EncodeToString(traceID))
+	if trace == nil || len(trace.Spans) != 2 {
+		t.Fatalf("expected 2 stored spans, got %v", trace)
+	}
+
+	for _, span := range trace.Spans {
+		if span.ServiceName != "checkout" || span.DeploymentID != "1.4.2" {
+			t.Errorf("resource mapping wrong: service=%q deployment=%q", span.ServiceName, span.DeploymentID)
+		}
+		if span.Tags["host.name"] != "node-1" || span.Tags["otel.scope.name"] != "net/http" {
+			t.Errorf("missing resource/scope tags: %v", span.Tags)
+		}
+		switch span.OperationName {
+		case "POST /checkout":
+			if span.SpanKind != "server" || span.Duration != 250*time.Millisecond || span.Tags["http.method"] != "POST" {
+				t.Errorf("root span mapped wrong: %+v", span)
+			}
+		case "charge card":
+			if span.ParentSpanID != hex.EncodeToString(rootID) || span.Status != "error" || span.StatusMessage != "declined" {
+				t.Errorf("child span mapped wrong: %+v", span)
+			}
+			if len(span.Events) != 1 || span.Events[0].Name != "retry" || span.Events[0].Attributes["attempt"] != "2" {
+				t.Errorf("span events mapped wrong: %+v", span.Events)
+			}
+		}
+	}
+}
+
+func TestHandleOTLPTraces_JSONGzip(t *testing.T) {
+	col, store := startOTLPCollector(t)
+
+	traceID := models.GenerateTraceID()
+	start := time.Now().Add(-time.Second).UnixNano()
+	payload := `{"resourceSpans":[{"resource":{"attributes":[{"key":"service.name","value":{"stringValue":"web"}}]},
+		"scopeSpans":[{"spans":[{"traceId":"` + traceID + `","spanId":"` + models.GenerateSpanID() + `",
+		"name":"GET /","kind":2,"startTimeUnixNano":"` + itoa(start) + `","endTimeUnixNano":"` + itoa(start+int64(time.Millisecond)) + `",
+		"attributes":[{"key":"retries","value":{"intValue":"3"}}]}]}]}]}`
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write([]byte(payload))
+	gz.Close()
+
+	httpReq := httptest.NewRequest(http.MethodPost, "/v1/traces", &buf)
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	col.HandleOTLPTraces(rec, httpReq)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("response content type = %q", ct)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	trace, _ := store.GetTrace(context.Background(), traceID)
+	if trace == nil || len(trace.Spans) != 1 {
+		t.Fatalf("expected 1 stored span, got %v", trace)
+	}
+	span := trace.Spans[0]
+	if span.ServiceName != "web" || span.SpanKind != "server" || span.Tags["retries"] != "3" {
+		t.Errorf("span mapped wrong: %+v", span)
+	}
+}
+
+func TestHandleOTLPTraces_Rejects(t *testing.T) {
+	col, _ := startOTLPCollector(t)
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        int
+	}{
+		{"unsupported content type", "text/plain", "x", http.StatusUnsupportedMediaType},
+		{"invalid json", "application/json", "{", http.StatusBadRequest},
+		{"non-hex id", "application/json", `{"resourceSpans":[{"scopeSpans":[{"spans":[{"traceId":"zz"}]}]}]}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/v1/traces", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+		rec := httptest.NewRecorder()
+		col.HandleOTLPTraces(rec, req)
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func itoa(n int64) string {
+	return strconv.FormatInt(n, 10)
+}
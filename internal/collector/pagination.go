@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// pageLinks are the next/prev URLs for a paginated FindTraces response.
+type pageLinks struct {
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+// buildPageLinks derives next/prev URLs from the request by rewriting offset,
+// keeping every other parameter (filters, fields, limit) as sent.
+func buildPageLinks(r *http.Request, offset, limit int, hasNext bool) pageLinks {
+	return buildParamPageLinks(r, "offset", "limit", offset, limit, hasNext)
+}
+
+// buildParamPageLinks is buildPageLinks for pages selected by the named
+// offset and limit parameters.
+func buildParamPageLinks(r *http.Request, offsetParam, limitParam string, offset, limit int, hasNext bool) pageLinks {
+	var links pageLinks
+	if limit <= 0 {
+		return links // Unpaginated
+	}
+
+	pageURL := func(offset int) string {
+		params := r.URL.Query()
+		params.Set(offsetParam, strconv.Itoa(offset))
+		params.Set(limitParam, strconv.Itoa(limit))
+		u := url.URL{Path: r.URL.Path, RawQuery: params.Encode()}
+		return u.String()
+	}
+
+	if hasNext {
+		links.Next = pageURL(offset + limit)
+	}
+	if offset > 0 {
+		prev := offset - limit
+		if prev < 0 {
+			prev = 0
+		}
+		links.Prev = pageURL(prev)
+	}
+	return links
+}
+
+// setLinkHeader writes the links as an RFC 5988 Link header.
+func setLinkHeader(w http.ResponseWriter, links pageLinks) {
+	var parts []string
+	if links.Next != "" {
+		parts = append(parts, fmt.Sprintf(`<%s>; rel="next"`, links.Next))
+	}
+	if links.Prev != "" {
+		parts = append(parts, fmt.Sprintf(`<%s>; rel="prev"`, links.Prev))
+	}
+	if len(parts) > 0 {
+		w.Header().Set("Link", strings.Join(parts, ", "))
+	}
+}
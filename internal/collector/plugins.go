@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/saintparish4/asmbly/internal/events"
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// errSpanDropped signals that a processor intentionally discarded a span
+var errSpanDropped = errors.New("span dropped by processor")
+
+// exporterQueueSize bounds each exporter's pending span queue
+const exporterQueueSize = 1000
+
+// runProcessors passes a span through every processor in order.
+// It returns a nil span if any processor dropped it.
+func (c *Collector) runProcessors(ctx context.Context, span *models.Span) (*models.Span, error) {
+	for _, p := range c.processors {
+		var err error
+		span, err = p.Process(ctx, span)
+		if err != nil {
+			return nil, fmt.Errorf("processor %s: %w", p.Name(), err)
+		}
+		if span == nil {
+			return nil, nil
+		}
+	}
+	return span, nil
+}
+
+// startExporters subscribes each exporter to stored spans.
+func (c *Collector) startExporters(ctx context.Context) {
+	for _, exp := range c.exporters {
+		sub := c.events.Subscribe(exporterQueueSize, events.SpanStored)
+
+		c.exportWg.Add(1)
+		go func(exp plugin.Exporter, sub *events.Subscription) {
+			defer c.exportWg.Done()
+
+			for evt := range sub.C() {
+				if err := exp.Export(ctx, evt.Span); err != nil {
+					c.logger.Warn("exporter failed",
+						"exporter", exp.Name(),
+						"trace_id", evt.Span.TraceID,
+						"span_id", evt.Span.SpanID,
+						"error", err,
+					)
+				}
+			}
+		}(exp, sub)
+	}
+}
+
+// stopExporters waits for exporters to drain and shuts them down.
+// It must be called after the event bus is closed.
+func (c *Collector) stopExporters(ctx context.Context) error {
+	c.exportWg.Wait()
+
+	var errs []error
+	for _, exp := range c.exporters {
+		if err := exp.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("exporter %s: %w", exp.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// dropOperation drops spans for one operation and tags the rest
+type dropOperation struct {
+	operation string
+}
+
+func (p *dropOperation) Name() string { return "drop_operation" }
+
+func (p *dropOperation) Process(ctx context.Context, span *models.Span) (*models.Span, error) {
+	if span.OperationName == p.operation {
+		return nil, nil
+	}
+	span.SetTag("processed", "true")
+	return span, nil
+}
+
+// recordingExporter remembers exported span IDs
+type recordingExporter struct {
+	mu       sync.Mutex
+	spanIDs  []string
+	shutdown bool
+}
+
+func (e *recordingExporter) Name() string { return "recording" }
+
+func (e *recordingExporter) Export(ctx context.Context, span *models.Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.spanIDs = append(e.spanIDs, span.SpanID)
+	return nil
+}
+
+func (e *recordingExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdown = true
+	return nil
+}
+
+func TestPlugins_ProcessorsAndExporters(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	exporter := &recordingExporter{}
+	config := &Config{
+		Workers:       2,
+		ChannelBuffer: 10,
+		Processors:    []plugin.Processor{&dropOperation{operation: "GET /health"}},
+		Exporters:     []plugin.Exporter{exporter},
+	}
+	col := NewCollector(store, config, slog.Default())
+
+	ctx := context.Background()
+	col.Start(ctx)
+
+	kept := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "GET /users",
+		StartTime:     time.Now(),
+		Status:        "ok",
+	}
+	dropped := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "GET /health",
+		StartTime:     time.Now(),
+		Status:        "ok",
+	}
+	col.SubmitSpan(kept)
+	col.SubmitSpan(dropped)
+
+	if err := col.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+
+	metrics := col.GetMetrics()
+	if metrics.SpansStored != 1 || metrics.SpansDropped != 1 {
+		t.Errorf("stored=%d dropped=%d, want 1 and 1", metrics.SpansStored, metrics.SpansDropped)
+	}
+
+	trace, _ := store.GetTrace(ctx, kept.TraceID)
+	if trace == nil || trace.Spans[0].GetTag("processed") != "true" {
+		t.Error("kept span should be stored with processor tag")
+	}
+	if trace, _ := store.GetTrace(ctx, dropped.TraceID); trace != nil {
+		t.Error("dropped span should not be stored")
+	}
+
+	exporter.mu.Lock()
+	defer exporter.mu.Unlock()
+	if len(exporter.spanIDs) != 1 || exporter.spanIDs[0] != kept.SpanID {
+		t.Errorf("exported %v, want [%s]", exporter.spanIDs, kept.SpanID)
+	}
+	if !exporter.shutdown {
+		t.Error("exporter should be shut down on Stop")
+	}
+}
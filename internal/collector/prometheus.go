@@ -0,0 +1,180 @@
+package collector
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+)
+
+// Ingestion endpoint labels
+const (
+	ingestSpan   = "span"
+	ingestBatch  = "batch"
+	ingestOTLP   = "otlp"
+	ingestZipkin = "zipkin"
+)
+
+// Histogram bucket upper bounds
+var (
+	ingestLatencyBuckets = []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5}
+	spanLatencyBuckets   = []float64{0.00001, 0.000025, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.05, 0.25, 1}
+	queueFillBuckets     = []float64{0.1, 0.25, 0.5, 0.75, 0.8, 0.9, 0.95, 1}
+)
+
+// pipelineMetrics are histograms of the span path from HTTP request to
+// storage. Counters show how much got through; these show where it slows
+// down, so saturation can be alerted on before the queue overflows.
+type pipelineMetrics struct {
+	ingestDuration  *prometheus.HistogramVec // Seconds per ingestion request, by endpoint
+	queueFill       prometheus.Histogram     // Queue length over capacity, per queued span
+	workerDuration  prometheus.Histogram     // Seconds a worker spends on one span
+	storageDuration prometheus.Histogram     // Seconds per store write
+}
+
+func newPipelineMetrics() *pipelineMetrics {
+	return &pipelineMetrics{
+		ingestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "traceflow_ingest_request_duration_seconds",
+			Help:    "Span ingestion request latency, including refused requests",
+			Buckets: ingestLatencyBuckets,
+		}, []string{"endpoint"}),
+		queueFill: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "traceflow_queue_fill_ratio",
+			Help:    "Span queue depth as a fraction of its capacity, sampled as each span is queued",
+			Buckets: queueFillBuckets,
+		}),
+		workerDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "traceflow_worker_span_duration_seconds",
+			Help:    "Time a worker spends processing and storing one span",
+			Buckets: spanLatencyBuckets,
+		}),
+		storageDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "traceflow_storage_write_duration_seconds",
+			Help:    "Span store write latency",
+			Buckets: spanLatencyBuckets,
+		}),
+	}
+}
+
+// timeIngest records the latency of ingestion requests to endpoint.
+func (c *Collector) timeIngest(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next(w, r)
+		c.pipeline.ingestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	}
+}
+
+var (
+	spansReceivedDesc = prometheus.NewDesc("traceflow_spans_received_total",
+		"Total number of spans received", nil, nil)
+	spansStoredDesc = prometheus.NewDesc("traceflow_spans_stored_total",
+		"Total number of spans stored", nil, nil)
+	spanErrorsDesc = prometheus.NewDesc("traceflow_span_errors_total",
+		"Total number of span errors", nil, nil)
+	spansDroppedDesc = prometheus.NewDesc("traceflow_spans_dropped_total",
+		"Total number of spans dropped by processors", nil, nil)
+	spansShedDesc = prometheus.NewDesc("traceflow_spans_shed_total",
+		"Spans of whole traces discarded by load shedding", nil, nil)
+	spansSpilledDesc = prometheus.NewDesc("traceflow_spans_spilled_total",
+		"Spans written to disk while the queue was full", nil, nil)
+	ingestInFlightDesc = prometheus.NewDesc("traceflow_ingest_requests_in_flight",
+		"Ingestion requests being handled", nil, nil)
+	ingestRejectedDesc = prometheus.NewDesc("traceflow_ingest_requests_rejected_total",
+		"Ingestion requests refused by the concurrency limit", nil, nil)
+	ingestRateLimitedDesc = prometheus.NewDesc("traceflow_ingest_requests_rate_limited_total",
+		"Ingestion requests refused by the per-client rate limit", nil, nil)
+	ingestReplayedDesc = prometheus.NewDesc("traceflow_ingest_requests_replayed_total",
+		"Batch submissions answered from the Idempotency-Key cache instead of ingested again", nil, nil)
+	queueDepthDesc = prometheus.NewDesc("traceflow_queue_depth",
+		"Spans waiting for a worker", nil, nil)
+	queueCapacityDesc = prometheus.NewDesc("traceflow_queue_capacity",
+		"Spans the queue holds before ingestion is refused", nil, nil)
+	evictionsDesc = prometheus.NewDesc("traceflow_storage_evictions_total",
+		"Traces evicted by the store, by reason (capacity or retention)", []string{"reason"}, nil)
+	archiveFilesDesc = prometheus.NewDesc("traceflow_archive_files_total",
+		"Files written to the trace archive", nil, nil)
+	archiveTracesDesc = prometheus.NewDesc("traceflow_archive_traces_total",
+		"Traces written to the trace archive", nil, nil)
+	archiveDroppedDesc = prometheus.NewDesc("traceflow_archive_traces_dropped_total",
+		"Traces discarded after failed archive writes overflowed its buffer", nil, nil)
+	archiveFailedDesc = prometheus.NewDesc("traceflow_archive_write_failures_total",
+		"Failed trace archive file writes", nil, nil)
+	chaosInjectedDesc = prometheus.NewDesc("traceflow_chaos_injected_total",
+		"Failures injected by chaos mode, by fault (storage_error, drop, reject or latency)", []string{"fault"}, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		spansReceivedDesc, spansStoredDesc, spanErrorsDesc, spansDroppedDesc, spansShedDesc, spansSpilledDesc,
+		ingestInFlightDesc, ingestRejectedDesc, ingestRateLimitedDesc, ingestReplayedDesc,
+		queueDepthDesc, queueCapacityDesc, evictionsDesc,
+		archiveFilesDesc, archiveTracesDesc, archiveDroppedDesc, archiveFailedDesc,
+		chaosInjectedDesc,
+	} {
+		ch <- desc
+	}
+	c.pipeline.ingestDuration.Describe(ch)
+	c.pipeline.queueFill.Describe(ch)
+	c.pipeline.workerDuration.Describe(ch)
+	c.pipeline.storageDuration.Describe(ch)
+	c.queryMetrics.Describe(ch)
+	c.redMetrics.Describe(ch)
+	c.ingestLag.Describe(ch)
+}
+
+// Collect implements prometheus.Collector, so a registry can export the
+// collector's counters, pipeline histograms, query, RED and ingest lag
+// metrics.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	metrics := c.GetMetrics()
+	counter := func(desc *prometheus.Desc, value int64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, float64(value))
+	}
+	counter(spansReceivedDesc, metrics.SpansReceived)
+	counter(spansStoredDesc, metrics.SpansStored)
+	counter(spanErrorsDesc, metrics.SpanErrors)
+	counter(spansDroppedDesc, metrics.SpansDropped)
+	counter(spansShedDesc, metrics.SpansShed)
+	counter(spansSpilledDesc, metrics.SpansSpilled)
+	counter(ingestRejectedDesc, metrics.IngestRejected)
+	counter(ingestRateLimitedDesc, metrics.IngestRateLimited)
+	counter(ingestReplayedDesc, metrics.IngestReplayed)
+	ch <- prometheus.MustNewConstMetric(ingestInFlightDesc, prometheus.GaugeValue, float64(metrics.IngestInFlight))
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(len(c.spanCh)))
+	ch <- prometheus.MustNewConstMetric(queueCapacityDesc, prometheus.GaugeValue, float64(cap(c.spanCh)))
+
+	if c.archive != nil {
+		stats := c.archive.Stats()
+		counter(archiveFilesDesc, stats.Files)
+		counter(archiveTracesDesc, stats.Traces)
+		counter(archiveDroppedDesc, stats.Dropped)
+		counter(archiveFailedDesc, stats.Failed)
+	}
+
+	if c.chaos != nil {
+		injected := c.chaos.counts()
+		ch <- prometheus.MustNewConstMetric(chaosInjectedDesc, prometheus.CounterValue, float64(injected.StorageErrors), "storage_error")
+		ch <- prometheus.MustNewConstMetric(chaosInjectedDesc, prometheus.CounterValue, float64(injected.SpansDropped), "drop")
+		ch <- prometheus.MustNewConstMetric(chaosInjectedDesc, prometheus.CounterValue, float64(injected.RequestsRejected), "reject")
+		ch <- prometheus.MustNewConstMetric(chaosInjectedDesc, prometheus.CounterValue, float64(injected.SpansDelayed), "latency")
+	}
+
+	if evictor, ok := c.store.(storage.Evictor); ok {
+		evictions := evictor.Evictions()
+		ch <- prometheus.MustNewConstMetric(evictionsDesc, prometheus.CounterValue, float64(evictions.Capacity), "capacity")
+		ch <- prometheus.MustNewConstMetric(evictionsDesc, prometheus.CounterValue, float64(evictions.Retention), "retention")
+	}
+
+	c.pipeline.ingestDuration.Collect(ch)
+	c.pipeline.queueFill.Collect(ch)
+	c.pipeline.workerDuration.Collect(ch)
+	c.pipeline.storageDuration.Collect(ch)
+	c.queryMetrics.Collect(ch)
+	c.redMetrics.Collect(ch)
+	c.ingestLag.Collect(ch)
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// scrape registers collectors in a pedantic registry and returns the
+// /metrics text they produce.
+func scrape(t *testing.T, collectors ...prometheus.Collector) string {
+	t.Helper()
+	registry := prometheus.NewPedanticRegistry()
+	for _, c := range collectors {
+		if err := registry.Register(c); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+	}
+	rec := httptest.NewRecorder()
+	promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.PanicOnError}).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return rec.Body.String()
+}
+
+func TestCollector_Collect(t *testing.T) {
+	store := storage.NewMemoryStore(2)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+	col.Start(ctx)
+
+	// Three traces in a store that keeps two: one eviction
+	var spans []*models.Span
+	for i := 0; i < 3; i++ {
+		spans = append(spans, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /users",
+			StartTime:     time.Now().Add(time.Duration(i) * time.Millisecond),
+			Duration:      10 * time.Millisecond,
+			Status:        "ok",
+		})
+	}
+	body, _ := json.Marshal(spans)
+	rec := httptest.NewRecorder()
+	col.HandlePostSpansBatch(rec, httptest.NewRequest(http.MethodPost, "/api/v1/spans/batch", bytes.NewReader(body)))
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("batch status = %d", rec.Code)
+	}
+	col.Stop(ctx)
+
+	out := scrape(t, col)
+	for _, want := range []string{
+		"traceflow_spans_received_total 3",
+		"traceflow_spans_stored_total 3",
+		"traceflow_queue_capacity 10",
+		`traceflow_ingest_request_duration_seconds_count{endpoint="batch"} 1`,
+		"traceflow_queue_fill_ratio_count 3",
+		"traceflow_worker_span_duration_seconds_count 3",
+		"traceflow_storage_write_duration_seconds_count 3",
+		`traceflow_storage_evictions_total{reason="capacity"} 1`,
+		`traceflow_storage_evictions_total{reason="retention"} 0`,
+		`traceflow_service_requests_total{operation="GET /users",service="api"} 3`,
+		"# TYPE traceflow_storage_write_duration_seconds histogram",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
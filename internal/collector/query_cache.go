@@ -0,0 +1,224 @@
+package collector
+
+import (
+	"container/list"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// DefaultQueryCacheTTL is how long cached FindTraces results are served.
+const DefaultQueryCacheTTL = 5 * time.Second
+
+// Parameters that only shape the response, not the result set
+var queryCacheIgnoredParams = []string{"fields", "strict"}
+
+// queryCache is an LRU cache of FindTraces results. Dashboards tend to
+// re-issue identical queries every few seconds; entries expire after a short
+// TTL and are dropped early when a stored span could change their result.
+type queryCache struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[string]*list.Element
+	lru     *list.List // Front = most recently used
+
+	// Keys of entries by the trace IDs in their results and by their service
+	// filter, so a write only checks entries it could affect. Entries whose
+	// service filter a span of another service can satisfy are under ""
+	// with the unfiltered ones (see indexedService)
+	byTrace   map[string]map[string]struct{}
+	byService map[string]map[string]struct{}
+}
+
+type queryCacheEntry struct {
+	key     string
+	query   storage.Query   // For invalidation
+	traces  []*models.Trace // Shared with responses; never mutated
+	expires time.Time
+}
+
+func newQueryCache(size int, ttl time.Duration) *queryCache {
+	if ttl <= 0 {
+		ttl = DefaultQueryCacheTTL
+	}
+	return &queryCache{
+		size:      size,
+		ttl:       ttl,
+		entries:   make(map[string]*list.Element),
+		lru:       list.New(),
+		byTrace:   make(map[string]map[string]struct{}),
+		byService: make(map[string]map[string]struct{}),
+	}
+}
+
+// queryCacheKey normalizes request parameters into a cache key. Parameters
+// are sorted, and relative times (lookback=1h) stay relative, so repeated
+// dashboard requests share an entry. Results are cached as projected, so
+// the projection is part of the key.
+func queryCacheKey(params url.Values, projection storage.Projection) string {
+	normalized := make(url.Values, len(params))
+	for k, v := range params {
+		normalized[k] = v
+	}
+	for _, k := range queryCacheIgnoredParams {
+		normalized.Del(k)
+	}
+	return string(projection) + "?" + normalized.Encode()
+}
+
+// get returns the cached traces for key, if present and fresh.
+func (c *queryCache) get(key string) ([]*models.Trace, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*queryCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.traces, true
+}
+
+// put stores a result, evicting the least recently used entry if full.
+func (c *queryCache) put(key string, query *storage.Query, traces []*models.Trace) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	entry := &queryCacheEntry{
+		key:     key,
+		query:   *query,
+		traces:  traces,
+		expires: time.Now().Add(c.ttl),
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for _, trace := range traces {
+		addCacheKey(c.byTrace, trace.TraceID, key)
+	}
+	addCacheKey(c.byService, indexedService(query), key)
+
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate drops entries whose result a newly stored span could change.
+// Only entries holding the span's trace or filtering on its service (or on
+// none) are candidates; others cannot be affected. traceHasService reports
+// whether the span's trace has a span from a service; it is only called,
+// at most once per service, for entries that need it.
+func (c *queryCache) invalidate(span *models.Span, traceHasService func(service string) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	known := make(map[string]bool)
+	hasService := func(service string) bool {
+		has, ok := known[service]
+		if !ok {
+			has = traceHasService(service)
+			known[service] = has
+		}
+		return has
+	}
+
+	var affected []*list.Element
+	for _, keys := range []map[string]struct{}{
+		c.byTrace[span.TraceID],
+		c.byService[span.ServiceName],
+		c.byService[""],
+	} {
+		for key := range keys {
+			elem := c.entries[key]
+			if elem.Value.(*queryCacheEntry).affectedBy(span, hasService) {
+				affected = append(affected, elem)
+			}
+		}
+	}
+	for _, elem := range affected {
+		// An entry can be a candidate twice
+		if c.entries[elem.Value.(*queryCacheEntry).key] == elem {
+			c.remove(elem)
+		}
+	}
+}
+
+// affectedBy reports whether span could change the cached result: either it
+// extends a trace in the result, or it may start a new matching trace. A span
+// of another service than the query's can still make its trace match the
+// query's other filters, if the trace has a span of that service.
+func (e *queryCacheEntry) affectedBy(span *models.Span, traceHasService func(service string) bool) bool {
+	for _, trace := range e.traces {
+		if trace.TraceID == span.TraceID {
+			return true
+		}
+	}
+	if e.query.Service != "" && e.query.Service != span.ServiceName {
+		if !filtersBeyondService(&e.query) || !traceHasService(e.query.Service) {
+			return false
+		}
+	}
+	if !e.query.EndTime.IsZero() && span.StartTime.After(e.query.EndTime) {
+		return false
+	}
+	return true
+}
+
+// filtersBeyondService reports whether query filters on properties of the
+// whole trace that a span of any service can change.
+func filtersBeyondService(query *storage.Query) bool {
+	return len(query.Tags) > 0 || query.ErrorsOnly ||
+		query.MinDuration > 0 || query.MaxDuration > 0 ||
+		query.MinCost != 0 || query.MaxCost != 0 ||
+		query.HasProfile != nil || query.InProgress != nil || query.MinTTL > 0
+}
+
+// indexedService is the byService key of an entry for query: its service
+// filter, or "" when spans of any service can affect it.
+func indexedService(query *storage.Query) string {
+	if filtersBeyondService(query) {
+		return ""
+	}
+	return query.Service
+}
+
+// remove deletes elem and its index entries. Caller must hold mu.
+func (c *queryCache) remove(elem *list.Element) {
+	entry := elem.Value.(*queryCacheEntry)
+	c.lru.Remove(elem)
+	delete(c.entries, entry.key)
+	for _, trace := range entry.traces {
+		removeCacheKey(c.byTrace, trace.TraceID, entry.key)
+	}
+	removeCacheKey(c.byService, indexedService(&entry.query), entry.key)
+}
+
+// addCacheKey adds key to index[value].
+func addCacheKey(index map[string]map[string]struct{}, value, key string) {
+	keys, ok := index[value]
+	if !ok {
+		keys = make(map[string]struct{})
+		index[value] = keys
+	}
+	keys[key] = struct{}{}
+}
+
+// removeCacheKey removes key from index[value], dropping the set once empty.
+func removeCacheKey(index map[string]map[string]struct{}, value, key string) {
+	if keys, ok := index[value]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(index, value)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestQueryCache_LRUAndTTL(t *testing.T) {
+	cache := newQueryCache(2, 50*time.Millisecond)
+	query := storage.NewQuery()
+
+	cache.put("a", query, nil)
+	cache.put("b", query, nil)
+	cache.get("a") // a is now most recently used
+	cache.put("c", query, nil)
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("least recently used entry should have been evicted")
+	}
+	if _, ok := cache.get("a"); !ok {
+		t.Error("recently used entry was evicted")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if _, ok := cache.get("a"); ok {
+		t.Error("entry should have expired")
+	}
+}
+
+func TestQueryCacheKey_Normalizes(t *testing.T) {
+	a, _ := url.ParseQuery("service=api&lookback=1h&fields=trace_id")
+	b, _ := url.ParseQuery("lookback=1h&strict=false&service=api")
+	if queryCacheKey(a, storage.ProjectFull) != queryCacheKey(b, storage.ProjectFull) {
+		t.Errorf("keys differ: %q vs %q", queryCacheKey(a, storage.ProjectFull), queryCacheKey(b, storage.ProjectFull))
+	}
+	if queryCacheKey(a, storage.ProjectFull) == queryCacheKey(a, storage.ProjectMetadata) {
+		t.Error("projections share a key")
+	}
+}
+
+// noService is a traceHasService for new traces.
+func noService(string) bool { return false }
+
+func TestQueryCache_InvalidatesOnRelevantWrites(t *testing.T) {
+	cached := &models.Trace{TraceID: models.GenerateTraceID()}
+	entry := &queryCacheEntry{query: storage.Query{Service: "api"}, traces: []*models.Trace{cached}}
+
+	tests := []struct {
+		name string
+		span *models.Span
+		want bool
+	}{
+		{"same service", &models.Span{TraceID: models.GenerateTraceID(), ServiceName: "api"}, true},
+		{"other service, new trace", &models.Span{TraceID: models.GenerateTraceID(), ServiceName: "db"}, false},
+		{"other service, cached trace", &models.Span{TraceID: cached.TraceID, ServiceName: "db"}, true},
+	}
+	for _, tt := range tests {
+		if got := entry.affectedBy(tt.span, noService); got != tt.want {
+			t.Errorf("%s: affectedBy = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	end := time.Now().Add(-time.Hour)
+	window := &queryCacheEntry{query: storage.Query{EndTime: end}}
+	if window.affectedBy(&models.Span{TraceID: models.GenerateTraceID(), StartTime: time.Now()}, noService) {
+		t.Error("span after the query window should not invalidate")
+	}
+}
+
+func TestQueryCache_InvalidatesOnOtherServiceSpans(t *testing.T) {
+	errorsOnly := &queryCacheEntry{query: storage.Query{Service: "api", ErrorsOnly: true}}
+	span := &models.Span{TraceID: models.GenerateTraceID(), ServiceName: "db", Status: "error", StartTime: time.Now()}
+
+	// An error from db makes a trace with an api span match
+	hasAPI := func(service string) bool { return service == "api" }
+	if !errorsOnly.affectedBy(span, hasAPI) {
+		t.Error("db error in a trace with an api span should invalidate")
+	}
+	if errorsOnly.affectedBy(span, noService) {
+		t.Error("db error in a trace without an api span should not invalidate")
+	}
+
+	// Without further filters the trace's services are not looked up
+	serviceOnly := &queryCacheEntry{query: storage.Query{Service: "api"}}
+	if serviceOnly.affectedBy(span, func(string) bool { t.Error("unexpected service lookup"); return true }) {
+		t.Error("db span should not invalidate a service-only query")
+	}
+}
+
+func TestQueryCache_InvalidateUsesIndexes(t *testing.T) {
+	cache := newQueryCache(10, time.Minute)
+	apiTrace := &models.Trace{TraceID: models.GenerateTraceID()}
+	dbTrace := &models.Trace{TraceID: models.GenerateTraceID()}
+	window := storage.NewQuery().WithTimeRange(time.Time{}, time.Now().Add(-time.Hour))
+
+	cache.put("api", storage.NewQuery().WithService("api"), []*models.Trace{apiTrace})
+	cache.put("db", storage.NewQuery().WithService("db"), []*models.Trace{dbTrace})
+	cache.put("all", storage.NewQuery(), []*models.Trace{apiTrace, dbTrace})
+	cache.put("window", window, []*models.Trace{dbTrace})
+
+	// A new api trace affects the api and unfiltered entries only
+	cache.invalidate(&models.Span{TraceID: models.GenerateTraceID(), ServiceName: "api", StartTime: time.Now()}, noService)
+	for key, want := range map[string]bool{"api": false, "db": true, "all": false, "window": true} {
+		if _, ok := cache.get(key); ok != want {
+			t.Errorf("entry %q cached = %v, want %v", key, ok, want)
+		}
+	}
+
+	// A span of a cached trace affects every entry holding it
+	cache.invalidate(&models.Span{TraceID: dbTrace.TraceID, ServiceName: "api", StartTime: time.Now()}, noService)
+	if len(cache.entries) != 0 {
+		t.Errorf("%d entries left, want 0", len(cache.entries))
+	}
+	if len(cache.byTrace) != 0 || len(cache.byService) != 0 {
+		t.Errorf("indexes not emptied: %d traces, %d services", len(cache.byTrace), len(cache.byService))
+	}
+}
+
+func TestHandleFindTraces_ServedFromCache(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, QueryCacheSize: 10}, slog.Default())
+	ctx := context.Background()
+
+	newSpan := func(service string) *models.Span {
+		return &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   service,
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Status:        "ok",
+		}
+	}
+	col.processSpan(ctx, newSpan("api"))
+
+	find := func() {
+		col.HandleFindTraces(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/traces?service=api", nil))
+	}
+	find()
+	find()
+
+	stats := col.QueryMetrics().Stats(endpointFindTraces)
+	if stats.CacheMisses != 1 || stats.CacheHits != 1 {
+		t.Fatalf("cache stats = %+v, want 1 miss and 1 hit", stats)
+	}
+
+	// Unrelated write keeps the entry; a matching write drops it
+	col.processSpan(ctx, newSpan("db"))
+	find()
+	col.processSpan(ctx, newSpan("api"))
+	find()
+
+	stats = col.QueryMetrics().Stats(endpointFindTraces)
+	if stats.CacheHits != 2 || stats.CacheMisses != 2 {
+		t.Errorf("cache stats = %+v, want 2 hits and 2 misses", stats)
+	}
+}
+
+func TestHandleFindTraces_CacheInvalidatedByOtherService(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, QueryCacheSize: 10}, slog.Default())
+	ctx := context.Background()
+
+	root := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "GET /users",
+		StartTime:     time.Now(),
+		Duration:      10 * time.Millisecond,
+		Status:        "ok",
+	}
+	col.processSpan(ctx, root)
+
+	find := func() int {
+		rec := httptest.NewRecorder()
+		col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces?service=api&errors_only=true", nil))
+		var resp struct {
+			Traces []models.Trace `json:"traces"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatalf("invalid JSON: %v", err)
+		}
+		return len(resp.Traces)
+	}
+	if n := find(); n != 0 {
+		t.Fatalf("got %d traces before the error, want 0", n)
+	}
+
+	// A failed db span makes the api trace match
+	col.processSpan(ctx, &models.Span{
+		TraceID:       root.TraceID,
+		SpanID:        models.GenerateSpanID(),
+		ParentSpanID:  root.SpanID,
+		ServiceName:   "db",
+		OperationName: "SELECT",
+		StartTime:     time.Now(),
+		Duration:      time.Millisecond,
+		Status:        "error",
+	})
+	if n := find(); n != 1 {
+		t.Errorf("got %d traces after a db error, want 1 (stale cache)", n)
+	}
+}
+
+func TestHandleFindTraces_CacheKeepsProjectionsApart(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, QueryCacheSize: 10}, slog.Default())
+	col.processSpan(context.Background(), &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+		Tags:          map[string]string{"customer_id": "c-42"},
+	})
+
+	// v2 summaries are read without tags; a v1 query with the same
+	// parameters must not be served that projected result
+	col.HandleFindTracesV2(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v2/traces?service=api", nil))
+	rec := httptest.NewRecorder()
+	col.HandleFindTraces(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces?service=api", nil))
+
+	var resp struct {
+		Traces []models.Trace `json:"traces"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(resp.Traces) != 1 || resp.Traces[0].Spans[0].GetTag("customer_id") != "c-42" {
+		t.Errorf("v1 traces = %+v, want the span's tags", resp.Traces)
+	}
+}
@@ -0,0 +1,147 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+)
+
+// Query API endpoint labels
+const (
+	endpointGetTrace   = "get_trace"
+	endpointFindTraces = "find_traces"
+	endpointServices   = "services"
+)
+
+// Histogram bucket upper bounds
+var (
+	queryLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+	queryResultBuckets  = []float64{0, 1, 5, 10, 25, 50, 100, 250, 500, 1000}
+)
+
+// QueryMetrics tracks per-endpoint performance of the query API so operators
+// can see when queries slow down as the store grows.
+type QueryMetrics struct {
+	mu        sync.Mutex
+	endpoints map[string]*endpointMetrics
+}
+
+type endpointMetrics struct {
+	latency     *histogram.Histogram // Seconds
+	results     *histogram.Histogram // Traces (or services) returned
+	errors      uint64
+	cacheHits   uint64
+	cacheMisses uint64
+}
+
+// EndpointStats is a snapshot of one endpoint's counters.
+type EndpointStats struct {
+	Requests    uint64
+	Errors      uint64
+	LatencySum  time.Duration
+	ResultSum   float64
+	CacheHits   uint64
+	CacheMisses uint64
+}
+
+func newQueryMetrics() *QueryMetrics {
+	return &QueryMetrics{endpoints: make(map[string]*endpointMetrics)}
+}
+
+// endpoint returns the metrics for name, creating them. Caller must hold mu.
+func (m *QueryMetrics) endpoint(name string) *endpointMetrics {
+	e, ok := m.endpoints[name]
+	if !ok {
+		e = &endpointMetrics{
+			latency: histogram.New(queryLatencyBuckets),
+			results: histogram.New(queryResultBuckets),
+		}
+		m.endpoints[name] = e
+	}
+	return e
+}
+
+// Observe records a completed request.
+func (m *QueryMetrics) Observe(endpoint string, latency time.Duration, results int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.endpoint(endpoint)
+	e.latency.Observe(latency.Seconds())
+	e.results.Observe(float64(results))
+}
+
+// ObserveError records a request that failed with a server error.
+func (m *QueryMetrics) ObserveError(endpoint string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.endpoint(endpoint).errors++
+}
+
+// ObserveCache records a result cache lookup.
+func (m *QueryMetrics) ObserveCache(endpoint string, hit bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e := m.endpoint(endpoint)
+	if hit {
+		e.cacheHits++
+	} else {
+		e.cacheMisses++
+	}
+}
+
+// Stats returns a snapshot of the counters for endpoint.
+func (m *QueryMetrics) Stats(endpoint string) EndpointStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.endpoints[endpoint]
+	if !ok {
+		return EndpointStats{}
+	}
+	return EndpointStats{
+		Requests:    e.latency.Count(),
+		Errors:      e.errors,
+		LatencySum:  time.Duration(e.latency.Sum() * float64(time.Second)),
+		ResultSum:   e.results.Sum(),
+		CacheHits:   e.cacheHits,
+		CacheMisses: e.cacheMisses,
+	}
+}
+
+var (
+	queryDurationDesc = prometheus.NewDesc("traceflow_query_duration_seconds",
+		"Query API request latency", []string{"endpoint"}, nil)
+	queryResultsDesc = prometheus.NewDesc("traceflow_query_results",
+		"Results returned per query", []string{"endpoint"}, nil)
+	queryErrorsDesc = prometheus.NewDesc("traceflow_query_errors_total",
+		"Query API requests that failed with a server error", []string{"endpoint"}, nil)
+	queryCacheHitsDesc = prometheus.NewDesc("traceflow_query_cache_hits_total",
+		"Query result cache hits", []string{"endpoint"}, nil)
+	queryCacheMissesDesc = prometheus.NewDesc("traceflow_query_cache_misses_total",
+		"Query result cache misses", []string{"endpoint"}, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (m *QueryMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queryDurationDesc
+	ch <- queryResultsDesc
+	ch <- queryErrorsDesc
+	ch <- queryCacheHitsDesc
+	ch <- queryCacheMissesDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *QueryMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for name, e := range m.endpoints {
+		ch <- prometheus.MustNewConstHistogram(queryDurationDesc, e.latency.Count(), e.latency.Sum(), e.latency.Buckets(), name)
+		ch <- prometheus.MustNewConstHistogram(queryResultsDesc, e.results.Count(), e.results.Sum(), e.results.Buckets(), name)
+		ch <- prometheus.MustNewConstMetric(queryErrorsDesc, prometheus.CounterValue, float64(e.errors), name)
+		ch <- prometheus.MustNewConstMetric(queryCacheHitsDesc, prometheus.CounterValue, float64(e.cacheHits), name)
+		ch <- prometheus.MustNewConstMetric(queryCacheMissesDesc, prometheus.CounterValue, float64(e.cacheMisses), name)
+	}
+}
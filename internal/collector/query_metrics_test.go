@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestQueryMetrics_RecordsHandlers(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+
+	for i := 0; i < 3; i++ {
+		store.WriteSpan(context.Background(), &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Status:        "ok",
+		})
+	}
+
+	col.HandleFindTraces(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/traces", nil))
+	col.HandleFindTraces(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/traces?limit=1", nil))
+	col.HandleGetTrace(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/api/v1/traces/missing", nil))
+
+	stats := col.QueryMetrics().Stats(endpointFindTraces)
+	if stats.Requests != 2 || stats.ResultSum != 4 {
+		t.Errorf("find_traces stats = %+v, want 2 requests returning 4 traces", stats)
+	}
+	if got := col.QueryMetrics().Stats(endpointGetTrace).Requests; got != 1 {
+		t.Errorf("get_trace requests = %d, want 1", got)
+	}
+}
+
+func TestQueryMetrics_Collect(t *testing.T) {
+	m := newQueryMetrics()
+	m.Observe(endpointFindTraces, 3*time.Millisecond, 20)
+	m.Observe(endpointFindTraces, 2*time.Second, 500)
+	m.ObserveCache(endpointFindTraces, true)
+
+	out := scrape(t, m)
+
+	for _, want := range []string{
+		`traceflow_query_duration_seconds_bucket{endpoint="find_traces",le="0.001"} 0`,
+		`traceflow_query_duration_seconds_bucket{endpoint="find_traces",le="0.005"} 1`,
+		`traceflow_query_duration_seconds_bucket{endpoint="find_traces",le="+Inf"} 2`,
+		`traceflow_query_duration_seconds_count{endpoint="find_traces"} 2`,
+		`traceflow_query_results_bucket{endpoint="find_traces",le="25"} 1`,
+		`traceflow_query_results_sum{endpoint="find_traces"} 520`,
+		`traceflow_query_cache_hits_total{endpoint="find_traces"} 1`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/wal"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// QueueFullPolicy is what SubmitSpan does with a span that finds the span
+// queue full. Bursty producers can overrun the queue for a few milliseconds
+// at a time; blocking or spilling rides out such bursts instead of refusing
+// spans the workers would have caught up with.
+type QueueFullPolicy string
+
+const (
+	// QueueFullDrop refuses the span with ErrQueueFull (the default).
+	QueueFullDrop QueueFullPolicy = "drop"
+
+	// QueueFullBlock waits up to Config.QueueFullTimeout for room, then
+	// refuses the span. Each span waits separately, so a batch submitted
+	// to a stalled collector can take a multiple of the timeout.
+	QueueFullBlock QueueFullPolicy = "block"
+
+	// QueueFullSpill accepts the span into Config.Spill, an on-disk log
+	// moved back into the queue as room frees up. Spilled spans are
+	// processed after spans submitted later, and spans still spilled at
+	// shutdown are processed after the next start.
+	QueueFullSpill QueueFullPolicy = "spill"
+)
+
+// DefaultQueueFullTimeout is how long QueueFullBlock waits unless configured.
+const DefaultQueueFullTimeout = 100 * time.Millisecond
+
+// spillDrainInterval is how often spilled spans are moved back to the queue.
+var spillDrainInterval = time.Second
+
+// ParseQueueFullPolicy parses "drop", "block" or "spill" ("" = drop).
+func ParseQueueFullPolicy(s string) (QueueFullPolicy, error) {
+	switch policy := QueueFullPolicy(s); policy {
+	case "":
+		return QueueFullDrop, nil
+	case QueueFullDrop, QueueFullBlock, QueueFullSpill:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid queue full policy %q, want drop, block or spill", s)
+	}
+}
+
+// queueFull applies the queue full policy to a span the queue had no room
+// for.
+func (c *Collector) queueFull(span *models.Span) error {
+	switch c.queueFullPolicy {
+	case QueueFullBlock:
+		return c.queueWithin(span, c.queueFullTimeout)
+	case QueueFullSpill:
+		return c.spillSpan(span)
+	default:
+		return ErrQueueFull
+	}
+}
+
+// queueWithin queues a span, waiting up to timeout for room.
+func (c *Collector) queueWithin(span *models.Span, timeout time.Duration) error {
+	item := queuedSpan{span: span}
+	if c.wal != nil {
+		pos, err := c.appendWAL(span)
+		if err != nil {
+			return err
+		}
+		item.wal = pos
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case c.spanCh <- item:
+		c.spanQueued()
+		return nil
+	case <-timer.C:
+		c.ackWAL(item.wal)
+		return ErrQueueFull
+	case <-c.stopCh:
+		c.ackWAL(item.wal)
+		return ErrDraining
+	}
+}
+
+// queueWaiting queues a span recovered from disk, logging it to the WAL
+// first and waiting as long as it takes for room.
+func (c *Collector) queueWaiting(span *models.Span) error {
+	var pos wal.Position
+	if c.wal != nil {
+		var err error
+		if pos, err = c.appendWAL(span); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case c.spanCh <- queuedSpan{span: span, wal: pos}:
+		c.spanQueued()
+		return nil
+	case <-c.stopCh:
+		c.ackWAL(pos)
+		return ErrDraining
+	}
+}
+
+// spillSpan appends a span to the spill log. The span counts as accepted;
+// the spill drainer queues it later.
+func (c *Collector) spillSpan(span *models.Span) error {
+	if _, err := c.spill.Append(span.MarshalProto()); err != nil {
+		c.logger.Error("failed to spill span to disk", "error", err)
+		return ErrQueueFull
+	}
+	c.metrics.mu.Lock()
+	c.metrics.SpansSpilled++
+	c.metrics.mu.Unlock()
+	return nil
+}
+
+// spillDrainer moves spilled spans back into the queue until shutdown,
+// starting with any left on disk by a previous run.
+func (c *Collector) spillDrainer() {
+	defer c.spillWg.Done()
+
+	ticker := time.NewTicker(spillDrainInterval)
+	defer ticker.Stop()
+	for {
+		c.drainSpill()
+		select {
+		case <-c.stopCh:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// drainSpill queues every span spilled so far, waiting for room. Storage
+// upserts by span ID, so spans queued again after a failed drain are
+// harmless.
+func (c *Collector) drainSpill() {
+	result, err := c.spill.Drain(func(data []byte) error {
+		var span models.Span
+		if err := span.UnmarshalProto(data); err != nil {
+			c.logger.Warn("skipping undecodable spilled span", "error", err)
+			return nil
+		}
+		return c.queueWaiting(&span)
+	})
+	if err != nil && !errors.Is(err, ErrDraining) {
+		c.logger.Error("failed to drain spilled spans", "queued", result.Records, "error", err)
+		return
+	}
+	if result.Records > 0 {
+		c.logger.Debug("queued spilled spans", "spans", result.Records)
+	}
+}
@@ -0,0 +1,130 @@
+package collector
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/internal/wal"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func queueFullTestSpan() *models.Span {
+	return &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Duration:      10 * time.Millisecond,
+		Status:        "ok",
+	}
+}
+
+func TestParseQueueFullPolicy(t *testing.T) {
+	for in, want := range map[string]QueueFullPolicy{
+		"":      QueueFullDrop,
+		"drop":  QueueFullDrop,
+		"block": QueueFullBlock,
+		"spill": QueueFullSpill,
+	} {
+		if got, err := ParseQueueFullPolicy(in); err != nil || got != want {
+			t.Errorf("ParseQueueFullPolicy(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := ParseQueueFullPolicy("wait"); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestSubmitSpan_BlockWaitsForRoom(t *testing.T) {
+	// Workers are not started, so the queue only drains when the test reads it
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{
+		Workers: 1, ChannelBuffer: 1, QueueFull: QueueFullBlock, QueueFullTimeout: 5 * time.Second,
+	}, slog.Default())
+	col.setState(StateReady)
+	if err := col.SubmitSpan(&models.Span{TraceID: models.GenerateTraceID()}); err != nil {
+		t.Fatalf("first SubmitSpan failed: %v", err)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		<-col.spanCh
+	}()
+	if err := col.SubmitSpan(&models.Span{TraceID: models.GenerateTraceID()}); err != nil {
+		t.Fatalf("blocked SubmitSpan returned %v, want nil once a span was taken", err)
+	}
+	if metrics := col.GetMetrics(); metrics.SpansReceived != 2 {
+		t.Errorf("received %d spans, want 2", metrics.SpansReceived)
+	}
+}
+
+func TestSubmitSpan_BlockTimesOut(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{
+		Workers: 1, ChannelBuffer: 1, QueueFull: QueueFullBlock, QueueFullTimeout: 20 * time.Millisecond,
+	}, slog.Default())
+	col.setState(StateReady)
+	if err := col.SubmitSpan(&models.Span{TraceID: models.GenerateTraceID()}); err != nil {
+		t.Fatalf("first SubmitSpan failed: %v", err)
+	}
+
+	start := time.Now()
+	err := col.SubmitSpan(&models.Span{TraceID: models.GenerateTraceID()})
+	if !errors.Is(err, ErrQueueFull) {
+		t.Fatalf("SubmitSpan returned %v, want ErrQueueFull", err)
+	}
+	if waited := time.Since(start); waited < 20*time.Millisecond {
+		t.Errorf("refused after %v, want at least the 20ms timeout", waited)
+	}
+}
+
+func TestSubmitSpan_SpillsToDisk(t *testing.T) {
+	defer func(interval time.Duration) { spillDrainInterval = interval }(spillDrainInterval)
+	spillDrainInterval = 10 * time.Millisecond
+
+	spill, err := wal.Open(t.TempDir(), wal.Options{})
+	if err != nil {
+		t.Fatalf("wal.Open failed: %v", err)
+	}
+	defer spill.Close()
+
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{
+		Workers: 1, ChannelBuffer: 1, QueueFull: QueueFullSpill, Spill: spill,
+	}, slog.Default())
+	col.setState(StateReady)
+
+	queued := queueFullTestSpan()
+	spilled := queueFullTestSpan()
+	if err := col.SubmitSpan(queued); err != nil {
+		t.Fatalf("first SubmitSpan failed: %v", err)
+	}
+	if err := col.SubmitSpan(spilled); err != nil {
+		t.Fatalf("SubmitSpan to a full queue returned %v, want the span spilled", err)
+	}
+	if metrics := col.GetMetrics(); metrics.SpansSpilled != 1 {
+		t.Fatalf("spilled %d spans, want 1", metrics.SpansSpilled)
+	}
+
+	// Once workers run, the spilled span is queued and stored too
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for _, span := range []*models.Span{queued, spilled} {
+		for {
+			trace, _ := store.GetTrace(ctx, span.TraceID)
+			if trace != nil {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("span %s was not stored", span.SpanID)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+)
+
+// Rate limiting: every client gets a token bucket of spans, so one noisy
+// producer exhausts its own allowance instead of the shared span queue. A
+// client with an empty bucket is refused with 429 before its request body is
+// read; otherwise the request's spans are counted once it is parsed. A
+// request larger than the bucket is admitted when the bucket is full and
+// leaves it in debt, so big batches are slowed rather than refused forever.
+//
+// Admission also adapts to queue pressure: while a throttle hint is in
+// effect a span costs 1/SampleRate tokens, so the clients sending the most
+// are refused first and well-behaved ones keep their share of the queue.
+
+// RateLimit is a token bucket refilling at SpansPerSecond and holding at
+// most Burst spans.
+type RateLimit struct {
+	SpansPerSecond float64 `json:"spans_per_second"` // 0 = unlimited
+	Burst          int     `json:"burst,omitempty"`  // 0 = one second of spans
+}
+
+// burst returns the bucket size.
+func (l RateLimit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.SpansPerSecond))
+}
+
+// RateLimitConfig limits the spans each client may submit over HTTP.
+type RateLimitConfig struct {
+	// RateLimit applies to every client without an override in Tenants
+	RateLimit
+
+	// KeyHeader names a request header identifying the tenant, e.g.
+	// "X-Tenant-ID". Requests without it are keyed by client address.
+	KeyHeader string `json:"key_header,omitempty"`
+
+	// TrustForwardedFor takes the client address from the first
+	// X-Forwarded-For entry (only behind a proxy that sets it).
+	TrustForwardedFor bool `json:"trust_forwarded_for,omitempty"`
+
+	// Tenants overrides the limit per key (tenant header value or client
+	// address); a zero rate exempts the key
+	Tenants map[string]RateLimit `json:"tenants,omitempty"`
+}
+
+// Enabled reports whether any client is limited.
+func (c RateLimitConfig) Enabled() bool {
+	if c.SpansPerSecond > 0 {
+		return true
+	}
+	for _, limit := range c.Tenants {
+		if limit.SpansPerSecond > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate rejects negative rates and bursts.
+func (c RateLimitConfig) Validate() error {
+	if c.SpansPerSecond < 0 || c.Burst < 0 {
+		return errors.New("rate limit: spans_per_second and burst must not be negative")
+	}
+	for key, limit := range c.Tenants {
+		if limit.SpansPerSecond < 0 || limit.Burst < 0 {
+			return fmt.Errorf("rate limit for %q: spans_per_second and burst must not be negative", key)
+		}
+	}
+	return nil
+}
+
+// rateLimitPruneInterval is how often full buckets, which are equivalent to
+// absent ones, are forgotten so idle clients do not accumulate.
+const rateLimitPruneInterval = time.Minute
+
+// rateLimiter holds a token bucket per client key.
+type rateLimiter struct {
+	config RateLimitConfig
+	now    func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a limiter for config, or nil if it limits nobody.
+func newRateLimiter(config RateLimitConfig) *rateLimiter {
+	if !config.Enabled() {
+		return nil
+	}
+	return &rateLimiter{config: config, now: time.Now, buckets: make(map[string]*tokenBucket)}
+}
+
+// key identifies the client of r.
+func (l *rateLimiter) key(r *http.Request) string {
+	if l.config.KeyHeader != "" {
+		if tenant := r.Header.Get(l.config.KeyHeader); tenant != "" {
+			return tenant
+		}
+	}
+	return clientAddress(r, l.config.TrustForwardedFor)
+}
+
+// limitFor returns the limit applying to key.
+func (l *rateLimiter) limitFor(key string) RateLimit {
+	if limit, ok := l.config.Tenants[key]; ok {
+		return limit
+	}
+	return l.config.RateLimit
+}
+
+// reserve takes spans tokens from key's bucket, each costing 1/sampleRate.
+// With spans == 0 it only checks that the bucket is not empty. When the
+// request is refused it returns how long until it would be admitted.
+func (l *rateLimiter) reserve(key string, spans int, sampleRate float64) (bool, time.Duration) {
+	limit := l.limitFor(key)
+	if limit.SpansPerSecond <= 0 {
+		return true, 0
+	}
+	burst := limit.burst()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.prune(now)
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*limit.SpansPerSecond)
+	b.last = now
+
+	cost := float64(spans) / sampleRate
+	need := math.Min(math.Max(cost, 1), burst)
+	if b.tokens < need {
+		wait := time.Duration((need - b.tokens) / limit.SpansPerSecond * float64(time.Second))
+		return false, wait
+	}
+	b.tokens -= cost
+	return true, 0
+}
+
+// prune forgets buckets that have refilled completely. Callers hold l.mu.
+func (l *rateLimiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < rateLimitPruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		limit := l.limitFor(key)
+		if b.tokens+now.Sub(b.last).Seconds()*limit.SpansPerSecond >= limit.burst() {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// admit charges spans to the client of r and answers 429 with Retry-After
+// if its bucket cannot cover them; spans == 0 checks for an empty bucket
+// before the body is read. It reports whether the request may proceed.
+func (h *ingestHandler) admit(w http.ResponseWriter, r *http.Request, spans int) bool {
+	if h.rateLimit == nil {
+		return true
+	}
+
+	sampleRate := 1.0
+	if hint := receiver.ThrottleHintFor(h.consumer); hint != nil && hint.SampleRate > 0 {
+		sampleRate = hint.SampleRate
+	}
+	key := h.rateLimit.key(r)
+	ok, wait := h.rateLimit.reserve(key, spans, sampleRate)
+	if ok {
+		return true
+	}
+
+	h.rateLimited.Add(1)
+	h.logger.Debug("rate limited ingestion request", "client", key, "spans", spans, "retry_after", wait)
+	h.setThrottleHeaders(w)
+	seconds := int64(math.Ceil(wait.Seconds())) // Retry-After is whole seconds, round up
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+	http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+	return false
+}
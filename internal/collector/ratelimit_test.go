@@ -0,0 +1,116 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	l := newRateLimiter(RateLimitConfig{
+		RateLimit: RateLimit{SpansPerSecond: 10, Burst: 20},
+		Tenants:   map[string]RateLimit{"trusted": {}, "small": {SpansPerSecond: 1}},
+	})
+	l.now = func() time.Time { return now }
+
+	if ok, _ := l.reserve("a", 20, 1); !ok {
+		t.Fatal("a full bucket should admit a burst")
+	}
+	ok, wait := l.reserve("a", 0, 1)
+	if ok || wait != 100*time.Millisecond {
+		t.Errorf("empty bucket: ok %v, wait %v; want refused for one span's refill", ok, wait)
+	}
+	if ok, _ := l.reserve("b", 1, 1); !ok {
+		t.Error("other clients have their own bucket")
+	}
+
+	// Larger than the bucket: admitted when full, leaving it in debt
+	now = now.Add(2 * time.Second)
+	if ok, _ := l.reserve("a", 50, 1); !ok {
+		t.Error("a batch larger than the burst should be admitted from a full bucket")
+	}
+	now = now.Add(2 * time.Second)
+	if ok, _ := l.reserve("a", 0, 1); ok {
+		t.Error("bucket in debt should refuse requests")
+	}
+
+	// Queue pressure multiplies the cost of each span
+	if ok, _ := l.reserve("c", 10, 0.5); !ok {
+		t.Fatal("first half-rate request should fit")
+	}
+	if ok, _ := l.reserve("c", 1, 0.5); ok {
+		t.Error("under pressure 10 spans should use the whole bucket of 20")
+	}
+
+	for i := 0; i < 1000; i++ {
+		if ok, _ := l.reserve("trusted", 100, 1); !ok {
+			t.Fatal("a zero tenant rate should exempt the key")
+		}
+	}
+	l.reserve("small", 1, 1)
+	if ok, _ := l.reserve("small", 1, 1); ok {
+		t.Error("tenant override should replace the default limit")
+	}
+
+	// Full buckets are forgotten
+	now = now.Add(time.Hour)
+	l.reserve("b", 1, 1)
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets = %d after pruning, want only the one just used", len(l.buckets))
+	}
+}
+
+func TestHandlePostSpansBatch_RateLimited(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{
+		Workers:       1,
+		ChannelBuffer: 100,
+		RateLimit:     RateLimitConfig{RateLimit: RateLimit{SpansPerSecond: 1, Burst: 3}, KeyHeader: "X-Tenant-ID"},
+	}, slog.Default())
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	post := func(tenant string, n int) *httptest.ResponseRecorder {
+		spans := make([]models.Span, n)
+		for i := range spans {
+			spans[i] = models.Span{
+				TraceID:       models.GenerateTraceID(),
+				SpanID:        models.GenerateSpanID(),
+				ServiceName:   "test-service",
+				OperationName: "test-op",
+				StartTime:     time.Now(),
+				Status:        "ok",
+			}
+		}
+		body, _ := json.Marshal(spans)
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/spans/batch", bytes.NewReader(body))
+		req.Header.Set("X-Tenant-ID", tenant)
+		rec := httptest.NewRecorder()
+		col.HandlePostSpansBatch(rec, req)
+		return rec
+	}
+
+	if rec := post("noisy", 3); rec.Code != http.StatusAccepted {
+		t.Fatalf("first batch: status %d", rec.Code)
+	}
+	rec := post("noisy", 2)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("over limit: status %d, Retry-After %q; want 429 and 1", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := post("quiet", 2); rec.Code != http.StatusAccepted {
+		t.Errorf("another tenant: status %d, want 202", rec.Code)
+	}
+	if m := col.GetMetrics(); m.IngestRateLimited != 1 || m.SpansReceived != 5 {
+		t.Errorf("rate limited %d, received %d; want 1 and 5", m.IngestRateLimited, m.SpansReceived)
+	}
+}
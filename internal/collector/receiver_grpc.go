@@ -0,0 +1,160 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/internal/spanexport"
+)
+
+func init() {
+	receiver.Register("grpc", newGRPCReceiver)
+}
+
+// GRPCReceiverConfig configures the streaming SDK export listener.
+type GRPCReceiverConfig struct {
+	Addr   string       `json:"addr"`             // Listen address, e.g. ":4317"
+	Origin OriginConfig `json:"origin,omitempty"` // Tag spans with the submitting client
+	TLS    TLSConfig    `json:"tls,omitempty"`    // Serve over TLS or mutual TLS
+}
+
+// grpcListener runs the gRPC server shared by the gRPC-based receivers.
+type grpcListener struct {
+	addr   string
+	bound  string // Actual listen address once started
+	origin OriginConfig
+	tls    *tls.Config // Nil serves plaintext
+	logger *slog.Logger
+	server *grpc.Server
+}
+
+// grpcReceiver accepts span batches over the spanexport streaming RPC and
+// acknowledges each batch once its spans are queued.
+type grpcReceiver struct {
+	grpcListener
+	consumer receiver.Consumer
+}
+
+func newGRPCReceiver(raw json.RawMessage, logger *slog.Logger) (receiver.Receiver, error) {
+	var config GRPCReceiverConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if config.Addr == "" {
+		return nil, errors.New("addr is required")
+	}
+	tlsConfig, err := config.TLS.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &grpcReceiver{grpcListener: grpcListener{addr: config.Addr, origin: config.Origin, tls: tlsConfig, logger: logger}}, nil
+}
+
+// Start listens on the configured address and serves in the background.
+func (r *grpcReceiver) Start(ctx context.Context, consumer receiver.Consumer) error {
+	r.consumer = consumer
+	return r.listen(func(server *grpc.Server) {
+		spanexport.RegisterServer(server, r)
+	})
+}
+
+// Stop waits for open streams to end, forcing them closed if ctx expires.
+func (r *grpcReceiver) Stop(ctx context.Context) error {
+	return r.stop(ctx)
+}
+
+// listen binds the address, registers services and serves in the background.
+func (l *grpcListener) listen(register func(*grpc.Server)) error {
+	listener, err := net.Listen("tcp", l.addr)
+	if err != nil {
+		return err
+	}
+	l.bound = listener.Addr().String()
+
+	var opts []grpc.ServerOption
+	if l.tls != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(l.tls)))
+	}
+	l.server = grpc.NewServer(opts...)
+	register(l.server)
+
+	go func() {
+		l.logger.Info("grpc receiver listening", "addr", l.bound, "tls", l.tls != nil)
+		if err := l.server.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			l.logger.Error("grpc receiver error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// stop drains in-flight RPCs, forcing them closed if ctx expires.
+func (l *grpcListener) stop(ctx context.Context) error {
+	if l.server == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		l.server.GracefulStop()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		l.server.Stop()
+		return ctx.Err()
+	}
+}
+
+// Export implements spanexport.Server.
+func (r *grpcReceiver) Export(stream spanexport.ServerStream) error {
+	consumer := withOrigin(r.consumer, r.origin.grpcOrigin(stream.Context()))
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		ack := &spanexport.Ack{Seq: batch.Seq}
+		for _, span := range batch.Spans {
+			if span == nil {
+				ack.Rejected++
+				continue
+			}
+			if err := consumer.SubmitSpan(span); err != nil {
+				ack.Rejected++
+				continue
+			}
+			ack.Accepted++
+		}
+		ack.Throttle = receiver.ThrottleHintFor(r.consumer)
+		if ack.Rejected > 0 {
+			r.logger.Warn("spans rejected from grpc batch",
+				"seq", batch.Seq,
+				"rejected", ack.Rejected,
+				"total", len(batch.Spans),
+			)
+		}
+
+		if err := stream.Send(ack); err != nil {
+			return err
+		}
+	}
+}
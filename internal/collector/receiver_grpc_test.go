@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/internal/spanexport"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestGRPCReceiver_AcksBatches(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 2, ChannelBuffer: 10}, slog.Default())
+
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	manager, err := receiver.NewManager([]receiver.Spec{
+		{Name: "grpc", Config: json.RawMessage(`{"addr": "127.0.0.1:0"}`)},
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.Start(ctx, col); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop(ctx)
+
+	r := manager.Receivers()["grpc"].(*grpcReceiver)
+	conn, err := grpc.NewClient(r.bound, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	stream, err := spanexport.OpenStream(ctx, conn)
+	if err != nil {
+		t.Fatalf("OpenStream() error = %v", err)
+	}
+
+	traceID := models.GenerateTraceID()
+	batch := &spanexport.Batch{Seq: 7}
+	for i := 0; i < 3; i++ {
+		batch.Spans = append(batch.Spans, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "test-service",
+			OperationName: "test-op",
+			StartTime:     time.Now(),
+			Status:        "ok",
+		})
+	}
+	if err := stream.Send(batch); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	ack, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv() error = %v", err)
+	}
+	if ack.Seq != 7 || ack.Accepted != 3 || ack.Rejected != 0 {
+		t.Errorf("unexpected ack: %+v", ack)
+	}
+	stream.CloseSend()
+
+	time.Sleep(100 * time.Millisecond)
+	trace, _ := store.GetTrace(ctx, traceID)
+	if trace == nil || len(trace.Spans) != 3 {
+		t.Errorf("expected 3 stored spans, got %v", trace)
+	}
+	if got := manager.Metrics()["grpc"].Accepted; got != 3 {
+		t.Errorf("receiver accepted = %d, want 3", got)
+	}
+}
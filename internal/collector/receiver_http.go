@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+)
+
+func init() {
+	receiver.Register("http", newHTTPReceiver)
+}
+
+// HTTPReceiverConfig configures an additional JSON ingestion listener.
+type HTTPReceiverConfig struct {
+	Addr        string       `json:"addr"`                    // Listen address, e.g. ":4319"
+	MaxInFlight int          `json:"max_in_flight,omitempty"` // Concurrent request limit (0 = unlimited)
+	Origin      OriginConfig `json:"origin,omitempty"`        // Tag spans with the submitting client
+	TLS         TLSConfig    `json:"tls,omitempty"`           // Serve over TLS or mutual TLS
+
+	// RateLimit caps the spans each client may submit to this listener
+	RateLimit RateLimitConfig `json:"rate_limit,omitempty"`
+
+	// IdempotencyTTL is how long batch responses are remembered by
+	// Idempotency-Key, e.g. "5m" (empty = disabled)
+	IdempotencyTTL string `json:"idempotency_ttl,omitempty"`
+}
+
+// httpReceiver serves /api/v1/spans, /api/v1/spans/batch, Zipkin and OTLP/HTTP
+// /v1/traces on its own listener, independent of the collector's query API server.
+type httpReceiver struct {
+	addr        string
+	maxInFlight int
+	origin      OriginConfig
+	rateLimit   RateLimitConfig
+	idempotency time.Duration
+	tls         *tls.Config // Nil serves plaintext
+	bound       string      // Actual listen address once started
+	logger      *slog.Logger
+	server      *http.Server
+}
+
+func newHTTPReceiver(raw json.RawMessage, logger *slog.Logger) (receiver.Receiver, error) {
+	var config HTTPReceiverConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if config.Addr == "" {
+		return nil, errors.New("addr is required")
+	}
+	if config.MaxInFlight < 0 {
+		return nil, errors.New("max_in_flight must not be negative")
+	}
+	if err := config.RateLimit.Validate(); err != nil {
+		return nil, err
+	}
+	var idempotency time.Duration
+	if config.IdempotencyTTL != "" {
+		var err error
+		if idempotency, err = time.ParseDuration(config.IdempotencyTTL); err != nil || idempotency < 0 {
+			return nil, fmt.Errorf("invalid idempotency_ttl %q", config.IdempotencyTTL)
+		}
+	}
+	tlsConfig, err := config.TLS.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &httpReceiver{
+		addr:        config.Addr,
+		maxInFlight: config.MaxInFlight,
+		origin:      config.Origin,
+		rateLimit:   config.RateLimit,
+		idempotency: idempotency,
+		tls:         tlsConfig,
+		logger:      logger,
+	}, nil
+}
+
+// Start listens on the configured address and serves in the background.
+func (r *httpReceiver) Start(ctx context.Context, consumer receiver.Consumer) error {
+	listener, err := net.Listen("tcp", r.addr)
+	if err != nil {
+		return err
+	}
+	r.bound = listener.Addr().String()
+	if r.tls != nil {
+		listener = tls.NewListener(listener, r.tls)
+	}
+
+	ingest := newIngestHandler(consumer, r.maxInFlight, r.origin, r.logger)
+	ingest.rateLimit = newRateLimiter(r.rateLimit)
+	if r.idempotency > 0 {
+		ingest.idempotency = newIdempotencyCache(r.idempotency)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/spans", ingest.limit(ingest.handlePostSpan))
+	mux.HandleFunc("/api/v1/spans/batch", ingest.limit(ingest.idempotent(ingest.handlePostSpansBatch)))
+	mux.HandleFunc("/v1/traces", ingest.limit(ingest.handleOTLPTraces))
+	mux.HandleFunc("/api/v2/spans", ingest.limit(ingest.handleZipkinSpans))
+
+	r.server = &http.Server{
+		Handler:      mux,
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  120 * time.Second,
+	}
+
+	go func() {
+		r.logger.Info("http receiver listening", "addr", r.bound, "tls", r.tls != nil)
+		if err := r.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.Error("http receiver error", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Stop gracefully shuts down the listener.
+func (r *httpReceiver) Stop(ctx context.Context) error {
+	if r.server == nil {
+		return nil
+	}
+	return r.server.Shutdown(ctx)
+}
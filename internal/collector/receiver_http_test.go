@@ -0,0 +1,70 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHTTPReceiver_IngestsIntoCollector(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 2, ChannelBuffer: 10}, slog.Default())
+
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	manager, err := receiver.NewManager([]receiver.Spec{
+		{Name: "http", ID: "internal", Config: json.RawMessage(`{"addr": "127.0.0.1:0"}`)},
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.Start(ctx, col); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop(ctx)
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+	}
+	spanJSON, _ := json.Marshal(span)
+
+	r := manager.Receivers()["internal"].(*httpReceiver)
+	resp, err := http.Post("http://"+r.bound+"/api/v1/spans", "application/json", bytes.NewReader(spanJSON))
+	if err != nil {
+		t.Fatalf("POST failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusAccepted)
+	}
+	if got := manager.Metrics()["internal"].Accepted; got != 1 {
+		t.Errorf("receiver accepted = %d, want 1", got)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if trace, _ := store.GetTrace(ctx, span.TraceID); trace == nil {
+		t.Error("span from receiver was not stored")
+	}
+}
+
+func TestHTTPReceiver_RequiresAddr(t *testing.T) {
+	if _, err := newHTTPReceiver(nil, slog.Default()); err == nil {
+		t.Error("expected error when addr is missing")
+	}
+}
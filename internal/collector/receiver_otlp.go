@@ -0,0 +1,76 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	_ "google.golang.org/grpc/encoding/gzip" // OTLP exporters commonly gzip requests
+	"google.golang.org/grpc/status"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+)
+
+func init() {
+	receiver.Register("otlp_grpc", newOTLPGRPCReceiver)
+}
+
+// OTLPGRPCReceiverConfig configures the OTLP/gRPC listener.
+type OTLPGRPCReceiverConfig struct {
+	Addr   string       `json:"addr"`             // Listen address, conventionally ":4317"
+	Origin OriginConfig `json:"origin,omitempty"` // Tag spans with the submitting client
+	TLS    TLSConfig    `json:"tls,omitempty"`    // Serve over TLS or mutual TLS
+}
+
+// otlpGRPCReceiver implements the OpenTelemetry TraceService so agents and
+// SDKs using OTLP/gRPC exporters can ship spans to the collector.
+type otlpGRPCReceiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+	grpcListener
+	consumer receiver.Consumer
+}
+
+func newOTLPGRPCReceiver(raw json.RawMessage, logger *slog.Logger) (receiver.Receiver, error) {
+	var config OTLPGRPCReceiverConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("invalid config: %w", err)
+		}
+	}
+	if config.Addr == "" {
+		return nil, errors.New("addr is required")
+	}
+	tlsConfig, err := config.TLS.ServerConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &otlpGRPCReceiver{grpcListener: grpcListener{addr: config.Addr, origin: config.Origin, tls: tlsConfig, logger: logger}}, nil
+}
+
+// Start listens on the configured address and serves in the background.
+func (r *otlpGRPCReceiver) Start(ctx context.Context, consumer receiver.Consumer) error {
+	r.consumer = consumer
+	return r.listen(func(server *grpc.Server) {
+		coltracepb.RegisterTraceServiceServer(server, r)
+	})
+}
+
+// Stop drains in-flight exports, forcing them closed if ctx expires.
+func (r *otlpGRPCReceiver) Stop(ctx context.Context) error {
+	return r.stop(ctx)
+}
+
+// Export implements coltracepb.TraceServiceServer.
+func (r *otlpGRPCReceiver) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	resp, err := submitOTLP(withOrigin(r.consumer, r.origin.grpcOrigin(ctx)), req)
+	if err != nil {
+		// Unavailable is retryable for OTLP exporters
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+	return resp, nil
+}
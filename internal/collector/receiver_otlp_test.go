@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestOTLPGRPCReceiver_Export(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 2, ChannelBuffer: 10}, slog.Default())
+
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	manager, err := receiver.NewManager([]receiver.Spec{
+		{Name: "otlp_grpc", Config: json.RawMessage(`{"addr": "127.0.0.1:0"}`)},
+	}, slog.Default())
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.Start(ctx, col); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop(ctx)
+
+	r := manager.Receivers()["otlp_grpc"].(*otlpGRPCReceiver)
+	conn, err := grpc.NewClient(r.bound, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	traceID, _ := hex.DecodeString(models.GenerateTraceID())
+	spanID, _ := hex.DecodeString(models.GenerateSpanID())
+	start := time.Now().Add(-time.Second)
+	req := &coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: []*tracepb.ResourceSpans{{
+			Resource: &resourcepb.Resource{Attributes: []*commonpb.KeyValue{stringAttr("service.name", "inventory")}},
+			ScopeSpans: []*tracepb.ScopeSpans{{
+				Spans: []*tracepb.Span{{
+					TraceId:           traceID,
+					SpanId:            spanID,
+					Name:              "reserve",
+					StartTimeUnixNano: uint64(start.UnixNano()),
+					EndTimeUnixNano:   uint64(start.Add(5 * time.Millisecond).UnixNano()),
+				}},
+			}},
+		}},
+	}
+
+	client := coltracepb.NewTraceServiceClient(conn)
+	resp, err := client.Export(ctx, req, grpc.UseCompressor(gzip.Name))
+	if err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+	if resp.GetPartialSuccess().GetRejectedSpans() != 0 {
+		t.Errorf("unexpected partial success: %v", resp.GetPartialSuccess())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	trace, _ := store.GetTrace(ctx, hex.EncodeToString(traceID))
+	if trace == nil || trace.Spans[0].ServiceName != "inventory" {
+		t.Fatalf("span not stored via OTLP/gRPC: %v", trace)
+	}
+}
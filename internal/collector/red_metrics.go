@@ -0,0 +1,327 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// redWindow is the sliding window over which request and error rates are computed.
+const redWindow = time.Minute
+
+// maxREDSeries bounds the service/operation pairs tracked. Once reached, new
+// operations of a service are counted under redOtherOperation.
+const maxREDSeries = 10000
+
+const redOtherOperation = "_other"
+
+// DefaultApdexThreshold is the response time up to which a span counts as
+// satisfying in Apdex scores.
+const DefaultApdexThreshold = 500 * time.Millisecond
+
+// Span duration histogram bucket upper bounds, in seconds
+var redDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// REDMetrics derives request rate, error rate and duration (RED) metrics per
+// service and operation from stored spans, so services get dashboards and
+// alerts without separate metrics instrumentation. In-progress spans are
+// counted once they complete.
+//
+// Each operation also gets an Apdex score: successful spans within the
+// threshold T are satisfied, those within 4T tolerating, and the rest,
+// including every failed span, frustrated. The score is
+// (satisfied + tolerating/2) / total.
+type REDMetrics struct {
+	mu             sync.Mutex
+	series         map[redKey]*redSeries
+	apdexThreshold time.Duration
+	now            func() time.Time
+}
+
+type redKey struct {
+	service   string
+	operation string
+}
+
+type redSeries struct {
+	requests uint64
+	errors   uint64
+	duration *histogram.Histogram // Seconds
+
+	// Apdex counts; frustrated spans are requests minus both
+	satisfied  uint64
+	tolerating uint64
+
+	// Per-second counts over the last redWindow, indexed by Unix second
+	slots [int(redWindow / time.Second)]redSlot
+}
+
+type redSlot struct {
+	second   int64
+	requests uint64
+	errors   uint64
+}
+
+func newREDMetrics() *REDMetrics {
+	return &REDMetrics{series: make(map[redKey]*redSeries), apdexThreshold: DefaultApdexThreshold, now: time.Now}
+}
+
+// Observe records a stored span.
+func (m *REDMetrics) Observe(span *models.Span) {
+	if span.InProgress {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := redKey{service: span.ServiceName, operation: span.OperationName}
+	s, ok := m.series[key]
+	if !ok {
+		if len(m.series) >= maxREDSeries {
+			key.operation = redOtherOperation
+			s, ok = m.series[key]
+		}
+		if !ok {
+			s = &redSeries{duration: histogram.New(redDurationBuckets)}
+			m.series[key] = s
+		}
+	}
+
+	isError := span.IsError()
+	s.requests++
+	if isError {
+		s.errors++
+	}
+	s.duration.Observe(span.Duration.Seconds())
+	switch {
+	case isError:
+	case span.Duration <= m.apdexThreshold:
+		s.satisfied++
+	case span.Duration <= 4*m.apdexThreshold:
+		s.tolerating++
+	}
+
+	second := m.now().Unix()
+	slot := &s.slots[second%int64(len(s.slots))]
+	if slot.second != second {
+		*slot = redSlot{second: second}
+	}
+	slot.requests++
+	if isError {
+		slot.errors++
+	}
+}
+
+// windowCounts sums the slots within redWindow of now.
+func (s *redSeries) windowCounts(now int64) (requests, errors uint64) {
+	for _, slot := range s.slots {
+		if now-slot.second < int64(len(s.slots)) {
+			requests += slot.requests
+			errors += slot.errors
+		}
+	}
+	return requests, errors
+}
+
+// OperationRED is the RED summary of one operation, or of a whole service.
+type OperationRED struct {
+	Operation     string          `json:"operation,omitempty"`
+	RequestsTotal uint64          `json:"requests_total"`
+	ErrorsTotal   uint64          `json:"errors_total"`
+	RequestRate   float64         `json:"request_rate"` // Per second over the window
+	ErrorRate     float64         `json:"error_rate"`   // Fraction of requests in the window that failed
+	Duration      DurationSummary `json:"duration"`
+	Apdex         Apdex           `json:"apdex"`
+}
+
+// Apdex is an application performance index: a single 0-1 satisfaction
+// score computed from span durations and errors.
+type Apdex struct {
+	Score            float64 `json:"score"` // 1 when there are no spans
+	ThresholdSeconds float64 `json:"threshold_seconds"`
+	Satisfied        uint64  `json:"satisfied"`
+	Tolerating       uint64  `json:"tolerating"`
+	Frustrated       uint64  `json:"frustrated"`
+}
+
+// apdex summarizes the series' Apdex counts.
+func (s *redSeries) apdex(threshold time.Duration) Apdex {
+	a := Apdex{
+		Score:            1,
+		ThresholdSeconds: threshold.Seconds(),
+		Satisfied:        s.satisfied,
+		Tolerating:       s.tolerating,
+		Frustrated:       s.requests - s.satisfied - s.tolerating,
+	}
+	if s.requests > 0 {
+		a.Score = (float64(s.satisfied) + float64(s.tolerating)/2) / float64(s.requests)
+	}
+	return a
+}
+
+// DurationSummary is a span duration histogram with percentiles estimated
+// from its buckets.
+type DurationSummary struct {
+	Count      uint64           `json:"count"`
+	SumSeconds float64          `json:"sum_seconds"`
+	AvgSeconds float64          `json:"avg_seconds"`
+	P50Seconds float64          `json:"p50_seconds"`
+	P95Seconds float64          `json:"p95_seconds"`
+	P99Seconds float64          `json:"p99_seconds"`
+	Buckets    []DurationBucket `json:"buckets"`
+}
+
+// DurationBucket is a cumulative histogram bucket.
+type DurationBucket struct {
+	LE    string `json:"le"` // Upper bound in seconds, or "+Inf"
+	Count uint64 `json:"count"`
+}
+
+// ServiceRED is the RED summary of a service and its operations.
+type ServiceRED struct {
+	Service       string         `json:"service"`
+	WindowSeconds int            `json:"window_seconds"`
+	OperationRED                 // Totals across operations
+	Operations    []OperationRED `json:"operations"`
+}
+
+// Service returns the RED summary of service, or false if it has no spans.
+func (m *REDMetrics) Service(service string) (ServiceRED, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now().Unix()
+	result := ServiceRED{Service: service, WindowSeconds: int(redWindow / time.Second)}
+	total := &redSeries{duration: histogram.New(redDurationBuckets)}
+	var totalWindowRequests, totalWindowErrors uint64
+
+	for key, s := range m.series {
+		if key.service != service {
+			continue
+		}
+		requests, errors := s.windowCounts(now)
+		result.Operations = append(result.Operations, m.summarize(key.operation, s, requests, errors))
+
+		total.requests += s.requests
+		total.errors += s.errors
+		total.satisfied += s.satisfied
+		total.tolerating += s.tolerating
+		total.duration.Merge(s.duration)
+		totalWindowRequests += requests
+		totalWindowErrors += errors
+	}
+	if len(result.Operations) == 0 {
+		return ServiceRED{}, false
+	}
+
+	sort.Slice(result.Operations, func(i, j int) bool {
+		return result.Operations[i].Operation < result.Operations[j].Operation
+	})
+	result.OperationRED = m.summarize("", total, totalWindowRequests, totalWindowErrors)
+	return result, true
+}
+
+// summarize builds the summary of a series. Caller holds mu.
+func (m *REDMetrics) summarize(operation string, s *redSeries, windowRequests, windowErrors uint64) OperationRED {
+	h := s.duration
+	summary := OperationRED{
+		Operation:     operation,
+		RequestsTotal: s.requests,
+		ErrorsTotal:   s.errors,
+		RequestRate:   float64(windowRequests) / redWindow.Seconds(),
+		Duration: DurationSummary{
+			Count:      h.Count(),
+			SumSeconds: h.Sum(),
+			P50Seconds: h.Quantile(0.50),
+			P95Seconds: h.Quantile(0.95),
+			P99Seconds: h.Quantile(0.99),
+			Buckets:    make([]DurationBucket, 0, len(h.Bounds())+1),
+		},
+		Apdex: s.apdex(m.apdexThreshold),
+	}
+	if windowRequests > 0 {
+		summary.ErrorRate = float64(windowErrors) / float64(windowRequests)
+	}
+	if h.Count() > 0 {
+		summary.Duration.AvgSeconds = h.Sum() / float64(h.Count())
+	}
+
+	cumulative := h.Cumulative()
+	for i, bound := range h.Bounds() {
+		summary.Duration.Buckets = append(summary.Duration.Buckets, DurationBucket{LE: fmt.Sprintf("%g", bound), Count: cumulative[i]})
+	}
+	summary.Duration.Buckets = append(summary.Duration.Buckets, DurationBucket{LE: "+Inf", Count: h.Count()})
+	return summary
+}
+
+var (
+	redLabels       = []string{"service", "operation"}
+	redRequestsDesc = prometheus.NewDesc("traceflow_service_requests_total",
+		"Spans completed per service and operation", redLabels, nil)
+	redErrorsDesc = prometheus.NewDesc("traceflow_service_errors_total",
+		"Spans with error status per service and operation", redLabels, nil)
+	redApdexDesc = prometheus.NewDesc("traceflow_service_apdex",
+		"Apdex score per service and operation", redLabels, nil)
+	redDurationDesc = prometheus.NewDesc("traceflow_service_duration_seconds",
+		"Span duration per service and operation", redLabels, nil)
+)
+
+// Describe implements prometheus.Collector.
+func (m *REDMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- redRequestsDesc
+	ch <- redErrorsDesc
+	ch <- redApdexDesc
+	ch <- redDurationDesc
+}
+
+// Collect implements prometheus.Collector.
+func (m *REDMetrics) Collect(ch chan<- prometheus.Metric) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for key, s := range m.series {
+		ch <- prometheus.MustNewConstMetric(redRequestsDesc, prometheus.CounterValue, float64(s.requests), key.service, key.operation)
+		ch <- prometheus.MustNewConstMetric(redErrorsDesc, prometheus.CounterValue, float64(s.errors), key.service, key.operation)
+		ch <- prometheus.MustNewConstMetric(redApdexDesc, prometheus.GaugeValue, s.apdex(m.apdexThreshold).Score, key.service, key.operation)
+		ch <- prometheus.MustNewConstHistogram(redDurationDesc, s.duration.Count(), s.duration.Sum(), s.duration.Buckets(), key.service, key.operation)
+	}
+}
+
+// REDMetrics returns the trace-derived service metrics.
+func (c *Collector) REDMetrics() *REDMetrics {
+	return c.redMetrics
+}
+
+// HandleServiceMetrics handles GET /api/v1/metrics/services/:service - RED
+// metrics of one service, derived from its spans.
+func (c *Collector) HandleServiceMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	service := strings.TrimPrefix(r.URL.Path, "/api/v1/metrics/services/")
+	if service == "" || strings.Contains(service, "/") {
+		http.Error(w, "service name is required", http.StatusBadRequest)
+		return
+	}
+
+	summary, ok := c.redMetrics.Service(service)
+	if !ok {
+		http.Error(w, "no metrics for service", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func redSpan(service, operation, status string, duration time.Duration) *models.Span {
+	return &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   service,
+		OperationName: operation,
+		StartTime:     time.Now(),
+		Duration:      duration,
+		Status:        status,
+	}
+}
+
+func TestREDMetrics_ServiceSummary(t *testing.T) {
+	m := newREDMetrics()
+	now := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return now }
+
+	m.Observe(redSpan("api", "GET /users", "ok", 20*time.Millisecond))
+	m.Observe(redSpan("api", "GET /users", "error", 200*time.Millisecond))
+	m.Observe(redSpan("api", "POST /orders", "ok", 2*time.Second))
+	m.Observe(redSpan("db", "SELECT", "ok", time.Millisecond))
+
+	inProgress := redSpan("api", "GET /users", "ok", 0)
+	inProgress.InProgress = true
+	m.Observe(inProgress)
+
+	summary, ok := m.Service("api")
+	if !ok {
+		t.Fatal("expected metrics for api")
+	}
+	if summary.RequestsTotal != 3 || summary.ErrorsTotal != 1 {
+		t.Errorf("totals = %d requests, %d errors; want 3, 1", summary.RequestsTotal, summary.ErrorsTotal)
+	}
+	if want := 3 / redWindow.Seconds(); summary.RequestRate != want {
+		t.Errorf("request rate = %v, want %v", summary.RequestRate, want)
+	}
+	if len(summary.Operations) != 2 || summary.Operations[0].Operation != "GET /users" {
+		t.Fatalf("operations = %+v", summary.Operations)
+	}
+	users := summary.Operations[0]
+	if users.ErrorRate != 0.5 {
+		t.Errorf("GET /users error rate = %v, want 0.5", users.ErrorRate)
+	}
+	if users.Duration.Count != 2 || users.Duration.Buckets[len(users.Duration.Buckets)-1].Count != 2 {
+		t.Errorf("GET /users duration = %+v", users.Duration)
+	}
+
+	// Rates only cover the window; totals keep counting
+	now = now.Add(2 * redWindow)
+	summary, _ = m.Service("api")
+	if summary.RequestRate != 0 || summary.ErrorRate != 0 || summary.RequestsTotal != 3 {
+		t.Errorf("after window: rate=%v error_rate=%v total=%d", summary.RequestRate, summary.ErrorRate, summary.RequestsTotal)
+	}
+
+	if _, ok := m.Service("missing"); ok {
+		t.Error("unknown service should have no metrics")
+	}
+}
+
+func TestREDMetrics_Apdex(t *testing.T) {
+	m := newREDMetrics()
+	m.apdexThreshold = 100 * time.Millisecond
+
+	m.Observe(redSpan("api", "GET /users", "ok", 50*time.Millisecond))    // Satisfied
+	m.Observe(redSpan("api", "GET /users", "ok", 100*time.Millisecond))   // Satisfied
+	m.Observe(redSpan("api", "GET /users", "ok", 300*time.Millisecond))   // Tolerating
+	m.Observe(redSpan("api", "GET /users", "ok", time.Second))            // Frustrated
+	m.Observe(redSpan("api", "GET /users", "error", 10*time.Millisecond)) // Frustrated
+	m.Observe(redSpan("api", "POST /orders", "ok", 20*time.Millisecond))  // Satisfied
+
+	summary, _ := m.Service("api")
+	users := summary.Operations[0].Apdex
+	if users.Satisfied != 2 || users.Tolerating != 1 || users.Frustrated != 2 {
+		t.Errorf("GET /users apdex counts = %+v", users)
+	}
+	if users.Score != 0.5 || users.ThresholdSeconds != 0.1 {
+		t.Errorf("GET /users apdex = %+v, want score 0.5 at 0.1s", users)
+	}
+	// (3 satisfied + 1 tolerating / 2) / 6
+	if want := 3.5 / 6; summary.Apdex.Score != want {
+		t.Errorf("service apdex = %v, want %v", summary.Apdex.Score, want)
+	}
+
+	out := scrape(t, m)
+	if want := `traceflow_service_apdex{operation="GET /users",service="api"} 0.5`; !strings.Contains(out, want) {
+		t.Errorf("output missing %q\n%s", want, out)
+	}
+}
+
+func TestREDMetrics_Collect(t *testing.T) {
+	m := newREDMetrics()
+	m.Observe(redSpan("api", "GET /users", "ok", 20*time.Millisecond))
+	m.Observe(redSpan("api", "GET /users", "error", 3*time.Second))
+
+	out := scrape(t, m)
+
+	for _, want := range []string{
+		`traceflow_service_requests_total{operation="GET /users",service="api"} 2`,
+		`traceflow_service_errors_total{operation="GET /users",service="api"} 1`,
+		`traceflow_service_duration_seconds_bucket{operation="GET /users",service="api",le="0.025"} 1`,
+		`traceflow_service_duration_seconds_bucket{operation="GET /users",service="api",le="+Inf"} 2`,
+		`traceflow_service_duration_seconds_count{operation="GET /users",service="api"} 2`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\n%s", want, out)
+		}
+	}
+}
+
+func TestHandleServiceMetrics(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	if err := col.processSpan(context.Background(), redSpan("api", "GET /users", "ok", 10*time.Millisecond)); err != nil {
+		t.Fatalf("processSpan failed: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleServiceMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/services/api", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var summary ServiceRED
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if summary.Service != "api" || summary.RequestsTotal != 1 || len(summary.Operations) != 1 {
+		t.Errorf("summary = %+v", summary)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleServiceMetrics(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metrics/services/unknown", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown service status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,279 @@
+package collector
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Report periods; any other duration of at least an hour is also accepted.
+const (
+	ReportPeriodDay  = "day"
+	ReportPeriodWeek = "week"
+)
+
+const (
+	defaultReportPeriods = 7
+	maxReportPeriods     = 366
+	minReportPeriod      = time.Hour
+)
+
+// TrendReport summarizes each service's latency, errors and cost per period,
+// oldest period first, with the change from the period before.
+type TrendReport struct {
+	Period   string         `json:"period"` // "day", "week" or a duration
+	From     time.Time      `json:"from"`
+	To       time.Time      `json:"to"`
+	Services []ServiceTrend `json:"services"`
+}
+
+// ServiceTrend is one service's row of periods.
+type ServiceTrend struct {
+	Service string        `json:"service"`
+	Periods []TrendPeriod `json:"periods"`
+}
+
+// TrendPeriod aggregates a service's completed spans that started in one period.
+type TrendPeriod struct {
+	Start     time.Time `json:"start"`
+	Spans     uint64    `json:"spans"`
+	Errors    uint64    `json:"errors"`
+	ErrorRate float64   `json:"error_rate"`
+	AvgMs     float64   `json:"avg_ms"`
+	P50Ms     float64   `json:"p50_ms"`
+	P95Ms     float64   `json:"p95_ms"`
+	P99Ms     float64   `json:"p99_ms"`
+	Cost      float64   `json:"cost"`
+
+	// Change from the previous period: relative for p95 latency and cost
+	// (0.25 = 25% higher), absolute for the error rate. Unset when either
+	// period has no spans.
+	P95Change       *float64 `json:"p95_change,omitempty"`
+	ErrorRateChange *float64 `json:"error_rate_change,omitempty"`
+	CostChange      *float64 `json:"cost_change,omitempty"`
+}
+
+// reportPeriods divides time into report periods: day and week boundaries
+// fall on UTC midnight (weeks start on Monday), other durations on multiples
+// of the duration since the Unix epoch.
+type reportPeriods struct {
+	name string
+	step time.Duration
+}
+
+func parseReportPeriod(value string) (reportPeriods, error) {
+	switch value {
+	case "", ReportPeriodDay:
+		return reportPeriods{name: ReportPeriodDay, step: 24 * time.Hour}, nil
+	case ReportPeriodWeek:
+		return reportPeriods{name: ReportPeriodWeek, step: 7 * 24 * time.Hour}, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d < minReportPeriod {
+		return reportPeriods{}, fmt.Errorf("must be day, week or a duration of at least %s", minReportPeriod)
+	}
+	return reportPeriods{name: value, step: d}, nil
+}
+
+// start returns the start of the period containing t.
+func (p reportPeriods) start(t time.Time) time.Time {
+	t = t.UTC()
+	if p.name == ReportPeriodWeek {
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		daysSinceMonday := (int(day.Weekday()) + 6) % 7
+		return day.AddDate(0, 0, -daysSinceMonday)
+	}
+	return t.Truncate(p.step)
+}
+
+// trendAccumulator collects one service's spans in one period.
+type trendAccumulator struct {
+	spans    uint64
+	errors   uint64
+	cost     float64
+	duration *histogram.Histogram // Seconds
+}
+
+// buildTrendReport aggregates the spans of traces into count periods ending
+// with the one containing now. A non-empty service limits it to that service.
+func buildTrendReport(traces []*models.Trace, periods reportPeriods, count int, service string, now time.Time) TrendReport {
+	last := periods.start(now)
+	first := last.Add(-time.Duration(count-1) * periods.step)
+	report := TrendReport{Period: periods.name, From: first, To: last.Add(periods.step), Services: []ServiceTrend{}}
+
+	byService := make(map[string][]*trendAccumulator)
+	for _, trace := range traces {
+		for i := range trace.Spans {
+			span := &trace.Spans[i]
+			if span.InProgress || (service != "" && span.ServiceName != service) {
+				continue
+			}
+			if span.StartTime.Before(first) || !span.StartTime.Before(report.To) {
+				continue
+			}
+
+			index := int(periods.start(span.StartTime).Sub(first) / periods.step)
+			accs, ok := byService[span.ServiceName]
+			if !ok {
+				accs = make([]*trendAccumulator, count)
+				byService[span.ServiceName] = accs
+			}
+			if accs[index] == nil {
+				accs[index] = &trendAccumulator{duration: histogram.New(redDurationBuckets)}
+			}
+			acc := accs[index]
+			acc.spans++
+			if span.IsError() {
+				acc.errors++
+			}
+			acc.cost += span.Cost
+			acc.duration.Observe(span.Duration.Seconds())
+		}
+	}
+
+	for name, accs := range byService {
+		trend := ServiceTrend{Service: name, Periods: make([]TrendPeriod, count)}
+		for i, acc := range accs {
+			period := TrendPeriod{Start: first.Add(time.Duration(i) * periods.step)}
+			if acc != nil {
+				h := acc.duration
+				period.Spans = acc.spans
+				period.Errors = acc.errors
+				period.ErrorRate = float64(acc.errors) / float64(acc.spans)
+				period.AvgMs = h.Sum() / float64(h.Count()) * 1000
+				period.P50Ms = h.Quantile(0.50) * 1000
+				period.P95Ms = h.Quantile(0.95) * 1000
+				period.P99Ms = h.Quantile(0.99) * 1000
+				period.Cost = acc.cost
+			}
+			if i > 0 && period.Spans > 0 && trend.Periods[i-1].Spans > 0 {
+				previous := trend.Periods[i-1]
+				period.P95Change = relativeChange(previous.P95Ms, period.P95Ms)
+				errorRateChange := period.ErrorRate - previous.ErrorRate
+				period.ErrorRateChange = &errorRateChange
+				period.CostChange = relativeChange(previous.Cost, period.Cost)
+			}
+			trend.Periods[i] = period
+		}
+		report.Services = append(report.Services, trend)
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		return report.Services[i].Service < report.Services[j].Service
+	})
+	return report
+}
+
+// relativeChange returns (current - previous) / previous, or nil when
+// previous is zero.
+func relativeChange(previous, current float64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := (current - previous) / previous
+	return &change
+}
+
+// HandleReports handles GET /api/v1/reports - per-service latency, error and
+// cost trends over the last periods, as JSON or CSV (format=csv or
+// Accept: text/csv).
+func (c *Collector) HandleReports(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	params := r.URL.Query()
+	var errs []QueryParamError
+	periods, err := parseReportPeriod(params.Get("period"))
+	if err != nil {
+		errs = append(errs, QueryParamError{Param: "period", Value: params.Get("period"), Reason: err.Error()})
+	}
+	count := defaultReportPeriods
+	if value := params.Get("periods"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxReportPeriods {
+			errs = append(errs, QueryParamError{Param: "periods", Value: value, Reason: fmt.Sprintf("must be an integer from 1 to %d", maxReportPeriods)})
+		} else {
+			count = n
+		}
+	}
+	format := params.Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "text/csv") {
+		format = "csv"
+	}
+	if format != "" && format != "json" && format != "csv" {
+		errs = append(errs, QueryParamError{Param: "format", Value: format, Reason: "must be json or csv"})
+	}
+	if len(errs) > 0 {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	now := time.Now()
+	service := params.Get("service")
+	from := periods.start(now).Add(-time.Duration(count-1) * periods.step)
+	query := &storage.Query{Service: service, StartTime: from}
+	traces, err := c.store.FindTraces(r.Context(), query)
+	if err != nil {
+		c.logger.Error("failed to find traces for report", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	report := buildTrendReport(traces, periods, count, service, now)
+
+	if format == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "traceflow-report-"+periods.name+".csv"))
+		writeTrendCSV(w, report)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// writeTrendCSV writes one row per service and period.
+func writeTrendCSV(w http.ResponseWriter, report TrendReport) {
+	out := csv.NewWriter(w)
+	out.Write([]string{
+		"service", "period_start", "spans", "errors", "error_rate",
+		"avg_ms", "p50_ms", "p95_ms", "p99_ms", "cost",
+		"p95_change", "error_rate_change", "cost_change",
+	})
+
+	number := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	optional := func(f *float64) string {
+		if f == nil {
+			return ""
+		}
+		return number(*f)
+	}
+	for _, trend := range report.Services {
+		for _, p := range trend.Periods {
+			out.Write([]string{
+				trend.Service,
+				p.Start.Format(time.RFC3339),
+				strconv.FormatUint(p.Spans, 10),
+				strconv.FormatUint(p.Errors, 10),
+				number(p.ErrorRate),
+				number(p.AvgMs),
+				number(p.P50Ms),
+				number(p.P95Ms),
+				number(p.P99Ms),
+				number(p.Cost),
+				optional(p.P95Change),
+				optional(p.ErrorRateChange),
+				optional(p.CostChange),
+			})
+		}
+	}
+	out.Flush()
+}
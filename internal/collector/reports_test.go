@@ -0,0 +1,131 @@
+package collector
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestReportPeriods_Start(t *testing.T) {
+	// A Wednesday afternoon
+	at := time.Date(2024, 1, 17, 15, 30, 0, 0, time.UTC)
+
+	day, _ := parseReportPeriod("day")
+	if got := day.start(at); !got.Equal(time.Date(2024, 1, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("day start = %v", got)
+	}
+	week, _ := parseReportPeriod("week")
+	if got := week.start(at); !got.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("week start = %v, want Monday", got)
+	}
+	sixHours, _ := parseReportPeriod("6h")
+	if got := sixHours.start(at); !got.Equal(time.Date(2024, 1, 17, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("6h start = %v", got)
+	}
+	if _, err := parseReportPeriod("1m"); err == nil {
+		t.Error("expected error for a period under an hour")
+	}
+}
+
+func TestBuildTrendReport(t *testing.T) {
+	now := time.Date(2024, 1, 17, 15, 0, 0, 0, time.UTC)
+	span := func(service, status string, daysAgo int, duration time.Duration, cost float64) models.Span {
+		return models.Span{
+			ServiceName: service,
+			StartTime:   now.AddDate(0, 0, -daysAgo),
+			Duration:    duration,
+			Status:      status,
+			Cost:        cost,
+		}
+	}
+	trace := &models.Trace{Spans: []models.Span{
+		span("api", "ok", 1, 20*time.Millisecond, 0.01),
+		span("api", "error", 1, 20*time.Millisecond, 0.01),
+		span("api", "ok", 0, 200*time.Millisecond, 0.03),
+		span("api", "ok", 0, 200*time.Millisecond, 0.03),
+		span("db", "ok", 0, time.Millisecond, 0),
+		span("api", "ok", 10, time.Millisecond, 0), // Before the report
+	}}
+
+	day, _ := parseReportPeriod("day")
+	report := buildTrendReport([]*models.Trace{trace}, day, 3, "", now)
+	if !report.From.Equal(time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)) || !report.To.Equal(time.Date(2024, 1, 18, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("report range = %v - %v", report.From, report.To)
+	}
+	if len(report.Services) != 2 || report.Services[0].Service != "api" {
+		t.Fatalf("services = %+v", report.Services)
+	}
+
+	api := report.Services[0].Periods
+	if len(api) != 3 || api[0].Spans != 0 || api[1].Spans != 2 || api[2].Spans != 2 {
+		t.Fatalf("api periods = %+v", api)
+	}
+	if api[1].ErrorRate != 0.5 || api[2].ErrorRate != 0 {
+		t.Errorf("error rates = %v, %v", api[1].ErrorRate, api[2].ErrorRate)
+	}
+	if api[1].P95Change != nil {
+		t.Error("no change expected after an empty period")
+	}
+	today := api[2]
+	if today.P95Change == nil || *today.P95Change <= 0 {
+		t.Errorf("p95 change = %v, want an increase", today.P95Change)
+	}
+	if today.ErrorRateChange == nil || *today.ErrorRateChange != -0.5 {
+		t.Errorf("error rate change = %v, want -0.5", today.ErrorRateChange)
+	}
+	if today.CostChange == nil || *today.CostChange < 1.99 || *today.CostChange > 2.01 {
+		t.Errorf("cost change = %v, want 2 (tripled)", today.CostChange)
+	}
+
+	if report := buildTrendReport([]*models.Trace{trace}, day, 3, "db", now); len(report.Services) != 1 {
+		t.Errorf("service filter left %+v", report.Services)
+	}
+}
+
+func TestHandleReports_CSV(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	store.WriteSpan(context.Background(), &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "op",
+		StartTime:     time.Now(),
+		Duration:      10 * time.Millisecond,
+		Status:        "ok",
+	})
+
+	rec := httptest.NewRecorder()
+	col.HandleReports(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports?period=week&periods=2&format=csv", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("status = %d, content type %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	rows, err := csv.NewReader(rec.Body).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(rows) != 3 || rows[0][0] != "service" || rows[2][0] != "api" || rows[2][2] != "1" {
+		t.Errorf("rows = %v, want a header and two weeks of api", rows)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleReports(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports", nil))
+	var report TrendReport
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil || report.Period != "day" || len(report.Services[0].Periods) != defaultReportPeriods {
+		t.Errorf("default report = %+v (%v)", report, err)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleReports(rec, httptest.NewRequest(http.MethodGet, "/api/v1/reports?period=month&periods=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid parameters: status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// SamplingStrategyConfig is the sampling configuration of one service.
+type SamplingStrategyConfig struct {
+	DefaultRate *float64              `json:"default_rate,omitempty"` // Unset keeps every trace, or inherits for a service
+	Rules       []models.SamplingRule `json:"rules,omitempty"`        // Checked in order; the first match wins
+}
+
+// SamplingConfig is the "sampling" section of the collector's config file:
+// sample rates served to SDKs at GET /api/v1/sampling, so they can be
+// changed without redeploying instrumented services. Services listed under
+// Services get their own rules instead of the top-level ones.
+type SamplingConfig struct {
+	SamplingStrategyConfig
+	Services map[string]SamplingStrategyConfig `json:"services,omitempty"`
+}
+
+// Validate checks every rate and rule.
+func (c SamplingConfig) Validate() error {
+	if err := c.SamplingStrategyConfig.validate(); err != nil {
+		return fmt.Errorf("sampling: %w", err)
+	}
+	for service, strategy := range c.Services {
+		if err := strategy.validate(); err != nil {
+			return fmt.Errorf("sampling for %q: %w", service, err)
+		}
+	}
+	return nil
+}
+
+func (c SamplingStrategyConfig) validate() error {
+	if c.DefaultRate != nil && (*c.DefaultRate < 0 || *c.DefaultRate > 1) {
+		return fmt.Errorf("default_rate %v must be between 0 and 1", *c.DefaultRate)
+	}
+	for i, rule := range c.Rules {
+		if rule.Operation == "" {
+			return fmt.Errorf("rule %d: operation is required", i)
+		}
+		if rule.Rate < 0 || rule.Rate > 1 {
+			return fmt.Errorf("rule %d (%s): rate %v must be between 0 and 1", i, rule.Operation, rule.Rate)
+		}
+	}
+	return nil
+}
+
+// Strategy returns the sampling strategy of service.
+func (c *SamplingConfig) Strategy(service string) models.SamplingStrategy {
+	strategy := models.SamplingStrategy{Service: service, DefaultRate: 1}
+	if c == nil {
+		return strategy
+	}
+	if c.DefaultRate != nil {
+		strategy.DefaultRate = *c.DefaultRate
+	}
+	strategy.Rules = c.Rules
+	if own, ok := c.Services[service]; ok {
+		if own.DefaultRate != nil {
+			strategy.DefaultRate = *own.DefaultRate
+		}
+		strategy.Rules = own.Rules
+	}
+	return strategy
+}
+
+// HandleSampling handles GET /api/v1/sampling?service=name: the service's
+// sampling strategy with the current throttle hint. SDKs poll it to adapt
+// their sample rates. Without a sampling config every trace is kept.
+func (c *Collector) HandleSampling(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	strategy := c.sampling.Strategy(r.URL.Query().Get("service"))
+	strategy.Throttle = c.ThrottleHint()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(strategy)
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestSamplingConfig_Strategy(t *testing.T) {
+	half, all := 0.5, 1.0
+	config := &SamplingConfig{
+		SamplingStrategyConfig: SamplingStrategyConfig{
+			DefaultRate: &half,
+			Rules:       []models.SamplingRule{{Operation: "GET /health", Rate: 0}},
+		},
+		Services: map[string]SamplingStrategyConfig{
+			"checkout": {DefaultRate: &all},
+			"search":   {Rules: []models.SamplingRule{{Operation: "GET /search*", Rate: 0.1}}},
+		},
+	}
+	if err := config.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if s := config.Strategy("api"); s.DefaultRate != 0.5 || len(s.Rules) != 1 {
+		t.Errorf("api strategy = %+v, want the top-level one", s)
+	}
+	if s := config.Strategy("checkout"); s.DefaultRate != 1 || len(s.Rules) != 0 {
+		t.Errorf("checkout strategy = %+v, want its own rate and no rules", s)
+	}
+	if s := config.Strategy("search"); s.DefaultRate != 0.5 || len(s.Rules) != 1 || s.Rules[0].Rate != 0.1 {
+		t.Errorf("search strategy = %+v, want its own rules and the inherited rate", s)
+	}
+	if s := (*SamplingConfig)(nil).Strategy("api"); s.DefaultRate != 1 {
+		t.Errorf("unconfigured strategy = %+v, want every trace kept", s)
+	}
+
+	invalid := SamplingConfig{Services: map[string]SamplingStrategyConfig{
+		"api": {Rules: []models.SamplingRule{{Operation: "x", Rate: 2}}},
+	}}
+	if err := invalid.Validate(); err == nil {
+		t.Error("rate 2 accepted")
+	}
+}
+
+func TestHandleSampling_IncludesThrottleHint(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	col.setState(StateReady)
+	for i := 0; i < 10; i++ {
+		col.spanCh <- queuedSpan{span: queueFullTestSpan()}
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleSampling(rec, httptest.NewRequest(http.MethodGet, "/api/v1/sampling?service=api", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var strategy models.SamplingStrategy
+	if err := json.NewDecoder(rec.Body).Decode(&strategy); err != nil {
+		t.Fatal(err)
+	}
+	if strategy.Service != "api" || strategy.DefaultRate != 1 {
+		t.Errorf("strategy = %+v", strategy)
+	}
+	if strategy.Throttle == nil || strategy.Throttle.BackoffMs == 0 {
+		t.Errorf("throttle = %+v, want a hint from the full queue", strategy.Throttle)
+	}
+}
@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+// Load shedding: with Config.LoadShedding, once the span queue is past
+// throttleQueueThreshold the collector itself keeps only the fraction of
+// traces suggested by its throttle hint, instead of waiting for the queue to
+// fill and refusing whichever spans arrive next. The decision hashes the
+// trace ID, so every span of a trace gets the same answer and surviving
+// traces stay complete. Traces hashing below minThrottleSampleRate are never
+// shed; others are kept or shed whole while the load stays steady.
+
+// shouldShed reports whether spans of traceID are discarded under the
+// current load.
+func (c *Collector) shouldShed(traceID string) bool {
+	if !c.loadShedding {
+		return false
+	}
+	hint := c.ThrottleHint()
+	if hint == nil {
+		return false
+	}
+	return traceKeepHash(traceID) >= hint.SampleRate
+}
+
+// traceKeepHash maps a trace ID uniformly onto [0, 1).
+func traceKeepHash(traceID string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(traceID))
+	// FNV mixes its last bytes poorly into the high bits; fold them in
+	sum := h.Sum64()
+	sum ^= sum >> 29
+	sum *= 0xbf58476d1ce4e5b9
+	sum ^= sum >> 32
+	return float64(sum>>11) / math.Exp2(53)
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"log/slog"
+	"math"
+	"testing"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestTraceKeepHash_Uniform(t *testing.T) {
+	const n = 10000
+	below := 0
+	for i := 0; i < n; i++ {
+		h := traceKeepHash(models.GenerateTraceID())
+		if h < 0 || h >= 1 {
+			t.Fatalf("hash %v outside [0, 1)", h)
+		}
+		if h < 0.25 {
+			below++
+		}
+	}
+	if got := float64(below) / n; math.Abs(got-0.25) > 0.03 {
+		t.Errorf("%.3f of traces hash below 0.25, want about 0.25", got)
+	}
+}
+
+func TestSubmitSpan_ShedsWholeTraces(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	// Workers are not started, so the queue fill stays where the test puts it
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, LoadShedding: true}, slog.Default())
+	col.setState(StateReady)
+
+	for i := 0; i < 9; i++ {
+		if err := col.SubmitSpan(&models.Span{TraceID: models.GenerateTraceID()}); err != nil {
+			t.Fatalf("submit below threshold: %v", err)
+		}
+	}
+	rate := col.ThrottleHint().SampleRate
+
+	// Find traces on either side of the keep probability
+	var kept, shed string
+	for kept == "" || shed == "" {
+		id := models.GenerateTraceID()
+		if traceKeepHash(id) < rate {
+			kept = id
+		} else {
+			shed = id
+		}
+	}
+
+	// Every span of a shed trace is discarded, yet accepted
+	for i := 0; i < 3; i++ {
+		if err := col.SubmitSpan(&models.Span{TraceID: shed}); err != nil {
+			t.Fatalf("shed span returned %v, want nil", err)
+		}
+	}
+	if metrics := col.GetMetrics(); metrics.SpansShed != 3 || metrics.SpansReceived != 9 {
+		t.Errorf("shed %d, received %d; want 3 and 9", metrics.SpansShed, metrics.SpansReceived)
+	}
+
+	if err := col.SubmitSpan(&models.Span{TraceID: kept}); err != nil {
+		t.Fatalf("kept span returned %v", err)
+	}
+	if len(col.spanCh) != 10 {
+		t.Errorf("queue holds %d spans, want the kept span queued", len(col.spanCh))
+	}
+}
+
+func TestSubmitSpan_NoSheddingByDefault(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	col.setState(StateReady)
+
+	for i := 0; i < 10; i++ {
+		col.SubmitSpan(&models.Span{TraceID: models.GenerateTraceID()})
+	}
+	if metrics := col.GetMetrics(); metrics.SpansShed != 0 || metrics.SpansReceived != 10 {
+		t.Errorf("shed %d, received %d; want every span queued", metrics.SpansShed, metrics.SpansReceived)
+	}
+}
@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+)
+
+// parseStatsQuery reads the service and time range of a stats request; they
+// take the same values as in trace searches.
+func parseStatsQuery(r *http.Request) (*storage.StatsQuery, []QueryParamError) {
+	query, errs := parseQueryParams(r.URL.Query())
+	return &storage.StatsQuery{
+		Service:   query.Service,
+		StartTime: query.StartTime,
+		EndTime:   query.EndTime,
+	}, errs
+}
+
+// HandleServiceStats handles GET /api/v1/stats/services - span counts, error
+// rates, costs and duration percentiles per service, aggregated by the store.
+func (c *Collector) HandleServiceStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query, errs := parseStatsQuery(r)
+	if len(errs) > 0 && isStrict(r) {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	stats, err := c.store.GetServiceStats(r.Context(), query)
+	if err != nil {
+		c.logger.Error("failed to get service stats", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"services": stats,
+		"total":    len(stats),
+	})
+}
+
+// HandleOperationStats handles GET /api/v1/stats/operations - the same
+// aggregates per service and operation.
+func (c *Collector) HandleOperationStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	query, errs := parseStatsQuery(r)
+	if len(errs) > 0 && isStrict(r) {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	stats, err := c.store.GetOperationStats(r.Context(), query)
+	if err != nil {
+		c.logger.Error("failed to get operation stats", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"operations": stats,
+		"total":      len(stats),
+	})
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestHandleStats(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	ctx := context.Background()
+
+	traceID := models.GenerateTraceID()
+	for i, op := range []string{"GET /", "GET /", "POST /"} {
+		status := "ok"
+		if i == 0 {
+			status = "error"
+		}
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: op,
+			StartTime:     time.Now(),
+			Duration:      10 * time.Millisecond,
+			Status:        status,
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleServiceStats(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/services?lookback=1h", nil))
+	var services struct {
+		Services []storage.ServiceStats `json:"services"`
+		Total    int                    `json:"total"`
+	}
+	json.NewDecoder(rec.Body).Decode(&services)
+	if services.Total != 1 || services.Services[0].Traces != 1 || services.Services[0].Spans != 3 || services.Services[0].Errors != 1 {
+		t.Errorf("service stats = %+v", services)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleOperationStats(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/operations?service=api", nil))
+	var operations struct {
+		Operations []storage.OperationStats `json:"operations"`
+	}
+	json.NewDecoder(rec.Body).Decode(&operations)
+	if len(operations.Operations) != 2 || operations.Operations[0].Operation != "GET /" || operations.Operations[0].ErrorRate != 0.5 {
+		t.Errorf("operation stats = %+v", operations)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleOperationStats(rec, httptest.NewRequest(http.MethodGet, "/api/v1/stats/operations?start_time=soon", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid start_time: status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,151 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/events"
+	"github.com/saintparish4/asmbly/models"
+)
+
+const (
+	// maxTraceStreams bounds concurrent GET /api/v1/traces/stream clients.
+	maxTraceStreams = 100
+
+	// traceStreamQueueSize is each client's queue of completed traces; a
+	// client that falls further behind misses traces (reported as "dropped").
+	traceStreamQueueSize = 256
+
+	// traceStreamKeepAlive is how often an idle stream sends a comment so
+	// proxies do not time the connection out.
+	traceStreamKeepAlive = 15 * time.Second
+)
+
+// traceStreamFilter selects the completed traces a stream client receives.
+type traceStreamFilter struct {
+	Service     string        `json:"service,omitempty"`
+	MinDuration time.Duration `json:"min_duration,omitempty"`
+	ErrorsOnly  bool          `json:"error,omitempty"`
+}
+
+// parseTraceStreamFilter reads service, min_duration and error parameters.
+func parseTraceStreamFilter(params url.Values) (traceStreamFilter, []QueryParamError) {
+	var filter traceStreamFilter
+	var errs []QueryParamError
+
+	filter.Service = params.Get("service")
+	if value := params.Get("min_duration"); value != "" {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			errs = append(errs, QueryParamError{Param: "min_duration", Value: value, Reason: "must be a non-negative duration such as 100ms or 1.5s"})
+		}
+		filter.MinDuration = d
+	}
+	if value := params.Get("error"); value != "" {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			errs = append(errs, QueryParamError{Param: "error", Value: value, Reason: "must be true or false"})
+		}
+		filter.ErrorsOnly = b
+	}
+	return filter, errs
+}
+
+func (f traceStreamFilter) matches(trace *models.Trace) bool {
+	if f.Service != "" && !slices.Contains(trace.Services, f.Service) {
+		return false
+	}
+	if trace.Duration < f.MinDuration {
+		return false
+	}
+	return !f.ErrorsOnly || traceHasError(trace)
+}
+
+// HandleTraceStream handles GET /api/v1/traces/stream - a live tail of
+// completed traces matching service, min_duration and error, sent as
+// Server-Sent Events. Each trace is a "trace" event whose data is the trace
+// JSON; a "dropped" event reports traces missed because the client fell behind.
+// The stream ends when the client disconnects or the collector drains.
+func (c *Collector) HandleTraceStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, errs := parseTraceStreamFilter(r.URL.Query())
+	if len(errs) > 0 {
+		writeQueryErrors(w, errs)
+		return
+	}
+
+	if c.State() >= StateDraining {
+		c.ingest.writeSubmitError(w, stateError(c.State()))
+		return
+	}
+	if atomic.AddInt32(&c.traceStreams, 1) > maxTraceStreams {
+		atomic.AddInt32(&c.traceStreams, -1)
+		http.Error(w, "too many trace streams", http.StatusServiceUnavailable)
+		return
+	}
+	defer atomic.AddInt32(&c.traceStreams, -1)
+
+	// The server's write timeout is meant for ordinary requests; lift it for
+	// this connection. Flushing is required to stream at all.
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	sub := c.events.Subscribe(traceStreamQueueSize, events.TraceCompleted)
+	defer c.events.Unsubscribe(sub)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no") // Disable nginx response buffering
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": streaming completed traces\n\n")
+	if err := rc.Flush(); err != nil {
+		c.logger.Error("trace stream unsupported by response writer", "error", err)
+		return
+	}
+
+	c.logger.Debug("trace stream opened", "filter", filter)
+	keepAlive := time.NewTicker(traceStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	var reportedDrops int64
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.lifecycle.draining:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case evt, ok := <-sub.C():
+			if !ok {
+				return // Bus closed
+			}
+			if dropped := sub.Dropped(); dropped > reportedDrops {
+				fmt.Fprintf(w, "event: dropped\ndata: {\"dropped\":%d}\n\n", dropped-reportedDrops)
+				reportedDrops = dropped
+			}
+			if !filter.matches(evt.Trace) {
+				continue
+			}
+			data, err := json.Marshal(evt.Trace)
+			if err != nil {
+				c.logger.Error("failed to encode streamed trace", "trace_id", evt.Trace.TraceID, "error", err)
+				continue
+			}
+			fmt.Fprintf(w, "event: trace\nid: %s\ndata: %s\n\n", evt.Trace.TraceID, data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
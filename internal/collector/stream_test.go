@@ -0,0 +1,127 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestTraceStreamFilter(t *testing.T) {
+	filter, errs := parseTraceStreamFilter(url.Values{
+		"service":      {"api"},
+		"min_duration": {"100ms"},
+		"error":        {"true"},
+	})
+	if len(errs) > 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	failing := &models.Trace{
+		Services: []string{"api", "db"},
+		Duration: 200 * time.Millisecond,
+		Spans:    []models.Span{{Status: "error"}},
+	}
+	if !filter.matches(failing) {
+		t.Error("expected slow failing api trace to match")
+	}
+	for name, trace := range map[string]*models.Trace{
+		"other service": {Services: []string{"web"}, Duration: time.Second, Spans: failing.Spans},
+		"too fast":      {Services: []string{"api"}, Duration: time.Millisecond, Spans: failing.Spans},
+		"no error":      {Services: []string{"api"}, Duration: time.Second, Spans: []models.Span{{Status: "ok"}}},
+	} {
+		if filter.matches(trace) {
+			t.Errorf("%s: unexpected match", name)
+		}
+	}
+
+	if _, errs := parseTraceStreamFilter(url.Values{"min_duration": {"soon"}, "error": {"maybe"}}); len(errs) != 2 {
+		t.Errorf("expected 2 errors, got %v", errs)
+	}
+}
+
+func TestHandleTraceStream(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	config := &Config{Workers: 1, ChannelBuffer: 10, TraceIdleTimeout: 50 * time.Millisecond}
+	col := NewCollector(store, config, slog.Default())
+
+	ctx := context.Background()
+	col.Start(ctx)
+	defer col.Stop(ctx)
+
+	server := httptest.NewServer(http.HandlerFunc(col.HandleTraceStream))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?service=wanted")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// The subscription exists once headers arrive; submit a trace the
+	// filter skips, then one it matches
+	traceIDs := map[string]string{}
+	for _, service := range []string{"other", "wanted"} {
+		span := &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   service,
+			OperationName: "op",
+			StartTime:     time.Now(),
+			Duration:      time.Millisecond,
+			Status:        "ok",
+		}
+		traceIDs[service] = span.TraceID
+		if err := col.SubmitSpan(span); err != nil {
+			t.Fatalf("submit failed: %v", err)
+		}
+	}
+
+	events := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- data
+			}
+		}
+		close(events)
+	}()
+
+	select {
+	case data := <-events:
+		var trace models.Trace
+		if err := json.Unmarshal([]byte(data), &trace); err != nil {
+			t.Fatalf("invalid trace data: %v", err)
+		}
+		if trace.TraceID != traceIDs["wanted"] {
+			t.Errorf("streamed trace %s, want %s", trace.TraceID, traceIDs["wanted"])
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for a streamed trace")
+	}
+
+	// Draining ends the stream
+	col.Drain()
+	select {
+	case data, ok := <-events:
+		if ok {
+			t.Errorf("unexpected event after drain: %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream still open after drain")
+	}
+}
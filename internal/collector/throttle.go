@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Flow control: once the span queue is this full, ingestion responses carry a
+// throttle hint whose sample rate falls linearly from 1 to minThrottleSampleRate
+// as the queue approaches capacity.
+const (
+	throttleQueueThreshold = 0.8
+	minThrottleSampleRate  = 0.1
+	throttleBackoff        = time.Second // Suggested pause when the queue is full
+)
+
+// ThrottleHint returns a hint for clients when the collector is saturated,
+// or nil when it is keeping up.
+func (c *Collector) ThrottleHint() *models.ThrottleHint {
+	capacity := cap(c.spanCh)
+	if capacity == 0 {
+		return nil
+	}
+
+	fill := float64(len(c.spanCh)) / float64(capacity)
+	if fill < throttleQueueThreshold {
+		return nil
+	}
+
+	rate := 1 - (fill-throttleQueueThreshold)/(1-throttleQueueThreshold)
+	if rate < minThrottleSampleRate {
+		rate = minThrottleSampleRate
+	}
+
+	hint := &models.ThrottleHint{SampleRate: rate}
+	if len(c.spanCh) >= capacity {
+		hint.BackoffMs = throttleBackoff.Milliseconds()
+	}
+	return hint
+}
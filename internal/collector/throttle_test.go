@@ -0,0 +1,142 @@
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestThrottleHint_ScalesWithQueueFill(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	// Workers are not started, so submitted spans stay queued
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	col.setState(StateReady)
+
+	submit := func(n int) {
+		for i := 0; i < n; i++ {
+			col.SubmitSpan(&models.Span{})
+		}
+	}
+
+	submit(7)
+	if hint := col.ThrottleHint(); hint != nil {
+		t.Fatalf("expected no hint at 70%% fill, got %+v", hint)
+	}
+
+	submit(2)
+	hint := col.ThrottleHint()
+	if hint == nil {
+		t.Fatal("expected hint at 90% fill")
+	}
+	if hint.SampleRate >= 1 || hint.SampleRate < minThrottleSampleRate {
+		t.Errorf("sample rate = %v, want in [%v, 1)", hint.SampleRate, minThrottleSampleRate)
+	}
+	if hint.BackoffMs != 0 {
+		t.Errorf("backoff = %d, want 0 before the queue is full", hint.BackoffMs)
+	}
+
+	submit(1)
+	hint = col.ThrottleHint()
+	if hint.SampleRate != minThrottleSampleRate || hint.BackoffMs == 0 {
+		t.Errorf("full queue hint = %+v, want min rate with backoff", hint)
+	}
+}
+
+func TestHandlePostSpan_ThrottleHeaders(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 2}, slog.Default())
+	col.setState(StateReady) // Accept spans without workers draining the queue
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+	}
+	spanJSON, _ := json.Marshal(span)
+
+	post := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/spans", bytes.NewReader(spanJSON))
+		rec := httptest.NewRecorder()
+		col.HandlePostSpan(rec, req)
+		return rec
+	}
+
+	// Half full: no hint
+	if rec := post(); rec.Header().Get(ThrottleSampleRateHeader) != "" {
+		t.Errorf("unexpected throttle header at 50%% fill")
+	}
+
+	// Full: accepted with a hint in headers and body
+	rec := post()
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+	if rec.Header().Get(ThrottleSampleRateHeader) == "" || rec.Header().Get("Retry-After") != "1" {
+		t.Errorf("missing throttle headers: %v", rec.Header())
+	}
+	var body struct {
+		Throttle *models.ThrottleHint `json:"throttle"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if body.Throttle == nil {
+		t.Error("expected throttle hint in response body")
+	}
+
+	// Rejected: 503 still carries the hint
+	rec = post()
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After on rejected span")
+	}
+}
+
+func TestIngestHandler_ConcurrencyLimit(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, MaxInFlightRequests: 1}, slog.Default())
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	blocking := col.ingest.limit(func(w http.ResponseWriter, r *http.Request) {
+		close(entered)
+		<-release
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	done := make(chan int)
+	go func() {
+		rec := httptest.NewRecorder()
+		blocking(rec, httptest.NewRequest(http.MethodPost, "/api/v1/spans", nil))
+		done <- rec.Code
+	}()
+	<-entered
+
+	// The only slot is taken: refused before the body is read
+	rec := httptest.NewRecorder()
+	col.HandlePostSpan(rec, httptest.NewRequest(http.MethodPost, "/api/v1/spans", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("over limit: status %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if m := col.GetMetrics(); m.IngestInFlight != 1 || m.IngestRejected != 1 {
+		t.Errorf("in flight %d, rejected %d, want 1 and 1", m.IngestInFlight, m.IngestRejected)
+	}
+
+	close(release)
+	if code := <-done; code != http.StatusAccepted {
+		t.Errorf("admitted request status = %d", code)
+	}
+	if m := col.GetMetrics(); m.IngestInFlight != 0 {
+		t.Errorf("in flight after release = %d", m.IngestInFlight)
+	}
+}
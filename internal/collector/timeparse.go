@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts accepted for absolute times without an explicit offset; they are
+// interpreted in the request's time zone (the tz parameter, default UTC).
+var localTimeLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseTimeParam parses a time query parameter. Accepted forms:
+//
+//	2024-01-15T10:00:00Z        RFC3339 (offset included)
+//	2024-01-15T10:00:00         local time in loc
+//	1705312800000               epoch milliseconds
+//	-1h, now-90m, now           relative to now
+func parseTimeParam(value string, now time.Time, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+
+	// Relative: "now", "now-1h", "-1h", "+5m"
+	if value == "now" {
+		return now, nil
+	}
+	if rel := strings.TrimPrefix(value, "now"); strings.HasPrefix(rel, "-") || strings.HasPrefix(rel, "+") {
+		d, err := time.ParseDuration(rel)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid relative time %q: %w", value, err)
+		}
+		return now.Add(d), nil
+	}
+
+	// Epoch milliseconds
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q: want RFC3339, epoch millis or relative (e.g. -1h)", value)
+}
@@ -0,0 +1,64 @@
+package collector
+
+import (
+	"log/slog"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+)
+
+func TestParseTimeParam(t *testing.T) {
+	now := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	est := time.FixedZone("EST", -5*3600)
+
+	tests := []struct {
+		value string
+		loc   *time.Location
+		want  time.Time
+	}{
+		{"now", time.UTC, now},
+		{"-1h", time.UTC, now.Add(-time.Hour)},
+		{"now-90m", time.UTC, now.Add(-90 * time.Minute)},
+		{"+5m", time.UTC, now.Add(5 * time.Minute)},
+		{"1705312800000", time.UTC, time.UnixMilli(1705312800000)},
+		{"2024-01-15T10:00:00Z", est, time.Date(2024, 1, 15, 10, 0, 0, 0, time.UTC)},
+		{"2024-01-15T10:00:00+02:00", time.UTC, time.Date(2024, 1, 15, 8, 0, 0, 0, time.UTC)},
+		{"2024-01-15T10:00:00", est, time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)},
+		{"2024-01-15", time.UTC, time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		got, err := parseTimeParam(tt.value, now, tt.loc)
+		if err != nil {
+			t.Errorf("parseTimeParam(%q) error = %v", tt.value, err)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseTimeParam(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{"yesterday", "-1x", "now-", "2024-13-45"} {
+		if _, err := parseTimeParam(bad, now, time.UTC); err == nil {
+			t.Errorf("parseTimeParam(%q) expected error", bad)
+		}
+	}
+}
+
+func TestParseQuery_Lookback(t *testing.T) {
+	col := NewCollector(storage.NewMemoryStore(10), DefaultConfig(), slog.Default())
+
+	before := time.Now()
+	query, _ := col.parseQuery(httptest.NewRequest("GET", "/api/v1/traces?lookback=30m", nil))
+	if query.StartTime.Before(before.Add(-30*time.Minute)) || query.StartTime.After(time.Now().Add(-30*time.Minute)) {
+		t.Errorf("lookback start_time = %v, want ~30m ago", query.StartTime)
+	}
+
+	// An explicit start_time wins over lookback
+	query, _ = col.parseQuery(httptest.NewRequest("GET", "/api/v1/traces?lookback=30m&start_time=1705312800000", nil))
+	if !query.StartTime.Equal(time.UnixMilli(1705312800000)) {
+		t.Errorf("start_time = %v, want epoch millis value", query.StartTime)
+	}
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// TLSConfig enables TLS on a listener. With ClientCAFile set, clients must
+// also present a certificate signed by one of its CAs (mutual TLS).
+type TLSConfig struct {
+	CertFile     string `json:"cert_file,omitempty"`      // PEM certificate chain
+	KeyFile      string `json:"key_file,omitempty"`       // PEM private key
+	ClientCAFile string `json:"client_ca_file,omitempty"` // PEM CA bundle for client certificates
+}
+
+// Enabled reports whether any TLS setting is present.
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != "" || c.ClientCAFile != ""
+}
+
+// ServerConfig loads the certificate and client CAs. It returns nil when TLS
+// is not configured, so listeners stay plaintext.
+func (c TLSConfig) ServerConfig() (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, errors.New("tls: a certificate and a key are both required")
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if c.ClientCAFile != "" {
+		pem, err := os.ReadFile(c.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", c.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
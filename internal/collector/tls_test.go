@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"log/slog"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// testPKI holds PEM files for a CA, a server certificate for 127.0.0.1 and a
+// client certificate, all signed by the CA.
+type testPKI struct {
+	caFile, serverCert, serverKey, clientCert, clientKey string
+	pool                                                 *x509.CertPool
+}
+
+func newTestPKI(t *testing.T) testPKI {
+	t.Helper()
+	dir := t.TempDir()
+	write := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage, certName, keyName string) (string, string) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: certName},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keyDER, _ := x509.MarshalECPrivateKey(key)
+		return write(certName+".pem", "CERTIFICATE", der), write(keyName, "EC PRIVATE KEY", keyDER)
+	}
+
+	pki := testPKI{caFile: write("ca.pem", "CERTIFICATE", caDER), pool: x509.NewCertPool()}
+	pki.pool.AddCert(ca)
+	pki.serverCert, pki.serverKey = issue(2, x509.ExtKeyUsageServerAuth, "server", "server-key.pem")
+	pki.clientCert, pki.clientKey = issue(3, x509.ExtKeyUsageClientAuth, "client", "client-key.pem")
+	return pki
+}
+
+func TestTLSConfig_ServerConfig(t *testing.T) {
+	pki := newTestPKI(t)
+
+	if config, err := (TLSConfig{}).ServerConfig(); config != nil || err != nil {
+		t.Errorf("disabled: config %v, err %v; want nil, nil", config, err)
+	}
+
+	config, err := TLSConfig{CertFile: pki.serverCert, KeyFile: pki.serverKey, ClientCAFile: pki.caFile}.ServerConfig()
+	if err != nil {
+		t.Fatalf("ServerConfig failed: %v", err)
+	}
+	if len(config.Certificates) != 1 || config.ClientAuth != tls.RequireAndVerifyClientCert || config.ClientCAs == nil {
+		t.Errorf("config = %+v, want a certificate and required client certificates", config)
+	}
+
+	for _, bad := range []TLSConfig{
+		{CertFile: pki.serverCert},
+		{ClientCAFile: pki.caFile},
+		{CertFile: pki.serverCert, KeyFile: pki.clientKey}, // Mismatched key
+		{CertFile: pki.serverCert, KeyFile: pki.serverKey, ClientCAFile: pki.serverKey}, // Not a certificate
+	} {
+		if _, err := bad.ServerConfig(); err == nil {
+			t.Errorf("ServerConfig(%+v) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestHTTPReceiver_MutualTLS(t *testing.T) {
+	pki := newTestPKI(t)
+	ctx := context.Background()
+
+	receiverConfig, _ := json.Marshal(HTTPReceiverConfig{
+		Addr: "127.0.0.1:0",
+		TLS:  TLSConfig{CertFile: pki.serverCert, KeyFile: pki.serverKey, ClientCAFile: pki.caFile},
+	})
+	manager, err := receiver.NewManager([]receiver.Spec{{Name: "http", Config: receiverConfig}}, slog.Default())
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.Start(ctx, consumerFunc(func(*models.Span) error { return nil })); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop(ctx)
+	url := "https://" + manager.Receivers()["http"].(*httpReceiver).bound + "/api/v1/spans/batch"
+
+	post := func(config *tls.Config) error {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+		resp, err := client.Post(url, "application/json", nil)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// The server answers plaintext requests with a 400 before any handler runs
+	if resp, err := http.Post("http"+url[len("https"):], "application/json", nil); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("plaintext request: status = %d, want 400", resp.StatusCode)
+		}
+	}
+	if err := post(&tls.Config{RootCAs: pki.pool}); err == nil {
+		t.Error("request without a client certificate succeeded")
+	}
+	clientCert, err := tls.LoadX509KeyPair(pki.clientCert, pki.clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := post(&tls.Config{RootCAs: pki.pool, Certificates: []tls.Certificate{clientCert}}); err != nil {
+		t.Errorf("request with a client certificate failed: %v", err)
+	}
+}
+
+func TestOTLPGRPCReceiver_TLS(t *testing.T) {
+	pki := newTestPKI(t)
+	ctx := context.Background()
+
+	receiverConfig, _ := json.Marshal(OTLPGRPCReceiverConfig{
+		Addr: "127.0.0.1:0",
+		TLS:  TLSConfig{CertFile: pki.serverCert, KeyFile: pki.serverKey},
+	})
+	manager, err := receiver.NewManager([]receiver.Spec{{Name: "otlp_grpc", Config: receiverConfig}}, slog.Default())
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+	if err := manager.Start(ctx, consumerFunc(func(*models.Span) error { return nil })); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer manager.Stop(ctx)
+
+	conn, err := grpc.NewClient(manager.Receivers()["otlp_grpc"].(*otlpGRPCReceiver).bound,
+		grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pki.pool})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	callCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if _, err := coltracepb.NewTraceServiceClient(conn).Export(callCtx, &coltracepb.ExportTraceServiceRequest{}); err != nil {
+		t.Errorf("export over TLS failed: %v", err)
+	}
+}
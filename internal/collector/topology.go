@@ -0,0 +1,302 @@
+package collector
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/events"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Topology change types
+const (
+	EdgeAdded   = "added"
+	EdgeRemoved = "removed"
+)
+
+// DefaultTopologyEdgeTTL is how long an edge may go unseen before it is
+// reported as removed.
+const DefaultTopologyEdgeTTL = time.Hour
+
+const (
+	maxTopologyChanges   = 1000 // Changes kept, oldest discarded first
+	topologyQueueSize    = 1024 // Completed traces buffered for the tracker
+	topologyExpiryPeriod = time.Minute
+)
+
+// ServiceEdge is a call from one service to another, seen in a trace as a
+// span whose parent belongs to a different service.
+type ServiceEdge struct {
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Calls     int64     `json:"calls"` // Spans seen on this edge
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+
+	// Deployments of the caller and callee when the edge first appeared
+	FromDeployment string `json:"from_deployment,omitempty"`
+	ToDeployment   string `json:"to_deployment,omitempty"`
+}
+
+// TopologyChange records an edge appearing in or disappearing from the graph.
+type TopologyChange struct {
+	Type string    `json:"type"` // EdgeAdded or EdgeRemoved
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	At   time.Time `json:"at"`
+
+	// Deployments of the caller and callee when the edge was first (added)
+	// or last (removed) seen
+	FromDeployment string `json:"from_deployment,omitempty"`
+	ToDeployment   string `json:"to_deployment,omitempty"`
+}
+
+type topologyEdgeKey struct {
+	from, to string
+}
+
+// Topology tracks the service dependency graph derived from completed traces
+// and the edges added to or removed from it. Edges seen during the first
+// edge TTL after startup form the baseline and are not reported as added.
+// Topology is safe for concurrent use.
+type Topology struct {
+	mu       sync.Mutex
+	edgeTTL  time.Duration
+	baseline time.Time // Edges first seen before this are not changes
+	edges    map[topologyEdgeKey]*topologyEdge
+	changes  []TopologyChange // Oldest first
+	now      func() time.Time
+}
+
+type topologyEdge struct {
+	ServiceEdge
+	lastFromDeployment string
+	lastToDeployment   string
+}
+
+func newTopology(edgeTTL time.Duration) *Topology {
+	if edgeTTL <= 0 {
+		edgeTTL = DefaultTopologyEdgeTTL
+	}
+	t := &Topology{
+		edgeTTL: edgeTTL,
+		edges:   make(map[topologyEdgeKey]*topologyEdge),
+		now:     time.Now,
+	}
+	t.baseline = t.now().Add(edgeTTL)
+	return t
+}
+
+// Observe adds the cross-service calls of a completed trace to the graph.
+func (t *Topology) Observe(trace *models.Trace) {
+	parents := make(map[string]*models.Span, len(trace.Spans))
+	for i := range trace.Spans {
+		parents[trace.Spans[i].SpanID] = &trace.Spans[i]
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	for i := range trace.Spans {
+		span := &trace.Spans[i]
+		parent, ok := parents[span.ParentSpanID]
+		if !ok || parent.ServiceName == span.ServiceName {
+			continue
+		}
+
+		key := topologyEdgeKey{from: parent.ServiceName, to: span.ServiceName}
+		edge, ok := t.edges[key]
+		if !ok {
+			edge = &topologyEdge{ServiceEdge: ServiceEdge{
+				From:           key.from,
+				To:             key.to,
+				FirstSeen:      now,
+				FromDeployment: parent.DeploymentID,
+				ToDeployment:   span.DeploymentID,
+			}}
+			t.edges[key] = edge
+			if now.After(t.baseline) {
+				t.record(TopologyChange{
+					Type:           EdgeAdded,
+					From:           key.from,
+					To:             key.to,
+					At:             now,
+					FromDeployment: parent.DeploymentID,
+					ToDeployment:   span.DeploymentID,
+				})
+			}
+		}
+		edge.Calls++
+		edge.LastSeen = now
+		edge.lastFromDeployment = parent.DeploymentID
+		edge.lastToDeployment = span.DeploymentID
+	}
+}
+
+// expire removes edges unseen for the edge TTL, recording their removal.
+func (t *Topology) expire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	cutoff := now.Add(-t.edgeTTL)
+	var removed []*topologyEdge
+	for key, edge := range t.edges {
+		if edge.LastSeen.Before(cutoff) {
+			removed = append(removed, edge)
+			delete(t.edges, key)
+		}
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].LastSeen.Before(removed[j].LastSeen) })
+	for _, edge := range removed {
+		t.record(TopologyChange{
+			Type:           EdgeRemoved,
+			From:           edge.From,
+			To:             edge.To,
+			At:             now,
+			FromDeployment: edge.lastFromDeployment,
+			ToDeployment:   edge.lastToDeployment,
+		})
+	}
+}
+
+// record appends a change, discarding the oldest beyond maxTopologyChanges.
+// Caller holds mu.
+func (t *Topology) record(change TopologyChange) {
+	if len(t.changes) >= maxTopologyChanges {
+		t.changes = append(t.changes[:0], t.changes[1:]...)
+	}
+	t.changes = append(t.changes, change)
+}
+
+// Edges returns the current graph, sorted by caller then callee.
+func (t *Topology) Edges() []ServiceEdge {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	edges := make([]ServiceEdge, 0, len(t.edges))
+	for _, edge := range t.edges {
+		edges = append(edges, edge.ServiceEdge)
+	}
+	sort.Slice(edges, func(i, j int) bool {
+		if edges[i].From != edges[j].From {
+			return edges[i].From < edges[j].From
+		}
+		return edges[i].To < edges[j].To
+	})
+	return edges
+}
+
+// Changes returns the changes at or after since, newest first. A non-empty
+// deployment keeps only changes involving that deployment of either service.
+func (t *Topology) Changes(since time.Time, deployment string) []TopologyChange {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	changes := []TopologyChange{}
+	for i := len(t.changes) - 1; i >= 0; i-- {
+		change := t.changes[i]
+		if change.At.Before(since) {
+			break
+		}
+		if deployment != "" && change.FromDeployment != deployment && change.ToDeployment != deployment {
+			continue
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+// Topology returns the dependency graph tracker, or nil when disabled.
+func (c *Collector) Topology() *Topology {
+	return c.topology
+}
+
+// startTopology feeds completed traces to the tracker and expires stale
+// edges. It stops once the event bus is closed.
+func (c *Collector) startTopology() {
+	if c.topology == nil {
+		return
+	}
+	sub := c.events.Subscribe(topologyQueueSize, events.TraceCompleted)
+
+	// Waited for with the exporters, which also drain the bus on Stop
+	c.exportWg.Add(1)
+	go func() {
+		defer c.exportWg.Done()
+
+		ticker := time.NewTicker(topologyExpiryPeriod)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case evt, ok := <-sub.C():
+				if !ok {
+					return
+				}
+				c.topology.Observe(evt.Trace)
+			case <-ticker.C:
+				c.topology.expire()
+			}
+		}
+	}()
+}
+
+// HandleTopology handles GET /api/v1/topology - the current service
+// dependency graph.
+func (c *Collector) HandleTopology(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.topology == nil {
+		http.Error(w, "topology tracking disabled", http.StatusNotFound)
+		return
+	}
+
+	edges := c.topology.Edges()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"edges": edges,
+		"total": len(edges),
+	})
+}
+
+// HandleTopologyChanges handles GET /api/v1/topology/changes - dependency
+// edges recently added or removed, newest first.
+func (c *Collector) HandleTopologyChanges(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if c.topology == nil {
+		http.Error(w, "topology tracking disabled", http.StatusNotFound)
+		return
+	}
+
+	params := r.URL.Query()
+	var since time.Time
+	if value := params.Get("since"); value != "" {
+		t, err := parseTimeParam(value, time.Now(), time.UTC)
+		if err != nil {
+			writeQueryErrors(w, []QueryParamError{{
+				Param:  "since",
+				Value:  value,
+				Reason: "must be RFC3339, epoch millis or relative (e.g. -24h)",
+			}})
+			return
+		}
+		since = t
+	}
+
+	changes := c.topology.Changes(since, params.Get("deployment"))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"changes": changes,
+		"total":   len(changes),
+	})
+}
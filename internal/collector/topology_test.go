@@ -0,0 +1,113 @@
+package collector
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// callTrace builds a trace in which each service calls the next.
+func callTrace(deployment string, services ...string) *models.Trace {
+	trace := &models.Trace{TraceID: models.GenerateTraceID()}
+	parent := ""
+	for _, service := range services {
+		span := models.Span{
+			TraceID:      trace.TraceID,
+			SpanID:       models.GenerateSpanID(),
+			ParentSpanID: parent,
+			ServiceName:  service,
+			DeploymentID: deployment,
+		}
+		trace.Spans = append(trace.Spans, span)
+		parent = span.SpanID
+	}
+	return trace
+}
+
+func TestTopology_TracksAddedAndRemovedEdges(t *testing.T) {
+	topo := newTopology(time.Hour)
+	now := time.Unix(1700000000, 0)
+	topo.now = func() time.Time { return now }
+	topo.baseline = now
+
+	// Edges present at startup are the baseline, not changes
+	topo.Observe(callTrace("v1", "api", "users", "db"))
+	if changes := topo.Changes(time.Time{}, ""); len(changes) != 0 {
+		t.Errorf("baseline produced changes: %+v", changes)
+	}
+
+	now = now.Add(time.Minute)
+	topo.Observe(callTrace("v2", "api", "payments"))
+	topo.Observe(callTrace("v2", "api", "users"))
+	changes := topo.Changes(time.Time{}, "")
+	if len(changes) != 1 || changes[0].Type != EdgeAdded || changes[0].To != "payments" || changes[0].FromDeployment != "v2" {
+		t.Fatalf("changes = %+v, want api->payments added by v2", changes)
+	}
+
+	edges := topo.Edges()
+	if len(edges) != 3 || edges[0].From != "api" || edges[0].To != "payments" {
+		t.Fatalf("edges = %+v", edges)
+	}
+	if users := edges[1]; users.To != "users" || users.Calls != 2 || users.FromDeployment != "v1" {
+		t.Errorf("api->users = %+v", users)
+	}
+
+	// users->db is not seen again and expires
+	now = now.Add(time.Hour + 30*time.Second)
+	topo.Observe(callTrace("v2", "api", "users"))
+	topo.Observe(callTrace("v2", "api", "payments"))
+	topo.expire()
+	changes = topo.Changes(time.Time{}, "")
+	if len(changes) != 2 || changes[0].Type != EdgeRemoved || changes[0].From != "users" || changes[0].To != "db" {
+		t.Fatalf("changes = %+v, want users->db removed first", changes)
+	}
+	if len(topo.Edges()) != 2 {
+		t.Errorf("edges after expiry = %+v", topo.Edges())
+	}
+
+	if changes := topo.Changes(time.Time{}, "v2"); len(changes) != 1 || changes[0].To != "payments" {
+		t.Errorf("changes for deployment v2 = %+v", changes)
+	}
+	if changes := topo.Changes(now, ""); len(changes) != 1 || changes[0].Type != EdgeRemoved {
+		t.Errorf("changes since removal = %+v", changes)
+	}
+}
+
+func TestHandleTopologyChanges(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, TrackTopology: true}, slog.Default())
+	col.topology.baseline = time.Time{}
+	col.topology.Observe(callTrace("v2", "api", "payments"))
+
+	rec := httptest.NewRecorder()
+	col.HandleTopologyChanges(rec, httptest.NewRequest(http.MethodGet, "/api/v1/topology/changes?since=-1h&deployment=v2", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var body struct {
+		Changes []TopologyChange `json:"changes"`
+	}
+	json.NewDecoder(rec.Body).Decode(&body)
+	if len(body.Changes) != 1 || body.Changes[0].From != "api" {
+		t.Errorf("changes = %+v", body.Changes)
+	}
+
+	rec = httptest.NewRecorder()
+	col.HandleTopologyChanges(rec, httptest.NewRequest(http.MethodGet, "/api/v1/topology/changes?since=yesterday", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid since: status = %d, want 400", rec.Code)
+	}
+
+	disabled := NewCollector(store, DefaultConfig(), slog.Default())
+	rec = httptest.NewRecorder()
+	disabled.HandleTopology(rec, httptest.NewRequest(http.MethodGet, "/api/v1/topology", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("disabled: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// streamFlushSpans is how many spans of a trace are written between flushes,
+// so clients receive a large trace as it is encoded.
+const streamFlushSpans = 1000
+
+// spanPage describes the spans of a GetTrace response paged with span_offset
+// and span_limit. The trace's other fields always describe the whole trace.
+type spanPage struct {
+	Offset  int  `json:"offset"`
+	Limit   int  `json:"limit,omitempty"` // 0 = all spans from offset
+	Total   int  `json:"total"`
+	HasMore bool `json:"has_more"`
+}
+
+// parseSpanPage reads span_offset and span_limit. It returns nil when
+// neither is set, i.e. the response holds every span.
+func parseSpanPage(params url.Values) (*spanPage, []QueryParamError) {
+	if !params.Has("span_offset") && !params.Has("span_limit") {
+		return nil, nil
+	}
+	page := &spanPage{}
+	var errs []QueryParamError
+	if value := params.Get("span_offset"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			page.Offset = n
+		} else {
+			errs = append(errs, QueryParamError{Param: "span_offset", Value: value, Reason: "must be a non-negative integer"})
+		}
+	}
+	if value := params.Get("span_limit"); value != "" {
+		if n, err := strconv.Atoi(value); err == nil && n > 0 {
+			page.Limit = n
+		} else {
+			errs = append(errs, QueryParamError{Param: "span_limit", Value: value, Reason: "must be a positive integer"})
+		}
+	}
+	return page, errs
+}
+
+// parseTraceFormat reads format, which is "flat" (the default) or "tree".
+// Trees are v1 only and hold every span of the trace.
+func parseTraceFormat(params url.Values, version apiVersion) (tree bool, errs []QueryParamError) {
+	switch value := params.Get("format"); value {
+	case "", "flat":
+		return false, nil
+	case "tree":
+		if version != apiV1 {
+			return false, []QueryParamError{{Param: "format", Value: value, Reason: "trees are only served by /api/v1"}}
+		}
+		if params.Has("span_offset") || params.Has("span_limit") {
+			return false, []QueryParamError{{Param: "format", Value: value, Reason: "trees cannot be paged with span_offset or span_limit"}}
+		}
+		return true, nil
+	default:
+		return false, []QueryParamError{{Param: "format", Value: value, Reason: "must be flat or tree"}}
+	}
+}
+
+// apply returns the spans on the page and fills in its totals.
+func (p *spanPage) apply(spans []models.Span) []models.Span {
+	p.Total = len(spans)
+	if p.Offset >= len(spans) {
+		return nil
+	}
+	spans = spans[p.Offset:]
+	if p.Limit > 0 && len(spans) > p.Limit {
+		spans = spans[:p.Limit]
+		p.HasMore = true
+	}
+	return spans
+}
+
+// traceHeaderV1 is a v1 trace without its spans, which are streamed after it.
+type traceHeaderV1 struct {
+	*models.Trace
+	Spans    *struct{} `json:"spans,omitempty"` // Hides Trace.Spans
+	SpanPage *spanPage `json:"span_page,omitempty"`
+}
+
+// traceTreeV1 is a v1 trace with its spans arranged as a tree, answering
+// GetTrace with format=tree.
+type traceTreeV1 struct {
+	*models.Trace
+	Spans *struct{}         `json:"spans,omitempty"` // Hides Trace.Spans
+	Tree  *models.TraceTree `json:"tree"`
+}
+
+// traceHeaderV2 is the v2 equivalent of traceHeaderV1.
+type traceHeaderV2 struct {
+	traceV2
+	SpanPage *spanPage `json:"span_page,omitempty"`
+}
+
+// writeTrace answers GetTrace with the trace and spans, all of its spans or
+// the page of them described by page. Spans are encoded one at a time, so a
+// trace with tens of thousands of spans is never held as one JSON buffer.
+func writeTrace(w http.ResponseWriter, version apiVersion, trace *models.Trace, spans []models.Span, page *spanPage) error {
+	w.Header().Set("Content-Type", "application/json")
+	if version == apiV2 {
+		meta, _ := json.Marshal(responseMetaV2{APIVersion: int(apiV2)})
+		return streamTrace(w, `{"data":`, traceHeaderV2{traceV2: newTraceV2(trace), SpanPage: page}, spans,
+			func(span *models.Span) interface{} { return newSpanV2(span) },
+			`,"meta":`+string(meta)+`}`)
+	}
+	return streamTrace(w, "", traceHeaderV1{Trace: trace, SpanPage: page}, spans,
+		func(span *models.Span) interface{} { return span }, "")
+}
+
+// streamTrace writes header, a JSON object, with a "spans" array of the
+// converted spans added as its last field, between prefix and suffix.
+func streamTrace(w http.ResponseWriter, prefix string, header interface{}, spans []models.Span,
+	convert func(*models.Span) interface{}, suffix string) error {
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriterSize(w, 32<<10)
+	out.WriteString(prefix)
+	out.Write(data[:len(data)-1]) // Reopen the object
+	if len(data) > 2 {
+		out.WriteByte(',')
+	}
+	out.WriteString(`"spans":[`)
+
+	enc := json.NewEncoder(out)
+	controller := http.NewResponseController(w)
+	for i := range spans {
+		if i > 0 {
+			out.WriteByte(',')
+		}
+		if err := enc.Encode(convert(&spans[i])); err != nil {
+			return err
+		}
+		if (i+1)%streamFlushSpans == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+			controller.Flush() // Best effort; not every writer can flush
+		}
+	}
+
+	out.WriteString("]}")
+	out.WriteString(suffix)
+	out.WriteByte('\n')
+	return out.Flush()
+}
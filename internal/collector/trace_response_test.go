@@ -0,0 +1,189 @@
+package collector
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// largeTrace stores a trace of n spans and returns its ID.
+func largeTrace(t *testing.T, store storage.Store, n int) string {
+	t.Helper()
+	traceID := models.GenerateTraceID()
+	start := time.Now().Add(-time.Minute)
+	for i := 0; i < n; i++ {
+		err := store.WriteSpan(context.Background(), &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "query",
+			StartTime:     start.Add(time.Duration(i) * time.Microsecond),
+			Duration:      time.Millisecond,
+			Status:        "ok",
+			Tags:          map[string]string{"db.rows": "1"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return traceID
+}
+
+func TestHandleGetTrace_StreamsLargeTraces(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	traceID := largeTrace(t, store, 2500)
+
+	rec := httptest.NewRecorder()
+	col.HandleGetTrace(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces/"+traceID, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	if !rec.Flushed {
+		t.Error("response was not flushed while streaming")
+	}
+	var trace models.Trace
+	if err := json.NewDecoder(rec.Body).Decode(&trace); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if trace.TraceID != traceID || len(trace.Spans) != 2500 || len(trace.Services) != 1 {
+		t.Errorf("trace %s with %d spans, services %v", trace.TraceID, len(trace.Spans), trace.Services)
+	}
+
+	// Streamed through response compression
+	handler := CompressionMiddleware(col.HandleGetTraceV2)
+	req := httptest.NewRequest(http.MethodGet, "/api/v2/traces/"+traceID, nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	gz, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip: %v", err)
+	}
+	var v2 struct {
+		Data struct {
+			SpanCount int      `json:"span_count"`
+			Spans     []spanV2 `json:"spans"`
+		} `json:"data"`
+		Meta responseMetaV2 `json:"meta"`
+	}
+	if err := json.NewDecoder(gz).Decode(&v2); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if v2.Data.SpanCount != 2500 || len(v2.Data.Spans) != 2500 || v2.Meta.APIVersion != 2 {
+		t.Errorf("v2: span_count %d, %d spans, meta %+v", v2.Data.SpanCount, len(v2.Data.Spans), v2.Meta)
+	}
+}
+
+func TestHandleGetTrace_SpanPages(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	traceID := largeTrace(t, store, 25)
+
+	type page struct {
+		Spans    []models.Span `json:"spans"`
+		SpanPage *spanPage     `json:"span_page"`
+	}
+	get := func(target string) (*httptest.ResponseRecorder, page) {
+		rec := httptest.NewRecorder()
+		col.HandleGetTrace(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		var p page
+		json.NewDecoder(rec.Body).Decode(&p)
+		return rec, p
+	}
+
+	seen := make(map[string]bool)
+	for offset := 0; offset < 25; offset += 10 {
+		rec, p := get("/api/v1/traces/" + traceID + "?span_limit=10&span_offset=" + strconv.Itoa(offset))
+		if p.SpanPage == nil || p.SpanPage.Total != 25 || p.SpanPage.Offset != offset {
+			t.Fatalf("offset %d: span_page = %+v", offset, p.SpanPage)
+		}
+		wantMore := offset+10 < 25
+		if p.SpanPage.HasMore != wantMore || strings.Contains(rec.Header().Get("Link"), `rel="next"`) != wantMore {
+			t.Errorf("offset %d: has_more %v, Link %q", offset, p.SpanPage.HasMore, rec.Header().Get("Link"))
+		}
+		for _, span := range p.Spans {
+			seen[span.SpanID] = true
+		}
+	}
+	if len(seen) != 25 {
+		t.Errorf("paged through %d spans, want 25", len(seen))
+	}
+
+	// Without span parameters the response is unchanged
+	if _, p := get("/api/v1/traces/" + traceID); p.SpanPage != nil || len(p.Spans) != 25 {
+		t.Errorf("unpaged: span_page %+v, %d spans", p.SpanPage, len(p.Spans))
+	}
+	if _, p := get("/api/v1/traces/" + traceID + "?span_offset=100"); len(p.Spans) != 0 || p.SpanPage.Total != 25 {
+		t.Errorf("past the end: %d spans, span_page %+v", len(p.Spans), p.SpanPage)
+	}
+	for _, query := range []string{"span_offset=-1", "span_limit=0", "span_limit=x"} {
+		if rec, _ := get("/api/v1/traces/" + traceID + "?" + query); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestHandleGetTrace_Tree(t *testing.T) {
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	traceID := models.GenerateTraceID()
+	start := time.Now().Add(-time.Minute)
+	root, child := models.GenerateSpanID(), models.GenerateSpanID()
+	for _, span := range []*models.Span{
+		{SpanID: root, OperationName: "GET /", Duration: 100 * time.Millisecond},
+		{SpanID: child, ParentSpanID: root, OperationName: "query", Duration: 40 * time.Millisecond},
+		{SpanID: models.GenerateSpanID(), ParentSpanID: models.GenerateSpanID(), OperationName: "lost", Duration: time.Millisecond},
+	} {
+		span.TraceID, span.ServiceName, span.StartTime, span.Status = traceID, "api", start, "ok"
+		if err := store.WriteSpan(context.Background(), span); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	col.HandleGetTrace(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces/"+traceID+"?format=tree", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body)
+	}
+	var response struct {
+		TraceID string           `json:"trace_id"`
+		Spans   []models.Span    `json:"spans"`
+		Tree    models.TraceTree `json:"tree"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if response.TraceID != traceID || response.Spans != nil {
+		t.Errorf("trace %s with %d flat spans", response.TraceID, len(response.Spans))
+	}
+	tree := response.Tree
+	if len(tree.Roots) != 2 || tree.OrphanSpans != 1 || len(tree.MissingParents) != 1 || tree.Depth != 1 {
+		t.Fatalf("tree = %+v", tree)
+	}
+	top := tree.Roots[0]
+	if top.Span.SpanID != root {
+		top = tree.Roots[1]
+	}
+	if len(top.Children) != 1 || top.Children[0].Span.SpanID != child || top.SelfTime != 60*time.Millisecond || !top.CriticalPath {
+		t.Errorf("root node = %+v", top)
+	}
+
+	for _, query := range []string{"format=nested", "format=tree&span_limit=10"} {
+		rec := httptest.NewRecorder()
+		col.HandleGetTrace(rec, httptest.NewRequest(http.MethodGet, "/api/v1/traces/"+traceID+"?"+query, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/saintparish4/asmbly/internal/wal"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// queuedSpan is a span waiting for a worker, with its write-ahead log
+// record (the zero Position when the WAL is disabled).
+type queuedSpan struct {
+	span *models.Span
+	wal  wal.Position
+}
+
+// appendWAL records an accepted span before it is queued.
+func (c *Collector) appendWAL(span *models.Span) (wal.Position, error) {
+	data, err := json.Marshal(span)
+	if err != nil {
+		return wal.Position{}, fmt.Errorf("failed to encode span for write-ahead log: %w", err)
+	}
+	pos, err := c.wal.Append(data)
+	if err != nil {
+		c.logger.Error("failed to append span to write-ahead log", "error", err)
+		return wal.Position{}, fmt.Errorf("write-ahead log unavailable: %w", err)
+	}
+	return pos, nil
+}
+
+// ackWAL releases a span's record once it no longer needs replaying.
+func (c *Collector) ackWAL(pos wal.Position) {
+	if c.wal != nil {
+		c.wal.Ack(pos)
+	}
+}
+
+// replayWAL queues the spans left in the log by a previous run, waiting for
+// room in the queue. Each is logged again before its old segment is deleted,
+// so a crash during replay loses nothing. Spans may be processed twice;
+// storage upserts by span ID, so replays are idempotent.
+func (c *Collector) replayWAL() {
+	if c.wal == nil {
+		return
+	}
+
+	result, err := c.wal.Replay(func(data []byte) error {
+		var span models.Span
+		if err := json.Unmarshal(data, &span); err != nil {
+			c.logger.Warn("skipping undecodable write-ahead log record", "error", err)
+			return nil
+		}
+		return c.queueWaiting(&span)
+	})
+	if err != nil {
+		c.logger.Error("write-ahead log replay incomplete", "replayed", result.Records, "error", err)
+		return
+	}
+	if result.Segments > 0 {
+		c.logger.Info("replayed write-ahead log",
+			"spans", result.Records,
+			"segments", result.Segments,
+			"torn_segments", result.Torn,
+		)
+	}
+}
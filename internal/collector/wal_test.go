@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/internal/wal"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestWAL_ReplaysSpansAcceptedBeforeCrash(t *testing.T) {
+	dir := t.TempDir()
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Duration:      10 * time.Millisecond,
+		Status:        "ok",
+	}
+
+	// First run: the span is accepted but no worker ever processes it
+	log, err := wal.Open(dir, wal.Options{})
+	if err != nil {
+		t.Fatalf("wal.Open failed: %v", err)
+	}
+	crashed := NewCollector(storage.NewMemoryStore(1000), &Config{Workers: 1, ChannelBuffer: 10, WAL: log}, slog.Default())
+	crashed.setState(StateReady)
+	if err := crashed.SubmitSpan(span); err != nil {
+		t.Fatalf("SubmitSpan failed: %v", err)
+	}
+
+	// Second run replays it on Start
+	log, err = wal.Open(dir, wal.Options{})
+	if err != nil {
+		t.Fatalf("wal.Open failed: %v", err)
+	}
+	store := storage.NewMemoryStore(1000)
+	col := NewCollector(store, &Config{Workers: 1, ChannelBuffer: 10, WAL: log}, slog.Default())
+	ctx := context.Background()
+	col.Start(ctx)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		trace, _ := store.GetTrace(ctx, span.TraceID)
+		if trace != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("replayed span was not stored")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Processed spans leave nothing to replay after a clean shutdown
+	if err := col.Stop(ctx); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	log, _ = wal.Open(dir, wal.Options{})
+	defer log.Close()
+	result, err := log.Replay(func([]byte) error { return nil })
+	if err != nil || result.Records != 0 {
+		t.Errorf("replay after clean shutdown: %+v, %v", result, err)
+	}
+}
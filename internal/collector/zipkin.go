@@ -0,0 +1,191 @@
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// zipkinSpan is a span in the Zipkin v2 JSON format
+// (https://zipkin.io/zipkin-api/#/default/post_spans).
+type zipkinSpan struct {
+	TraceID        string             `json:"traceId"`
+	ID             string             `json:"id"`
+	ParentID       string             `json:"parentId,omitempty"`
+	Name           string             `json:"name,omitempty"`
+	Kind           string             `json:"kind,omitempty"`
+	Timestamp      int64              `json:"timestamp,omitempty"` // Epoch microseconds
+	Duration       int64              `json:"duration,omitempty"`  // Microseconds
+	LocalEndpoint  *zipkinEndpoint    `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *zipkinEndpoint    `json:"remoteEndpoint,omitempty"`
+	Annotations    []zipkinAnnotation `json:"annotations,omitempty"`
+	Tags           map[string]string  `json:"tags,omitempty"`
+	Debug          bool               `json:"debug,omitempty"`
+	Shared         bool               `json:"shared,omitempty"`
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	IPv6        string `json:"ipv6,omitempty"`
+	Port        int    `json:"port,omitempty"`
+}
+
+type zipkinAnnotation struct {
+	Timestamp int64  `json:"timestamp"` // Epoch microseconds
+	Value     string `json:"value"`
+}
+
+// zipkinErrorTag marks a failed span; its value is the error message.
+const zipkinErrorTag = "error"
+
+// handleZipkinSpans handles POST /api/v2/spans - Zipkin v2 JSON ingestion,
+// so Zipkin-instrumented services can switch by changing the endpoint URL.
+// Like Zipkin, it answers 202 with an empty body.
+func (h *ingestHandler) handleZipkinSpans(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if contentType, _, _ := mime.ParseMediaType(ct); contentType != "application/json" {
+			http.Error(w, "unsupported content type, want application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		h.writeBodyError(w, err)
+		return
+	}
+
+	var zspans []zipkinSpan
+	if err := json.Unmarshal(body, &zspans); err != nil {
+		h.logger.Error("failed to parse Zipkin JSON", "error", err)
+		http.Error(w, "invalid JSON, want a Zipkin v2 span array", http.StatusBadRequest)
+		return
+	}
+	if !h.admit(w, r, len(zspans)) {
+		return
+	}
+
+	consumer := withOrigin(h.consumer, h.origin.httpOrigin(r))
+	accepted, rejected := 0, 0
+	var submitErr error
+	for i := range zspans {
+		span, err := spanFromZipkin(&zspans[i])
+		if err != nil {
+			h.logger.Warn("invalid Zipkin span", "span_index", i, "error", err)
+			rejected++
+			continue
+		}
+		if err := consumer.SubmitSpan(span); err != nil {
+			submitErr = err
+			rejected++
+			continue
+		}
+		accepted++
+	}
+	if rejected > 0 {
+		h.logger.Warn("rejected Zipkin spans", "rejected", rejected, "total", len(zspans))
+	}
+
+	if accepted == 0 && submitErr != nil {
+		h.writeSubmitError(w, submitErr)
+		return
+	}
+	h.setThrottleHeaders(w)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// spanFromZipkin converts a Zipkin v2 span. 64-bit trace IDs are left-padded
+// to 128 bits, annotations become span events, and an "error" tag marks the
+// span as failed.
+func spanFromZipkin(z *zipkinSpan) (*models.Span, error) {
+	traceID := strings.ToLower(z.TraceID)
+	if len(traceID) == 16 {
+		traceID = strings.Repeat("0", 16) + traceID
+	}
+	if !models.IsValidTraceID(traceID) {
+		return nil, fmt.Errorf("invalid traceId %q", z.TraceID)
+	}
+	spanID := strings.ToLower(z.ID)
+	if !models.IsValidSpanID(spanID) {
+		return nil, fmt.Errorf("invalid id %q", z.ID)
+	}
+	if z.Timestamp <= 0 {
+		return nil, errors.New("timestamp is required")
+	}
+
+	span := &models.Span{
+		TraceID:       traceID,
+		SpanID:        spanID,
+		ParentSpanID:  strings.ToLower(z.ParentID),
+		ServiceName:   "unknown_service",
+		OperationName: z.Name,
+		StartTime:     time.UnixMicro(z.Timestamp).UTC(),
+		Duration:      time.Duration(z.Duration) * time.Microsecond,
+		SpanKind:      zipkinSpanKind(z.Kind),
+		Status:        "ok",
+		Tags:          make(map[string]string, len(z.Tags)),
+	}
+	if span.OperationName == "" {
+		span.OperationName = "unknown"
+	}
+	if z.LocalEndpoint != nil && z.LocalEndpoint.ServiceName != "" {
+		span.ServiceName = z.LocalEndpoint.ServiceName
+	}
+	if z.RemoteEndpoint != nil {
+		if z.RemoteEndpoint.ServiceName != "" {
+			span.Tags["peer.service"] = z.RemoteEndpoint.ServiceName
+		}
+		if ip := z.RemoteEndpoint.IPv4 + z.RemoteEndpoint.IPv6; ip != "" {
+			span.Tags["peer.address"] = ip
+		}
+	}
+
+	for k, v := range z.Tags {
+		span.Tags[k] = v
+	}
+	if msg, ok := z.Tags[zipkinErrorTag]; ok {
+		span.Status = "error"
+		span.StatusMessage = msg
+	}
+	for _, a := range z.Annotations {
+		if len(span.Events) < models.MaxSpanEvents {
+			span.AddEvent(a.Value, time.UnixMicro(a.Timestamp).UTC(), nil)
+		}
+	}
+	if z.Debug {
+		span.Tags["zipkin.debug"] = "true"
+	}
+	if z.Shared {
+		span.Tags["zipkin.shared"] = "true"
+	}
+
+	return span, nil
+}
+
+// zipkinSpanKind maps Zipkin span kinds to the model's kind names.
+func zipkinSpanKind(kind string) string {
+	switch kind {
+	case "SERVER":
+		return "server"
+	case "CLIENT":
+		return "client"
+	case "PRODUCER":
+		return "producer"
+	case "CONSUMER":
+		return "consumer"
+	default:
+		return "internal"
+	}
+}
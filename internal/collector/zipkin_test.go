@@ -0,0 +1,78 @@
+package collector
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const zipkinPayload = `[
+	{
+		"traceId": "463ac35c9f6413ad",
+		"id": "72485a3953bb6124",
+		"name": "get /checkout",
+		"kind": "SERVER",
+		"timestamp": 1700000000000000,
+		"duration": 150000,
+		"localEndpoint": {"serviceName": "frontend"},
+		"remoteEndpoint": {"serviceName": "browser", "ipv4": "10.0.0.1"},
+		"annotations": [{"timestamp": 1700000000050000, "value": "cache.miss"}],
+		"tags": {"http.method": "GET", "error": "timeout"}
+	},
+	{"traceId": "not-hex", "id": "72485a3953bb6125", "timestamp": 1700000000000000}
+]`
+
+func TestHandleZipkinSpans(t *testing.T) {
+	col, store := startOTLPCollector(t)
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write([]byte(zipkinPayload))
+	gz.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v2/spans", &body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	col.HandleZipkinSpans(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want 202: %s", rec.Code, rec.Body.String())
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	trace, _ := store.GetTrace(context.Background(), "0000000000000000463ac35c9f6413ad")
+	if trace == nil || len(trace.Spans) != 1 {
+		t.Fatalf("expected 1 stored span, got %v", trace)
+	}
+	span := trace.Spans[0]
+	if span.ServiceName != "frontend" || span.OperationName != "get /checkout" || span.SpanKind != "server" {
+		t.Errorf("unexpected span identity: %+v", span)
+	}
+	if span.Duration != 150*time.Millisecond {
+		t.Errorf("duration = %v, want 150ms", span.Duration)
+	}
+	if span.Status != "error" || span.StatusMessage != "timeout" {
+		t.Errorf("status = %q (%q), want error (timeout)", span.Status, span.StatusMessage)
+	}
+	if span.Tags["peer.service"] != "browser" || span.Tags["http.method"] != "GET" {
+		t.Errorf("unexpected tags: %v", span.Tags)
+	}
+	if len(span.Events) != 1 || span.Events[0].Name != "cache.miss" ||
+		!span.Events[0].Timestamp.Equal(time.UnixMicro(1700000000050000)) {
+		t.Errorf("annotations not mapped to events: %+v", span.Events)
+	}
+}
+
+func TestHandleZipkinSpans_InvalidJSON(t *testing.T) {
+	col, _ := startOTLPCollector(t)
+
+	rec := httptest.NewRecorder()
+	col.HandleZipkinSpans(rec, httptest.NewRequest(http.MethodPost, "/api/v2/spans", bytes.NewBufferString(`{"traceId": "x"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
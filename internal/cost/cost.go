@@ -0,0 +1,209 @@
+// Package cost prices spans from configured rules: compute time per CPU
+// second, LLM calls per token and network egress per GB. Costs are then
+// attributed centrally instead of by every instrumented client.
+package cost
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/saintparish4/asmbly/internal/pattern"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Default tags usage is read from.
+const (
+	DefaultCPUTag          = "cpu.seconds"
+	DefaultInputTokensTag  = "gen_ai.usage.input_tokens"
+	DefaultOutputTokensTag = "gen_ai.usage.output_tokens"
+	DefaultModelTag        = "gen_ai.request.model"
+	DefaultEgressTag       = "http.response.body.size"
+)
+
+// AnyModel prices tokens of models a rule does not list.
+const AnyModel = "*"
+
+// bytesPerGB is the unit of egress prices (decimal, as cloud providers bill).
+const bytesPerGB = 1e9
+
+// TokenPrice is the price of an LLM model's tokens, per million.
+type TokenPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Rule prices the spans matching Service and Operation; patterns may use
+// '*' and empty patterns match every span. Prices are in the collector's
+// -currency units.
+type Rule struct {
+	Service   string `json:"service,omitempty"`
+	Operation string `json:"operation,omitempty"`
+
+	// CPUSecond is the price of a CPU second: the CPU tag's value, or the
+	// span's duration when the span does not report CPU time
+	CPUSecond float64 `json:"cpu_second,omitempty"`
+
+	// Tokens are token prices by model, the model tag's value; AnyModel
+	// prices the others. Spans without token counts cost nothing here.
+	Tokens map[string]TokenPrice `json:"tokens,omitempty"`
+
+	// EgressGB is the price of a GB (10^9 bytes) in the egress tag
+	EgressGB float64 `json:"egress_gb,omitempty"`
+}
+
+// Tags names the span tags usage is read from (empty = the default).
+type Tags struct {
+	CPU          string `json:"cpu,omitempty"`           // CPU seconds
+	InputTokens  string `json:"input_tokens,omitempty"`  // Prompt tokens
+	OutputTokens string `json:"output_tokens,omitempty"` // Completion tokens
+	Model        string `json:"model,omitempty"`         // LLM model name
+	Egress       string `json:"egress,omitempty"`        // Bytes sent
+}
+
+// Config is the "pricing" section of the collector's config file.
+type Config struct {
+	// Rules are checked in order; the first one matching a span prices it
+	Rules []Rule `json:"rules"`
+	Tags  Tags   `json:"tags,omitempty"`
+
+	// Override replaces costs clients sent; by default only spans without
+	// a cost are priced
+	Override bool `json:"override,omitempty"`
+}
+
+// Breakdown is a span's cost by what it paid for.
+type Breakdown struct {
+	CPU    float64 `json:"cpu"`
+	Tokens float64 `json:"tokens"`
+	Egress float64 `json:"egress"`
+}
+
+// Total is the span's cost.
+func (b Breakdown) Total() float64 { return b.CPU + b.Tokens + b.Egress }
+
+// Pricer assigns span costs from a Config.
+type Pricer struct {
+	rules    []compiledRule
+	tags     Tags
+	override bool
+}
+
+type compiledRule struct {
+	Rule
+	service, operation *regexp.Regexp
+}
+
+// New builds a pricer from config.
+func New(config Config) (*Pricer, error) {
+	if len(config.Rules) == 0 {
+		return nil, errors.New("pricing: at least one rule is required")
+	}
+	p := &Pricer{tags: config.Tags, override: config.Override}
+	for _, tag := range []struct {
+		name *string
+		def  string
+	}{
+		{&p.tags.CPU, DefaultCPUTag},
+		{&p.tags.InputTokens, DefaultInputTokensTag},
+		{&p.tags.OutputTokens, DefaultOutputTokensTag},
+		{&p.tags.Model, DefaultModelTag},
+		{&p.tags.Egress, DefaultEgressTag},
+	} {
+		if *tag.name == "" {
+			*tag.name = tag.def
+		}
+	}
+
+	for i, rule := range config.Rules {
+		if rule.CPUSecond < 0 || rule.EgressGB < 0 {
+			return nil, fmt.Errorf("pricing rule %d: prices must not be negative", i)
+		}
+		if rule.CPUSecond == 0 && rule.EgressGB == 0 && len(rule.Tokens) == 0 {
+			return nil, fmt.Errorf("pricing rule %d: set at least one of cpu_second, tokens or egress_gb", i)
+		}
+		for model, price := range rule.Tokens {
+			if price.Input < 0 || price.Output < 0 {
+				return nil, fmt.Errorf("pricing rule %d: token prices of %q must not be negative", i, model)
+			}
+		}
+		p.rules = append(p.rules, compiledRule{
+			Rule:      rule,
+			service:   pattern.Compile(rule.Service),
+			operation: pattern.Compile(rule.Operation),
+		})
+	}
+	return p, nil
+}
+
+// Price returns what span costs under the first rule matching it, and
+// whether any rule did.
+func (p *Pricer) Price(span *models.Span) (Breakdown, bool) {
+	for i := range p.rules {
+		rule := &p.rules[i]
+		if rule.matches(span) {
+			return p.price(rule, span), true
+		}
+	}
+	return Breakdown{}, false
+}
+
+// Apply sets span's cost from the rules. Spans that already have a cost
+// keep it unless the config overrides client costs, and spans no rule
+// matches keep theirs.
+func (p *Pricer) Apply(span *models.Span) {
+	if span.Cost != 0 && !p.override {
+		return
+	}
+	if breakdown, ok := p.Price(span); ok {
+		span.Cost = breakdown.Total()
+	}
+}
+
+func (p *Pricer) price(rule *compiledRule, span *models.Span) Breakdown {
+	var b Breakdown
+	if rule.CPUSecond > 0 {
+		seconds, ok := tagNumber(span, p.tags.CPU)
+		if !ok {
+			seconds = span.Duration.Seconds()
+		}
+		b.CPU = seconds * rule.CPUSecond
+	}
+	if len(rule.Tokens) > 0 {
+		price, ok := rule.Tokens[span.Tags[p.tags.Model]]
+		if !ok {
+			price = rule.Tokens[AnyModel]
+		}
+		input, _ := tagNumber(span, p.tags.InputTokens)
+		output, _ := tagNumber(span, p.tags.OutputTokens)
+		b.Tokens = (input*price.Input + output*price.Output) / 1e6
+	}
+	if rule.EgressGB > 0 {
+		bytes, _ := tagNumber(span, p.tags.Egress)
+		b.Egress = bytes / bytesPerGB * rule.EgressGB
+	}
+	return b
+}
+
+// tagNumber reads a non-negative number from a span tag.
+func tagNumber(span *models.Span, tag string) (float64, bool) {
+	value, ok := span.Tags[tag]
+	if !ok {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || n < 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return 0, false
+	}
+	return n, true
+}
+
+func (r *compiledRule) matches(span *models.Span) bool {
+	if r.service != nil && !r.service.MatchString(span.ServiceName) {
+		return false
+	}
+	return r.operation == nil || r.operation.MatchString(span.OperationName)
+}
@@ -0,0 +1,117 @@
+package cost
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func approx(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestPricer_Price(t *testing.T) {
+	pricer, err := New(Config{Rules: []Rule{
+		{Service: "llm-*", Tokens: map[string]TokenPrice{
+			"gpt-4o": {Input: 2.5, Output: 10},
+			AnyModel: {Input: 1, Output: 1},
+		}},
+		{Service: "cdn", EgressGB: 0.08},
+		{CPUSecond: 0.00005},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		span models.Span
+		want Breakdown
+	}{
+		{
+			name: "tokens of a listed model",
+			span: models.Span{ServiceName: "llm-gateway", Tags: map[string]string{
+				DefaultModelTag: "gpt-4o", DefaultInputTokensTag: "1000", DefaultOutputTokensTag: "500",
+			}},
+			want: Breakdown{Tokens: 0.0025 + 0.005},
+		},
+		{
+			name: "tokens of another model",
+			span: models.Span{ServiceName: "llm-gateway", Tags: map[string]string{
+				DefaultModelTag: "claude", DefaultInputTokensTag: "2000000",
+			}},
+			want: Breakdown{Tokens: 2},
+		},
+		{
+			name: "egress",
+			span: models.Span{ServiceName: "cdn", Tags: map[string]string{DefaultEgressTag: "5000000000"}},
+			want: Breakdown{Egress: 0.4},
+		},
+		{
+			name: "reported CPU time",
+			span: models.Span{ServiceName: "api", Duration: time.Second, Tags: map[string]string{DefaultCPUTag: "4"}},
+			want: Breakdown{CPU: 0.0002},
+		},
+		{
+			name: "duration without CPU time",
+			span: models.Span{ServiceName: "api", Duration: 2 * time.Second, Tags: map[string]string{DefaultCPUTag: "lots"}},
+			want: Breakdown{CPU: 0.0001},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pricer.Price(&tt.span)
+			if !ok || !approx(got.CPU, tt.want.CPU) || !approx(got.Tokens, tt.want.Tokens) || !approx(got.Egress, tt.want.Egress) {
+				t.Errorf("Price = %+v %v, want %+v", got, ok, tt.want)
+			}
+		})
+	}
+}
+
+func TestPricer_Apply(t *testing.T) {
+	rules := []Rule{{Service: "api", CPUSecond: 1}}
+	span := func(cost float64) *models.Span {
+		return &models.Span{ServiceName: "api", Duration: 500 * time.Millisecond, Cost: cost}
+	}
+
+	pricer, _ := New(Config{Rules: rules})
+	priced, sent, unmatched := span(0), span(3), &models.Span{ServiceName: "web", Duration: time.Second}
+	pricer.Apply(priced)
+	pricer.Apply(sent)
+	pricer.Apply(unmatched)
+	if priced.Cost != 0.5 || sent.Cost != 3 || unmatched.Cost != 0 {
+		t.Errorf("costs = %v, %v, %v; want 0.5, the client's 3 and 0", priced.Cost, sent.Cost, unmatched.Cost)
+	}
+
+	overriding, _ := New(Config{Rules: rules, Override: true})
+	sent = span(3)
+	overriding.Apply(sent)
+	if sent.Cost != 0.5 {
+		t.Errorf("overridden cost = %v, want 0.5", sent.Cost)
+	}
+}
+
+func TestNew_CustomTags(t *testing.T) {
+	pricer, err := New(Config{Rules: []Rule{{EgressGB: 1}}, Tags: Tags{Egress: "net.sent"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := pricer.Price(&models.Span{Tags: map[string]string{"net.sent": "2e9", DefaultEgressTag: "1e9"}})
+	if got.Egress != 2 {
+		t.Errorf("egress = %v, want 2 from the custom tag", got.Egress)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	for name, config := range map[string]Config{
+		"no rules":        {},
+		"no prices":       {Rules: []Rule{{Service: "api"}}},
+		"negative price":  {Rules: []Rule{{CPUSecond: -1}}},
+		"negative tokens": {Rules: []Rule{{Tokens: map[string]TokenPrice{AnyModel: {Input: -1}}}}},
+		"negative egress": {Rules: []Rule{{CPUSecond: 1, EgressGB: -0.1}}},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("%s: New succeeded", name)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Type identifies the kind of event published on the bus
+type Type string
+
+const (
+	// SpanStored is published after a span has been written to storage
+	SpanStored Type = "span_stored"
+
+	// TraceCompleted is published once a trace has received no new spans
+	// for the collector's idle timeout and is considered complete
+	TraceCompleted Type = "trace_completed"
+)
+
+// Event is a single notification delivered to subscribers.
+// Span and Trace are shared with storage and must be treated as read-only.
+type Event struct {
+	Type  Type
+	Time  time.Time
+	Span  *models.Span  // Set for SpanStored
+	Trace *models.Trace // Set for TraceCompleted
+}
+
+// Bus is an in-process publish/subscribe queue that decouples extensions
+// (processors, notifiers, live tail, aggregators) from the collector's
+// worker loop. Each subscriber has its own buffered queue; publishing never
+// blocks, and events for a full subscriber are dropped and counted.
+// Bus is safe for concurrent use.
+type Bus struct {
+	mu     sync.RWMutex
+	subs   map[Type][]*Subscription
+	closed bool
+}
+
+// Subscription receives events of the types it subscribed to.
+type Subscription struct {
+	ch      chan Event
+	types   []Type
+	dropped int64 // atomic
+}
+
+// NewBus creates an empty event bus.
+func NewBus() *Bus {
+	return &Bus{
+		subs: make(map[Type][]*Subscription),
+	}
+}
+
+// Subscribe registers a subscriber for the given event types with a queue of
+// size buffer. The returned subscription's channel is closed on Unsubscribe
+// or when the bus is closed.
+func (b *Bus) Subscribe(buffer int, types ...Type) *Subscription {
+	if buffer <= 0 {
+		buffer = 1
+	}
+
+	sub := &Subscription{
+		ch:    make(chan Event, buffer),
+		types: types,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		close(sub.ch)
+		return sub
+	}
+	for _, t := range types {
+		b.subs[t] = append(b.subs[t], sub)
+	}
+	return sub
+}
+
+// Unsubscribe removes a subscription and closes its channel.
+func (b *Bus) Unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	removed := false
+	for _, t := range sub.types {
+		subs := b.subs[t]
+		for i, s := range subs {
+			if s == sub {
+				b.subs[t] = append(subs[:i:i], subs[i+1:]...)
+				removed = true
+				break
+			}
+		}
+	}
+	if removed {
+		close(sub.ch)
+	}
+}
+
+// HasSubscribers reports whether anyone listens for events of type t.
+// Publishers use it to skip work that only feeds subscribers.
+func (b *Bus) HasSubscribers(t Type) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs[t]) > 0
+}
+
+// Publish delivers an event to every subscriber of its type without blocking.
+func (b *Bus) Publish(evt Event) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	if b.closed {
+		return
+	}
+	for _, sub := range b.subs[evt.Type] {
+		select {
+		case sub.ch <- evt:
+		default:
+			// Subscriber queue full - drop rather than stall ingestion
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
+
+// Close closes every subscription channel. Publishing after Close is a no-op.
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	b.closed = true
+
+	seen := make(map[*Subscription]bool)
+	for _, subs := range b.subs {
+		for _, sub := range subs {
+			if !seen[sub] {
+				seen[sub] = true
+				close(sub.ch)
+			}
+		}
+	}
+	b.subs = nil
+}
+
+// C returns the channel on which events are delivered.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns how many events were dropped because the queue was full.
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestBus_DeliversBySubscribedType(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	spans := bus.Subscribe(10, SpanStored)
+	traces := bus.Subscribe(10, TraceCompleted)
+
+	bus.Publish(Event{Type: SpanStored, Span: &models.Span{SpanID: "1111111111111111"}})
+	bus.Publish(Event{Type: TraceCompleted, Trace: &models.Trace{TraceID: "trace"}})
+
+	if evt := <-spans.C(); evt.Span == nil || evt.Span.SpanID != "1111111111111111" {
+		t.Errorf("span subscriber got %+v", evt)
+	}
+	if evt := <-traces.C(); evt.Trace == nil || evt.Trace.TraceID != "trace" {
+		t.Errorf("trace subscriber got %+v", evt)
+	}
+	if len(spans.C()) != 0 || len(traces.C()) != 0 {
+		t.Error("subscribers received events of types they did not subscribe to")
+	}
+}
+
+func TestBus_DropsWhenSubscriberFull(t *testing.T) {
+	bus := NewBus()
+	defer bus.Close()
+
+	sub := bus.Subscribe(1, SpanStored)
+
+	for i := 0; i < 3; i++ {
+		bus.Publish(Event{Type: SpanStored})
+	}
+
+	if sub.Dropped() != 2 {
+		t.Errorf("Dropped() = %d, want 2", sub.Dropped())
+	}
+}
+
+func TestBus_UnsubscribeAndClose(t *testing.T) {
+	bus := NewBus()
+
+	sub := bus.Subscribe(1, SpanStored, TraceCompleted)
+	if !bus.HasSubscribers(TraceCompleted) {
+		t.Fatal("HasSubscribers() = false, want true")
+	}
+
+	bus.Unsubscribe(sub)
+	if _, ok := <-sub.C(); ok {
+		t.Error("channel should be closed after Unsubscribe")
+	}
+	if bus.HasSubscribers(SpanStored) {
+		t.Error("HasSubscribers() = true after Unsubscribe")
+	}
+
+	other := bus.Subscribe(1, SpanStored)
+	bus.Close()
+	if _, ok := <-other.C(); ok {
+		t.Error("channel should be closed after Close")
+	}
+
+	// Publishing after Close must not panic
+	bus.Publish(Event{Type: SpanStored})
+}
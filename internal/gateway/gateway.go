@@ -0,0 +1,428 @@
+// Package gateway queries several regional collectors as one. Each region
+// keeps its spans in its own store; the gateway is a read-only
+// storage.Store that fans every query out to the regions' HTTP APIs,
+// merges the answers and labels each trace with the regions it came from.
+// A collector started with a gateway store serves the usual query API,
+// and the web UI, over every region.
+//
+// A trace whose request crossed regions has spans in more than one. The
+// gateway joins its parts into one trace, dropping spans two regions both
+// hold (e.g. replicated ones), so it is listed and counted once.
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// DefaultTimeout bounds each request to a region when Config.Timeout and
+// Region.Timeout are unset.
+const DefaultTimeout = 10 * time.Second
+
+// RegionTag is the span tag naming the region a span was read from. A span
+// that already has it, read through another gateway, keeps its value.
+const RegionTag = "traceflow.region"
+
+// maxRegionTraces is how many traces each region is asked for when a query
+// has no limit.
+const maxRegionTraces = 10000
+
+var (
+	// ErrReadOnly is returned by WriteSpan: spans are sent to the regional
+	// collectors, not the gateway
+	ErrReadOnly = errors.New("gateway: spans are stored by the regional collectors, not the gateway")
+
+	// ErrStatsUnsupported is returned for span stats, whose percentile
+	// histograms the regions' APIs do not expose for merging
+	ErrStatsUnsupported = errors.New("gateway: span stats are not merged across regions")
+)
+
+// Region is a regional collector.
+type Region struct {
+	Name     string            `json:"name"`
+	Endpoint string            `json:"endpoint"` // Base URL, e.g. "https://traces.eu-west-1.example.com"
+	Headers  map[string]string `json:"headers,omitempty"`
+	Timeout  string            `json:"timeout,omitempty"` // Overrides Config.Timeout
+}
+
+// Config is the "gateway" section of the collector's config file.
+type Config struct {
+	Regions []Region `json:"regions"`
+	Timeout string   `json:"timeout,omitempty"` // Per request to a region (default 10s)
+
+	// RequireAll fails a query when any region fails. By default the
+	// gateway answers from the regions that did, so one region's outage
+	// does not stop global search; the failures show in the regions' status
+	RequireAll bool `json:"require_all,omitempty"`
+}
+
+// Store is a read-only storage.Store over the regional collectors. It is
+// safe for concurrent use.
+type Store struct {
+	regions    []*region
+	requireAll bool
+	client     *http.Client
+	logger     *slog.Logger
+}
+
+// New validates config. Regions are not contacted until the first query.
+func New(config Config, logger *slog.Logger) (*Store, error) {
+	if len(config.Regions) == 0 {
+		return nil, errors.New("gateway: at least one region is required")
+	}
+	timeout := DefaultTimeout
+	if config.Timeout != "" {
+		parsed, err := time.ParseDuration(config.Timeout)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("gateway: invalid timeout %q", config.Timeout)
+		}
+		timeout = parsed
+	}
+
+	s := &Store{
+		requireAll: config.RequireAll,
+		client:     &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment}},
+		logger:     logger.With("component", "gateway"),
+	}
+	names := make(map[string]bool)
+	for _, cfg := range config.Regions {
+		r, err := s.newRegion(cfg, timeout)
+		if err != nil {
+			return nil, err
+		}
+		if names[cfg.Name] {
+			return nil, fmt.Errorf("gateway: duplicate region name %q", cfg.Name)
+		}
+		names[cfg.Name] = true
+		s.regions = append(s.regions, r)
+	}
+	return s, nil
+}
+
+func (s *Store) newRegion(cfg Region, timeout time.Duration) (*region, error) {
+	if cfg.Name == "" {
+		return nil, errors.New("gateway: region name is required")
+	}
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("gateway: region %q: endpoint must be an http or https URL", cfg.Name)
+	}
+	if cfg.Timeout != "" {
+		parsed, err := time.ParseDuration(cfg.Timeout)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("gateway: region %q: invalid timeout %q", cfg.Name, cfg.Timeout)
+		}
+		timeout = parsed
+	}
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	return &region{
+		name:     cfg.Name,
+		endpoint: endpoint,
+		headers:  cfg.Headers,
+		timeout:  timeout,
+		client:   s.client,
+		status:   RegionStatus{Name: cfg.Name, Endpoint: endpoint},
+	}, nil
+}
+
+// Regions returns the region names in configured order.
+func (s *Store) Regions() []string {
+	names := make([]string, len(s.regions))
+	for i, r := range s.regions {
+		names[i] = r.name
+	}
+	return names
+}
+
+// Statuses returns the state of every region, in configured order.
+func (s *Store) Statuses() []RegionStatus {
+	if s == nil {
+		return []RegionStatus{}
+	}
+	statuses := make([]RegionStatus, 0, len(s.regions))
+	for _, r := range s.regions {
+		r.mu.Lock()
+		statuses = append(statuses, r.status)
+		r.mu.Unlock()
+	}
+	return statuses
+}
+
+// each calls fn for every region at once and waits for all of them. It
+// returns an error when every region failed or, with RequireAll, any did;
+// otherwise failed regions are only logged, and their results left out.
+func (s *Store) each(ctx context.Context, op string, fn func(ctx context.Context, i int, r *region) error) error {
+	errs := make([]error, len(s.regions))
+	var wg sync.WaitGroup
+	for i, r := range s.regions {
+		wg.Add(1)
+		go func(i int, r *region) {
+			defer wg.Done()
+			if err := fn(ctx, i, r); err != nil {
+				errs[i] = fmt.Errorf("region %q: %w", r.name, err)
+			}
+		}(i, r)
+	}
+	wg.Wait()
+
+	var failed []error
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err)
+		}
+	}
+	if len(failed) == len(s.regions) || (s.requireAll && len(failed) > 0) {
+		return errors.Join(failed...)
+	}
+	for _, err := range failed {
+		s.logger.Warn("region failed, answering from the others", "op", op, "error", err)
+	}
+	return nil
+}
+
+// WriteSpan always fails: the gateway stores nothing.
+func (s *Store) WriteSpan(ctx context.Context, span *models.Span) error {
+	return ErrReadOnly
+}
+
+// GetTrace reads the trace from every region and joins the parts found.
+func (s *Store) GetTrace(ctx context.Context, traceID string) (*models.Trace, error) {
+	parts := make([]*models.Trace, len(s.regions))
+	err := s.each(ctx, "get_trace", func(ctx context.Context, i int, r *region) error {
+		trace, err := r.getTrace(ctx, traceID)
+		if trace != nil {
+			label(trace, r.name)
+		}
+		parts[i] = trace
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merge(parts), nil
+}
+
+// FindTraces runs the query in every region and merges the results in the
+// query's order. Filters apply to each region's part of a trace.
+func (s *Store) FindTraces(ctx context.Context, query *storage.Query) ([]*models.Trace, error) {
+	// Each region must return enough results to fill the merged page
+	limit := maxRegionTraces
+	if query.Limit > 0 {
+		limit = query.Offset + query.Limit
+	}
+	params := queryParams(query, limit)
+
+	results := make([][]*models.Trace, len(s.regions))
+	err := s.each(ctx, "find_traces", func(ctx context.Context, i int, r *region) error {
+		resp, err := r.findTraces(ctx, params)
+		for _, trace := range resp.Traces {
+			label(trace, r.name)
+		}
+		results[i] = resp.Traces
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Join the parts of traces found in several regions
+	byID := make(map[string][]*models.Trace)
+	var order []string
+	for _, traces := range results {
+		for _, trace := range traces {
+			if _, seen := byID[trace.TraceID]; !seen {
+				order = append(order, trace.TraceID)
+			}
+			byID[trace.TraceID] = append(byID[trace.TraceID], trace)
+		}
+	}
+	merged := make([]*models.Trace, 0, len(order))
+	for _, traceID := range order {
+		trace := merge(byID[traceID])
+		if query.HasProfile != nil && hasProfile(trace) != *query.HasProfile {
+			continue // Not a query parameter, so filtered here
+		}
+		merged = append(merged, trace)
+	}
+
+	query.SortTraces(merged)
+
+	if query.Offset >= len(merged) {
+		return []*models.Trace{}, nil
+	}
+	end := len(merged)
+	if query.Limit > 0 && query.Offset+query.Limit < end {
+		end = query.Offset + query.Limit
+	}
+	page := merged[query.Offset:end]
+	for i, trace := range page {
+		page[i] = storage.ProjectTrace(trace, query.Projection)
+	}
+	return page, nil
+}
+
+// CountTraces sums the regions' counts. A trace with spans in several
+// regions is counted by each of them.
+func (s *Store) CountTraces(ctx context.Context, query *storage.Query) (int, error) {
+	params := queryParams(query, 1)
+	counts := make([]int, len(s.regions))
+	err := s.each(ctx, "count_traces", func(ctx context.Context, i int, r *region) error {
+		resp, err := r.findTraces(ctx, params)
+		counts[i] = resp.Total
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	total := 0
+	for _, n := range counts {
+		total += n
+	}
+	return total, nil
+}
+
+// GetServices returns the union of the regions' services.
+func (s *Store) GetServices(ctx context.Context) ([]string, error) {
+	results := make([][]string, len(s.regions))
+	err := s.each(ctx, "get_services", func(ctx context.Context, i int, r *region) error {
+		services, err := r.services(ctx)
+		results[i] = services
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	services := []string{}
+	for _, list := range results {
+		for _, service := range list {
+			if !seen[service] {
+				seen[service] = true
+				services = append(services, service)
+			}
+		}
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// GetServiceStats is not supported; see ErrStatsUnsupported.
+func (s *Store) GetServiceStats(ctx context.Context, query *storage.StatsQuery) ([]storage.ServiceStats, error) {
+	return nil, ErrStatsUnsupported
+}
+
+// GetOperationStats is not supported; see ErrStatsUnsupported.
+func (s *Store) GetOperationStats(ctx context.Context, query *storage.StatsQuery) ([]storage.OperationStats, error) {
+	return nil, ErrStatsUnsupported
+}
+
+// Ping checks the regions' /healthz, failing as queries would: when every
+// region is down or, with RequireAll, any is.
+func (s *Store) Ping(ctx context.Context) error {
+	return s.each(ctx, "ping", func(ctx context.Context, i int, r *region) error {
+		return r.ping(ctx)
+	})
+}
+
+// Close releases idle connections to the regions.
+func (s *Store) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// label marks a trace and its spans as read from region, unless a gateway
+// it was read through already did.
+func label(trace *models.Trace, region string) {
+	if len(trace.Regions) == 0 {
+		trace.Regions = []string{region}
+	}
+	for i := range trace.Spans {
+		if trace.Spans[i].GetTag(RegionTag) == "" {
+			trace.Spans[i].SetTag(RegionTag, region)
+		}
+	}
+}
+
+// merge joins the parts of a trace read from several regions, skipping nil
+// parts. A span held by more than one region is kept once, preferring a
+// finished copy over one still in progress. It returns nil if no region
+// had the trace.
+func merge(parts []*models.Trace) *models.Trace {
+	var found []*models.Trace
+	for _, part := range parts {
+		if part != nil {
+			found = append(found, part)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil
+	case 1:
+		return found[0]
+	}
+
+	var spans []models.Span
+	index := make(map[string]int) // Span ID -> position in spans
+	currency := ""
+	var regions []string
+	var expiresAt *time.Time
+	var truncation *models.Truncation
+	for _, part := range found {
+		for _, span := range part.Spans {
+			if i, dup := index[span.SpanID]; dup {
+				if spans[i].InProgress && !span.InProgress {
+					spans[i] = span
+				}
+				continue
+			}
+			index[span.SpanID] = len(spans)
+			spans = append(spans, span)
+		}
+		if currency == "" {
+			currency = part.Currency
+		}
+		regions = append(regions, part.Regions...)
+		// Part of the trace is gone once the first region's copy expires
+		if part.ExpiresAt != nil && (expiresAt == nil || part.ExpiresAt.Before(*expiresAt)) {
+			expiresAt = part.ExpiresAt
+		}
+		if part.Truncation != nil {
+			if truncation == nil {
+				truncation = &models.Truncation{}
+			}
+			truncation.DroppedSpans += part.Truncation.DroppedSpans
+			truncation.DroppedTags += part.Truncation.DroppedTags
+			truncation.TruncatedTags += part.Truncation.TruncatedTags
+		}
+	}
+
+	trace := storage.BuildTrace(found[0].TraceID, spans, currency, 0)
+	trace.ExpiresAt = expiresAt
+	trace.Truncation = truncation
+	slices.Sort(regions)
+	trace.Regions = slices.Compact(regions)
+	return trace
+}
+
+// hasProfile reports whether any span of the trace was profiled.
+func hasProfile(trace *models.Trace) bool {
+	for i := range trace.Spans {
+		if trace.Spans[i].HasProfile {
+			return true
+		}
+	}
+	return false
+}
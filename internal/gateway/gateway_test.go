@@ -0,0 +1,281 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/collector"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// newRegion serves a collector's query API over store, as a regional
+// collector would.
+func newRegion(t *testing.T, store storage.Store) *httptest.Server {
+	t.Helper()
+	col := collector.NewCollector(store, &collector.Config{Workers: 1, ChannelBuffer: 10}, slog.Default())
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/traces/", collector.CompressionMiddleware(col.HandleGetTrace))
+	mux.HandleFunc("/api/v1/traces", collector.CompressionMiddleware(col.HandleFindTraces))
+	mux.HandleFunc("/api/v1/services", col.HandleGetServices)
+	mux.HandleFunc("/healthz", col.HandleHealthz)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func writeSpan(t *testing.T, store storage.Store, traceID, spanID, parentID, service string, start time.Time, duration time.Duration) {
+	t.Helper()
+	err := store.WriteSpan(context.Background(), &models.Span{
+		TraceID:       traceID,
+		SpanID:        spanID,
+		ParentSpanID:  parentID,
+		ServiceName:   service,
+		OperationName: "op",
+		StartTime:     start,
+		Duration:      duration,
+		Status:        "ok",
+	})
+	if err != nil {
+		t.Fatalf("WriteSpan() error = %v", err)
+	}
+}
+
+// newTestGateway returns a gateway over two regions: us-east holds trace A
+// and the root of trace C, eu-west trace B and C's child span, plus a copy
+// of C's root.
+func newTestGateway(t *testing.T, base time.Time) (*Store, []*httptest.Server) {
+	t.Helper()
+	east, west := storage.NewMemoryStore(100), storage.NewMemoryStore(100)
+
+	writeSpan(t, east, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "a000000000000001", "", "checkout", base, 30*time.Millisecond)
+	writeSpan(t, west, "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "b000000000000001", "", "search", base.Add(time.Second), 10*time.Millisecond)
+
+	cross := "cccccccccccccccccccccccccccccccc"
+	writeSpan(t, east, cross, "c000000000000001", "", "frontend", base.Add(2*time.Second), 100*time.Millisecond)
+	writeSpan(t, west, cross, "c000000000000001", "", "frontend", base.Add(2*time.Second), 100*time.Millisecond)
+	writeSpan(t, west, cross, "c000000000000002", "c000000000000001", "inventory", base.Add(2*time.Second+50*time.Millisecond), 200*time.Millisecond)
+
+	servers := []*httptest.Server{newRegion(t, east), newRegion(t, west)}
+	gw, err := New(Config{Regions: []Region{
+		{Name: "us-east", Endpoint: servers[0].URL},
+		{Name: "eu-west", Endpoint: servers[1].URL + "/"},
+	}}, slog.Default())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { gw.Close() })
+	return gw, servers
+}
+
+func TestStore_GetTrace_MergesRegions(t *testing.T) {
+	base := time.Now().Add(-time.Minute).UTC()
+	gw, _ := newTestGateway(t, base)
+	ctx := context.Background()
+
+	trace, err := gw.GetTrace(ctx, "cccccccccccccccccccccccccccccccc")
+	if err != nil {
+		t.Fatalf("GetTrace() error = %v", err)
+	}
+	if trace == nil {
+		t.Fatal("GetTrace() = nil")
+	}
+	if len(trace.Spans) != 2 {
+		t.Fatalf("spans = %d, want 2 (duplicate root dropped)", len(trace.Spans))
+	}
+	if !slices.Equal(trace.Regions, []string{"eu-west", "us-east"}) {
+		t.Errorf("Regions = %v", trace.Regions)
+	}
+	if !slices.Equal(trace.Services, []string{"frontend", "inventory"}) {
+		t.Errorf("Services = %v", trace.Services)
+	}
+	if want := 250 * time.Millisecond; trace.Duration != want {
+		t.Errorf("Duration = %v, want %v", trace.Duration, want)
+	}
+	for _, span := range trace.Spans {
+		if span.ServiceName == "inventory" && span.GetTag(RegionTag) != "eu-west" {
+			t.Errorf("inventory span region = %q, want eu-west", span.GetTag(RegionTag))
+		}
+	}
+
+	trace, err = gw.GetTrace(ctx, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+	if err != nil || trace == nil {
+		t.Fatalf("GetTrace() = %v, %v", trace, err)
+	}
+	if !slices.Equal(trace.Regions, []string{"us-east"}) {
+		t.Errorf("Regions = %v, want [us-east]", trace.Regions)
+	}
+
+	trace, err = gw.GetTrace(ctx, "dddddddddddddddddddddddddddddddd")
+	if err != nil || trace != nil {
+		t.Errorf("GetTrace(unknown) = %v, %v, want nil, nil", trace, err)
+	}
+}
+
+func TestStore_FindTraces(t *testing.T) {
+	base := time.Now().Add(-time.Minute).UTC()
+	gw, _ := newTestGateway(t, base)
+	ctx := context.Background()
+
+	query := storage.NewQuery().WithSort(storage.SortByStartTime, storage.SortAsc)
+	traces, err := gw.FindTraces(ctx, query)
+	if err != nil {
+		t.Fatalf("FindTraces() error = %v", err)
+	}
+	var ids []string
+	for _, trace := range traces {
+		ids = append(ids, trace.TraceID[:1])
+	}
+	if !slices.Equal(ids, []string{"a", "b", "c"}) {
+		t.Fatalf("traces = %v, want a, b, c once each", ids)
+	}
+	if len(traces[2].Spans) != 2 || len(traces[2].Regions) != 2 {
+		t.Errorf("cross-region trace: %d spans, regions %v", len(traces[2].Spans), traces[2].Regions)
+	}
+
+	// Pages are cut from the merged order
+	page, err := gw.FindTraces(ctx, storage.NewQuery().WithSort(storage.SortByDuration, storage.SortDesc).WithPagination(1, 1))
+	if err != nil {
+		t.Fatalf("FindTraces(page) error = %v", err)
+	}
+	if len(page) != 1 || page[0].TraceID[:1] != "a" {
+		t.Errorf("second slowest = %v, want trace a", page)
+	}
+
+	// Filters are sent to the regions
+	traces, err = gw.FindTraces(ctx, storage.NewQuery().WithService("search"))
+	if err != nil || len(traces) != 1 || traces[0].TraceID[:1] != "b" {
+		t.Errorf("FindTraces(service=search) = %v, %v", traces, err)
+	}
+	traces, err = gw.FindTraces(ctx, storage.NewQuery().WithTimeRange(base.Add(500*time.Millisecond), time.Time{}))
+	if err != nil || len(traces) != 2 {
+		t.Errorf("FindTraces(start_time) = %d traces, %v; want 2", len(traces), err)
+	}
+
+	// Metadata projection strips tags after labelling
+	traces, err = gw.FindTraces(ctx, storage.NewQuery().WithProjection(storage.ProjectMetadata))
+	if err != nil || len(traces) != 3 {
+		t.Fatalf("FindTraces(metadata) = %d traces, %v", len(traces), err)
+	}
+	if traces[0].Spans[0].Tags != nil || len(traces[0].Regions) == 0 {
+		t.Errorf("metadata trace: tags %v, regions %v", traces[0].Spans[0].Tags, traces[0].Regions)
+	}
+
+	count, err := gw.CountTraces(ctx, storage.NewQuery())
+	if err != nil || count != 4 {
+		t.Errorf("CountTraces() = %d, %v; want 4 (cross-region trace counted per region)", count, err)
+	}
+
+	services, err := gw.GetServices(ctx)
+	if err != nil || !slices.Equal(services, []string{"checkout", "frontend", "inventory", "search"}) {
+		t.Errorf("GetServices() = %v, %v", services, err)
+	}
+}
+
+func TestStore_RegionDown(t *testing.T) {
+	base := time.Now().Add(-time.Minute).UTC()
+	gw, servers := newTestGateway(t, base)
+	servers[1].Close()
+	ctx := context.Background()
+
+	// The other region still answers
+	traces, err := gw.FindTraces(ctx, storage.NewQuery())
+	if err != nil {
+		t.Fatalf("FindTraces() error = %v", err)
+	}
+	if len(traces) != 2 {
+		t.Errorf("traces = %d, want us-east's 2", len(traces))
+	}
+	if err := gw.Ping(ctx); err != nil {
+		t.Errorf("Ping() error = %v, want nil while one region is up", err)
+	}
+
+	statuses := gw.Statuses()
+	if !statuses[0].Up || statuses[1].Up || statuses[1].Failures == 0 || statuses[1].LastError == "" {
+		t.Errorf("statuses = %+v", statuses)
+	}
+
+	// Unless every region must answer
+	gw.requireAll = true
+	if _, err := gw.FindTraces(ctx, storage.NewQuery()); err == nil {
+		t.Error("FindTraces() with require_all and a region down: want error")
+	}
+
+	// Or none does
+	gw.requireAll = false
+	servers[0].Close()
+	if _, err := gw.GetTrace(ctx, "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"); err == nil {
+		t.Error("GetTrace() with every region down: want error")
+	}
+}
+
+func TestStore_ReadOnly(t *testing.T) {
+	gw, err := New(Config{Regions: []Region{{Name: "r", Endpoint: "http://localhost:1"}}}, slog.Default())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err := gw.WriteSpan(context.Background(), &models.Span{}); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("WriteSpan() error = %v, want ErrReadOnly", err)
+	}
+	if _, err := gw.GetServiceStats(context.Background(), &storage.StatsQuery{}); !errors.Is(err, ErrStatsUnsupported) {
+		t.Errorf("GetServiceStats() error = %v, want ErrStatsUnsupported", err)
+	}
+}
+
+func TestNew_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"no regions", Config{}},
+		{"no name", Config{Regions: []Region{{Endpoint: "http://a"}}}},
+		{"bad endpoint", Config{Regions: []Region{{Name: "a", Endpoint: "a:9090"}}}},
+		{"duplicate", Config{Regions: []Region{{Name: "a", Endpoint: "http://a"}, {Name: "a", Endpoint: "http://b"}}}},
+		{"bad timeout", Config{Timeout: "soon", Regions: []Region{{Name: "a", Endpoint: "http://a"}}}},
+		{"bad region timeout", Config{Regions: []Region{{Name: "a", Endpoint: "http://a", Timeout: "-1s"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.config, slog.Default()); err == nil {
+				t.Error("New() error = nil")
+			}
+		})
+	}
+}
+
+func TestHandleRegions(t *testing.T) {
+	base := time.Now().Add(-time.Minute).UTC()
+	gw, _ := newTestGateway(t, base)
+	if _, err := gw.GetServices(context.Background()); err != nil {
+		t.Fatalf("GetServices() error = %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	gw.HandleRegions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/gateway/regions", nil))
+	var body struct {
+		Regions []RegionStatus `json:"regions"`
+		Total   int            `json:"total"`
+		Up      int            `json:"up"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if body.Total != 2 || body.Up != 2 || body.Regions[0].Name != "us-east" || body.Regions[0].Requests != 1 {
+		t.Errorf("body = %+v", body)
+	}
+
+	// Without a gateway the list is empty
+	var none *Store
+	rec = httptest.NewRecorder()
+	none.HandleRegions(rec, httptest.NewRequest(http.MethodGet, "/api/v1/gateway/regions", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("nil store status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,29 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HandleRegions handles GET /api/v1/gateway/regions - the regional
+// collectors a gateway queries and how their requests are going. Without a
+// gateway the list is empty.
+func (s *Store) HandleRegions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	statuses := s.Statuses()
+	up := 0
+	for _, status := range statuses {
+		if status.Up {
+			up++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"regions": statuses,
+		"total":   len(statuses),
+		"up":      up,
+	})
+}
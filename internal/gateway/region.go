@@ -0,0 +1,218 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// maxResponseBytes caps how much of a regional response is read, so one
+// misbehaving region cannot exhaust the gateway's memory.
+const maxResponseBytes = 256 << 20
+
+// maxErrorBody caps the part of an error response quoted in the error.
+const maxErrorBody = 512
+
+// RegionStatus is the state of one region, served at /api/v1/gateway/regions.
+type RegionStatus struct {
+	Name     string `json:"name"`
+	Endpoint string `json:"endpoint"`
+
+	Up           bool          `json:"up"` // The last request succeeded
+	Requests     int64         `json:"requests"`
+	Failures     int64         `json:"failures"`
+	LastRequest  *time.Time    `json:"last_request,omitempty"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	LastErrorAt  *time.Time    `json:"last_error_at,omitempty"`
+}
+
+// region is a validated Region with its state.
+type region struct {
+	name     string
+	endpoint string // Without a trailing slash
+	headers  map[string]string
+	timeout  time.Duration
+	client   *http.Client
+
+	mu     sync.Mutex
+	status RegionStatus
+}
+
+// get fetches path from the region and decodes the JSON response into out.
+// It reports found=false, without an error, for 404 Not Found.
+func (r *region) get(ctx context.Context, path string, params url.Values, out interface{}) (found bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() { r.record(start, err) }()
+
+	target := r.endpoint + path
+	if len(params) > 0 {
+		target += "?" + params.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range r.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return false, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if out == nil {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, maxErrorBody))
+		return true, nil
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(out); err != nil {
+		return false, fmt.Errorf("invalid response: %w", err)
+	}
+	return true, nil
+}
+
+// record updates the region's status after a request.
+func (r *region) record(start time.Time, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.status.Requests++
+	r.status.LastRequest = &start
+	r.status.LastDuration = time.Since(start)
+	r.status.Up = err == nil
+	if err != nil {
+		r.status.Failures++
+		r.status.LastError = err.Error()
+		r.status.LastErrorAt = &start
+	}
+}
+
+// fetch is get for paths every collector serves, so 404 is an error.
+func (r *region) fetch(ctx context.Context, path string, params url.Values, out interface{}) error {
+	found, err := r.get(ctx, path, params, out)
+	if err == nil && !found {
+		err = fmt.Errorf("%s: 404 Not Found", path)
+	}
+	return err
+}
+
+// getTrace reads a trace from the region, or nil if it has none.
+func (r *region) getTrace(ctx context.Context, traceID string) (*models.Trace, error) {
+	var trace models.Trace
+	found, err := r.get(ctx, "/api/v1/traces/"+url.PathEscape(traceID), nil, &trace)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &trace, nil
+}
+
+// findTracesResponse is the part of a GET /api/v1/traces response the
+// gateway reads.
+type findTracesResponse struct {
+	Traces []*models.Trace `json:"traces"`
+	Total  int             `json:"total"`
+}
+
+// findTraces runs a search on the region.
+func (r *region) findTraces(ctx context.Context, params url.Values) (*findTracesResponse, error) {
+	var resp findTracesResponse
+	err := r.fetch(ctx, "/api/v1/traces", params, &resp)
+	return &resp, err
+}
+
+// services lists the region's services.
+func (r *region) services(ctx context.Context) ([]string, error) {
+	var resp struct {
+		Services []string `json:"services"`
+	}
+	err := r.fetch(ctx, "/api/v1/services", nil, &resp)
+	return resp.Services, err
+}
+
+// ping checks that the region's collector is alive.
+func (r *region) ping(ctx context.Context) error {
+	return r.fetch(ctx, "/healthz", nil, nil)
+}
+
+// queryParams encodes query as GET /api/v1/traces parameters, returning up
+// to limit results from the start.
+func queryParams(query *storage.Query, limit int) url.Values {
+	params := url.Values{}
+	if query.Service != "" {
+		params.Set("service", query.Service)
+	}
+	setDuration := func(name string, d time.Duration) {
+		if d > 0 {
+			params.Set(name, d.String())
+		}
+	}
+	setDuration("min_duration", query.MinDuration)
+	setDuration("max_duration", query.MaxDuration)
+	setDuration("min_ttl", query.MinTTL)
+	setFloat := func(name string, f float64) {
+		if f != 0 {
+			params.Set(name, strconv.FormatFloat(f, 'g', -1, 64))
+		}
+	}
+	setFloat("min_cost", query.MinCost)
+	setFloat("max_cost", query.MaxCost)
+	if !query.StartTime.IsZero() {
+		params.Set("start_time", query.StartTime.UTC().Format(time.RFC3339Nano))
+	}
+	if !query.EndTime.IsZero() {
+		params.Set("end_time", query.EndTime.UTC().Format(time.RFC3339Nano))
+	}
+	if query.ErrorsOnly {
+		params.Set("errors_only", "true")
+	}
+	if query.InProgress != nil {
+		params.Set("in_progress", strconv.FormatBool(*query.InProgress))
+	}
+
+	tags := make([]string, 0, len(query.Tags))
+	for key, value := range query.Tags {
+		if value == "" {
+			tags = append(tags, key)
+		} else {
+			tags = append(tags, key+":"+value)
+		}
+	}
+	sort.Strings(tags)
+	for _, tag := range tags {
+		params.Add("tag", tag)
+	}
+
+	if query.SortBy != "" {
+		params.Set("sort", query.SortBy)
+	}
+	if query.SortOrder != "" {
+		params.Set("order", query.SortOrder)
+	}
+	params.Set("limit", strconv.Itoa(limit))
+	return params
+}
@@ -0,0 +1,164 @@
+// Package histogram provides the bucketed histogram shared by trace indexes
+// and metrics: the same bounds place a trace in an index bucket, count it for
+// Prometheus and estimate percentiles.
+package histogram
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Exponential returns count bucket upper bounds starting at start and
+// growing by factor, e.g. Exponential(0.001, 2, 4) is 1ms, 2ms, 4ms, 8ms in
+// seconds. Relative error of percentile estimates is bounded by the factor.
+func Exponential(start, factor float64, count int) ([]float64, error) {
+	if start <= 0 {
+		return nil, fmt.Errorf("start %g must be positive", start)
+	}
+	if factor <= 1 {
+		return nil, fmt.Errorf("factor %g must be greater than 1", factor)
+	}
+	if count < 1 {
+		return nil, fmt.Errorf("count %d must be at least 1", count)
+	}
+	bounds := make([]float64, count)
+	for i := range bounds {
+		bounds[i] = start * math.Pow(factor, float64(i))
+	}
+	return bounds, nil
+}
+
+// ValidateBounds checks that bounds are positive and ascending.
+func ValidateBounds(bounds []float64) error {
+	if len(bounds) == 0 {
+		return errors.New("at least one bucket bound is required")
+	}
+	for i, bound := range bounds {
+		if bound <= 0 {
+			return fmt.Errorf("bucket bound %g must be positive", bound)
+		}
+		if i > 0 && bound <= bounds[i-1] {
+			return fmt.Errorf("bucket bounds must be ascending: %g after %g", bound, bounds[i-1])
+		}
+	}
+	return nil
+}
+
+// Histogram is a fixed-bucket histogram. Bucket i counts values below
+// bounds[i] (and at least bounds[i-1]); the last bucket counts the rest.
+// It is not safe for concurrent use; callers hold their own lock.
+type Histogram struct {
+	bounds []float64
+	counts []uint64 // Per bucket, non-cumulative; last entry is +Inf
+	sum    float64
+	count  uint64
+}
+
+// New returns an empty histogram. bounds must be ascending and are not copied.
+func New(bounds []float64) *Histogram {
+	return &Histogram{bounds: bounds, counts: make([]uint64, len(bounds)+1)}
+}
+
+// Bucket returns the index of the bucket v falls in.
+func (h *Histogram) Bucket(v float64) int {
+	return sort.Search(len(h.bounds), func(i int) bool {
+		return v < h.bounds[i]
+	})
+}
+
+// Observe records a value.
+func (h *Histogram) Observe(v float64) {
+	h.counts[h.Bucket(v)]++
+	h.sum += v
+	h.count++
+}
+
+// Remove takes back a value recorded with Observe.
+func (h *Histogram) Remove(v float64) {
+	if i := h.Bucket(v); h.counts[i] > 0 {
+		h.counts[i]--
+		h.sum -= v
+		h.count--
+	}
+}
+
+// Merge adds other's observations; both must share the same bounds.
+func (h *Histogram) Merge(other *Histogram) {
+	for i, n := range other.counts {
+		h.counts[i] += n
+	}
+	h.sum += other.sum
+	h.count += other.count
+}
+
+// Bounds returns the bucket upper bounds; the caller must not modify them.
+func (h *Histogram) Bounds() []float64 { return h.bounds }
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 { return h.count }
+
+// Sum returns the sum of observations.
+func (h *Histogram) Sum() float64 { return h.sum }
+
+// Cumulative returns the cumulative count of each bucket; the last entry,
+// for +Inf, equals Count.
+func (h *Histogram) Cumulative() []uint64 {
+	cumulative := make([]uint64, len(h.counts))
+	var total uint64
+	for i, n := range h.counts {
+		total += n
+		cumulative[i] = total
+	}
+	return cumulative
+}
+
+// Quantile estimates the q-quantile (0 <= q <= 1) by interpolating linearly
+// within the bucket holding it, the way Prometheus' histogram_quantile does.
+// Values in the overflow bucket are reported as the last bound. It returns 0
+// for an empty histogram.
+func (h *Histogram) Quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+	q = math.Max(0, math.Min(1, q))
+	rank := q * float64(h.count)
+
+	var below uint64
+	for i, n := range h.counts {
+		if n == 0 || float64(below+n) < rank {
+			below += n
+			continue
+		}
+		if i == len(h.bounds) {
+			return h.bounds[len(h.bounds)-1]
+		}
+		lower := 0.0
+		if i > 0 {
+			lower = h.bounds[i-1]
+		}
+		return lower + (h.bounds[i]-lower)*(rank-float64(below))/float64(n)
+	}
+	return h.bounds[len(h.bounds)-1]
+}
+
+// Buckets returns the cumulative count of each bucket keyed by upper bound,
+// the form Prometheus' const histograms take; +Inf is implied by Count.
+func (h *Histogram) Buckets() map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(h.bounds))
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		buckets[bound] = cumulative
+	}
+	return buckets
+}
+
+// ObserveBucket records count values totalling sum in bucket i, for
+// histograms aggregated elsewhere (e.g. by a database) and rebuilt here.
+func (h *Histogram) ObserveBucket(i int, count uint64, sum float64) {
+	h.counts[i] += count
+	h.sum += sum
+	h.count += count
+}
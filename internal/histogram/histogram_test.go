@@ -0,0 +1,98 @@
+package histogram
+
+import (
+	"math"
+	"testing"
+)
+
+func TestExponential(t *testing.T) {
+	bounds, err := Exponential(0.001, 2, 4)
+	if err != nil {
+		t.Fatalf("Exponential failed: %v", err)
+	}
+	want := []float64{0.001, 0.002, 0.004, 0.008}
+	for i := range want {
+		if math.Abs(bounds[i]-want[i]) > 1e-12 {
+			t.Errorf("bound %d = %g, want %g", i, bounds[i], want[i])
+		}
+	}
+
+	for _, args := range []struct {
+		start, factor float64
+		count         int
+	}{{0, 2, 4}, {1, 1, 4}, {1, 2, 0}} {
+		if _, err := Exponential(args.start, args.factor, args.count); err == nil {
+			t.Errorf("Exponential(%v): expected an error", args)
+		}
+	}
+}
+
+func TestValidateBounds(t *testing.T) {
+	if err := ValidateBounds([]float64{1, 2, 3}); err != nil {
+		t.Errorf("valid bounds rejected: %v", err)
+	}
+	for _, bounds := range [][]float64{nil, {0}, {-1, 1}, {2, 1}, {1, 1}} {
+		if err := ValidateBounds(bounds); err == nil {
+			t.Errorf("ValidateBounds(%v): expected an error", bounds)
+		}
+	}
+}
+
+func TestHistogram_ObserveRemoveMerge(t *testing.T) {
+	h := New([]float64{1, 10, 100})
+	for _, v := range []float64{0.5, 5, 5, 50, 500} {
+		h.Observe(v)
+	}
+	if got := h.Cumulative(); got[0] != 1 || got[1] != 3 || got[2] != 4 || got[3] != 5 {
+		t.Errorf("Cumulative() = %v", got)
+	}
+
+	h.Remove(5)
+	if h.Count() != 4 || h.Sum() != 555.5 {
+		t.Errorf("after Remove: count=%d sum=%g", h.Count(), h.Sum())
+	}
+
+	other := New([]float64{1, 10, 100})
+	other.Observe(2)
+	h.Merge(other)
+	if h.Count() != 5 || h.Cumulative()[1] != 3 {
+		t.Errorf("after Merge: count=%d cumulative=%v", h.Count(), h.Cumulative())
+	}
+}
+
+func TestHistogram_Quantile(t *testing.T) {
+	h := New([]float64{10, 20, 40})
+	if h.Quantile(0.5) != 0 {
+		t.Error("empty histogram should report 0")
+	}
+
+	// 10 values in [10, 20): the median interpolates to the middle
+	for i := 0; i < 10; i++ {
+		h.Observe(15)
+	}
+	if got := h.Quantile(0.5); got != 15 {
+		t.Errorf("p50 = %g, want 15", got)
+	}
+
+	// Overflow values report the last bound
+	for i := 0; i < 90; i++ {
+		h.Observe(1000)
+	}
+	if got := h.Quantile(0.99); got != 40 {
+		t.Errorf("p99 = %g, want 40", got)
+	}
+	if got := h.Quantile(0.05); got != 15 {
+		t.Errorf("p05 = %g, want 15", got)
+	}
+}
+
+func TestHistogram_Buckets(t *testing.T) {
+	h := New([]float64{0.1, 1})
+	h.Observe(0.05)
+	h.Observe(2)
+
+	buckets := h.Buckets()
+	if len(buckets) != 2 || buckets[0.1] != 1 || buckets[1] != 1 {
+		t.Errorf("Buckets() = %v, want map[0.1:1 1:1]", buckets)
+	}
+}
@@ -0,0 +1,22 @@
+// Package pattern compiles the '*' wildcard patterns that processor, cost
+// and assertion rules use to match services, operations and tag values.
+package pattern
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Compile turns a pattern with '*' wildcards into an anchored regexp, or nil
+// for an empty pattern, which matches anything. Everything other than '*' is
+// matched literally.
+func Compile(pattern string) *regexp.Regexp {
+	if pattern == "" {
+		return nil
+	}
+	parts := strings.Split(pattern, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
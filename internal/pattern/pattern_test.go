@@ -0,0 +1,31 @@
+package pattern
+
+import "testing"
+
+func TestCompile(t *testing.T) {
+	if Compile("") != nil {
+		t.Error(`Compile("") should be nil`)
+	}
+
+	tests := []struct {
+		pattern string
+		input   string
+		want    bool
+	}{
+		{"GET /health", "GET /health", true},
+		{"GET /health", "GET /healthz", false},
+		{"GET /*", "GET /users/42", true},
+		{"GET /*", "POST /users", false},
+		{"*-worker", "billing-worker", true},
+		{"*-worker", "billing-worker-2", false},
+		{"db.*.query", "db.users.query", true},
+		{"*", "", true},
+		{"a.b", "axb", false}, // Only '*' is special
+		{"(x)+", "(x)+", true},
+	}
+	for _, tt := range tests {
+		if got := Compile(tt.pattern).MatchString(tt.input); got != tt.want {
+			t.Errorf("Compile(%q).MatchString(%q) = %v, want %v", tt.pattern, tt.input, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+// Package dropfilter provides the "drop_filter" processor, which discards
+// noise such as health checks and Kubernetes probes before they reach
+// storage. Import it for its side effect of registering the processor:
+//
+//	import _ "github.com/saintparish4/asmbly/internal/plugin/dropfilter"
+package dropfilter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/saintparish4/asmbly/internal/pattern"
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Name is the registered processor name.
+const Name = "drop_filter"
+
+func init() {
+	plugin.RegisterProcessor(Name, func(config json.RawMessage) (plugin.Processor, error) {
+		var cfg Config
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid config: %w", err)
+			}
+		}
+		return New(cfg)
+	})
+}
+
+// Rule matches spans to drop. Every non-empty field must match; patterns
+// may use '*' to match any run of characters. Tag names a tag key and Value
+// is matched against its value (a missing tag never matches).
+type Rule struct {
+	Service   string `json:"service,omitempty"`
+	Operation string `json:"operation,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Value     string `json:"value,omitempty"`
+}
+
+// Config is the processor's JSON config. With no rules, DefaultRules apply.
+type Config struct {
+	Rules []Rule `json:"rules,omitempty"`
+}
+
+// DefaultRules drop common health check endpoints and Kubernetes probes.
+var DefaultRules = []Rule{
+	{Operation: "GET /health*"},
+	{Operation: "GET /livez"},
+	{Operation: "GET /readyz"},
+	{Tag: "http.user_agent", Value: "kube-probe/*"},
+	{Tag: "user_agent.original", Value: "kube-probe/*"},
+}
+
+// Processor drops spans matching any of its rules.
+type Processor struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	service, operation *regexp.Regexp
+	tag                string
+	value              *regexp.Regexp
+}
+
+// New builds a processor from config.
+func New(config Config) (*Processor, error) {
+	rules := config.Rules
+	if len(rules) == 0 {
+		rules = DefaultRules
+	}
+
+	p := &Processor{}
+	for i, rule := range rules {
+		if rule.Service == "" && rule.Operation == "" && rule.Tag == "" {
+			return nil, fmt.Errorf("rule %d: set at least one of service, operation or tag", i)
+		}
+		if rule.Value != "" && rule.Tag == "" {
+			return nil, fmt.Errorf("rule %d: value requires a tag", i)
+		}
+		p.rules = append(p.rules, compiledRule{
+			service:   pattern.Compile(rule.Service),
+			operation: pattern.Compile(rule.Operation),
+			tag:       rule.Tag,
+			value:     pattern.Compile(rule.Value),
+		})
+	}
+	return p, nil
+}
+
+// Name returns the registered plugin name.
+func (p *Processor) Name() string { return Name }
+
+// Process drops the span (returns nil) if any rule matches.
+func (p *Processor) Process(ctx context.Context, span *models.Span) (*models.Span, error) {
+	for _, rule := range p.rules {
+		if rule.matches(span) {
+			return nil, nil
+		}
+	}
+	return span, nil
+}
+
+func (r *compiledRule) matches(span *models.Span) bool {
+	if r.service != nil && !r.service.MatchString(span.ServiceName) {
+		return false
+	}
+	if r.operation != nil && !r.operation.MatchString(span.OperationName) {
+		return false
+	}
+	if r.tag != "" {
+		value, ok := span.Tags[r.tag]
+		if !ok {
+			return false
+		}
+		if r.value != nil && !r.value.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
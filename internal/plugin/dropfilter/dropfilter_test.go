@@ -0,0 +1,68 @@
+package dropfilter
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestProcessor_DefaultRules(t *testing.T) {
+	p, err := New(Config{})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		span *models.Span
+		drop bool
+	}{
+		{"health check", &models.Span{OperationName: "GET /healthz"}, true},
+		{"readiness", &models.Span{OperationName: "GET /readyz"}, true},
+		{"kube probe", &models.Span{OperationName: "GET /", Tags: map[string]string{"http.user_agent": "kube-probe/1.29"}}, true},
+		{"browser", &models.Span{OperationName: "GET /", Tags: map[string]string{"http.user_agent": "Mozilla/5.0"}}, false},
+		{"checkout", &models.Span{OperationName: "POST /checkout"}, false},
+	}
+	for _, tt := range tests {
+		got, err := p.Process(context.Background(), tt.span)
+		if err != nil {
+			t.Fatalf("%s: Process() error = %v", tt.name, err)
+		}
+		if dropped := got == nil; dropped != tt.drop {
+			t.Errorf("%s: dropped = %v, want %v", tt.name, dropped, tt.drop)
+		}
+	}
+}
+
+func TestProcessor_ConfiguredRulesMatchAllFields(t *testing.T) {
+	config := plugin.Config{Processors: []plugin.Spec{{
+		Name:   Name,
+		Config: json.RawMessage(`{"rules": [{"service": "api", "operation": "GET /ping"}]}`),
+	}}}
+	procs, err := config.BuildProcessors()
+	if err != nil {
+		t.Fatalf("BuildProcessors() error = %v", err)
+	}
+
+	ping := &models.Span{ServiceName: "api", OperationName: "GET /ping"}
+	if got, _ := procs[0].Process(context.Background(), ping); got != nil {
+		t.Error("matching span should be dropped")
+	}
+	other := &models.Span{ServiceName: "web", OperationName: "GET /ping"}
+	if got, _ := procs[0].Process(context.Background(), other); got == nil {
+		t.Error("span from another service should be kept")
+	}
+	health := &models.Span{ServiceName: "api", OperationName: "GET /healthz"}
+	if got, _ := procs[0].Process(context.Background(), health); got == nil {
+		t.Error("configured rules replace the defaults")
+	}
+}
+
+func TestNew_RejectsEmptyRule(t *testing.T) {
+	if _, err := New(Config{Rules: []Rule{{}}}); err == nil {
+		t.Error("expected error for a rule that matches everything")
+	}
+}
@@ -0,0 +1,425 @@
+// Package otlpexport provides the "otlp" exporter, which forwards stored
+// spans to an upstream OTLP/HTTP endpoint. Dual-writing lets the collector
+// run alongside an existing observability vendor during an evaluation.
+// Import it for its side effect of registering the exporter:
+//
+//	import _ "github.com/saintparish4/asmbly/internal/plugin/otlpexport"
+package otlpexport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Name is the registered exporter name.
+const Name = "otlp"
+
+func init() {
+	plugin.RegisterExporter(Name, func(config json.RawMessage) (plugin.Exporter, error) {
+		var cfg Config
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid config: %w", err)
+			}
+		}
+		return New(cfg)
+	})
+}
+
+// Config is the exporter's JSON config. Only Endpoint is required.
+type Config struct {
+	// Endpoint is the full OTLP/HTTP traces URL, e.g.
+	// "https://otlp.example.com/v1/traces"
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers,omitempty"` // e.g. the vendor's API key header
+
+	BatchSize     int    `json:"batch_size,omitempty"`     // Max spans per request (default 512)
+	QueueSize     int    `json:"queue_size,omitempty"`     // Buffered spans before new ones are dropped (default 4096)
+	FlushInterval string `json:"flush_interval,omitempty"` // Max time a partial batch waits (default 5s)
+	Timeout       string `json:"timeout,omitempty"`        // Per-request timeout (default 10s)
+	MaxRetries    int    `json:"max_retries,omitempty"`    // Retries of a retryable failure (default 3)
+	Compression   string `json:"compression,omitempty"`    // "gzip" (default) or "none"
+}
+
+// Defaults for unset Config fields.
+const (
+	DefaultBatchSize     = 512
+	DefaultQueueSize     = 4096
+	DefaultFlushInterval = 5 * time.Second
+	DefaultTimeout       = 10 * time.Second
+	DefaultMaxRetries    = 3
+)
+
+// retryBackoff is the delay before the first retry, doubled on each retry.
+var retryBackoff = 500 * time.Millisecond
+
+// Exporter batches spans in the background and posts them as protobuf
+// ExportTraceServiceRequests. Export never blocks the collector: spans that
+// do not fit the queue are dropped and counted.
+type Exporter struct {
+	endpoint      string
+	headers       map[string]string
+	gzip          bool
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	client        *http.Client
+	logger        *slog.Logger
+
+	queue    chan *models.Span
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	exported atomic.Int64
+	dropped  atomic.Int64
+}
+
+// New validates config and starts the export loop.
+func New(config Config) (*Exporter, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("endpoint must be an http or https URL")
+	}
+
+	e := &Exporter{
+		endpoint:      config.Endpoint,
+		headers:       config.Headers,
+		batchSize:     config.BatchSize,
+		flushInterval: DefaultFlushInterval,
+		maxRetries:    config.MaxRetries,
+		logger:        slog.Default().With("exporter", Name),
+		done:          make(chan struct{}),
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = DefaultBatchSize
+	}
+	if e.maxRetries <= 0 {
+		e.maxRetries = DefaultMaxRetries
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	e.queue = make(chan *models.Span, queueSize)
+
+	if config.FlushInterval != "" {
+		if e.flushInterval, err = time.ParseDuration(config.FlushInterval); err != nil || e.flushInterval <= 0 {
+			return nil, fmt.Errorf("invalid flush_interval %q", config.FlushInterval)
+		}
+	}
+	timeout := DefaultTimeout
+	if config.Timeout != "" {
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", config.Timeout)
+		}
+	}
+	e.client = &http.Client{Timeout: timeout}
+
+	switch config.Compression {
+	case "", "gzip":
+		e.gzip = true
+	case "none":
+	default:
+		return nil, fmt.Errorf("compression must be gzip or none")
+	}
+
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+// Name returns the registered plugin name.
+func (e *Exporter) Name() string { return Name }
+
+// Export queues a completed span. In-progress snapshots are skipped so the
+// upstream only sees each span once.
+func (e *Exporter) Export(ctx context.Context, span *models.Span) error {
+	if span.InProgress {
+		return nil
+	}
+	select {
+	case <-e.done:
+		return errors.New("exporter stopped")
+	default:
+	}
+	select {
+	case e.queue <- span:
+		return nil
+	default:
+		e.dropped.Add(1)
+		return errors.New("export queue full, span dropped")
+	}
+}
+
+// Shutdown flushes queued spans, waiting until they are sent or ctx expires.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		e.logger.Info("otlp exporter stopped", "exported", e.exported.Load(), "dropped", e.dropped.Load())
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run batches queued spans until shutdown, then drains the queue.
+func (e *Exporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.Span, 0, e.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = make([]*models.Span, 0, e.batchSize)
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= e.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts one batch, retrying throttling and server errors with
+// exponential backoff. A batch that still fails is dropped.
+func (e *Exporter) send(batch []*models.Span) {
+	body, err := proto.Marshal(ToOTLP(batch))
+	if err != nil {
+		e.logger.Error("failed to encode otlp batch", "error", err)
+		e.dropped.Add(int64(len(batch)))
+		return
+	}
+	if e.gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(body)
+		zw.Close()
+		body = buf.Bytes()
+	}
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := e.post(body)
+		if err == nil {
+			e.exported.Add(int64(len(batch)))
+			return
+		}
+		if !retry || attempt >= e.maxRetries {
+			e.logger.Warn("otlp export failed", "spans", len(batch), "attempts", attempt+1, "error", err)
+			e.dropped.Add(int64(len(batch)))
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one request; retry reports whether a failure is transient.
+func (e *Exporter) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if e.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return true, fmt.Errorf("upstream returned %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("upstream returned %d", resp.StatusCode)
+	}
+}
+
+// ToOTLP converts spans into an export request with one resource per
+// service, deployment and environment. Tags become string attributes;
+// the "otel.scope.name" tag set on OTLP ingestion restores the scope.
+func ToOTLP(spans []*models.Span) *coltracepb.ExportTraceServiceRequest {
+	type resourceKey struct{ service, version, environment, gitSHA string }
+	type scopeKey struct {
+		resource resourceKey
+		scope    string
+	}
+
+	resources := make(map[resourceKey]*tracepb.ResourceSpans)
+	scopes := make(map[scopeKey]*tracepb.ScopeSpans)
+	var order []resourceKey
+
+	for _, span := range spans {
+		rk := resourceKey{span.ServiceName, span.DeploymentID, span.Environment, span.GitSHA}
+		rs, ok := resources[rk]
+		if !ok {
+			rs = &tracepb.ResourceSpans{Resource: &resourcepb.Resource{Attributes: resourceAttributes(rk.service, rk.version, rk.environment, rk.gitSHA)}}
+			resources[rk] = rs
+			order = append(order, rk)
+		}
+		sk := scopeKey{rk, span.Tags["otel.scope.name"]}
+		ss, ok := scopes[sk]
+		if !ok {
+			ss = &tracepb.ScopeSpans{Scope: &commonpb.InstrumentationScope{Name: sk.scope}}
+			scopes[sk] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, spanToOTLP(span))
+	}
+
+	req := &coltracepb.ExportTraceServiceRequest{}
+	for _, rk := range order {
+		req.ResourceSpans = append(req.ResourceSpans, resources[rk])
+	}
+	return req
+}
+
+func resourceAttributes(service, version, environment, gitSHA string) []*commonpb.KeyValue {
+	attrs := []*commonpb.KeyValue{stringAttribute("service.name", service)}
+	if version != "" {
+		attrs = append(attrs, stringAttribute("service.version", version))
+	}
+	if environment != "" {
+		attrs = append(attrs, stringAttribute("deployment.environment", environment))
+	}
+	if gitSHA != "" {
+		attrs = append(attrs, stringAttribute("vcs.revision", gitSHA))
+	}
+	return attrs
+}
+
+func spanToOTLP(span *models.Span) *tracepb.Span {
+	traceID, _ := hex.DecodeString(span.TraceID)
+	spanID, _ := hex.DecodeString(span.SpanID)
+	start := span.StartTime.UnixNano()
+
+	out := &tracepb.Span{
+		TraceId:           traceID,
+		SpanId:            spanID,
+		Name:              span.OperationName,
+		Kind:              spanKindToOTLP(span.SpanKind),
+		StartTimeUnixNano: uint64(start),
+		EndTimeUnixNano:   uint64(start + int64(span.Duration)),
+		Status:            &tracepb.Status{Code: tracepb.Status_STATUS_CODE_OK},
+	}
+	if span.ParentSpanID != "" {
+		out.ParentSpanId, _ = hex.DecodeString(span.ParentSpanID)
+	}
+	if span.IsError() {
+		out.Status = &tracepb.Status{Code: tracepb.Status_STATUS_CODE_ERROR, Message: span.StatusMessage}
+	}
+
+	keys := make([]string, 0, len(span.Tags))
+	for k := range span.Tags {
+		if k != "otel.scope.name" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		out.Attributes = append(out.Attributes, stringAttribute(k, span.Tags[k]))
+	}
+	if span.Cost != 0 {
+		out.Attributes = append(out.Attributes, &commonpb.KeyValue{
+			Key:   "traceflow.cost",
+			Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: span.Cost}},
+		})
+	}
+
+	for _, event := range span.Events {
+		e := &tracepb.Span_Event{Name: event.Name, TimeUnixNano: uint64(event.Timestamp.UnixNano())}
+		for k, v := range event.Attributes {
+			e.Attributes = append(e.Attributes, stringAttribute(k, v))
+		}
+		sort.Slice(e.Attributes, func(i, j int) bool { return e.Attributes[i].Key < e.Attributes[j].Key })
+		out.Events = append(out.Events, e)
+	}
+	return out
+}
+
+func spanKindToOTLP(kind string) tracepb.Span_SpanKind {
+	switch kind {
+	case "server":
+		return tracepb.Span_SPAN_KIND_SERVER
+	case "client":
+		return tracepb.Span_SPAN_KIND_CLIENT
+	case "producer":
+		return tracepb.Span_SPAN_KIND_PRODUCER
+	case "consumer":
+		return tracepb.Span_SPAN_KIND_CONSUMER
+	case "internal":
+		return tracepb.Span_SPAN_KIND_INTERNAL
+	default:
+		return tracepb.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
@@ -0,0 +1,173 @@
+package otlpexport
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// upstream is a fake OTLP/HTTP endpoint that fails the first failures
+// requests with 503.
+type upstream struct {
+	mu       sync.Mutex
+	requests []*coltracepb.ExportTraceServiceRequest
+	headers  []http.Header
+	failures int
+}
+
+func (u *upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.failures > 0 {
+		u.failures--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		body = zr
+	}
+	data, _ := io.ReadAll(body)
+	req := &coltracepb.ExportTraceServiceRequest{}
+	if err := proto.Unmarshal(data, req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	u.requests = append(u.requests, req)
+	u.headers = append(u.headers, r.Header)
+	w.WriteHeader(http.StatusOK)
+}
+
+func testSpan(service, status string) *models.Span {
+	return &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ParentSpanID:  models.GenerateSpanID(),
+		ServiceName:   service,
+		OperationName: "GET /users",
+		StartTime:     time.Unix(1700000000, 0),
+		Duration:      25 * time.Millisecond,
+		SpanKind:      "server",
+		Status:        status,
+		DeploymentID:  "v1.2.0",
+		Tags:          map[string]string{"http.method": "GET", "otel.scope.name": "net/http"},
+		Events:        []models.SpanEvent{{Name: "retry", Timestamp: time.Unix(1700000000, 0)}},
+	}
+}
+
+func TestToOTLP(t *testing.T) {
+	api, failed, db := testSpan("api", "ok"), testSpan("api", "error"), testSpan("db", "ok")
+	failed.StatusMessage = "timeout"
+	req := ToOTLP([]*models.Span{api, db, failed})
+
+	if len(req.ResourceSpans) != 2 {
+		t.Fatalf("resources = %d, want one per service", len(req.ResourceSpans))
+	}
+	resource := req.ResourceSpans[0]
+	attrs := make(map[string]string)
+	for _, kv := range resource.Resource.Attributes {
+		attrs[kv.Key] = kv.Value.GetStringValue()
+	}
+	if attrs["service.name"] != "api" || attrs["service.version"] != "v1.2.0" {
+		t.Errorf("resource attributes = %v", attrs)
+	}
+	scope := resource.ScopeSpans[0]
+	if scope.Scope.Name != "net/http" || len(scope.Spans) != 2 {
+		t.Fatalf("scope = %s with %d spans", scope.Scope.Name, len(scope.Spans))
+	}
+
+	span := scope.Spans[0]
+	if hex.EncodeToString(span.TraceId) != api.TraceID || hex.EncodeToString(span.ParentSpanId) != api.ParentSpanID {
+		t.Errorf("ids = %x/%x", span.TraceId, span.ParentSpanId)
+	}
+	if span.Kind != tracepb.Span_SPAN_KIND_SERVER || span.EndTimeUnixNano-span.StartTimeUnixNano != uint64(25*time.Millisecond) {
+		t.Errorf("kind %v, duration %d", span.Kind, span.EndTimeUnixNano-span.StartTimeUnixNano)
+	}
+	if len(span.Attributes) != 1 || span.Attributes[0].Key != "http.method" || len(span.Events) != 1 {
+		t.Errorf("attributes %v, events %v; scope tag should not be an attribute", span.Attributes, span.Events)
+	}
+	if status := scope.Spans[1].Status; status.Code != tracepb.Status_STATUS_CODE_ERROR || status.Message != "timeout" {
+		t.Errorf("error status = %v", status)
+	}
+}
+
+func TestExporter_BatchesRetriesAndFlushesOnShutdown(t *testing.T) {
+	retryBackoff = time.Millisecond
+	up := &upstream{failures: 1}
+	server := httptest.NewServer(up)
+	defer server.Close()
+
+	exp, err := New(Config{
+		Endpoint:      server.URL + "/v1/traces",
+		Headers:       map[string]string{"X-API-Key": "secret"},
+		BatchSize:     2,
+		FlushInterval: "1h", // Only full batches and shutdown flush
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx := context.Background()
+	inProgress := testSpan("api", "ok")
+	inProgress.InProgress = true
+	for _, span := range []*models.Span{testSpan("api", "ok"), inProgress, testSpan("api", "ok"), testSpan("db", "ok")} {
+		if err := exp.Export(ctx, span); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// The first full batch was retried after the 503, the partial one flushed at shutdown
+	if len(up.requests) != 2 {
+		t.Fatalf("requests = %d, want 2", len(up.requests))
+	}
+	if n := len(up.requests[0].ResourceSpans[0].ScopeSpans[0].Spans); n != 2 {
+		t.Errorf("first batch has %d spans, want 2 (in-progress skipped)", n)
+	}
+	if up.headers[0].Get("X-API-Key") != "secret" || up.headers[0].Get("Content-Type") != "application/x-protobuf" {
+		t.Errorf("headers = %v", up.headers[0])
+	}
+	if exp.exported.Load() != 3 || exp.dropped.Load() != 0 {
+		t.Errorf("exported %d, dropped %d", exp.exported.Load(), exp.dropped.Load())
+	}
+
+	if err := exp.Export(ctx, testSpan("api", "ok")); err == nil {
+		t.Error("Export after Shutdown succeeded")
+	}
+}
+
+func TestRegisteredFromConfig(t *testing.T) {
+	cfg := plugin.Config{Exporters: []plugin.Spec{{Name: Name, Config: json.RawMessage(`{"endpoint": "ftp://vendor"}`)}}}
+	if _, err := cfg.BuildExporters(); err == nil {
+		t.Error("expected error for a non-HTTP endpoint")
+	}
+
+	cfg.Exporters[0].Config = json.RawMessage(`{"endpoint": "https://otlp.example.com/v1/traces", "compression": "none"}`)
+	exporters, err := cfg.BuildExporters()
+	if err != nil || len(exporters) != 1 || exporters[0].Name() != Name {
+		t.Fatalf("BuildExporters() = %v, %v", exporters, err)
+	}
+	exporters[0].Shutdown(context.Background())
+}
@@ -0,0 +1,101 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+type tagProcessor struct {
+	key, value string
+}
+
+func (p *tagProcessor) Name() string { return "test_tag" }
+
+func (p *tagProcessor) Process(ctx context.Context, span *models.Span) (*models.Span, error) {
+	span.SetTag(p.key, p.value)
+	return span, nil
+}
+
+type nopExporter struct{}
+
+func (nopExporter) Name() string                                        { return "test_nop" }
+func (nopExporter) Export(ctx context.Context, span *models.Span) error { return nil }
+func (nopExporter) Shutdown(ctx context.Context) error                  { return nil }
+
+func init() {
+	RegisterProcessor("test_tag", func(config json.RawMessage) (Processor, error) {
+		var cfg struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(config, &cfg); err != nil {
+			return nil, err
+		}
+		return &tagProcessor{key: cfg.Key, value: cfg.Value}, nil
+	})
+	RegisterExporter("test_nop", func(config json.RawMessage) (Exporter, error) {
+		return nopExporter{}, nil
+	})
+}
+
+func TestConfig_BuildsEnabledPlugins(t *testing.T) {
+	data := `{
+		"processors": [
+			{"name": "test_tag", "config": {"key": "team", "value": "payments"}},
+			{"name": "test_tag", "enabled": false, "config": {"key": "ignored", "value": "x"}}
+		],
+		"exporters": [{"name": "test_nop"}]
+	}`
+	var config Config
+	if err := json.Unmarshal([]byte(data), &config); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	procs, err := config.BuildProcessors()
+	if err != nil {
+		t.Fatalf("BuildProcessors() error = %v", err)
+	}
+	if len(procs) != 1 {
+		t.Fatalf("built %d processors, want 1", len(procs))
+	}
+
+	span := &models.Span{}
+	if _, err := procs[0].Process(context.Background(), span); err != nil {
+		t.Fatalf("Process() error = %v", err)
+	}
+	if span.GetTag("team") != "payments" {
+		t.Errorf("team tag = %q, want payments", span.GetTag("team"))
+	}
+
+	exps, err := config.BuildExporters()
+	if err != nil {
+		t.Fatalf("BuildExporters() error = %v", err)
+	}
+	if len(exps) != 1 || exps[0].Name() != "test_nop" {
+		t.Errorf("unexpected exporters: %v", exps)
+	}
+}
+
+func TestBuild_UnknownPlugin(t *testing.T) {
+	config := &Config{Processors: []Spec{{Name: "does_not_exist"}}}
+	if _, err := config.BuildProcessors(); err == nil {
+		t.Error("expected error for unknown processor")
+	}
+
+	config = &Config{Exporters: []Spec{{Name: "does_not_exist"}}}
+	if _, err := config.BuildExporters(); err == nil {
+		t.Error("expected error for unknown exporter")
+	}
+}
+
+func TestRegister_DuplicatePanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected panic on duplicate registration")
+		}
+	}()
+	RegisterProcessor("test_tag", nil)
+}
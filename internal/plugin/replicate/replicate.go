@@ -0,0 +1,289 @@
+// Package replicate provides the "replica" exporter, which forwards stored
+// spans to a standby collector. The standby holds a warm copy of the
+// in-memory store, so clients can fail over to it without losing recent
+// traces. Import it for its side effect of registering the exporter:
+//
+//	import _ "github.com/saintparish4/asmbly/internal/plugin/replicate"
+package replicate
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Name is the registered exporter name.
+const Name = "replica"
+
+// batchPath is the standby's batch ingestion endpoint.
+const batchPath = "/api/v1/spans/batch"
+
+func init() {
+	plugin.RegisterExporter(Name, func(config json.RawMessage) (plugin.Exporter, error) {
+		var cfg Config
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid config: %w", err)
+			}
+		}
+		return New(cfg)
+	})
+}
+
+// Config is the exporter's JSON config. Only Endpoint is required.
+type Config struct {
+	// Endpoint is the standby collector's base URL, e.g. "http://standby:9090"
+	Endpoint string            `json:"endpoint"`
+	Headers  map[string]string `json:"headers,omitempty"` // e.g. an auth header for the standby
+
+	BatchSize     int    `json:"batch_size,omitempty"`     // Max spans per request (default 512)
+	QueueSize     int    `json:"queue_size,omitempty"`     // Buffered spans before new ones are dropped (default 8192)
+	FlushInterval string `json:"flush_interval,omitempty"` // Max time a partial batch waits (default 1s)
+	Timeout       string `json:"timeout,omitempty"`        // Per-request timeout (default 10s)
+	MaxRetries    int    `json:"max_retries,omitempty"`    // Retries of a retryable failure (default 3)
+}
+
+// Defaults for unset Config fields. The flush interval is short so the
+// standby trails the primary by about a second.
+const (
+	DefaultBatchSize     = 512
+	DefaultQueueSize     = 8192
+	DefaultFlushInterval = time.Second
+	DefaultTimeout       = 10 * time.Second
+	DefaultMaxRetries    = 3
+)
+
+// retryBackoff is the delay before the first retry, doubled on each retry.
+var retryBackoff = 500 * time.Millisecond
+
+// Exporter batches spans in the background and posts them, gzipped, to the
+// standby's batch endpoint in the collector's own span format. Export never
+// blocks the primary: spans that do not fit the queue are dropped and
+// counted. Writes are upserts, so a retried batch is safe to apply twice.
+type Exporter struct {
+	url           string
+	headers       map[string]string
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	client        *http.Client
+	logger        *slog.Logger
+
+	queue    chan *models.Span
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+
+	replicated atomic.Int64
+	dropped    atomic.Int64
+}
+
+// New validates config and starts the replication loop.
+func New(config Config) (*Exporter, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, errors.New("endpoint must be an http or https URL")
+	}
+
+	e := &Exporter{
+		url:           strings.TrimSuffix(config.Endpoint, "/") + batchPath,
+		headers:       config.Headers,
+		batchSize:     config.BatchSize,
+		flushInterval: DefaultFlushInterval,
+		maxRetries:    config.MaxRetries,
+		logger:        slog.Default().With("exporter", Name),
+		done:          make(chan struct{}),
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = DefaultBatchSize
+	}
+	if e.maxRetries <= 0 {
+		e.maxRetries = DefaultMaxRetries
+	}
+	queueSize := config.QueueSize
+	if queueSize <= 0 {
+		queueSize = DefaultQueueSize
+	}
+	e.queue = make(chan *models.Span, queueSize)
+
+	if config.FlushInterval != "" {
+		if e.flushInterval, err = time.ParseDuration(config.FlushInterval); err != nil || e.flushInterval <= 0 {
+			return nil, fmt.Errorf("invalid flush_interval %q", config.FlushInterval)
+		}
+	}
+	timeout := DefaultTimeout
+	if config.Timeout != "" {
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", config.Timeout)
+		}
+	}
+	e.client = &http.Client{Timeout: timeout}
+
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+// Name returns the registered plugin name.
+func (e *Exporter) Name() string { return Name }
+
+// Export queues a stored span. Unlike upstream exporters, in-progress
+// snapshots are replicated too, so the standby sees partial traces.
+func (e *Exporter) Export(ctx context.Context, span *models.Span) error {
+	select {
+	case <-e.done:
+		return errors.New("exporter stopped")
+	default:
+	}
+
+	select {
+	case e.queue <- span:
+		return nil
+	default:
+		e.dropped.Add(1)
+		return errors.New("replication queue full, span dropped")
+	}
+}
+
+// Shutdown flushes queued spans, waiting until they are sent or ctx expires.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.done) })
+
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		e.logger.Info("replication stopped", "replicated", e.replicated.Load(), "dropped", e.dropped.Load())
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run batches queued spans until shutdown, then drains the queue.
+func (e *Exporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.Span, 0, e.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = make([]*models.Span, 0, e.batchSize)
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= e.batchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts one batch, retrying with exponential backoff while the standby
+// is unreachable, saturated or accepted only part of it. A batch that still
+// fails is dropped.
+func (e *Exporter) send(batch []*models.Span) {
+	data, err := json.Marshal(batch)
+	if err != nil {
+		e.logger.Error("failed to encode replication batch", "error", err)
+		e.dropped.Add(int64(len(batch)))
+		return
+	}
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(data)
+	zw.Close()
+	body := buf.Bytes()
+
+	backoff := retryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := e.post(body)
+		if err == nil {
+			e.replicated.Add(int64(len(batch)))
+			return
+		}
+		if !retry || attempt >= e.maxRetries {
+			e.logger.Warn("replication failed", "spans", len(batch), "attempts", attempt+1, "error", err)
+			e.dropped.Add(int64(len(batch)))
+			return
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one request; retry reports whether a failure is transient.
+func (e *Exporter) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", "gzip")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode == http.StatusPartialContent:
+		// Some spans did not fit the standby's queue; resending the
+		// whole batch only rewrites the ones it already has
+		return true, errors.New("standby accepted part of the batch")
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return true, fmt.Errorf("standby returned %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("standby returned %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,108 @@
+package replicate
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/collector"
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/internal/storage"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func testSpan(traceID string) *models.Span {
+	return &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "GET /users",
+		StartTime:     time.Unix(1700000000, 0),
+		Duration:      25 * time.Millisecond,
+		Status:        "ok",
+		Tags:          map[string]string{"http.method": "GET"},
+	}
+}
+
+func TestExporter_ReplicatesToStandby(t *testing.T) {
+	retryBackoff = time.Millisecond
+
+	// A standby collector that is briefly unavailable
+	store := storage.NewMemoryStore(1000)
+	standby := collector.NewCollector(store, &collector.Config{Workers: 1, ChannelBuffer: 100}, slog.Default())
+	ctx := context.Background()
+	standby.Start(ctx)
+	var failures atomic.Int32
+	failures.Store(1)
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		headers = r.Header
+		standby.HandlePostSpansBatch(w, r)
+	}))
+	defer server.Close()
+
+	exp, err := New(Config{
+		Endpoint:      server.URL + "/",
+		Headers:       map[string]string{"Authorization": "Bearer replica"},
+		BatchSize:     2,
+		FlushInterval: "1h", // Only full batches and shutdown flush
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	traceID := models.GenerateTraceID()
+	inProgress := testSpan(traceID)
+	inProgress.InProgress = true
+	for _, span := range []*models.Span{testSpan(traceID), inProgress, testSpan(traceID)} {
+		if err := exp.Export(ctx, span); err != nil {
+			t.Fatalf("Export() error = %v", err)
+		}
+	}
+	if err := exp.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	standby.Stop(ctx)
+
+	// The first batch was retried after the 503; in-progress spans are kept
+	trace, err := store.GetTrace(ctx, traceID)
+	if err != nil || trace == nil {
+		t.Fatalf("GetTrace() = %v, %v", trace, err)
+	}
+	if len(trace.Spans) != 3 || !trace.InProgress || trace.Spans[0].GetTag("http.method") != "GET" {
+		t.Errorf("standby trace has %d spans, in_progress %v", len(trace.Spans), trace.InProgress)
+	}
+	if headers.Get("Authorization") != "Bearer replica" || headers.Get("Content-Encoding") != "gzip" {
+		t.Errorf("headers = %v", headers)
+	}
+	if exp.replicated.Load() != 3 || exp.dropped.Load() != 0 {
+		t.Errorf("replicated %d, dropped %d", exp.replicated.Load(), exp.dropped.Load())
+	}
+
+	if err := exp.Export(ctx, testSpan(traceID)); err == nil {
+		t.Error("Export after Shutdown succeeded")
+	}
+}
+
+func TestRegisteredFromConfig(t *testing.T) {
+	cfg := plugin.Config{Exporters: []plugin.Spec{{Name: Name, Config: json.RawMessage(`{"endpoint": "standby:9090"}`)}}}
+	if _, err := cfg.BuildExporters(); err == nil {
+		t.Error("expected error for an endpoint without a scheme")
+	}
+
+	cfg.Exporters[0].Config = json.RawMessage(`{"endpoint": "http://standby:9090", "flush_interval": "200ms"}`)
+	exporters, err := cfg.BuildExporters()
+	if err != nil || len(exporters) != 1 || exporters[0].Name() != Name {
+		t.Fatalf("BuildExporters() = %v, %v", exporters, err)
+	}
+	exporters[0].Shutdown(context.Background())
+}
@@ -0,0 +1,267 @@
+// Package transform provides the "transform" processor, which renames,
+// copies, deletes and sets span tags and fields before storage, so
+// inconsistent instrumentation can be fixed centrally. Import it for its side
+// effect of registering the processor:
+//
+//	import _ "github.com/saintparish4/asmbly/internal/plugin/transform"
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/saintparish4/asmbly/internal/pattern"
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Name is the registered processor name.
+const Name = "transform"
+
+func init() {
+	plugin.RegisterProcessor(Name, func(config json.RawMessage) (plugin.Processor, error) {
+		var cfg Config
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid config: %w", err)
+			}
+		}
+		return New(cfg)
+	})
+}
+
+// Actions.
+const (
+	ActionSet    = "set"    // Set Key to Value
+	ActionDelete = "delete" // Remove Key
+	ActionRename = "rename" // Move Key's value to To
+	ActionCopy   = "copy"   // Copy Key's value to To
+)
+
+// TagPrefix addresses a tag rather than a span field: "tags.http.status"
+// is the "http.status" tag.
+const TagPrefix = "tags."
+
+// Action is one operation. Key and To are either a field name (see Fields)
+// or TagPrefix followed by a tag key. Rename and copy do nothing when Key is
+// missing and overwrite To when it exists.
+type Action struct {
+	Action string `json:"action"`
+	Key    string `json:"key"`
+	To     string `json:"to,omitempty"`    // Rename and copy
+	Value  string `json:"value,omitempty"` // Set
+}
+
+// Rule applies its actions, in order, to spans matching Service and
+// Operation; patterns may use '*' and empty patterns match every span.
+type Rule struct {
+	Service   string   `json:"service,omitempty"`
+	Operation string   `json:"operation,omitempty"`
+	Actions   []Action `json:"actions"`
+}
+
+// Config is the processor's JSON config. Rules run in order, so later rules
+// see the changes of earlier ones.
+type Config struct {
+	Rules []Rule `json:"rules"`
+}
+
+// field reads and writes one string field of a span.
+type field struct {
+	get      func(*models.Span) string
+	set      func(*models.Span, string)
+	required bool // Cannot be deleted or renamed away
+}
+
+// fields are the span fields actions can address, by JSON name.
+var fields = map[string]field{
+	"service_name":   {func(s *models.Span) string { return s.ServiceName }, func(s *models.Span, v string) { s.ServiceName = v }, true},
+	"operation_name": {func(s *models.Span) string { return s.OperationName }, func(s *models.Span, v string) { s.OperationName = v }, true},
+	"span_kind":      {func(s *models.Span) string { return s.SpanKind }, func(s *models.Span, v string) { s.SpanKind = v }, false},
+	"status_message": {func(s *models.Span) string { return s.StatusMessage }, func(s *models.Span, v string) { s.StatusMessage = v }, false},
+	"deployment_id":  {func(s *models.Span) string { return s.DeploymentID }, func(s *models.Span, v string) { s.DeploymentID = v }, false},
+	"git_sha":        {func(s *models.Span) string { return s.GitSHA }, func(s *models.Span, v string) { s.GitSHA = v }, false},
+	"environment":    {func(s *models.Span) string { return s.Environment }, func(s *models.Span, v string) { s.Environment = v }, false},
+}
+
+// Fields returns the sorted names of the fields actions can address.
+func Fields() []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Processor applies transform rules to every span.
+type Processor struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	service, operation *regexp.Regexp
+	actions            []compiledAction
+}
+
+type compiledAction struct {
+	op      string
+	key, to target
+	value   string
+}
+
+// target is a tag key or a span field.
+type target struct {
+	tag   string
+	field *field
+}
+
+func (t target) get(span *models.Span) (string, bool) {
+	if t.field != nil {
+		value := t.field.get(span)
+		return value, value != ""
+	}
+	value, ok := span.Tags[t.tag]
+	return value, ok
+}
+
+func (t target) set(span *models.Span, value string) {
+	if t.field != nil {
+		t.field.set(span, value)
+		return
+	}
+	if span.Tags == nil {
+		span.Tags = make(map[string]string)
+	}
+	span.Tags[t.tag] = value
+}
+
+func (t target) delete(span *models.Span) {
+	if t.field != nil {
+		t.field.set(span, "")
+		return
+	}
+	delete(span.Tags, t.tag)
+}
+
+// parseTarget resolves a key to a tag or field.
+func parseTarget(key string) (target, error) {
+	if tag, ok := strings.CutPrefix(key, TagPrefix); ok {
+		if tag == "" {
+			return target{}, fmt.Errorf("empty tag key in %q", key)
+		}
+		return target{tag: tag}, nil
+	}
+	f, ok := fields[key]
+	if !ok {
+		return target{}, fmt.Errorf("unknown key %q: use %s<tag> or one of %v", key, TagPrefix, Fields())
+	}
+	return target{field: &f}, nil
+}
+
+// New builds a processor from config.
+func New(config Config) (*Processor, error) {
+	if len(config.Rules) == 0 {
+		return nil, fmt.Errorf("at least one rule is required")
+	}
+
+	p := &Processor{}
+	for i, rule := range config.Rules {
+		if len(rule.Actions) == 0 {
+			return nil, fmt.Errorf("rule %d: no actions", i)
+		}
+		compiled := compiledRule{
+			service:   pattern.Compile(rule.Service),
+			operation: pattern.Compile(rule.Operation),
+		}
+		for j, action := range rule.Actions {
+			a, err := compileAction(action)
+			if err != nil {
+				return nil, fmt.Errorf("rule %d action %d: %w", i, j, err)
+			}
+			compiled.actions = append(compiled.actions, a)
+		}
+		p.rules = append(p.rules, compiled)
+	}
+	return p, nil
+}
+
+func compileAction(action Action) (compiledAction, error) {
+	key, err := parseTarget(action.Key)
+	if err != nil {
+		return compiledAction{}, err
+	}
+	a := compiledAction{op: action.Action, key: key, value: action.Value}
+
+	switch action.Action {
+	case ActionSet:
+		if key.field != nil && key.field.required && action.Value == "" {
+			return a, fmt.Errorf("%s cannot be set to an empty value", action.Key)
+		}
+	case ActionDelete:
+		if key.field != nil && key.field.required {
+			return a, fmt.Errorf("%s cannot be deleted", action.Key)
+		}
+	case ActionRename, ActionCopy:
+		if action.To == "" {
+			return a, fmt.Errorf("%s requires to", action.Action)
+		}
+		if a.to, err = parseTarget(action.To); err != nil {
+			return a, err
+		}
+		if action.Action == ActionRename && key.field != nil && key.field.required {
+			return a, fmt.Errorf("%s cannot be renamed; copy it instead", action.Key)
+		}
+	default:
+		return a, fmt.Errorf("unknown action %q (want set, delete, rename or copy)", action.Action)
+	}
+	return a, nil
+}
+
+// Name returns the registered plugin name.
+func (p *Processor) Name() string { return Name }
+
+// Process applies the actions of every matching rule to the span in place.
+func (p *Processor) Process(ctx context.Context, span *models.Span) (*models.Span, error) {
+	for _, rule := range p.rules {
+		if !rule.matches(span) {
+			continue
+		}
+		for _, a := range rule.actions {
+			a.apply(span)
+		}
+	}
+	return span, nil
+}
+
+func (r *compiledRule) matches(span *models.Span) bool {
+	if r.service != nil && !r.service.MatchString(span.ServiceName) {
+		return false
+	}
+	if r.operation != nil && !r.operation.MatchString(span.OperationName) {
+		return false
+	}
+	return true
+}
+
+func (a *compiledAction) apply(span *models.Span) {
+	switch a.op {
+	case ActionSet:
+		a.key.set(span, a.value)
+	case ActionDelete:
+		a.key.delete(span)
+	case ActionRename, ActionCopy:
+		value, ok := a.key.get(span)
+		if !ok || (value == "" && a.to.field != nil && a.to.field.required) {
+			return
+		}
+		a.to.set(span, value)
+		if a.op == ActionRename {
+			a.key.delete(span)
+		}
+	}
+}
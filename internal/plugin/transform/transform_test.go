@@ -0,0 +1,113 @@
+package transform
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/saintparish4/asmbly/internal/plugin"
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestProcessor_Actions(t *testing.T) {
+	p, err := New(Config{Rules: []Rule{
+		{Actions: []Action{
+			{Action: ActionRename, Key: "tags.http.status", To: "tags.http.status_code"},
+			{Action: ActionDelete, Key: "tags.db.statement"},
+		}},
+		{Service: "legacy-*", Actions: []Action{
+			{Action: ActionCopy, Key: "tags.env", To: "environment"},
+			{Action: ActionSet, Key: "tags.team", Value: "payments"},
+			{Action: ActionRename, Key: "tags.missing", To: "tags.other"},
+		}},
+	}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	legacy := &models.Span{
+		ServiceName: "legacy-billing",
+		Tags:        map[string]string{"http.status": "200", "db.statement": "SELECT 1", "env": "prod"},
+	}
+	got, err := p.Process(context.Background(), legacy)
+	if err != nil || got != legacy {
+		t.Fatalf("Process() = %v, %v; want the span modified in place", got, err)
+	}
+	want := map[string]string{"http.status_code": "200", "env": "prod", "team": "payments"}
+	if len(got.Tags) != len(want) {
+		t.Errorf("tags = %v, want %v", got.Tags, want)
+	}
+	for k, v := range want {
+		if got.Tags[k] != v {
+			t.Errorf("tag %s = %q, want %q", k, got.Tags[k], v)
+		}
+	}
+	if got.Environment != "prod" {
+		t.Errorf("environment = %q, want copied from tag", got.Environment)
+	}
+
+	// Only the first rule applies to other services, and it has nothing to rename
+	other := &models.Span{ServiceName: "api"}
+	p.Process(context.Background(), other)
+	if other.Tags != nil || other.Environment != "" {
+		t.Errorf("non-matching span changed: %+v", other)
+	}
+}
+
+func TestProcessor_Fields(t *testing.T) {
+	p, err := New(Config{Rules: []Rule{{Operation: "GET /v1/*", Actions: []Action{
+		{Action: ActionCopy, Key: "operation_name", To: "tags.legacy.operation"},
+		{Action: ActionSet, Key: "operation_name", Value: "GET /v1"},
+		{Action: ActionRename, Key: "git_sha", To: "tags.commit"},
+	}}}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	span := &models.Span{OperationName: "GET /v1/users", GitSHA: "abc123", Tags: map[string]string{}}
+	p.Process(context.Background(), span)
+	if span.OperationName != "GET /v1" || span.Tags["legacy.operation"] != "GET /v1/users" {
+		t.Errorf("operation = %q, tags = %v", span.OperationName, span.Tags)
+	}
+	if span.GitSHA != "" || span.Tags["commit"] != "abc123" {
+		t.Errorf("git_sha = %q, tags = %v; want moved to a tag", span.GitSHA, span.Tags)
+	}
+}
+
+func TestNew_InvalidConfig(t *testing.T) {
+	for name, action := range map[string]Action{
+		"unknown action":        {Action: "upper", Key: "tags.a"},
+		"unknown field":         {Action: ActionDelete, Key: "duration"},
+		"empty tag":             {Action: ActionDelete, Key: "tags."},
+		"missing to":            {Action: ActionCopy, Key: "tags.a"},
+		"delete required field": {Action: ActionDelete, Key: "service_name"},
+		"rename required field": {Action: ActionRename, Key: "operation_name", To: "tags.op"},
+		"clear required field":  {Action: ActionSet, Key: "service_name"},
+		"unknown destination":   {Action: ActionRename, Key: "tags.a", To: "trace_id"},
+	} {
+		if _, err := New(Config{Rules: []Rule{{Actions: []Action{action}}}}); err == nil {
+			t.Errorf("%s: New() succeeded, want error", name)
+		}
+	}
+	if _, err := New(Config{}); err == nil {
+		t.Error("New() without rules succeeded")
+	}
+	if _, err := New(Config{Rules: []Rule{{Service: "api"}}}); err == nil {
+		t.Error("New() with an empty rule succeeded")
+	}
+}
+
+func TestRegisteredFromConfig(t *testing.T) {
+	config := plugin.Config{Processors: []plugin.Spec{{
+		Name:   Name,
+		Config: json.RawMessage(`{"rules": [{"actions": [{"action": "set", "key": "tags.region", "value": "eu"}]}]}`),
+	}}}
+	procs, err := config.BuildProcessors()
+	if err != nil {
+		t.Fatalf("BuildProcessors() error = %v", err)
+	}
+	span, _ := procs[0].Process(context.Background(), &models.Span{})
+	if span.Tags["region"] != "eu" {
+		t.Errorf("tags = %v", span.Tags)
+	}
+}
@@ -0,0 +1,28 @@
+package prober
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HandleProbes handles GET /api/v1/probes, the state of every check. A nil
+// Prober serves an empty list.
+func (p *Prober) HandleProbes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	statuses := p.Statuses()
+	up := 0
+	for _, status := range statuses {
+		if status.Up {
+			up++
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"probes": statuses,
+		"total":  len(statuses),
+		"up":     up,
+	})
+}
@@ -0,0 +1,488 @@
+// Package prober runs synthetic HTTP checks against configured endpoints.
+// Each run is recorded as a trace, with child spans for DNS, connect, TLS
+// and the wait for the first byte, and handed to the collector like any
+// other span. A check that keeps failing fires an alert on its notifiers
+// and resolves it once the endpoint recovers, so the collector doubles as a
+// basic uptime monitor.
+package prober
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/alerting"
+	"github.com/saintparish4/asmbly/internal/receiver"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Defaults for unset Config and Check fields.
+const (
+	DefaultService          = "synthetic-probe"
+	DefaultInterval         = 30 * time.Second
+	DefaultTimeout          = 10 * time.Second
+	DefaultFailureThreshold = 3
+)
+
+// maxBodyRead caps how much of a response is read to match ExpectBody.
+const maxBodyRead = 1 << 20
+
+// Check is one HTTP check, run every Interval.
+type Check struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Method  string            `json:"method,omitempty"` // Default GET
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+
+	Interval string `json:"interval,omitempty"` // Default 30s
+	Timeout  string `json:"timeout,omitempty"`  // Default 10s
+
+	// ExpectStatus lists the status codes that pass (default: 200-399);
+	// ExpectBody must appear in the response body if set
+	ExpectStatus []int  `json:"expect_status,omitempty"`
+	ExpectBody   string `json:"expect_body,omitempty"`
+
+	// Notify names the notifiers alerted once FailureThreshold runs in a
+	// row have failed (default 3), and again when a run passes
+	Notify           []string `json:"notify,omitempty"`
+	FailureThreshold int      `json:"failure_threshold,omitempty"`
+	Severity         string   `json:"severity,omitempty"` // Default "critical"
+}
+
+// Config is the "probes" section of the collector's config file.
+type Config struct {
+	// Service is the service name of probe spans (default "synthetic-probe")
+	Service string  `json:"service,omitempty"`
+	Checks  []Check `json:"checks"`
+}
+
+// Status is the state of one check, served at /api/v1/probes.
+type Status struct {
+	Name     string        `json:"name"`
+	URL      string        `json:"url"`
+	Interval time.Duration `json:"interval"`
+
+	Up                  bool          `json:"up"` // The last run passed
+	Runs                int64         `json:"runs"`
+	Failures            int64         `json:"failures"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	Alerting            bool          `json:"alerting"` // A firing alert was sent and not yet resolved
+	LastRun             *time.Time    `json:"last_run,omitempty"`
+	LastDuration        time.Duration `json:"last_duration"`
+	LastStatusCode      int           `json:"last_status_code,omitempty"`
+	LastError           string        `json:"last_error,omitempty"`
+	LastTraceID         string        `json:"last_trace_id,omitempty"`
+}
+
+// probe is a validated Check with its state.
+type probe struct {
+	Check
+	method    string
+	interval  time.Duration
+	timeout   time.Duration
+	threshold int
+
+	mu       sync.Mutex
+	status   Status
+	firingAt time.Time // When the open alert fired, zero if none
+}
+
+// Prober runs checks in the background. It is safe for concurrent use.
+type Prober struct {
+	service   string
+	probes    []*probe
+	notifiers *alerting.Set
+	client    *http.Client
+	logger    *slog.Logger
+
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// New validates config, checking notifier names against notifiers (which
+// may be nil when no check notifies). Call Start to run the checks.
+func New(config Config, notifiers *alerting.Set, logger *slog.Logger) (*Prober, error) {
+	p := &Prober{
+		service:   config.Service,
+		notifiers: notifiers,
+		logger:    logger.With("component", "prober"),
+		done:      make(chan struct{}),
+		// Each run gets fresh connections so DNS, connect and TLS are timed
+		client: &http.Client{
+			Transport: &http.Transport{DisableKeepAlives: true, Proxy: http.ProxyFromEnvironment},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse // The check's own status is what counts
+			},
+		},
+	}
+	if p.service == "" {
+		p.service = DefaultService
+	}
+	if len(config.Checks) == 0 {
+		return nil, errors.New("probes: at least one check is required")
+	}
+
+	names := make(map[string]bool)
+	for _, check := range config.Checks {
+		probe, err := newProbe(check)
+		if err != nil {
+			return nil, err
+		}
+		if names[check.Name] {
+			return nil, fmt.Errorf("duplicate check name %q", check.Name)
+		}
+		names[check.Name] = true
+		if len(check.Notify) > 0 {
+			if notifiers == nil {
+				return nil, fmt.Errorf("check %q: no notifiers configured", check.Name)
+			}
+			if err := notifiers.Validate(check.Notify); err != nil {
+				return nil, fmt.Errorf("check %q: %w", check.Name, err)
+			}
+		}
+		p.probes = append(p.probes, probe)
+	}
+	return p, nil
+}
+
+func newProbe(check Check) (*probe, error) {
+	if check.Name == "" {
+		return nil, errors.New("check name is required")
+	}
+	u, err := url.Parse(check.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("check %q: url must be an http or https URL", check.Name)
+	}
+	pr := &probe{
+		Check:     check,
+		method:    strings.ToUpper(check.Method),
+		interval:  DefaultInterval,
+		timeout:   DefaultTimeout,
+		threshold: check.FailureThreshold,
+	}
+	if pr.method == "" {
+		pr.method = http.MethodGet
+	}
+	if pr.threshold <= 0 {
+		pr.threshold = DefaultFailureThreshold
+	}
+	if pr.Severity == "" {
+		pr.Severity = "critical"
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		into  *time.Duration
+	}{
+		{"interval", check.Interval, &pr.interval},
+		{"timeout", check.Timeout, &pr.timeout},
+	} {
+		if d.value == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil || parsed <= 0 {
+			return nil, fmt.Errorf("check %q: invalid %s %q", check.Name, d.name, d.value)
+		}
+		*d.into = parsed
+	}
+	for _, code := range check.ExpectStatus {
+		if code < 100 || code > 599 {
+			return nil, fmt.Errorf("check %q: invalid expected status %d", check.Name, code)
+		}
+	}
+	pr.status = Status{Name: check.Name, URL: check.URL, Interval: pr.interval}
+	return pr, nil
+}
+
+// Checks returns the number of configured checks.
+func (p *Prober) Checks() int { return len(p.probes) }
+
+// Start runs every check on its interval, the first run right away, and
+// submits the spans of each run to consumer until Stop.
+func (p *Prober) Start(consumer receiver.Consumer) {
+	for _, pr := range p.probes {
+		p.wg.Add(1)
+		go func(pr *probe) {
+			defer p.wg.Done()
+			ticker := time.NewTicker(pr.interval)
+			defer ticker.Stop()
+			for {
+				p.run(pr, consumer)
+				select {
+				case <-p.done:
+					return
+				case <-ticker.C:
+				}
+			}
+		}(pr)
+	}
+}
+
+// Stop ends the checks, waiting for runs in progress. It is a no-op on a
+// nil Prober.
+func (p *Prober) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() { close(p.done) })
+	p.wg.Wait()
+}
+
+// Statuses returns the state of every check, in config order.
+func (p *Prober) Statuses() []Status {
+	if p == nil {
+		return []Status{}
+	}
+	statuses := make([]Status, 0, len(p.probes))
+	for _, pr := range p.probes {
+		pr.mu.Lock()
+		statuses = append(statuses, pr.status)
+		pr.mu.Unlock()
+	}
+	return statuses
+}
+
+// result is the outcome of one run.
+type result struct {
+	start      time.Time
+	duration   time.Duration
+	statusCode int
+	err        error
+	phases     []phase
+}
+
+// phase is a timed part of a request, recorded as a child span.
+type phase struct {
+	name       string
+	start, end time.Time
+}
+
+// run executes a check once, submits its spans and updates its status and
+// alert.
+func (p *Prober) run(pr *probe, consumer receiver.Consumer) {
+	traceID, spanID := models.GenerateTraceID(), models.GenerateSpanID()
+	res := p.execute(pr, traceID, spanID)
+
+	for _, span := range p.spans(pr, res, traceID, spanID) {
+		if err := consumer.SubmitSpan(span); err != nil {
+			p.logger.Warn("failed to submit probe span", "check", pr.Name, "error", err)
+		}
+	}
+	p.record(pr, res, traceID)
+}
+
+// execute makes the check's request, propagating the run's trace context so
+// an instrumented endpoint continues the probe's trace.
+func (p *Prober) execute(pr *probe, traceID, spanID string) result {
+	res := result{start: time.Now()}
+	defer func() { res.duration = time.Since(res.start) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pr.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	marks := make(map[string]time.Time)
+	mark := func(name string) {
+		mu.Lock()
+		if _, ok := marks[name]; !ok {
+			marks[name] = time.Now()
+		}
+		mu.Unlock()
+	}
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { mark("dns_start") },
+		DNSDone:              func(httptrace.DNSDoneInfo) { mark("dns_done") },
+		ConnectStart:         func(string, string) { mark("connect_start") },
+		ConnectDone:          func(string, string, error) { mark("connect_done") },
+		TLSHandshakeStart:    func() { mark("tls_start") },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { mark("tls_done") },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { mark("wrote_request") },
+		GotFirstResponseByte: func() { mark("first_byte") },
+	})
+
+	var body io.Reader
+	if pr.Body != "" {
+		body = strings.NewReader(pr.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, pr.method, pr.URL, body)
+	if err != nil {
+		res.err = err
+		return res
+	}
+	for key, value := range pr.Headers {
+		req.Header.Set(key, value)
+	}
+	req.Header.Set("User-Agent", "traceflow-prober")
+	req.Header.Set("traceparent", fmt.Sprintf("00-%s-%s-01", traceID, spanID))
+
+	resp, err := p.client.Do(req)
+	if err == nil {
+		res.statusCode = resp.StatusCode
+		res.err = pr.verify(resp)
+		resp.Body.Close()
+	} else {
+		res.err = err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, ph := range []struct{ name, start, end string }{
+		{"dns", "dns_start", "dns_done"},
+		{"connect", "connect_start", "connect_done"},
+		{"tls handshake", "tls_start", "tls_done"},
+		{"time to first byte", "wrote_request", "first_byte"},
+	} {
+		start, ok1 := marks[ph.start]
+		end, ok2 := marks[ph.end]
+		if ok1 && ok2 {
+			res.phases = append(res.phases, phase{name: ph.name, start: start, end: end})
+		}
+	}
+	return res
+}
+
+// verify checks the response against the check's expectations.
+func (pr *probe) verify(resp *http.Response) error {
+	if len(pr.ExpectStatus) > 0 {
+		if !slices.Contains(pr.ExpectStatus, resp.StatusCode) {
+			return fmt.Errorf("unexpected status %d", resp.StatusCode)
+		}
+	} else if resp.StatusCode >= 400 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if pr.ExpectBody != "" {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodyRead))
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		if !strings.Contains(string(body), pr.ExpectBody) {
+			return fmt.Errorf("body does not contain %q", pr.ExpectBody)
+		}
+	}
+	return nil
+}
+
+// spans returns the run's root span and a child span per request phase.
+func (p *Prober) spans(pr *probe, res result, traceID, spanID string) []*models.Span {
+	root := &models.Span{
+		TraceID:       traceID,
+		SpanID:        spanID,
+		ServiceName:   p.service,
+		OperationName: pr.Name,
+		StartTime:     res.start,
+		Duration:      res.duration,
+		SpanKind:      "client",
+		Status:        "ok",
+		Tags: map[string]string{
+			"probe.name":  pr.Name,
+			"http.method": pr.method,
+			"http.url":    pr.URL,
+			"probe.up":    strconv.FormatBool(res.err == nil),
+		},
+	}
+	if res.statusCode != 0 {
+		root.Tags["http.status_code"] = strconv.Itoa(res.statusCode)
+	}
+	if res.err != nil {
+		root.Status = "error"
+		root.StatusMessage = res.err.Error()
+		root.Tags["error.message"] = res.err.Error()
+	}
+
+	spans := []*models.Span{root}
+	for _, ph := range res.phases {
+		spans = append(spans, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ParentSpanID:  spanID,
+			ServiceName:   p.service,
+			OperationName: ph.name,
+			StartTime:     ph.start,
+			Duration:      ph.end.Sub(ph.start),
+			SpanKind:      "internal",
+			Status:        "ok",
+			Tags:          map[string]string{"probe.name": pr.Name},
+		})
+	}
+	return spans
+}
+
+// record updates the check's status and fires or resolves its alert.
+func (p *Prober) record(pr *probe, res result, traceID string) {
+	pr.mu.Lock()
+	status := &pr.status
+	status.Runs++
+	status.Up = res.err == nil
+	status.LastRun = &res.start
+	status.LastDuration = res.duration
+	status.LastStatusCode = res.statusCode
+	status.LastTraceID = traceID
+	status.LastError = ""
+	if res.err != nil {
+		status.Failures++
+		status.ConsecutiveFailures++
+		status.LastError = res.err.Error()
+	} else {
+		status.ConsecutiveFailures = 0
+	}
+
+	var alert *alerting.Alert
+	switch {
+	case res.err != nil && !status.Alerting && status.ConsecutiveFailures >= pr.threshold:
+		status.Alerting = true
+		pr.firingAt = res.start
+		alert = pr.alert(p.service, alerting.StatusFiring, res, status.ConsecutiveFailures)
+	case res.err == nil && status.Alerting:
+		status.Alerting = false
+		alert = pr.alert(p.service, alerting.StatusResolved, res, 0)
+		pr.firingAt = time.Time{}
+	}
+	pr.mu.Unlock()
+
+	if res.err != nil {
+		p.logger.Warn("probe failed", "check", pr.Name, "url", pr.URL, "error", res.err, "trace_id", traceID)
+	}
+	if alert != nil && len(pr.Notify) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), alerting.DefaultTimeout)
+		defer cancel()
+		if err := p.notifiers.Notify(ctx, *alert, pr.Notify); err != nil {
+			p.logger.Error("failed to send probe alert", "check", pr.Name, "status", alert.Status, "error", err)
+		}
+	}
+}
+
+// alert builds the check's notification; call with pr.mu held.
+func (pr *probe) alert(service string, status alerting.Status, res result, failures int) *alerting.Alert {
+	alert := &alerting.Alert{
+		Rule:      "probe:" + pr.Name,
+		Service:   service,
+		Status:    status,
+		Severity:  pr.Severity,
+		Value:     float64(failures),
+		Threshold: float64(pr.threshold),
+		StartsAt:  pr.firingAt,
+		Labels:    map[string]string{"probe": pr.Name, "url": pr.URL},
+	}
+	if status == alerting.StatusResolved {
+		ends := res.start
+		alert.EndsAt = &ends
+		alert.Summary = fmt.Sprintf("%s %s is passing again", pr.method, pr.URL)
+	} else {
+		alert.Summary = fmt.Sprintf("%s %s failed %d times in a row: %v", pr.method, pr.URL, failures, res.err)
+	}
+	return alert
+}
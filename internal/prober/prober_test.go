@@ -0,0 +1,143 @@
+package prober
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/saintparish4/asmbly/internal/alerting"
+	"github.com/saintparish4/asmbly/models"
+)
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*models.Span
+}
+
+func (r *spanRecorder) SubmitSpan(span *models.Span) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, span)
+	return nil
+}
+
+type alertRecorder struct {
+	mu     sync.Mutex
+	alerts []alerting.Alert
+}
+
+func (r *alertRecorder) Notify(_ context.Context, alert alerting.Alert) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.alerts = append(r.alerts, alert)
+	return nil
+}
+
+func TestProber_RecordsTrace(t *testing.T) {
+	var traceparent string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte("status: ok"))
+	}))
+	defer target.Close()
+
+	p, err := New(Config{Checks: []Check{{Name: "home", URL: target.URL, ExpectBody: "ok"}}}, nil, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := &spanRecorder{}
+	p.run(p.probes[0], spans)
+
+	if len(spans.spans) < 2 {
+		t.Fatalf("expected a root span and phase spans, got %d spans", len(spans.spans))
+	}
+	root := spans.spans[0]
+	if root.ServiceName != DefaultService || root.OperationName != "home" || root.Status != "ok" {
+		t.Errorf("unexpected root span: %+v", root)
+	}
+	if root.Tags["http.status_code"] != "200" || root.Tags["probe.up"] != "true" {
+		t.Errorf("unexpected root tags: %v", root.Tags)
+	}
+	if want := "00-" + root.TraceID + "-" + root.SpanID + "-01"; traceparent != want {
+		t.Errorf("traceparent = %q, want %q", traceparent, want)
+	}
+	for _, span := range spans.spans[1:] {
+		if span.TraceID != root.TraceID || span.ParentSpanID != root.SpanID {
+			t.Errorf("phase span %q is not a child of the root", span.OperationName)
+		}
+	}
+
+	status := p.Statuses()[0]
+	if !status.Up || status.Runs != 1 || status.LastTraceID != root.TraceID {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestProber_AlertsAfterThreshold(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}
+	}))
+	defer target.Close()
+
+	alerts := &alertRecorder{}
+	notifiers, _ := alerting.NewSet(nil)
+	notifiers.Add("oncall", "test", alerts)
+	p, err := New(Config{Checks: []Check{{Name: "api", URL: target.URL, FailureThreshold: 2, Notify: []string{"oncall"}}}}, notifiers, slog.Default())
+	if err != nil {
+		t.Fatal(err)
+	}
+	spans := &spanRecorder{}
+
+	p.run(p.probes[0], spans)
+	if len(alerts.alerts) != 0 {
+		t.Fatalf("alerted after one failure")
+	}
+	if root := spans.spans[0]; root.Status != "error" || !strings.Contains(root.StatusMessage, "503") {
+		t.Errorf("failed run not recorded as an error: %+v", root)
+	}
+	p.run(p.probes[0], spans)
+	p.run(p.probes[0], spans)
+	if len(alerts.alerts) != 1 || alerts.alerts[0].Status != alerting.StatusFiring {
+		t.Fatalf("expected one firing alert, got %+v", alerts.alerts)
+	}
+
+	failing.Store(false)
+	p.run(p.probes[0], spans)
+	if len(alerts.alerts) != 2 || alerts.alerts[1].Status != alerting.StatusResolved || alerts.alerts[1].EndsAt == nil {
+		t.Fatalf("expected a resolved alert, got %+v", alerts.alerts)
+	}
+	if status := p.Statuses()[0]; !status.Up || status.Failures != 3 || status.Alerting {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+	}{
+		{"no checks", Config{}},
+		{"no name", Config{Checks: []Check{{URL: "http://example.com"}}}},
+		{"bad url", Config{Checks: []Check{{Name: "a", URL: "example.com"}}}},
+		{"bad interval", Config{Checks: []Check{{Name: "a", URL: "http://example.com", Interval: "often"}}}},
+		{"duplicate", Config{Checks: []Check{{Name: "a", URL: "http://a"}, {Name: "a", URL: "http://b"}}}},
+		{"unknown notifier", Config{Checks: []Check{{Name: "a", URL: "http://a", Notify: []string{"missing"}}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notifiers, _ := alerting.NewSet(nil)
+			if _, err := New(tt.config, notifiers, slog.Default()); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
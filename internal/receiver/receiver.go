@@ -0,0 +1,220 @@
+package receiver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Consumer accepts spans from receivers. The collector implements it via
+// SubmitSpan, so every protocol shares the same worker pool.
+type Consumer interface {
+	SubmitSpan(span *models.Span) error
+}
+
+// Throttler is implemented by consumers that can signal overload back to
+// clients (see models.ThrottleHint).
+type Throttler interface {
+	ThrottleHint() *models.ThrottleHint
+}
+
+// ThrottleHintFor returns the consumer's current throttle hint, or nil if it
+// has none or does not implement Throttler.
+func ThrottleHintFor(consumer Consumer) *models.ThrottleHint {
+	if t, ok := consumer.(Throttler); ok {
+		return t.ThrottleHint()
+	}
+	return nil
+}
+
+// Receiver is a protocol listener (HTTP, gRPC, queue consumer, ...) that
+// pushes spans to a Consumer.
+type Receiver interface {
+	// Start begins receiving and must not block. Listener errors that can be
+	// detected up front (e.g. port in use) are returned.
+	Start(ctx context.Context, consumer Consumer) error
+
+	// Stop stops receiving and waits for in-flight requests to finish
+	Stop(ctx context.Context) error
+}
+
+// Factory builds a receiver from its raw JSON config (may be nil).
+type Factory func(config json.RawMessage, logger *slog.Logger) (Receiver, error)
+
+var (
+	registryMu sync.RWMutex
+	factories  = make(map[string]Factory)
+)
+
+// Register makes a receiver type available under name.
+// It panics if name is already registered.
+func Register(name string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if _, dup := factories[name]; dup {
+		panic("receiver: registered twice: " + name)
+	}
+	factories[name] = factory
+}
+
+// Registered returns the sorted names of all registered receiver types.
+func Registered() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Spec configures one receiver instance in the "receivers" section of the
+// collector config file. Name selects the registered type; ID distinguishes
+// several instances of the same type and defaults to Name.
+type Spec struct {
+	Name    string          `json:"name"`
+	ID      string          `json:"id,omitempty"`
+	Enabled *bool           `json:"enabled,omitempty"` // Defaults to true
+	Config  json.RawMessage `json:"config,omitempty"`
+}
+
+// Metrics are per-receiver ingestion counters.
+type Metrics struct {
+	Accepted int64 // Spans handed to the consumer
+	Rejected int64 // Spans the consumer refused (queue full, stopping)
+}
+
+// Manager runs a set of receivers with independent lifecycles and metrics.
+type Manager struct {
+	logger  *slog.Logger
+	running []*instance
+}
+
+type instance struct {
+	id       string
+	receiver Receiver
+	counter  *countingConsumer
+}
+
+// NewManager builds receivers for every enabled spec.
+func NewManager(specs []Spec, logger *slog.Logger) (*Manager, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	m := &Manager{logger: logger}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, spec := range specs {
+		if spec.Enabled != nil && !*spec.Enabled {
+			continue
+		}
+		id := spec.ID
+		if id == "" {
+			id = spec.Name
+		}
+		if seen[id] {
+			return nil, fmt.Errorf("duplicate receiver id %q", id)
+		}
+		seen[id] = true
+
+		factory, ok := factories[spec.Name]
+		if !ok {
+			return nil, fmt.Errorf("unknown receiver %q", spec.Name)
+		}
+		r, err := factory(spec.Config, logger.With("receiver", id))
+		if err != nil {
+			return nil, fmt.Errorf("receiver %q: %w", id, err)
+		}
+		m.running = append(m.running, &instance{id: id, receiver: r})
+	}
+	return m, nil
+}
+
+// Add registers an already-built receiver under id.
+func (m *Manager) Add(id string, r Receiver) {
+	m.running = append(m.running, &instance{id: id, receiver: r})
+}
+
+// Start starts every receiver. If one fails, the ones already started are stopped.
+func (m *Manager) Start(ctx context.Context, consumer Consumer) error {
+	for i, inst := range m.running {
+		inst.counter = &countingConsumer{next: consumer}
+		if err := inst.receiver.Start(ctx, inst.counter); err != nil {
+			for _, started := range m.running[:i] {
+				started.receiver.Stop(ctx)
+			}
+			return fmt.Errorf("receiver %q: %w", inst.id, err)
+		}
+		m.logger.Info("receiver started", "receiver", inst.id)
+	}
+	return nil
+}
+
+// Stop stops every receiver and returns the combined errors.
+func (m *Manager) Stop(ctx context.Context) error {
+	var errs []error
+	for _, inst := range m.running {
+		if err := inst.receiver.Stop(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("receiver %q: %w", inst.id, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Receivers returns the managed receivers keyed by ID.
+func (m *Manager) Receivers() map[string]Receiver {
+	result := make(map[string]Receiver, len(m.running))
+	for _, inst := range m.running {
+		result[inst.id] = inst.receiver
+	}
+	return result
+}
+
+// Metrics returns a snapshot of each started receiver's counters keyed by ID.
+func (m *Manager) Metrics() map[string]Metrics {
+	result := make(map[string]Metrics, len(m.running))
+	for _, inst := range m.running {
+		if inst.counter == nil {
+			continue
+		}
+		result[inst.id] = Metrics{
+			Accepted: atomic.LoadInt64(&inst.counter.accepted),
+			Rejected: atomic.LoadInt64(&inst.counter.rejected),
+		}
+	}
+	return result
+}
+
+// countingConsumer tracks per-receiver outcomes.
+type countingConsumer struct {
+	next     Consumer
+	accepted int64
+	rejected int64
+}
+
+func (c *countingConsumer) SubmitSpan(span *models.Span) error {
+	if err := c.next.SubmitSpan(span); err != nil {
+		atomic.AddInt64(&c.rejected, 1)
+		return err
+	}
+	atomic.AddInt64(&c.accepted, 1)
+	return nil
+}
+
+// ThrottleHint forwards to the wrapped consumer.
+func (c *countingConsumer) ThrottleHint() *models.ThrottleHint {
+	return ThrottleHintFor(c.next)
+}
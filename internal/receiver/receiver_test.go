@@ -0,0 +1,109 @@
+package receiver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// fakeReceiver pushes its configured number of spans on Start
+type fakeReceiver struct {
+	spans   int
+	stopped bool
+}
+
+func (r *fakeReceiver) Start(ctx context.Context, consumer Consumer) error {
+	for i := 0; i < r.spans; i++ {
+		consumer.SubmitSpan(&models.Span{})
+	}
+	return nil
+}
+
+func (r *fakeReceiver) Stop(ctx context.Context) error {
+	r.stopped = true
+	return nil
+}
+
+// limitedConsumer accepts up to capacity spans
+type limitedConsumer struct {
+	capacity int
+}
+
+func (c *limitedConsumer) SubmitSpan(span *models.Span) error {
+	if c.capacity == 0 {
+		return errors.New("queue full")
+	}
+	c.capacity--
+	return nil
+}
+
+var built []*fakeReceiver
+
+func init() {
+	Register("fake", func(raw json.RawMessage, logger *slog.Logger) (Receiver, error) {
+		var config struct {
+			Spans int `json:"spans"`
+		}
+		if len(raw) > 0 {
+			if err := json.Unmarshal(raw, &config); err != nil {
+				return nil, err
+			}
+		}
+		r := &fakeReceiver{spans: config.Spans}
+		built = append(built, r)
+		return r, nil
+	})
+}
+
+func TestManager_IndependentMetricsAndLifecycle(t *testing.T) {
+	built = nil
+	disabled := false
+	specs := []Spec{
+		{Name: "fake", ID: "a", Config: json.RawMessage(`{"spans": 2}`)},
+		{Name: "fake", ID: "b", Config: json.RawMessage(`{"spans": 3}`)},
+		{Name: "fake", ID: "off", Enabled: &disabled},
+	}
+
+	m, err := NewManager(specs, slog.Default())
+	if err != nil {
+		t.Fatalf("NewManager() error = %v", err)
+	}
+
+	ctx := context.Background()
+	if err := m.Start(ctx, &limitedConsumer{capacity: 4}); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	metrics := m.Metrics()
+	if len(metrics) != 2 {
+		t.Fatalf("got metrics for %d receivers, want 2", len(metrics))
+	}
+	if metrics["a"].Accepted != 2 || metrics["a"].Rejected != 0 {
+		t.Errorf("receiver a metrics = %+v", metrics["a"])
+	}
+	if metrics["b"].Accepted != 2 || metrics["b"].Rejected != 1 {
+		t.Errorf("receiver b metrics = %+v", metrics["b"])
+	}
+
+	if err := m.Stop(ctx); err != nil {
+		t.Fatalf("Stop() error = %v", err)
+	}
+	for _, r := range built {
+		if !r.stopped {
+			t.Error("receiver was not stopped")
+		}
+	}
+}
+
+func TestNewManager_Errors(t *testing.T) {
+	if _, err := NewManager([]Spec{{Name: "unknown"}}, nil); err == nil {
+		t.Error("expected error for unknown receiver")
+	}
+	if _, err := NewManager([]Spec{{Name: "fake"}, {Name: "fake"}}, nil); err == nil {
+		t.Error("expected error for duplicate receiver id")
+	}
+}
@@ -0,0 +1,131 @@
+// Package spanexport defines the streaming gRPC protocol the SDK uses to ship
+// spans to the collector. Messages are JSON-encoded with a registered gRPC
+// codec so the wire format matches the HTTP API and no generated code is needed.
+package spanexport
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// ServiceName is the fully qualified gRPC service name.
+const ServiceName = "traceflow.v1.SpanExport"
+
+// CodecName is the gRPC content-subtype used for all messages.
+const CodecName = "json"
+
+// Batch is one client->server message. Seq is chosen by the client and echoed
+// in the matching Ack so the client can release in-flight batches.
+type Batch struct {
+	Seq   uint64         `json:"seq"`
+	Spans []*models.Span `json:"spans"`
+}
+
+// Ack is the server->client response to a Batch.
+type Ack struct {
+	Seq      uint64 `json:"seq"`
+	Accepted int    `json:"accepted"`
+	Rejected int    `json:"rejected"` // Spans the collector refused (queue full, invalid)
+
+	// Throttle is set while the collector is saturated
+	Throttle *models.ThrottleHint `json:"throttle,omitempty"`
+}
+
+// Server handles an export stream.
+type Server interface {
+	Export(stream ServerStream) error
+}
+
+// ServerStream is the server side of an export stream.
+type ServerStream interface {
+	Recv() (*Batch, error)
+	Send(*Ack) error
+	Context() context.Context
+}
+
+// ClientStream is the client side of an export stream.
+type ClientStream interface {
+	Send(*Batch) error
+	Recv() (*Ack, error)
+	CloseSend() error
+}
+
+// RegisterServer registers srv on s.
+func RegisterServer(s *grpc.Server, srv Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// OpenStream opens a bidirectional export stream on conn.
+func OpenStream(ctx context.Context, conn grpc.ClientConnInterface) (ClientStream, error) {
+	stream, err := conn.NewStream(ctx, &serviceDesc.Streams[0], "/"+ServiceName+"/Export",
+		grpc.CallContentSubtype(CodecName))
+	if err != nil {
+		return nil, err
+	}
+	return &clientStream{stream}, nil
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: ServiceName,
+	HandlerType: (*Server)(nil),
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Export",
+			Handler:       exportHandler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+}
+
+func exportHandler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(Server).Export(&serverStream{stream})
+}
+
+type serverStream struct {
+	grpc.ServerStream
+}
+
+func (s *serverStream) Recv() (*Batch, error) {
+	batch := new(Batch)
+	if err := s.ServerStream.RecvMsg(batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (s *serverStream) Send(ack *Ack) error {
+	return s.ServerStream.SendMsg(ack)
+}
+
+type clientStream struct {
+	grpc.ClientStream
+}
+
+func (s *clientStream) Send(batch *Batch) error {
+	return s.ClientStream.SendMsg(batch)
+}
+
+func (s *clientStream) Recv() (*Ack, error) {
+	ack := new(Ack)
+	if err := s.ClientStream.RecvMsg(ack); err != nil {
+		return nil, err
+	}
+	return ack, nil
+}
+
+// codec marshals messages as JSON.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error)      { return json.Marshal(v) }
+func (codec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+func (codec) Name() string                               { return CodecName }
+
+func init() {
+	encoding.RegisterCodec(codec{})
+}
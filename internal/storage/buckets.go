@@ -0,0 +1,257 @@
+package storage
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+)
+
+// ExponentialPrefix introduces an exponential bucket spec in
+// ParseDurationBuckets and ParseCostBuckets: "exponential:START,FACTOR,COUNT".
+const ExponentialPrefix = "exponential:"
+
+// Default bucket specs: 18 bounds from 1ms to ~131s and 20 from 0.00001 to
+// ~5.2, doubling each time, so percentile estimates are within a factor of 2.
+const (
+	DefaultDurationBucketSpec = ExponentialPrefix + "1ms,2,18"
+	DefaultCostBucketSpec     = ExponentialPrefix + "0.00001,2,20"
+)
+
+// Bounds of the default bucket specs
+var (
+	DefaultDurationBuckets, _ = ParseDurationBuckets(DefaultDurationBucketSpec)
+	DefaultCostBuckets, _     = ParseCostBuckets(DefaultCostBucketSpec)
+)
+
+// DefaultCurrency is the unit costs are reported in unless configured.
+const DefaultCurrency = "USD"
+
+// histogramIndex buckets trace IDs by a value. The histogram holding the
+// bucket counts is the one percentiles are estimated from, so the index used
+// to narrow queries and the statistics reported about traces never disagree.
+type histogramIndex struct {
+	hist   *histogram.Histogram
+	traces []traceSet         // len(bounds)+1 buckets of trace IDs
+	values map[string]float64 // traceID -> indexed value
+}
+
+func newHistogramIndex(bounds []float64) histogramIndex {
+	return histogramIndex{
+		hist:   histogram.New(bounds),
+		traces: make([]traceSet, len(bounds)+1),
+		values: make(map[string]float64),
+	}
+}
+
+// add indexes a trace, moving it if it was indexed with another value.
+func (x *histogramIndex) add(traceID string, v float64) {
+	if old, ok := x.values[traceID]; ok {
+		if old == v {
+			return
+		}
+		x.remove(traceID)
+	}
+	i := x.hist.Bucket(v)
+	x.traces[i] = x.traces[i].add(traceID)
+	x.hist.Observe(v)
+	x.values[traceID] = v
+}
+
+// remove drops a trace from the index.
+func (x *histogramIndex) remove(traceID string) {
+	v, ok := x.values[traceID]
+	if !ok {
+		return
+	}
+	delete(x.traces[x.hist.Bucket(v)], traceID)
+	x.hist.Remove(v)
+	delete(x.values, traceID)
+}
+
+// DurationBuckets categorizes traces by duration for efficient duration
+// queries and percentile estimates. Bucket i holds traces shorter than
+// bounds[i] (and at least bounds[i-1]); the last bucket holds everything at
+// or above the final bound.
+type DurationBuckets struct {
+	histogramIndex
+}
+
+// NewDurationBuckets builds buckets from ascending, positive upper bounds.
+// Batch workloads may want e.g. 1s, 1m, 10m where RPCs want 10ms, 100ms, 1s.
+func NewDurationBuckets(bounds []time.Duration) (*DurationBuckets, error) {
+	seconds := make([]float64, len(bounds))
+	for i, bound := range bounds {
+		seconds[i] = bound.Seconds()
+	}
+	if err := histogram.ValidateBounds(seconds); err != nil {
+		return nil, err
+	}
+	return &DurationBuckets{newHistogramIndex(seconds)}, nil
+}
+
+// ExponentialDurationBuckets returns count bounds starting at start and
+// growing by factor.
+func ExponentialDurationBuckets(start time.Duration, factor float64, count int) ([]time.Duration, error) {
+	seconds, err := histogram.Exponential(start.Seconds(), factor, count)
+	if err != nil {
+		return nil, err
+	}
+	bounds := make([]time.Duration, len(seconds))
+	for i, s := range seconds {
+		bounds[i] = secondsToDuration(s)
+	}
+	return bounds, nil
+}
+
+// ParseDurationBuckets parses comma-separated bucket bounds, e.g.
+// "10ms,100ms,1s", or an exponential spec such as "exponential:1ms,2,18".
+func ParseDurationBuckets(s string) ([]time.Duration, error) {
+	if spec, ok := strings.CutPrefix(s, ExponentialPrefix); ok {
+		start, factor, count, err := parseExponential(spec, func(field string) (float64, error) {
+			d, err := time.ParseDuration(field)
+			return d.Seconds(), err
+		})
+		if err != nil {
+			return nil, err
+		}
+		return ExponentialDurationBuckets(secondsToDuration(start), factor, count)
+	}
+
+	var bounds []time.Duration
+	for _, field := range strings.Split(s, ",") {
+		bound, err := time.ParseDuration(strings.TrimSpace(field))
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket bound %q", field)
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
+// newDefaultDurationBuckets returns buckets with DefaultDurationBuckets bounds.
+func newDefaultDurationBuckets() *DurationBuckets {
+	b, _ := NewDurationBuckets(DefaultDurationBuckets)
+	return b
+}
+
+// Bounds returns a copy of the bucket upper bounds.
+func (b *DurationBuckets) Bounds() []time.Duration {
+	bounds := make([]time.Duration, len(b.hist.Bounds()))
+	for i, s := range b.hist.Bounds() {
+		bounds[i] = secondsToDuration(s)
+	}
+	return bounds
+}
+
+// Label names bucket i by its range, e.g. "10ms-100ms" or ">=1s".
+func (b *DurationBuckets) Label(i int) string {
+	bounds := b.Bounds()
+	switch {
+	case i == 0:
+		return "<" + bounds[0].String()
+	case i >= len(bounds):
+		return ">=" + bounds[len(bounds)-1].String()
+	default:
+		return bounds[i-1].String() + "-" + bounds[i].String()
+	}
+}
+
+// Percentile estimates the q-quantile (0 <= q <= 1) of indexed trace durations.
+func (b *DurationBuckets) Percentile(q float64) time.Duration {
+	return secondsToDuration(b.hist.Quantile(q))
+}
+
+// CostBuckets categorizes traces by cost for efficient cost queries and
+// percentile estimates. Bounds are in the store's currency and laid out like
+// DurationBuckets.
+type CostBuckets struct {
+	histogramIndex
+}
+
+// NewCostBuckets builds buckets from ascending, positive upper bounds.
+func NewCostBuckets(bounds []float64) (*CostBuckets, error) {
+	if err := histogram.ValidateBounds(bounds); err != nil {
+		return nil, err
+	}
+	return &CostBuckets{newHistogramIndex(append([]float64(nil), bounds...))}, nil
+}
+
+// ParseCostBuckets parses comma-separated bucket bounds, e.g. "0.0001,0.001",
+// or an exponential spec such as "exponential:0.00001,2,20".
+func ParseCostBuckets(s string) ([]float64, error) {
+	if spec, ok := strings.CutPrefix(s, ExponentialPrefix); ok {
+		start, factor, count, err := parseExponential(spec, func(field string) (float64, error) {
+			return strconv.ParseFloat(field, 64)
+		})
+		if err != nil {
+			return nil, err
+		}
+		return histogram.Exponential(start, factor, count)
+	}
+
+	var bounds []float64
+	for _, field := range strings.Split(s, ",") {
+		bound, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid bucket bound %q", field)
+		}
+		bounds = append(bounds, bound)
+	}
+	return bounds, nil
+}
+
+// newDefaultCostBuckets returns buckets with DefaultCostBuckets bounds.
+func newDefaultCostBuckets() *CostBuckets {
+	b, _ := NewCostBuckets(DefaultCostBuckets)
+	return b
+}
+
+// Bounds returns a copy of the bucket upper bounds.
+func (b *CostBuckets) Bounds() []float64 {
+	return append([]float64(nil), b.hist.Bounds()...)
+}
+
+// Label names bucket i by its range, e.g. "0.0001-0.001" or ">=0.001".
+func (b *CostBuckets) Label(i int) string {
+	bounds := b.hist.Bounds()
+	format := func(f float64) string { return strconv.FormatFloat(f, 'g', -1, 64) }
+	switch {
+	case i == 0:
+		return "<" + format(bounds[0])
+	case i >= len(bounds):
+		return ">=" + format(bounds[len(bounds)-1])
+	default:
+		return format(bounds[i-1]) + "-" + format(bounds[i])
+	}
+}
+
+// Percentile estimates the q-quantile (0 <= q <= 1) of indexed trace costs.
+func (b *CostBuckets) Percentile(q float64) float64 {
+	return b.hist.Quantile(q)
+}
+
+// parseExponential parses "START,FACTOR,COUNT" with START read by parseStart.
+func parseExponential(spec string, parseStart func(string) (float64, error)) (start, factor float64, count int, err error) {
+	fields := strings.Split(spec, ",")
+	if len(fields) != 3 {
+		return 0, 0, 0, fmt.Errorf("invalid exponential buckets %q, want %sSTART,FACTOR,COUNT", spec, ExponentialPrefix)
+	}
+	if start, err = parseStart(strings.TrimSpace(fields[0])); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid start %q", fields[0])
+	}
+	if factor, err = strconv.ParseFloat(strings.TrimSpace(fields[1]), 64); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid factor %q", fields[1])
+	}
+	if count, err = strconv.Atoi(strings.TrimSpace(fields[2])); err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid count %q", fields[2])
+	}
+	return start, factor, count, nil
+}
+
+// secondsToDuration converts float seconds, rounding to the nearest nanosecond.
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds*float64(time.Second) + 0.5)
+}
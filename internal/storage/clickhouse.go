@@ -0,0 +1,902 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Defaults for ClickHouseConfig
+const (
+	DefaultClickHouseTable         = "spans"
+	DefaultClickHouseBatchSize     = 10000
+	DefaultClickHouseFlushInterval = time.Second
+	DefaultClickHouseQueueSize     = 100000
+)
+
+// ErrClickHouseQueueFull is returned by WriteSpan while inserts lag behind
+// writes and the span queue is full.
+var ErrClickHouseQueueFull = errors.New("clickhouse insert queue is full")
+
+// ClickHouseConfig configures a "clickhouse" backend.
+type ClickHouseConfig struct {
+	URL           string `json:"url"`                      // HTTP interface, e.g. "http://clickhouse:8123"
+	Database      string `json:"database,omitempty"`       // Default "default"
+	Table         string `json:"table,omitempty"`          // Default "spans"; created if missing
+	Username      string `json:"username,omitempty"`       // Default user when empty
+	Password      string `json:"password,omitempty"`       // Password of Username
+	BatchSize     int    `json:"batch_size,omitempty"`     // Spans per insert (default 10000)
+	FlushInterval string `json:"flush_interval,omitempty"` // Longest a span waits for its batch (default "1s")
+	QueueSize     int    `json:"queue_size,omitempty"`     // Spans waiting to be inserted before writes fail (default 100000)
+	Retention     string `json:"retention,omitempty"`      // Table TTL, e.g. "720h" (empty = keep forever)
+	Currency      string `json:"currency,omitempty"`       // Default "USD"
+	Timeout       string `json:"timeout,omitempty"`        // Per request (default "30s")
+}
+
+// identifierPattern matches database and table names safe to use unquoted.
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func init() {
+	RegisterBackend("clickhouse", func(config json.RawMessage) (Store, error) {
+		var cfg ClickHouseConfig
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid config: %w", err)
+			}
+		}
+		return NewClickHouseStore(context.Background(), cfg)
+	})
+}
+
+// ClickHouseStore keeps spans in a ClickHouse table, for trace volumes far
+// beyond what fits in memory. Each span is a row in a columnar table
+// partitioned by day and ordered by trace ID, so a trace is read from one
+// place and old partitions drop off cheaply. Writes are queued and inserted
+// in batches in the background; a span becomes readable once its batch is
+// inserted, within FlushInterval. Queries run as SQL: duration, cost, time
+// and tag filters, sorting and pagination all happen in ClickHouse, which
+// returns only the page of traces asked for.
+//
+// Writes are upserts: the table is a ReplacingMergeTree keyed by trace and
+// span ID, and reads use FINAL so the latest version of a span wins before
+// ClickHouse has merged the older one away.
+type ClickHouseStore struct {
+	endpoint  string // Base URL of the HTTP interface
+	table     string // database.table
+	username  string
+	password  string
+	retention time.Duration
+	currency  string
+	client    *http.Client
+
+	batchSize     int
+	flushInterval time.Duration
+
+	// queue feeds the inserter; closed (under mu) by Close
+	mu     sync.RWMutex
+	closed bool
+	queue  chan *models.Span
+	done   chan struct{}
+
+	// Set by the inserter, reported by Close
+	insertErr error
+
+	stats struct {
+		batches, inserted, dropped atomic.Int64
+	}
+}
+
+// ClickHouseStats counts a ClickHouseStore's inserts.
+type ClickHouseStats struct {
+	Batches  int64 // Inserts that succeeded
+	Inserted int64 // Spans in them
+	Dropped  int64 // Spans in inserts that failed after retries
+	Queued   int   // Spans waiting to be inserted
+}
+
+// NewClickHouseStore connects to ClickHouse, creates the span table if it
+// does not exist and starts the background inserter.
+func NewClickHouseStore(ctx context.Context, config ClickHouseConfig) (*ClickHouseStore, error) {
+	if config.URL == "" {
+		return nil, errors.New("url is required")
+	}
+	endpoint, err := url.Parse(config.URL)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid url %q", config.URL)
+	}
+	if config.Database == "" {
+		config.Database = "default"
+	}
+	if config.Table == "" {
+		config.Table = DefaultClickHouseTable
+	}
+	if !identifierPattern.MatchString(config.Database) {
+		return nil, fmt.Errorf("invalid database %q", config.Database)
+	}
+	if !identifierPattern.MatchString(config.Table) {
+		return nil, fmt.Errorf("invalid table %q", config.Table)
+	}
+	if config.BatchSize < 0 || config.QueueSize < 0 {
+		return nil, errors.New("batch_size and queue_size must not be negative")
+	}
+	if config.BatchSize == 0 {
+		config.BatchSize = DefaultClickHouseBatchSize
+	}
+	if config.QueueSize == 0 {
+		config.QueueSize = DefaultClickHouseQueueSize
+	}
+	if config.Currency == "" {
+		config.Currency = DefaultCurrency
+	}
+	flushInterval := DefaultClickHouseFlushInterval
+	if config.FlushInterval != "" {
+		if flushInterval, err = time.ParseDuration(config.FlushInterval); err != nil || flushInterval <= 0 {
+			return nil, fmt.Errorf("invalid flush_interval %q", config.FlushInterval)
+		}
+	}
+	var retention time.Duration
+	if config.Retention != "" {
+		if retention, err = time.ParseDuration(config.Retention); err != nil || retention < time.Second {
+			return nil, fmt.Errorf("invalid retention %q", config.Retention)
+		}
+	}
+	timeout := 30 * time.Second
+	if config.Timeout != "" {
+		if timeout, err = time.ParseDuration(config.Timeout); err != nil || timeout <= 0 {
+			return nil, fmt.Errorf("invalid timeout %q", config.Timeout)
+		}
+	}
+
+	s := &ClickHouseStore{
+		endpoint:      strings.TrimSuffix(config.URL, "/"),
+		table:         config.Database + "." + config.Table,
+		username:      config.Username,
+		password:      config.Password,
+		retention:     retention,
+		currency:      config.Currency,
+		client:        &http.Client{Timeout: timeout},
+		batchSize:     config.BatchSize,
+		flushInterval: flushInterval,
+		queue:         make(chan *models.Span, config.QueueSize),
+		done:          make(chan struct{}),
+	}
+	if err := s.exec(ctx, s.createTableSQL(), nil, nil); err != nil {
+		return nil, fmt.Errorf("create table %s: %w", s.table, err)
+	}
+	go s.insertLoop()
+	return s, nil
+}
+
+// createTableSQL is the span table's schema. Low-cardinality columns are
+// dictionary encoded; the skip indexes let queries by time, service and tag
+// skip granules that cannot match.
+func (s *ClickHouseStore) createTableSQL() string {
+	ttl := ""
+	if s.retention > 0 {
+		ttl = fmt.Sprintf("\nTTL toDateTime(start_time) + INTERVAL %d SECOND", int64(s.retention/time.Second))
+	}
+	return `CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	trace_id String,
+	span_id String,
+	parent_span_id String,
+	service LowCardinality(String),
+	operation LowCardinality(String),
+	kind LowCardinality(String),
+	status LowCardinality(String),
+	status_message String,
+	start_time DateTime64(9, 'UTC'),
+	duration_ns Int64,
+	in_progress Bool,
+	tags Map(String, String),
+	events String,
+	deployment_id LowCardinality(String),
+	git_sha LowCardinality(String),
+	environment LowCardinality(String),
+	cost Float64,
+	has_profile Bool,
+	profile_id String,
+	version UInt64,
+	INDEX idx_start_time start_time TYPE minmax GRANULARITY 1,
+	INDEX idx_service service TYPE set(1000) GRANULARITY 4,
+	INDEX idx_tag_keys mapKeys(tags) TYPE bloom_filter GRANULARITY 4,
+	INDEX idx_tag_values mapValues(tags) TYPE bloom_filter GRANULARITY 4
+)
+ENGINE = ReplacingMergeTree(version)
+PARTITION BY toDate(start_time)
+ORDER BY (trace_id, span_id)` + ttl
+}
+
+// clickHouseSpan is a span as a row of the span table.
+type clickHouseSpan struct {
+	TraceID       string            `json:"trace_id"`
+	SpanID        string            `json:"span_id"`
+	ParentSpanID  string            `json:"parent_span_id"`
+	Service       string            `json:"service"`
+	Operation     string            `json:"operation"`
+	Kind          string            `json:"kind"`
+	Status        string            `json:"status"`
+	StatusMessage string            `json:"status_message"`
+	StartTime     string            `json:"start_time,omitempty"` // Written as text
+	StartNanos    int64             `json:"start_ns,omitempty"`   // Read as Unix nanoseconds
+	DurationNanos int64             `json:"duration_ns"`
+	InProgress    bool              `json:"in_progress"`
+	Tags          map[string]string `json:"tags"`
+	Events        string            `json:"events"`
+	DeploymentID  string            `json:"deployment_id"`
+	GitSHA        string            `json:"git_sha"`
+	Environment   string            `json:"environment"`
+	Cost          float64           `json:"cost"`
+	HasProfile    bool              `json:"has_profile"`
+	ProfileID     string            `json:"profile_id"`
+	Version       uint64            `json:"version,omitempty"`
+}
+
+// clickHouseTimeFormat is how DateTime64(9) values are written.
+const clickHouseTimeFormat = "2006-01-02 15:04:05.000000000"
+
+// spanColumns are the columns read back into a span; metadata projections
+// skip tags and events, the widest of them.
+func spanColumns(projection Projection) string {
+	columns := "trace_id, span_id, parent_span_id, service, operation, kind, status, status_message, " +
+		"toUnixTimestamp64Nano(start_time) AS start_ns, duration_ns, in_progress, deployment_id, git_sha, " +
+		"environment, cost, has_profile, profile_id"
+	if projection != ProjectMetadata {
+		columns += ", tags, events"
+	}
+	return columns
+}
+
+func toClickHouseSpan(span *models.Span, version uint64) (*clickHouseSpan, error) {
+	events := ""
+	if len(span.Events) > 0 {
+		data, err := json.Marshal(span.Events)
+		if err != nil {
+			return nil, err
+		}
+		events = string(data)
+	}
+	tags := span.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return &clickHouseSpan{
+		TraceID:       span.TraceID,
+		SpanID:        span.SpanID,
+		ParentSpanID:  span.ParentSpanID,
+		Service:       span.ServiceName,
+		Operation:     span.OperationName,
+		Kind:          span.SpanKind,
+		Status:        span.Status,
+		StatusMessage: span.StatusMessage,
+		StartTime:     span.StartTime.UTC().Format(clickHouseTimeFormat),
+		DurationNanos: int64(span.Duration),
+		InProgress:    span.InProgress,
+		Tags:          tags,
+		Events:        events,
+		DeploymentID:  span.DeploymentID,
+		GitSHA:        span.GitSHA,
+		Environment:   span.Environment,
+		Cost:          span.Cost,
+		HasProfile:    span.HasProfile,
+		ProfileID:     span.ProfileID,
+		Version:       version,
+	}, nil
+}
+
+func (row *clickHouseSpan) span() (models.Span, error) {
+	span := models.Span{
+		TraceID:       row.TraceID,
+		SpanID:        row.SpanID,
+		ParentSpanID:  row.ParentSpanID,
+		ServiceName:   row.Service,
+		OperationName: row.Operation,
+		SpanKind:      row.Kind,
+		Status:        row.Status,
+		StatusMessage: row.StatusMessage,
+		StartTime:     time.Unix(0, row.StartNanos).UTC(),
+		Duration:      time.Duration(row.DurationNanos),
+		InProgress:    row.InProgress,
+		DeploymentID:  row.DeploymentID,
+		GitSHA:        row.GitSHA,
+		Environment:   row.Environment,
+		Cost:          row.Cost,
+		HasProfile:    row.HasProfile,
+		ProfileID:     row.ProfileID,
+	}
+	if len(row.Tags) > 0 {
+		span.Tags = row.Tags
+	}
+	if row.Events != "" {
+		if err := json.Unmarshal([]byte(row.Events), &span.Events); err != nil {
+			return span, fmt.Errorf("span %s: invalid events: %w", row.SpanID, err)
+		}
+	}
+	return span, nil
+}
+
+// WriteSpan validates the span and queues it for insertion. It fails with
+// ErrClickHouseQueueFull rather than block while ClickHouse falls behind.
+func (s *ClickHouseStore) WriteSpan(ctx context.Context, span *models.Span) error {
+	if err := span.Validate(); err != nil {
+		return fmt.Errorf("invalid span: %w", err)
+	}
+	copied := *span
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errors.New("clickhouse store is closed")
+	}
+	select {
+	case s.queue <- &copied:
+		return nil
+	default:
+		return ErrClickHouseQueueFull
+	}
+}
+
+// insertLoop inserts queued spans in batches of batchSize, or whatever has
+// queued up every flushInterval, until the queue is closed.
+func (s *ClickHouseStore) insertLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.Span, 0, s.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			s.insertErr = s.insert(batch)
+			batch = batch[:0]
+		}
+	}
+	for {
+		select {
+		case span, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= s.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// insertRetries are the waits between attempts of a failed insert.
+var insertRetries = []time.Duration{100 * time.Millisecond, time.Second, 5 * time.Second}
+
+// insert writes one batch as JSONEachRow, retrying failures. Rows are
+// versioned by insert time so the latest write of a span wins.
+func (s *ClickHouseStore) insert(batch []*models.Span) error {
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	version := uint64(time.Now().UnixNano())
+	rows := 0
+	for i, span := range batch {
+		// Spans of one batch are versioned in queue order
+		row, err := toClickHouseSpan(span, version+uint64(i))
+		if err == nil {
+			err = encoder.Encode(row)
+		}
+		if err != nil {
+			s.stats.dropped.Add(1)
+			continue
+		}
+		rows++
+	}
+	if rows == 0 {
+		return nil
+	}
+
+	query := "INSERT INTO " + s.table + " (trace_id, span_id, parent_span_id, service, operation, kind, status, " +
+		"status_message, start_time, duration_ns, in_progress, tags, events, deployment_id, git_sha, environment, " +
+		"cost, has_profile, profile_id, version) FORMAT JSONEachRow"
+	data := body.Bytes()
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = s.exec(context.Background(), query, nil, data); err == nil {
+			s.stats.batches.Add(1)
+			s.stats.inserted.Add(int64(rows))
+			return nil
+		}
+		if attempt == len(insertRetries) {
+			break
+		}
+		time.Sleep(insertRetries[attempt])
+	}
+	s.stats.dropped.Add(int64(rows))
+	return fmt.Errorf("insert %d spans: %w", rows, err)
+}
+
+// Stats returns insert counters.
+func (s *ClickHouseStore) Stats() ClickHouseStats {
+	return ClickHouseStats{
+		Batches:  s.stats.batches.Load(),
+		Inserted: s.stats.inserted.Load(),
+		Dropped:  s.stats.dropped.Load(),
+		Queued:   len(s.queue),
+	}
+}
+
+// GetTrace reads every span of the trace.
+func (s *ClickHouseStore) GetTrace(ctx context.Context, traceID string) (*models.Trace, error) {
+	traces, err := s.readTraces(ctx, []string{traceID}, ProjectFull)
+	if err != nil {
+		return nil, err
+	}
+	trace := traces[traceID]
+	if trace == nil || (trace.ExpiresAt != nil && !trace.ExpiresAt.After(time.Now())) {
+		return nil, nil // Not found, or past retention but not removed yet
+	}
+	return trace, nil
+}
+
+// FindTraces finds matching traces in two queries. The first aggregates each
+// candidate trace's spans, filters and sorts the traces and returns one page
+// of trace IDs; the second reads that page's spans.
+func (s *ClickHouseStore) FindTraces(ctx context.Context, query *Query) ([]*models.Trace, error) {
+	sql, params := s.findSQL(query, time.Now())
+	var ids []string
+	err := s.exec(ctx, sql, params, nil, func(line []byte) error {
+		var row struct {
+			TraceID string `json:"trace_id"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		ids = append(ids, row.TraceID)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Without a limit the offset is applied here (see findSQL)
+	if query.Limit <= 0 {
+		if query.Offset >= len(ids) {
+			return []*models.Trace{}, nil
+		}
+		ids = ids[query.Offset:]
+	}
+	if len(ids) == 0 {
+		return []*models.Trace{}, nil
+	}
+
+	traces, err := s.readTraces(ctx, ids, query.Projection)
+	if err != nil {
+		return nil, err
+	}
+	page := make([]*models.Trace, 0, len(ids))
+	for _, id := range ids {
+		if trace := traces[id]; trace != nil {
+			page = append(page, trace)
+		}
+	}
+	return page, nil
+}
+
+// clickHouseSortColumns are the trace aggregates each sort key orders by.
+var clickHouseSortColumns = map[string]string{
+	SortByStartTime: "t_start",
+	SortByDuration:  "t_duration",
+	SortByCost:      "t_cost",
+	SortBySpanCount: "t_spans",
+}
+
+// findSQL builds the trace search for query: the matching traces, sorted
+// and paginated.
+func (s *ClickHouseStore) findSQL(query *Query, now time.Time) (string, url.Values) {
+	sql, params := s.matchSQL(query, now)
+
+	column, ok := clickHouseSortColumns[query.SortBy]
+	if !ok {
+		column = clickHouseSortColumns[SortByStartTime]
+	}
+	order := "DESC"
+	if query.SortOrder == SortAsc {
+		order = "ASC"
+	}
+	// Ties as in Query.SortTraces, so pages are stable
+	sql += " ORDER BY " + column + " " + order + ", t_start DESC, trace_id ASC"
+	if query.Limit > 0 {
+		sql += fmt.Sprintf(" LIMIT %d OFFSET %d", query.Limit, max(query.Offset, 0))
+	}
+	return sql, params
+}
+
+// matchSQL selects the traces matching query's filters, with their start
+// time, duration, cost and span count. Trace-level filters (duration,
+// cost, time range, errors, tags...) hold for a trace's spans as a whole, so
+// they are HAVING conditions on the aggregated spans. The spans read are
+// narrowed first: each filter that some span of a matching trace must meet
+// on its own becomes a "trace_id IN (...)" subquery, which the skip indexes
+// and the table's trace ID order make cheap. The time range narrows every
+// subquery too, since all spans of a trace start at or after it does, which
+// lets ClickHouse skip whole partitions.
+func (s *ClickHouseStore) matchSQL(query *Query, now time.Time) (string, url.Values) {
+	params := url.Values{}
+	param := func(name, typ string, value string) string {
+		params.Set("param_"+name, value)
+		return "{" + name + ":" + typ + "}"
+	}
+
+	// Span conditions for the subqueries
+	var since string
+	if !query.StartTime.IsZero() {
+		since = "start_time >= fromUnixTimestamp64Nano(" + param("start", "Int64", nanos(query.StartTime)) + ", 'UTC')"
+	}
+	var prefilters []string
+	prefilter := func(condition string) {
+		if since != "" {
+			condition = since + " AND " + condition
+		}
+		prefilters = append(prefilters, "trace_id IN (SELECT trace_id FROM "+s.table+" WHERE "+condition+")")
+	}
+
+	// Trace conditions on the aggregates
+	var having []string
+	if !query.StartTime.IsZero() {
+		having = append(having, "t_start >= {start:Int64}")
+	}
+	if !query.EndTime.IsZero() {
+		end := param("end", "Int64", nanos(query.EndTime))
+		having = append(having, "t_start <= "+end)
+		// The trace's first span starts within the range
+		prefilter("start_time <= fromUnixTimestamp64Nano(" + end + ", 'UTC')")
+	}
+	if query.Service != "" {
+		condition := "service = " + param("service", "String", query.Service)
+		prefilter(condition)
+		having = append(having, "countIf("+condition+") > 0")
+	}
+	if query.MinDuration > 0 {
+		having = append(having, "t_duration >= "+param("min_duration", "Int64", strconv.FormatInt(int64(query.MinDuration), 10)))
+	}
+	if query.MaxDuration > 0 {
+		having = append(having, "t_duration <= "+param("max_duration", "Int64", strconv.FormatInt(int64(query.MaxDuration), 10)))
+	}
+	if query.MinCost > 0 {
+		having = append(having, "t_cost >= "+param("min_cost", "Float64", strconv.FormatFloat(query.MinCost, 'g', -1, 64)))
+	}
+	if query.MaxCost > 0 {
+		having = append(having, "t_cost <= "+param("max_cost", "Float64", strconv.FormatFloat(query.MaxCost, 'g', -1, 64)))
+	}
+	if query.ErrorsOnly {
+		prefilter("status = 'error'")
+		having = append(having, "countIf(status = 'error') > 0")
+	}
+	keys := make([]string, 0, len(query.Tags))
+	for key := range query.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for i, key := range keys {
+		name := "tag" + strconv.Itoa(i)
+		condition := "mapContains(tags, " + param(name, "String", key) + ")"
+		if want := query.Tags[key]; want != "" {
+			condition = "tags[{" + name + ":String}] = " + param(name+"_value", "String", want)
+		}
+		prefilter(condition)
+		having = append(having, "countIf("+condition+") > 0")
+	}
+	if query.HasProfile != nil {
+		if *query.HasProfile {
+			prefilter("has_profile")
+			having = append(having, "countIf(has_profile) > 0")
+		} else {
+			having = append(having, "countIf(has_profile) = 0")
+		}
+	}
+	if query.InProgress != nil {
+		if *query.InProgress {
+			prefilter("in_progress")
+			having = append(having, "countIf(in_progress) > 0")
+		} else {
+			having = append(having, "countIf(in_progress) = 0")
+		}
+	}
+	if s.retention > 0 {
+		// Past retention but not removed by the TTL yet, or expiring within MinTTL
+		cutoff := now.Add(max(query.MinTTL, 0) - s.retention)
+		having = append(having, "t_start >= "+param("cutoff", "Int64", nanos(cutoff)))
+	}
+
+	if len(prefilters) == 0 && since != "" {
+		prefilters = append(prefilters, "trace_id IN (SELECT trace_id FROM "+s.table+" WHERE "+since+")")
+	}
+
+	var sql strings.Builder
+	sql.WriteString("SELECT trace_id, min(toUnixTimestamp64Nano(start_time)) AS t_start, " +
+		"max(toUnixTimestamp64Nano(start_time) + duration_ns) - t_start AS t_duration, " +
+		"sum(cost) AS t_cost, count() AS t_spans FROM " + s.table + " FINAL")
+	if len(prefilters) > 0 {
+		sql.WriteString(" WHERE " + strings.Join(prefilters, " AND "))
+	}
+	sql.WriteString(" GROUP BY trace_id")
+	if len(having) > 0 {
+		sql.WriteString(" HAVING " + strings.Join(having, " AND "))
+	}
+	return sql.String(), params
+}
+
+// readTraces reads the spans of traces and assembles them, by trace ID.
+func (s *ClickHouseStore) readTraces(ctx context.Context, traceIDs []string, projection Projection) (map[string]*models.Trace, error) {
+	params := url.Values{"param_ids": {clickHouseArray(traceIDs)}}
+	sql := "SELECT " + spanColumns(projection) + " FROM " + s.table +
+		" FINAL WHERE trace_id IN {ids:Array(String)} ORDER BY trace_id, start_time"
+
+	spans := make(map[string][]models.Span, len(traceIDs))
+	err := s.exec(ctx, sql, params, nil, func(line []byte) error {
+		var row clickHouseSpan
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		span, err := row.span()
+		if err != nil {
+			return err
+		}
+		spans[span.TraceID] = append(spans[span.TraceID], span)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	traces := make(map[string]*models.Trace, len(spans))
+	for traceID, traceSpans := range spans {
+		traces[traceID] = BuildTrace(traceID, traceSpans, s.currency, s.retention)
+	}
+	return traces, nil
+}
+
+// CountTraces counts the matching traces in ClickHouse.
+func (s *ClickHouseStore) CountTraces(ctx context.Context, query *Query) (int, error) {
+	sql, params := s.matchSQL(query, time.Now())
+	total := 0
+	err := s.exec(ctx, "SELECT count() AS total FROM ("+sql+")", params, nil, func(line []byte) error {
+		var row struct {
+			Total int `json:"total"`
+		}
+		err := json.Unmarshal(line, &row)
+		total = row.Total
+		return err
+	})
+	return total, err
+}
+
+// GetServiceStats aggregates the selected spans per service in ClickHouse.
+func (s *ClickHouseStore) GetServiceStats(ctx context.Context, query *StatsQuery) ([]ServiceStats, error) {
+	stats, err := s.spanStats(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	where, params := s.statsWhere(query)
+	sql := "SELECT service, uniqExact(trace_id) AS traces FROM " + s.table + " FINAL" + where + " GROUP BY service"
+	err = s.exec(ctx, sql, params, nil, func(line []byte) error {
+		var row struct {
+			Service string `json:"service"`
+			Traces  int64  `json:"traces"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		stats.traces[row.Service] = row.Traces
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats.serviceStats(), nil
+}
+
+// GetOperationStats aggregates the selected spans per operation in ClickHouse.
+func (s *ClickHouseStore) GetOperationStats(ctx context.Context, query *StatsQuery) ([]OperationStats, error) {
+	stats, err := s.spanStats(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	return stats.operationStats(), nil
+}
+
+// spanStats has ClickHouse count the selected spans per operation and
+// StatsDurationBounds bucket, and rebuilds the duration histograms from the
+// counts, so no span leaves the database.
+func (s *ClickHouseStore) spanStats(ctx context.Context, query *StatsQuery) (*statsAccumulator, error) {
+	where, params := s.statsWhere(query)
+	bounds := make([]string, len(StatsDurationBounds))
+	for i, bound := range StatsDurationBounds {
+		bounds[i] = strconv.FormatInt(int64(secondsToDuration(bound)), 10)
+	}
+	params.Set("param_bounds", "["+strings.Join(bounds, ",")+"]")
+
+	sql := "SELECT service, operation, arrayFirstIndex(b -> duration_ns < b, {bounds:Array(Int64)}) AS bucket, " +
+		"count() AS spans, countIf(status = 'error') AS errors, sum(cost) AS cost, sum(duration_ns) AS total_ns FROM " +
+		s.table + " FINAL" + where + " GROUP BY service, operation, bucket"
+
+	stats := newStatsAccumulator()
+	err := s.exec(ctx, sql, params, nil, func(line []byte) error {
+		var row struct {
+			Service   string  `json:"service"`
+			Operation string  `json:"operation"`
+			Bucket    int     `json:"bucket"` // 1-based; 0 past the last bound
+			Spans     int64   `json:"spans"`
+			Errors    int64   `json:"errors"`
+			Cost      float64 `json:"cost"`
+			TotalNs   int64   `json:"total_ns"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		bucket := len(StatsDurationBounds)
+		if row.Bucket > 0 && row.Bucket <= len(StatsDurationBounds) {
+			bucket = row.Bucket - 1
+		}
+		op := stats.operation(row.Service, row.Operation)
+		op.Spans += row.Spans
+		op.Errors += row.Errors
+		op.TotalCost += row.Cost
+		op.Durations.ObserveBucket(bucket, uint64(row.Spans), time.Duration(row.TotalNs).Seconds())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// statsWhere is the WHERE clause selecting a stats query's spans.
+func (s *ClickHouseStore) statsWhere(query *StatsQuery) (string, url.Values) {
+	params := url.Values{}
+	conditions := []string{"NOT in_progress"}
+	if query.Service != "" {
+		params.Set("param_service", query.Service)
+		conditions = append(conditions, "service = {service:String}")
+	}
+	if !query.StartTime.IsZero() {
+		params.Set("param_start", nanos(query.StartTime))
+		conditions = append(conditions, "start_time >= fromUnixTimestamp64Nano({start:Int64}, 'UTC')")
+	}
+	if !query.EndTime.IsZero() {
+		params.Set("param_end", nanos(query.EndTime))
+		conditions = append(conditions, "start_time <= fromUnixTimestamp64Nano({end:Int64}, 'UTC')")
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), params
+}
+
+// GetServices returns every service with spans in the table.
+func (s *ClickHouseStore) GetServices(ctx context.Context) ([]string, error) {
+	services := []string{}
+	err := s.exec(ctx, "SELECT DISTINCT service FROM "+s.table+" ORDER BY service", nil, nil, func(line []byte) error {
+		var row struct {
+			Service string `json:"service"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return err
+		}
+		services = append(services, row.Service)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return services, nil
+}
+
+// Close stops accepting spans and inserts the ones still queued. It returns
+// the error of the last insert, if it failed.
+func (s *ClickHouseStore) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return s.insertErr
+}
+
+// Ping checks that ClickHouse answers queries.
+func (s *ClickHouseStore) Ping(ctx context.Context) error {
+	return s.exec(ctx, "SELECT 1", nil, nil)
+}
+
+// exec runs sql over the HTTP interface. Statements that return rows are
+// read as JSONEachRow and each row is passed to scan; body, if any, is
+// data for an INSERT. params bind the query's {name:Type} placeholders, so
+// values are never spliced into the SQL.
+func (s *ClickHouseStore) exec(ctx context.Context, sql string, params url.Values, body []byte, scan ...func(line []byte) error) error {
+	values := url.Values{}
+	for name, value := range params {
+		values[name] = value
+	}
+	if len(scan) > 0 {
+		sql += " FORMAT JSONEachRow"
+		values.Set("output_format_json_quote_64bit_integers", "0")
+	}
+
+	// The query goes in the URL when the body carries insert data
+	var reader io.Reader = strings.NewReader(sql)
+	if body != nil {
+		values.Set("query", sql)
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint+"/?"+values.Encode(), reader)
+	if err != nil {
+		return err
+	}
+	if s.username != "" {
+		req.Header.Set("X-ClickHouse-User", s.username)
+		req.Header.Set("X-ClickHouse-Key", s.password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("clickhouse returned status %d: %s", resp.StatusCode, bytes.TrimSpace(message))
+	}
+	if len(scan) == 0 {
+		_, err := io.Copy(io.Discard, resp.Body)
+		return err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		if err := scan[0](scanner.Bytes()); err != nil {
+			return fmt.Errorf("invalid row from clickhouse: %w", err)
+		}
+	}
+	return scanner.Err()
+}
+
+// nanos formats t as Unix nanoseconds.
+func nanos(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// clickHouseEscaper escapes string literals in query parameters.
+var clickHouseEscaper = strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+
+// clickHouseArray formats values as an Array(String) query parameter.
+func clickHouseArray(values []string) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, value := range values {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteByte('\'')
+		b.WriteString(clickHouseEscaper.Replace(value))
+		b.WriteByte('\'')
+	}
+	b.WriteByte(']')
+	return b.String()
+}
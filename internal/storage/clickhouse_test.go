@@ -0,0 +1,311 @@
+package storage
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// fakeClickHouse records the statements sent to a ClickHouse HTTP interface
+// and answers SELECTs with the JSONEachRow rows answer returns.
+type fakeClickHouse struct {
+	mu      sync.Mutex
+	queries []string
+	params  []map[string]string
+	inserts [][]clickHouseSpan
+	answer  func(query string) string
+}
+
+func (f *fakeClickHouse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	query := r.URL.Query().Get("query")
+	body, _ := io.ReadAll(r.Body)
+	if query == "" {
+		query = string(body)
+	} else {
+		var rows []clickHouseSpan
+		scanner := bufio.NewScanner(strings.NewReader(string(body)))
+		for scanner.Scan() {
+			var row clickHouseSpan
+			if err := json.Unmarshal(scanner.Bytes(), &row); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rows = append(rows, row)
+		}
+		f.inserts = append(f.inserts, rows)
+	}
+	params := make(map[string]string)
+	for name, values := range r.URL.Query() {
+		if strings.HasPrefix(name, "param_") {
+			params[strings.TrimPrefix(name, "param_")] = values[0]
+		}
+	}
+	f.queries = append(f.queries, query)
+	f.params = append(f.params, params)
+	if strings.HasPrefix(query, "SELECT") && f.answer != nil {
+		io.WriteString(w, f.answer(query))
+	}
+}
+
+func newClickHouseTestStore(t *testing.T, fake *fakeClickHouse, config ClickHouseConfig) *ClickHouseStore {
+	t.Helper()
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	config.URL = server.URL
+	store, err := NewClickHouseStore(context.Background(), config)
+	if err != nil {
+		t.Fatalf("NewClickHouseStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestClickHouseStore_InsertsInBatches(t *testing.T) {
+	fake := &fakeClickHouse{}
+	store := newClickHouseTestStore(t, fake, ClickHouseConfig{Database: "traces", BatchSize: 2, FlushInterval: "1h", Retention: "720h"})
+	if !strings.Contains(fake.queries[0], "CREATE TABLE IF NOT EXISTS traces.spans") ||
+		!strings.Contains(fake.queries[0], "TTL toDateTime(start_time) + INTERVAL 2592000 SECOND") {
+		t.Fatalf("schema = %s", fake.queries[0])
+	}
+
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 123456789, time.UTC)
+	for i := 0; i < 3; i++ {
+		span := routedSpan(traceID, "api", "prod", start)
+		span.Tags["region"] = "eu"
+		if err := store.WriteSpan(ctx, span); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.WriteSpan(ctx, &models.Span{}); err == nil {
+		t.Error("invalid span queued")
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A full batch, then the rest on Close
+	if len(fake.inserts) != 2 || len(fake.inserts[0]) != 2 || len(fake.inserts[1]) != 1 {
+		t.Fatalf("inserts = %d batches, want 2 and 1 spans", len(fake.inserts))
+	}
+	row := fake.inserts[0][0]
+	if row.StartTime != "2024-05-01 12:00:00.123456789" || row.Tags["region"] != "eu" || row.Service != "api" {
+		t.Errorf("row = %+v", row)
+	}
+	if fake.inserts[0][1].Version <= row.Version || fake.inserts[1][0].Version <= fake.inserts[0][1].Version {
+		t.Error("later writes must have higher versions so they replace earlier ones")
+	}
+	if stats := store.Stats(); stats.Batches != 2 || stats.Inserted != 3 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+	if err := store.WriteSpan(ctx, routedSpan(traceID, "api", "", start)); err == nil {
+		t.Error("span accepted after Close")
+	}
+}
+
+func TestClickHouseStore_PushesFiltersDown(t *testing.T) {
+	store := &ClickHouseStore{table: "default.spans", retention: time.Hour}
+	hasProfile := true
+	now := time.Unix(1700000000, 0)
+	query := &Query{
+		Service:     "checkout",
+		MinDuration: time.Second,
+		MaxCost:     0.5,
+		StartTime:   now.Add(-time.Hour),
+		EndTime:     now,
+		ErrorsOnly:  true,
+		Tags:        map[string]string{"region": "eu", "canary": ""},
+		HasProfile:  &hasProfile,
+		SortBy:      SortByDuration,
+		SortOrder:   SortAsc,
+		Limit:       20,
+		Offset:      40,
+	}
+	sql, params := store.findSQL(query, now)
+
+	for _, want := range []string{
+		"countIf(service = {service:String}) > 0",
+		"t_duration >= {min_duration:Int64}",
+		"t_cost <= {max_cost:Float64}",
+		"t_start >= {start:Int64}",
+		"t_start <= {end:Int64}",
+		"countIf(status = 'error') > 0",
+		"countIf(mapContains(tags, {tag0:String})) > 0",
+		"countIf(tags[{tag1:String}] = {tag1_value:String}) > 0",
+		"countIf(has_profile) > 0",
+		"t_start >= {cutoff:Int64}",
+		// Every span narrowing subquery can skip partitions before the range
+		"WHERE start_time >= fromUnixTimestamp64Nano({start:Int64}, 'UTC') AND service = {service:String})",
+		"ORDER BY t_duration ASC, t_start DESC, trace_id ASC LIMIT 20 OFFSET 40",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query lacks %q:\n%s", want, sql)
+		}
+	}
+	for name, want := range map[string]string{
+		"service":      "checkout",
+		"min_duration": fmt.Sprint(int64(time.Second)),
+		"end":          fmt.Sprint(now.UnixNano()),
+		"tag0":         "canary",
+		"tag1":         "region",
+		"tag1_value":   "eu",
+		"cutoff":       fmt.Sprint(now.Add(-time.Hour).UnixNano()),
+	} {
+		if got := params.Get("param_" + name); got != want {
+			t.Errorf("param %s = %q, want %q", name, got, want)
+		}
+	}
+	if strings.Contains(sql, "'checkout'") || strings.Contains(sql, "'eu'") {
+		t.Errorf("values must be bound as parameters, not spliced into the SQL:\n%s", sql)
+	}
+
+	// Without filters every trace is aggregated
+	sql, _ = store.findSQL(&Query{}, now)
+	if strings.Contains(sql, "WHERE") || strings.Contains(sql, "LIMIT") {
+		t.Errorf("unfiltered query = %s", sql)
+	}
+}
+
+func TestClickHouseStore_FindTracesReadsOnePage(t *testing.T) {
+	fake := &fakeClickHouse{}
+	store := newClickHouseTestStore(t, fake, ClickHouseConfig{})
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	traceID := models.GenerateTraceID()
+	traceRows := fmt.Sprintf(`{"trace_id":%q,"t_start":0}`+"\n", traceID)
+	spans := fmt.Sprintf(`{"trace_id":%[1]q,"span_id":"a1","service":"api","operation":"GET /","status":"ok","start_ns":%[2]d,"duration_ns":3000000000,"cost":0.25,"tags":{"region":"eu"},"events":"[{\"name\":\"retry\",\"timestamp\":\"2024-05-01T12:00:01Z\"}]"}
+{"trace_id":%[1]q,"span_id":"a2","parent_span_id":"a1","service":"db","operation":"query","status":"error","start_ns":%[3]d,"duration_ns":1000000000,"cost":0.5,"tags":{}}
+`, traceID, start.UnixNano(), start.Add(time.Second).UnixNano())
+	fake.answer = func(query string) string {
+		if strings.Contains(query, "GROUP BY trace_id") {
+			return traceRows
+		}
+		return spans
+	}
+
+	traces, err := store.FindTraces(context.Background(), &Query{ErrorsOnly: true, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(traces) != 1 {
+		t.Fatalf("got %d traces, want 1", len(traces))
+	}
+	trace := traces[0]
+	if trace.TraceID != traceID || len(trace.Spans) != 2 || trace.Duration != 3*time.Second ||
+		trace.TotalCost != 0.75 || trace.Currency != DefaultCurrency || !trace.StartTime.Equal(start) {
+		t.Errorf("trace = %+v", trace)
+	}
+	if got := strings.Join(trace.Services, ","); got != "api,db" {
+		t.Errorf("services = %s", got)
+	}
+	if len(trace.Spans[0].Events) != 1 || trace.Spans[0].Tags["region"] != "eu" {
+		t.Errorf("span = %+v", trace.Spans[0])
+	}
+
+	// The page's spans are read by trace ID
+	last := len(fake.queries) - 1
+	if !strings.Contains(fake.queries[last], "FINAL WHERE trace_id IN {ids:Array(String)}") {
+		t.Errorf("span query = %s", fake.queries[last])
+	}
+	if ids := fake.params[last]["ids"]; ids != "['"+traceID+"']" {
+		t.Errorf("ids = %s", ids)
+	}
+}
+
+func TestClickHouseStore_AggregatesInSQL(t *testing.T) {
+	fake := &fakeClickHouse{}
+	store := newClickHouseTestStore(t, fake, ClickHouseConfig{})
+	fake.answer = func(query string) string {
+		switch {
+		case strings.HasPrefix(query, "SELECT count()"):
+			return `{"total":1234}` + "\n"
+		case strings.Contains(query, "uniqExact(trace_id)"):
+			return `{"service":"api","traces":40}` + "\n"
+		default:
+			// 90 spans of about 12ms, 10 of about 1s and one past the last bound
+			return `{"service":"api","operation":"GET /","bucket":13,"spans":90,"errors":3,"cost":0.9,"total_ns":1080000000}
+{"service":"api","operation":"GET /","bucket":24,"spans":10,"errors":2,"cost":0.1,"total_ns":12000000000}
+{"service":"api","operation":"POST /","bucket":0,"spans":1,"errors":0,"cost":0,"total_ns":3600000000000}
+`
+		}
+	}
+	ctx := context.Background()
+
+	query := NewQuery().WithService("api").WithPagination(10, 20)
+	if n, err := store.CountTraces(ctx, query); err != nil || n != 1234 {
+		t.Fatalf("CountTraces() = %d, %v", n, err)
+	}
+	count := fake.queries[len(fake.queries)-1]
+	if !strings.HasPrefix(count, "SELECT count() AS total FROM (SELECT trace_id") || strings.Contains(count, "LIMIT") {
+		t.Errorf("count query = %s", count)
+	}
+
+	services, err := store.GetServiceStats(ctx, &StatsQuery{Service: "api", StartTime: time.Unix(1700000000, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Traces != 40 || services[0].Spans != 101 || services[0].Errors != 5 {
+		t.Fatalf("services = %+v", services)
+	}
+	stats := fake.queries[len(fake.queries)-2]
+	for _, want := range []string{
+		"arrayFirstIndex(b -> duration_ns < b, {bounds:Array(Int64)}) AS bucket",
+		"WHERE NOT in_progress AND service = {service:String} AND start_time >= fromUnixTimestamp64Nano({start:Int64}, 'UTC')",
+		"GROUP BY service, operation, bucket",
+	} {
+		if !strings.Contains(stats, want) {
+			t.Errorf("stats query lacks %q:\n%s", want, stats)
+		}
+	}
+
+	operations, _ := store.GetOperationStats(ctx, &StatsQuery{})
+	if len(operations) != 2 || operations[0].Operation != "GET /" || operations[0].Spans != 100 {
+		t.Fatalf("operations = %+v", operations)
+	}
+	get := operations[0]
+	// Bucket 13 holds 8.7ms-13ms and bucket 24 holds 748ms-1.12s
+	if p50 := get.P50Duration; p50 < 8*time.Millisecond || p50 > 13*time.Millisecond {
+		t.Errorf("p50 = %v, want within bucket 13", p50)
+	}
+	if p95 := get.P95Duration; p95 < 748*time.Millisecond || p95 > 1123*time.Millisecond {
+		t.Errorf("p95 = %v, want within bucket 24", p95)
+	}
+	if get.AvgDuration != 130800*time.Microsecond {
+		t.Errorf("average = %v, want 130.8ms", get.AvgDuration)
+	}
+}
+
+func TestClickHouseStore_Ping(t *testing.T) {
+	fake := &fakeClickHouse{}
+	server := httptest.NewServer(fake)
+	store, err := NewClickHouseStore(context.Background(), ClickHouseConfig{URL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if err := store.Ping(context.Background()); err != nil {
+		t.Errorf("Ping() error = %v", err)
+	}
+	if last := fake.queries[len(fake.queries)-1]; last != "SELECT 1" {
+		t.Errorf("ping sent %q", last)
+	}
+
+	server.Close()
+	if err := store.Ping(context.Background()); err == nil {
+		t.Error("Ping() succeeded with ClickHouse down")
+	}
+}
@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// DefaultInternCapacity is how many distinct strings a store interns.
+const DefaultInternCapacity = 1 << 16
+
+// interner makes equal strings share one backing array. Service and
+// operation names, span kinds and tag keys repeat across millions of spans,
+// but each decoded span carries its own copies; interned, they cost memory
+// once. Strings stay interned for the life of the store, so once capacity is
+// reached new strings are kept as they are: a high-cardinality value (e.g. a
+// URL used as an operation name) cannot grow the interner without bound.
+type interner struct {
+	strings  sync.Map // string → the same string, canonical copy
+	size     atomic.Int64
+	capacity int64
+}
+
+func newInterner(capacity int) *interner {
+	return &interner{capacity: int64(capacity)}
+}
+
+// intern returns the canonical copy of str.
+func (in *interner) intern(str string) string {
+	if str == "" {
+		return str
+	}
+	if canonical, ok := in.strings.Load(str); ok {
+		return canonical.(string)
+	}
+	if in.size.Load() >= in.capacity {
+		return str
+	}
+
+	// Clone so the canonical copy doesn't pin a larger request buffer
+	canonical, loaded := in.strings.LoadOrStore(str, strings.Clone(str))
+	if !loaded {
+		in.size.Add(1)
+	}
+	return canonical.(string)
+}
+
+// internSpan returns span with its repetitive strings interned: names,
+// kind, status, deployment fields and tag and event attribute keys. Tag
+// values, IDs and messages are mostly unique and are left alone. Like
+// limitTags, it copies the span rather than modifying the caller's.
+func (in *interner) internSpan(span *models.Span) *models.Span {
+	interned := *span
+	interned.ServiceName = in.intern(span.ServiceName)
+	interned.OperationName = in.intern(span.OperationName)
+	interned.SpanKind = in.intern(span.SpanKind)
+	interned.Status = in.intern(span.Status)
+	interned.DeploymentID = in.intern(span.DeploymentID)
+	interned.GitSHA = in.intern(span.GitSHA)
+	interned.Environment = in.intern(span.Environment)
+	interned.Tags = in.internKeys(span.Tags)
+
+	if len(span.Events) > 0 {
+		interned.Events = make([]models.SpanEvent, len(span.Events))
+		for i, event := range span.Events {
+			event.Name = in.intern(event.Name)
+			event.Attributes = in.internKeys(event.Attributes)
+			interned.Events[i] = event
+		}
+	}
+	return &interned
+}
+
+// internKeys returns a copy of m with interned keys.
+func (in *interner) internKeys(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	interned := make(map[string]string, len(m))
+	for key, value := range m {
+		interned[in.intern(key)] = value
+	}
+	return interned
+}
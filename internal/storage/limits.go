@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"sort"
+	"unicode/utf8"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// SpanLimits bound what a MemoryStore keeps per trace and span, so a
+// runaway instrumented loop cannot exhaust memory or make GetTrace slow.
+// Zero fields are unlimited. What is discarded is counted in the trace's
+// Truncation.
+type SpanLimits struct {
+	// MaxSpansPerTrace refuses new spans once a trace has this many;
+	// updates to spans already stored are still applied
+	MaxSpansPerTrace int `json:"max_spans_per_trace,omitempty"`
+
+	// MaxTagsPerSpan keeps a span's first tags in key order and drops the rest
+	MaxTagsPerSpan int `json:"max_tags_per_span,omitempty"`
+
+	// MaxTagValueLength cuts longer tag values to this many bytes
+	MaxTagValueLength int `json:"max_tag_value_length,omitempty"`
+}
+
+// WithLimits sets per-trace and per-span limits.
+func (s *MemoryStore) WithLimits(limits SpanLimits) *MemoryStore {
+	s.limits = limits
+	return s
+}
+
+// limitTags applies the tag limits, returning span itself when nothing
+// exceeds them or else a copy with trimmed tags.
+func (l SpanLimits) limitTags(span *models.Span) (*models.Span, models.Truncation) {
+	var truncation models.Truncation
+
+	overCount := l.MaxTagsPerSpan > 0 && len(span.Tags) > l.MaxTagsPerSpan
+	overSize := false
+	if l.MaxTagValueLength > 0 {
+		for _, value := range span.Tags {
+			if len(value) > l.MaxTagValueLength {
+				overSize = true
+				break
+			}
+		}
+	}
+	if !overCount && !overSize {
+		return span, truncation
+	}
+
+	keys := make([]string, 0, len(span.Tags))
+	for key := range span.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if overCount {
+		truncation.DroppedTags = len(keys) - l.MaxTagsPerSpan
+		keys = keys[:l.MaxTagsPerSpan]
+	}
+
+	limited := *span
+	limited.Tags = make(map[string]string, len(keys))
+	for _, key := range keys {
+		value := span.Tags[key]
+		if l.MaxTagValueLength > 0 && len(value) > l.MaxTagValueLength {
+			value = truncateUTF8(value, l.MaxTagValueLength)
+			truncation.TruncatedTags++
+		}
+		limited.Tags[key] = value
+	}
+	return &limited, truncation
+}
+
+// truncateUTF8 cuts s to at most n bytes without splitting a character.
+func truncateUTF8(s string, n int) string {
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// addTruncation accumulates t into total.
+func addTruncation(total *models.Truncation, t models.Truncation) {
+	total.DroppedSpans += t.DroppedSpans
+	total.DroppedTags += t.DroppedTags
+	total.TruncatedTags += t.TruncatedTags
+}
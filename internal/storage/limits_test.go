@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestLimits_MaxSpansPerTrace(t *testing.T) {
+	store := NewMemoryStore(10).WithLimits(SpanLimits{MaxSpansPerTrace: 2})
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+
+	var first *models.Span
+	for i := 0; i < 5; i++ {
+		span := &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "loop",
+			OperationName: "iteration",
+			StartTime:     time.Now(),
+			Status:        "ok",
+		}
+		if first == nil {
+			first = span
+		}
+		if err := store.WriteSpan(ctx, span); err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+	}
+
+	trace, _ := store.GetTrace(ctx, traceID)
+	if len(trace.Spans) != 2 {
+		t.Errorf("trace has %d spans, want 2", len(trace.Spans))
+	}
+	if trace.Truncation == nil || trace.Truncation.DroppedSpans != 3 {
+		t.Errorf("truncation = %+v, want 3 dropped spans", trace.Truncation)
+	}
+	if n := store.spanCount.Load(); n != 2 {
+		t.Errorf("spanCount = %d, want 2", n)
+	}
+
+	// Updates to a stored span still apply once the trace is full
+	updated := *first
+	updated.Status = "error"
+	store.WriteSpan(ctx, &updated)
+	trace, _ = store.GetTrace(ctx, traceID)
+	if trace.Truncation.DroppedSpans != 3 {
+		t.Errorf("upsert counted as dropped: %+v", trace.Truncation)
+	}
+	for _, span := range trace.Spans {
+		if span.SpanID == first.SpanID && span.Status != "error" {
+			t.Error("upsert of a stored span was not applied")
+		}
+	}
+}
+
+func TestLimits_Tags(t *testing.T) {
+	store := NewMemoryStore(10).WithLimits(SpanLimits{MaxTagsPerSpan: 2, MaxTagValueLength: 4})
+	ctx := context.Background()
+
+	tags := map[string]string{"a": "short", "b": "héllo", "c": "x", "d": "y"}
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+		Tags:          tags,
+	}
+	store.WriteSpan(ctx, span)
+
+	trace, _ := store.GetTrace(ctx, span.TraceID)
+	got := trace.Spans[0].Tags
+	if len(got) != 2 || got["a"] != "shor" || got["b"] != "hél" {
+		t.Errorf("tags = %v, want a and b cut to 4 bytes", got)
+	}
+	if *trace.Truncation != (models.Truncation{DroppedTags: 2, TruncatedTags: 2}) {
+		t.Errorf("truncation = %+v", trace.Truncation)
+	}
+	if len(tags) != 4 || !strings.HasPrefix(tags["a"], "short") {
+		t.Error("caller's tags were modified")
+	}
+}
+
+func TestLimits_NoTruncationReported(t *testing.T) {
+	store := NewMemoryStore(10).WithLimits(SpanLimits{MaxSpansPerTrace: 10, MaxTagsPerSpan: 10})
+	ctx := context.Background()
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+		Tags:          map[string]string{"k": "v"},
+	}
+	store.WriteSpan(ctx, span)
+
+	trace, _ := store.GetTrace(ctx, span.TraceID)
+	if trace.Truncation != nil {
+		t.Errorf("truncation = %+v, want nil", trace.Truncation)
+	}
+}
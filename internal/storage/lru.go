@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"container/list"
+	"sync"
+)
+
+// traceLRU orders traces by their last write, so capacity eviction removes
+// the least recently updated traces in O(1) per trace instead of scanning
+// and sorting the whole store. A trace still receiving spans stays fresh
+// however long ago it started. Each shard keeps its own list; entries carry
+// the store-wide write sequence so the oldest trace across shards can be
+// found by comparing the shards' tails.
+type traceLRU struct {
+	mu      sync.Mutex
+	order   *list.List               // Front = most recently written; values are *lruEntry
+	entries map[string]*list.Element // traceID → element in order
+}
+
+type lruEntry struct {
+	traceID string
+	seq     uint64 // Store-wide write sequence at the last touch
+}
+
+func newTraceLRU() *traceLRU {
+	return &traceLRU{
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// touch marks traceID as written at seq, adding it if new.
+func (l *traceLRU) touch(traceID string, seq uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[traceID]; ok {
+		elem.Value.(*lruEntry).seq = seq
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.entries[traceID] = l.order.PushFront(&lruEntry{traceID: traceID, seq: seq})
+}
+
+// remove forgets traceID.
+func (l *traceLRU) remove(traceID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.entries[traceID]; ok {
+		l.order.Remove(elem)
+		delete(l.entries, traceID)
+	}
+}
+
+// popOldest removes and returns the least recently written trace.
+func (l *traceLRU) popOldest() (string, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem := l.order.Back()
+	if elem == nil {
+		return "", false
+	}
+	traceID := l.order.Remove(elem).(*lruEntry).traceID
+	delete(l.entries, traceID)
+	return traceID, true
+}
+
+// oldest returns the write sequence of the least recently written trace.
+func (l *traceLRU) oldest() (uint64, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	elem := l.order.Back()
+	if elem == nil {
+		return 0, false
+	}
+	return elem.Value.(*lruEntry).seq, true
+}
+
+// len returns the number of traces tracked.
+func (l *traceLRU) len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
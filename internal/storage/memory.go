@@ -5,80 +5,175 @@ import (
 	"fmt"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/saintparish4/asmbly/internal/models"
+	"github.com/saintparish4/asmbly/internal/histogram"
+	"github.com/saintparish4/asmbly/models"
 )
 
 // MemoryStore is a concurrent-safe in-memory trace storage implementation
 // It uses sync.Map for lock-free reads and maintains multiple indexes for efficiency
 type MemoryStore struct {
-	// Core storage - concurrent-safe maps
-	spans  sync.Map // spanID (string) -> *models.Span
-	traces sync.Map // traceID (string) -> []string (spanIDs)
+	// Traces, their spans and indexes, split by trace ID hash (see shard.go)
+	shards []*shard
 
-	// Indexes for efficient queries
-	indexes *Indexes
-	indexMu sync.RWMutex // protects indexes updates
+	// Striped locks for per-trace span lists (see trace_spans.go)
+	traceLocks traceLocks
+
+	// Capacity eviction: writes are stamped with writeSeq in their shard's
+	// LRU, so the oldest trace across shards can be found (see lru.go)
+	writeSeq atomic.Uint64
+	evictMu  sync.Mutex // Serializes capacity eviction
+
+	// Bucket bounds every shard's duration and cost indexes use
+	durationBounds []float64
+	costBounds     []float64
 
 	// Config
-	maxTraces int // Max traces to keep in memory
+	maxTraces int           // Max traces to keep in memory
+	retention time.Duration // Max trace age, measured from trace start (0 = unlimited)
+	currency  string        // Unit of span and trace costs
+	limits    SpanLimits    // Per-trace and per-span size limits (see limits.go)
+	strings   *interner     // Shared copies of repeated strings (see intern.go)
+
+	// Retention janitor (see StartJanitor), closed by Close
+	janitorStop chan struct{}
+	janitorDone chan struct{}
+	closeOnce   sync.Once
 
 	// Metrics
-	spanCount  int64
-	traceCount int64
-	mu         sync.RWMutex // Protects counters
+	spanCount  atomic.Int64
+	traceCount atomic.Int64
+	evictions  EvictionStats
+	mu         sync.RWMutex // Protects evictions
 }
 
 // Indexes maintains multiple indexes for efficient trace queries.
 type Indexes struct {
-	// Service index: service name → []traceID
-	byService map[string][]string
+	// Service index: service name → traceIDs
+	byService map[string]traceSet
 
 	// Time buckets: hourly buckets for temporal queries
 	byTimestamp *TimeBuckets
 
-	// Duration buckets: categorize traces by duration
+	// Duration buckets: categorize traces by duration (see buckets.go)
 	byDuration *DurationBuckets
 
-	// Cost buckets: categorize traces by cost (Week 3)
+	// Cost buckets: categorize traces by cost (see buckets.go)
 	byCost *CostBuckets
+
+	// Error index: traceID → number of failed spans (traces without any
+	// are absent)
+	byError map[string]int
 }
 
 // TimeBuckets organizes traces by hourly time buckets for efficient time-range queries.
 type TimeBuckets struct {
-	buckets map[int64][]string // Unix hour → []traceID
+	buckets map[int64]traceSet // Unix hour → traceIDs
 }
 
-// DurationBuckets categorizes traces by duration for efficient duration queries.
-type DurationBuckets struct {
-	fast     []string // < 10ms
-	medium   []string // 10ms - 100ms
-	slow     []string // 100ms - 1s
-	verySlow []string // > 1s
-}
-
-// CostBuckets categorizes traces by cost for efficient cost queries.
-type CostBuckets struct {
-	cheap     []string // < $0.0001
-	moderate  []string // $0.0001 - $0.001
-	expensive []string // > $0.001
+// hourBucket returns the TimeBuckets key for t.
+func hourBucket(t time.Time) int64 {
+	return t.Unix() / 3600
 }
 
 // NewMemoryStore creates a new in-memory storage with the given capacity.
 // maxTraces controls how many traces to keep before evicting old ones.
 func NewMemoryStore(maxTraces int) *MemoryStore {
+	durationBounds := newDefaultDurationBuckets().hist.Bounds()
+	costBounds := newDefaultCostBuckets().hist.Bounds()
 	return &MemoryStore{
-		maxTraces: maxTraces,
-		indexes: &Indexes{
-			byService:   make(map[string][]string),
-			byTimestamp: &TimeBuckets{buckets: make(map[int64][]string)},
-			byDuration:  &DurationBuckets{},
-			byCost:      &CostBuckets{},
-		},
+		maxTraces:      maxTraces,
+		shards:         newShards(DefaultShards, durationBounds, costBounds),
+		durationBounds: durationBounds,
+		costBounds:     costBounds,
+		currency:       DefaultCurrency,
+		strings:        newInterner(DefaultInternCapacity),
 	}
 }
 
+// DefaultJanitorInterval is how often the janitor removes expired traces.
+const DefaultJanitorInterval = time.Minute
+
+// WithRetention sets how long traces are kept after their start time.
+// Traces report the resulting deadline as ExpiresAt and are hidden from reads
+// once it passes; the janitor (see StartJanitor) frees them. Capacity
+// eviction (maxTraces) may still remove them earlier.
+func (s *MemoryStore) WithRetention(retention time.Duration) *MemoryStore {
+	s.retention = retention
+	return s
+}
+
+// StartJanitor starts a goroutine that removes traces past retention every
+// interval (0 = DefaultJanitorInterval), keeping the sweep off the write
+// path. It does nothing without retention or when already started. Close
+// stops it.
+func (s *MemoryStore) StartJanitor(interval time.Duration) *MemoryStore {
+	if s.retention <= 0 || s.janitorStop != nil {
+		return s
+	}
+	if interval <= 0 {
+		interval = DefaultJanitorInterval
+	}
+	s.janitorStop = make(chan struct{})
+	s.janitorDone = make(chan struct{})
+
+	go func() {
+		defer close(s.janitorDone)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.janitorStop:
+				return
+			case now := <-ticker.C:
+				s.evictExpired(now)
+			}
+		}
+	}()
+	return s
+}
+
+// WithShards sets how many shards traces are split across (default
+// DefaultShards). More shards let more writers proceed at once; queries
+// visit every shard. Call it before writing spans; stored traces are dropped.
+func (s *MemoryStore) WithShards(n int) *MemoryStore {
+	if n > 0 {
+		s.shards = newShards(n, s.durationBounds, s.costBounds)
+	}
+	return s
+}
+
+// WithDurationBuckets replaces the default duration buckets, using their
+// bounds in every shard. Call it before writing spans; traces already
+// indexed are not re-bucketed.
+func (s *MemoryStore) WithDurationBuckets(buckets *DurationBuckets) *MemoryStore {
+	s.durationBounds = buckets.hist.Bounds()
+	for _, sh := range s.shards {
+		sh.indexes.byDuration = &DurationBuckets{newHistogramIndex(s.durationBounds)}
+	}
+	return s
+}
+
+// WithCostBuckets replaces the default cost buckets, using their bounds in
+// every shard. Call it before writing spans; traces already indexed are not
+// re-bucketed.
+func (s *MemoryStore) WithCostBuckets(buckets *CostBuckets) *MemoryStore {
+	s.costBounds = buckets.hist.Bounds()
+	for _, sh := range s.shards {
+		sh.indexes.byCost = &CostBuckets{newHistogramIndex(s.costBounds)}
+	}
+	return s
+}
+
+// WithCurrency sets the unit span costs are recorded in, e.g. "EUR" or
+// "USD_MICROS", reported on traces alongside their cost.
+func (s *MemoryStore) WithCurrency(currency string) *MemoryStore {
+	s.currency = currency
+	return s
+}
+
 // WriteSpan stores a span and updates all indexes.
 // This method is safe for concurrent use.
 func (s *MemoryStore) WriteSpan(ctx context.Context, span *models.Span) error {
@@ -87,19 +182,37 @@ func (s *MemoryStore) WriteSpan(ctx context.Context, span *models.Span) error {
 		return fmt.Errorf("invalid span: %w", err)
 	}
 
-	// Store span in main map
-	s.spans.Store(span.SpanID, span)
+	// Trim oversized tags and intern repeated strings, then add the span to
+	// its trace's span list unless the trace is full
+	span, truncation := s.limits.limitTags(span)
+	span = s.strings.internSpan(span)
+	newTrace, added := s.addSpanToTrace(span, truncation)
+	if newTrace {
+		s.traceCount.Add(1)
+	}
+	sh := s.shardFor(span.TraceID)
+	sh.lru.touch(span.TraceID, s.writeSeq.Add(1))
+	if !added {
+		return nil // Counted in the trace's Truncation
+	}
 
-	// Add span to trace's span list
-	s.addSpanToTrace(span.TraceID, span.SpanID)
+	// Store span in its shard, replacing any earlier version (upsert). A read
+	// between addSpanToTrace and the Swap may have assembled the trace
+	// without this span, so invalidate the cache again now it is visible
+	value, replaced := sh.spans.Swap(span.SpanID, span)
+	s.invalidateAssembled(span.TraceID)
 
 	// Update indexes
-	s.updateIndexes(span)
+	var previous *models.Span
+	if replaced {
+		previous = value.(*models.Span)
+	}
+	sh.updateIndexes(span, previous)
 
-	// Update counters
-	s.mu.Lock()
-	s.spanCount++
-	s.mu.Unlock()
+	// Update counters (replacements don't add a span)
+	if !replaced {
+		s.spanCount.Add(1)
+	}
 
 	// Check if eviction is needed
 	s.maybeEvict()
@@ -108,38 +221,80 @@ func (s *MemoryStore) WriteSpan(ctx context.Context, span *models.Span) error {
 }
 
 // GetTrace retrieves and assembles a complete trace by ID.
+// Completed traces are assembled once and then served from a cache until
+// the next write to the trace, so the result must not be modified.
 func (s *MemoryStore) GetTrace(ctx context.Context, traceID string) (*models.Trace, error) {
-	// Get span IDs for this trace
-	value, ok := s.traces.Load(traceID)
-	if !ok {
-		return nil, nil // Trace not found
-	}
+	// Get span IDs for this trace, or the cached assembly
+	spanIDs, version, trace, truncation := s.traceSnapshot(traceID)
+	if trace == nil {
+		if len(spanIDs) == 0 {
+			return nil, nil // Trace not found
+		}
 
-	spanIDs := value.([]string)
-	if len(spanIDs) == 0 {
-		return nil, nil
-	}
+		// Retrieve all spans
+		sh := s.shardFor(traceID)
+		spans := make([]models.Span, 0, len(spanIDs))
+		for _, spanID := range spanIDs {
+			if value, ok := sh.spans.Load(spanID); ok {
+				span := value.(*models.Span)
+				spans = append(spans, *span)
+			}
+		}
 
-	// Retrieve all spans
-	spans := make([]models.Span, 0, len(spanIDs))
-	for _, spanID := range spanIDs {
-		if value, ok := s.spans.Load(spanID); ok {
-			span := value.(*models.Span)
-			spans = append(spans, *span)
+		if len(spans) == 0 {
+			return nil, nil
+		}
+
+		// Assemble trace metadata; traces still in progress change too
+		// often to be worth caching
+		trace = s.assembleTrace(traceID, spans)
+		trace.Truncation = truncation
+		if !trace.InProgress {
+			s.cacheAssembled(traceID, version, trace)
 		}
 	}
 
-	if len(spans) == 0 {
+	// Past retention but not swept yet
+	if trace.ExpiresAt != nil && !trace.ExpiresAt.After(time.Now()) {
 		return nil, nil
 	}
-
-	// Assemble trace metadata
-	trace := s.assembleTrace(traceID, spans)
 	return trace, nil
 }
 
 // FindTraces searches for traces matching the query criteria.
 func (s *MemoryStore) FindTraces(ctx context.Context, query *Query) ([]*models.Trace, error) {
+	results := s.matchingTraces(ctx, query)
+
+	// Sort (newest first unless the query says otherwise)
+	query.SortTraces(results)
+
+	// Apply pagination
+	total := len(results)
+	if query.Offset >= total {
+		return []*models.Trace{}, nil
+	}
+
+	end := query.Offset + query.Limit
+	if query.Limit == 0 {
+		end = total
+	} else if end > total {
+		end = total
+	}
+
+	page := results[query.Offset:end]
+	for i, trace := range page {
+		page[i] = ProjectTrace(trace, query.Projection)
+	}
+	return page, nil
+}
+
+// CountTraces counts the traces matching the query.
+func (s *MemoryStore) CountTraces(ctx context.Context, query *Query) (int, error) {
+	return len(s.matchingTraces(ctx, query)), nil
+}
+
+// matchingTraces returns the traces matching the query, unsorted.
+func (s *MemoryStore) matchingTraces(ctx context.Context, query *Query) []*models.Trace {
 	// Get candidate trace IDs from indexes
 	candidates := s.getCandidateTraces(query)
 
@@ -159,35 +314,58 @@ func (s *MemoryStore) FindTraces(ctx context.Context, query *Query) ([]*models.T
 			results = append(results, trace)
 		}
 	}
+	return results
+}
 
-	// Sort by start time (newest first)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].StartTime.After(results[j].StartTime)
-	})
+// GetServiceStats aggregates the selected spans per service.
+func (s *MemoryStore) GetServiceStats(ctx context.Context, query *StatsQuery) ([]ServiceStats, error) {
+	return s.spanStats(query).serviceStats(), nil
+}
 
-	// Apply pagination
-	total := len(results)
-	if query.Offset >= total {
-		return []*models.Trace{}, nil
-	}
+// GetOperationStats aggregates the selected spans per operation.
+func (s *MemoryStore) GetOperationStats(ctx context.Context, query *StatsQuery) ([]OperationStats, error) {
+	return s.spanStats(query).operationStats(), nil
+}
 
-	end := query.Offset + query.Limit
-	if query.Limit == 0 {
-		end = total
-	} else if end > total {
-		end = total
+// spanStats aggregates the spans the query selects, reading spans directly
+// rather than assembling their traces.
+func (s *MemoryStore) spanStats(query *StatsQuery) *statsAccumulator {
+	stats := newStatsAccumulator()
+	traces := make(map[string]map[string]struct{}) // service -> trace IDs
+	for _, sh := range s.shards {
+		sh.spans.Range(func(_, value any) bool {
+			span := value.(*models.Span)
+			if !query.matches(span) {
+				return true
+			}
+			stats.operation(span.ServiceName, span.OperationName).observe(span)
+			if traces[span.ServiceName] == nil {
+				traces[span.ServiceName] = make(map[string]struct{})
+			}
+			traces[span.ServiceName][span.TraceID] = struct{}{}
+			return true
+		})
 	}
-
-	return results[query.Offset:end], nil
+	for service, ids := range traces {
+		stats.traces[service] = int64(len(ids))
+	}
+	return stats
 }
 
 // GetServices returns all unique service names.
 func (s *MemoryStore) GetServices(ctx context.Context) ([]string, error) {
-	s.indexMu.RLock()
-	defer s.indexMu.RUnlock()
+	// A service writing many traces is in every shard's index
+	seen := make(map[string]struct{})
+	for _, sh := range s.shards {
+		sh.indexMu.RLock()
+		for service := range sh.indexes.byService {
+			seen[service] = struct{}{}
+		}
+		sh.indexMu.RUnlock()
+	}
 
-	services := make([]string, 0, len(s.indexes.byService))
-	for service := range s.indexes.byService {
+	services := make([]string, 0, len(seen))
+	for service := range seen {
 		services = append(services, service)
 	}
 
@@ -195,130 +373,92 @@ func (s *MemoryStore) GetServices(ctx context.Context) ([]string, error) {
 	return services, nil
 }
 
-// Close cleanly shuts down the storage (no-op for in-memory).
+// Close stops the janitor, if started. Stored traces stay readable.
 func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() {
+		if s.janitorStop != nil {
+			close(s.janitorStop)
+			<-s.janitorDone
+		}
+	})
 	return nil
 }
 
-// addSpanToTrace adds a span ID to a trace's span list.
-func (s *MemoryStore) addSpanToTrace(traceID, spanID string) {
-	// Load existing span IDs or create new slice
-	value, loaded := s.traces.LoadOrStore(traceID, []string{})
-	spanIDs := value.([]string)
-
-	// If this is a new trace, increment counter
-	if !loaded {
-		s.mu.Lock()
-		s.traceCount++
-		s.mu.Unlock()
-	}
+// updateIndexes updates the shard's indexes with the new span's information.
+// previous is the version of the span being replaced, or nil for a new span.
+func (sh *shard) updateIndexes(span *models.Span, previous *models.Span) {
+	sh.indexMu.Lock()
+	defer sh.indexMu.Unlock()
+	indexes := sh.indexes
 
-	// Check if span already exists (idempotency)
-	for _, id := range spanIDs {
-		if id == spanID {
-			return
-		}
+	// A replaced root span invalidates the trace's duration/cost buckets
+	if previous != nil && previous.ParentSpanID == "" {
+		indexes.unindexDurationAndCost(previous.TraceID)
 	}
 
-	// Add new span ID
-	spanIDs = append(spanIDs, spanID)
-	s.traces.Store(traceID, spanIDs)
-}
-
-// updateIndexes updates all indexes with the new span's information.
-func (s *MemoryStore) updateIndexes(span *models.Span) {
-	s.indexMu.Lock()
-	defer s.indexMu.Unlock()
-
 	// Index by service name
-	if !s.containsString(s.indexes.byService[span.ServiceName], span.TraceID) {
-		s.indexes.byService[span.ServiceName] = append(
-			s.indexes.byService[span.ServiceName],
-			span.TraceID,
-		)
+	indexes.byService[span.ServiceName] = indexes.byService[span.ServiceName].add(span.TraceID)
+
+	// Index failed spans, moving the count if an upsert changed the status
+	if previous != nil && previous.IsError() {
+		indexes.unindexError(previous.TraceID)
+	}
+	if span.IsError() {
+		indexes.byError[span.TraceID]++
 	}
 
 	// Index by timestamp (hourly buckets)
-	hourBucket := span.StartTime.Unix() / 3600
-	if !s.containsString(s.indexes.byTimestamp.buckets[hourBucket], span.TraceID) {
-		s.indexes.byTimestamp.buckets[hourBucket] = append(
-			s.indexes.byTimestamp.buckets[hourBucket],
-			span.TraceID,
-		)
-	}
+	hour := hourBucket(span.StartTime)
+	indexes.byTimestamp.buckets[hour] = indexes.byTimestamp.buckets[hour].add(span.TraceID)
 
 	// Note: Duration and cost indexes are updated when trace is complete
 	// For now, we'll index on first span (root span typically)
-	if span.ParentSpanID == "" {
+	// In-progress spans have no final duration yet; they are indexed on completion
+	if span.ParentSpanID == "" && !span.InProgress {
 		// This is likely a root span
-		s.updateDurationIndex(span.TraceID, span.Duration)
-		s.updateCostIndex(span.TraceID, span.Cost)
+		indexes.byDuration.add(span.TraceID, span.Duration.Seconds())
+		indexes.byCost.add(span.TraceID, span.Cost)
 	}
 }
 
-// updateDurationIndex categorizes a trace by duration.
-func (s *MemoryStore) updateDurationIndex(traceID string, duration time.Duration) {
-	ms := duration.Milliseconds()
-
-	switch {
-	case ms < 10:
-		if !s.containsString(s.indexes.byDuration.fast, traceID) {
-			s.indexes.byDuration.fast = append(s.indexes.byDuration.fast, traceID)
-		}
-	case ms < 100:
-		if !s.containsString(s.indexes.byDuration.medium, traceID) {
-			s.indexes.byDuration.medium = append(s.indexes.byDuration.medium, traceID)
-		}
-	case ms < 1000:
-		if !s.containsString(s.indexes.byDuration.slow, traceID) {
-			s.indexes.byDuration.slow = append(s.indexes.byDuration.slow, traceID)
-		}
-	default:
-		if !s.containsString(s.indexes.byDuration.verySlow, traceID) {
-			s.indexes.byDuration.verySlow = append(s.indexes.byDuration.verySlow, traceID)
-		}
-	}
-}
-
-// updateCostIndex categorizes a trace by cost.
-func (s *MemoryStore) updateCostIndex(traceID string, cost float64) {
-	switch {
-	case cost < 0.0001:
-		if !s.containsString(s.indexes.byCost.cheap, traceID) {
-			s.indexes.byCost.cheap = append(s.indexes.byCost.cheap, traceID)
-		}
-	case cost < 0.001:
-		if !s.containsString(s.indexes.byCost.moderate, traceID) {
-			s.indexes.byCost.moderate = append(s.indexes.byCost.moderate, traceID)
-		}
-	default:
-		if !s.containsString(s.indexes.byCost.expensive, traceID) {
-			s.indexes.byCost.expensive = append(s.indexes.byCost.expensive, traceID)
-		}
+// getCandidateTraces uses indexes to get a set of candidate trace IDs.
+func (s *MemoryStore) getCandidateTraces(query *Query) []string {
+	var candidates []string
+	for _, sh := range s.shards {
+		candidates = append(candidates, sh.candidateTraces(query)...)
 	}
+	return candidates
 }
 
-// getCandidateTraces uses indexes to get a set of candidate trace IDs.
-func (s *MemoryStore) getCandidateTraces(query *Query) []string {
-	s.indexMu.RLock()
-	defer s.indexMu.RUnlock()
+// candidateTraces returns the shard's candidate trace IDs for a query.
+func (sh *shard) candidateTraces(query *Query) []string {
+	sh.indexMu.RLock()
+	defer sh.indexMu.RUnlock()
 
 	var candidates []string
 
+	// Failed traces are usually a small fraction, so the error index
+	// narrows the search the most
+	if query.ErrorsOnly {
+		candidates = make([]string, 0, len(sh.indexes.byError))
+		for traceID := range sh.indexes.byError {
+			candidates = append(candidates, traceID)
+		}
+		return candidates
+	}
+
 	// Use service index if service filter is specified
 	if query.Service != "" {
-		candidates = s.indexes.byService[query.Service]
-		return s.deduplicate(candidates)
+		return sh.indexes.byService[query.Service].ids()
 	}
 
 	// Use time index if time range is specified
 	if !query.StartTime.IsZero() || !query.EndTime.IsZero() {
-		candidates = s.getTracesInTimeRange(query.StartTime, query.EndTime)
-		return s.deduplicate(candidates)
+		return sh.getTracesInTimeRange(query.StartTime, query.EndTime)
 	}
 
 	// Otherwise, get all traces
-	s.traces.Range(func(key, value interface{}) bool {
+	sh.traces.Range(func(key, value interface{}) bool {
 		traceID := key.(string)
 		candidates = append(candidates, traceID)
 		return true
@@ -328,7 +468,7 @@ func (s *MemoryStore) getCandidateTraces(query *Query) []string {
 }
 
 // getTracesInTimeRange retrieves trace IDs within a time range using hourly buckets.
-func (s *MemoryStore) getTracesInTimeRange(start, end time.Time) []string {
+func (sh *shard) getTracesInTimeRange(start, end time.Time) []string {
 	if start.IsZero() {
 		start = time.Unix(0, 0)
 	}
@@ -336,18 +476,19 @@ func (s *MemoryStore) getTracesInTimeRange(start, end time.Time) []string {
 		end = time.Now().Add(24 * time.Hour)
 	}
 
-	var traceIDs []string
+	// A trace whose spans started in different hours is in several buckets
+	var traceIDs traceSet
 
-	startHour := start.Unix() / 3600
-	endHour := end.Unix() / 3600
+	startHour := hourBucket(start)
+	endHour := hourBucket(end)
 
 	for hour := startHour; hour <= endHour; hour++ {
-		if bucket, ok := s.indexes.byTimestamp.buckets[hour]; ok {
-			traceIDs = append(traceIDs, bucket...)
+		for traceID := range sh.indexes.byTimestamp.buckets[hour] {
+			traceIDs = traceIDs.add(traceID)
 		}
 	}
 
-	return traceIDs
+	return traceIDs.ids()
 }
 
 // matchesQuery checks if a trace matches all query filters.
@@ -382,6 +523,26 @@ func (s *MemoryStore) matchesQuery(trace *models.Trace, query *Query) bool {
 		return false
 	}
 
+	// Error filter
+	if query.ErrorsOnly && !traceHasError(trace) {
+		return false
+	}
+
+	// Tag filters
+	if len(query.Tags) > 0 && !traceHasTags(trace, query.Tags) {
+		return false
+	}
+
+	// Partial trace filter
+	if query.InProgress != nil && trace.InProgress != *query.InProgress {
+		return false
+	}
+
+	// Retention: past it but not swept yet, or expiring within MinTTL
+	if trace.ExpiresAt != nil && time.Until(*trace.ExpiresAt) < max(query.MinTTL, 0) {
+		return false
+	}
+
 	// Time range filters
 	if !query.StartTime.IsZero() && trace.StartTime.Before(query.StartTime) {
 		return false
@@ -393,8 +554,43 @@ func (s *MemoryStore) matchesQuery(trace *models.Trace, query *Query) bool {
 	return true
 }
 
+// traceHasError reports whether any span of the trace failed.
+func traceHasError(trace *models.Trace) bool {
+	for i := range trace.Spans {
+		if trace.Spans[i].IsError() {
+			return true
+		}
+	}
+	return false
+}
+
+// traceHasTags reports whether every tag filter is satisfied by some span.
+func traceHasTags(trace *models.Trace, tags map[string]string) bool {
+	for key, want := range tags {
+		found := false
+		for i := range trace.Spans {
+			value, ok := trace.Spans[i].Tags[key]
+			if ok && (want == "" || value == want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
 // assembleTrace constructs a Trace from a collection of spans.
 func (s *MemoryStore) assembleTrace(traceID string, spans []models.Span) *models.Trace {
+	return BuildTrace(traceID, spans, s.currency, s.retention)
+}
+
+// BuildTrace constructs a Trace from its spans, or returns nil if there are
+// none. Costs are labelled with currency; with retention, the trace expires
+// that long after it started.
+func BuildTrace(traceID string, spans []models.Span, currency string, retention time.Duration) *models.Trace {
 	if len(spans) == 0 {
 		return nil
 	}
@@ -434,6 +630,11 @@ func (s *MemoryStore) assembleTrace(traceID string, spans []models.Span) *models
 		costBreakdown[span.ServiceName] += span.Cost
 	}
 
+	// Costs are only labelled when there are any
+	if totalCost == 0 {
+		currency = ""
+	}
+
 	// Collect deployment info
 	deployments := make(map[string]string)
 	for _, span := range spans {
@@ -442,146 +643,219 @@ func (s *MemoryStore) assembleTrace(traceID string, spans []models.Span) *models
 		}
 	}
 
+	// A trace is in progress while any of its spans is
+	inProgress := false
+	for _, span := range spans {
+		if span.InProgress {
+			inProgress = true
+			break
+		}
+	}
+
+	// Retention deadline
+	var expiresAt *time.Time
+	if retention > 0 {
+		t := startTime.Add(retention)
+		expiresAt = &t
+	}
+
 	return &models.Trace{
 		TraceID:       traceID,
 		Spans:         spans,
 		StartTime:     startTime,
 		Duration:      duration,
 		Services:      services,
+		InProgress:    inProgress,
+		ExpiresAt:     expiresAt,
 		Deployments:   deployments,
 		TotalCost:     totalCost,
 		CostBreakdown: costBreakdown,
+		Currency:      currency,
 	}
 }
 
-// maybeEvict checks if eviction is needed and evicts old traces if necessary.
+// maybeEvict evicts the least recently written traces while the store holds
+// more than maxTraces.
 func (s *MemoryStore) maybeEvict() {
-	// Count traces
-	var count int
-	s.traces.Range(func(key, value interface{}) bool {
-		count++
-		return true
-	})
-
-	if count <= s.maxTraces {
+	if s.traceTotal() <= int64(s.maxTraces) {
 		return
 	}
 
-	// Simple eviction: remove oldest traces
-	// In production, this would be LRU with timestamps
-	s.evictOldTraces(count - s.maxTraces)
+	// Recount under the lock so concurrent writers don't both evict for
+	// the same excess
+	s.evictMu.Lock()
+	defer s.evictMu.Unlock()
+	if excess := s.traceTotal() - int64(s.maxTraces); excess > 0 {
+		s.evictOldTraces(int(excess))
+	}
 }
 
-// evictOldTraces removes the oldest n traces.
-func (s *MemoryStore) evictOldTraces(n int) {
-	// Collect all traces with timestamps
-	type traceInfo struct {
-		traceID   string
-		startTime time.Time
+// traceTotal returns the number of stored traces.
+func (s *MemoryStore) traceTotal() int64 {
+	return s.traceCount.Load()
+}
+
+// evictExpired removes traces that started more than retention before now.
+func (s *MemoryStore) evictExpired(now time.Time) {
+	if s.retention <= 0 {
+		return
 	}
 
-	var traces []traceInfo
-	s.traces.Range(func(key, value interface{}) bool {
-		traceID := key.(string)
-		spanIDs := value.([]string)
-		if len(spanIDs) > 0 {
-			if value, ok := s.spans.Load(spanIDs[0]); ok {
-				span := value.(*models.Span)
-				traces = append(traces, traceInfo{
-					traceID:   traceID,
-					startTime: span.StartTime,
-				})
+	cutoff := now.Add(-s.retention)
+	var expired []string
+	for _, sh := range s.shards {
+		sh.traces.Range(func(key, value interface{}) bool {
+			if start, ok := s.traceStart(key.(string)); ok && start.Before(cutoff) {
+				expired = append(expired, key.(string))
 			}
+			return true
+		})
+	}
+
+	var evicted int64
+	for _, traceID := range expired {
+		if s.evictTrace(traceID) {
+			evicted++
 		}
-		return true
-	})
+	}
 
-	// Sort by start time (oldest first)
-	sort.Slice(traces, func(i, j int) bool {
-		return traces[i].startTime.Before(traces[j].startTime)
-	})
+	s.mu.Lock()
+	s.evictions.Retention += evicted
+	s.mu.Unlock()
+}
 
-	// Evict oldest n traces
-	for i := 0; i < n && i < len(traces); i++ {
-		s.evictTrace(traces[i].traceID)
+// evictOldTraces removes the n least recently written traces. Each one is
+// found by comparing the shards' oldest entries, O(shards) per trace.
+func (s *MemoryStore) evictOldTraces(n int) {
+	var evicted int64
+	for evicted < int64(n) {
+		sh := s.oldestShard()
+		if sh == nil {
+			break
+		}
+		traceID, ok := sh.lru.popOldest()
+		if !ok {
+			continue // Emptied concurrently
+		}
+		// A trace evicted concurrently (e.g. by the janitor) may still be
+		// listed; it does not count
+		if s.evictTrace(traceID) {
+			evicted++
+		}
 	}
+
+	s.mu.Lock()
+	s.evictions.Capacity += evicted
+	s.mu.Unlock()
+}
+
+// Evictions returns the number of traces evicted for capacity and retention.
+func (s *MemoryStore) Evictions() EvictionStats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.evictions
 }
 
 // evictTrace removes a trace and all its spans from storage and indexes.
-func (s *MemoryStore) evictTrace(traceID string) {
-	// Get span IDs
-	value, ok := s.traces.Load(traceID)
+// It reports false if the trace was already gone.
+func (s *MemoryStore) evictTrace(traceID string) bool {
+	// Delete trace
+	ts, ok := s.removeTrace(traceID)
 	if !ok {
-		return
+		return false
 	}
-
-	spanIDs := value.([]string)
+	sh := s.shardFor(traceID)
+	sh.lru.remove(traceID)
 
 	// Delete all spans
-	for _, spanID := range spanIDs {
-		s.spans.Delete(spanID)
+	for _, spanID := range ts.ids {
+		sh.spans.Delete(spanID)
 	}
 
-	// Delete trace
-	s.traces.Delete(traceID)
-
 	// Decrement trace counter
-	s.mu.Lock()
-	s.traceCount--
-	s.mu.Unlock()
-
-	// Clean up indexes (simplified - in production, would track references)
-	s.indexMu.Lock()
-	defer s.indexMu.Unlock()
+	s.traceCount.Add(-1)
+
+	// Clean up indexes
+	sh.indexMu.Lock()
+	defer sh.indexMu.Unlock()
+	indexes := sh.indexes
+
+	// Remove from the services and hours the trace was indexed under,
+	// dropping entries left empty so they do not linger
+	for _, service := range ts.services {
+		traceIDs := indexes.byService[service]
+		if delete(traceIDs, traceID); len(traceIDs) == 0 {
+			delete(indexes.byService, service)
+		}
+	}
 
-	// Remove from all indexes
-	for service := range s.indexes.byService {
-		s.indexes.byService[service] = s.removeString(s.indexes.byService[service], traceID)
+	for _, hour := range ts.hours {
+		traceIDs := indexes.byTimestamp.buckets[hour]
+		if delete(traceIDs, traceID); len(traceIDs) == 0 {
+			delete(indexes.byTimestamp.buckets, hour)
+		}
 	}
 
-	for hour := range s.indexes.byTimestamp.buckets {
-		s.indexes.byTimestamp.buckets[hour] = s.removeString(s.indexes.byTimestamp.buckets[hour], traceID)
+	delete(indexes.byError, traceID)
+	indexes.unindexDurationAndCost(traceID)
+	return true
+}
+
+// unindexError removes one failed span of a trace from the error index.
+// Caller must hold the shard's indexMu.
+func (x *Indexes) unindexError(traceID string) {
+	if n := x.byError[traceID] - 1; n > 0 {
+		x.byError[traceID] = n
+	} else {
+		delete(x.byError, traceID)
 	}
+}
 
-	s.indexes.byDuration.fast = s.removeString(s.indexes.byDuration.fast, traceID)
-	s.indexes.byDuration.medium = s.removeString(s.indexes.byDuration.medium, traceID)
-	s.indexes.byDuration.slow = s.removeString(s.indexes.byDuration.slow, traceID)
-	s.indexes.byDuration.verySlow = s.removeString(s.indexes.byDuration.verySlow, traceID)
+// unindexDurationAndCost removes a trace from all duration and cost buckets.
+// Caller must hold the shard's indexMu.
+func (x *Indexes) unindexDurationAndCost(traceID string) {
+	x.byDuration.remove(traceID)
+	x.byCost.remove(traceID)
+}
 
-	s.indexes.byCost.cheap = s.removeString(s.indexes.byCost.cheap, traceID)
-	s.indexes.byCost.moderate = s.removeString(s.indexes.byCost.moderate, traceID)
-	s.indexes.byCost.expensive = s.removeString(s.indexes.byCost.expensive, traceID)
+// DurationPercentile estimates the q-quantile (0 <= q <= 1) of completed
+// trace durations from the shards' duration indexes, e.g. 0.99 for p99.
+func (s *MemoryStore) DurationPercentile(q float64) time.Duration {
+	merged := s.mergedHistogram(s.durationBounds, func(x *Indexes) *histogram.Histogram { return x.byDuration.hist })
+	return secondsToDuration(merged.Quantile(q))
 }
 
-// Helper functions
+// CostPercentile estimates the q-quantile (0 <= q <= 1) of completed trace
+// costs from the shards' cost indexes.
+func (s *MemoryStore) CostPercentile(q float64) float64 {
+	merged := s.mergedHistogram(s.costBounds, func(x *Indexes) *histogram.Histogram { return x.byCost.hist })
+	return merged.Quantile(q)
+}
 
-func (s *MemoryStore) containsString(slice []string, str string) bool {
-	for _, s := range slice {
-		if s == str {
-			return true
-		}
+// traceSet is a set of trace IDs. Indexes hold sets so adding and removing
+// a trace cost O(1) however many traces an entry holds.
+type traceSet map[string]struct{}
+
+// add inserts traceID, allocating the set if it is nil, and returns the set.
+func (t traceSet) add(traceID string) traceSet {
+	if t == nil {
+		t = make(traceSet)
 	}
-	return false
+	t[traceID] = struct{}{}
+	return t
 }
 
-func (s *MemoryStore) removeString(slice []string, str string) []string {
-	result := make([]string, 0, len(slice))
-	for _, s := range slice {
-		if s != str {
-			result = append(result, s)
-		}
-	}
-	return result
+// has reports whether traceID is in the set.
+func (t traceSet) has(traceID string) bool {
+	_, ok := t[traceID]
+	return ok
 }
 
-func (s *MemoryStore) deduplicate(slice []string) []string {
-	seen := make(map[string]bool)
-	result := make([]string, 0, len(slice))
-	for _, s := range slice {
-		if !seen[s] {
-			seen[s] = true
-			result = append(result, s)
-		}
+// ids returns the set's trace IDs in no particular order.
+func (t traceSet) ids() []string {
+	ids := make([]string, 0, len(t))
+	for traceID := range t {
+		ids = append(ids, traceID)
 	}
-	return result
+	return ids
 }
@@ -5,7 +5,7 @@ import (
 	"testing"
 	"time"
 
-	"github.com/saintparish4/asmbly/internal/models"
+	"github.com/saintparish4/asmbly/models"
 )
 
 func BenchmarkWriteSpan_Sequential(b *testing.B) {
@@ -327,7 +327,7 @@ func BenchmarkIndexUpdate(b *testing.B) {
 	b.ReportAllocs()
 
 	for i := 0; i < b.N; i++ {
-		store.updateIndexes(spans[i])
+		store.shardFor(spans[i].TraceID).updateIndexes(spans[i], nil)
 	}
 }
 
@@ -398,3 +398,33 @@ func BenchmarkEviction(b *testing.B) {
 		store.WriteSpan(ctx, span) // This will trigger eviction
 	}
 }
+
+// BenchmarkWriteSpan_LargeTrace measures writes into a single growing trace.
+// Per-write cost should stay flat as the trace grows.
+func BenchmarkWriteSpan_LargeTrace(b *testing.B) {
+	store := NewMemoryStore(100000)
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+
+	spans := make([]*models.Span, b.N)
+	for i := 0; i < b.N; i++ {
+		spans[i] = &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "benchmark-service",
+			OperationName: "benchmark-op",
+			StartTime:     time.Now(),
+			Duration:      50 * time.Millisecond,
+			Status:        "ok",
+		}
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := store.WriteSpan(ctx, spans[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,11 +2,13 @@ package storage
 
 import (
 	"context"
+	"encoding/json"
 	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
-	"github.com/saintparish4/asmbly/internal/models"
+	"github.com/saintparish4/asmbly/models"
 )
 
 func TestWriteSpan_SingleSpan(t *testing.T) {
@@ -29,7 +31,7 @@ func TestWriteSpan_SingleSpan(t *testing.T) {
 	}
 
 	// Verify span was stored
-	value, ok := store.spans.Load(span.SpanID)
+	value, ok := store.shardFor(span.TraceID).spans.Load(span.SpanID)
 	if !ok {
 		t.Fatal("span not found in storage")
 	}
@@ -104,10 +106,12 @@ func TestWriteSpan_Concurrent(t *testing.T) {
 
 	// Verify all spans were stored
 	count := 0
-	store.spans.Range(func(key, value interface{}) bool {
-		count++
-		return true
-	})
+	for _, sh := range store.shards {
+		sh.spans.Range(func(key, value interface{}) bool {
+			count++
+			return true
+		})
+	}
 
 	expected := goroutines * spansPerGoroutine
 	if count != expected {
@@ -414,14 +418,160 @@ func TestEviction(t *testing.T) {
 
 	// Count traces
 	count := 0
-	store.traces.Range(func(key, value interface{}) bool {
-		count++
-		return true
-	})
+	for _, sh := range store.shards {
+		sh.traces.Range(func(key, value interface{}) bool {
+			count++
+			return true
+		})
+	}
 
 	if count > 5 {
 		t.Errorf("stored %d traces, want <= 5 (eviction failed)", count)
 	}
+	if got := store.Evictions(); got.Capacity != int64(10-count) || got.Retention != 0 {
+		t.Errorf("Evictions() = %+v, want %d for capacity", got, 10-count)
+	}
+}
+
+func TestEvictTrace_RemovesItsIndexEntries(t *testing.T) {
+	store := NewMemoryStore(100)
+	ctx := context.Background()
+	now := time.Now()
+
+	// Two traces in one shard share the api service and the current hour
+	traceID := models.GenerateTraceID()
+	other := models.GenerateTraceID()
+	for store.shardFor(other) != store.shardFor(traceID) {
+		other = models.GenerateTraceID()
+	}
+	spans := []*models.Span{
+		{TraceID: traceID, ServiceName: "api", StartTime: now},
+		{TraceID: traceID, ServiceName: "db", StartTime: now.Add(-2 * time.Hour)},
+		{TraceID: other, ServiceName: "api", StartTime: now},
+	}
+	for _, span := range spans {
+		span.SpanID = models.GenerateSpanID()
+		span.OperationName = "op"
+		span.Status = "ok"
+		if err := store.WriteSpan(ctx, span); err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+	}
+
+	if !store.evictTrace(traceID) {
+		t.Fatal("evictTrace() = false")
+	}
+	sh := store.shardFor(traceID)
+	sh.indexMu.RLock()
+	defer sh.indexMu.RUnlock()
+	if _, ok := sh.indexes.byService["db"]; ok {
+		t.Error("db service entry left after its only trace was evicted")
+	}
+	if _, ok := sh.indexes.byTimestamp.buckets[hourBucket(now.Add(-2*time.Hour))]; ok {
+		t.Error("hour bucket left after its only trace was evicted")
+	}
+	if api := sh.indexes.byService["api"]; len(api) != 1 || !api.has(other) {
+		t.Errorf("api entry = %v, want only the other trace", api.ids())
+	}
+	if hour := sh.indexes.byTimestamp.buckets[hourBucket(now)]; len(hour) != 1 || !hour.has(other) {
+		t.Errorf("current hour = %v, want only the other trace", hour.ids())
+	}
+}
+
+func TestEviction_LeastRecentlyWritten(t *testing.T) {
+	store := NewMemoryStore(3)
+	ctx := context.Background()
+
+	write := func(traceID string) {
+		t.Helper()
+		err := store.WriteSpan(ctx, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "op",
+			StartTime:     time.Now(),
+			Status:        "ok",
+		})
+		if err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+	}
+
+	// The oldest trace keeps receiving spans, so the second is evicted
+	longRunning, idle := models.GenerateTraceID(), models.GenerateTraceID()
+	write(longRunning)
+	write(idle)
+	write(models.GenerateTraceID())
+	write(longRunning)
+	write(models.GenerateTraceID())
+
+	if _, ok := store.shardFor(idle).traces.Load(idle); ok {
+		t.Error("least recently written trace was kept")
+	}
+	if _, ok := store.shardFor(longRunning).traces.Load(longRunning); !ok {
+		t.Error("recently written trace was evicted")
+	}
+	tracked := 0
+	for _, sh := range store.shards {
+		tracked += sh.lru.len()
+	}
+	if tracked != 3 || store.traceTotal() != 3 {
+		t.Errorf("lru tracks %d traces, store counts %d, want 3", tracked, store.traceTotal())
+	}
+}
+
+func TestWithShards(t *testing.T) {
+	buckets, err := NewDurationBuckets([]time.Duration{time.Second, time.Minute})
+	if err != nil {
+		t.Fatalf("NewDurationBuckets failed: %v", err)
+	}
+	store := NewMemoryStore(50).WithDurationBuckets(buckets).WithShards(4)
+	ctx := context.Background()
+
+	// 100 traces in 4 shards; capacity keeps the 50 most recently written
+	var traceIDs []string
+	for i := 0; i < 100; i++ {
+		traceID := models.GenerateTraceID()
+		traceIDs = append(traceIDs, traceID)
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   []string{"api", "db", "cache"}[i%3],
+			OperationName: "op",
+			StartTime:     time.Now(),
+			Duration:      30 * time.Second,
+			Status:        "ok",
+		})
+	}
+
+	if len(store.shards) != 4 {
+		t.Fatalf("store has %d shards, want 4", len(store.shards))
+	}
+	for i, sh := range store.shards {
+		if sh.lru.len() == 0 {
+			t.Errorf("shard %d holds no traces", i)
+		}
+		if bounds := sh.indexes.byDuration.Bounds(); len(bounds) != 2 {
+			t.Errorf("shard %d duration bounds = %v, want the configured ones", i, bounds)
+		}
+	}
+	for i, traceID := range traceIDs {
+		_, kept := store.shardFor(traceID).traces.Load(traceID)
+		if kept != (i >= 50) {
+			t.Errorf("trace %d kept = %v, want %v", i, kept, i >= 50)
+		}
+	}
+
+	// Reads combine every shard
+	if services, _ := store.GetServices(ctx); len(services) != 3 {
+		t.Errorf("GetServices() = %v, want 3 services", services)
+	}
+	if traces, _ := store.FindTraces(ctx, NewQuery()); len(traces) != 50 {
+		t.Errorf("FindTraces returned %d traces, want 50", len(traces))
+	}
+	if p50 := store.DurationPercentile(0.5); p50 < time.Second || p50 > time.Minute {
+		t.Errorf("p50 duration = %v, want within 1s-1m", p50)
+	}
 }
 
 func TestIndexing_ServiceIndex(t *testing.T) {
@@ -442,15 +592,13 @@ func TestIndexing_ServiceIndex(t *testing.T) {
 	store.WriteSpan(ctx, span)
 
 	// Check service index
-	store.indexMu.RLock()
-	traceIDs := store.indexes.byService["test-service"]
-	store.indexMu.RUnlock()
+	sh := store.shardFor(traceID)
+	sh.indexMu.RLock()
+	traceIDs := sh.indexes.byService["test-service"]
+	sh.indexMu.RUnlock()
 
-	if len(traceIDs) != 1 {
-		t.Errorf("service index has %d traces, want 1", len(traceIDs))
-	}
-	if traceIDs[0] != traceID {
-		t.Errorf("service index trace ID = %s, want %s", traceIDs[0], traceID)
+	if len(traceIDs) != 1 || !traceIDs.has(traceID) {
+		t.Errorf("service index = %v, want only %s", traceIDs.ids(), traceID)
 	}
 }
 
@@ -474,15 +622,13 @@ func TestIndexing_TimestampBuckets(t *testing.T) {
 
 	// Check time bucket
 	hourBucket := now.Unix() / 3600
-	store.indexMu.RLock()
-	traceIDs := store.indexes.byTimestamp.buckets[hourBucket]
-	store.indexMu.RUnlock()
+	sh := store.shardFor(traceID)
+	sh.indexMu.RLock()
+	traceIDs := sh.indexes.byTimestamp.buckets[hourBucket]
+	sh.indexMu.RUnlock()
 
-	if len(traceIDs) != 1 {
-		t.Errorf("time bucket has %d traces, want 1", len(traceIDs))
-	}
-	if traceIDs[0] != traceID {
-		t.Errorf("time bucket trace ID = %s, want %s", traceIDs[0], traceID)
+	if len(traceIDs) != 1 || !traceIDs.has(traceID) {
+		t.Errorf("time bucket = %v, want only %s", traceIDs.ids(), traceID)
 	}
 }
 
@@ -490,15 +636,18 @@ func TestIndexing_DurationBuckets(t *testing.T) {
 	store := NewMemoryStore(1000)
 	ctx := context.Background()
 
+	// Default buckets double from 1ms
 	tests := []struct {
 		name     string
 		duration time.Duration
 		bucket   string
 	}{
-		{"fast", 5 * time.Millisecond, "fast"},
-		{"medium", 50 * time.Millisecond, "medium"},
-		{"slow", 500 * time.Millisecond, "slow"},
-		{"verySlow", 2000 * time.Millisecond, "verySlow"},
+		{"sub-millisecond", 500 * time.Microsecond, "<1ms"},
+		{"fast", 5 * time.Millisecond, "4ms-8ms"},
+		{"medium", 50 * time.Millisecond, "32ms-64ms"},
+		{"slow", 500 * time.Millisecond, "256ms-512ms"},
+		{"verySlow", 2000 * time.Millisecond, "1.024s-2.048s"},
+		{"overflow", time.Hour, ">=2m11.072s"},
 	}
 
 	for _, tt := range tests {
@@ -517,28 +666,424 @@ func TestIndexing_DurationBuckets(t *testing.T) {
 			store.WriteSpan(ctx, span)
 
 			// Check appropriate bucket
-			store.indexMu.RLock()
-			var found bool
-			switch tt.bucket {
-			case "fast":
-				found = store.containsString(store.indexes.byDuration.fast, traceID)
-			case "medium":
-				found = store.containsString(store.indexes.byDuration.medium, traceID)
-			case "slow":
-				found = store.containsString(store.indexes.byDuration.slow, traceID)
-			case "verySlow":
-				found = store.containsString(store.indexes.byDuration.verySlow, traceID)
+			sh := store.shardFor(traceID)
+			sh.indexMu.RLock()
+			defer sh.indexMu.RUnlock()
+			byDuration := sh.indexes.byDuration
+			i := byDuration.hist.Bucket(tt.duration.Seconds())
+			if label := byDuration.Label(i); label != tt.bucket {
+				t.Errorf("%v indexed in %s bucket, want %s", tt.duration, label, tt.bucket)
 			}
-			store.indexMu.RUnlock()
-
-			if !found {
+			if !byDuration.traces[i].has(traceID) {
 				t.Errorf("trace not found in %s bucket", tt.bucket)
 			}
 		})
 	}
 }
 
+func TestIndexing_CustomDurationBuckets(t *testing.T) {
+	buckets, err := NewDurationBuckets([]time.Duration{time.Second, time.Minute, 10 * time.Minute})
+	if err != nil {
+		t.Fatalf("NewDurationBuckets failed: %v", err)
+	}
+	store := NewMemoryStore(1000).WithDurationBuckets(buckets)
+	ctx := context.Background()
+
+	tests := []struct {
+		duration time.Duration
+		label    string
+	}{
+		{500 * time.Millisecond, "<1s"},
+		{30 * time.Second, "1s-1m0s"},
+		{5 * time.Minute, "1m0s-10m0s"},
+		{time.Hour, ">=10m0s"},
+	}
+
+	for i, tt := range tests {
+		traceID := models.GenerateTraceID()
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "batch",
+			OperationName: "nightly-export",
+			StartTime:     time.Now(),
+			Duration:      tt.duration,
+			Status:        "ok",
+		})
+
+		if label := buckets.Label(i); label != tt.label {
+			t.Errorf("bucket %d label = %q, want %q", i, label, tt.label)
+		}
+		sh := store.shardFor(traceID)
+		sh.indexMu.RLock()
+		found := sh.indexes.byDuration.traces[i].has(traceID)
+		sh.indexMu.RUnlock()
+		if !found {
+			t.Errorf("%v trace not found in %s bucket", tt.duration, tt.label)
+		}
+	}
+}
+
+func TestNewDurationBuckets_Validation(t *testing.T) {
+	tests := []struct {
+		name   string
+		bounds []time.Duration
+	}{
+		{"empty", nil},
+		{"zero", []time.Duration{0, time.Second}},
+		{"descending", []time.Duration{time.Second, time.Millisecond}},
+		{"duplicate", []time.Duration{time.Second, time.Second}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewDurationBuckets(tt.bounds); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+func TestParseDurationBuckets(t *testing.T) {
+	bounds, err := ParseDurationBuckets("10ms, 100ms,1s")
+	if err != nil {
+		t.Fatalf("ParseDurationBuckets failed: %v", err)
+	}
+	want := []time.Duration{10 * time.Millisecond, 100 * time.Millisecond, time.Second}
+	if len(bounds) != len(want) {
+		t.Fatalf("got %v, want %v", bounds, want)
+	}
+	for i := range want {
+		if bounds[i] != want[i] {
+			t.Errorf("bound %d = %v, want %v", i, bounds[i], want[i])
+		}
+	}
+
+	if _, err := ParseDurationBuckets("10ms,fast"); err == nil {
+		t.Error("expected an error for an invalid bound")
+	}
+}
+
+func TestInProgressSpan_CompletedByLaterWrite(t *testing.T) {
+	store := NewMemoryStore(1000)
+	ctx := context.Background()
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "batch-worker",
+		OperationName: "nightly-export",
+		StartTime:     time.Now(),
+		Status:        "ok",
+		InProgress:    true,
+	}
+	if err := store.WriteSpan(ctx, span); err != nil {
+		t.Fatalf("WriteSpan failed: %v", err)
+	}
+
+	inProgress := true
+	query := NewQuery()
+	query.InProgress = &inProgress
+
+	traces, err := store.FindTraces(ctx, query)
+	if err != nil {
+		t.Fatalf("FindTraces failed: %v", err)
+	}
+	if len(traces) != 1 || !traces[0].InProgress {
+		t.Fatalf("expected 1 in-progress trace, got %d", len(traces))
+	}
+
+	// Completion update keyed by the same SpanID
+	completed := *span
+	completed.InProgress = false
+	completed.Duration = 2 * time.Second
+	if err := store.WriteSpan(ctx, &completed); err != nil {
+		t.Fatalf("WriteSpan (completion) failed: %v", err)
+	}
+
+	trace, err := store.GetTrace(ctx, span.TraceID)
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	if len(trace.Spans) != 1 {
+		t.Fatalf("trace has %d spans, want 1", len(trace.Spans))
+	}
+	if trace.InProgress {
+		t.Error("trace should no longer be in progress")
+	}
+	if trace.Duration != 2*time.Second {
+		t.Errorf("trace duration = %v, want 2s", trace.Duration)
+	}
+
+	traces, err = store.FindTraces(ctx, query)
+	if err != nil {
+		t.Fatalf("FindTraces failed: %v", err)
+	}
+	if len(traces) != 0 {
+		t.Errorf("found %d in-progress traces after completion, want 0", len(traces))
+	}
+}
+
+func TestWriteSpan_UpsertReindexes(t *testing.T) {
+	store := NewMemoryStore(1000)
+	ctx := context.Background()
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "test-service",
+		OperationName: "test-op",
+		StartTime:     time.Now(),
+		Duration:      5 * time.Millisecond,
+		Status:        "ok",
+	}
+	if err := store.WriteSpan(ctx, span); err != nil {
+		t.Fatalf("WriteSpan failed: %v", err)
+	}
+
+	// Same SpanID, new values
+	updated := *span
+	updated.Duration = 500 * time.Millisecond
+	updated.Cost = 0.01
+	if err := store.WriteSpan(ctx, &updated); err != nil {
+		t.Fatalf("WriteSpan (update) failed: %v", err)
+	}
+
+	sh := store.shardFor(span.TraceID)
+	sh.indexMu.RLock()
+	byDuration, byCost := sh.indexes.byDuration, sh.indexes.byCost
+	inFast := byDuration.traces[byDuration.hist.Bucket(0.005)].has(span.TraceID)
+	inSlow := byDuration.traces[byDuration.hist.Bucket(0.5)].has(span.TraceID)
+	inCheap := byCost.traces[byCost.hist.Bucket(0)].has(span.TraceID)
+	inExpensive := byCost.traces[byCost.hist.Bucket(0.01)].has(span.TraceID)
+	durationCount, costCount := byDuration.hist.Count(), byCost.hist.Count()
+	sh.indexMu.RUnlock()
+
+	if durationCount != 1 || costCount != 1 {
+		t.Errorf("histogram counts = %d, %d; a replaced span must not be counted twice", durationCount, costCount)
+	}
+
+	if inFast || !inSlow {
+		t.Errorf("duration buckets not reindexed: fast=%v slow=%v", inFast, inSlow)
+	}
+	if inCheap || !inExpensive {
+		t.Errorf("cost buckets not reindexed: cheap=%v expensive=%v", inCheap, inExpensive)
+	}
+
+	if spanCount := store.spanCount.Load(); spanCount != 1 {
+		t.Errorf("spanCount = %d, want 1 after replacement", spanCount)
+	}
+
+	trace, err := store.GetTrace(ctx, span.TraceID)
+	if err != nil {
+		t.Fatalf("GetTrace failed: %v", err)
+	}
+	if len(trace.Spans) != 1 || trace.Spans[0].Duration != 500*time.Millisecond {
+		t.Errorf("trace should hold only the replacement span")
+	}
+}
+
 // Helper function to create a simple test trace
+func TestRetention_ExpiresAtAndMinTTL(t *testing.T) {
+	store := NewMemoryStore(1000).WithRetention(time.Hour)
+	ctx := context.Background()
+
+	write := func(start time.Time) string {
+		span := &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /",
+			StartTime:     start,
+			Status:        "ok",
+		}
+		if err := store.WriteSpan(ctx, span); err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+		return span.TraceID
+	}
+
+	now := time.Now()
+	fresh := write(now)
+	aging := write(now.Add(-50 * time.Minute))
+
+	trace, _ := store.GetTrace(ctx, fresh)
+	if trace.ExpiresAt == nil || !trace.ExpiresAt.Equal(trace.StartTime.Add(time.Hour)) {
+		t.Fatalf("expires_at = %v, want start + 1h", trace.ExpiresAt)
+	}
+
+	// Exclude traces with less than 30m left
+	query := NewQuery()
+	query.MinTTL = 30 * time.Minute
+	traces, err := store.FindTraces(ctx, query)
+	if err != nil {
+		t.Fatalf("FindTraces failed: %v", err)
+	}
+	if len(traces) != 1 || traces[0].TraceID != fresh {
+		t.Errorf("expected only the fresh trace, got %d traces", len(traces))
+	}
+
+	// Past retention: hidden immediately and removed by the next sweep
+	expired := write(now.Add(-2 * time.Hour))
+	if trace, _ := store.GetTrace(ctx, expired); trace != nil {
+		t.Error("expired trace should not be returned")
+	}
+	if traces, _ := store.FindTraces(ctx, NewQuery()); len(traces) != 2 {
+		t.Errorf("FindTraces returned %d traces, want 2 without the expired one", len(traces))
+	}
+	store.evictExpired(now)
+	if _, ok := store.shardFor(expired).traces.Load(expired); ok {
+		t.Error("expired trace was not evicted")
+	}
+	if _, ok := store.shardFor(aging).traces.Load(aging); !ok {
+		t.Error("trace within retention was evicted")
+	}
+	if got := store.Evictions(); got.Retention != 1 {
+		t.Errorf("Evictions() = %+v, want 1 for retention", got)
+	}
+}
+
+func TestRetention_JanitorRemovesExpiredTraces(t *testing.T) {
+	store := NewMemoryStore(1000).WithRetention(time.Hour).StartJanitor(10 * time.Millisecond)
+	defer store.Close()
+	ctx := context.Background()
+
+	span := &models.Span{
+		TraceID:       models.GenerateTraceID(),
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "legacy",
+		OperationName: "GET /",
+		StartTime:     time.Now().Add(-2 * time.Hour),
+		Status:        "ok",
+	}
+	if err := store.WriteSpan(ctx, span); err != nil {
+		t.Fatalf("WriteSpan failed: %v", err)
+	}
+
+	// No further writes: the janitor alone frees the trace
+	deadline := time.Now().Add(time.Second)
+	for store.Evictions().Retention == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, ok := store.shardFor(span.TraceID).traces.Load(span.TraceID); ok {
+		t.Fatal("expired trace was not removed by the janitor")
+	}
+	if _, ok := store.shardFor(span.TraceID).spans.Load(span.SpanID); ok {
+		t.Error("span of the expired trace was kept")
+	}
+
+	// Index entries left empty are pruned
+	services, _ := store.GetServices(ctx)
+	sh := store.shardFor(span.TraceID)
+	sh.indexMu.RLock()
+	buckets := len(sh.indexes.byTimestamp.buckets)
+	sh.indexMu.RUnlock()
+	if len(services) != 0 || buckets != 0 {
+		t.Errorf("services %v and %d time buckets left after expiry", services, buckets)
+	}
+
+	if err := store.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+}
+
+func TestRetention_ExpiresByEarliestSpan(t *testing.T) {
+	store := NewMemoryStore(1000).WithRetention(time.Hour)
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+	rootID := models.GenerateSpanID()
+
+	// The child arrives first but starts within retention; the root started
+	// two hours ago, so the trace as a whole has expired
+	store.WriteSpan(ctx, &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ParentSpanID:  rootID,
+		ServiceName:   "db",
+		OperationName: "SELECT",
+		StartTime:     time.Now().Add(-50 * time.Minute),
+		Status:        "ok",
+	})
+	store.WriteSpan(ctx, &models.Span{
+		TraceID:       traceID,
+		SpanID:        rootID,
+		ServiceName:   "api",
+		OperationName: "GET /report",
+		StartTime:     time.Now().Add(-2 * time.Hour),
+		Duration:      90 * time.Minute,
+		Status:        "ok",
+	})
+
+	store.evictExpired(time.Now())
+	if _, ok := store.shardFor(traceID).traces.Load(traceID); ok {
+		t.Fatal("trace whose root started before the retention cutoff was kept")
+	}
+	if got := store.Evictions(); got.Retention != 1 {
+		t.Errorf("Evictions() = %+v, want 1 for retention", got)
+	}
+}
+
+func TestRetention_UpsertMovesTraceStart(t *testing.T) {
+	store := NewMemoryStore(1000).WithRetention(time.Hour)
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+	now := time.Now()
+
+	root := &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "GET /report",
+		StartTime:     now.Add(-10 * time.Minute),
+		Status:        "ok",
+		InProgress:    true,
+	}
+	child := &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ParentSpanID:  root.SpanID,
+		ServiceName:   "db",
+		OperationName: "SELECT",
+		StartTime:     now.Add(-30 * time.Minute),
+		Status:        "ok",
+	}
+	store.WriteSpan(ctx, root)
+	store.WriteSpan(ctx, child)
+
+	// The completed root reports its real, earlier start
+	completed := *root
+	completed.StartTime = now.Add(-2 * time.Hour)
+	completed.Duration = 90 * time.Minute
+	completed.InProgress = false
+	store.WriteSpan(ctx, &completed)
+	if start, _ := store.traceStart(traceID); !start.Equal(completed.StartTime) {
+		t.Errorf("start after an earlier upsert = %v, want %v", start, completed.StartTime)
+	}
+
+	// Moving the earliest span later falls back to the next earliest
+	corrected := completed
+	corrected.StartTime = now.Add(-5 * time.Minute)
+	store.WriteSpan(ctx, &corrected)
+	if start, _ := store.traceStart(traceID); !start.Equal(child.StartTime) {
+		t.Errorf("start after a later upsert = %v, want %v", start, child.StartTime)
+	}
+
+	store.WriteSpan(ctx, &completed)
+	store.evictExpired(now)
+	if _, ok := store.shardFor(traceID).traces.Load(traceID); ok {
+		t.Error("trace whose upserted root started before the cutoff was kept")
+	}
+}
+
+func TestRetention_DisabledOmitsExpiresAt(t *testing.T) {
+	store := NewMemoryStore(1000)
+	traceID := createTestTrace(t, store, "api", 10*time.Millisecond)
+
+	trace, _ := store.GetTrace(context.Background(), traceID)
+	if trace.ExpiresAt != nil {
+		t.Errorf("expires_at = %v, want nil without retention", trace.ExpiresAt)
+	}
+}
+
 func createTestTrace(t *testing.T, store *MemoryStore, serviceName string, duration time.Duration) string {
 	t.Helper()
 
@@ -559,3 +1104,568 @@ func createTestTrace(t *testing.T, store *MemoryStore, serviceName string, durat
 
 	return traceID
 }
+
+func TestWriteSpan_LargeTraceDeduplicates(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+
+	var spans []*models.Span
+	for i := 0; i < 1000; i++ {
+		span := &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "op",
+			StartTime:     time.Now(),
+			Status:        "ok",
+		}
+		spans = append(spans, span)
+		store.WriteSpan(ctx, span)
+	}
+	// Retries of known spans are upserts, not additions
+	for _, span := range spans[:100] {
+		store.WriteSpan(ctx, span)
+	}
+
+	ids := store.traceSpanIDs(traceID)
+	if len(ids) != 1000 {
+		t.Fatalf("trace has %d span IDs, want 1000", len(ids))
+	}
+	if ids[0] != spans[0].SpanID || ids[999] != spans[999].SpanID {
+		t.Error("span IDs should keep arrival order")
+	}
+	if n := store.traceCount.Load(); n != 1 {
+		t.Errorf("traceCount = %d, want 1", n)
+	}
+}
+
+func TestGetTrace_CachesCompletedTraces(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+
+	root := &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "GET /users",
+		StartTime:     time.Now(),
+		Duration:      10 * time.Millisecond,
+		Status:        "ok",
+	}
+	store.WriteSpan(ctx, root)
+
+	first, _ := store.GetTrace(ctx, traceID)
+	second, _ := store.GetTrace(ctx, traceID)
+	if first == nil || first != second {
+		t.Fatal("repeat reads of a completed trace should return the cached trace")
+	}
+
+	// A new span invalidates the cache
+	store.WriteSpan(ctx, &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ParentSpanID:  root.SpanID,
+		ServiceName:   "db",
+		OperationName: "SELECT",
+		StartTime:     time.Now(),
+		Duration:      time.Millisecond,
+		Status:        "ok",
+	})
+	third, _ := store.GetTrace(ctx, traceID)
+	if third == first {
+		t.Fatal("write should invalidate the cached trace")
+	}
+	if len(third.Spans) != 2 {
+		t.Errorf("got %d spans, want 2", len(third.Spans))
+	}
+}
+
+func TestGetTrace_DoesNotCacheInProgressTraces(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+
+	store.WriteSpan(ctx, &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "GET /users",
+		StartTime:     time.Now(),
+		Status:        "ok",
+		InProgress:    true,
+	})
+
+	first, _ := store.GetTrace(ctx, traceID)
+	second, _ := store.GetTrace(ctx, traceID)
+	if first == nil || !first.InProgress {
+		t.Fatal("expected an in-progress trace")
+	}
+	if first == second {
+		t.Error("in-progress traces should be reassembled on every read")
+	}
+}
+
+func TestGetTrace_ConcurrentWritesInvalidateCache(t *testing.T) {
+	store := NewMemoryStore(10000)
+	ctx := context.Background()
+	start := time.Now()
+
+	// For each trace, readers assemble and cache the completed trace
+	// while its second span is written; no read may leave behind a cached
+	// assembly that lacks the span
+	const traces = 2000
+	for i := 0; i < traces; i++ {
+		traceID := models.GenerateTraceID()
+		root := &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "GET /users",
+			StartTime:     start,
+			Duration:      10 * time.Millisecond,
+			Status:        "ok",
+		}
+		if err := store.WriteSpan(ctx, root); err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+
+		done := make(chan struct{})
+		var wg sync.WaitGroup
+		for r := 0; r < 4; r++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+						store.GetTrace(ctx, traceID)
+					}
+				}
+			}()
+		}
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ParentSpanID:  root.SpanID,
+			ServiceName:   "db",
+			OperationName: "SELECT",
+			StartTime:     start,
+			Duration:      time.Millisecond,
+			Status:        "ok",
+		})
+		close(done)
+		wg.Wait()
+
+		trace, _ := store.GetTrace(ctx, traceID)
+		if trace == nil || len(trace.Spans) != 2 {
+			t.Fatalf("trace %d: got %v, want 2 spans", i, trace)
+		}
+	}
+}
+
+func TestIndexing_CustomCostBucketsAndCurrency(t *testing.T) {
+	buckets, err := NewCostBuckets([]float64{1, 100})
+	if err != nil {
+		t.Fatalf("NewCostBuckets failed: %v", err)
+	}
+	store := NewMemoryStore(1000).WithCostBuckets(buckets).WithCurrency("USD_MICROS")
+	ctx := context.Background()
+
+	tests := []struct {
+		cost  float64
+		label string
+	}{
+		{0.5, "<1"},
+		{50, "1-100"},
+		{250, ">=100"},
+	}
+
+	for i, tt := range tests {
+		traceID := models.GenerateTraceID()
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "llm",
+			OperationName: "complete",
+			StartTime:     time.Now(),
+			Cost:          tt.cost,
+			Status:        "ok",
+		})
+
+		if label := buckets.Label(i); label != tt.label {
+			t.Errorf("bucket %d label = %q, want %q", i, label, tt.label)
+		}
+		sh := store.shardFor(traceID)
+		sh.indexMu.RLock()
+		found := sh.indexes.byCost.traces[i].has(traceID)
+		sh.indexMu.RUnlock()
+		if !found {
+			t.Errorf("cost %v trace not found in %s bucket", tt.cost, tt.label)
+		}
+
+		trace, _ := store.GetTrace(ctx, traceID)
+		if trace.Currency != "USD_MICROS" {
+			t.Errorf("currency = %q, want USD_MICROS", trace.Currency)
+		}
+	}
+}
+
+func TestGetTrace_CurrencyOmittedWithoutCost(t *testing.T) {
+	store := NewMemoryStore(10)
+	ctx := context.Background()
+	traceID := models.GenerateTraceID()
+	store.WriteSpan(ctx, &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "op",
+		StartTime:     time.Now(),
+		Status:        "ok",
+	})
+
+	trace, _ := store.GetTrace(ctx, traceID)
+	if trace.Currency != "" {
+		t.Errorf("currency = %q, want empty for a trace without cost", trace.Currency)
+	}
+}
+
+func TestNewCostBuckets_Validation(t *testing.T) {
+	for _, bounds := range [][]float64{nil, {0}, {-1, 1}, {1, 0.5}, {1, 1}} {
+		if _, err := NewCostBuckets(bounds); err == nil {
+			t.Errorf("NewCostBuckets(%v): expected an error", bounds)
+		}
+	}
+
+	bounds, err := ParseCostBuckets("0.01, 1")
+	if err != nil || len(bounds) != 2 || bounds[0] != 0.01 || bounds[1] != 1 {
+		t.Errorf("ParseCostBuckets = %v, %v", bounds, err)
+	}
+	if _, err := ParseCostBuckets("cheap"); err == nil {
+		t.Error("expected an error for an invalid bound")
+	}
+}
+
+func TestPercentiles_FromIndexHistograms(t *testing.T) {
+	store := NewMemoryStore(1000)
+	ctx := context.Background()
+
+	// 90 fast traces and 10 slow, expensive ones
+	for i := 0; i < 100; i++ {
+		duration, cost := 3*time.Millisecond, 0.00002
+		if i >= 90 {
+			duration, cost = 3*time.Second, 1.5
+		}
+		store.WriteSpan(ctx, &models.Span{
+			TraceID:       models.GenerateTraceID(),
+			SpanID:        models.GenerateSpanID(),
+			ServiceName:   "api",
+			OperationName: "op",
+			StartTime:     time.Now(),
+			Duration:      duration,
+			Cost:          cost,
+			Status:        "ok",
+		})
+	}
+
+	// Estimates fall inside the bucket holding the true value
+	if p50 := store.DurationPercentile(0.5); p50 < 2*time.Millisecond || p50 > 4*time.Millisecond {
+		t.Errorf("p50 duration = %v, want within 2ms-4ms", p50)
+	}
+	if p99 := store.DurationPercentile(0.99); p99 < 2048*time.Millisecond || p99 > 4096*time.Millisecond {
+		t.Errorf("p99 duration = %v, want within 2.048s-4.096s", p99)
+	}
+	if p99 := store.CostPercentile(0.99); p99 < 1.31 || p99 > 2.63 {
+		t.Errorf("p99 cost = %v, want within the 1.31-2.62 bucket", p99)
+	}
+}
+
+func TestParseBuckets_Exponential(t *testing.T) {
+	durations, err := ParseDurationBuckets("exponential:1s,10,3")
+	if err != nil {
+		t.Fatalf("ParseDurationBuckets failed: %v", err)
+	}
+	if len(durations) != 3 || durations[0] != time.Second || durations[2] != 100*time.Second {
+		t.Errorf("durations = %v, want [1s 10s 1m40s]", durations)
+	}
+
+	costs, err := ParseCostBuckets("exponential:0.01,4,2")
+	if err != nil || len(costs) != 2 || costs[1] != 0.04 {
+		t.Errorf("costs = %v, %v; want [0.01 0.04]", costs, err)
+	}
+
+	for _, spec := range []string{"exponential:1s,2", "exponential:fast,2,3", "exponential:1s,1,3", "exponential:1s,2,0"} {
+		if _, err := ParseDurationBuckets(spec); err == nil {
+			t.Errorf("ParseDurationBuckets(%q): expected an error", spec)
+		}
+	}
+}
+
+func TestFindTraces_Sort(t *testing.T) {
+	store := NewMemoryStore(100)
+	ctx := context.Background()
+	base := time.Now()
+
+	// Start time, duration, cost and span count all rank the traces differently
+	traces := []struct {
+		start    time.Duration
+		duration time.Duration
+		cost     float64
+		spans    int
+	}{
+		{0, 30 * time.Millisecond, 0.2, 1},
+		{time.Second, 10 * time.Millisecond, 0.3, 2},
+		{2 * time.Second, 20 * time.Millisecond, 0.1, 3},
+	}
+	ids := make([]string, len(traces))
+	for i, tr := range traces {
+		ids[i] = models.GenerateTraceID()
+		rootID := models.GenerateSpanID()
+		for j := 0; j < tr.spans; j++ {
+			span := &models.Span{
+				TraceID:       ids[i],
+				SpanID:        rootID,
+				ServiceName:   "api",
+				OperationName: "op",
+				StartTime:     base.Add(tr.start),
+				Duration:      tr.duration,
+				Status:        "ok",
+			}
+			if j == 0 {
+				span.Cost = tr.cost
+			} else {
+				span.SpanID = models.GenerateSpanID()
+				span.ParentSpanID = rootID
+				span.Duration = time.Millisecond
+			}
+			store.WriteSpan(ctx, span)
+		}
+	}
+
+	tests := []struct {
+		by, order string
+		want      []int
+	}{
+		{"", "", []int{2, 1, 0}}, // Newest first
+		{SortByStartTime, SortAsc, []int{0, 1, 2}},
+		{SortByDuration, SortDesc, []int{0, 2, 1}},
+		{SortByCost, SortDesc, []int{1, 0, 2}},
+		{SortBySpanCount, SortAsc, []int{0, 1, 2}},
+	}
+	for _, tt := range tests {
+		results, err := store.FindTraces(ctx, NewQuery().WithSort(tt.by, tt.order))
+		if err != nil {
+			t.Fatalf("FindTraces failed: %v", err)
+		}
+		for i, want := range tt.want {
+			if results[i].TraceID != ids[want] {
+				t.Errorf("sort=%q order=%q: position %d is not trace %d", tt.by, tt.order, i, want)
+				break
+			}
+		}
+	}
+}
+
+func TestFindTraces_Tags(t *testing.T) {
+	store := NewMemoryStore(100)
+	ctx := context.Background()
+
+	// One trace has the status on its server span and the customer on its root
+	failed := models.GenerateTraceID()
+	rootID := models.GenerateSpanID()
+	spans := []*models.Span{
+		{TraceID: failed, SpanID: rootID, Tags: map[string]string{"customer_id": "c-42"}},
+		{TraceID: failed, SpanID: models.GenerateSpanID(), ParentSpanID: rootID, Tags: map[string]string{"http.status_code": "500"}},
+		{TraceID: models.GenerateTraceID(), SpanID: models.GenerateSpanID(), Tags: map[string]string{"http.status_code": "200"}},
+	}
+	for _, span := range spans {
+		span.ServiceName = "api"
+		span.OperationName = "op"
+		span.StartTime = time.Now()
+		span.Status = "ok"
+		if err := store.WriteSpan(ctx, span); err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		query *Query
+		want  int
+	}{
+		{"exact value", NewQuery().WithTag("http.status_code", "500"), 1},
+		{"key exists", NewQuery().WithTag("http.status_code", ""), 2},
+		{"tags on different spans", NewQuery().WithTag("http.status_code", "500").WithTag("customer_id", "c-42"), 1},
+		{"one tag missing", NewQuery().WithTag("http.status_code", "200").WithTag("customer_id", ""), 0},
+		{"unknown key", NewQuery().WithTag("region", ""), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			traces, err := store.FindTraces(ctx, tt.query)
+			if err != nil {
+				t.Fatalf("FindTraces failed: %v", err)
+			}
+			if len(traces) != tt.want {
+				t.Errorf("got %d traces, want %d", len(traces), tt.want)
+			}
+			if tt.want == 1 && traces[0].TraceID != failed {
+				t.Errorf("got trace %s, want %s", traces[0].TraceID, failed)
+			}
+		})
+	}
+}
+
+func TestFindTraces_ErrorsOnly(t *testing.T) {
+	store := NewMemoryStore(100)
+	ctx := context.Background()
+
+	write := func(traceID, spanID, status string) {
+		t.Helper()
+		err := store.WriteSpan(ctx, &models.Span{
+			TraceID:       traceID,
+			SpanID:        spanID,
+			ServiceName:   "api",
+			OperationName: "op",
+			StartTime:     time.Now(),
+			Status:        status,
+		})
+		if err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+	}
+	find := func() []*models.Trace {
+		t.Helper()
+		traces, err := store.FindTraces(ctx, NewQuery().WithErrorsOnly())
+		if err != nil {
+			t.Fatalf("FindTraces failed: %v", err)
+		}
+		return traces
+	}
+
+	failed, retried := models.GenerateTraceID(), models.GenerateTraceID()
+	retriedSpan := models.GenerateSpanID()
+	write(failed, models.GenerateSpanID(), "ok")
+	write(failed, models.GenerateSpanID(), "error")
+	write(retried, retriedSpan, "error")
+	write(models.GenerateTraceID(), models.GenerateSpanID(), "ok")
+
+	if traces := find(); len(traces) != 2 {
+		t.Fatalf("got %d failed traces, want 2", len(traces))
+	}
+
+	// An upsert that succeeds takes the trace out of the error index
+	write(retried, retriedSpan, "ok")
+	traces := find()
+	if len(traces) != 1 || traces[0].TraceID != failed {
+		t.Fatalf("after upsert got %v, want only %s", traces, failed)
+	}
+	if n := store.shardFor(retried).indexes.byError[retried]; n != 0 {
+		t.Errorf("error index still counts %d spans of the recovered trace", n)
+	}
+
+	store.evictTrace(failed)
+	if traces := find(); len(traces) != 0 || store.shardFor(failed).indexes.byError[failed] != 0 {
+		t.Errorf("after eviction got %d traces, index %v", len(traces), store.shardFor(failed).indexes.byError)
+	}
+}
+
+func TestFindTraces_ProjectMetadata(t *testing.T) {
+	store := NewMemoryStore(100)
+	ctx := context.Background()
+
+	traceID := models.GenerateTraceID()
+	err := store.WriteSpan(ctx, &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   "api",
+		OperationName: "op",
+		StartTime:     time.Now(),
+		Duration:      time.Millisecond,
+		Status:        "ok",
+		Tags:          map[string]string{"customer_id": "c-42"},
+	})
+	if err != nil {
+		t.Fatalf("WriteSpan failed: %v", err)
+	}
+
+	// Filters still see tags the projection leaves out
+	traces, err := store.FindTraces(ctx, NewQuery().WithTag("customer_id", "c-42").WithProjection(ProjectMetadata))
+	if err != nil {
+		t.Fatalf("FindTraces failed: %v", err)
+	}
+	if len(traces) != 1 || len(traces[0].Spans) != 1 {
+		t.Fatalf("got %d traces, want 1 with 1 span", len(traces))
+	}
+	span := traces[0].Spans[0]
+	if span.Tags != nil || span.SpanID == "" || span.Duration != time.Millisecond {
+		t.Errorf("projected span = %+v", span)
+	}
+	if traces[0].Duration != time.Millisecond || len(traces[0].Services) != 1 {
+		t.Errorf("trace fields not kept: %+v", traces[0])
+	}
+
+	// The stored trace is not modified
+	trace, _ := store.GetTrace(ctx, traceID)
+	if trace.Spans[0].GetTag("customer_id") != "c-42" {
+		t.Error("projection removed tags from the stored trace")
+	}
+}
+
+func TestWriteSpan_InternsRepeatedStrings(t *testing.T) {
+	store := NewMemoryStore(100)
+	ctx := context.Background()
+
+	// Decoded separately, as spans from different requests are
+	var written []models.Span
+	for i := 0; i < 2; i++ {
+		var span models.Span
+		data := `{"trace_id":"` + models.GenerateTraceID() + `","span_id":"` + models.GenerateSpanID() + `",` +
+			`"service_name":"checkout","operation_name":"POST /orders","start_time":"2024-01-01T00:00:00Z",` +
+			`"status":"ok","tags":{"http.method":"POST"}}`
+		if err := json.Unmarshal([]byte(data), &span); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.WriteSpan(ctx, &span); err != nil {
+			t.Fatalf("WriteSpan failed: %v", err)
+		}
+		written = append(written, span)
+	}
+
+	load := func(span models.Span) *models.Span {
+		value, _ := store.shardFor(span.TraceID).spans.Load(span.SpanID)
+		return value.(*models.Span)
+	}
+	a, b := load(written[0]), load(written[1])
+	if unsafe.StringData(a.ServiceName) != unsafe.StringData(b.ServiceName) ||
+		unsafe.StringData(a.OperationName) != unsafe.StringData(b.OperationName) {
+		t.Error("service and operation names are not shared")
+	}
+	for keyA := range a.Tags {
+		for keyB := range b.Tags {
+			if unsafe.StringData(keyA) != unsafe.StringData(keyB) {
+				t.Error("tag keys are not shared")
+			}
+		}
+	}
+	if a.SpanID == b.SpanID || a.Tags["http.method"] != "POST" {
+		t.Errorf("stored spans changed: %+v, %+v", a, b)
+	}
+}
+
+func TestInterner_Capacity(t *testing.T) {
+	in := newInterner(2)
+	first := in.intern(string([]byte("api")))
+	if in.intern(string([]byte("api"))) != first || unsafe.StringData(in.intern(string([]byte("api")))) != unsafe.StringData(first) {
+		t.Error("equal strings not shared")
+	}
+	in.intern("db")
+
+	// Full: new strings are returned as they are
+	s := string([]byte("cache"))
+	if got := in.intern(s); unsafe.StringData(got) != unsafe.StringData(s) || in.size.Load() != 2 {
+		t.Errorf("interned past capacity, size %d", in.size.Load())
+	}
+}
@@ -0,0 +1,453 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// BackendFactory builds a store from its raw JSON config (may be nil).
+type BackendFactory func(config json.RawMessage) (Store, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = make(map[string]BackendFactory)
+)
+
+// RegisterBackend makes a storage backend type available to routing configs
+// under name. It panics if name is already registered.
+func RegisterBackend(name string, factory BackendFactory) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+
+	if _, dup := backends[name]; dup {
+		panic("storage: backend registered twice: " + name)
+	}
+	backends[name] = factory
+}
+
+// Backends returns the sorted names of all registered backend types.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	return registeredBackends()
+}
+
+// registeredBackends lists backend types; callers hold backendsMu.
+func registeredBackends() []string {
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MemoryBackendConfig configures a "memory" backend.
+type MemoryBackendConfig struct {
+	MaxTraces       int    `json:"max_traces"`                 // Default 10000
+	Retention       string `json:"retention,omitempty"`        // e.g. "24h" (empty = no time-based retention)
+	JanitorInterval string `json:"janitor_interval,omitempty"` // How often expired traces are removed (empty = 1m)
+	Shards          int    `json:"shards,omitempty"`           // Trace ID hash shards (0 = 32)
+	DurationBuckets string `json:"duration_buckets,omitempty"` // e.g. "1s,1m,10m" or "exponential:1ms,2,18" (empty = default)
+	CostBuckets     string `json:"cost_buckets,omitempty"`     // Bounds in Currency, same syntax (empty = default)
+	Currency        string `json:"currency,omitempty"`         // Default "USD"
+
+	// max_spans_per_trace, max_tags_per_span, max_tag_value_length (0 = unlimited)
+	SpanLimits
+}
+
+func init() {
+	RegisterBackend("memory", func(config json.RawMessage) (Store, error) {
+		cfg := MemoryBackendConfig{MaxTraces: 10000}
+		if len(config) > 0 {
+			if err := json.Unmarshal(config, &cfg); err != nil {
+				return nil, fmt.Errorf("invalid config: %w", err)
+			}
+		}
+		var retention time.Duration
+		if cfg.Retention != "" {
+			var err error
+			if retention, err = time.ParseDuration(cfg.Retention); err != nil {
+				return nil, fmt.Errorf("invalid retention %q", cfg.Retention)
+			}
+		}
+		var janitorInterval time.Duration
+		if cfg.JanitorInterval != "" {
+			var err error
+			if janitorInterval, err = time.ParseDuration(cfg.JanitorInterval); err != nil || janitorInterval <= 0 {
+				return nil, fmt.Errorf("invalid janitor_interval %q", cfg.JanitorInterval)
+			}
+		}
+		if cfg.MaxSpansPerTrace < 0 || cfg.MaxTagsPerSpan < 0 || cfg.MaxTagValueLength < 0 {
+			return nil, errors.New("span limits must not be negative")
+		}
+		if cfg.Shards < 0 {
+			return nil, errors.New("shards must not be negative")
+		}
+		store := NewMemoryStore(cfg.MaxTraces).WithShards(cfg.Shards).WithRetention(retention).WithLimits(cfg.SpanLimits)
+		if cfg.DurationBuckets != "" {
+			buckets, err := ParseDurationBuckets(cfg.DurationBuckets)
+			if err != nil {
+				return nil, fmt.Errorf("duration_buckets: %w", err)
+			}
+			byDuration, err := NewDurationBuckets(buckets)
+			if err != nil {
+				return nil, fmt.Errorf("duration_buckets: %w", err)
+			}
+			store.WithDurationBuckets(byDuration)
+		}
+		if cfg.CostBuckets != "" {
+			bounds, err := ParseCostBuckets(cfg.CostBuckets)
+			if err != nil {
+				return nil, fmt.Errorf("cost_buckets: %w", err)
+			}
+			byCost, err := NewCostBuckets(bounds)
+			if err != nil {
+				return nil, fmt.Errorf("cost_buckets: %w", err)
+			}
+			store.WithCostBuckets(byCost)
+		}
+		if cfg.Currency != "" {
+			store.WithCurrency(cfg.Currency)
+		}
+		return store.StartJanitor(janitorInterval), nil
+	})
+}
+
+// TenantTag is the span tag that routing rules match against Match.Tenant.
+const TenantTag = "tenant"
+
+// RoutingConfig is the "storage" section of the collector config file.
+type RoutingConfig struct {
+	// Backends are named store instances
+	Backends map[string]BackendSpec `json:"backends"`
+
+	// Routes are checked in order; the first match picks the backend
+	Routes []Route `json:"routes,omitempty"`
+
+	// Default receives traces no route matches
+	Default string `json:"default"`
+}
+
+// BackendSpec selects a registered backend type and its config.
+type BackendSpec struct {
+	Type   string          `json:"type"`
+	Config json.RawMessage `json:"config,omitempty"`
+}
+
+// Route sends matching traces to Backend.
+type Route struct {
+	Match   RouteMatch `json:"match"`
+	Backend string     `json:"backend"`
+}
+
+// RouteMatch criteria. Every non-empty field must equal the span's value.
+type RouteMatch struct {
+	Service     string            `json:"service,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Tenant      string            `json:"tenant,omitempty"` // Value of the "tenant" tag
+	Tags        map[string]string `json:"tags,omitempty"`
+}
+
+func (m *RouteMatch) matches(span *models.Span) bool {
+	if m.Service != "" && m.Service != span.ServiceName {
+		return false
+	}
+	if m.Environment != "" && m.Environment != span.Environment {
+		return false
+	}
+	if m.Tenant != "" && m.Tenant != span.Tags[TenantTag] {
+		return false
+	}
+	for k, v := range m.Tags {
+		if span.Tags[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// maxRoutedTraces bounds the trace -> backend assignments remembered by a
+// RoutingStore. Older assignments are forgotten; reads then search every
+// backend and late spans of the trace are routed on their own attributes.
+const maxRoutedTraces = 100000
+
+// RoutingStore sends each trace to one of several stores according to
+// routing rules, e.g. production traces to a long-retention store and
+// development traces to a small in-memory one. A trace is routed by its
+// first span so all of its spans land in the same backend. Reads fan out to
+// every backend and merge the results.
+type RoutingStore struct {
+	names    []string // Backend names, sorted
+	backends map[string]Store
+	routes   []Route
+	fallback string
+
+	mu          sync.Mutex
+	assignments map[string]string // traceID -> backend name
+	order       []string          // Assignment order, for bounding the map
+}
+
+// NewRoutingStore builds every backend in config. If a backend fails to
+// build, the ones already built are closed.
+func NewRoutingStore(config *RoutingConfig) (*RoutingStore, error) {
+	if len(config.Backends) == 0 {
+		return nil, errors.New("at least one backend is required")
+	}
+	if _, ok := config.Backends[config.Default]; !ok {
+		return nil, fmt.Errorf("default backend %q is not defined", config.Default)
+	}
+	for i, route := range config.Routes {
+		if _, ok := config.Backends[route.Backend]; !ok {
+			return nil, fmt.Errorf("route %d: backend %q is not defined", i, route.Backend)
+		}
+	}
+
+	s := &RoutingStore{
+		backends:    make(map[string]Store, len(config.Backends)),
+		routes:      config.Routes,
+		fallback:    config.Default,
+		assignments: make(map[string]string),
+	}
+	for name := range config.Backends {
+		s.names = append(s.names, name)
+	}
+	sort.Strings(s.names)
+
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	for _, name := range s.names {
+		spec := config.Backends[name]
+		factory, ok := backends[spec.Type]
+		if !ok {
+			s.Close()
+			return nil, fmt.Errorf("backend %q: unknown type %q (registered: %v)", name, spec.Type, registeredBackends())
+		}
+		store, err := factory(spec.Config)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("backend %q: %w", name, err)
+		}
+		s.backends[name] = store
+	}
+	return s, nil
+}
+
+// Backend returns the named backend, or nil.
+func (s *RoutingStore) Backend(name string) Store {
+	return s.backends[name]
+}
+
+// Evictions sums the evictions of backends that evict traces.
+func (s *RoutingStore) Evictions() EvictionStats {
+	var total EvictionStats
+	for _, name := range s.names {
+		if evictor, ok := s.backends[name].(Evictor); ok {
+			stats := evictor.Evictions()
+			total.Capacity += stats.Capacity
+			total.Retention += stats.Retention
+		}
+	}
+	return total
+}
+
+// Ping pings every backend that is a Pinger.
+func (s *RoutingStore) Ping(ctx context.Context) error {
+	var errs []error
+	for _, name := range s.names {
+		if pinger, ok := s.backends[name].(Pinger); ok {
+			if err := pinger.Ping(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("backend %q: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteSpan writes the span to its trace's backend.
+func (s *RoutingStore) WriteSpan(ctx context.Context, span *models.Span) error {
+	// Invalid spans must not claim a trace assignment
+	if err := span.Validate(); err != nil {
+		return fmt.Errorf("invalid span: %w", err)
+	}
+	return s.backends[s.route(span)].WriteSpan(ctx, span)
+}
+
+// route returns the backend for span, assigning its trace on first sight.
+func (s *RoutingStore) route(span *models.Span) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if name, ok := s.assignments[span.TraceID]; ok {
+		return name
+	}
+
+	name := s.fallback
+	for i := range s.routes {
+		if s.routes[i].Match.matches(span) {
+			name = s.routes[i].Backend
+			break
+		}
+	}
+
+	s.assignments[span.TraceID] = name
+	s.order = append(s.order, span.TraceID)
+	if len(s.order) > maxRoutedTraces {
+		// Forget the oldest half at once to keep the cost amortized
+		forget := len(s.order) / 2
+		for _, traceID := range s.order[:forget] {
+			delete(s.assignments, traceID)
+		}
+		s.order = append([]string(nil), s.order[forget:]...)
+	}
+	return name
+}
+
+// GetTrace reads the trace from its backend, or searches all backends if the
+// assignment is no longer known.
+func (s *RoutingStore) GetTrace(ctx context.Context, traceID string) (*models.Trace, error) {
+	s.mu.Lock()
+	name, ok := s.assignments[traceID]
+	s.mu.Unlock()
+	if ok {
+		return s.backends[name].GetTrace(ctx, traceID)
+	}
+
+	for _, name := range s.names {
+		trace, err := s.backends[name].GetTrace(ctx, traceID)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", name, err)
+		}
+		if trace != nil {
+			return trace, nil
+		}
+	}
+	return nil, nil
+}
+
+// FindTraces queries every backend and merges the results in the query's order.
+func (s *RoutingStore) FindTraces(ctx context.Context, query *Query) ([]*models.Trace, error) {
+	// Each backend must return enough results to fill the merged page
+	perBackend := *query
+	perBackend.Offset = 0
+	if query.Limit > 0 {
+		perBackend.Limit = query.Offset + query.Limit
+	}
+
+	var results []*models.Trace
+	for _, name := range s.names {
+		traces, err := s.backends[name].FindTraces(ctx, &perBackend)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", name, err)
+		}
+		results = append(results, traces...)
+	}
+
+	query.SortTraces(results)
+
+	if query.Offset >= len(results) {
+		return []*models.Trace{}, nil
+	}
+	end := len(results)
+	if query.Limit > 0 && query.Offset+query.Limit < end {
+		end = query.Offset + query.Limit
+	}
+	return results[query.Offset:end], nil
+}
+
+// CountTraces sums every backend's count; a trace lives in one backend.
+func (s *RoutingStore) CountTraces(ctx context.Context, query *Query) (int, error) {
+	total := 0
+	for _, name := range s.names {
+		n, err := s.backends[name].CountTraces(ctx, query)
+		if err != nil {
+			return 0, fmt.Errorf("backend %q: %w", name, err)
+		}
+		total += n
+	}
+	return total, nil
+}
+
+// GetServiceStats merges every backend's service stats.
+func (s *RoutingStore) GetServiceStats(ctx context.Context, query *StatsQuery) ([]ServiceStats, error) {
+	merged := make(map[string]*ServiceStats)
+	for _, name := range s.names {
+		stats, err := s.backends[name].GetServiceStats(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", name, err)
+		}
+		for i := range stats {
+			service, ok := merged[stats[i].Service]
+			if !ok {
+				service = &ServiceStats{Service: stats[i].Service, SpanStats: newSpanStats()}
+				merged[stats[i].Service] = service
+			}
+			service.Traces += stats[i].Traces
+			service.merge(&stats[i].SpanStats)
+		}
+	}
+
+	result := make([]ServiceStats, 0, len(merged))
+	for _, service := range merged {
+		service.summarize()
+		result = append(result, *service)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Service < result[j].Service })
+	return result, nil
+}
+
+// GetOperationStats merges every backend's operation stats.
+func (s *RoutingStore) GetOperationStats(ctx context.Context, query *StatsQuery) ([]OperationStats, error) {
+	merged := newStatsAccumulator()
+	for _, name := range s.names {
+		stats, err := s.backends[name].GetOperationStats(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", name, err)
+		}
+		for i := range stats {
+			merged.operation(stats[i].Service, stats[i].Operation).merge(&stats[i].SpanStats)
+		}
+	}
+	return merged.operationStats(), nil
+}
+
+// GetServices returns the union of every backend's services.
+func (s *RoutingStore) GetServices(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, name := range s.names {
+		services, err := s.backends[name].GetServices(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("backend %q: %w", name, err)
+		}
+		for _, service := range services {
+			seen[service] = true
+		}
+	}
+
+	services := make([]string, 0, len(seen))
+	for service := range seen {
+		services = append(services, service)
+	}
+	sort.Strings(services)
+	return services, nil
+}
+
+// Close closes every backend and returns the combined errors.
+func (s *RoutingStore) Close() error {
+	var errs []error
+	for _, name := range s.names {
+		if store := s.backends[name]; store != nil {
+			if err := store.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("backend %q: %w", name, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func newRoutingTestStore(t *testing.T) *RoutingStore {
+	t.Helper()
+	config := &RoutingConfig{
+		Backends: map[string]BackendSpec{
+			"prod": {Type: "memory", Config: json.RawMessage(`{"max_traces": 100, "retention": "168h"}`)},
+			"dev":  {Type: "memory", Config: json.RawMessage(`{"max_traces": 10}`)},
+		},
+		Routes: []Route{
+			{Match: RouteMatch{Environment: "prod"}, Backend: "prod"},
+			{Match: RouteMatch{Tenant: "acme"}, Backend: "prod"},
+		},
+		Default: "dev",
+	}
+	store, err := NewRoutingStore(config)
+	if err != nil {
+		t.Fatalf("NewRoutingStore() error = %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func routedSpan(traceID, service, environment string, start time.Time) *models.Span {
+	return &models.Span{
+		TraceID:       traceID,
+		SpanID:        models.GenerateSpanID(),
+		ServiceName:   service,
+		OperationName: "test-op",
+		StartTime:     start,
+		Status:        "ok",
+		Environment:   environment,
+		Tags:          map[string]string{},
+	}
+}
+
+func TestRoutingStore_RoutesTracesByFirstSpan(t *testing.T) {
+	store := newRoutingTestStore(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	prodTrace := models.GenerateTraceID()
+	store.WriteSpan(ctx, routedSpan(prodTrace, "api", "prod", now))
+	// A later span without the environment still follows its trace
+	store.WriteSpan(ctx, routedSpan(prodTrace, "db", "", now))
+
+	tenantSpan := routedSpan(models.GenerateTraceID(), "api", "staging", now.Add(time.Second))
+	tenantSpan.Tags[TenantTag] = "acme"
+	store.WriteSpan(ctx, tenantSpan)
+
+	devTrace := models.GenerateTraceID()
+	store.WriteSpan(ctx, routedSpan(devTrace, "web", "dev", now.Add(2*time.Second)))
+
+	prod, _ := store.Backend("prod").GetTrace(ctx, prodTrace)
+	if prod == nil || len(prod.Spans) != 2 {
+		t.Fatalf("prod backend trace = %v, want 2 spans", prod)
+	}
+	if trace, _ := store.Backend("prod").GetTrace(ctx, tenantSpan.TraceID); trace == nil {
+		t.Error("tenant trace should be routed to prod")
+	}
+	if trace, _ := store.Backend("dev").GetTrace(ctx, devTrace); trace == nil {
+		t.Error("unmatched trace should go to the default backend")
+	}
+
+	// Reads merge every backend, newest first
+	traces, err := store.FindTraces(ctx, NewQuery().WithPagination(2, 0))
+	if err != nil {
+		t.Fatalf("FindTraces() error = %v", err)
+	}
+	if len(traces) != 2 || traces[0].TraceID != devTrace || traces[1].TraceID != tenantSpan.TraceID {
+		t.Errorf("unexpected merged page: %v", traces)
+	}
+
+	services, _ := store.GetServices(ctx)
+	if len(services) != 3 {
+		t.Errorf("services = %v, want [api db web]", services)
+	}
+}
+
+func TestNewRoutingStore_ValidatesConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config RoutingConfig
+	}{
+		{"no backends", RoutingConfig{Default: "x"}},
+		{"unknown default", RoutingConfig{Backends: map[string]BackendSpec{"a": {Type: "memory"}}, Default: "b"}},
+		{"unknown route backend", RoutingConfig{
+			Backends: map[string]BackendSpec{"a": {Type: "memory"}},
+			Routes:   []Route{{Backend: "b"}},
+			Default:  "a",
+		}},
+		{"unknown type", RoutingConfig{Backends: map[string]BackendSpec{"a": {Type: "cassandra"}}, Default: "a"}},
+	}
+	for _, tt := range tests {
+		if _, err := NewRoutingStore(&tt.config); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
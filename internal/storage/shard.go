@@ -0,0 +1,92 @@
+package storage
+
+import (
+	"sync"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+)
+
+// DefaultShards is how many shards a MemoryStore splits its traces across.
+const DefaultShards = 32
+
+// shard holds the traces whose IDs hash to it, with their spans and index
+// entries. Writes to traces in different shards share no map or lock, so
+// concurrent writers no longer queue on a single index lock.
+type shard struct {
+	// Core storage - concurrent-safe maps
+	spans  sync.Map // spanID (string) -> *models.Span
+	traces sync.Map // traceID (string) -> *traceSpans, guarded by traceLocks
+
+	// Indexes of the shard's traces
+	indexes *Indexes
+	indexMu sync.RWMutex // protects indexes updates
+
+	// The shard's traces by last write, for capacity eviction (see lru.go)
+	lru *traceLRU
+}
+
+func newShard(durationBounds, costBounds []float64) *shard {
+	return &shard{
+		indexes: &Indexes{
+			byService:   make(map[string]traceSet),
+			byTimestamp: &TimeBuckets{buckets: make(map[int64]traceSet)},
+			byDuration:  &DurationBuckets{newHistogramIndex(durationBounds)},
+			byCost:      &CostBuckets{newHistogramIndex(costBounds)},
+			byError:     make(map[string]int),
+		},
+		lru: newTraceLRU(),
+	}
+}
+
+// newShards returns n empty shards indexing with the given bucket bounds.
+func newShards(n int, durationBounds, costBounds []float64) []*shard {
+	shards := make([]*shard, n)
+	for i := range shards {
+		shards[i] = newShard(durationBounds, costBounds)
+	}
+	return shards
+}
+
+// shardFor returns the shard holding traceID.
+func (s *MemoryStore) shardFor(traceID string) *shard {
+	return s.shards[traceHash(traceID)%uint32(len(s.shards))]
+}
+
+// oldestShard returns the shard whose least recently written trace is the
+// oldest in the store, or nil if the store is empty.
+func (s *MemoryStore) oldestShard() *shard {
+	var oldest *shard
+	var oldestSeq uint64
+	for _, sh := range s.shards {
+		if seq, ok := sh.lru.oldest(); ok && (oldest == nil || seq < oldestSeq) {
+			oldest, oldestSeq = sh, seq
+		}
+	}
+	return oldest
+}
+
+// mergedHistogram combines one histogram from every shard, chosen by pick.
+func (s *MemoryStore) mergedHistogram(bounds []float64, pick func(*Indexes) *histogram.Histogram) *histogram.Histogram {
+	merged := histogram.New(bounds)
+	for _, sh := range s.shards {
+		sh.indexMu.RLock()
+		merged.Merge(pick(sh.indexes))
+		sh.indexMu.RUnlock()
+	}
+	return merged
+}
+
+// traceHash is the 32-bit FNV-1a hash of traceID. It picks both a trace's
+// shard and its lock stripe, without the allocation of hash/fnv.
+func traceHash(traceID string) uint32 {
+	const (
+		offset32 = 2166136261
+		prime32  = 16777619
+	)
+	h := uint32(offset32)
+	for i := 0; i < len(traceID); i++ {
+		h ^= uint32(traceID[i])
+		h *= prime32
+	}
+	return h
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/saintparish4/asmbly/internal/histogram"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// StatsDurationBounds are the span duration histogram bounds, in seconds,
+// behind SpanStats percentiles: 40 exponential buckets growing by 1.5 from
+// 0.1ms (about 12 minutes at the top), so estimates are within a factor of
+// 1.5. Every store uses them, so stats from several stores can be merged.
+var StatsDurationBounds, _ = histogram.Exponential(0.0001, 1.5, 40)
+
+// StatsQuery selects the spans GetServiceStats and GetOperationStats
+// aggregate. Spans still in progress are never counted.
+type StatsQuery struct {
+	Service   string    // Only this service (empty = every service)
+	StartTime time.Time // Spans starting at or after StartTime (zero = no bound)
+	EndTime   time.Time // Spans starting at or before EndTime (zero = no bound)
+}
+
+// matches reports whether the query selects span.
+func (q *StatsQuery) matches(span *models.Span) bool {
+	if span.InProgress || (q.Service != "" && span.ServiceName != q.Service) {
+		return false
+	}
+	if !q.StartTime.IsZero() && span.StartTime.Before(q.StartTime) {
+		return false
+	}
+	return q.EndTime.IsZero() || !span.StartTime.After(q.EndTime)
+}
+
+// SpanStats aggregates a set of spans.
+type SpanStats struct {
+	Spans       int64         `json:"spans"`
+	Errors      int64         `json:"errors"`
+	ErrorRate   float64       `json:"error_rate"` // Errors / Spans
+	TotalCost   float64       `json:"total_cost,omitempty"`
+	AvgDuration time.Duration `json:"avg_duration"`
+	P50Duration time.Duration `json:"p50_duration"`
+	P95Duration time.Duration `json:"p95_duration"`
+	P99Duration time.Duration `json:"p99_duration"`
+
+	// Durations is the histogram (in seconds, over StatsDurationBounds) the
+	// percentiles are estimated from, kept so stats can be merged
+	Durations *histogram.Histogram `json:"-"`
+}
+
+func newSpanStats() SpanStats {
+	return SpanStats{Durations: histogram.New(StatsDurationBounds)}
+}
+
+// observe adds a span to the counts; summarize updates the derived fields.
+func (s *SpanStats) observe(span *models.Span) {
+	s.Spans++
+	if span.IsError() {
+		s.Errors++
+	}
+	s.TotalCost += span.Cost
+	s.Durations.Observe(span.Duration.Seconds())
+}
+
+// merge adds other's counts; summarize updates the derived fields.
+func (s *SpanStats) merge(other *SpanStats) {
+	s.Spans += other.Spans
+	s.Errors += other.Errors
+	s.TotalCost += other.TotalCost
+	s.Durations.Merge(other.Durations)
+}
+
+// summarize derives the error rate, average and percentiles from the counts.
+func (s *SpanStats) summarize() {
+	if s.Spans == 0 {
+		return
+	}
+	s.ErrorRate = float64(s.Errors) / float64(s.Spans)
+	s.AvgDuration = secondsToDuration(s.Durations.Sum() / float64(s.Durations.Count()))
+	s.P50Duration = secondsToDuration(s.Durations.Quantile(0.5))
+	s.P95Duration = secondsToDuration(s.Durations.Quantile(0.95))
+	s.P99Duration = secondsToDuration(s.Durations.Quantile(0.99))
+}
+
+// ServiceStats aggregates the spans of one service.
+type ServiceStats struct {
+	Service string `json:"service"`
+	Traces  int64  `json:"traces"` // Traces with a selected span of the service
+	SpanStats
+}
+
+// OperationStats aggregates the spans of one operation of a service.
+type OperationStats struct {
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+	SpanStats
+}
+
+// operationKey identifies an operation of a service.
+type operationKey struct {
+	service   string
+	operation string
+}
+
+// statsAccumulator gathers span stats per operation and trace counts per
+// service, then lists either.
+type statsAccumulator struct {
+	operations map[operationKey]*SpanStats
+	traces     map[string]int64 // service -> traces
+}
+
+func newStatsAccumulator() *statsAccumulator {
+	return &statsAccumulator{operations: make(map[operationKey]*SpanStats), traces: make(map[string]int64)}
+}
+
+// operation returns the stats of an operation, adding it if new.
+func (a *statsAccumulator) operation(service, operation string) *SpanStats {
+	key := operationKey{service, operation}
+	stats, ok := a.operations[key]
+	if !ok {
+		s := newSpanStats()
+		stats = &s
+		a.operations[key] = stats
+	}
+	return stats
+}
+
+// operationStats lists the operations sorted by service and operation.
+func (a *statsAccumulator) operationStats() []OperationStats {
+	result := make([]OperationStats, 0, len(a.operations))
+	for key, stats := range a.operations {
+		stats.summarize()
+		result = append(result, OperationStats{Service: key.service, Operation: key.operation, SpanStats: *stats})
+	}
+	slices.SortFunc(result, func(a, b OperationStats) int {
+		return cmp.Or(cmp.Compare(a.Service, b.Service), cmp.Compare(a.Operation, b.Operation))
+	})
+	return result
+}
+
+// serviceStats merges each service's operations, sorted by service.
+func (a *statsAccumulator) serviceStats() []ServiceStats {
+	services := make(map[string]*ServiceStats)
+	for key, stats := range a.operations {
+		service, ok := services[key.service]
+		if !ok {
+			service = &ServiceStats{Service: key.service, Traces: a.traces[key.service], SpanStats: newSpanStats()}
+			services[key.service] = service
+		}
+		service.merge(stats)
+	}
+
+	result := make([]ServiceStats, 0, len(services))
+	for _, service := range services {
+		service.summarize()
+		result = append(result, *service)
+	}
+	slices.SortFunc(result, func(a, b ServiceStats) int { return cmp.Compare(a.Service, b.Service) })
+	return result
+}
@@ -0,0 +1,130 @@
+package storage
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// writeStatsSpans writes 100 "GET /" spans of api lasting 1ms to 100ms, the
+// last 5 failed, and one "query" span of db in each of 10 traces.
+func writeStatsSpans(t *testing.T, store Store, start time.Time) {
+	t.Helper()
+	ctx := context.Background()
+	traceIDs := make([]string, 10)
+	for i := range traceIDs {
+		traceIDs[i] = models.GenerateTraceID()
+	}
+	for i := 1; i <= 100; i++ {
+		span := routedSpan(traceIDs[i%10], "api", "", start.Add(time.Duration(i)*time.Second))
+		span.OperationName = "GET /"
+		span.Duration = time.Duration(i) * time.Millisecond
+		span.Cost = 0.01
+		if i > 95 {
+			span.Status = "error"
+		}
+		if err := store.WriteSpan(ctx, span); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, traceID := range traceIDs {
+		span := routedSpan(traceID, "db", "", start)
+		span.OperationName = "query"
+		span.Duration = time.Millisecond
+		store.WriteSpan(ctx, span)
+	}
+
+	// Spans still running are not counted
+	running := routedSpan(traceIDs[0], "api", "", start)
+	running.InProgress = true
+	store.WriteSpan(ctx, running)
+}
+
+func TestMemoryStore_SpanStats(t *testing.T) {
+	store := NewMemoryStore(1000)
+	start := time.Now().Add(-time.Hour)
+	writeStatsSpans(t, store, start)
+	ctx := context.Background()
+
+	services, err := store.GetServiceStats(ctx, &StatsQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].Service != "api" || services[1].Service != "db" {
+		t.Fatalf("services = %+v", services)
+	}
+	api := services[0]
+	if api.Traces != 10 || api.Spans != 100 || api.Errors != 5 || api.ErrorRate != 0.05 {
+		t.Errorf("api stats = %+v", api)
+	}
+	if api.TotalCost < 0.99 || api.TotalCost > 1.01 {
+		t.Errorf("api cost = %v, want 1", api.TotalCost)
+	}
+	if api.AvgDuration < 50*time.Millisecond || api.AvgDuration > 51*time.Millisecond {
+		t.Errorf("average = %v, want 50.5ms", api.AvgDuration)
+	}
+	// Estimates are within the bucket growth factor of 1.5
+	if api.P95Duration < 95*time.Millisecond/3*2 || api.P95Duration > 95*time.Millisecond*3/2 {
+		t.Errorf("p95 = %v, want about 95ms", api.P95Duration)
+	}
+	if api.P50Duration >= api.P95Duration || api.P95Duration > api.P99Duration {
+		t.Errorf("percentiles out of order: %v %v %v", api.P50Duration, api.P95Duration, api.P99Duration)
+	}
+
+	// Filters select spans by service and start time
+	operations, _ := store.GetOperationStats(ctx, &StatsQuery{Service: "api", StartTime: start.Add(51 * time.Second)})
+	if len(operations) != 1 || operations[0].Operation != "GET /" || operations[0].Spans != 50 {
+		t.Errorf("operations = %+v", operations)
+	}
+	operations, _ = store.GetOperationStats(ctx, &StatsQuery{EndTime: start})
+	if len(operations) != 1 || operations[0].Service != "db" || operations[0].Spans != 10 {
+		t.Errorf("operations up to start = %+v", operations)
+	}
+}
+
+func TestMemoryStore_CountTraces(t *testing.T) {
+	store := NewMemoryStore(1000)
+	for i := 0; i < 5; i++ {
+		createTestTrace(t, store, "api", time.Duration(i+1)*time.Second)
+	}
+	createTestTrace(t, store, "web", time.Second)
+
+	query := NewQuery().WithService("api").WithPagination(2, 0)
+	if n, _ := store.CountTraces(context.Background(), query); n != 5 {
+		t.Errorf("CountTraces() = %d, want 5 regardless of the page", n)
+	}
+	query.MinDuration = 3 * time.Second
+	if n, _ := store.CountTraces(context.Background(), query); n != 3 {
+		t.Errorf("CountTraces(min_duration=3s) = %d, want 3", n)
+	}
+}
+
+func TestRoutingStore_MergesAggregates(t *testing.T) {
+	store := newRoutingTestStore(t)
+	start := time.Now().Add(-time.Hour)
+	writeStatsSpans(t, store.Backend("prod"), start)
+	writeStatsSpans(t, store.Backend("dev"), start)
+	ctx := context.Background()
+
+	services, err := store.GetServiceStats(ctx, &StatsQuery{Service: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 1 || services[0].Traces != 20 || services[0].Spans != 200 || services[0].Errors != 10 {
+		t.Fatalf("merged services = %+v", services)
+	}
+	if p95 := services[0].P95Duration; p95 < 60*time.Millisecond || p95 > 145*time.Millisecond {
+		t.Errorf("merged p95 = %v, want about 95ms", p95)
+	}
+
+	operations, _ := store.GetOperationStats(ctx, &StatsQuery{})
+	if len(operations) != 2 || operations[1].Service != "db" || operations[1].Spans != 20 {
+		t.Errorf("merged operations = %+v", operations)
+	}
+
+	if n, _ := store.CountTraces(ctx, NewQuery().WithPagination(1, 0)); n != 20 {
+		t.Errorf("CountTraces() = %d, want 20 across backends", n)
+	}
+}
@@ -1,16 +1,21 @@
 package storage
 
 import (
+	"cmp"
 	"context"
+	"sort"
 	"time"
 
-	"github.com/saintparish4/asmbly/internal/models"
+	"github.com/saintparish4/asmbly/models"
 )
 
 // Store defines the interface for trace storage operations
 // Implementations must be safe for concurrent use by multiple goroutines
 type Store interface {
 	// WriteSpan stores a single span and the span will be validated before storage
+	// Writes are upserts: a later span with the same SpanID replaces the earlier
+	// version (e.g. an in-progress span being completed, or an SDK retry) and the
+	// trace is reindexed from the new values
 	// Returns an error if the span is invalid or storage fails
 	WriteSpan(ctx context.Context, span *models.Span) error
 
@@ -22,13 +27,43 @@ type Store interface {
 	// Results are paginated using query.Limit and query.Offset
 	FindTraces(ctx context.Context, query *Query) ([]*models.Trace, error)
 
+	// CountTraces returns how many traces match the query, ignoring its
+	// pagination, sorting and projection
+	CountTraces(ctx context.Context, query *Query) (int, error)
+
 	// GetService returns a list of all unique service names that have sent spans
 	GetServices(ctx context.Context) ([]string, error)
 
+	// GetServiceStats aggregates the spans the query selects per service,
+	// sorted by service name
+	GetServiceStats(ctx context.Context, query *StatsQuery) ([]ServiceStats, error)
+
+	// GetOperationStats aggregates the spans the query selects per service
+	// and operation, sorted by service and then operation name
+	GetOperationStats(ctx context.Context, query *StatsQuery) ([]OperationStats, error)
+
 	// Close cleanly shuts down the storage system, flushing any pending writes
 	Close() error
 }
 
+// EvictionStats counts traces a store removed on its own.
+type EvictionStats struct {
+	Capacity  int64 // Oldest traces removed to stay within max traces
+	Retention int64 // Traces removed past their retention
+}
+
+// Evictor is implemented by stores that evict traces, so their eviction
+// rate can be monitored.
+type Evictor interface {
+	Evictions() EvictionStats
+}
+
+// Pinger is implemented by stores backed by a server, so readiness checks
+// can tell whether it is reachable.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
 // Query defines search criteria for finding traces
 // All filters are optional - nil/zero values are ignored
 type Query struct {
@@ -47,16 +82,112 @@ type Query struct {
 	StartTime time.Time // Include traces with start time >= StartTime
 	EndTime   time.Time // Include traces with end time <= EndTime
 
+	// ErrorsOnly includes only traces with at least one failed span
+	ErrorsOnly bool
+
+	// Tag filters: each key must be on some span of the trace, with the given
+	// value, or with any value when the value is empty
+	Tags map[string]string
+
 	// Profiling filter
 	HasProfile *bool // If set, filter traces by whether they have profiled spans
 
+	// Partial trace filter
+	InProgress *bool // If set, filter traces by whether any span is still running
+
+	// Retention filter
+	MinTTL time.Duration // Exclude traces that expire within MinTTL (0 = no filter)
+
 	// Pagination
 	Limit  int // Max number of results to return (0 = no limit)
 	Offset int // Number of results to skip (for pagination)
 
-	// Sorting (future feature)
-	// SortBy string // "start_time", "duration", "cost"
-	// SortOrder string // "asc", "desc"
+	// Sorting, applied before pagination
+	SortBy    string // One of the SortBy* keys (empty = SortByStartTime)
+	SortOrder string // SortAsc or SortDesc (empty = SortDesc)
+
+	// Projection limits how much of each span is returned (empty = ProjectFull)
+	Projection Projection
+}
+
+// Projection selects which span fields FindTraces returns. Filters always
+// see complete spans, and trace-level fields (duration, services, costs) are
+// always complete.
+type Projection string
+
+const (
+	// ProjectFull returns spans with all their fields
+	ProjectFull Projection = ""
+
+	// ProjectMetadata returns spans without Tags and Events: enough for trace
+	// summaries, and persistent backends can skip reading and decoding the
+	// largest part of each span
+	ProjectMetadata Projection = "metadata"
+)
+
+// ProjectTrace returns trace reduced to projection. Traces may be shared
+// (e.g. cached by a store), so a reduced trace is a copy.
+func ProjectTrace(trace *models.Trace, projection Projection) *models.Trace {
+	if projection != ProjectMetadata {
+		return trace
+	}
+	projected := *trace
+	projected.Spans = make([]models.Span, len(trace.Spans))
+	for i, span := range trace.Spans {
+		span.Tags = nil
+		span.Events = nil
+		projected.Spans[i] = span
+	}
+	return &projected
+}
+
+// Sort keys for Query.SortBy
+const (
+	SortByStartTime = "start_time"
+	SortByDuration  = "duration"
+	SortByCost      = "cost"
+	SortBySpanCount = "span_count"
+)
+
+// Sort orders for Query.SortOrder. Descending, the default, lists the
+// newest, slowest, most expensive or largest traces first.
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+)
+
+// SortKeys lists the valid Query.SortBy values.
+func SortKeys() []string {
+	return []string{SortByStartTime, SortByDuration, SortByCost, SortBySpanCount}
+}
+
+// traceSortKeys compare two traces by each sort key.
+var traceSortKeys = map[string]func(a, b *models.Trace) int{
+	SortByStartTime: func(a, b *models.Trace) int { return a.StartTime.Compare(b.StartTime) },
+	SortByDuration:  func(a, b *models.Trace) int { return cmp.Compare(a.Duration, b.Duration) },
+	SortByCost:      func(a, b *models.Trace) int { return cmp.Compare(a.TotalCost, b.TotalCost) },
+	SortBySpanCount: func(a, b *models.Trace) int { return cmp.Compare(len(a.Spans), len(b.Spans)) },
+}
+
+// SortTraces orders traces by the query's sort key and order. Ties are
+// broken by start time (newest first) and then trace ID, so pages are stable.
+func (q *Query) SortTraces(traces []*models.Trace) {
+	key, ok := traceSortKeys[q.SortBy]
+	if !ok {
+		key = traceSortKeys[SortByStartTime]
+	}
+	ascending := q.SortOrder == SortAsc
+
+	sort.Slice(traces, func(i, j int) bool {
+		a, b := traces[i], traces[j]
+		if c := key(a, b); c != 0 {
+			return (c < 0) == ascending
+		}
+		if c := a.StartTime.Compare(b.StartTime); c != 0 {
+			return c > 0
+		}
+		return a.TraceID < b.TraceID
+	})
 }
 
 // QueryResult represents a paginated query response.
@@ -80,6 +211,21 @@ func (q *Query) WithService(service string) *Query {
 	return q
 }
 
+// WithErrorsOnly restricts results to traces with a failed span.
+func (q *Query) WithErrorsOnly() *Query {
+	q.ErrorsOnly = true
+	return q
+}
+
+// WithTag adds a tag filter; an empty value only requires the key to exist.
+func (q *Query) WithTag(key, value string) *Query {
+	if q.Tags == nil {
+		q.Tags = make(map[string]string)
+	}
+	q.Tags[key] = value
+	return q
+}
+
 // WithDurationRange adds duration filters.
 func (q *Query) WithDurationRange(min, max time.Duration) *Query {
 	q.MinDuration = min
@@ -101,6 +247,19 @@ func (q *Query) WithTimeRange(start, end time.Time) *Query {
 	return q
 }
 
+// WithSort sets the sort key and order.
+func (q *Query) WithSort(by, order string) *Query {
+	q.SortBy = by
+	q.SortOrder = order
+	return q
+}
+
+// WithProjection sets which span fields are returned.
+func (q *Query) WithProjection(projection Projection) *Query {
+	q.Projection = projection
+	return q
+}
+
 // WithPagination sets pagination parameters.
 func (q *Query) WithPagination(limit, offset int) *Query {
 	q.Limit = limit
@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// traceLockStripes is the number of locks guarding per-trace span lists.
+// Writes to different traces rarely share a stripe, so they don't contend.
+const traceLockStripes = 256
+
+// traceSpans is the set of span IDs in one trace. ids keeps arrival order;
+// starts maps each span ID to its start time and makes duplicate checks
+// O(1), so building a trace is linear in its span count.
+type traceSpans struct {
+	ids    []string
+	starts map[string]time.Time
+
+	// Earliest of starts, which ages the trace for retention whatever order
+	// its spans arrive in
+	start time.Time
+
+	// Service names and hourly buckets the trace is indexed under, so
+	// eviction only touches those index entries
+	services []string
+	hours    []int64
+
+	// version counts writes; assembled caches the trace built at that
+	// version for completed traces, so repeat reads skip reassembly
+	version   uint64
+	assembled *models.Trace
+
+	// What the store's SpanLimits discarded from this trace
+	truncation models.Truncation
+}
+
+// traceLocks guards traceSpans values and their presence in the shards'
+// traces maps.
+type traceLocks [traceLockStripes]sync.Mutex
+
+// forTrace returns the stripe lock for traceID.
+func (l *traceLocks) forTrace(traceID string) *sync.Mutex {
+	return &l[traceHash(traceID)%traceLockStripes]
+}
+
+// addSpanToTrace adds a span ID to a trace's span list, recording what was
+// truncated from the span and keeping the trace's start at the earliest of
+// its spans' start times, upserts included. It reports whether the trace is
+// new and whether the span was added; a new span is refused once the trace
+// reaches MaxSpansPerTrace, which is recorded as truncation instead.
+func (s *MemoryStore) addSpanToTrace(span *models.Span, truncation models.Truncation) (newTrace, added bool) {
+	traceID, spanID := span.TraceID, span.SpanID
+
+	lock := s.traceLocks.forTrace(traceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	traces := &s.shardFor(traceID).traces
+	value, loaded := traces.Load(traceID)
+	if !loaded {
+		value = &traceSpans{starts: make(map[string]time.Time)}
+		traces.Store(traceID, value)
+	}
+	ts := value.(*traceSpans)
+
+	// Idempotent: upserts of a known span keep their position
+	added = true
+	previous, known := ts.starts[spanID]
+	if !known {
+		if limit := s.limits.MaxSpansPerTrace; limit > 0 && len(ts.ids) >= limit {
+			truncation = models.Truncation{DroppedSpans: 1}
+			added = false
+		} else {
+			ts.ids = append(ts.ids, spanID)
+		}
+	}
+	if added {
+		ts.starts[spanID] = span.StartTime
+		switch {
+		case ts.start.IsZero() || span.StartTime.Before(ts.start):
+			ts.start = span.StartTime
+		case known && previous.Equal(ts.start) && span.StartTime.After(previous):
+			// An upsert moved the earliest span later
+			ts.start = span.StartTime
+			for _, start := range ts.starts {
+				if start.Before(ts.start) {
+					ts.start = start
+				}
+			}
+		}
+		if !slices.Contains(ts.services, span.ServiceName) {
+			ts.services = append(ts.services, span.ServiceName)
+		}
+		if hour := hourBucket(span.StartTime); !slices.Contains(ts.hours, hour) {
+			ts.hours = append(ts.hours, hour)
+		}
+	}
+	addTruncation(&ts.truncation, truncation)
+
+	// Every write, including upserts and refusals, invalidates the assembled trace
+	ts.version++
+	ts.assembled = nil
+	return !loaded, added
+}
+
+// traceSpanIDs returns a copy of a trace's span IDs, or nil if it is unknown.
+func (s *MemoryStore) traceSpanIDs(traceID string) []string {
+	ids, _, _, _ := s.traceSnapshot(traceID)
+	return ids
+}
+
+// traceSnapshot returns the cached assembled trace if there is one, or else
+// a copy of the trace's span IDs, the version they belong to and what was
+// truncated (nil if nothing).
+func (s *MemoryStore) traceSnapshot(traceID string) (ids []string, version uint64, cached *models.Trace, truncation *models.Truncation) {
+	lock := s.traceLocks.forTrace(traceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	value, ok := s.shardFor(traceID).traces.Load(traceID)
+	if !ok {
+		return nil, 0, nil, nil
+	}
+	ts := value.(*traceSpans)
+	if ts.assembled != nil {
+		return nil, ts.version, ts.assembled, nil
+	}
+	if ts.truncation != (models.Truncation{}) {
+		t := ts.truncation
+		truncation = &t
+	}
+	return append([]string(nil), ts.ids...), ts.version, nil, truncation
+}
+
+// cacheAssembled stores a trace assembled from the given version, unless a
+// write has happened since.
+func (s *MemoryStore) cacheAssembled(traceID string, version uint64, trace *models.Trace) {
+	lock := s.traceLocks.forTrace(traceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if value, ok := s.shardFor(traceID).traces.Load(traceID); ok {
+		if ts := value.(*traceSpans); ts.version == version {
+			ts.assembled = trace
+		}
+	}
+}
+
+// invalidateAssembled drops a trace's cached assembly and advances its
+// version, so an assembly started before a span became visible in its
+// shard is not cached.
+func (s *MemoryStore) invalidateAssembled(traceID string) {
+	lock := s.traceLocks.forTrace(traceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if value, ok := s.shardFor(traceID).traces.Load(traceID); ok {
+		ts := value.(*traceSpans)
+		ts.version++
+		ts.assembled = nil
+	}
+}
+
+// traceStart returns the earliest start time of a trace's spans.
+func (s *MemoryStore) traceStart(traceID string) (time.Time, bool) {
+	lock := s.traceLocks.forTrace(traceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	value, ok := s.shardFor(traceID).traces.Load(traceID)
+	if !ok || len(value.(*traceSpans).ids) == 0 {
+		return time.Time{}, false
+	}
+	return value.(*traceSpans).start, true
+}
+
+// removeTrace deletes a trace's span list and returns it.
+func (s *MemoryStore) removeTrace(traceID string) (*traceSpans, bool) {
+	lock := s.traceLocks.forTrace(traceID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	value, ok := s.shardFor(traceID).traces.LoadAndDelete(traceID)
+	if !ok {
+		return nil, false
+	}
+	return value.(*traceSpans), true
+}
@@ -0,0 +1,428 @@
+// TraceFlow trace explorer. Plain DOM, no build step: the collector embeds
+// this file as is. Every request goes to the public query API, relative to
+// the page, so the UI works wherever the collector is mounted.
+"use strict";
+
+const $ = (sel) => document.querySelector(sel);
+
+// Trace fields the result table shows; the span arrays are left out.
+const SEARCH_FIELDS = "trace_id,start_time,duration,services,span_count,total_cost,currency,in_progress,truncation";
+
+// ---------------------------------------------------------------------------
+// API
+
+class APIError extends Error {}
+
+async function api(path, params) {
+  const url = new URL("../api/v1/" + path, location.href);
+  if (params) url.search = params.toString();
+  const res = await fetch(url, { headers: { Accept: "application/json" } });
+  if (res.ok) return res.json();
+
+  // Query errors list each bad parameter; anything else is plain text
+  const body = await res.text();
+  let message = body.trim() || res.status + " " + res.statusText;
+  try {
+    const parsed = JSON.parse(body);
+    message = parsed.error || message;
+    if (parsed.fields) {
+      message += "\n" + parsed.fields.map((f) => `${f.param}=${f.value}: ${f.reason}`).join("\n");
+    }
+  } catch (_) {
+    // Not JSON
+  }
+  throw new APIError(message);
+}
+
+// ---------------------------------------------------------------------------
+// Formatting
+
+// Timestamps are RFC 3339 with up to nanosecond precision, more than a JS
+// Date keeps. parseMicros returns microseconds since the epoch, which still
+// fit a double exactly.
+function parseMicros(s) {
+  const m = /^(.*T\d\d:\d\d:\d\d)(\.\d+)?(.*)$/.exec(s);
+  if (!m) return Date.parse(s) * 1000;
+  const micros = m[2] ? Math.round(parseFloat("0" + m[2]) * 1e6) : 0;
+  return Date.parse(m[1] + m[3]) * 1000 + micros;
+}
+
+function formatDuration(ns) {
+  if (ns < 1e3) return ns + "ns";
+  if (ns < 1e6) return (ns / 1e3).toFixed(ns < 1e4 ? 2 : 1) + "µs";
+  if (ns < 1e9) return (ns / 1e6).toFixed(ns < 1e7 ? 2 : 1) + "ms";
+  if (ns < 60e9) return (ns / 1e9).toFixed(2) + "s";
+  const minutes = Math.floor(ns / 60e9);
+  return minutes + "m" + Math.round((ns - minutes * 60e9) / 1e9) + "s";
+}
+
+function formatTime(s) {
+  const d = new Date(s);
+  return isNaN(d) ? s : d.toLocaleString();
+}
+
+// serviceColor gives each service a stable hue.
+function serviceColor(service) {
+  let hash = 0;
+  for (const ch of service) hash = (hash * 31 + ch.charCodeAt(0)) | 0;
+  return `hsl(${Math.abs(hash) % 360}, 60%, 55%)`;
+}
+
+// el builds an element; text is always set as text, never parsed as HTML.
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [key, value] of Object.entries(attrs || {})) {
+    if (key === "class") node.className = value;
+    else if (key === "style") Object.assign(node.style, value);
+    else if (key.startsWith("on")) node.addEventListener(key.slice(2), value);
+    else node.setAttribute(key, value);
+  }
+  for (const child of children) {
+    if (child == null || child === false) continue;
+    node.append(child instanceof Node ? child : String(child));
+  }
+  return node;
+}
+
+function showError(node, err) {
+  node.textContent = err instanceof APIError ? err.message : "Request failed: " + err.message;
+  node.hidden = false;
+}
+
+// ---------------------------------------------------------------------------
+// Search
+
+const form = $("#search");
+
+async function loadServices() {
+  try {
+    const data = await api("services");
+    const select = form.elements.service;
+    const current = select.value;
+    for (const service of data.services || []) {
+      select.append(el("option", { value: service }, service));
+    }
+    select.value = current;
+  } catch (err) {
+    showError($("#search-error"), err);
+  }
+}
+
+function toggleCustomRange() {
+  const custom = form.elements.lookback.value === "";
+  for (const label of form.querySelectorAll(".custom-range")) label.hidden = !custom;
+}
+
+// formParams turns the form into /api/v1/traces parameters.
+function formParams() {
+  const params = new URLSearchParams();
+  const f = form.elements;
+  for (const name of ["service", "min_duration", "max_duration", "sort", "order", "limit"]) {
+    const value = f[name].value.trim();
+    if (value) params.set(name, value);
+  }
+  if (f.lookback.value) {
+    params.set("lookback", f.lookback.value);
+  } else {
+    for (const name of ["start_time", "end_time"]) {
+      const value = f[name].value.trim();
+      if (value) params.set(name, value);
+    }
+  }
+  for (const tag of f.tag.value.split(/\s+/)) {
+    if (tag) params.append("tag", tag);
+  }
+  for (const name of ["errors_only", "in_progress"]) {
+    if (f[name].checked) params.set(name, "true");
+  }
+  return params;
+}
+
+// fillForm shows params, e.g. from a shared link, in the form.
+function fillForm(params) {
+  const f = form.elements;
+  for (const name of ["service", "min_duration", "max_duration", "start_time", "end_time"]) {
+    f[name].value = params.get(name) || "";
+  }
+  f.sort.value = params.get("sort") || "start_time";
+  f.order.value = params.get("order") || "desc";
+  f.limit.value = params.get("limit") || "20";
+  f.lookback.value = params.has("start_time") || params.has("end_time") ? "" : params.get("lookback") || "1h";
+  f.tag.value = params.getAll("tag").join(" ");
+  f.errors_only.checked = params.get("errors_only") === "true";
+  f.in_progress.checked = params.get("in_progress") === "true";
+  toggleCustomRange();
+}
+
+async function runSearch(params) {
+  const error = $("#search-error");
+  const table = $("#results");
+  const tbody = table.querySelector("tbody");
+  error.hidden = true;
+  $("#results-summary").textContent = "Searching…";
+
+  const query = new URLSearchParams(params);
+  query.set("fields", SEARCH_FIELDS);
+  let data;
+  try {
+    data = await api("traces", query);
+  } catch (err) {
+    $("#results-summary").textContent = "";
+    table.hidden = true;
+    showError(error, err);
+    return;
+  }
+
+  const traces = data.traces || [];
+  const offset = parseInt(query.get("offset"), 10) || 0;
+  $("#results-summary").textContent = traces.length
+    ? `Traces ${offset + 1}–${offset + traces.length} of ${data.total}`
+    : "No traces match.";
+
+  tbody.replaceChildren(...traces.map(resultRow));
+  table.hidden = traces.length === 0;
+  setPageButton($("#prev"), data.links && data.links.prev);
+  setPageButton($("#next"), data.links && data.links.next);
+}
+
+function resultRow(trace) {
+  const services = el("td", {});
+  for (const service of trace.services || []) {
+    services.append(el("span", { class: "badge", style: { background: serviceColor(service) } }, service));
+  }
+  if (trace.in_progress) services.append(el("span", { class: "badge warn" }, "in progress"));
+  if (trace.truncation) services.append(el("span", { class: "badge warn" }, "truncated"));
+
+  const cost = trace.total_cost ? trace.total_cost.toPrecision(3) + (trace.currency ? " " + trace.currency : "") : "";
+  return el("tr", { onclick: () => { location.hash = "#/trace/" + encodeURIComponent(trace.trace_id); } },
+    el("td", {}, formatTime(trace.start_time)),
+    el("td", { class: "mono" }, trace.trace_id),
+    services,
+    el("td", { class: "num" }, trace.span_count),
+    el("td", { class: "num" }, formatDuration(trace.duration)),
+    el("td", { class: "num" }, cost));
+}
+
+// setPageButton points a pagination button at one of the response's links,
+// which carry every search parameter.
+function setPageButton(button, link) {
+  button.hidden = !link;
+  button.onclick = () => {
+    const params = new URL(link, location.href).searchParams;
+    params.delete("fields");
+    location.hash = "#/search?" + params.toString();
+  };
+}
+
+// ---------------------------------------------------------------------------
+// Trace waterfall
+
+const collapsed = new Set();
+let current = null; // { trace, rows, start, duration, selected }
+
+async function showTrace(traceID) {
+  const error = $("#trace-error");
+  error.hidden = true;
+  $("#trace-header").replaceChildren();
+  $("#axis").replaceChildren();
+  $("#rows").replaceChildren();
+  $("#span-detail").hidden = true;
+  collapsed.clear();
+
+  let trace;
+  try {
+    trace = await api("traces/" + encodeURIComponent(traceID), new URLSearchParams({ format: "tree" }));
+  } catch (err) {
+    showError(error, err);
+    return;
+  }
+
+  // Depth-first, children by start time as the tree returns them
+  const rows = [];
+  const stack = [...(trace.tree.roots || [])].reverse();
+  while (stack.length) {
+    const node = stack.pop();
+    node.start = parseMicros(node.span.start_time);
+    rows.push(node);
+    for (const child of [...(node.children || [])].reverse()) {
+      child.parent = node;
+      stack.push(child);
+    }
+  }
+
+  const start = parseMicros(trace.start_time);
+  let duration = trace.duration;
+  for (const row of rows) {
+    duration = Math.max(duration, (row.start - start) * 1000 + row.span.duration);
+  }
+  current = { trace, rows, start, duration: Math.max(duration, 1), selected: null };
+
+  renderTraceHeader(trace, rows.length);
+  renderAxis();
+  renderRows();
+}
+
+function renderTraceHeader(trace, spanCount) {
+  const errors = current.rows.filter((row) => row.span.status === "error").length;
+  const badges = [];
+  if (trace.in_progress) badges.push(el("span", { class: "badge warn" }, "in progress"));
+  if (trace.truncation) badges.push(el("span", { class: "badge warn" }, "truncated: spans or tags were dropped"));
+  if (trace.tree.orphan_spans) badges.push(el("span", { class: "badge warn" }, trace.tree.orphan_spans + " orphan spans"));
+  if (errors) badges.push(el("span", { class: "badge err" }, errors + " errors"));
+
+  $("#trace-header").replaceChildren(
+    el("h2", { class: "mono" }, trace.trace_id),
+    el("div", { class: "meta" },
+      `${formatTime(trace.start_time)} · ${formatDuration(current.duration)} · ${spanCount} spans · ` +
+      `${(trace.services || []).length} services · depth ${trace.tree.depth + 1}`),
+    el("div", {}, ...badges),
+    el("div", { class: "legend" }, "Outlined bars are on the critical path; hatched bars are errors. Click a span for details."));
+}
+
+function renderAxis() {
+  const ticks = 5;
+  const axis = $("#axis");
+  axis.replaceChildren();
+  for (let i = 0; i < ticks; i++) {
+    axis.append(el("span", { style: { left: (i / ticks) * 100 + "%" } }, formatDuration(Math.round((current.duration * i) / ticks))));
+  }
+}
+
+function isHidden(row) {
+  for (let p = row.parent; p; p = p.parent) {
+    if (collapsed.has(p.span.span_id)) return true;
+  }
+  return false;
+}
+
+function renderRows() {
+  const { rows, start, duration } = current;
+  const out = [];
+  for (const row of rows) {
+    if (isHidden(row)) continue;
+    const span = row.span;
+    const offset = (row.start - start) * 1000;
+    const left = (offset / duration) * 100;
+    const width = (span.duration / duration) * 100;
+
+    const hasChildren = row.children && row.children.length > 0;
+    const toggle = el("span", { class: "toggle" }, hasChildren ? (collapsed.has(span.span_id) ? "▸" : "▾") : "");
+    if (hasChildren) {
+      toggle.addEventListener("click", (event) => {
+        event.stopPropagation();
+        if (collapsed.has(span.span_id)) collapsed.delete(span.span_id);
+        else collapsed.add(span.span_id);
+        renderRows();
+      });
+    }
+
+    const classes = ["bar"];
+    if (row.critical_path) classes.push("critical");
+    if (span.status === "error") classes.push("error");
+    if (span.in_progress) classes.push("running");
+
+    // The duration sits right of the bar, or left of it near the end
+    const label = left + width > 85
+      ? { right: 100 - left + "%" }
+      : { left: left + width + "%" };
+
+    out.push(el("div", {
+      class: "row" + (current.selected === row ? " selected" : ""),
+      title: `${span.service_name}: ${span.operation_name} (${formatDuration(span.duration)})`,
+      onclick: () => selectSpan(row),
+    },
+    el("div", { class: "label", style: { paddingLeft: row.depth * 14 + 4 + "px" } },
+      toggle,
+      el("span", { class: "service" }, span.service_name), " ",
+      el("span", { class: "op" }, span.operation_name),
+      row.orphan ? el("span", { class: "badge warn" }, "orphan") : null),
+    el("div", { class: "track" },
+      el("div", { class: classes.join(" "), style: { left: left + "%", width: width + "%", background: serviceColor(span.service_name) } }),
+      el("span", { class: "duration", style: label }, formatDuration(span.duration)))));
+  }
+  $("#rows").replaceChildren(...out);
+}
+
+function selectSpan(row) {
+  current.selected = row;
+  renderRows();
+
+  const span = row.span;
+  const rowsOf = (pairs) => el("table", {}, el("tbody", {},
+    ...pairs.filter(([, value]) => value !== undefined && value !== "")
+      .map(([key, value]) => el("tr", {}, el("td", {}, key), el("td", {}, value)))));
+
+  const offset = (row.start - current.start) * 1000;
+  const detail = [
+    el("h3", {}, `${span.service_name}: ${span.operation_name}`),
+    rowsOf([
+      ["Span ID", span.span_id],
+      ["Parent span ID", span.parent_span_id],
+      ["Kind", span.span_kind],
+      ["Status", span.status + (span.status_message ? ": " + span.status_message : "")],
+      ["Start", `${span.start_time} (+${formatDuration(offset)})`],
+      ["Duration", formatDuration(span.duration) + (span.in_progress ? " (in progress)" : "")],
+      ["Self time", formatDuration(row.self_time || 0)],
+      ["Critical path", row.critical_path ? "yes" : "no"],
+      ["Deployment", span.deployment_id],
+      ["Git SHA", span.git_sha],
+      ["Environment", span.environment],
+      ["Cost", span.cost ? String(span.cost) : ""],
+    ]),
+  ];
+
+  const tags = Object.entries(span.tags || {}).sort(([a], [b]) => a.localeCompare(b));
+  if (tags.length) detail.push(el("h4", {}, "Tags"), rowsOf(tags));
+
+  if (span.events && span.events.length) {
+    detail.push(el("h4", {}, "Events"), rowsOf(span.events.map((event) => {
+      const at = (parseMicros(event.timestamp) - row.start) * 1000;
+      const attrs = Object.entries(event.attributes || {}).map(([k, v]) => `${k}=${v}`).join(" ");
+      return [`+${formatDuration(at)}`, event.name + (attrs ? " " + attrs : "")];
+    })));
+  }
+
+  const panel = $("#span-detail");
+  panel.replaceChildren(...detail);
+  panel.hidden = false;
+  panel.scrollIntoView({ block: "nearest" });
+}
+
+// ---------------------------------------------------------------------------
+// Routing: #/trace/<id> shows a trace, #/search?<params> (or nothing) the
+// search with its parameters, so either can be bookmarked and shared.
+
+function route() {
+  const hash = location.hash.slice(1);
+  const traceMatch = /^\/trace\/(.+)$/.exec(hash);
+  $("#search-view").hidden = !!traceMatch;
+  $("#trace-view").hidden = !traceMatch;
+
+  if (traceMatch) {
+    showTrace(decodeURIComponent(traceMatch[1]));
+    return;
+  }
+  const params = new URLSearchParams(hash.startsWith("/search?") ? hash.slice("/search?".length) : "");
+  fillForm(params);
+  const search = formParams();
+  if (params.has("offset")) search.set("offset", params.get("offset")); // Set by the page buttons
+  runSearch(search);
+}
+
+form.addEventListener("submit", (event) => {
+  event.preventDefault();
+  const next = "#/search?" + formParams().toString();
+  if (location.hash === next) route();
+  else location.hash = next;
+});
+
+form.elements.lookback.addEventListener("change", toggleCustomRange);
+
+$("#lookup").addEventListener("submit", (event) => {
+  event.preventDefault();
+  const id = $("#lookup-id").value.trim();
+  if (id) location.hash = "#/trace/" + encodeURIComponent(id);
+});
+
+window.addEventListener("hashchange", route);
+loadServices().then(route);
@@ -0,0 +1,38 @@
+// Package ui embeds the trace explorer: a single-page app served by the
+// collector that lists services, searches traces with the query API's
+// filters and draws a trace as a waterfall. It uses only the public HTTP
+// API, so anything it shows can also be fetched with curl.
+package ui
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed static
+var static embed.FS
+
+// Handler serves the app's files. Mount it at a path ending in "/" with
+// that path stripped, e.g. http.StripPrefix("/ui", Handler()) at "/ui/":
+// the app calls the API relative to its own URL, at ../api/v1/, so it also
+// works behind a proxy that serves the collector under a path prefix.
+func Handler() http.Handler {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		panic(err) // static/ is embedded at build time
+	}
+	fileServer := http.FileServer(http.FS(files))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// Files change with the binary, and embedded files have no
+		// modification time to revalidate against
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		fileServer.ServeHTTP(w, r)
+	})
+}
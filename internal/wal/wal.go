@@ -0,0 +1,406 @@
+// Package wal is an append-only write-ahead log split into segment files.
+// Each record is acknowledged once it has been processed; a segment is
+// deleted when it has been rotated out and all its records are acknowledged.
+// Segments still on disk when the log is opened are replayed, so records
+// accepted before a crash are processed at least once.
+package wal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSegmentSize is the size at which the active segment is rotated.
+const DefaultSegmentSize = 64 << 20
+
+// maxRecordSize bounds a record; a larger length prefix means corruption.
+const maxRecordSize = 64 << 20
+
+// recordHeaderSize is the length and CRC-32C prefix of every record.
+const recordHeaderSize = 8
+
+const segmentSuffix = ".wal"
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ErrClosed is returned by Append after Close.
+var ErrClosed = errors.New("wal: log closed")
+
+// Options configures a Log.
+type Options struct {
+	// SegmentSize rotates the active segment once it reaches this many
+	// bytes (0 = DefaultSegmentSize).
+	SegmentSize int64
+
+	// SyncInterval batches fsyncs: records are synced in the background on
+	// this period instead of before Append returns, trading up to one
+	// interval of records on power loss for throughput (0 = sync every append).
+	SyncInterval time.Duration
+}
+
+// Position identifies where a record was appended, for Ack.
+// The zero Position refers to no record.
+type Position struct {
+	segment uint64
+}
+
+// Log is a segmented write-ahead log. It is safe for concurrent use.
+type Log struct {
+	dir  string
+	opts Options
+
+	mu         sync.Mutex
+	active     *os.File
+	activeID   uint64
+	activeSize int64
+	dirty      bool           // Appended since the last sync
+	pending    map[uint64]int // Segment -> unacknowledged records
+	replay     []uint64       // Segments found at Open, oldest first
+	closed     bool
+	stopSync   chan struct{}
+	syncDone   chan struct{}
+}
+
+// Open opens the log in dir, creating the directory if needed. Segments
+// already present are kept for Replay; new records go to a fresh segment.
+func Open(dir string, opts Options) (*Log, error) {
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = DefaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	ids, err := listSegments(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Log{
+		dir:     dir,
+		opts:    opts,
+		pending: make(map[uint64]int),
+		replay:  ids,
+	}
+	next := uint64(1)
+	if len(ids) > 0 {
+		next = ids[len(ids)-1] + 1
+	}
+	if err := l.openSegment(next); err != nil {
+		return nil, err
+	}
+
+	if opts.SyncInterval > 0 {
+		l.stopSync = make(chan struct{})
+		l.syncDone = make(chan struct{})
+		go l.syncLoop()
+	}
+	return l, nil
+}
+
+// Dir returns the directory holding the segments.
+func (l *Log) Dir() string {
+	return l.dir
+}
+
+// Append writes a record and returns its position. Unless SyncInterval is
+// set, the record is on stable storage when Append returns.
+func (l *Log) Append(data []byte) (Position, error) {
+	if len(data) > maxRecordSize {
+		return Position{}, fmt.Errorf("wal: record of %d bytes exceeds %d", len(data), maxRecordSize)
+	}
+
+	record := make([]byte, recordHeaderSize+len(data))
+	binary.LittleEndian.PutUint32(record[0:4], uint32(len(data)))
+	binary.LittleEndian.PutUint32(record[4:8], crc32.Checksum(data, crcTable))
+	copy(record[recordHeaderSize:], data)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.closed {
+		return Position{}, ErrClosed
+	}
+	if l.activeSize > 0 && l.activeSize+int64(len(record)) > l.opts.SegmentSize {
+		if err := l.rotate(); err != nil {
+			return Position{}, err
+		}
+	}
+
+	if _, err := l.active.Write(record); err != nil {
+		return Position{}, err
+	}
+	l.activeSize += int64(len(record))
+	if l.opts.SyncInterval > 0 {
+		l.dirty = true
+	} else if err := l.active.Sync(); err != nil {
+		return Position{}, err
+	}
+
+	l.pending[l.activeID]++
+	return Position{segment: l.activeID}, nil
+}
+
+// Ack marks the record at pos as processed. Acknowledging the zero
+// Position is a no-op.
+func (l *Log) Ack(pos Position) {
+	if pos.segment == 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	n := l.pending[pos.segment] - 1
+	if n > 0 || pos.segment == l.activeID {
+		l.pending[pos.segment] = max(n, 0)
+		return
+	}
+	delete(l.pending, pos.segment)
+	os.Remove(l.segmentPath(pos.segment))
+}
+
+// ReplayResult summarizes a Replay.
+type ReplayResult struct {
+	Segments int // Segments replayed and deleted
+	Records  int // Records passed to the callback
+	Torn     int // Segments ending in a partial or corrupt record, skipped from there
+}
+
+// Replay passes every record of the segments found at Open to fn, oldest
+// first, deleting each segment once all its records were handled. A
+// segment ending in a partial or corrupt record (a write cut short by a
+// crash) is replayed up to that record. If fn fails, replay stops and the
+// remaining segments are kept for the next Open.
+func (l *Log) Replay(fn func(data []byte) error) (ReplayResult, error) {
+	l.mu.Lock()
+	ids := l.replay
+	l.mu.Unlock()
+
+	return l.replaySegments(ids, fn)
+}
+
+// Drain seals the active segment, if it holds records, and passes every
+// record of the sealed segments (those found at Open and those rotated out
+// since) to fn like Replay. It is for logs used as an on-disk queue, whose
+// records are consumed by draining rather than acknowledged. Records
+// appended meanwhile go to a new segment for the next Drain. Drain must not
+// run concurrently with itself or Replay.
+func (l *Log) Drain(fn func(data []byte) error) (ReplayResult, error) {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return ReplayResult{}, ErrClosed
+	}
+	if l.activeSize > 0 {
+		if err := l.rotate(); err != nil {
+			l.mu.Unlock()
+			return ReplayResult{}, err
+		}
+	}
+	ids, err := listSegments(l.dir)
+	activeID := l.activeID
+	l.mu.Unlock()
+	if err != nil {
+		return ReplayResult{}, err
+	}
+
+	sealed := ids[:0]
+	for _, id := range ids {
+		if id < activeID {
+			sealed = append(sealed, id)
+		}
+	}
+	return l.replaySegments(sealed, fn)
+}
+
+// replaySegments passes the records of segments ids to fn, deleting each
+// segment once all its records were handled. On failure, the segments not
+// yet deleted are kept for the next Replay.
+func (l *Log) replaySegments(ids []uint64, fn func(data []byte) error) (ReplayResult, error) {
+	var result ReplayResult
+	for i, id := range ids {
+		records, torn, err := readSegment(l.segmentPath(id), fn)
+		result.Records += records
+		if err != nil {
+			l.mu.Lock()
+			l.replay = ids[i:]
+			l.mu.Unlock()
+			return result, fmt.Errorf("wal: replaying segment %d: %w", id, err)
+		}
+		if torn {
+			result.Torn++
+		}
+		if err := os.Remove(l.segmentPath(id)); err != nil {
+			return result, err
+		}
+		l.mu.Lock()
+		delete(l.pending, id)
+		l.mu.Unlock()
+		result.Segments++
+	}
+
+	l.mu.Lock()
+	l.replay = nil
+	l.mu.Unlock()
+	return result, nil
+}
+
+// Close syncs and closes the active segment, deleting it if all its records
+// were acknowledged.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	l.mu.Unlock()
+
+	if l.stopSync != nil {
+		close(l.stopSync)
+		<-l.syncDone
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.active.Sync()
+	if cerr := l.active.Close(); err == nil {
+		err = cerr
+	}
+
+	if l.pending[l.activeID] == 0 {
+		os.Remove(l.segmentPath(l.activeID))
+	}
+	return err
+}
+
+// rotate seals the active segment and starts the next one. Caller holds mu.
+func (l *Log) rotate() error {
+	if err := l.active.Sync(); err != nil {
+		return err
+	}
+	if err := l.active.Close(); err != nil {
+		return err
+	}
+	l.dirty = false
+	if l.pending[l.activeID] == 0 {
+		delete(l.pending, l.activeID)
+		os.Remove(l.segmentPath(l.activeID))
+	}
+	return l.openSegment(l.activeID + 1)
+}
+
+// openSegment creates segment id and makes it active.
+func (l *Log) openSegment(id uint64) error {
+	f, err := os.OpenFile(l.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	l.active = f
+	l.activeID = id
+	l.activeSize = 0
+	return nil
+}
+
+func (l *Log) syncLoop() {
+	defer close(l.syncDone)
+
+	ticker := time.NewTicker(l.opts.SyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stopSync:
+			return
+		case <-ticker.C:
+			l.mu.Lock()
+			if l.dirty {
+				l.active.Sync()
+				l.dirty = false
+			}
+			l.mu.Unlock()
+		}
+	}
+}
+
+func (l *Log) segmentPath(id uint64) string {
+	return filepath.Join(l.dir, fmt.Sprintf("%016d%s", id, segmentSuffix))
+}
+
+// listSegments returns the segment IDs in dir in ascending order.
+func listSegments(dir string) ([]uint64, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []uint64
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentSuffix)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		id, err := strconv.ParseUint(name, 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids, nil
+}
+
+// readSegment passes each record in path to fn. torn reports a partial or
+// corrupt record, where reading stopped.
+func readSegment(path string, fn func([]byte) error) (records int, torn bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			if err == io.EOF {
+				return records, false, nil
+			}
+			if err == io.ErrUnexpectedEOF {
+				return records, true, nil
+			}
+			return records, false, err
+		}
+
+		size := binary.LittleEndian.Uint32(header[0:4])
+		if size > maxRecordSize {
+			return records, true, nil
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return records, true, nil
+			}
+			return records, false, err
+		}
+		if crc32.Checksum(data, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+			return records, true, nil
+		}
+
+		if err := fn(data); err != nil {
+			return records, false, err
+		}
+		records++
+	}
+}
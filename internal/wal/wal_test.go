@@ -0,0 +1,185 @@
+package wal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func segmentFiles(t *testing.T, dir string) []uint64 {
+	t.Helper()
+	ids, err := listSegments(dir)
+	if err != nil {
+		t.Fatalf("listSegments failed: %v", err)
+	}
+	return ids
+}
+
+func replayAll(t *testing.T, l *Log) ([]string, ReplayResult) {
+	t.Helper()
+	var records []string
+	result, err := l.Replay(func(data []byte) error {
+		records = append(records, string(data))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Replay failed: %v", err)
+	}
+	return records, result
+}
+
+func TestLog_ReplaysUnacknowledgedSegments(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for _, record := range []string{"a", "b"} {
+		if _, err := l.Append([]byte(record)); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	// Simulate a crash: no Ack, no Close
+	l.active.Close()
+
+	l, err = Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer l.Close()
+
+	records, result := replayAll(t, l)
+	if len(records) != 2 || records[0] != "a" || records[1] != "b" {
+		t.Errorf("replayed %v, want [a b]", records)
+	}
+	if result.Segments != 1 || result.Torn != 0 {
+		t.Errorf("result = %+v", result)
+	}
+	if ids := segmentFiles(t, dir); len(ids) != 1 || ids[0] != 2 {
+		t.Errorf("segments after replay = %v, want only the new active one", ids)
+	}
+}
+
+func TestLog_AckDeletesRotatedSegments(t *testing.T) {
+	dir := t.TempDir()
+	// Every record fills a segment
+	l, err := Open(dir, Options{SegmentSize: recordHeaderSize + 1})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	first, _ := l.Append([]byte("1"))
+	second, _ := l.Append([]byte("2"))
+	if first == second {
+		t.Fatal("expected records in different segments")
+	}
+	if ids := segmentFiles(t, dir); len(ids) != 2 {
+		t.Fatalf("segments = %v, want 2", ids)
+	}
+
+	l.Ack(first)
+	if ids := segmentFiles(t, dir); len(ids) != 1 {
+		t.Errorf("segments after acking the sealed one = %v", ids)
+	}
+
+	// The active segment survives acks; Close removes it once nothing is pending
+	l.Ack(second)
+	if ids := segmentFiles(t, dir); len(ids) != 1 {
+		t.Errorf("active segment removed early: %v", ids)
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if ids := segmentFiles(t, dir); len(ids) != 0 {
+		t.Errorf("segments after clean close = %v", ids)
+	}
+	if _, err := l.Append([]byte("x")); !errors.Is(err, ErrClosed) {
+		t.Errorf("Append after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestLog_ReplayStopsAtTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := Open(dir, Options{})
+	l.Append([]byte("complete"))
+	l.Append([]byte("cut short"))
+	l.active.Close()
+
+	// Drop the last bytes, as if the machine died mid-write
+	path := filepath.Join(dir, "0000000000000001.wal")
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatalf("truncate failed: %v", err)
+	}
+
+	l, _ = Open(dir, Options{})
+	defer l.Close()
+	records, result := replayAll(t, l)
+	if len(records) != 1 || records[0] != "complete" || result.Torn != 1 {
+		t.Errorf("replayed %v with result %+v", records, result)
+	}
+}
+
+func TestLog_ReplayKeepsSegmentOnCallbackError(t *testing.T) {
+	dir := t.TempDir()
+	l, _ := Open(dir, Options{})
+	l.Append([]byte("a"))
+	l.active.Close()
+
+	l, _ = Open(dir, Options{})
+	if _, err := l.Replay(func([]byte) error { return errors.New("queue closed") }); err == nil {
+		t.Fatal("expected replay error")
+	}
+	l.Close()
+
+	// Still there for the next start
+	l, _ = Open(dir, Options{})
+	defer l.Close()
+	if records, _ := replayAll(t, l); len(records) != 1 {
+		t.Errorf("replayed %v after failed replay", records)
+	}
+}
+
+func TestLog_DrainConsumesSealedRecords(t *testing.T) {
+	dir := t.TempDir()
+	l, err := Open(dir, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer l.Close()
+
+	drain := func(appendDuring string) []string {
+		t.Helper()
+		var records []string
+		_, err := l.Drain(func(data []byte) error {
+			records = append(records, string(data))
+			if appendDuring != "" {
+				l.Append([]byte(appendDuring))
+				appendDuring = ""
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Drain failed: %v", err)
+		}
+		return records
+	}
+
+	l.Append([]byte("a"))
+	l.Append([]byte("b"))
+
+	// Records appended while draining wait for the next Drain
+	if records := drain("c"); len(records) != 2 || records[0] != "a" || records[1] != "b" {
+		t.Errorf("first drain = %v, want [a b]", records)
+	}
+	if records := drain(""); len(records) != 1 || records[0] != "c" {
+		t.Errorf("second drain = %v, want [c]", records)
+	}
+	if records := drain(""); len(records) != 0 {
+		t.Errorf("third drain = %v, want nothing", records)
+	}
+	if ids := segmentFiles(t, dir); len(ids) != 1 {
+		t.Errorf("segments after draining = %v, want only the active one", ids)
+	}
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// Features a collector may advertise in Capabilities. SDKs only use a
+// feature the collector lists, so a newer SDK degrades gracefully against an
+// older collector instead of sending data it would refuse or drop.
+const (
+	FeatureEvents   = "events"   // Span events are accepted and stored
+	FeatureProtobuf = "protobuf" // Payloads in the span.proto wire format
+	FeatureGzip     = "gzip"     // Gzip request bodies
+	FeatureZstd     = "zstd"     // Zstandard request bodies
+)
+
+// Capabilities is what a collector accepts on span ingestion, advertised on
+// ingestion responses and in GET /api/v1/info.
+type Capabilities struct {
+	// SchemaVersion is the newest span schema_version the collector reads
+	SchemaVersion int `json:"schema_version"`
+
+	// Features lists the supported optional features (see FeatureEvents)
+	Features []string `json:"features"`
+
+	// MaxSpanEvents is how many events a span may carry (0 = no events)
+	MaxSpanEvents int `json:"max_span_events"`
+}
+
+// Supports reports whether feature is among the advertised features.
+func (c *Capabilities) Supports(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatFeatures joins features for a header, sorted so equal sets format
+// equally.
+func FormatFeatures(features []string) string {
+	sorted := append([]string(nil), features...)
+	sort.Strings(sorted)
+	return strings.Join(sorted, ",")
+}
+
+// ParseFeatures splits a header formatted by FormatFeatures, ignoring
+// whitespace and empty entries.
+func ParseFeatures(header string) []string {
+	var features []string
+	for _, f := range strings.Split(header, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			features = append(features, f)
+		}
+	}
+	return features
+}
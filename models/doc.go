@@ -0,0 +1,22 @@
+// Package models defines the span and trace types the traceflow collector
+// stores and serves, their validation and the versioned span wire format.
+// Custom receivers, exporters and storage backends built outside this
+// repository use these types to exchange spans with the collector:
+//
+//	import "github.com/saintparish4/asmbly/models"
+//
+//	span := &models.Span{
+//		TraceID:       models.GenerateTraceID(),
+//		SpanID:        models.GenerateSpanID(),
+//		ServiceName:   "checkout",
+//		OperationName: "POST /orders",
+//		StartTime:     time.Now(),
+//		Status:        "ok",
+//	}
+//	if err := span.Validate(); err != nil {
+//		// reject the span
+//	}
+//
+// The exported API is stable: later versions only add to it, and spans
+// encoded in an older schema version still decode (see SchemaVersion1).
+package models
@@ -3,6 +3,7 @@ package models
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"strings"
 )
 
 // GenerateTraceID creates a cryptographically random 128-bit trace ID.
@@ -12,13 +13,17 @@ import (
 // ensuring trace IDs are globally unique across all services.
 func GenerateTraceID() string {
 	b := make([]byte, 16) // 128 bits = 16 bytes
-	_, err := rand.Read(b)
-	if err != nil {
-		// crypto/rand.Read only fails on catastrophic system errors
-		// In practice, this should never happen on modern systems
-		panic("failed to generate random trace ID: " + err.Error())
+	for {
+		_, err := rand.Read(b)
+		if err != nil {
+			// crypto/rand.Read only fails on catastrophic system errors
+			// In practice, this should never happen on modern systems
+			panic("failed to generate random trace ID: " + err.Error())
+		}
+		if !isZeroBytes(b) { // All zeros is the invalid trace ID
+			return hex.EncodeToString(b) // 16 bytes → 32 hex chars
+		}
 	}
-	return hex.EncodeToString(b) // 16 bytes → 32 hex chars
 }
 
 // GenerateSpanID creates a cryptographically random 64-bit span ID.
@@ -28,32 +33,40 @@ func GenerateTraceID() string {
 // ensuring span IDs are unique within a trace.
 func GenerateSpanID() string {
 	b := make([]byte, 8) // 64 bits = 8 bytes
-	_, err := rand.Read(b)
-	if err != nil {
-		// crypto/rand.Read only fails on catastrophic system errors
-		panic("failed to generate random span ID: " + err.Error())
+	for {
+		_, err := rand.Read(b)
+		if err != nil {
+			// crypto/rand.Read only fails on catastrophic system errors
+			panic("failed to generate random span ID: " + err.Error())
+		}
+		if !isZeroBytes(b) { // All zeros is the invalid span ID
+			return hex.EncodeToString(b) // 8 bytes → 16 hex chars
+		}
 	}
-	return hex.EncodeToString(b) // 8 bytes → 16 hex chars
 }
 
 // IsValidTraceID validates that a trace ID is properly formatted:
 // - Exactly 32 characters
 // - All characters are lowercase hexadecimal (0-9, a-f)
+// - Not all zeros, which W3C Trace Context and OpenTelemetry reserve for
+// "no trace"
 func IsValidTraceID(id string) bool {
 	if len(id) != 32 {
 		return false
 	}
-	return isHex(id)
+	return isHex(id) && !isZeroID(id)
 }
 
 // IsValidSpanID validates that a span ID is properly formatted:
 // - Exactly 16 characters
 // - All characters are lowercase hexadecimal (0-9, a-f)
+// - Not all zeros, which W3C Trace Context and OpenTelemetry reserve for
+// "no span"
 func IsValidSpanID(id string) bool {
 	if len(id) != 16 {
 		return false
 	}
-	return isHex(id)
+	return isHex(id) && !isZeroID(id)
 }
 
 // isHex checks if a string contains only hexadecimal characters (0-9, a-f, A-F).
@@ -67,3 +80,18 @@ func isHex(s string) bool {
 	}
 	return true
 }
+
+// isZeroID checks if a hex ID is all zeros.
+func isZeroID(id string) bool {
+	return strings.Trim(id, "0") == ""
+}
+
+// isZeroBytes checks if every byte of b is zero.
+func isZeroBytes(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,280 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// ProtobufContentType is the Content-Type of span payloads in the protobuf
+// wire format defined by span.proto.
+const ProtobufContentType = "application/x-protobuf"
+
+// Field numbers from span.proto
+const (
+	spanTraceID       protowire.Number = 1
+	spanSpanID        protowire.Number = 2
+	spanParentSpanID  protowire.Number = 3
+	spanServiceName   protowire.Number = 4
+	spanOperationName protowire.Number = 5
+	spanStartTime     protowire.Number = 6
+	spanDuration      protowire.Number = 7
+	spanKind          protowire.Number = 8
+	spanStatus        protowire.Number = 9
+	spanStatusMessage protowire.Number = 10
+	spanInProgress    protowire.Number = 11
+	spanTags          protowire.Number = 12
+	spanEvents        protowire.Number = 13
+	spanDeploymentID  protowire.Number = 14
+	spanGitSHA        protowire.Number = 15
+	spanEnvironment   protowire.Number = 16
+	spanCost          protowire.Number = 17
+	spanHasProfile    protowire.Number = 18
+	spanProfileID     protowire.Number = 19
+
+	eventName       protowire.Number = 1
+	eventTime       protowire.Number = 2
+	eventAttributes protowire.Number = 3
+
+	batchSpans protowire.Number = 1
+)
+
+// MarshalProto encodes the span as a span.proto Span message.
+func (s *Span) MarshalProto() []byte {
+	return s.appendProto(nil)
+}
+
+// UnmarshalProto decodes a span.proto Span message into s. Unknown fields
+// are skipped, so payloads from newer clients still decode.
+func (s *Span) UnmarshalProto(data []byte) error {
+	*s = Span{}
+	return rangeFields(data, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		switch {
+		case typ == protowire.BytesType:
+			switch num {
+			case spanTraceID:
+				s.TraceID = string(b)
+			case spanSpanID:
+				s.SpanID = string(b)
+			case spanParentSpanID:
+				s.ParentSpanID = string(b)
+			case spanServiceName:
+				s.ServiceName = string(b)
+			case spanOperationName:
+				s.OperationName = string(b)
+			case spanKind:
+				s.SpanKind = string(b)
+			case spanStatus:
+				s.Status = string(b)
+			case spanStatusMessage:
+				s.StatusMessage = string(b)
+			case spanTags:
+				if s.Tags == nil {
+					s.Tags = make(map[string]string)
+				}
+				return unmarshalMapEntry(b, s.Tags)
+			case spanEvents:
+				var event SpanEvent
+				if err := event.unmarshalProto(b); err != nil {
+					return err
+				}
+				s.Events = append(s.Events, event)
+			case spanDeploymentID:
+				s.DeploymentID = string(b)
+			case spanGitSHA:
+				s.GitSHA = string(b)
+			case spanEnvironment:
+				s.Environment = string(b)
+			case spanProfileID:
+				s.ProfileID = string(b)
+			}
+		case typ == protowire.VarintType:
+			switch num {
+			case spanStartTime:
+				s.StartTime = time.Unix(0, int64(v)).UTC()
+			case spanDuration:
+				s.Duration = time.Duration(int64(v))
+			case spanInProgress:
+				s.InProgress = v != 0
+			case spanHasProfile:
+				s.HasProfile = v != 0
+			}
+		case typ == protowire.Fixed64Type && num == spanCost:
+			s.Cost = math.Float64frombits(v)
+		}
+		return nil
+	})
+}
+
+// MarshalSpansProto encodes spans as a span.proto SpanBatch message.
+func MarshalSpansProto(spans []*Span) []byte {
+	var b []byte
+	for _, span := range spans {
+		b = protowire.AppendTag(b, batchSpans, protowire.BytesType)
+		b = protowire.AppendBytes(b, span.MarshalProto())
+	}
+	return b
+}
+
+// UnmarshalSpansProto decodes a span.proto SpanBatch message.
+func UnmarshalSpansProto(data []byte) ([]Span, error) {
+	var spans []Span
+	err := rangeFields(data, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		if num != batchSpans || typ != protowire.BytesType {
+			return nil
+		}
+		var span Span
+		if err := span.UnmarshalProto(b); err != nil {
+			return err
+		}
+		spans = append(spans, span)
+		return nil
+	})
+	return spans, err
+}
+
+func (s *Span) appendProto(b []byte) []byte {
+	b = appendString(b, spanTraceID, s.TraceID)
+	b = appendString(b, spanSpanID, s.SpanID)
+	b = appendString(b, spanParentSpanID, s.ParentSpanID)
+	b = appendString(b, spanServiceName, s.ServiceName)
+	b = appendString(b, spanOperationName, s.OperationName)
+	if !s.StartTime.IsZero() {
+		b = appendVarint(b, spanStartTime, uint64(s.StartTime.UnixNano()))
+	}
+	b = appendVarint(b, spanDuration, uint64(s.Duration))
+	b = appendString(b, spanKind, s.SpanKind)
+	b = appendString(b, spanStatus, s.Status)
+	b = appendString(b, spanStatusMessage, s.StatusMessage)
+	b = appendBool(b, spanInProgress, s.InProgress)
+	b = appendMap(b, spanTags, s.Tags)
+	for i := range s.Events {
+		b = protowire.AppendTag(b, spanEvents, protowire.BytesType)
+		b = protowire.AppendBytes(b, s.Events[i].appendProto(nil))
+	}
+	b = appendString(b, spanDeploymentID, s.DeploymentID)
+	b = appendString(b, spanGitSHA, s.GitSHA)
+	b = appendString(b, spanEnvironment, s.Environment)
+	if s.Cost != 0 {
+		b = protowire.AppendTag(b, spanCost, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(s.Cost))
+	}
+	b = appendBool(b, spanHasProfile, s.HasProfile)
+	b = appendString(b, spanProfileID, s.ProfileID)
+	return b
+}
+
+func (e *SpanEvent) appendProto(b []byte) []byte {
+	b = appendString(b, eventName, e.Name)
+	if !e.Timestamp.IsZero() {
+		b = appendVarint(b, eventTime, uint64(e.Timestamp.UnixNano()))
+	}
+	return appendMap(b, eventAttributes, e.Attributes)
+}
+
+func (e *SpanEvent) unmarshalProto(data []byte) error {
+	return rangeFields(data, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		switch {
+		case num == eventName && typ == protowire.BytesType:
+			e.Name = string(b)
+		case num == eventTime && typ == protowire.VarintType:
+			e.Timestamp = time.Unix(0, int64(v)).UTC()
+		case num == eventAttributes && typ == protowire.BytesType:
+			if e.Attributes == nil {
+				e.Attributes = make(map[string]string)
+			}
+			return unmarshalMapEntry(b, e.Attributes)
+		}
+		return nil
+	})
+}
+
+// rangeFields calls fn for each field of a message, with v holding varint
+// and fixed values and b length-delimited ones. Fields of other wire types
+// are passed with neither, for fn to ignore.
+func rangeFields(data []byte, fn func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return fmt.Errorf("invalid protobuf: %w", protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		var v uint64
+		var b []byte
+		switch typ {
+		case protowire.VarintType:
+			v, n = protowire.ConsumeVarint(data)
+		case protowire.Fixed64Type:
+			v, n = protowire.ConsumeFixed64(data)
+		case protowire.BytesType:
+			b, n = protowire.ConsumeBytes(data)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, data)
+		}
+		if n < 0 {
+			return fmt.Errorf("invalid protobuf field %d: %w", num, protowire.ParseError(n))
+		}
+		data = data[n:]
+
+		if err := fn(num, typ, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unmarshalMapEntry decodes one map<string, string> entry into m.
+func unmarshalMapEntry(data []byte, m map[string]string) error {
+	var key, value string
+	err := rangeFields(data, func(num protowire.Number, typ protowire.Type, v uint64, b []byte) error {
+		if typ == protowire.BytesType {
+			switch num {
+			case 1:
+				key = string(b)
+			case 2:
+				value = string(b)
+			}
+		}
+		return nil
+	})
+	m[key] = value
+	return err
+}
+
+func appendString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendBool(b []byte, num protowire.Number, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarint(b, num, 1)
+}
+
+// appendMap encodes a map<string, string> field, one entry per element.
+func appendMap(b []byte, num protowire.Number, m map[string]string) []byte {
+	for key, value := range m {
+		var entry []byte
+		entry = appendString(entry, 1, key)
+		entry = appendString(entry, 2, value)
+		b = protowire.AppendTag(b, num, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func protoTestSpan() *Span {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 500, time.UTC)
+	return &Span{
+		TraceID:       GenerateTraceID(),
+		SpanID:        GenerateSpanID(),
+		ParentSpanID:  GenerateSpanID(),
+		ServiceName:   "checkout",
+		OperationName: "POST /orders",
+		StartTime:     start,
+		Duration:      42 * time.Millisecond,
+		SpanKind:      "server",
+		Status:        "error",
+		StatusMessage: "payment declined",
+		InProgress:    true,
+		Tags:          map[string]string{"http.method": "POST", "empty": ""},
+		Events: []SpanEvent{
+			{Name: "retry", Timestamp: start.Add(time.Millisecond), Attributes: map[string]string{"attempt": "2"}},
+			{Name: "cache miss", Timestamp: start.Add(2 * time.Millisecond)},
+		},
+		DeploymentID: "v2.3.1",
+		GitSHA:       "abc123",
+		Environment:  "prod",
+		Cost:         0.0025,
+		HasProfile:   true,
+		ProfileID:    "p-1",
+	}
+}
+
+func TestSpanProto_RoundTrip(t *testing.T) {
+	span := protoTestSpan()
+
+	var decoded Span
+	if err := decoded.UnmarshalProto(span.MarshalProto()); err != nil {
+		t.Fatalf("UnmarshalProto() error = %v", err)
+	}
+	if !reflect.DeepEqual(&decoded, span) {
+		t.Errorf("decoded = %+v\nwant %+v", decoded, *span)
+	}
+
+	// Zero values are omitted and decode as zero values
+	var empty Span
+	if err := empty.UnmarshalProto((&Span{}).MarshalProto()); err != nil || !reflect.DeepEqual(empty, Span{}) {
+		t.Errorf("empty span decoded as %+v, %v", empty, err)
+	}
+}
+
+func TestSpansProto_Batch(t *testing.T) {
+	spans := []*Span{protoTestSpan(), protoTestSpan()}
+	data := MarshalSpansProto(spans)
+
+	// Fields added by newer clients are skipped
+	data = protowire.AppendTag(data, 99, protowire.VarintType)
+	data = protowire.AppendVarint(data, 7)
+
+	decoded, err := UnmarshalSpansProto(data)
+	if err != nil {
+		t.Fatalf("UnmarshalSpansProto() error = %v", err)
+	}
+	if len(decoded) != 2 || decoded[1].SpanID != spans[1].SpanID || decoded[0].Tags["http.method"] != "POST" {
+		t.Errorf("decoded = %+v", decoded)
+	}
+
+	if _, err := UnmarshalSpansProto(data[:len(data)/2]); err == nil {
+		t.Error("expected an error for a truncated batch")
+	}
+}
+
+func BenchmarkUnmarshalSpans(b *testing.B) {
+	spans := make([]*Span, 100)
+	for i := range spans {
+		spans[i] = protoTestSpan()
+	}
+	jsonData, _ := json.Marshal(spans)
+	protoData := MarshalSpansProto(spans)
+
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var decoded []Span
+			if err := json.Unmarshal(jsonData, &decoded); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("protobuf", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := UnmarshalSpansProto(protoData); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,21 @@
+package models
+
+// SamplingRule overrides the sample rate for matching operations.
+type SamplingRule struct {
+	// Operation is an exact operation name or a pattern where '*' matches
+	// any run of characters, e.g. "GET /health*"
+	Operation string `json:"operation"`
+
+	// Rate is the fraction of matching traces kept: 0 drops all, 1 keeps all
+	Rate float64 `json:"rate"`
+}
+
+// SamplingStrategy is the sampling configuration the collector serves to
+// SDKs for one service, along with its current throttle hint so clients
+// that export rarely still learn when it is saturated.
+type SamplingStrategy struct {
+	Service     string         `json:"service,omitempty"`
+	DefaultRate float64        `json:"default_rate"`
+	Rules       []SamplingRule `json:"rules,omitempty"` // Checked in order; the first match wins
+	Throttle    *ThrottleHint  `json:"throttle,omitempty"`
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Span wire format versions, declared by clients in a span's schema_version
+// field. Spans without one are read as version 1, the format deployed SDKs
+// send, and every older version is upgraded on decode, so the format can
+// evolve without breaking clients.
+const (
+	// SchemaVersion1 carries tags and event attributes as string maps.
+	SchemaVersion1 = 1
+
+	// SchemaVersion2 adds typed attributes: "attributes" on spans and
+	// events may hold strings, numbers or booleans. Span attributes are
+	// merged into Tags (overriding tags of the same key), keeping their
+	// JSON text, e.g. 200 or true.
+	SchemaVersion2 = 2
+
+	// CurrentSchemaVersion is the newest version the collector reads.
+	CurrentSchemaVersion = SchemaVersion2
+)
+
+// ErrUnsupportedSchemaVersion is returned when decoding a span declaring a
+// schema_version newer than CurrentSchemaVersion.
+var ErrUnsupportedSchemaVersion = errors.New("unsupported schema_version")
+
+// AttributeValue is a typed attribute as text: a JSON string's contents, or
+// the literal of a number or boolean.
+type AttributeValue string
+
+// UnmarshalJSON accepts a string, number or boolean.
+func (v *AttributeValue) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	switch {
+	case len(data) > 0 && data[0] == '"':
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*v = AttributeValue(s)
+	case bytes.Equal(data, []byte("true")), bytes.Equal(data, []byte("false")):
+		*v = AttributeValue(data)
+	default:
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("attribute value must be a string, number or boolean, got %s", data)
+		}
+		*v = AttributeValue(n)
+	}
+	return nil
+}
+
+// spanFields has Span's fields without its methods, so decoding into it
+// does not recurse into Span.UnmarshalJSON.
+type spanFields Span
+
+// wireSpan is a span in any supported wire format version.
+type wireSpan struct {
+	spanFields
+	SchemaVersion int                       `json:"schema_version,omitempty"`
+	Attributes    map[string]AttributeValue `json:"attributes,omitempty"`
+	Events        []wireEvent               `json:"events,omitempty"` // Shadows Span.Events
+}
+
+type wireEvent struct {
+	SpanEvent
+	Attributes map[string]AttributeValue `json:"attributes,omitempty"` // Shadows SpanEvent.Attributes
+}
+
+// UnmarshalJSON decodes a span in any supported wire format version and
+// upgrades it to the current model.
+func (s *Span) UnmarshalJSON(data []byte) error {
+	var w wireSpan
+	if err := json.Unmarshal(data, &w); err != nil {
+		return err
+	}
+
+	version := w.SchemaVersion
+	if version == 0 {
+		version = SchemaVersion1
+	}
+	if version < SchemaVersion1 || version > CurrentSchemaVersion {
+		return fmt.Errorf("%w %d (supported: %d to %d)", ErrUnsupportedSchemaVersion, w.SchemaVersion, SchemaVersion1, CurrentSchemaVersion)
+	}
+
+	*s = Span(w.spanFields)
+	s.Events = nil
+	for _, e := range w.Events {
+		event := e.SpanEvent
+		event.Attributes = attributeStrings(e.Attributes)
+		s.Events = append(s.Events, event)
+	}
+	// Typed span attributes (version 2) override tags of the same key
+	for key, value := range w.Attributes {
+		s.SetTag(key, string(value))
+	}
+	return nil
+}
+
+// attributeStrings converts typed attributes to the model's string map.
+func attributeStrings(attrs map[string]AttributeValue) map[string]string {
+	if attrs == nil {
+		return nil
+	}
+	out := make(map[string]string, len(attrs))
+	for key, value := range attrs {
+		out[key] = string(value)
+	}
+	return out
+}
+
+// VersionedSpan encodes a span with an explicit schema_version. Span itself
+// encodes as version 1 (string tags), so clients declare SchemaVersion1.
+type VersionedSpan struct {
+	*Span
+	SchemaVersion int `json:"schema_version"`
+}
+
+// WithSchemaVersion wraps spans for encoding as version 1 payloads.
+func WithSchemaVersion(spans []*Span) []VersionedSpan {
+	out := make([]VersionedSpan, len(spans))
+	for i, span := range spans {
+		out[i] = VersionedSpan{Span: span, SchemaVersion: SchemaVersion1}
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSpanUnmarshalJSON_Versions(t *testing.T) {
+	v1 := `{"trace_id": "t", "span_id": "s", "service_name": "api", "operation_name": "GET /",
+		"start_time": "2024-01-01T00:00:00Z", "status": "ok",
+		"tags": {"http.method": "GET"},
+		"events": [{"name": "retry", "timestamp": "2024-01-01T00:00:01Z", "attributes": {"attempt": "2"}}]}`
+	v2 := `{"schema_version": 2, "trace_id": "t", "span_id": "s", "service_name": "api", "operation_name": "GET /",
+		"start_time": "2024-01-01T00:00:00Z", "status": "ok",
+		"tags": {"http.method": "GET", "http.status_code": "500"},
+		"attributes": {"http.status_code": 200, "cache.hit": true, "ratio": 0.5, "peer": "db-1"},
+		"events": [{"name": "retry", "timestamp": "2024-01-01T00:00:01Z", "attributes": {"attempt": 2}}]}`
+
+	var old, typed Span
+	if err := json.Unmarshal([]byte(v1), &old); err != nil {
+		t.Fatalf("v1: %v", err)
+	}
+	if err := json.Unmarshal([]byte(v2), &typed); err != nil {
+		t.Fatalf("v2: %v", err)
+	}
+
+	if old.Tags["http.method"] != "GET" || old.Events[0].Attributes["attempt"] != "2" {
+		t.Errorf("v1 span = %+v", old)
+	}
+	if !old.StartTime.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || old.ServiceName != "api" {
+		t.Errorf("v1 core fields = %+v", old)
+	}
+	want := map[string]string{"http.method": "GET", "http.status_code": "200", "cache.hit": "true", "ratio": "0.5", "peer": "db-1"}
+	for key, value := range want {
+		if typed.Tags[key] != value {
+			t.Errorf("v2 tag %s = %q, want %q", key, typed.Tags[key], value)
+		}
+	}
+	if typed.Events[0].Attributes["attempt"] != "2" {
+		t.Errorf("v2 event attributes = %v", typed.Events[0].Attributes)
+	}
+}
+
+func TestSpanUnmarshalJSON_Rejects(t *testing.T) {
+	var span Span
+	err := json.Unmarshal([]byte(`{"schema_version": 3, "trace_id": "t"}`), &span)
+	if !errors.Is(err, ErrUnsupportedSchemaVersion) {
+		t.Errorf("future version: err = %v, want ErrUnsupportedSchemaVersion", err)
+	}
+
+	// The error also surfaces from inside a batch
+	var spans []Span
+	err = json.Unmarshal([]byte(`[{"schema_version": 1}, {"schema_version": 9}]`), &spans)
+	if !errors.Is(err, ErrUnsupportedSchemaVersion) {
+		t.Errorf("batch: err = %v, want ErrUnsupportedSchemaVersion", err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"schema_version": 2, "attributes": {"a": {"nested": 1}}}`), &span); err == nil {
+		t.Error("object attribute value decoded, want error")
+	}
+}
+
+func TestWithSchemaVersion_RoundTrip(t *testing.T) {
+	span := &Span{
+		TraceID:   GenerateTraceID(),
+		SpanID:    GenerateSpanID(),
+		StartTime: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Status:    "ok",
+		Tags:      map[string]string{"k": "v"},
+	}
+	data, err := json.Marshal(WithSchemaVersion([]*Span{span}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw []map[string]interface{}
+	json.Unmarshal(data, &raw)
+	if raw[0]["schema_version"] != float64(SchemaVersion1) || raw[0]["trace_id"] != span.TraceID {
+		t.Errorf("encoded = %s", data)
+	}
+	var decoded []Span
+	if err := json.Unmarshal(data, &decoded); err != nil || decoded[0].Tags["k"] != "v" {
+		t.Errorf("decoded = %+v, err %v", decoded, err)
+	}
+}
+
+// FuzzSpanUnmarshalJSON feeds arbitrary bytes to the span decoder, which
+// reads untrusted request bodies. Decoding and validation must not panic,
+// and a span that validates must survive a round trip.
+func FuzzSpanUnmarshalJSON(f *testing.F) {
+	f.Add([]byte(`{"trace_id":"a1b2c3d4e5f6789012345678901234ab","span_id":"1111111111111111","service_name":"api","operation_name":"GET /","start_time":"2024-01-15T10:30:00Z","duration":1000,"status":"ok","tags":{"k":"v"}}`))
+	f.Add([]byte(`{"schema_version":2,"attributes":{"n":1,"b":true,"s":"x"},"events":[{"name":"retry","timestamp":"2024-01-15T10:30:00Z","attributes":{"attempt":2}}]}`))
+	f.Add([]byte(`{"schema_version":-1}`))
+	f.Add([]byte(`[]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var span Span
+		if err := json.Unmarshal(data, &span); err != nil {
+			return
+		}
+		if span.Validate() != nil {
+			return
+		}
+		encoded, err := json.Marshal(&span)
+		if err != nil {
+			return // e.g. a start time past year 9999
+		}
+		var decoded Span
+		if err := json.Unmarshal(encoded, &decoded); err != nil {
+			t.Fatalf("re-decoding %s: %v", encoded, err)
+		}
+		if err := decoded.Validate(); err != nil {
+			t.Fatalf("round trip of %s: %v", encoded, err)
+		}
+	})
+}
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// ThrottleHint is sent by a saturated collector in ingestion responses.
+// SDKs honor it by sampling new traces at SampleRate and, when Backoff is
+// set, holding off exports for that long.
+type ThrottleHint struct {
+	// SampleRate is the suggested fraction of new traces to keep (0-1]
+	SampleRate float64 `json:"sample_rate"`
+
+	// BackoffMs asks clients to pause exporting; 0 means no pause
+	BackoffMs int64 `json:"backoff_ms,omitempty"`
+}
+
+// Backoff returns BackoffMs as a duration.
+func (h *ThrottleHint) Backoff() time.Duration {
+	return time.Duration(h.BackoffMs) * time.Millisecond
+}
@@ -29,9 +29,16 @@ type Span struct {
 	Status        string `json:"status"` // "ok" or "error"
 	StatusMessage string `json:"status_message,omitempty"`
 
+	// InProgress marks a span that has started but not finished yet.
+	// A later span with the same SpanID carries the completed version.
+	InProgress bool `json:"in_progress,omitempty"`
+
 	// Tags are key-value pairs for additional context
 	Tags map[string]string `json:"tags,omitempty"`
 
+	// Events are timestamped milestones within the span (cache miss, retry, ...)
+	Events []SpanEvent `json:"events,omitempty"`
+
 	// 🚀 Deployment tracking - enables per-version performance analysis
 	DeploymentID string `json:"deployment_id,omitempty"` // e.g., "v2.3.1-abc123"
 	GitSHA       string `json:"git_sha,omitempty"`       // commit hash
@@ -45,6 +52,16 @@ type Span struct {
 	ProfileID  string `json:"profile_id,omitempty"`
 }
 
+// SpanEvent is a named point in time within a span, with optional attributes.
+type SpanEvent struct {
+	Name       string            `json:"name"`
+	Timestamp  time.Time         `json:"timestamp"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// MaxSpanEvents caps the events a span may carry.
+const MaxSpanEvents = 128
+
 // Trace represents a complete trace containing multiple spans.
 type Trace struct {
 	TraceID   string        `json:"trace_id"`
@@ -55,12 +72,33 @@ type Trace struct {
 	// Services involved in this trace
 	Services []string `json:"services"`
 
+	// InProgress is true while any span in the trace is still running
+	InProgress bool `json:"in_progress,omitempty"`
+
+	// ExpiresAt is when retention removes the trace (nil = no time-based retention)
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
 	// Deployment context - maps service name to deployment ID
 	Deployments map[string]string `json:"deployments,omitempty"`
 
 	// Cost attribution (populated in Week 3)
 	TotalCost     float64            `json:"total_cost,omitempty"`
 	CostBreakdown map[string]float64 `json:"cost_breakdown,omitempty"` // service → cost
+	Currency      string             `json:"currency,omitempty"`       // Unit of the costs, e.g. "USD"
+
+	// Truncation is set when storage discarded data to keep the trace
+	// within its limits, so the trace is known to be incomplete
+	Truncation *Truncation `json:"truncation,omitempty"`
+
+	// Regions are the regional collectors a gateway read the trace from
+	Regions []string `json:"regions,omitempty"`
+}
+
+// Truncation counts what storage discarded from a trace.
+type Truncation struct {
+	DroppedSpans  int `json:"dropped_spans,omitempty"`  // Spans refused once the trace reached the span limit
+	DroppedTags   int `json:"dropped_tags,omitempty"`   // Tags removed from spans over the tag count limit
+	TruncatedTags int `json:"truncated_tags,omitempty"` // Tag values cut to the size limit
 }
 
 // Common validation errors
@@ -71,10 +109,15 @@ var (
 	ErrMissingOperationName = errors.New("operation_name is required")
 	ErrInvalidTraceIDFormat = errors.New("trace_id must be 32 hex characters")
 	ErrInvalidSpanIDFormat  = errors.New("span_id must be 16 hex characters")
+	ErrZeroTraceID          = errors.New("trace_id must not be all zeros")
+	ErrZeroSpanID           = errors.New("span_id must not be all zeros")
 	ErrNegativeDuration     = errors.New("duration cannot be negative")
 	ErrMissingStartTime     = errors.New("start_time is required")
 	ErrInvalidStatus        = errors.New("status must be 'ok' or 'error'")
 	ErrInvalidSpanKind      = errors.New("span_kind must be one of: client, server, internal, producer, consumer")
+	ErrTooManyEvents        = errors.New("span has more than 128 events")
+	ErrMissingEventName     = errors.New("event name is required")
+	ErrMissingEventTime     = errors.New("event timestamp is required")
 )
 
 // Validate checks if the span has all required fields and valid values.
@@ -96,9 +139,15 @@ func (s *Span) Validate() error {
 
 	// Format validation - ensure IDs are properly formatted
 	if !IsValidTraceID(s.TraceID) {
+		if len(s.TraceID) == 32 && isZeroID(s.TraceID) {
+			return ErrZeroTraceID
+		}
 		return ErrInvalidTraceIDFormat
 	}
 	if !IsValidSpanID(s.SpanID) {
+		if len(s.SpanID) == 16 && isZeroID(s.SpanID) {
+			return ErrZeroSpanID
+		}
 		return ErrInvalidSpanIDFormat
 	}
 
@@ -129,6 +178,19 @@ func (s *Span) Validate() error {
 		}
 	}
 
+	// Events validation
+	if len(s.Events) > MaxSpanEvents {
+		return ErrTooManyEvents
+	}
+	for i := range s.Events {
+		if s.Events[i].Name == "" {
+			return ErrMissingEventName
+		}
+		if s.Events[i].Timestamp.IsZero() {
+			return ErrMissingEventTime
+		}
+	}
+
 	return nil
 }
 
@@ -157,3 +219,12 @@ func (s *Span) SetTag(key, value string) {
 	}
 	s.Tags[key] = value
 }
+
+// AddEvent appends an event to the span.
+func (s *Span) AddEvent(name string, timestamp time.Time, attributes map[string]string) {
+	s.Events = append(s.Events, SpanEvent{
+		Name:       name,
+		Timestamp:  timestamp,
+		Attributes: attributes,
+	})
+}
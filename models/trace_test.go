@@ -155,6 +155,30 @@ func TestSpanValidation_InvalidFormats(t *testing.T) {
 			},
 			expectedErr: ErrInvalidSpanIDFormat,
 		},
+		{
+			name: "trace_id all zeros",
+			span: Span{
+				TraceID:       "00000000000000000000000000000000",
+				SpanID:        GenerateSpanID(),
+				ServiceName:   "test",
+				OperationName: "test",
+				StartTime:     time.Now(),
+				Status:        "ok",
+			},
+			expectedErr: ErrZeroTraceID,
+		},
+		{
+			name: "span_id all zeros",
+			span: Span{
+				TraceID:       GenerateTraceID(),
+				SpanID:        "0000000000000000",
+				ServiceName:   "test",
+				OperationName: "test",
+				StartTime:     time.Now(),
+				Status:        "ok",
+			},
+			expectedErr: ErrZeroSpanID,
+		},
 	}
 
 	for _, tt := range tests {
@@ -212,6 +236,45 @@ func TestSpanValidation_InvalidValues(t *testing.T) {
 			},
 			expectedErr: ErrInvalidSpanKind,
 		},
+		{
+			name: "event without name",
+			span: Span{
+				TraceID:       GenerateTraceID(),
+				SpanID:        GenerateSpanID(),
+				ServiceName:   "test",
+				OperationName: "test",
+				StartTime:     time.Now(),
+				Status:        "ok",
+				Events:        []SpanEvent{{Timestamp: time.Now()}},
+			},
+			expectedErr: ErrMissingEventName,
+		},
+		{
+			name: "event without timestamp",
+			span: Span{
+				TraceID:       GenerateTraceID(),
+				SpanID:        GenerateSpanID(),
+				ServiceName:   "test",
+				OperationName: "test",
+				StartTime:     time.Now(),
+				Status:        "ok",
+				Events:        []SpanEvent{{Name: "cache.miss"}},
+			},
+			expectedErr: ErrMissingEventTime,
+		},
+		{
+			name: "too many events",
+			span: Span{
+				TraceID:       GenerateTraceID(),
+				SpanID:        GenerateSpanID(),
+				ServiceName:   "test",
+				OperationName: "test",
+				StartTime:     time.Now(),
+				Status:        "ok",
+				Events:        make([]SpanEvent, MaxSpanEvents+1),
+			},
+			expectedErr: ErrTooManyEvents,
+		},
 	}
 
 	for _, tt := range tests {
@@ -451,6 +514,7 @@ func TestIsValidTraceID(t *testing.T) {
 		{"non-hex chars", "0123456789abcdefghij456789abcdef", false},
 		{"empty string", "", false},
 		{"special chars", "0123456789abcdef-123456789abcdef", false},
+		{"all zeros", "00000000000000000000000000000000", false},
 	}
 
 	for _, tt := range tests {
@@ -477,6 +541,7 @@ func TestIsValidSpanID(t *testing.T) {
 		{"non-hex chars", "0123456789abcdez", false},
 		{"empty string", "", false},
 		{"special chars", "0123456789abcd-f", false},
+		{"all zeros", "0000000000000000", false},
 	}
 
 	for _, tt := range tests {
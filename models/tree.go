@@ -0,0 +1,235 @@
+package models
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// TraceTree is a trace's spans arranged by parent span ID.
+type TraceTree struct {
+	// Roots are the spans without a parent in the trace, by start time:
+	// the root span, and orphans whose parent never arrived
+	Roots []*SpanNode `json:"roots"`
+
+	Depth       int `json:"depth"`        // Depth of the deepest span
+	OrphanSpans int `json:"orphan_spans"` // Roots that reference a parent
+
+	// MissingParents lists the parent span IDs orphans reference, sorted
+	MissingParents []string `json:"missing_parents,omitempty"`
+}
+
+// SpanNode is a span in a TraceTree.
+type SpanNode struct {
+	Span  *Span `json:"span"`
+	Depth int   `json:"depth"` // 0 for roots
+
+	// SelfTime is the part of the span's duration no child was running
+	SelfTime time.Duration `json:"self_time"`
+
+	// CriticalPath marks the spans the trace's duration waited on: the
+	// last span to finish, the last to finish before it started, and so
+	// on, descending into each span's children the same way
+	CriticalPath bool `json:"critical_path"`
+
+	// Orphan marks a root that references a parent: one missing from the
+	// trace, or one whose own ancestry leads back to this span
+	Orphan bool `json:"orphan,omitempty"`
+
+	Children []*SpanNode `json:"children,omitempty"` // By start time
+}
+
+// BuildTraceTree arranges the spans of trace into a tree. Nodes point at
+// trace.Spans, which must not change while the tree is in use.
+func BuildTraceTree(trace *Trace) *TraceTree {
+	nodes := make([]*SpanNode, len(trace.Spans))
+	byID := make(map[string]*SpanNode, len(trace.Spans))
+	for i := range trace.Spans {
+		nodes[i] = &SpanNode{Span: &trace.Spans[i]}
+		if _, ok := byID[trace.Spans[i].SpanID]; !ok {
+			byID[trace.Spans[i].SpanID] = nodes[i]
+		}
+	}
+	slices.SortStableFunc(nodes, func(a, b *SpanNode) int { return a.Span.StartTime.Compare(b.Span.StartTime) })
+
+	tree := &TraceTree{Roots: []*SpanNode{}}
+	missing := make(map[string]bool)
+	for _, node := range nodes {
+		parentID := node.Span.ParentSpanID
+		parent, ok := byID[parentID]
+		switch {
+		case parentID == "":
+			tree.Roots = append(tree.Roots, node)
+		case !ok || parent == node:
+			node.Orphan = true
+			if !ok {
+				missing[parentID] = true
+			}
+			tree.Roots = append(tree.Roots, node)
+		default:
+			parent.Children = append(parent.Children, node)
+		}
+	}
+
+	// Spans whose ancestry loops are unreachable from the roots; cutting
+	// the loop at its earliest span makes that span a root
+	reached := make(map[*SpanNode]bool, len(nodes))
+	for _, root := range tree.Roots {
+		tree.visit(root, 0, reached)
+	}
+	for _, node := range nodes {
+		if reached[node] {
+			continue
+		}
+		parent := byID[node.Span.ParentSpanID]
+		parent.Children = slices.DeleteFunc(parent.Children, func(child *SpanNode) bool { return child == node })
+		node.Orphan = true
+		tree.Roots = append(tree.Roots, node)
+		tree.visit(node, 0, reached)
+	}
+	slices.SortStableFunc(tree.Roots, func(a, b *SpanNode) int { return a.Span.StartTime.Compare(b.Span.StartTime) })
+
+	for _, root := range tree.Roots {
+		if root.Orphan {
+			tree.OrphanSpans++
+		}
+	}
+	for parentID := range missing {
+		tree.MissingParents = append(tree.MissingParents, parentID)
+	}
+	slices.Sort(tree.MissingParents)
+
+	start, end := treeBounds(tree.Roots)
+	walkCriticalPath(tree.Roots, start, end, func(node *SpanNode, _ time.Duration) { node.CriticalPath = true })
+	return tree
+}
+
+// CriticalPath is the chain of spans a trace's duration waited on, as
+// flagged by SpanNode.CriticalPath.
+type CriticalPath struct {
+	Duration time.Duration `json:"duration"` // From the first span's start to the last span's end
+
+	// Spans are the spans on the path by start time, each with the time it
+	// contributed; contributions and Untraced add up to Duration
+	Spans []CriticalPathSpan `json:"spans"`
+
+	// Untraced is time on the path no span covers, e.g. between a root and
+	// an orphan whose parent is missing
+	Untraced time.Duration `json:"untraced"`
+}
+
+// CriticalPathSpan is a span's share of the critical path.
+type CriticalPathSpan struct {
+	Span *Span `json:"span"`
+
+	// Contribution is the time the path spent in the span itself rather than
+	// in a child on the path
+	Contribution time.Duration `json:"contribution"`
+	Share        float64       `json:"share"` // Contribution / CriticalPath.Duration
+}
+
+// CriticalPath returns the spans on the tree's critical path and their
+// contributions to the trace's duration.
+func (t *TraceTree) CriticalPath() *CriticalPath {
+	path := &CriticalPath{Spans: []CriticalPathSpan{}}
+	start, end := treeBounds(t.Roots)
+	path.Duration = end.Sub(start)
+	covered := walkCriticalPath(t.Roots, start, end, func(node *SpanNode, contribution time.Duration) {
+		path.Spans = append(path.Spans, CriticalPathSpan{Span: node.Span, Contribution: contribution})
+	})
+	path.Untraced = path.Duration - covered
+
+	for i := range path.Spans {
+		if path.Duration > 0 {
+			path.Spans[i].Share = float64(path.Spans[i].Contribution) / float64(path.Duration)
+		}
+	}
+	slices.SortStableFunc(path.Spans, func(a, b CriticalPathSpan) int { return a.Span.StartTime.Compare(b.Span.StartTime) })
+	return path
+}
+
+// visit sets the depth and self time of node and its descendants.
+func (t *TraceTree) visit(node *SpanNode, depth int, reached map[*SpanNode]bool) {
+	reached[node] = true
+	node.Depth = depth
+	t.Depth = max(t.Depth, depth)
+	node.SelfTime = node.Span.Duration - childTime(node)
+	for _, child := range node.Children {
+		t.visit(child, depth+1, reached)
+	}
+}
+
+// childTime is how long at least one child of node ran within it.
+func childTime(node *SpanNode) time.Duration {
+	start, end := node.Span.StartTime, node.Span.EndTime()
+	var total time.Duration
+	cursor := start
+	for _, child := range node.Children { // By start time
+		from := maxTime(child.Span.StartTime, cursor)
+		to := minTime(child.Span.EndTime(), end)
+		if to.After(from) {
+			total += to.Sub(from)
+			cursor = to
+		}
+	}
+	return total
+}
+
+// treeBounds returns the earliest start and latest end of the roots, which
+// bound every span on the critical path.
+func treeBounds(roots []*SpanNode) (start, end time.Time) {
+	for i, root := range roots {
+		if i == 0 || root.Span.StartTime.Before(start) {
+			start = root.Span.StartTime
+		}
+		if i == 0 || root.Span.EndTime().After(end) {
+			end = root.Span.EndTime()
+		}
+	}
+	return start, end
+}
+
+// walkCriticalPath finds the spans among nodes and their descendants on
+// the critical path between from and to, working back from to: the node
+// that finished last, then the last to finish before that one started, and
+// so on. Each span is clipped to the time left and visit receives it with
+// the time it contributes, i.e. during which none of its own children on
+// the path ran. It returns the time covered by the nodes on the path.
+func walkCriticalPath(nodes []*SpanNode, from, to time.Time, visit func(*SpanNode, time.Duration)) time.Duration {
+	byEnd := slices.Clone(nodes)
+	slices.SortStableFunc(byEnd, func(a, b *SpanNode) int {
+		return cmp.Or(b.Span.EndTime().Compare(a.Span.EndTime()), a.Span.StartTime.Compare(b.Span.StartTime))
+	})
+
+	var covered time.Duration
+	cursor := to
+	for _, node := range byEnd {
+		if !cursor.After(from) {
+			break
+		}
+		start := maxTime(node.Span.StartTime, from)
+		end := minTime(node.Span.EndTime(), cursor)
+		if !end.After(start) {
+			continue
+		}
+		length := end.Sub(start)
+		visit(node, length-walkCriticalPath(node.Children, start, end, visit))
+		covered += length
+		cursor = start
+	}
+	return covered
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
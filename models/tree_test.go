@@ -0,0 +1,155 @@
+package models
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// treeSpan returns a span of trace starting start after base and lasting
+// duration.
+func treeSpan(id, parentID string, start, duration time.Duration) Span {
+	base := time.Date(2024, 1, 15, 10, 30, 0, 0, time.UTC)
+	return Span{
+		TraceID:       "a1b2c3d4e5f6789012345678901234ab",
+		SpanID:        id,
+		ParentSpanID:  parentID,
+		ServiceName:   "api",
+		OperationName: id,
+		StartTime:     base.Add(start),
+		Duration:      duration,
+		Status:        "ok",
+	}
+}
+
+// findNode returns the node of span id in tree.
+func findNode(tree *TraceTree, id string) *SpanNode {
+	var found *SpanNode
+	var search func([]*SpanNode)
+	search = func(nodes []*SpanNode) {
+		for _, node := range nodes {
+			if node.Span.SpanID == id {
+				found = node
+			}
+			search(node.Children)
+		}
+	}
+	search(tree.Roots)
+	return found
+}
+
+func TestBuildTraceTree(t *testing.T) {
+	ms := time.Millisecond
+	// root 0-100ms calls b 5-60ms and a 10-40ms in parallel, then c
+	// 70-90ms; a calls d 15-35ms
+	trace := &Trace{Spans: []Span{
+		treeSpan("c", "root", 70*ms, 20*ms),
+		treeSpan("d", "a", 15*ms, 20*ms),
+		treeSpan("root", "", 0, 100*ms),
+		treeSpan("b", "root", 5*ms, 55*ms),
+		treeSpan("a", "root", 10*ms, 30*ms),
+	}}
+	tree := BuildTraceTree(trace)
+
+	if len(tree.Roots) != 1 || tree.Roots[0].Span.SpanID != "root" {
+		t.Fatalf("roots = %+v", tree.Roots)
+	}
+	var children []string
+	for _, child := range tree.Roots[0].Children {
+		children = append(children, child.Span.SpanID)
+	}
+	if !slices.Equal(children, []string{"b", "a", "c"}) {
+		t.Errorf("children = %v, want by start time", children)
+	}
+	if tree.Depth != 2 || findNode(tree, "d").Depth != 2 {
+		t.Errorf("depth = %d, d at %d, want 2", tree.Depth, findNode(tree, "d").Depth)
+	}
+	if tree.OrphanSpans != 0 || tree.MissingParents != nil {
+		t.Errorf("orphans = %d %v, want none", tree.OrphanSpans, tree.MissingParents)
+	}
+
+	// Children ran 5-60ms and 70-90ms of the root's 100ms
+	selfTimes := map[string]time.Duration{"root": 25 * ms, "a": 10 * ms, "b": 55 * ms, "c": 20 * ms, "d": 20 * ms}
+	for id, want := range selfTimes {
+		if got := findNode(tree, id).SelfTime; got != want {
+			t.Errorf("self time of %s = %v, want %v", id, got, want)
+		}
+	}
+
+	// The root waited on c, then on b, which ran all the while a did
+	for id, want := range map[string]bool{"root": true, "c": true, "b": true, "a": false, "d": false} {
+		if got := findNode(tree, id).CriticalPath; got != want {
+			t.Errorf("critical path of %s = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestBuildTraceTree_Orphans(t *testing.T) {
+	ms := time.Millisecond
+	trace := &Trace{Spans: []Span{
+		treeSpan("root", "", 0, 50*ms),
+		treeSpan("lost", "gone", 60*ms, 20*ms),
+		treeSpan("child", "lost", 65*ms, 10*ms),
+		// x and y name each other as parent
+		treeSpan("x", "y", 10*ms, 10*ms),
+		treeSpan("y", "x", 12*ms, 5*ms),
+	}}
+	tree := BuildTraceTree(trace)
+
+	var roots []string
+	for _, root := range tree.Roots {
+		roots = append(roots, root.Span.SpanID)
+	}
+	if !slices.Equal(roots, []string{"root", "x", "lost"}) {
+		t.Fatalf("roots = %v", roots)
+	}
+	if tree.OrphanSpans != 2 || !slices.Equal(tree.MissingParents, []string{"gone"}) {
+		t.Errorf("orphans = %d, missing parents = %v", tree.OrphanSpans, tree.MissingParents)
+	}
+	if lost := findNode(tree, "lost"); !lost.Orphan || len(lost.Children) != 1 || findNode(tree, "child").Depth != 1 {
+		t.Errorf("lost = %+v", lost)
+	}
+	if y := findNode(tree, "y"); y == nil || y.Orphan || y.Depth != 1 {
+		t.Errorf("y = %+v, want a child of x", y)
+	}
+
+	// The path crosses the gap between the root and the orphan
+	for id, want := range map[string]bool{"lost": true, "child": true, "root": true, "x": false} {
+		if got := findNode(tree, id).CriticalPath; got != want {
+			t.Errorf("critical path of %s = %v, want %v", id, got, want)
+		}
+	}
+}
+
+func TestTraceTree_CriticalPath(t *testing.T) {
+	ms := time.Millisecond
+	trace := &Trace{Spans: []Span{
+		treeSpan("root", "", 0, 100*ms),
+		treeSpan("b", "root", 5*ms, 55*ms),
+		treeSpan("a", "root", 10*ms, 30*ms),
+		treeSpan("c", "root", 70*ms, 20*ms),
+		// An orphan 10ms after the root ended
+		treeSpan("lost", "gone", 110*ms, 40*ms),
+	}}
+	path := BuildTraceTree(trace).CriticalPath()
+
+	if path.Duration != 150*ms || path.Untraced != 10*ms {
+		t.Errorf("duration %v, untraced %v, want 150ms and 10ms", path.Duration, path.Untraced)
+	}
+	want := []struct {
+		id           string
+		contribution time.Duration
+	}{{"root", 25 * ms}, {"b", 55 * ms}, {"c", 20 * ms}, {"lost", 40 * ms}}
+	if len(path.Spans) != len(want) {
+		t.Fatalf("path has %d spans, want %d", len(path.Spans), len(want))
+	}
+	for i, w := range want {
+		got := path.Spans[i]
+		if got.Span.SpanID != w.id || got.Contribution != w.contribution {
+			t.Errorf("spans[%d] = %s %v, want %s %v", i, got.Span.SpanID, got.Contribution, w.id, w.contribution)
+		}
+	}
+	if share := path.Spans[1].Share; share < 0.366 || share > 0.367 {
+		t.Errorf("share of b = %v, want 55/150", share)
+	}
+}
@@ -0,0 +1,308 @@
+package sdk
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// BatchConfig tunes the tracer's HTTP batch exporter. Zero values use defaults.
+type BatchConfig struct {
+	QueueSize     int           // Buffered spans (default 2048)
+	BatchSize     int           // Max spans per request (default 128)
+	FlushInterval time.Duration // Max time a partial batch waits (default 1s)
+	MaxRetries    int           // Retries per batch after the first attempt (default 3)
+	RetryBackoff  time.Duration // Delay before the first retry, doubled on each retry (default 100ms)
+	Protobuf      bool          // Send batches in the protobuf wire format, cheaper for the collector to decode than JSON
+
+	// Compression compresses request bodies, for collectors across a costly
+	// link: CompressionGzip or CompressionZstd (falling back to gzip for
+	// collectors without zstd). Bodies under CompressMinBytes are sent as
+	// they are, as compressing them saves little (default 0: compress every
+	// batch).
+	Compression      string
+	CompressMinBytes int
+
+	// Compress gzips request bodies; it is shorthand for Compression
+	// CompressionGzip.
+	Compress bool
+}
+
+// WithBatching tunes how finished spans are batched to the collector's
+// /api/v1/spans/batch endpoint. Ignored when a gRPC exporter is set.
+func WithBatching(config BatchConfig) TracerOption {
+	return func(t *Tracer) {
+		t.batchConfig = config
+	}
+}
+
+// batchExporter queues finished spans and posts them to the collector in
+// batches from a single background goroutine, so a busy service makes one
+// request per batch instead of one per span. Failed requests are retried with
+// exponential backoff; spans that do not fit the queue are dropped.
+type batchExporter struct {
+	client   *http.Client
+	url      string
+	logger   *slog.Logger
+	throttle *throttleState
+	config   BatchConfig
+
+	// Features the collector advertised (see negotiate.go)
+	collector collectorCapabilities
+
+	queue chan *models.Span
+
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// newBatchExporter starts a batch exporter posting to collectorUrl.
+func newBatchExporter(client *http.Client, collectorUrl string, logger *slog.Logger, throttle *throttleState, config BatchConfig) *batchExporter {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 2048
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 128
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.MaxRetries <= 0 {
+		config.MaxRetries = 3
+	}
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = 100 * time.Millisecond
+	}
+	if config.Compress && config.Compression == "" {
+		config.Compression = CompressionGzip
+	}
+	switch config.Compression {
+	case "", CompressionGzip, CompressionZstd:
+	default:
+		logger.Warn("unknown compression, sending uncompressed batches", "compression", config.Compression)
+		config.Compression = ""
+	}
+
+	e := &batchExporter{
+		client:   client,
+		url:      fmt.Sprintf("%s/api/v1/spans/batch", collectorUrl),
+		logger:   logger,
+		throttle: throttle,
+		config:   config,
+		queue:    make(chan *models.Span, config.QueueSize),
+		done:     make(chan struct{}),
+	}
+
+	e.wg.Add(1)
+	go e.run()
+
+	return e
+}
+
+// export queues a span without blocking. It returns false if the span was dropped.
+func (e *batchExporter) export(span *models.Span) bool {
+	select {
+	case <-e.done:
+		return false
+	default:
+	}
+
+	select {
+	case e.queue <- span:
+		return true
+	default:
+		e.logger.Debug("span queue full, dropping span",
+			"trace_id", span.TraceID,
+			"span_id", span.SpanID,
+		)
+		return false
+	}
+}
+
+// shutdown flushes queued spans and waits for the last batch to be sent.
+func (e *batchExporter) shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() {
+		close(e.done)
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run collects spans into batches and flushes them by size or interval.
+func (e *batchExporter) run() {
+	defer e.wg.Done()
+
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.Span, 0, e.config.BatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		e.send(batch)
+		batch = make([]*models.Span, 0, e.config.BatchSize)
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.config.BatchSize {
+				flush()
+			}
+
+		case <-ticker.C:
+			flush()
+
+		case <-e.done:
+			// Drain what is already queued
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= e.config.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts a batch, retrying retryable failures with exponential backoff.
+// Every attempt carries the same Idempotency-Key, so a retry after a request
+// that timed out but reached the collector is not ingested twice.
+func (e *batchExporter) send(batch []*models.Span) {
+	backoff := e.config.RetryBackoff
+	key := models.GenerateTraceID()
+	for attempt := 0; ; attempt++ {
+		e.waitBackoff()
+
+		retry, err := e.post(batch, key)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= e.config.MaxRetries {
+			e.logger.Error("failed to send span batch, dropping it",
+				"spans", len(batch),
+				"attempts", attempt+1,
+				"error", err,
+			)
+			return
+		}
+
+		e.logger.Warn("failed to send span batch, retrying",
+			"spans", len(batch),
+			"retry_in", backoff,
+			"error", err,
+		)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// post makes one request and reports whether a failure is worth retrying.
+// Features the collector does not advertise are left out of the request.
+func (e *batchExporter) post(batch []*models.Span, idempotencyKey string) (retry bool, err error) {
+	protobuf := e.config.Protobuf && e.collector.supports(models.FeatureProtobuf, "sending JSON", e.logger)
+	batch = e.fitEvents(batch)
+
+	contentType := "application/json"
+	var data []byte
+	if protobuf {
+		contentType = models.ProtobufContentType
+		data = models.MarshalSpansProto(batch)
+	} else if data, err = json.Marshal(models.WithSchemaVersion(batch)); err != nil {
+		return false, err
+	}
+	size := len(data)
+	encoding := e.contentEncoding(size)
+	if data, err = compressBody(encoding, data); err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(sdkVersionHeader, sdkVersion)
+	req.Header.Set("Idempotency-Key", idempotencyKey)
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	// Follow the collector's flow-control hint (nil clears throttling)
+	e.throttle.apply(throttleHintFromHeaders(resp.Header))
+	e.collector.apply(resp.Header)
+
+	switch {
+	case (resp.StatusCode == http.StatusBadRequest || resp.StatusCode == http.StatusUnsupportedMediaType) &&
+		e.downgraded(protobuf, encoding, size):
+		// The request used a feature the collector just said it lacks
+		return true, fmt.Errorf("collector returned status %d", resp.StatusCode)
+	case resp.StatusCode == http.StatusPartialContent:
+		// Some spans were refused; resending would duplicate the rest
+		e.logger.Warn("collector rejected part of a span batch", "spans", len(batch))
+		return false, nil
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		resp.StatusCode == http.StatusConflict: // An earlier attempt is still being handled
+		return true, fmt.Errorf("collector returned status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+}
+
+// downgraded reports whether a request sent with the given features would
+// now be sent without one of them.
+func (e *batchExporter) downgraded(protobuf bool, encoding string, size int) bool {
+	return (protobuf && !e.collector.supports(models.FeatureProtobuf, "sending JSON", e.logger)) ||
+		(encoding != "" && e.contentEncoding(size) != encoding)
+}
+
+// waitBackoff pauses while the collector has asked for a backoff. Shutdown
+// cuts the wait short so queued spans are still flushed.
+func (e *batchExporter) waitBackoff() {
+	remaining := e.throttle.backoffRemaining()
+	if remaining <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-e.done:
+	}
+}
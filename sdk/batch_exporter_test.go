@@ -0,0 +1,196 @@
+package sdk
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// batchCollector records the size of every batch it accepts after answering
+// the given number of requests with 503.
+func batchCollector(t *testing.T, failures int) (*httptest.Server, func() []int) {
+	var mu sync.Mutex
+	var batches []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var spans []models.Span
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		batches = append(batches, len(spans))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int(nil), batches...)
+	}
+}
+
+func TestBatchExporter_FlushesBySizeAndOnShutdown(t *testing.T) {
+	server, batches := batchCollector(t, 0)
+	tracer := NewTracer("test-service", server.URL, WithBatching(BatchConfig{
+		BatchSize:     4,
+		FlushInterval: time.Hour,
+	}))
+
+	for i := 0; i < 10; i++ {
+		span, _ := tracer.StartSpan(context.Background(), "op")
+		span.Finish()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	got := batches()
+	if len(got) != 3 || got[0] != 4 || got[1] != 4 || got[2] != 2 {
+		t.Errorf("batches = %v, want [4 4 2]", got)
+	}
+
+	// Spans finished after shutdown are dropped
+	if tracer.batcher.export(&models.Span{}) {
+		t.Error("export after shutdown should fail")
+	}
+}
+
+func TestBatchExporter_RetriesFailedBatches(t *testing.T) {
+	server, batches := batchCollector(t, 2)
+	tracer := NewTracer("test-service", server.URL, WithBatching(BatchConfig{
+		FlushInterval: 5 * time.Millisecond,
+		RetryBackoff:  time.Millisecond,
+	}))
+	defer tracer.Shutdown(context.Background())
+
+	span, _ := tracer.StartSpan(context.Background(), "op")
+	span.Finish()
+
+	deadline := time.Now().Add(time.Second)
+	for len(batches()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := batches(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("batches = %v, want one batch of 1 after retries", got)
+	}
+}
+
+func TestBatchExporter_RetriesReuseIdempotencyKey(t *testing.T) {
+	var mu sync.Mutex
+	var keys []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		keys = append(keys, r.Header.Get("Idempotency-Key"))
+		if len(keys) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL, WithBatching(BatchConfig{
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+	}))
+	for i := 0; i < 2; i++ {
+		// Each span is sent as its own batch
+		span, _ := tracer.StartSpan(context.Background(), "op")
+		span.Finish()
+		tracer.batcher.send([]*models.Span{span.span})
+	}
+	tracer.Shutdown(context.Background())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(keys) < 4 || keys[0] == "" || keys[0] != keys[1] || keys[1] != keys[2] || keys[3] == keys[0] {
+		t.Errorf("keys = %q, want one key for all attempts at a batch and a new key per batch", keys)
+	}
+}
+
+func TestBatchExporter_CompressesBatches(t *testing.T) {
+	received := make(chan int, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") != "gzip" {
+			t.Errorf("Content-Encoding = %q, want gzip", r.Header.Get("Content-Encoding"))
+		}
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			t.Fatalf("body is not gzip: %v", err)
+		}
+		var spans []models.Span
+		if err := json.NewDecoder(gz).Decode(&spans); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		received <- len(spans)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL, WithBatching(BatchConfig{
+		FlushInterval: time.Hour,
+		Compress:      true,
+	}))
+	for i := 0; i < 3; i++ {
+		span, _ := tracer.StartSpan(context.Background(), "op")
+		span.Finish()
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if got := <-received; got != 3 {
+		t.Errorf("batch size = %d, want 3", got)
+	}
+}
+
+func TestBatchExporter_SendsProtobuf(t *testing.T) {
+	received := make(chan []models.Span, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != models.ProtobufContentType {
+			t.Errorf("Content-Type = %q, want %s", ct, models.ProtobufContentType)
+		}
+		body, _ := io.ReadAll(r.Body)
+		spans, err := models.UnmarshalSpansProto(body)
+		if err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		received <- spans
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL, WithBatching(BatchConfig{
+		FlushInterval: time.Hour,
+		Protobuf:      true,
+	}))
+	span, _ := tracer.StartSpan(context.Background(), "op")
+	span.SetTag("http.method", "GET")
+	span.Finish()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	spans := <-received
+	if len(spans) != 1 || spans[0].ServiceName != "test-service" || spans[0].GetTag("http.method") != "GET" {
+		t.Errorf("received %+v", spans)
+	}
+}
@@ -0,0 +1,241 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Carrier holds trace context as string key-value pairs, for transports
+// other than HTTP and gRPC: message queue headers and attributes, job
+// payloads, environment variables.
+type Carrier interface {
+	Get(key string) string
+	Set(key, value string)
+}
+
+// MultiValueCarrier is a Carrier whose keys may repeat, like HTTP headers
+// and gRPC metadata. Extract reads every value, so it can combine
+// tracestate split across lines and reject conflicting traceparents.
+type MultiValueCarrier interface {
+	Carrier
+	Values(key string) []string
+}
+
+// Inject writes the trace context of ctx into carrier: the traceparent and
+// tracestate of its span, or of the remote parent it was extracted with. It
+// writes nothing when ctx carries neither.
+//
+//	span, ctx := tracer.StartSpan(ctx, "publish orders", sdk.WithSpanKind("producer"))
+//	defer span.Finish()
+//	msg := amqp.Publishing{Headers: amqp.Table{}, Body: body}
+//	sdk.Inject(ctx, sdk.AMQPCarrier(&msg.Headers))
+func Inject(ctx context.Context, carrier Carrier) {
+	if span := SpanFromContext(ctx); span != nil && span.span != nil {
+		InjectTraceContext(span, carrier.Set)
+		return
+	}
+	if tc := traceContextFromContext(ctx); tc != nil {
+		carrier.Set(TraceParentHeader, EncodeTraceParent(tc.TraceID, tc.SpanID, tc.Flags))
+		if tc.TraceState.Len() > 0 {
+			carrier.Set(TraceStateHeader, tc.TraceState.String())
+		}
+	}
+}
+
+// Extract returns ctx with the trace context found in carrier, so spans
+// started from it continue the sender's trace. Without a valid traceparent
+// ctx is returned unchanged and spans start new traces.
+//
+//	ctx := sdk.Extract(context.Background(), sdk.SQSCarrier(&msg.MessageAttributes))
+//	span, ctx := tracer.StartSpan(ctx, "process order", sdk.WithSpanKind("consumer"))
+func Extract(ctx context.Context, carrier Carrier) context.Context {
+	tc, err := extractTraceContext(carrier)
+	if err != nil || tc == nil {
+		return ctx
+	}
+	return contextWithTraceContext(ctx, tc)
+}
+
+// HeaderCarrier adapts HTTP headers. Names match regardless of case, also
+// when they were added to the map without being canonicalized.
+type HeaderCarrier http.Header
+
+// Get implements Carrier.
+func (c HeaderCarrier) Get(key string) string {
+	if values := c.Values(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values implements MultiValueCarrier.
+func (c HeaderCarrier) Values(key string) []string {
+	if values := http.Header(c).Values(key); len(values) > 0 {
+		return values
+	}
+	for name, values := range c {
+		if strings.EqualFold(name, key) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Set implements Carrier.
+func (c HeaderCarrier) Set(key, value string) { http.Header(c).Set(key, value) }
+
+// MetadataCarrier adapts gRPC metadata, whose keys are lowercase.
+type MetadataCarrier metadata.MD
+
+// Get implements Carrier.
+func (c MetadataCarrier) Get(key string) string {
+	if values := c.Values(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// Values implements MultiValueCarrier.
+func (c MetadataCarrier) Values(key string) []string { return metadata.MD(c).Get(key) }
+
+// Set implements Carrier.
+func (c MetadataCarrier) Set(key, value string) { metadata.MD(c).Set(key, value) }
+
+// getterCarrier reads trace context through a lookup function.
+type getterCarrier func(key string) string
+
+func (c getterCarrier) Get(key string) string { return c(key) }
+
+func (c getterCarrier) Set(key, value string) {}
+
+// MapCarrier is a Carrier over a plain map, e.g. Kafka headers copied into
+// one. Set needs a non-nil map.
+type MapCarrier map[string]string
+
+// Get implements Carrier.
+func (c MapCarrier) Get(key string) string { return c[key] }
+
+// Set implements Carrier.
+func (c MapCarrier) Set(key, value string) { c[key] = value }
+
+// PubSubCarrier adapts the Attributes of a Google Cloud Pub/Sub message,
+// allocating them on Set if nil.
+func PubSubCarrier(attributes *map[string]string) Carrier {
+	return pubSubCarrier{attributes}
+}
+
+type pubSubCarrier struct {
+	attributes *map[string]string
+}
+
+func (c pubSubCarrier) Get(key string) string { return (*c.attributes)[key] }
+
+func (c pubSubCarrier) Set(key, value string) {
+	if *c.attributes == nil {
+		*c.attributes = make(map[string]string)
+	}
+	(*c.attributes)[key] = value
+}
+
+// AMQPCarrier adapts AMQP message headers, such as the Headers of an
+// amqp091-go Publishing or Delivery, allocating them on Set if nil. Values
+// are written as strings; byte string values are read too.
+func AMQPCarrier[T ~map[string]any](headers *T) Carrier {
+	return amqpCarrier[T]{headers}
+}
+
+type amqpCarrier[T ~map[string]any] struct {
+	headers *T
+}
+
+func (c amqpCarrier[T]) Get(key string) string {
+	switch value := (*c.headers)[key].(type) {
+	case string:
+		return value
+	case []byte:
+		return string(value)
+	}
+	return ""
+}
+
+func (c amqpCarrier[T]) Set(key, value string) {
+	if *c.headers == nil {
+		*c.headers = make(T)
+	}
+	(*c.headers)[key] = value
+}
+
+// SQSCarrier adapts the MessageAttributes of an Amazon SQS message or send
+// request, from either AWS SDK for Go: V is types.MessageAttributeValue
+// (v2) or *sqs.MessageAttributeValue (v1). Attributes are written with the
+// "String" data type, allocating the map on Set if nil. SQS allows 10
+// attributes per message and trace context takes up to 2 of them.
+//
+// It panics if V is not a message attribute value, i.e. a struct or struct
+// pointer with DataType and StringValue fields of type *string.
+func SQSCarrier[V any](attributes *map[string]V) Carrier {
+	if !isSQSAttributeValue(reflect.TypeOf((*V)(nil)).Elem()) {
+		panic("sdk: SQSCarrier needs SQS message attribute values with DataType and StringValue fields")
+	}
+	return sqsCarrier[V]{attributes}
+}
+
+type sqsCarrier[V any] struct {
+	attributes *map[string]V
+}
+
+// isSQSAttributeValue reports whether t has the fields SQSCarrier uses.
+func isSQSAttributeValue(t reflect.Type) bool {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	stringPointer := reflect.TypeOf((*string)(nil))
+	for _, name := range []string{"DataType", "StringValue"} {
+		if field, ok := t.FieldByName(name); !ok || field.Type != stringPointer {
+			return false
+		}
+	}
+	return true
+}
+
+func (c sqsCarrier[V]) Get(key string) string {
+	attribute, ok := (*c.attributes)[key]
+	if !ok {
+		return ""
+	}
+	value := reflect.ValueOf(&attribute).Elem()
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		value = value.Elem()
+	}
+	if s := value.FieldByName("StringValue"); !s.IsNil() {
+		return s.Elem().String()
+	}
+	return ""
+}
+
+func (c sqsCarrier[V]) Set(key, value string) {
+	var attribute V
+	fields := reflect.ValueOf(&attribute).Elem()
+	if fields.Kind() == reflect.Pointer {
+		fields.Set(reflect.New(fields.Type().Elem()))
+		fields = fields.Elem()
+	}
+	dataType := "String"
+	fields.FieldByName("DataType").Set(reflect.ValueOf(&dataType))
+	fields.FieldByName("StringValue").Set(reflect.ValueOf(&value))
+
+	if *c.attributes == nil {
+		*c.attributes = make(map[string]V)
+	}
+	(*c.attributes)[key] = attribute
+}
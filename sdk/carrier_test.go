@@ -0,0 +1,93 @@
+package sdk
+
+import (
+	"context"
+	"testing"
+)
+
+// Shapes of the message attribute types in the AWS SDKs for Go and of
+// amqp091-go's Table
+type (
+	sqsV2AttributeValue struct {
+		BinaryValue []byte
+		DataType    *string
+		StringValue *string
+	}
+	sqsV1AttributeValue struct {
+		DataType    *string
+		StringValue *string
+	}
+	amqpTable map[string]interface{}
+)
+
+func TestCarriers_PropagateAcrossMessages(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+	tracer := NewTracer("test-service", server.URL)
+
+	producer, ctx := tracer.StartSpan(context.Background(), "publish", WithSpanKind("producer"))
+	defer producer.Finish()
+	if err := producer.SetTraceState("traceflow", "s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	var sqsV2 map[string]sqsV2AttributeValue
+	var sqsV1 map[string]*sqsV1AttributeValue
+	var pubsub map[string]string
+	var amqp amqpTable
+	carriers := map[string]Carrier{
+		"sqs v2": SQSCarrier(&sqsV2),
+		"sqs v1": SQSCarrier(&sqsV1),
+		"pubsub": PubSubCarrier(&pubsub),
+		"amqp":   AMQPCarrier(&amqp),
+		"map":    MapCarrier{},
+	}
+	for name, carrier := range carriers {
+		Inject(ctx, carrier)
+
+		consumer, _ := tracer.StartSpan(Extract(context.Background(), carrier), "process", WithSpanKind("consumer"))
+		if consumer.span.TraceID != producer.span.TraceID || consumer.span.ParentSpanID != producer.span.SpanID {
+			t.Errorf("%s: consumer span %s/%s is not a child of the producer", name, consumer.span.TraceID, consumer.span.ParentSpanID)
+		}
+		if v, _ := consumer.TraceState().Get("traceflow"); v != "s1" {
+			t.Errorf("%s: tracestate = %q, want it carried along", name, consumer.TraceState().String())
+		}
+		consumer.Finish()
+	}
+	if dataType := sqsV2[TraceParentHeader].DataType; dataType == nil || *dataType != "String" {
+		t.Errorf("SQS attribute data type = %v, want String", dataType)
+	}
+
+	// Byte string headers, as some AMQP clients send them
+	amqp[TraceParentHeader] = []byte(amqp[TraceParentHeader].(string))
+	if tc := traceContextFromContext(Extract(context.Background(), AMQPCarrier(&amqp))); tc == nil {
+		t.Error("byte string traceparent was not extracted")
+	}
+}
+
+func TestExtract_WithoutTraceContext(t *testing.T) {
+	ctx := context.Background()
+	if got := Extract(ctx, MapCarrier{}); got != ctx {
+		t.Error("context changed without a traceparent")
+	}
+	if got := Extract(ctx, MapCarrier{TraceParentHeader: "garbage"}); got != ctx {
+		t.Error("context changed by a malformed traceparent")
+	}
+
+	// Nothing to inject either
+	carrier := MapCarrier{}
+	Inject(ctx, carrier)
+	if len(carrier) != 0 {
+		t.Errorf("injected %v without a span", carrier)
+	}
+}
+
+func TestSQSCarrier_RejectsOtherTypes(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("SQSCarrier accepted string values")
+		}
+	}()
+	var attributes map[string]string
+	SQSCarrier(&attributes)
+}
@@ -0,0 +1,61 @@
+package sdk
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Request body compression for BatchConfig.Compression
+const (
+	CompressionGzip = "gzip" // Understood by every collector
+	CompressionZstd = "zstd" // Smaller and cheaper to produce than gzip
+)
+
+// zstdEncoder compresses whole bodies; EncodeAll is safe for concurrent use.
+var zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+// contentEncoding picks the encoding for a body of size bytes: none below
+// the configured threshold, otherwise the configured compression if the
+// collector supports it. A collector without zstd gets gzip.
+func (e *batchExporter) contentEncoding(size int) string {
+	if size < e.config.CompressMinBytes {
+		return ""
+	}
+	switch e.config.Compression {
+	case CompressionZstd:
+		if e.collector.supports(models.FeatureZstd, "sending gzip", e.logger) {
+			return CompressionZstd
+		}
+		fallthrough
+	case CompressionGzip:
+		if e.collector.supports(models.FeatureGzip, "sending uncompressed batches", e.logger) {
+			return CompressionGzip
+		}
+	}
+	return ""
+}
+
+// compressBody encodes data for a Content-Encoding.
+func compressBody(encoding string, data []byte) ([]byte, error) {
+	switch encoding {
+	case "":
+		return data, nil
+	case CompressionZstd:
+		return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+	case CompressionGzip:
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		gz.Write(data)
+		if err := gz.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q", encoding)
+	}
+}
@@ -0,0 +1,107 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestBatchExporter_CompressesWithZstdAboveThreshold(t *testing.T) {
+	var mu sync.Mutex
+	var encodings []string
+	var sizes []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := io.Reader(r.Body)
+		if r.Header.Get("Content-Encoding") == CompressionZstd {
+			zr, err := zstd.NewReader(r.Body)
+			if err != nil {
+				t.Fatalf("body is not zstd: %v", err)
+			}
+			defer zr.Close()
+			body = zr
+		}
+		var spans []models.Span
+		if err := json.NewDecoder(body).Decode(&spans); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		mu.Lock()
+		encodings = append(encodings, r.Header.Get("Content-Encoding"))
+		sizes = append(sizes, len(spans))
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL, WithBatching(BatchConfig{
+		BatchSize:        20,
+		FlushInterval:    time.Hour,
+		Compression:      CompressionZstd,
+		CompressMinBytes: 2048,
+	}))
+	// A full batch above the threshold, then a single span below it
+	for i := 0; i < 21; i++ {
+		span, _ := tracer.StartSpan(context.Background(), "op")
+		span.Finish()
+	}
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(encodings) != 2 || encodings[0] != CompressionZstd || encodings[1] != "" {
+		t.Errorf("encodings = %q, want zstd for the full batch and none for the small one", encodings)
+	}
+	if len(sizes) != 2 || sizes[0] != 20 || sizes[1] != 1 {
+		t.Errorf("batch sizes = %v, want [20 1]", sizes)
+	}
+}
+
+func TestBatchExporter_ContentEncodingFollowsCollector(t *testing.T) {
+	e := &batchExporter{logger: slog.Default(), config: BatchConfig{Compression: CompressionZstd}}
+	if got := e.contentEncoding(10); got != CompressionZstd {
+		t.Errorf("before negotiation: %q, want zstd", got)
+	}
+
+	// A collector without zstd gets gzip, one without either gets neither
+	header := http.Header{}
+	header.Set(featuresHeader, models.FeatureGzip)
+	e.collector.apply(header)
+	if got := e.contentEncoding(10); got != CompressionGzip {
+		t.Errorf("gzip-only collector: %q, want gzip", got)
+	}
+	header.Set(featuresHeader, models.FeatureEvents)
+	e.collector.apply(header)
+	if got := e.contentEncoding(10); got != "" {
+		t.Errorf("collector without compression: %q, want none", got)
+	}
+	if !e.downgraded(false, CompressionZstd, 10) {
+		t.Error("a zstd request to a collector without zstd was not reported as downgraded")
+	}
+}
+
+func TestCompressBody_RoundTrips(t *testing.T) {
+	data := []byte(`[{"trace_id":"abc","span_id":"def"}]`)
+	compressed, err := compressBody(CompressionZstd, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoder, _ := zstd.NewReader(nil)
+	defer decoder.Close()
+	if got, err := decoder.DecodeAll(compressed, nil); err != nil || string(got) != string(data) {
+		t.Errorf("zstd round trip = %q, %v", got, err)
+	}
+	if _, err := compressBody("br", data); err == nil {
+		t.Error("unknown compression accepted")
+	}
+}
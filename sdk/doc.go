@@ -0,0 +1,27 @@
+// Package sdk instruments Go services for the traceflow collector. A Tracer
+// starts spans, the HTTP and gRPC middleware propagate W3C trace context
+// (Inject and Extract carry it over message queues), samplers choose what to keep, and finished spans are exported in batches
+// over HTTP or gRPC.
+//
+//	import "github.com/saintparish4/asmbly/sdk"
+//
+//	tracer := sdk.NewTracer("checkout", "http://collector:9090",
+//		sdk.WithBatching(sdk.BatchConfig{Compression: sdk.CompressionZstd, CompressMinBytes: 1024}))
+//	defer tracer.Shutdown(context.Background())
+//
+//	span, ctx := tracer.StartSpan(ctx, "POST /orders", sdk.WithSpanKind("server"))
+//	defer span.Finish()
+//
+// The exported API is stable: later versions only add to it. Spans are sent
+// in the collector's versioned wire format, so services built against an
+// older SDK keep working with newer collectors. In the other direction, the
+// HTTP exporter stops using features an older collector does not advertise.
+package sdk
+
+import "github.com/saintparish4/asmbly/models"
+
+// SpanData is a span as exported to the collector.
+type SpanData = models.Span
+
+// SpanEvent is a named point in time within a span (see Span.AddEvent).
+type SpanEvent = models.SpanEvent
@@ -0,0 +1,104 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Go runs fn in a new goroutine under a child span of the span in ctx.
+// The child span keeps the parent linkage that is usually lost when a
+// worker is spawned with a bare `go` statement. If ctx carries no span,
+// fn still runs but nothing is recorded.
+func Go(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	span, ctx := startChildSpan(ctx, name)
+	go runTraced(ctx, span, fn)
+}
+
+// Group is a traced equivalent of errgroup.Group. Every function started
+// with Group.Go runs under its own child span of the span that was active
+// when the group was created, and the first error cancels the group context.
+// A zero Group is valid; its functions run under context.Background, so
+// they have no parent span.
+type Group struct {
+	initOnce sync.Once
+	ctx      context.Context
+	cancel   context.CancelCauseFunc
+
+	wg      sync.WaitGroup
+	errOnce sync.Once
+	err     error
+}
+
+// WithGroup returns a new Group and a derived context that is canceled
+// when any function in the group returns an error or when Wait returns.
+func WithGroup(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{ctx: ctx, cancel: cancel}, ctx
+}
+
+// init gives a zero Group its context.
+func (g *Group) init() {
+	g.initOnce.Do(func() {
+		if g.ctx == nil {
+			g.ctx, g.cancel = context.WithCancelCause(context.Background())
+		}
+	})
+}
+
+// Go starts fn in a new goroutine under a child span named name.
+func (g *Group) Go(name string, fn func(ctx context.Context) error) {
+	g.init()
+	span, ctx := startChildSpan(g.ctx, name)
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		if err := runTraced(ctx, span, fn); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel(err)
+			})
+		}
+	}()
+}
+
+// Wait blocks until all functions started with Go have returned and
+// returns the first non-nil error, if any.
+func (g *Group) Wait() error {
+	g.init()
+	g.wg.Wait()
+	g.cancel(nil)
+	return g.err
+}
+
+// startChildSpan starts a span for a background goroutine using the tracer
+// of the span in ctx. A no-op span is returned when ctx carries no span.
+func startChildSpan(ctx context.Context, name string) (*Span, context.Context) {
+	parent := SpanFromContext(ctx)
+	if parent == nil || parent.span == nil || parent.tracer == nil {
+		return &Span{}, ctx
+	}
+
+	span, ctx := parent.tracer.StartSpan(ctx, name)
+	span.SetTag("goroutine", "true")
+	return span, ctx
+}
+
+// runTraced runs fn and records its error or panic on span before finishing it.
+func runTraced(ctx context.Context, span *Span, fn func(ctx context.Context) error) (err error) {
+	defer span.Finish()
+
+	// Handle panics
+	defer func() {
+		if r := recover(); r != nil {
+			span.SetError(fmt.Errorf("panic: %v", r))
+			panic(r) // Re-throw panic
+		}
+	}()
+
+	err = fn(ctx)
+	span.SetError(err)
+	return err
+}
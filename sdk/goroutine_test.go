@@ -0,0 +1,120 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestGo_CreatesChildSpan(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL)
+	parent, ctx := tracer.StartSpan(context.Background(), "parent-operation")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	var child *Span
+	Go(ctx, "background-work", func(ctx context.Context) error {
+		defer wg.Done()
+		child = SpanFromContext(ctx)
+		return nil
+	})
+	wg.Wait()
+
+	if child == nil || child.span == nil {
+		t.Fatal("child span not in goroutine context")
+	}
+	if child.span.TraceID != parent.span.TraceID {
+		t.Errorf("child TraceID = %s, want %s", child.span.TraceID, parent.span.TraceID)
+	}
+	if child.span.ParentSpanID != parent.span.SpanID {
+		t.Errorf("child ParentSpanID = %s, want %s", child.span.ParentSpanID, parent.span.SpanID)
+	}
+	if child.span.OperationName != "background-work" {
+		t.Errorf("OperationName = %s, want background-work", child.span.OperationName)
+	}
+}
+
+func TestGo_NoParentSpan(t *testing.T) {
+	done := make(chan *Span, 1)
+	Go(context.Background(), "orphan-work", func(ctx context.Context) error {
+		done <- SpanFromContext(ctx)
+		return nil
+	})
+
+	if span := <-done; span != nil {
+		t.Error("expected no span in context when parent is missing")
+	}
+}
+
+func TestGroup_PropagatesParentAndFirstError(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL)
+	parent, ctx := tracer.StartSpan(context.Background(), "parent-operation")
+
+	g, gctx := WithGroup(ctx)
+	testErr := errors.New("worker failed")
+
+	var mu sync.Mutex
+	var children []*Span
+
+	for i := 0; i < 3; i++ {
+		g.Go("worker", func(ctx context.Context) error {
+			mu.Lock()
+			children = append(children, SpanFromContext(ctx))
+			mu.Unlock()
+			return nil
+		})
+	}
+	g.Go("failing-worker", func(ctx context.Context) error {
+		mu.Lock()
+		children = append(children, SpanFromContext(ctx))
+		mu.Unlock()
+		return testErr
+	})
+
+	if err := g.Wait(); !errors.Is(err, testErr) {
+		t.Errorf("Wait() = %v, want %v", err, testErr)
+	}
+	if gctx.Err() == nil {
+		t.Error("group context should be canceled after an error")
+	}
+
+	if len(children) != 4 {
+		t.Fatalf("got %d child spans, want 4", len(children))
+	}
+	for _, child := range children {
+		if child.span.ParentSpanID != parent.span.SpanID {
+			t.Errorf("child ParentSpanID = %s, want %s", child.span.ParentSpanID, parent.span.SpanID)
+		}
+		if child.span.OperationName == "failing-worker" && child.span.Status != "error" {
+			t.Errorf("failing worker Status = %s, want error", child.span.Status)
+		}
+	}
+}
+
+func TestGroup_ZeroValue(t *testing.T) {
+	var g Group
+	if err := g.Wait(); err != nil {
+		t.Errorf("Wait() on an empty group = %v, want nil", err)
+	}
+
+	var g2 Group
+	testErr := errors.New("worker failed")
+	g2.Go("worker", func(ctx context.Context) error {
+		if ctx == nil {
+			t.Error("worker got a nil context")
+		}
+		return nil
+	})
+	g2.Go("failing-worker", func(ctx context.Context) error { return testErr })
+	if err := g2.Wait(); !errors.Is(err, testErr) {
+		t.Errorf("Wait() = %v, want %v", err, testErr)
+	}
+}
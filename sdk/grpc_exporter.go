@@ -0,0 +1,344 @@
+package sdk
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/saintparish4/asmbly/internal/spanexport"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// GRPCExporter ships spans to the collector over a single long-lived
+// streaming RPC instead of one HTTP request per span. Spans are batched, and
+// at most MaxInFlight batches may be unacknowledged at once: when the
+// collector falls behind, acks slow down, the local queue fills and new spans
+// are dropped instead of piling up in memory. Throttle hints on acks pause
+// sending and lower the tracer's sample rate for new traces.
+//
+// Delivery is at-most-once; batches in flight when a stream breaks are lost.
+type GRPCExporter struct {
+	conn   *grpc.ClientConn
+	logger *slog.Logger
+
+	batchSize     int
+	flushInterval time.Duration
+	maxInFlight   int
+
+	queue chan *models.Span
+
+	// Latest collector flow-control hint, also read by the tracer
+	throttle throttleState
+
+	seq      uint64
+	sent     int64
+	acked    int64
+	rejected int64
+	dropped  int64
+
+	done     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// GRPCExporterStats are cumulative exporter counters.
+type GRPCExporterStats struct {
+	Sent     int64 // Spans written to a stream
+	Acked    int64 // Spans the collector accepted
+	Rejected int64 // Spans the collector refused
+	Dropped  int64 // Spans discarded locally because the queue was full or the exporter was stopped
+}
+
+// GRPCExporterConfig tunes batching and flow control. Zero values use defaults.
+type GRPCExporterConfig struct {
+	QueueSize     int           // Buffered spans (default 2048)
+	BatchSize     int           // Max spans per message (default 128)
+	FlushInterval time.Duration // Max time a partial batch waits (default 1s)
+	MaxInFlight   int           // Unacknowledged batches per stream (default 4)
+	Logger        *slog.Logger
+	TLS           *tls.Config       // Connects over TLS (mutual TLS with client certificates)
+	DialOptions   []grpc.DialOption // Defaults to TLS when set, otherwise an insecure connection
+}
+
+// NewGRPCExporter connects lazily to the collector's gRPC receiver at addr
+// and starts the background export loop.
+func NewGRPCExporter(addr string, config GRPCExporterConfig) (*GRPCExporter, error) {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 2048
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 128
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = 4
+	}
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+	if len(config.DialOptions) == 0 {
+		creds := insecure.NewCredentials()
+		if config.TLS != nil {
+			creds = credentials.NewTLS(config.TLS)
+		}
+		config.DialOptions = []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	}
+
+	conn, err := grpc.NewClient(addr, config.DialOptions...)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &GRPCExporter{
+		conn:          conn,
+		logger:        config.Logger,
+		batchSize:     config.BatchSize,
+		flushInterval: config.FlushInterval,
+		maxInFlight:   config.MaxInFlight,
+		queue:         make(chan *models.Span, config.QueueSize),
+		done:          make(chan struct{}),
+	}
+
+	e.wg.Add(1)
+	go e.run()
+
+	return e, nil
+}
+
+// Export queues a span without blocking. It returns false if the span was dropped.
+func (e *GRPCExporter) Export(span *SpanData) bool {
+	select {
+	case <-e.done:
+	default:
+		select {
+		case e.queue <- span:
+			return true
+		default:
+		}
+	}
+	atomic.AddInt64(&e.dropped, 1)
+	return false
+}
+
+// Stats returns a snapshot of the exporter counters.
+func (e *GRPCExporter) Stats() GRPCExporterStats {
+	return GRPCExporterStats{
+		Sent:     atomic.LoadInt64(&e.sent),
+		Acked:    atomic.LoadInt64(&e.acked),
+		Rejected: atomic.LoadInt64(&e.rejected),
+		Dropped:  atomic.LoadInt64(&e.dropped),
+	}
+}
+
+// Shutdown flushes queued spans, waits for their acks and closes the connection.
+func (e *GRPCExporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() {
+		close(e.done)
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+
+	var err error
+	select {
+	case <-finished:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if closeErr := e.conn.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// run keeps a stream open until shutdown, reopening it after failures.
+func (e *GRPCExporter) run() {
+	defer e.wg.Done()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	backoff := 100 * time.Millisecond
+	for {
+		err := e.stream(ctx)
+		if err == nil {
+			return // Clean shutdown
+		}
+		e.logger.Warn("span export stream failed", "error", err, "retry_in", backoff)
+
+		select {
+		case <-e.done:
+			e.dropQueued()
+			return
+		case <-time.After(backoff):
+		}
+		if backoff < 10*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// stream sends batches on one stream. It returns nil after a clean shutdown
+// and an error if the stream broke.
+func (e *GRPCExporter) stream(ctx context.Context) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := spanexport.OpenStream(streamCtx, e.conn)
+	if err != nil {
+		return err
+	}
+
+	// Each outstanding batch holds a slot until its ack arrives
+	inFlight := make(chan struct{}, e.maxInFlight)
+	recvErr := make(chan error, 1)
+	go func() {
+		recvErr <- e.receiveAcks(stream, inFlight)
+	}()
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.Span, 0, e.batchSize)
+	send := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		e.waitBackoff()
+		select {
+		case inFlight <- struct{}{}:
+		case err := <-recvErr:
+			return streamError(err)
+		}
+		msg := &spanexport.Batch{Seq: atomic.AddUint64(&e.seq, 1), Spans: batch}
+		if err := stream.Send(msg); err != nil {
+			return err
+		}
+		atomic.AddInt64(&e.sent, int64(len(batch)))
+		batch = make([]*models.Span, 0, e.batchSize)
+		return nil
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				if err := send(); err != nil {
+					return err
+				}
+			}
+
+		case <-ticker.C:
+			if err := send(); err != nil {
+				return err
+			}
+
+		case err := <-recvErr:
+			return streamError(err)
+
+		case <-e.done:
+			// Drain what is already queued, then wait for the remaining acks
+		drain:
+			for {
+				select {
+				case span := <-e.queue:
+					batch = append(batch, span)
+					if len(batch) >= e.batchSize {
+						if err := send(); err != nil {
+							return err
+						}
+					}
+				default:
+					break drain
+				}
+			}
+			if err := send(); err != nil {
+				return err
+			}
+			if err := stream.CloseSend(); err != nil {
+				return err
+			}
+			if err := <-recvErr; err != nil {
+				return err
+			}
+			return nil
+		}
+	}
+}
+
+// receiveAcks releases in-flight slots as acks arrive. It returns nil when the
+// server closes the stream.
+func (e *GRPCExporter) receiveAcks(stream spanexport.ClientStream, inFlight chan struct{}) error {
+	for {
+		ack, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		e.throttle.apply(ack.Throttle)
+		atomic.AddInt64(&e.acked, int64(ack.Accepted))
+		if ack.Rejected > 0 {
+			atomic.AddInt64(&e.rejected, int64(ack.Rejected))
+			e.logger.Warn("collector rejected spans", "seq", ack.Seq, "rejected", ack.Rejected)
+		}
+
+		select {
+		case <-inFlight:
+		default:
+		}
+	}
+}
+
+// waitBackoff pauses while the collector has asked for a backoff. Shutdown
+// cuts the wait short so queued spans are still flushed.
+func (e *GRPCExporter) waitBackoff() {
+	remaining := e.throttle.backoffRemaining()
+	if remaining <= 0 {
+		return
+	}
+
+	timer := time.NewTimer(remaining)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-e.done:
+	}
+}
+
+// dropQueued discards spans that can no longer be delivered.
+func (e *GRPCExporter) dropQueued() {
+	for {
+		select {
+		case <-e.queue:
+			atomic.AddInt64(&e.dropped, 1)
+		default:
+			return
+		}
+	}
+}
+
+// streamError converts a premature clean close into an error so the stream is reopened.
+func streamError(err error) error {
+	if err == nil {
+		return errors.New("stream closed by collector")
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github.com/saintparish4/asmbly/internal/spanexport"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// ackServer records received spans and acks every batch.
+type ackServer struct {
+	mu    sync.Mutex
+	spans []*models.Span
+}
+
+func (s *ackServer) Export(stream spanexport.ServerStream) error {
+	for {
+		batch, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.spans = append(s.spans, batch.Spans...)
+		s.mu.Unlock()
+		if err := stream.Send(&spanexport.Ack{Seq: batch.Seq, Accepted: len(batch.Spans)}); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *ackServer) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.spans)
+}
+
+func startAckServer(t *testing.T) (*ackServer, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &ackServer{}
+	server := grpc.NewServer()
+	spanexport.RegisterServer(server, srv)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return srv, listener.Addr().String()
+}
+
+func TestGRPCExporter_StreamsSpansFromTracer(t *testing.T) {
+	srv, addr := startAckServer(t)
+
+	exporter, err := NewGRPCExporter(addr, GRPCExporterConfig{BatchSize: 10, FlushInterval: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewGRPCExporter() error = %v", err)
+	}
+	tracer := NewTracer("test-service", "http://unused", WithGRPCExporter(exporter))
+
+	for i := 0; i < 25; i++ {
+		span, _ := tracer.StartSpan(context.Background(), "op")
+		span.Finish()
+	}
+
+	// Finish sends asynchronously; wait until every span is queued
+	deadline := time.Now().Add(2 * time.Second)
+	for srv.count() < 25 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if got := srv.count(); got != 25 {
+		t.Errorf("server received %d spans, want 25", got)
+	}
+	stats := exporter.Stats()
+	if stats.Sent != 25 || stats.Acked != 25 || stats.Dropped != 0 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestGRPCExporter_ShutdownFlushesQueue(t *testing.T) {
+	srv, addr := startAckServer(t)
+
+	// Long flush interval: only Shutdown can push the partial batch out
+	exporter, err := NewGRPCExporter(addr, GRPCExporterConfig{BatchSize: 100, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewGRPCExporter() error = %v", err)
+	}
+	for i := 0; i < 5; i++ {
+		exporter.Export(&models.Span{SpanID: models.GenerateSpanID()})
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := exporter.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if got := srv.count(); got != 5 {
+		t.Errorf("server received %d spans, want 5", got)
+	}
+
+	if exporter.Export(&models.Span{}) {
+		t.Error("Export after Shutdown should drop the span")
+	}
+}
@@ -0,0 +1,163 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor traces incoming unary RPCs, continuing the trace
+// from the caller's traceparent metadata.
+func UnaryServerInterceptor(tracer *Tracer) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		span, ctx := startServerSpan(ctx, tracer, info.FullMethod)
+		defer span.Finish()
+
+		resp, err := handler(ctx, req)
+		finishRPC(span, err, serverErrorCodes)
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor traces incoming streaming RPCs; the span covers
+// the whole stream.
+func StreamServerInterceptor(tracer *Tracer) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		span, ctx := startServerSpan(ss.Context(), tracer, info.FullMethod)
+		defer span.Finish()
+
+		err := handler(srv, &tracedServerStream{ServerStream: ss, ctx: ctx})
+		finishRPC(span, err, serverErrorCodes)
+		return err
+	}
+}
+
+// UnaryClientInterceptor traces outgoing unary RPCs and propagates the trace
+// context in the request metadata.
+func UnaryClientInterceptor(tracer *Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		span, ctx := startClientSpan(ctx, tracer, method)
+		defer span.Finish()
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		finishRPC(span, err, nil)
+		return err
+	}
+}
+
+// StreamClientInterceptor traces outgoing streaming RPCs. The span finishes
+// when the stream ends: once RecvMsg returns an error or io.EOF, so callers
+// should read streams until then.
+func StreamClientInterceptor(tracer *Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span, ctx := startClientSpan(ctx, tracer, method)
+
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			finishRPC(span, err, nil)
+			span.Finish()
+			return nil, err
+		}
+		return &tracedClientStream{ClientStream: stream, span: span}, nil
+	}
+}
+
+// Server spans only count codes that indicate a server fault as errors;
+// client spans treat every non-OK code as one.
+var serverErrorCodes = map[codes.Code]bool{
+	codes.Unknown:          true,
+	codes.DeadlineExceeded: true,
+	codes.Unimplemented:    true,
+	codes.Internal:         true,
+	codes.Unavailable:      true,
+	codes.DataLoss:         true,
+}
+
+func startServerSpan(ctx context.Context, tracer *Tracer, fullMethod string) (*Span, context.Context) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = Extract(ctx, MetadataCarrier(md))
+
+	span, ctx := tracer.StartSpan(ctx, strings.TrimPrefix(fullMethod, "/"), WithSpanKind("server"))
+	setRPCTags(span, fullMethod)
+	return span, ctx
+}
+
+func startClientSpan(ctx context.Context, tracer *Tracer, fullMethod string) (*Span, context.Context) {
+	span, ctx := tracer.StartSpan(ctx, strings.TrimPrefix(fullMethod, "/"), WithSpanKind("client"))
+	setRPCTags(span, fullMethod)
+
+	var pairs []string
+	InjectTraceContext(span, func(key, value string) {
+		pairs = append(pairs, key, value)
+	})
+	if len(pairs) > 0 {
+		ctx = metadata.AppendToOutgoingContext(ctx, pairs...)
+	}
+	return span, ctx
+}
+
+// setRPCTags tags a span with the service and method of "/package.Service/Method".
+func setRPCTags(span *Span, fullMethod string) {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	span.SetTag("rpc.system", "grpc")
+	span.SetTag("rpc.service", service)
+	span.SetTag("rpc.method", method)
+}
+
+// finishRPC records the call's status code, marking the span failed for
+// error codes (any non-OK code when errorCodes is nil).
+func finishRPC(span *Span, err error, errorCodes map[codes.Code]bool) {
+	code := status.Code(err)
+	span.SetTag("rpc.grpc.status_code", strconv.Itoa(int(code)))
+	if code == codes.OK {
+		return
+	}
+	if errorCodes == nil || errorCodes[code] {
+		span.SetError(err)
+	} else {
+		span.SetTag("rpc.grpc.status_message", status.Convert(err).Message())
+	}
+}
+
+// tracedServerStream hands the handler a context carrying the server span.
+type tracedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *tracedServerStream) Context() context.Context {
+	return s.ctx
+}
+
+// tracedClientStream finishes the client span when the stream ends.
+type tracedClientStream struct {
+	grpc.ClientStream
+	span *Span
+	once sync.Once
+}
+
+func (s *tracedClientStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.finish(err)
+	}
+	return err
+}
+
+func (s *tracedClientStream) finish(err error) {
+	s.once.Do(func() {
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		finishRPC(s.span, err, nil)
+		s.span.Finish()
+	})
+}
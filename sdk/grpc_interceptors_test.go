@@ -0,0 +1,121 @@
+package sdk
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCInterceptors_PropagateTraceContext(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+	tracer := NewTracer("test-service", server.URL)
+
+	const method = "/payments.v1.Payments/Charge"
+	var clientSpan, serverSpan *Span
+
+	// The client's outgoing metadata becomes the server's incoming metadata
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		clientSpan = SpanFromContext(ctx)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		_, err := UnaryServerInterceptor(tracer)(metadata.NewIncomingContext(context.Background(), md), req,
+			&grpc.UnaryServerInfo{FullMethod: method},
+			func(ctx context.Context, req interface{}) (interface{}, error) {
+				serverSpan = SpanFromContext(ctx)
+				return nil, status.Error(codes.NotFound, "no such card")
+			})
+		return err
+	}
+
+	err := UnaryClientInterceptor(tracer)(context.Background(), method, nil, nil, nil, invoker)
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("err = %v, want NotFound", err)
+	}
+	if clientSpan == nil || serverSpan == nil {
+		t.Fatal("expected client and server spans in the contexts")
+	}
+
+	if serverSpan.TraceID() != clientSpan.TraceID() || serverSpan.span.ParentSpanID != clientSpan.SpanID() {
+		t.Errorf("server span %s/%s is not a child of client span %s/%s",
+			serverSpan.TraceID(), serverSpan.span.ParentSpanID, clientSpan.TraceID(), clientSpan.SpanID())
+	}
+	if serverSpan.span.SpanKind != "server" || clientSpan.span.SpanKind != "client" {
+		t.Errorf("span kinds = %s, %s", serverSpan.span.SpanKind, clientSpan.span.SpanKind)
+	}
+	if op := serverSpan.span.OperationName; op != "payments.v1.Payments/Charge" {
+		t.Errorf("operation = %q", op)
+	}
+
+	tags := serverSpan.span.Tags
+	if tags["rpc.system"] != "grpc" || tags["rpc.service"] != "payments.v1.Payments" || tags["rpc.method"] != "Charge" {
+		t.Errorf("rpc tags = %v", tags)
+	}
+	if tags["rpc.grpc.status_code"] != "5" {
+		t.Errorf("status code tag = %q, want 5 (NotFound)", tags["rpc.grpc.status_code"])
+	}
+
+	// NotFound is the caller's problem: an error for the client only
+	if serverSpan.span.Status != "ok" || clientSpan.span.Status != "error" {
+		t.Errorf("statuses = server %s, client %s; want ok, error", serverSpan.span.Status, clientSpan.span.Status)
+	}
+}
+
+func TestUnaryServerInterceptor_ServerFaultIsError(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+	tracer := NewTracer("test-service", server.URL)
+
+	var span *Span
+	UnaryServerInterceptor(tracer)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/svc/M"},
+		func(ctx context.Context, req interface{}) (interface{}, error) {
+			span = SpanFromContext(ctx)
+			return nil, status.Error(codes.Internal, "boom")
+		})
+	if span.span.Status != "error" || span.span.ParentSpanID != "" {
+		t.Errorf("span = %+v, want a failed root span", span.span)
+	}
+}
+
+// fakeClientStream returns n messages and then ends.
+type fakeClientStream struct {
+	grpc.ClientStream
+	n int
+}
+
+func (s *fakeClientStream) RecvMsg(m interface{}) error {
+	if s.n == 0 {
+		return io.EOF
+	}
+	s.n--
+	return nil
+}
+
+func TestStreamClientInterceptor_FinishesAtEOF(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+	tracer := NewTracer("test-service", server.URL)
+
+	var span *Span
+	streamer := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		span = SpanFromContext(ctx)
+		return &fakeClientStream{n: 2}, nil
+	}
+	stream, err := StreamClientInterceptor(tracer)(context.Background(), &grpc.StreamDesc{}, nil, "/svc/Watch", streamer)
+	if err != nil {
+		t.Fatalf("interceptor failed: %v", err)
+	}
+
+	for stream.RecvMsg(nil) == nil {
+	}
+	if span.span.Duration == 0 {
+		t.Error("span not finished at end of stream")
+	}
+	if span.span.Status != "ok" || span.span.Tags["rpc.grpc.status_code"] != "0" {
+		t.Errorf("span = %+v, want ok with status code 0", span.span)
+	}
+}
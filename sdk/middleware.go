@@ -1,4 +1,4 @@
-package instrumentation
+package sdk
 
 import (
 	"context"
@@ -11,16 +11,8 @@ import (
 func Middleware(tracer *Tracer) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Extract trace context from headers
-			tc, _ := ExtractTraceContext(func(key string) string {
-				return r.Header.Get(key)
-			})
-
-			// Add trace context to request context
-			ctx := r.Context()
-			if tc != nil {
-				ctx = contextWithTraceContext(ctx, tc)
-			}
+			// Continue the caller's trace, if any
+			ctx := Extract(r.Context(), HeaderCarrier(r.Header))
 
 			// Start span for this request
 			span, ctx := tracer.StartSpan(ctx, fmt.Sprintf("%s %s", r.Method, r.URL.Path),
@@ -32,6 +24,9 @@ func Middleware(tracer *Tracer) func(http.Handler) http.Handler {
 			span.SetTag("http.method", r.Method)
 			span.SetTag("http.url", r.URL.Path)
 			span.SetTag("http.host", r.Host)
+			if ua := r.UserAgent(); ua != "" {
+				span.SetTag("http.user_agent", ua)
+			}
 			span.SetTag("http.scheme", r.URL.Scheme)
 			if r.URL.Scheme == "" {
 				if r.TLS != nil {
@@ -0,0 +1,116 @@
+package sdk
+
+import (
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Version is the SDK's version, sent to the collector with every batch.
+const Version = "1.0.0"
+
+// Headers exchanged with the collector to negotiate features
+const (
+	sdkVersionHeader    = "X-Traceflow-SDK-Version"
+	featuresHeader      = "X-Traceflow-Features"
+	maxSpanEventsHeader = "X-Traceflow-Max-Span-Events"
+)
+
+// sdkVersion identifies this SDK in the version header.
+const sdkVersion = "go/" + Version
+
+// collectorCapabilities holds the capabilities advertised on the collector's
+// latest response. Until one arrives every feature is assumed, so collectors
+// predating negotiation see the configured behavior.
+type collectorCapabilities struct {
+	mu     sync.Mutex
+	caps   *models.Capabilities // nil until advertised
+	warned map[string]bool      // Features whose downgrade was logged
+}
+
+// apply records the capabilities advertised in response headers. Responses
+// without them, e.g. from a proxy, leave the last advertised ones in place.
+func (c *collectorCapabilities) apply(header http.Header) {
+	features, ok := header[http.CanonicalHeaderKey(featuresHeader)]
+	if !ok {
+		return
+	}
+	caps := &models.Capabilities{MaxSpanEvents: -1}
+	if len(features) > 0 {
+		caps.Features = models.ParseFeatures(features[0])
+	}
+	if limit, err := strconv.Atoi(header.Get(maxSpanEventsHeader)); err == nil && limit >= 0 {
+		caps.MaxSpanEvents = limit
+	}
+
+	c.mu.Lock()
+	c.caps = caps
+	c.mu.Unlock()
+}
+
+// supports reports whether the collector accepts a feature, logging the
+// fallback the first time it does not.
+func (c *collectorCapabilities) supports(feature, fallback string, logger *slog.Logger) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.caps == nil || c.caps.Supports(feature) {
+		return true
+	}
+	if !c.warned[feature] {
+		if c.warned == nil {
+			c.warned = make(map[string]bool)
+		}
+		c.warned[feature] = true
+		logger.Warn("collector does not support "+feature+", "+fallback, "feature", feature)
+	}
+	return false
+}
+
+// maxSpanEvents returns how many events a span may carry, or -1 if the
+// collector did not say.
+func (c *collectorCapabilities) maxSpanEvents() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.caps == nil {
+		return -1
+	}
+	return c.caps.MaxSpanEvents
+}
+
+// fitEvents trims span events to what the collector accepts, dropping them
+// all if it does not support events. Spans are copied before trimming, as
+// the batch may still be retried.
+func (e *batchExporter) fitEvents(batch []*models.Span) []*models.Span {
+	limit := e.collector.maxSpanEvents()
+	if !e.collector.supports(models.FeatureEvents, "dropping span events", e.logger) {
+		limit = 0
+	}
+	if limit < 0 {
+		return batch
+	}
+
+	var fitted []*models.Span
+	for i, span := range batch {
+		if len(span.Events) <= limit {
+			continue
+		}
+		if fitted == nil {
+			fitted = append([]*models.Span(nil), batch...)
+		}
+		trimmed := *span
+		trimmed.Events = span.Events[:limit:limit]
+		if limit == 0 {
+			trimmed.Events = nil
+		}
+		fitted[i] = &trimmed
+	}
+	if fitted == nil {
+		return batch
+	}
+	return fitted
+}
@@ -0,0 +1,81 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestBatchExporter_DowngradesToCollectorCapabilities(t *testing.T) {
+	received := make(chan []models.Span, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get(sdkVersionHeader); got != sdkVersion {
+			t.Errorf("%s = %q, want %q", sdkVersionHeader, got, sdkVersion)
+		}
+		// An older collector: JSON only, at most one event per span
+		w.Header().Set(featuresHeader, models.FeatureEvents)
+		w.Header().Set(maxSpanEventsHeader, "1")
+		if r.Header.Get("Content-Type") == models.ProtobufContentType {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		var spans []models.Span
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("failed to decode batch: %v", err)
+		}
+		received <- spans
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL, WithBatching(BatchConfig{
+		FlushInterval: time.Hour,
+		RetryBackoff:  time.Millisecond,
+		Protobuf:      true,
+	}))
+	span, _ := tracer.StartSpan(context.Background(), "op")
+	span.AddEvent("first", nil)
+	span.AddEvent("second", nil)
+	span.Finish()
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// The refused protobuf batch is resent as JSON with the events trimmed
+	spans := <-received
+	if len(spans) != 1 || len(spans[0].Events) != 1 || spans[0].Events[0].Name != "first" {
+		t.Errorf("received %+v, want one span with only its first event", spans)
+	}
+	if len(span.span.Events) != 2 {
+		t.Errorf("exported span was modified: %d events, want 2", len(span.span.Events))
+	}
+}
+
+func TestCollectorCapabilities_DropsEventsWhenUnsupported(t *testing.T) {
+	e := &batchExporter{logger: slog.Default()}
+	span := &models.Span{Events: []models.SpanEvent{{Name: "retry"}}}
+
+	// Nothing advertised yet: the batch is sent as is
+	if got := e.fitEvents([]*models.Span{span}); got[0] != span {
+		t.Error("spans were changed before the collector advertised capabilities")
+	}
+
+	header := http.Header{}
+	header.Set(featuresHeader, models.FormatFeatures([]string{models.FeatureGzip, models.FeatureProtobuf}))
+	e.collector.apply(header)
+	if got := e.fitEvents([]*models.Span{span}); got[0].Events != nil {
+		t.Errorf("events = %v, want none for a collector without events", got[0].Events)
+	}
+
+	// Responses without capability headers keep the last advertised ones
+	e.collector.apply(http.Header{})
+	if !e.collector.supports(models.FeatureGzip, "", e.logger) || e.collector.supports(models.FeatureEvents, "", e.logger) {
+		t.Error("capabilities were reset by a response without them")
+	}
+}
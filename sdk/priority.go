@@ -0,0 +1,112 @@
+package sdk
+
+import (
+	"sync/atomic"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// ExportPriority ranks finished spans for export while the exporter queue
+// is filling up.
+type ExportPriority int
+
+// Export priorities, lowest first
+const (
+	PriorityLow ExportPriority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// PriorityFunc assigns a finished span its export priority.
+type PriorityFunc func(span *SpanData) ExportPriority
+
+// DefaultPriority ranks error spans and root spans high, as they carry the
+// most diagnostic value, internal spans low and the rest normal.
+func DefaultPriority(span *SpanData) ExportPriority {
+	switch {
+	case span.Status == "error" || span.ParentSpanID == "":
+		return PriorityHigh
+	case span.SpanKind == "internal" || span.SpanKind == "":
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// Default queue fill limits of an ExportPolicy
+const (
+	DefaultLowPriorityLimit    = 0.5
+	DefaultNormalPriorityLimit = 0.9
+)
+
+// ExportPolicy decides which spans survive when the exporter queue fills
+// up faster than it drains. A span is queued only while the queue is less
+// full than its priority's limit, so low-priority spans are dropped first
+// and the remaining room is kept for the spans that matter most. High
+// priority spans may use the whole queue.
+type ExportPolicy struct {
+	Priority    PriorityFunc // Defaults to DefaultPriority
+	LowLimit    float64      // Queue fill (0-1) from which low-priority spans are dropped (default 0.5)
+	NormalLimit float64      // Queue fill (0-1) from which normal-priority spans are dropped (default 0.9)
+}
+
+// WithExportPolicy prioritizes spans under exporter queue pressure instead
+// of dropping whichever spans find the queue full. It applies to both the
+// HTTP batch exporter and a gRPC exporter.
+func WithExportPolicy(policy ExportPolicy) TracerOption {
+	return func(t *Tracer) {
+		if policy.Priority == nil {
+			policy.Priority = DefaultPriority
+		}
+		if policy.LowLimit <= 0 || policy.LowLimit > 1 {
+			policy.LowLimit = DefaultLowPriorityLimit
+		}
+		if policy.NormalLimit <= 0 || policy.NormalLimit > 1 {
+			policy.NormalLimit = DefaultNormalPriorityLimit
+		}
+		t.exportPolicy = &policy
+	}
+}
+
+// admit reports whether a span may join a queue holding queued of capacity
+// spans.
+func (p *ExportPolicy) admit(span *models.Span, queued, capacity int) bool {
+	var limit float64
+	switch p.Priority(span) {
+	case PriorityHigh:
+		return true
+	case PriorityNormal:
+		limit = p.NormalLimit
+	default:
+		limit = p.LowLimit
+	}
+	return float64(queued) < limit*float64(capacity)
+}
+
+// admitExport applies the export policy, if any, to a finished span. Spans
+// it turns away are counted as dropped by the exporter.
+func (t *Tracer) admitExport(span *models.Span) bool {
+	if t.exportPolicy == nil {
+		return true
+	}
+	queue := t.exportQueue()
+	if t.exportPolicy.admit(span, len(queue), cap(queue)) {
+		return true
+	}
+	if t.grpcExporter != nil {
+		atomic.AddInt64(&t.grpcExporter.dropped, 1)
+	}
+	t.logger.Debug("exporter queue under pressure, dropping low-priority span",
+		"trace_id", span.TraceID,
+		"span_id", span.SpanID,
+	)
+	return false
+}
+
+// exportQueue returns the queue of the configured exporter.
+func (t *Tracer) exportQueue() chan *models.Span {
+	if t.grpcExporter != nil {
+		return t.grpcExporter.queue
+	}
+	return t.batcher.queue
+}
@@ -0,0 +1,56 @@
+package sdk
+
+import (
+	"log/slog"
+	"testing"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestDefaultPriority(t *testing.T) {
+	tests := []struct {
+		name string
+		span models.Span
+		want ExportPriority
+	}{
+		{"root", models.Span{SpanKind: "internal", Status: "ok"}, PriorityHigh},
+		{"error", models.Span{ParentSpanID: "p", SpanKind: "internal", Status: "error"}, PriorityHigh},
+		{"client", models.Span{ParentSpanID: "p", SpanKind: "client", Status: "ok"}, PriorityNormal},
+		{"internal", models.Span{ParentSpanID: "p", SpanKind: "internal", Status: "ok"}, PriorityLow},
+	}
+	for _, tt := range tests {
+		if got := DefaultPriority(&tt.span); got != tt.want {
+			t.Errorf("%s: priority = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestExportPolicy_DropsLowPriorityFirst(t *testing.T) {
+	// A tracer whose exporter is not draining its queue
+	tracer := &Tracer{
+		logger:  slog.Default(),
+		batcher: &batchExporter{logger: slog.Default(), queue: make(chan *models.Span, 10), done: make(chan struct{})},
+	}
+	WithExportPolicy(ExportPolicy{})(tracer)
+
+	internal := &models.Span{ParentSpanID: "p", SpanKind: "internal", Status: "ok"}
+	client := &models.Span{ParentSpanID: "p", SpanKind: "client", Status: "ok"}
+	failed := &models.Span{ParentSpanID: "p", SpanKind: "internal", Status: "error"}
+
+	for i := 0; i < 10; i++ {
+		tracer.export(internal)
+	}
+	if queued := len(tracer.batcher.queue); queued != 5 {
+		t.Fatalf("queued %d low-priority spans, want 5 (half the queue)", queued)
+	}
+	for i := 0; i < 10; i++ {
+		tracer.export(client)
+	}
+	if queued := len(tracer.batcher.queue); queued != 9 {
+		t.Fatalf("queued %d spans, want 9 (normal priority up to 90%%)", queued)
+	}
+	tracer.export(failed)
+	if queued := len(tracer.batcher.queue); queued != 10 {
+		t.Errorf("error span was not queued into the reserved room")
+	}
+}
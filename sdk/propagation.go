@@ -0,0 +1,361 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"regexp"
+	"strings"
+)
+
+// W3C Trace Context constants
+const (
+	// TraceParentHeader is the W3C traceparent header name
+	TraceParentHeader = "traceparent"
+
+	// TraceStateHeader is the W3C tracestate header name
+	TraceStateHeader = "tracestate"
+)
+
+// TraceContext represents parsed W3C Trace Context.
+type TraceContext struct {
+	Version string
+	TraceID string
+	SpanID  string
+	Flags   string
+
+	// TraceState carries vendor-specific data from upstream services
+	TraceState TraceState
+}
+
+// W3C Trace Context format: version-trace-id-parent-id-trace-flags
+// Example: 00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01
+// Versions after 00 may append fields, each preceded by a dash.
+var traceParentRegex = regexp.MustCompile(`^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})(-.*)?$`)
+
+// Header length limits: a traceparent with room for fields later versions
+// may add, and a tracestate of MaxTraceStateEntries members of the largest
+// allowed key and value. Longer headers are rejected without parsing.
+const (
+	maxTraceParentLength = 512
+	maxTraceStateLength  = MaxTraceStateEntries * (256 + 1 + 256 + 1)
+)
+
+// EncodeTraceParent creates a W3C traceparent header value.
+// Format: version-trace-id-parent-id-trace-flags
+func EncodeTraceParent(traceID, spanID, flags string) string {
+	// Default version is "00"
+	version := "00"
+
+	// Default flags is "01" (sampled)
+	if flags == "" {
+		flags = "01"
+	}
+
+	return fmt.Sprintf("%s-%s-%s-%s", version, traceID, spanID, flags)
+}
+
+// DecodeTraceParent parses a W3C traceparent header. Following the spec's
+// forward compatibility rules, versions above 00 are accepted and parsed as
+// version 00, ignoring any fields after the flags; version ff is invalid, as
+// are all-zero trace and parent IDs. Injection always writes version 00.
+func DecodeTraceParent(header string) (*TraceContext, error) {
+	if header == "" {
+		return nil, fmt.Errorf("traceparent header is empty")
+	}
+
+	if len(header) > maxTraceParentLength {
+		return nil, fmt.Errorf("traceparent header exceeds %d bytes", maxTraceParentLength)
+	}
+
+	// Match against W3C format
+	matches := traceParentRegex.FindStringSubmatch(header)
+	if matches == nil {
+		return nil, fmt.Errorf("invalid traceparent format: %s", header)
+	}
+	switch version, extra := matches[1], matches[5]; {
+	case version == "ff":
+		return nil, fmt.Errorf("invalid traceparent version ff: %s", header)
+	case version == "00" && extra != "":
+		return nil, fmt.Errorf("traceparent version 00 has extra fields: %s", header)
+	case strings.Trim(matches[2], "0") == "":
+		return nil, fmt.Errorf("traceparent trace ID is all zeros: %s", header)
+	case strings.Trim(matches[3], "0") == "":
+		return nil, fmt.Errorf("traceparent parent ID is all zeros: %s", header)
+	}
+
+	return &TraceContext{
+		Version: matches[1],
+		TraceID: matches[2],
+		SpanID:  matches[3],
+		Flags:   matches[4],
+	}, nil
+}
+
+// IsValidTraceParent checks if a header value is valid W3C format.
+func IsValidTraceParent(header string) bool {
+	_, err := DecodeTraceParent(header)
+	return err == nil
+}
+
+// MaxTraceStateEntries is the W3C limit on tracestate list members.
+const MaxTraceStateEntries = 32
+
+// tracestate keys are a simple key, or tenant@system for multi-tenant vendors;
+// values are printable ASCII without ',' or '=' that do not end in a space.
+var (
+	traceStateKeyRegex   = regexp.MustCompile(`^([a-z][_0-9a-z\-*/]{0,255}|[a-z0-9][_0-9a-z\-*/]{0,240}@[a-z][_0-9a-z\-*/]{0,13})$`)
+	traceStateValueRegex = regexp.MustCompile(`^[\x20-\x2b\x2d-\x3c\x3e-\x7e]{0,255}[\x21-\x2b\x2d-\x3c\x3e-\x7e]$`)
+)
+
+// TraceStateEntry is one vendor's key=value member of a tracestate.
+type TraceStateEntry struct {
+	Key   string
+	Value string
+}
+
+// TraceState is a parsed W3C tracestate: vendor entries ordered from most to
+// least recently updated. It is immutable; Insert and Delete return copies,
+// so a span's state can be shared with its children.
+type TraceState struct {
+	entries []TraceStateEntry
+}
+
+// ParseTraceState parses a tracestate header. Empty members are skipped; a
+// malformed member, duplicate key or more than 32 members is an error, in
+// which case the header must not be propagated.
+func ParseTraceState(header string) (TraceState, error) {
+	if len(header) > maxTraceStateLength {
+		return TraceState{}, fmt.Errorf("tracestate header exceeds %d bytes", maxTraceStateLength)
+	}
+	var ts TraceState
+	seen := make(map[string]bool)
+	for _, member := range strings.Split(header, ",") {
+		member = strings.Trim(member, " \t")
+		if member == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(member, "=")
+		if !ok {
+			return TraceState{}, fmt.Errorf("invalid tracestate member %q", member)
+		}
+		if err := validateTraceStateEntry(key, value); err != nil {
+			return TraceState{}, err
+		}
+		if seen[key] {
+			return TraceState{}, fmt.Errorf("duplicate tracestate key %q", key)
+		}
+		seen[key] = true
+		ts.entries = append(ts.entries, TraceStateEntry{Key: key, Value: value})
+	}
+	if len(ts.entries) > MaxTraceStateEntries {
+		return TraceState{}, fmt.Errorf("tracestate has %d members, limit is %d", len(ts.entries), MaxTraceStateEntries)
+	}
+	return ts, nil
+}
+
+func validateTraceStateEntry(key, value string) error {
+	if !traceStateKeyRegex.MatchString(key) {
+		return fmt.Errorf("invalid tracestate key %q", key)
+	}
+	if !traceStateValueRegex.MatchString(value) {
+		return fmt.Errorf("invalid tracestate value %q for key %q", value, key)
+	}
+	return nil
+}
+
+// Get returns the value of a vendor key.
+func (ts TraceState) Get(key string) (string, bool) {
+	for _, e := range ts.entries {
+		if e.Key == key {
+			return e.Value, true
+		}
+	}
+	return "", false
+}
+
+// Insert sets a vendor key and moves it to the front, as the W3C spec asks
+// of a vendor updating its entry. Past 32 members the oldest are dropped.
+func (ts TraceState) Insert(key, value string) (TraceState, error) {
+	if err := validateTraceStateEntry(key, value); err != nil {
+		return ts, err
+	}
+	entries := make([]TraceStateEntry, 0, len(ts.entries)+1)
+	entries = append(entries, TraceStateEntry{Key: key, Value: value})
+	for _, e := range ts.entries {
+		if e.Key != key && len(entries) < MaxTraceStateEntries {
+			entries = append(entries, e)
+		}
+	}
+	return TraceState{entries: entries}, nil
+}
+
+// Delete removes a vendor key.
+func (ts TraceState) Delete(key string) TraceState {
+	entries := make([]TraceStateEntry, 0, len(ts.entries))
+	for _, e := range ts.entries {
+		if e.Key != key {
+			entries = append(entries, e)
+		}
+	}
+	return TraceState{entries: entries}
+}
+
+// Entries returns a copy of the entries, most recently updated first.
+func (ts TraceState) Entries() []TraceStateEntry {
+	return append([]TraceStateEntry(nil), ts.entries...)
+}
+
+// Len returns the number of entries.
+func (ts TraceState) Len() int {
+	return len(ts.entries)
+}
+
+// String encodes the tracestate header value.
+func (ts TraceState) String() string {
+	members := make([]string, len(ts.entries))
+	for i, e := range ts.entries {
+		members[i] = e.Key + "=" + e.Value
+	}
+	return strings.Join(members, ",")
+}
+
+// Context helpers
+
+type contextKey int
+
+const (
+	spanContextKey contextKey = iota
+	traceContextContextKey
+)
+
+// SpanFromContext extracts the span from the context.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+
+	span, ok := ctx.Value(spanContextKey).(*Span)
+	if !ok {
+		return nil
+	}
+
+	return span
+}
+
+// ContextWithSpan adds a span to the context.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, spanContextKey, span)
+}
+
+// traceContextFromContext extracts trace context from the context.
+func traceContextFromContext(ctx context.Context) *TraceContext {
+	if ctx == nil {
+		return nil
+	}
+
+	tc, ok := ctx.Value(traceContextContextKey).(*TraceContext)
+	if !ok {
+		return nil
+	}
+
+	return tc
+}
+
+// contextWithTraceContext adds trace context to the context.
+func contextWithTraceContext(ctx context.Context, tc *TraceContext) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return context.WithValue(ctx, traceContextContextKey, tc)
+}
+
+// InjectTraceContext injects trace context into HTTP headers.
+func InjectTraceContext(span *Span, header func(key, value string)) {
+	if span == nil || span.span == nil {
+		return
+	}
+
+	// Create traceparent header
+	traceparent := EncodeTraceParent(span.span.TraceID, span.span.SpanID, "01")
+	header(TraceParentHeader, traceparent)
+
+	// Pass vendor state along unchanged unless the span updated it
+	if ts := span.TraceState(); ts.Len() > 0 {
+		header(TraceStateHeader, ts.String())
+	}
+}
+
+// ExtractTraceContext extracts trace context from HTTP headers. getHeader
+// is called with lowercase names and then, for a missing traceparent, the
+// canonical form; see Extract for carriers with repeated keys.
+func ExtractTraceContext(getHeader func(key string) string) (*TraceContext, error) {
+	return extractTraceContext(getterCarrier(getHeader))
+}
+
+// extractTraceContext reads trace context from carrier following the W3C
+// rules: names match regardless of case, spaces and tabs around values are
+// ignored, and a traceparent with several different values is invalid since
+// the parent cannot be told apart. Several tracestate values are combined
+// in order, like tracestate header lines.
+func extractTraceContext(carrier Carrier) (*TraceContext, error) {
+	traceparents := carrierValues(carrier, TraceParentHeader)
+	if len(traceparents) == 0 {
+		return nil, nil // No trace context
+	}
+	var traceparent string
+	for _, value := range traceparents {
+		// A comma separates header lines folded into one
+		for _, part := range strings.Split(value, ",") {
+			part = strings.Trim(part, " \t")
+			switch {
+			case part == "", part == traceparent:
+			case traceparent == "":
+				traceparent = part
+			default:
+				return nil, fmt.Errorf("conflicting traceparent values %q and %q", traceparent, part)
+			}
+		}
+	}
+
+	// Parse header
+	tc, err := DecodeTraceParent(traceparent)
+	if err != nil {
+		return nil, err
+	}
+
+	// A malformed tracestate is dropped; the traceparent still applies
+	if values := carrierValues(carrier, TraceStateHeader); len(values) > 0 {
+		if ts, err := ParseTraceState(strings.Join(values, ",")); err == nil {
+			tc.TraceState = ts
+		}
+	}
+	return tc, nil
+}
+
+// carrierValues returns every non-empty value of key in carrier. Carriers
+// with a single value per key are also asked for the canonical HTTP form of
+// the name, for maps filled from canonicalized headers.
+func carrierValues(carrier Carrier, key string) []string {
+	var values []string
+	if multi, ok := carrier.(MultiValueCarrier); ok {
+		values = multi.Values(key)
+	} else {
+		value := carrier.Get(key)
+		if value == "" {
+			value = carrier.Get(textproto.CanonicalMIMEHeaderKey(key))
+		}
+		values = []string{value}
+	}
+
+	nonEmpty := values[:0:0]
+	for _, value := range values {
+		if strings.Trim(value, " \t") != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return nonEmpty
+}
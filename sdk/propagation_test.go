@@ -0,0 +1,280 @@
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseTraceState(t *testing.T) {
+	ts, err := ParseTraceState("rojo=00f067aa0ba902b7, congo=t61rcWkgMzE,,acme@vendor=a b")
+	if err != nil {
+		t.Fatalf("ParseTraceState failed: %v", err)
+	}
+	if ts.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", ts.Len())
+	}
+	if v, ok := ts.Get("congo"); !ok || v != "t61rcWkgMzE" {
+		t.Errorf("Get(congo) = %q, %v", v, ok)
+	}
+	if v, _ := ts.Get("acme@vendor"); v != "a b" {
+		t.Errorf("Get(acme@vendor) = %q, want %q", v, "a b")
+	}
+	if got, want := ts.String(), "rojo=00f067aa0ba902b7,congo=t61rcWkgMzE,acme@vendor=a b"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestParseTraceState_Invalid(t *testing.T) {
+	tooMany := make([]string, MaxTraceStateEntries+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("k%d=v", i)
+	}
+
+	for _, header := range []string{
+		"novalue",
+		"UPPER=v",
+		"key=has,comma",
+		"key=",
+		"key=a=b",
+		"dup=1,dup=2",
+		"tenant@Vendor=v",
+		strings.Join(tooMany, ","),
+	} {
+		if _, err := ParseTraceState(header); err == nil {
+			t.Errorf("ParseTraceState(%q): expected an error", header)
+		}
+	}
+}
+
+func TestDecodeTraceParent_Versions(t *testing.T) {
+	const ids = "0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331"
+	for _, header := range []string{
+		"00-" + ids + "-01",
+		"01-" + ids + "-01",
+		"cc-" + ids + "-01-what-the-future-will-be-like",
+		"fe-" + ids + "-09-",
+	} {
+		tc, err := DecodeTraceParent(header)
+		if err != nil {
+			t.Errorf("DecodeTraceParent(%q) failed: %v", header, err)
+			continue
+		}
+		if tc.TraceID != "0af7651916cd43dd8448eb211c80319c" || tc.SpanID != "b7ad6b7169203331" || tc.Version != header[:2] {
+			t.Errorf("DecodeTraceParent(%q) = %+v", header, tc)
+		}
+	}
+
+	for _, header := range []string{
+		"ff-" + ids + "-01",
+		"00-" + ids + "-01-extra",
+		"cc-" + ids + "-01extra",
+		"0g-" + ids + "-01",
+		"00-" + ids,
+		"00-00000000000000000000000000000000-b7ad6b7169203331-01",
+		"00-0af7651916cd43dd8448eb211c80319c-0000000000000000-01",
+	} {
+		if _, err := DecodeTraceParent(header); err == nil || IsValidTraceParent(header) {
+			t.Errorf("DecodeTraceParent(%q): expected an error", header)
+		}
+	}
+
+	// Spans continuing a newer version's trace propagate version 00
+	tracer := NewTracer("test-service", "http://localhost:0")
+	ctx := contextWithTraceContext(context.Background(), &TraceContext{Version: "cc", TraceID: "0af7651916cd43dd8448eb211c80319c", SpanID: "b7ad6b7169203331", Flags: "01"})
+	span, _ := tracer.StartSpan(ctx, "child")
+	var traceparent string
+	InjectTraceContext(span, func(key, value string) {
+		if key == TraceParentHeader {
+			traceparent = value
+		}
+	})
+	if !strings.HasPrefix(traceparent, "00-0af7651916cd43dd8448eb211c80319c-") {
+		t.Errorf("traceparent = %q, want version 00 in the same trace", traceparent)
+	}
+}
+
+// FuzzDecodeTraceParent checks that any header either fails to parse or
+// yields IDs that encode back to a valid traceparent.
+func FuzzDecodeTraceParent(f *testing.F) {
+	f.Add("00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+	f.Add("cc-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01-future")
+	f.Add("ff-00000000000000000000000000000000-0000000000000000-00")
+	f.Fuzz(func(t *testing.T, header string) {
+		tc, err := DecodeTraceParent(header)
+		if err != nil {
+			return
+		}
+		encoded := EncodeTraceParent(tc.TraceID, tc.SpanID, tc.Flags)
+		if again, err := DecodeTraceParent(encoded); err != nil || again.TraceID != tc.TraceID || again.SpanID != tc.SpanID {
+			t.Fatalf("%q decoded to %+v, which encodes to %q: %v", header, tc, encoded, err)
+		}
+	})
+}
+
+// FuzzParseTraceState checks that a parsed tracestate re-encodes to a
+// header that parses to the same entries.
+func FuzzParseTraceState(f *testing.F) {
+	f.Add("rojo=00f067aa0ba902b7, congo=t61rcWkgMzE,,acme@vendor=a b")
+	f.Add("k=v,k=w")
+	f.Add(",,,=,")
+	f.Fuzz(func(t *testing.T, header string) {
+		ts, err := ParseTraceState(header)
+		if err != nil {
+			return
+		}
+		again, err := ParseTraceState(ts.String())
+		if err != nil || again.String() != ts.String() {
+			t.Fatalf("%q parsed to %q, which reparses to %q: %v", header, ts.String(), again.String(), err)
+		}
+	})
+}
+
+func TestTraceState_InsertMovesToFrontAndCaps(t *testing.T) {
+	ts, _ := ParseTraceState("a=1,b=2,c=3")
+
+	updated, err := ts.Insert("b", "updated")
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+	if got := updated.String(); got != "b=updated,a=1,c=3" {
+		t.Errorf("after Insert: %q", got)
+	}
+	if got := ts.String(); got != "a=1,b=2,c=3" {
+		t.Errorf("Insert modified the original: %q", got)
+	}
+
+	if _, err := ts.Insert("Bad", "v"); err == nil {
+		t.Error("expected an error for an invalid key")
+	}
+
+	// A new key at the limit evicts the oldest entry
+	full := TraceState{}
+	for i := 0; i < MaxTraceStateEntries; i++ {
+		full, _ = full.Insert(fmt.Sprintf("k%d", i), "v")
+	}
+	full, _ = full.Insert("newest", "v")
+	if full.Len() != MaxTraceStateEntries {
+		t.Errorf("Len() = %d, want %d", full.Len(), MaxTraceStateEntries)
+	}
+	if _, ok := full.Get("k0"); ok {
+		t.Error("oldest entry should be dropped")
+	}
+	if full.Entries()[0].Key != "newest" {
+		t.Errorf("first entry = %q, want newest", full.Entries()[0].Key)
+	}
+
+	if got := updated.Delete("a").String(); got != "b=updated,c=3" {
+		t.Errorf("after Delete: %q", got)
+	}
+}
+
+func TestExtractTraceContext_DropsInvalidTraceState(t *testing.T) {
+	headers := map[string]string{
+		TraceParentHeader: EncodeTraceParent("0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", "01"),
+		TraceStateHeader:  "bad key=1",
+	}
+	tc, err := ExtractTraceContext(func(key string) string { return headers[key] })
+	if err != nil || tc == nil {
+		t.Fatalf("ExtractTraceContext = %v, %v", tc, err)
+	}
+	if tc.TraceState.Len() != 0 {
+		t.Errorf("invalid tracestate should be dropped, got %q", tc.TraceState.String())
+	}
+}
+
+func TestTraceState_PropagatesThroughService(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+	tracer := NewTracer("test-service", server.URL)
+
+	var downstream http.Header
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downstream = r.Header.Clone()
+	}))
+	defer backend.Close()
+
+	handler := Middleware(tracer)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span := SpanFromContext(r.Context())
+		if err := span.SetTraceState("traceflow", "s1"); err != nil {
+			t.Errorf("SetTraceState failed: %v", err)
+		}
+
+		child, ctx := tracer.StartSpan(r.Context(), "call-backend")
+		defer child.Finish()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, backend.URL, nil)
+		resp, err := WrapHTTPClient(http.DefaultClient).Do(req)
+		if err != nil {
+			t.Errorf("request failed: %v", err)
+			return
+		}
+		resp.Body.Close()
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceParentHeader, EncodeTraceParent("0af7651916cd43dd8448eb211c80319c", "b7ad6b7169203331", "01"))
+	req.Header.Add(TraceStateHeader, "rojo=00f067aa0ba902b7")
+	req.Header.Add(TraceStateHeader, "congo=t61rcWkgMzE")
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(context.Background()))
+
+	if downstream == nil {
+		t.Fatal("backend was not called")
+	}
+	want := "traceflow=s1,rojo=00f067aa0ba902b7,congo=t61rcWkgMzE"
+	if got := downstream.Get(TraceStateHeader); got != want {
+		t.Errorf("downstream tracestate = %q, want %q", got, want)
+	}
+}
+
+func TestExtract_HeaderEdgeCases(t *testing.T) {
+	const (
+		parent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+		other  = "00-0af7651916cd43dd8448eb211c80319c-00f067aa0ba902b7-01"
+	)
+	tests := []struct {
+		name      string
+		header    http.Header
+		wantSpan  string // Parent span ID, or empty for no trace context
+		wantState string
+	}{
+		{"canonical name", http.Header{"Traceparent": {parent}}, "b7ad6b7169203331", ""},
+		{"lowercase name", http.Header{"traceparent": {parent}}, "b7ad6b7169203331", ""},
+		{"mixed case name", http.Header{"TraceParent": {parent}}, "b7ad6b7169203331", ""},
+		{"surrounding whitespace", http.Header{"Traceparent": {" \t" + parent + "\t "}}, "b7ad6b7169203331", ""},
+		{"repeated identical values", http.Header{"Traceparent": {parent, parent}}, "b7ad6b7169203331", ""},
+		{"conflicting values", http.Header{"Traceparent": {parent, other}}, "", ""},
+		{"conflicting values folded into one line", http.Header{"Traceparent": {parent + ", " + other}}, "", ""},
+		{"whitespace inside the value", http.Header{"Traceparent": {"00- 0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"}}, "", ""},
+		{"blank value", http.Header{"Traceparent": {"  "}}, "", ""},
+		{
+			"tracestate over several lines",
+			http.Header{"Traceparent": {parent}, "tracestate": {"rojo=1 ", "\tcongo=2"}},
+			"b7ad6b7169203331", "rojo=1,congo=2",
+		},
+	}
+	for _, tt := range tests {
+		tc := traceContextFromContext(Extract(context.Background(), HeaderCarrier(tt.header)))
+		switch {
+		case tt.wantSpan == "" && tc != nil:
+			t.Errorf("%s: extracted %+v, want no trace context", tt.name, tc)
+		case tt.wantSpan != "" && (tc == nil || tc.SpanID != tt.wantSpan):
+			t.Errorf("%s: extracted %+v, want parent %s", tt.name, tc, tt.wantSpan)
+		case tc != nil && tc.TraceState.String() != tt.wantState:
+			t.Errorf("%s: tracestate = %q, want %q", tt.name, tc.TraceState.String(), tt.wantState)
+		}
+	}
+
+	// gRPC metadata keys are lowercase and may repeat too
+	md := MetadataCarrier{}
+	md.Set("TraceParent", parent)
+	if tc := traceContextFromContext(Extract(context.Background(), md)); tc == nil {
+		t.Error("traceparent not extracted from gRPC metadata")
+	}
+	md["traceparent"] = append(md["traceparent"], other)
+	if tc := traceContextFromContext(Extract(context.Background(), md)); tc != nil {
+		t.Error("conflicting traceparents in gRPC metadata were accepted")
+	}
+}
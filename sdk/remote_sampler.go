@@ -0,0 +1,107 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Defaults for RemoteSamplingConfig
+const (
+	DefaultSamplingPollInterval = time.Minute
+	DefaultSamplingRecovery     = 30 * time.Second
+)
+
+// RemoteSamplingConfig tunes WithRemoteSampling. Zero values use defaults.
+type RemoteSamplingConfig struct {
+	PollInterval time.Duration // How often the collector's sampling config is fetched (default 1m)
+	Recovery     time.Duration // How long sample rates take to climb back once the collector stops throttling (default 30s)
+}
+
+// WithRemoteSampling makes sampling adapt without operator intervention.
+// The tracer polls the collector's /api/v1/sampling endpoint for this
+// service's sampling rules and uses them in place of the configured
+// sampler, which still applies until the first fetch succeeds. The
+// collector's throttle hints, from the poll or from exports, lower the
+// sample rate while it is saturated; afterwards the rate climbs back over
+// Recovery instead of returning to full volume at once.
+func WithRemoteSampling(config RemoteSamplingConfig) TracerOption {
+	return func(t *Tracer) {
+		if config.PollInterval <= 0 {
+			config.PollInterval = DefaultSamplingPollInterval
+		}
+		if config.Recovery <= 0 {
+			config.Recovery = DefaultSamplingRecovery
+		}
+		t.remoteSampling = &config
+	}
+}
+
+// remoteSampler samples with the rules last fetched from the collector.
+type remoteSampler struct {
+	fallback Sampler
+	rules    atomic.Pointer[RuleSampler]
+}
+
+// ShouldSample implements Sampler.
+func (s *remoteSampler) ShouldSample(operationName string) bool {
+	if rules := s.rules.Load(); rules != nil {
+		return rules.ShouldSample(operationName)
+	}
+	return s.fallback.ShouldSample(operationName)
+}
+
+// startRemoteSampling wraps the sampler and starts polling if
+// WithRemoteSampling enabled it.
+func (t *Tracer) startRemoteSampling() {
+	if t.remoteSampling == nil {
+		return
+	}
+	sampler := &remoteSampler{fallback: t.sampler}
+	t.sampler = sampler
+	t.throttleState().recovery = t.remoteSampling.Recovery
+
+	go func() {
+		ticker := time.NewTicker(t.remoteSampling.PollInterval)
+		defer ticker.Stop()
+		for {
+			if err := t.fetchSampling(sampler); err != nil {
+				t.logger.Warn("failed to fetch sampling config, keeping the current one", "error", err)
+			}
+			select {
+			case <-t.stopCh:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// fetchSampling fetches this service's sampling strategy and applies it.
+func (t *Tracer) fetchSampling(sampler *remoteSampler) error {
+	resp, err := t.client.Get(t.collectorUrl + "/api/v1/sampling?service=" + url.QueryEscape(t.serviceName))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("collector returned status %d", resp.StatusCode)
+	}
+
+	var strategy models.SamplingStrategy
+	if err := json.NewDecoder(resp.Body).Decode(&strategy); err != nil {
+		return fmt.Errorf("invalid sampling config: %w", err)
+	}
+	rules, err := NewRuleSampler(RuleSamplerConfig{DefaultRate: strategy.DefaultRate, Rules: strategy.Rules})
+	if err != nil {
+		return err
+	}
+	sampler.rules.Store(rules)
+	t.throttleState().apply(strategy.Throttle)
+	return nil
+}
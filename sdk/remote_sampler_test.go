@@ -0,0 +1,89 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestTracer_RemoteSamplingAdaptsToCollector(t *testing.T) {
+	var mu sync.Mutex
+	strategy := models.SamplingStrategy{
+		DefaultRate: 1,
+		Rules:       []models.SamplingRule{{Operation: "GET /health", Rate: 0}},
+		Throttle:    &models.ThrottleHint{SampleRate: 0.2},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/sampling" {
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		if got := r.URL.Query().Get("service"); got != "checkout" {
+			t.Errorf("service = %q, want checkout", got)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		json.NewEncoder(w).Encode(strategy)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("checkout", server.URL, WithRemoteSampling(RemoteSamplingConfig{
+		PollInterval: 10 * time.Millisecond,
+		Recovery:     time.Hour,
+	}))
+	defer tracer.Shutdown(context.Background())
+
+	// Saturated collector: the hinted rate applies on top of the rules
+	deadline := time.Now().Add(5 * time.Second)
+	for tracer.throttleState().sampleRate() != 0.2 {
+		if time.Now().After(deadline) {
+			t.Fatal("throttle hint from the sampling config was not applied")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if span, _ := tracer.StartSpan(context.Background(), "GET /health"); span.span != nil {
+		t.Error("span sampled despite a remote rule with rate 0")
+	}
+
+	// Once the collector recovers, the rate climbs back gradually
+	mu.Lock()
+	strategy.Throttle = nil
+	mu.Unlock()
+	for tracer.throttleState().sampleRate() == 0.2 {
+		if time.Now().After(deadline) {
+			t.Fatal("sample rate did not start recovering")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if rate := tracer.throttleState().sampleRate(); rate >= 0.5 {
+		t.Errorf("sample rate = %v right after recovery started, want a gradual climb", rate)
+	}
+}
+
+func TestThrottleState_RecoversGradually(t *testing.T) {
+	state := throttleState{recovery: time.Minute}
+	state.apply(&models.ThrottleHint{SampleRate: 0.2})
+	state.apply(nil)
+
+	// Pretend recovery started half a minute ago
+	state.expires = time.Now().Add(-30 * time.Second)
+	if rate := state.sampleRate(); rate < 0.55 || rate > 0.65 {
+		t.Errorf("sample rate halfway through recovery = %v, want about 0.6", rate)
+	}
+	state.expires = time.Now().Add(-time.Minute)
+	if rate := state.sampleRate(); rate != 1 {
+		t.Errorf("sample rate after recovery = %v, want 1", rate)
+	}
+
+	// Responses while not throttled do not restart recovery
+	state.apply(nil)
+	if rate := state.sampleRate(); rate != 1 {
+		t.Errorf("sample rate = %v after an unthrottled response, want 1", rate)
+	}
+}
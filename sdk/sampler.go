@@ -0,0 +1,138 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"regexp"
+	"strconv"
+
+	"github.com/saintparish4/asmbly/internal/pattern"
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Environment variables read by RuleSamplerFromEnv
+const (
+	// EnvSampleRate is the default sample rate, e.g. "0.25"
+	EnvSampleRate = "TRACEFLOW_SAMPLE_RATE"
+
+	// EnvSamplingRules is a RuleSamplerConfig as JSON
+	EnvSamplingRules = "TRACEFLOW_SAMPLING_RULES"
+
+	// EnvSamplingRulesFile is the path of a RuleSamplerConfig JSON file
+	EnvSamplingRulesFile = "TRACEFLOW_SAMPLING_RULES_FILE"
+)
+
+// SamplingRule overrides the sample rate for matching operations.
+type SamplingRule = models.SamplingRule
+
+// RuleSamplerConfig configures a RuleSampler.
+type RuleSamplerConfig struct {
+	// DefaultRate applies to operations no rule matches
+	DefaultRate float64 `json:"default_rate"`
+
+	// Rules are checked in order; the first match wins
+	Rules []SamplingRule `json:"rules,omitempty"`
+}
+
+// RuleSampler samples traces by root operation name: per-operation rules
+// layered over a default ratio. For example, health checks can be dropped
+// entirely while every checkout is kept and everything else sampled at 10%.
+type RuleSampler struct {
+	defaultRate float64
+	rules       []samplingRule
+}
+
+type samplingRule struct {
+	pattern *regexp.Regexp
+	rate    float64
+}
+
+// NewRuleSampler validates config and builds a sampler.
+func NewRuleSampler(config RuleSamplerConfig) (*RuleSampler, error) {
+	if err := validateRate(config.DefaultRate); err != nil {
+		return nil, fmt.Errorf("default_rate: %w", err)
+	}
+
+	s := &RuleSampler{defaultRate: config.DefaultRate}
+	for i, rule := range config.Rules {
+		if rule.Operation == "" {
+			return nil, fmt.Errorf("rule %d: operation is required", i)
+		}
+		if err := validateRate(rule.Rate); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, rule.Operation, err)
+		}
+		s.rules = append(s.rules, samplingRule{pattern: pattern.Compile(rule.Operation), rate: rule.Rate})
+	}
+	return s, nil
+}
+
+// NewRuleSamplerFromJSON builds a sampler from a RuleSamplerConfig in JSON.
+// An omitted default_rate keeps every trace.
+func NewRuleSamplerFromJSON(data []byte) (*RuleSampler, error) {
+	config := RuleSamplerConfig{DefaultRate: 1}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid sampling config: %w", err)
+	}
+	return NewRuleSampler(config)
+}
+
+// RuleSamplerFromEnv builds a sampler from TRACEFLOW_SAMPLING_RULES (or the
+// file named by TRACEFLOW_SAMPLING_RULES_FILE), with TRACEFLOW_SAMPLE_RATE
+// overriding the default rate. With none of them set every trace is kept.
+func RuleSamplerFromEnv() (*RuleSampler, error) {
+	data := []byte(os.Getenv(EnvSamplingRules))
+	if path := os.Getenv(EnvSamplingRulesFile); len(data) == 0 && path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("%s: %w", EnvSamplingRulesFile, err)
+		}
+	}
+
+	config := RuleSamplerConfig{DefaultRate: 1}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("invalid sampling config: %w", err)
+		}
+	}
+	if rate := os.Getenv(EnvSampleRate); rate != "" {
+		parsed, err := strconv.ParseFloat(rate, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid rate %q", EnvSampleRate, rate)
+		}
+		config.DefaultRate = parsed
+	}
+	return NewRuleSampler(config)
+}
+
+// ShouldSample keeps the trace with the rate of the first matching rule.
+func (s *RuleSampler) ShouldSample(operationName string) bool {
+	rate := s.Rate(operationName)
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return rand.Float64() < rate
+	}
+}
+
+// Rate returns the sample rate that applies to operationName.
+func (s *RuleSampler) Rate(operationName string) float64 {
+	for _, rule := range s.rules {
+		if rule.pattern.MatchString(operationName) {
+			return rule.rate
+		}
+	}
+	return s.defaultRate
+}
+
+func validateRate(rate float64) error {
+	if math.IsNaN(rate) || rate < 0 || rate > 1 {
+		return fmt.Errorf("rate %v must be between 0 and 1", rate)
+	}
+	return nil
+}
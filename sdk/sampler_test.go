@@ -0,0 +1,97 @@
+package sdk
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestRuleSampler_FirstMatchingRuleWins(t *testing.T) {
+	sampler, err := NewRuleSampler(RuleSamplerConfig{
+		DefaultRate: 0.1,
+		Rules: []SamplingRule{
+			{Operation: "GET /health*", Rate: 0},
+			{Operation: "POST /checkout", Rate: 1},
+			{Operation: "*", Rate: 0.5},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewRuleSampler() error = %v", err)
+	}
+
+	tests := []struct {
+		operation string
+		want      float64
+	}{
+		{"GET /healthz", 0},
+		{"GET /health/live", 0},
+		{"POST /checkout", 1},
+		{"POST /checkout/confirm", 0.5},
+	}
+	for _, tt := range tests {
+		if got := sampler.Rate(tt.operation); got != tt.want {
+			t.Errorf("Rate(%q) = %v, want %v", tt.operation, got, tt.want)
+		}
+	}
+
+	for i := 0; i < 100; i++ {
+		if sampler.ShouldSample("GET /healthz") || !sampler.ShouldSample("POST /checkout") {
+			t.Fatal("rates 0 and 1 must be deterministic")
+		}
+	}
+}
+
+func TestNewRuleSampler_RejectsInvalidRates(t *testing.T) {
+	if _, err := NewRuleSampler(RuleSamplerConfig{DefaultRate: 1.5}); err == nil {
+		t.Error("expected error for default rate above 1")
+	}
+	if _, err := NewRuleSampler(RuleSamplerConfig{DefaultRate: 1, Rules: []SamplingRule{{Operation: "x", Rate: -1}}}); err == nil {
+		t.Error("expected error for negative rule rate")
+	}
+	if _, err := NewRuleSampler(RuleSamplerConfig{DefaultRate: math.NaN()}); err == nil {
+		t.Error("expected error for NaN default rate")
+	}
+	if _, err := NewRuleSampler(RuleSamplerConfig{DefaultRate: 1, Rules: []SamplingRule{{Operation: "x", Rate: math.NaN()}}}); err == nil {
+		t.Error("expected error for NaN rule rate")
+	}
+
+	t.Setenv(EnvSampleRate, "NaN")
+	if _, err := RuleSamplerFromEnv(); err == nil {
+		t.Errorf("expected error for %s=NaN", EnvSampleRate)
+	}
+}
+
+func TestRuleSamplerFromEnv(t *testing.T) {
+	t.Setenv(EnvSamplingRules, `{"rules": [{"operation": "GET /healthz", "rate": 0}]}`)
+	t.Setenv(EnvSampleRate, "0.25")
+
+	sampler, err := RuleSamplerFromEnv()
+	if err != nil {
+		t.Fatalf("RuleSamplerFromEnv() error = %v", err)
+	}
+	if got := sampler.Rate("GET /healthz"); got != 0 {
+		t.Errorf("health check rate = %v, want 0", got)
+	}
+	if got := sampler.Rate("GET /orders"); got != 0.25 {
+		t.Errorf("default rate = %v, want 0.25", got)
+	}
+}
+
+func TestStartSpan_DroppedRootDropsChildren(t *testing.T) {
+	sampler, _ := NewRuleSamplerFromJSON([]byte(`{"rules": [{"operation": "GET /healthz", "rate": 0}]}`))
+	tracer := NewTracer("test-service", "http://localhost:9090", WithSampler(sampler))
+
+	root, ctx := tracer.StartSpan(context.Background(), "GET /healthz")
+	if root.span != nil {
+		t.Fatal("health check root should not be sampled")
+	}
+	child, _ := tracer.StartSpan(ctx, "db.ping")
+	if child.span != nil {
+		t.Error("child of a dropped root should not be sampled")
+	}
+
+	other, _ := tracer.StartSpan(context.Background(), "GET /orders")
+	if other.span == nil {
+		t.Error("unmatched operation should use the default rate of 1")
+	}
+}
@@ -0,0 +1,105 @@
+package sdk
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// throttleHintTTL is how long a hint stays in effect if the collector stops
+// responding; any response without a hint clears it immediately.
+const throttleHintTTL = 10 * time.Second
+
+// Header the collector uses to advertise its suggested sample rate
+const throttleSampleRateHeader = "X-Traceflow-Sample-Rate"
+
+// throttleState holds the latest collector throttle hint.
+type throttleState struct {
+	mu           sync.Mutex
+	rate         float64
+	expires      time.Time
+	backoffUntil time.Time
+
+	// recovery, if set, is how long the sample rate takes to climb back to
+	// 1 once the hint is cleared or lapses, instead of jumping back at once
+	// and likely saturating the collector again (see remote_sampler.go)
+	recovery time.Duration
+}
+
+// apply records a hint from an ingestion response; nil clears throttling.
+func (s *throttleState) apply(hint *models.ThrottleHint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if hint == nil {
+		// Recovery starts now; with none the hint is simply over
+		if now.Before(s.expires) {
+			s.expires = now
+		}
+		s.backoffUntil = time.Time{}
+		return
+	}
+
+	s.rate = hint.SampleRate
+	s.expires = now.Add(throttleHintTTL)
+	if hint.BackoffMs > 0 {
+		s.backoffUntil = now.Add(hint.Backoff())
+	}
+}
+
+// sampleRate returns the fraction of new traces to keep (1 when not throttled).
+func (s *throttleState) sampleRate() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rate <= 0 || s.rate >= 1 {
+		return 1
+	}
+	since := time.Since(s.expires)
+	switch {
+	case since < 0:
+		return s.rate
+	case since < s.recovery:
+		return s.rate + (1-s.rate)*float64(since)/float64(s.recovery)
+	default:
+		return 1
+	}
+}
+
+// backoffRemaining returns how long exports should still be held off.
+func (s *throttleState) backoffRemaining() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Until(s.backoffUntil)
+}
+
+// throttleHintFromHeaders reads the hint the collector puts on HTTP
+// ingestion responses, or nil if there is none.
+func throttleHintFromHeaders(header http.Header) *models.ThrottleHint {
+	value := header.Get(throttleSampleRateHeader)
+	if value == "" {
+		return nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil
+	}
+
+	hint := &models.ThrottleHint{SampleRate: rate}
+	if seconds, err := strconv.ParseInt(header.Get("Retry-After"), 10, 64); err == nil && seconds > 0 {
+		hint.BackoffMs = seconds * 1000
+	}
+	return hint
+}
+
+// throttleState returns the state fed by whichever export path is in use.
+func (t *Tracer) throttleState() *throttleState {
+	if t.grpcExporter != nil {
+		return &t.grpcExporter.throttle
+	}
+	return &t.throttle
+}
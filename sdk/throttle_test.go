@@ -0,0 +1,75 @@
+package sdk
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestTracer_HonorsThrottleHint(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(throttleSampleRateHeader, "0.100")
+		w.Header().Set("Retry-After", "1")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL)
+	defer tracer.Shutdown(context.Background())
+
+	span := &models.Span{TraceID: models.GenerateTraceID(), SpanID: models.GenerateSpanID()}
+	retry, err := tracer.batcher.post([]*models.Span{span}, models.GenerateTraceID())
+	if !retry || err == nil {
+		t.Fatalf("post() = %v, %v; want a retryable error", retry, err)
+	}
+
+	if rate := tracer.throttle.sampleRate(); rate != 0.1 {
+		t.Fatalf("sample rate = %v, want 0.1", rate)
+	}
+	if tracer.throttle.backoffRemaining() <= 0 {
+		t.Error("Retry-After should start a backoff")
+	}
+
+	// New traces are sampled at roughly the hinted rate
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		s, _ := tracer.StartSpan(context.Background(), "op")
+		if s.span != nil {
+			sampled++
+			if s.span.GetTag("sampling.throttle_rate") != "0.100" {
+				t.Fatalf("throttle_rate tag = %q", s.span.GetTag("sampling.throttle_rate"))
+			}
+		}
+	}
+	if sampled == 0 || sampled > 300 {
+		t.Errorf("sampled %d of 1000 traces at rate 0.1", sampled)
+	}
+
+	// Child spans of a kept trace are never dropped
+	var root *Span
+	var ctx context.Context
+	for root == nil || root.span == nil {
+		root, ctx = tracer.StartSpan(context.Background(), "root")
+	}
+	for i := 0; i < 100; i++ {
+		if child, _ := tracer.StartSpan(ctx, "child"); child.span == nil {
+			t.Fatal("child span of a sampled trace was dropped")
+		}
+	}
+}
+
+func TestThrottleState_NilHintClears(t *testing.T) {
+	var state throttleState
+	state.apply(&models.ThrottleHint{SampleRate: 0.5, BackoffMs: 1000})
+	if state.sampleRate() != 0.5 || state.backoffRemaining() <= 0 {
+		t.Fatal("hint was not applied")
+	}
+
+	state.apply(nil)
+	if state.sampleRate() != 1 || state.backoffRemaining() > 0 {
+		t.Error("nil hint should clear throttling")
+	}
+}
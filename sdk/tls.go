@@ -0,0 +1,80 @@
+package sdk
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ClientTLSConfig locates the files for TLS connections to the collector.
+// With CertFile and KeyFile set the client also presents a certificate, for
+// collectors started with -tls-client-ca (mutual TLS).
+type ClientTLSConfig struct {
+	CAFile     string // PEM CA bundle to verify the collector (default: system roots)
+	CertFile   string // PEM client certificate chain
+	KeyFile    string // PEM client private key
+	ServerName string // Name verified against the collector certificate (default: from the address)
+}
+
+// Load reads the files into a tls.Config for WithTLS or GRPCExporterConfig.
+func (c ClientTLSConfig) Load() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName: c.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("tls: no certificates found in %s", c.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return nil, errors.New("tls: a client certificate and key must be set together")
+	}
+	if c.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("tls: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// WithTLS sends spans to the collector over TLS using config; the collector
+// URL must use https. It also applies to a client set with WithHTTPClient,
+// unless that client has a custom RoundTripper, which must configure TLS
+// itself. For the gRPC exporter set GRPCExporterConfig.TLS.
+func WithTLS(config *tls.Config) TracerOption {
+	return func(t *Tracer) {
+		t.tlsConfig = config
+	}
+}
+
+// clientWithTLS returns a copy of client whose transport uses config.
+func clientWithTLS(client *http.Client, config *tls.Config) *http.Client {
+	var transport *http.Transport
+	switch rt := client.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = rt.Clone()
+	default:
+		return client
+	}
+	transport.TLSClientConfig = config
+
+	withTLS := *client
+	withTLS.Transport = transport
+	return &withTLS
+}
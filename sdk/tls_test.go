@@ -0,0 +1,60 @@
+package sdk
+
+import (
+	"context"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithTLS_ExportsOverHTTPS(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0o600)
+	config, err := ClientTLSConfig{CAFile: caFile}.Load()
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	// The TLS config also applies to a client passed with WithHTTPClient
+	tracer := NewTracer("test-service", server.URL,
+		WithHTTPClient(&http.Client{Timeout: time.Second}),
+		WithTLS(config),
+		WithBatching(BatchConfig{FlushInterval: 10 * time.Millisecond}),
+	)
+	span, _ := tracer.StartSpan(context.Background(), "op")
+	span.Finish()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown failed: %v", err)
+	}
+	if received.Load() != 1 {
+		t.Errorf("collector received %d batches, want 1", received.Load())
+	}
+}
+
+func TestClientTLSConfig_Load(t *testing.T) {
+	if _, err := (ClientTLSConfig{CertFile: "client.pem"}).Load(); err == nil {
+		t.Error("expected error for a certificate without a key")
+	}
+	if _, err := (ClientTLSConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}).Load(); err == nil {
+		t.Error("expected error for a missing CA file")
+	}
+	config, err := ClientTLSConfig{ServerName: "collector.internal"}.Load()
+	if err != nil || config.ServerName != "collector.internal" || config.RootCAs != nil {
+		t.Errorf("config = %+v, err %v; want system roots and the server name", config, err)
+	}
+}
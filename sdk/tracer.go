@@ -0,0 +1,485 @@
+package sdk
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// Tracer is the main entry point for instrumentation
+// It provides methods to create and manage spans
+type Tracer struct {
+	serviceName  string
+	collectorUrl string
+	client       *http.Client
+	tlsConfig    *tls.Config // Applied to client (see WithTLS)
+	sampler      Sampler
+	logger       *slog.Logger
+
+	// Finished spans are batched over HTTP unless a gRPC exporter is set
+	batcher      *batchExporter
+	batchConfig  BatchConfig
+	grpcExporter *GRPCExporter
+
+	// Which spans are dropped first under queue pressure, nil for none
+	// (see priority.go)
+	exportPolicy *ExportPolicy
+
+	// Collector flow-control hints from HTTP responses (see throttle.go)
+	throttle throttleState
+
+	// Sampling rules polled from the collector, nil when disabled
+	// (see remote_sampler.go)
+	remoteSampling *RemoteSamplingConfig
+
+	// Spans slower than this capture the finishing goroutine's stack (0 = disabled)
+	slowSpanThreshold time.Duration
+
+	// Deployment set on every span (see WithServiceDeployment)
+	deploymentID, gitSHA, environment string
+
+	// Watchdog reporting of spans left open too long (see watchdog.go)
+	watchdogThreshold time.Duration
+	activeMu          sync.Mutex
+	active            map[string]*Span // spanID -> open span, only tracked while the watchdog runs
+
+	// Lifecycle
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// maxStackSize caps the size of a captured stack so slow spans stay small on the wire
+const maxStackSize = 8 * 1024
+
+// Sampler determines whether a new trace should be sampled. It is consulted
+// for root spans only; child spans follow their parent's decision.
+// See RuleSampler for per-operation rates.
+type Sampler interface {
+	ShouldSample(operationName string) bool
+}
+
+// AlwaysSampler samples every span
+type AlwaysSampler struct{}
+
+func (s *AlwaysSampler) ShouldSample(operationName string) bool {
+	return true
+}
+
+// Span represents an active span in the SDK
+// It wraps the underlying models.Span and provides methods to manage it
+type Span struct {
+	tracer    *Tracer
+	span      *models.Span
+	startTime time.Time
+
+	mu       sync.Mutex // Guards span against concurrent watchdog snapshots
+	reported bool       // Set once the watchdog has reported this span as unfinished
+
+	// W3C tracestate propagated to outgoing requests, inherited from the
+	// parent span or the incoming request; guarded by mu
+	traceState TraceState
+}
+
+// Option is a function that configures a span
+type Option func(*Span)
+
+// TracerOption configures a Tracer in NewTracer. A Tracer cannot be changed
+// after construction, so it is safe to share between goroutines.
+type TracerOption func(*Tracer)
+
+// NewTracer creates a new tracer for the given service
+func NewTracer(serviceName, collectorUrl string, opts ...TracerOption) *Tracer {
+	t := &Tracer{
+		serviceName:  serviceName,
+		collectorUrl: collectorUrl,
+		client: &http.Client{
+			Timeout: 5 * time.Second,
+		},
+		sampler: &AlwaysSampler{},
+		logger:  slog.Default(),
+		stopCh:  make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	if t.tlsConfig != nil {
+		t.client = clientWithTLS(t.client, t.tlsConfig)
+	}
+
+	if t.grpcExporter == nil {
+		t.batcher = newBatchExporter(t.client, t.collectorUrl, t.logger, &t.throttle, t.batchConfig)
+	}
+	t.startWatchdog()
+	t.startRemoteSampling()
+	return t
+}
+
+// WithHTTPClient sets a custom http client
+func WithHTTPClient(client *http.Client) TracerOption {
+	return func(t *Tracer) {
+		t.client = client
+	}
+}
+
+// WithSampler sets a custom sampler
+func WithSampler(sampler Sampler) TracerOption {
+	return func(t *Tracer) {
+		t.sampler = sampler
+	}
+}
+
+// WithLogger sets a custom logger
+func WithLogger(logger *slog.Logger) TracerOption {
+	return func(t *Tracer) {
+		t.logger = logger
+	}
+}
+
+// WithGRPCExporter sends finished spans through a streaming gRPC exporter
+// instead of HTTP batches. The tracer shuts it down in Shutdown.
+func WithGRPCExporter(exporter *GRPCExporter) TracerOption {
+	return func(t *Tracer) {
+		t.grpcExporter = exporter
+	}
+}
+
+// WithSlowSpanThreshold enables stack capture for spans whose duration
+// exceeds threshold at Finish. The stack is recorded on the span as a cheap
+// way to pinpoint slow code paths without running a profiler.
+func WithSlowSpanThreshold(threshold time.Duration) TracerOption {
+	return func(t *Tracer) {
+		t.slowSpanThreshold = threshold
+	}
+}
+
+// WithServiceDeployment sets the deployment of every span the tracer starts,
+// including those of Middleware and ClientMiddleware, so the collector can
+// compare deployments. WithDeployment overrides it for a span.
+func WithServiceDeployment(deploymentID, gitSHA, environment string) TracerOption {
+	return func(t *Tracer) {
+		t.deploymentID = deploymentID
+		t.gitSHA = gitSHA
+		t.environment = environment
+	}
+}
+
+// Shutdown stops the tracer's background goroutines and drains queued spans
+// to the collector, waiting until they are sent or ctx expires. Spans
+// finished after Shutdown are dropped.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.stopOnce.Do(func() {
+		close(t.stopCh)
+	})
+	if t.grpcExporter != nil {
+		return t.grpcExporter.Shutdown(ctx)
+	}
+	return t.batcher.shutdown(ctx)
+}
+
+// StartSpan creates and starts a new span
+func (t *Tracer) StartSpan(ctx context.Context, operationName string, opts ...Option) (*Span, context.Context) {
+	// Get or create trace ID
+	var traceID string
+	var parentSpanID string
+	throttleRate := 1.0
+	var traceState TraceState
+
+	// Try to get parent span from context
+	parent := SpanFromContext(ctx)
+	if parent != nil && parent.span == nil {
+		// Parent was not sampled; drop its descendants too so traces stay whole
+		return t.noopSpan(ctx)
+	}
+	if parent != nil {
+		traceID = parent.span.TraceID
+		parentSpanID = parent.span.SpanID
+		traceState = parent.TraceState()
+	} else {
+		// Try to extract from W3C Trace Context in context
+		if tc := traceContextFromContext(ctx); tc != nil {
+			traceID = tc.TraceID
+			parentSpanID = tc.SpanID
+			traceState = tc.TraceState
+		} else {
+			// Sampling decisions are made for new traces only, so
+			// traces already in progress stay complete
+			if !t.sampler.ShouldSample(operationName) {
+				return t.noopSpan(ctx)
+			}
+
+			// Honor collector throttling
+			throttleRate = t.throttleState().sampleRate()
+			if throttleRate < 1 && rand.Float64() >= throttleRate {
+				return t.noopSpan(ctx)
+			}
+
+			// CREATE NEW TRACE
+			traceID = models.GenerateTraceID()
+		}
+	}
+
+	// Create span
+	span := &Span{
+		tracer:     t,
+		traceState: traceState,
+		startTime:  time.Now(),
+		span: &models.Span{
+			TraceID:       traceID,
+			SpanID:        models.GenerateSpanID(),
+			ParentSpanID:  parentSpanID,
+			ServiceName:   t.serviceName,
+			OperationName: operationName,
+			StartTime:     time.Now(),
+			SpanKind:      "internal", // Default
+			Status:        "ok",       // Default
+			Tags:          make(map[string]string),
+			DeploymentID:  t.deploymentID,
+			GitSHA:        t.gitSHA,
+			Environment:   t.environment,
+		},
+	}
+
+	// Record the rate so the backend can reweight counts
+	if throttleRate < 1 {
+		span.span.Tags["sampling.throttle_rate"] = strconv.FormatFloat(throttleRate, 'f', 3, 64)
+	}
+
+	// Apply options
+	for _, opt := range opts {
+		opt(span)
+	}
+
+	// Track open span for the watchdog
+	t.trackSpan(span)
+
+	// Add span to context
+	ctx = ContextWithSpan(ctx, span)
+
+	return span, ctx
+}
+
+// noopSpan returns an unsampled span. It is stored in the context so child
+// spans are skipped as well.
+func (t *Tracer) noopSpan(ctx context.Context) (*Span, context.Context) {
+	span := &Span{tracer: t}
+	return span, ContextWithSpan(ctx, span)
+}
+
+// Finish completes the span and sends it to the collector asynchronously.
+func (s *Span) Finish() {
+	if s.span == nil {
+		return // No-op span
+	}
+
+	s.tracer.untrackSpan(s)
+
+	s.mu.Lock()
+	// Calculate duration
+	s.span.Duration = time.Since(s.startTime)
+
+	// Capture the stack for slow spans
+	if threshold := s.tracer.slowSpanThreshold; threshold > 0 && s.span.Duration >= threshold {
+		s.captureStack(threshold)
+	}
+	s.mu.Unlock()
+
+	// Queue for export (never blocks)
+	s.tracer.export(s.span)
+}
+
+// captureStack records the current goroutine stack as a "slow_span" event.
+func (s *Span) captureStack(threshold time.Duration) {
+	stack := debug.Stack()
+	if len(stack) > maxStackSize {
+		stack = stack[:maxStackSize]
+	}
+
+	s.addEvent("slow_span", map[string]string{
+		"threshold_ms": strconv.FormatInt(threshold.Milliseconds(), 10),
+		"stack":        string(stack),
+	})
+}
+
+// AddEvent records a timestamped milestone within the span, such as a cache
+// miss or a retry attempt. Events past models.MaxSpanEvents are discarded.
+func (s *Span) AddEvent(name string, attrs map[string]string) *Span {
+	if s.span != nil {
+		s.mu.Lock()
+		s.addEvent(name, attrs)
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// addEvent appends an event; callers hold s.mu.
+func (s *Span) addEvent(name string, attrs map[string]string) {
+	if len(s.span.Events) >= models.MaxSpanEvents {
+		return
+	}
+	s.span.AddEvent(name, time.Now(), attrs)
+}
+
+// TraceState returns the W3C tracestate propagated by this span.
+func (s *Span) TraceState() TraceState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.traceState
+}
+
+// SetTraceState sets a vendor's tracestate entry, moving it to the front.
+// Children started afterwards and outgoing requests carry the new state.
+func (s *Span) SetTraceState(key, value string) error {
+	if s.span == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ts, err := s.traceState.Insert(key, value)
+	if err != nil {
+		return err
+	}
+	s.traceState = ts
+	return nil
+}
+
+// DeleteTraceState removes a vendor's tracestate entry.
+func (s *Span) DeleteTraceState(key string) *Span {
+	if s.span != nil {
+		s.mu.Lock()
+		s.traceState = s.traceState.Delete(key)
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// SetTag adds a tag to the span.
+func (s *Span) SetTag(key, value string) *Span {
+	if s.span != nil {
+		s.mu.Lock()
+		s.span.SetTag(key, value)
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// SetError marks the span as failed and records the error.
+func (s *Span) SetError(err error) *Span {
+	if s.span != nil && err != nil {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.span.Status = "error"
+		s.span.StatusMessage = err.Error()
+		s.span.SetTag("error", "true")
+		s.span.SetTag("error.message", err.Error())
+	}
+	return s
+}
+
+// SetStatus sets the span status.
+func (s *Span) SetStatus(status string) *Span {
+	if s.span != nil {
+		s.mu.Lock()
+		s.span.Status = status
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// SetSpanKind sets the span kind.
+func (s *Span) SetSpanKind(kind string) *Span {
+	if s.span != nil {
+		s.mu.Lock()
+		s.span.SpanKind = kind
+		s.mu.Unlock()
+	}
+	return s
+}
+
+// TraceID returns the trace ID of this span.
+func (s *Span) TraceID() string {
+	if s.span != nil {
+		return s.span.TraceID
+	}
+	return ""
+}
+
+// SpanID returns the span ID of this span.
+func (s *Span) SpanID() string {
+	if s.span != nil {
+		return s.span.SpanID
+	}
+	return ""
+}
+
+// export queues a span for the configured exporter without blocking.
+func (t *Tracer) export(span *models.Span) {
+	if !t.admitExport(span) {
+		return
+	}
+	if t.grpcExporter != nil {
+		t.grpcExporter.Export(span)
+		return
+	}
+	t.batcher.export(span)
+}
+
+// Option functions
+
+// WithParent sets the parent span.
+func WithParent(parent *Span) Option {
+	return func(s *Span) {
+		if parent != nil && parent.span != nil {
+			s.span.TraceID = parent.span.TraceID
+			s.span.ParentSpanID = parent.span.SpanID
+		}
+	}
+}
+
+// WithTags sets multiple tags on the span.
+func WithTags(tags map[string]string) Option {
+	return func(s *Span) {
+		if s.span != nil {
+			for k, v := range tags {
+				s.span.SetTag(k, v)
+			}
+		}
+	}
+}
+
+// WithSpanKind sets the span kind.
+func WithSpanKind(kind string) Option {
+	return func(s *Span) {
+		if s.span != nil {
+			s.span.SpanKind = kind
+		}
+	}
+}
+
+// WithDeployment sets deployment information.
+func WithDeployment(deploymentID, gitSHA, environment string) Option {
+	return func(s *Span) {
+		if s.span != nil {
+			s.span.DeploymentID = deploymentID
+			s.span.GitSHA = gitSHA
+			s.span.Environment = environment
+		}
+	}
+}
+
+// WithProfiling enables profiling for this span (Later).
+func WithProfiling() Option {
+	return func(s *Span) {
+		if s.span != nil {
+			s.span.HasProfile = true
+			// Profiling will be implemented in Later Weeks
+		}
+	}
+}
@@ -1,18 +1,21 @@
-package instrumentation
+package sdk
 
 import (
 	"context"
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/saintparish4/asmbly/models"
 )
 
 // Mock collector server for testing
 func mockCollector(t *testing.T) *httptest.Server {
 	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/api/v1/spans" {
+		if r.URL.Path != "/api/v1/spans/batch" {
 			t.Errorf("unexpected path: %s", r.URL.Path)
 		}
 		if r.Method != http.MethodPost {
@@ -39,6 +42,29 @@ func TestNewTracer(t *testing.T) {
 	}
 }
 
+type neverSampler struct{}
+
+func (neverSampler) ShouldSample(operationName string) bool { return false }
+
+func TestNewTracer_Options(t *testing.T) {
+	client := &http.Client{Timeout: time.Second}
+	tracer := NewTracer("test-service", "http://localhost:9090",
+		WithHTTPClient(client),
+		WithSampler(neverSampler{}),
+		WithSlowSpanThreshold(time.Second),
+	)
+
+	if tracer.client != client {
+		t.Error("WithHTTPClient was not applied")
+	}
+	if tracer.slowSpanThreshold != time.Second {
+		t.Errorf("slowSpanThreshold = %v, want 1s", tracer.slowSpanThreshold)
+	}
+	if span, _ := tracer.StartSpan(context.Background(), "op"); span.span != nil {
+		t.Error("WithSampler was not applied: span was sampled")
+	}
+}
+
 func TestStartSpan_CreatesSpan(t *testing.T) {
 	tracer := NewTracer("test-service", "http://localhost:9090")
 	ctx := context.Background()
@@ -142,6 +168,63 @@ func TestSpan_Finish(t *testing.T) {
 	}
 }
 
+func TestSpan_Finish_CapturesStackWhenSlow(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL, WithSlowSpanThreshold(5*time.Millisecond))
+
+	slow, _ := tracer.StartSpan(context.Background(), "slow-operation")
+	time.Sleep(10 * time.Millisecond)
+	slow.Finish()
+
+	if len(slow.span.Events) != 1 || slow.span.Events[0].Name != "slow_span" {
+		t.Fatalf("slow span events = %v, want one slow_span event", slow.span.Events)
+	}
+	event := slow.span.Events[0]
+	if !strings.Contains(event.Attributes["stack"], "goroutine") {
+		t.Error("slow span should carry a captured goroutine stack")
+	}
+	if event.Attributes["threshold_ms"] != "5" {
+		t.Errorf("threshold_ms = %s, want 5", event.Attributes["threshold_ms"])
+	}
+
+	fast, _ := tracer.StartSpan(context.Background(), "fast-operation")
+	fast.Finish()
+
+	if len(fast.span.Events) != 0 {
+		t.Error("fast span should not carry a stack")
+	}
+
+	// Wait for async send
+	time.Sleep(50 * time.Millisecond)
+}
+
+func TestSpan_AddEvent(t *testing.T) {
+	tracer := NewTracer("test-service", "http://localhost:9090")
+	span, _ := tracer.StartSpan(context.Background(), "test-operation")
+
+	span.AddEvent("cache.miss", map[string]string{"key": "user:42"})
+	for i := 0; i < models.MaxSpanEvents; i++ {
+		span.AddEvent("retry", nil)
+	}
+
+	if len(span.span.Events) != models.MaxSpanEvents {
+		t.Fatalf("events = %d, want capped at %d", len(span.span.Events), models.MaxSpanEvents)
+	}
+	first := span.span.Events[0]
+	if first.Name != "cache.miss" || first.Attributes["key"] != "user:42" || first.Timestamp.IsZero() {
+		t.Errorf("unexpected first event: %+v", first)
+	}
+	if err := span.span.Validate(); err != nil {
+		t.Errorf("span with events failed validation: %v", err)
+	}
+
+	// No-op spans ignore events
+	noop := &Span{}
+	noop.AddEvent("ignored", nil)
+}
+
 func TestWithTags(t *testing.T) {
 	tracer := NewTracer("test-service", "http://localhost:9090")
 	ctx := context.Background()
@@ -172,6 +255,21 @@ func TestWithSpanKind(t *testing.T) {
 	}
 }
 
+func TestWithServiceDeployment(t *testing.T) {
+	tracer := NewTracer("test-service", "http://localhost:9090", WithServiceDeployment("v2.3.1", "abc123", "staging"))
+	ctx := context.Background()
+
+	span, _ := tracer.StartSpan(ctx, "test-operation")
+	if span.span.DeploymentID != "v2.3.1" || span.span.GitSHA != "abc123" || span.span.Environment != "staging" {
+		t.Errorf("deployment = %q %q %q, want the tracer's", span.span.DeploymentID, span.span.GitSHA, span.span.Environment)
+	}
+
+	span, _ = tracer.StartSpan(ctx, "test-operation", WithDeployment("canary", "def456", "prod"))
+	if span.span.DeploymentID != "canary" || span.span.Environment != "prod" {
+		t.Errorf("deployment = %q in %q, want WithDeployment to override", span.span.DeploymentID, span.span.Environment)
+	}
+}
+
 // Trace Context Tests
 
 func TestEncodeTraceParent(t *testing.T) {
@@ -0,0 +1,119 @@
+package sdk
+
+import (
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+// minWatchdogInterval bounds how often the watchdog scans open spans
+const minWatchdogInterval = 10 * time.Millisecond
+
+// WithWatchdog starts a background watchdog that reports spans still open
+// after threshold. Each such span is sent once as an in-progress partial span
+// tagged unfinished=true so hung requests show up in the collector before (or
+// instead of) finishing. The watchdog stops on Tracer.Shutdown.
+func WithWatchdog(threshold time.Duration) TracerOption {
+	return func(t *Tracer) {
+		t.watchdogThreshold = threshold
+	}
+}
+
+// startWatchdog starts the watchdog if WithWatchdog enabled it.
+func (t *Tracer) startWatchdog() {
+	if t.watchdogThreshold <= 0 {
+		return
+	}
+
+	t.active = make(map[string]*Span)
+
+	interval := t.watchdogThreshold / 2
+	if interval < minWatchdogInterval {
+		interval = minWatchdogInterval
+	}
+	go t.runWatchdog(interval)
+}
+
+// trackSpan registers an open span with the watchdog (no-op when disabled).
+func (t *Tracer) trackSpan(span *Span) {
+	t.activeMu.Lock()
+	defer t.activeMu.Unlock()
+
+	if t.active != nil {
+		t.active[span.span.SpanID] = span
+	}
+}
+
+// untrackSpan removes a finished span from the watchdog.
+func (t *Tracer) untrackSpan(span *Span) {
+	t.activeMu.Lock()
+	defer t.activeMu.Unlock()
+
+	if t.active != nil {
+		delete(t.active, span.span.SpanID)
+	}
+}
+
+// runWatchdog periodically reports spans open longer than the threshold.
+func (t *Tracer) runWatchdog(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.stopCh:
+			return
+		case <-ticker.C:
+			for _, partial := range t.collectHungSpans() {
+				t.logger.Warn("span still open past watchdog threshold",
+					"trace_id", partial.TraceID,
+					"span_id", partial.SpanID,
+					"operation", partial.OperationName,
+					"open_for", partial.Duration,
+				)
+				t.export(partial)
+			}
+		}
+	}
+}
+
+// collectHungSpans snapshots every unreported span open past the threshold.
+func (t *Tracer) collectHungSpans() []*models.Span {
+	t.activeMu.Lock()
+	defer t.activeMu.Unlock()
+
+	var hung []*models.Span
+	for _, span := range t.active {
+		if time.Since(span.startTime) < t.watchdogThreshold {
+			continue
+		}
+		if partial := span.snapshotUnfinished(); partial != nil {
+			hung = append(hung, partial)
+		}
+	}
+	return hung
+}
+
+// snapshotUnfinished returns a copy of the span flagged as unfinished, or nil
+// if it was already reported.
+func (s *Span) snapshotUnfinished() *models.Span {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reported {
+		return nil
+	}
+	s.reported = true
+
+	partial := *s.span
+	partial.Tags = make(map[string]string, len(s.span.Tags)+1)
+	for k, v := range s.span.Tags {
+		partial.Tags[k] = v
+	}
+	partial.Events = append([]models.SpanEvent(nil), s.span.Events...)
+	partial.Duration = time.Since(s.startTime)
+	partial.InProgress = true
+	partial.SetTag("unfinished", "true")
+
+	return &partial
+}
@@ -0,0 +1,91 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/saintparish4/asmbly/models"
+)
+
+func TestWatchdog_ReportsHungSpanOnce(t *testing.T) {
+	var mu sync.Mutex
+	var received []models.Span
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var spans []models.Span
+		if err := json.NewDecoder(r.Body).Decode(&spans); err != nil {
+			t.Errorf("failed to decode spans: %v", err)
+		}
+		mu.Lock()
+		received = append(received, spans...)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL,
+		WithWatchdog(20*time.Millisecond),
+		WithBatching(BatchConfig{FlushInterval: 5 * time.Millisecond}),
+	)
+	defer tracer.Shutdown(context.Background())
+
+	span, _ := tracer.StartSpan(context.Background(), "hung-operation")
+
+	// Several watchdog intervals pass while the span stays open
+	time.Sleep(100 * time.Millisecond)
+
+	mu.Lock()
+	if len(received) != 1 {
+		t.Fatalf("received %d partial spans, want 1", len(received))
+	}
+	partial := received[0]
+	mu.Unlock()
+
+	if partial.SpanID != span.SpanID() {
+		t.Errorf("partial SpanID = %s, want %s", partial.SpanID, span.SpanID())
+	}
+	if !partial.InProgress {
+		t.Error("partial span should be marked in_progress")
+	}
+	if partial.Tags["unfinished"] != "true" {
+		t.Errorf("unfinished tag = %q, want true", partial.Tags["unfinished"])
+	}
+	if partial.Duration < 20*time.Millisecond {
+		t.Errorf("partial Duration = %v, want >= 20ms", partial.Duration)
+	}
+
+	// The finished span is sent normally and is not flagged
+	span.Finish()
+	time.Sleep(50 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 {
+		t.Fatalf("received %d spans, want 2", len(received))
+	}
+	if received[1].Tags["unfinished"] != "" {
+		t.Error("finished span should not be flagged unfinished")
+	}
+}
+
+func TestWatchdog_IgnoresFastSpans(t *testing.T) {
+	server := mockCollector(t)
+	defer server.Close()
+
+	tracer := NewTracer("test-service", server.URL, WithWatchdog(time.Second))
+	defer tracer.Shutdown(context.Background())
+
+	span, _ := tracer.StartSpan(context.Background(), "fast-operation")
+	span.Finish()
+
+	tracer.activeMu.Lock()
+	defer tracer.activeMu.Unlock()
+	if len(tracer.active) != 0 {
+		t.Errorf("active spans = %d, want 0 after Finish", len(tracer.active))
+	}
+}